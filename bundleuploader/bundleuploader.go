@@ -0,0 +1,390 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package bundleuploader uploads diagnostic bundles of failed update sessions to the backend.
+package bundleuploader
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/utils/cryptutils"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Bundles are stored in the upload dir and uploaded in background one by one starting from the oldest one, so bundles
+// of failed sessions survive reboots and connection loss. Each bundle is sent by HTTPS POST request authenticated by
+// the device certificate, and its SHA-256 digest is signed by the device key, the signature is sent in the signature
+// header. Failed upload is retried with exponential backoff. Bundle rejected by the backend with client error is
+// removed as it can't be accepted by retry. Bundle which exceeds the size limit is not stored, and the oldest bundles
+// are removed if total size of stored bundles exceeds the limit.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Upload request headers.
+const (
+	BundleNameHeader = "X-Aos-Bundle-Name"
+	SignatureHeader  = "X-Aos-Signature"
+)
+
+const (
+	maxRetryInterval = time.Hour
+	bundleTmpExt     = ".tmp"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// CertificateProvider provides device certificate.
+type CertificateProvider interface {
+	GetCertificate(certType string) (certURL, keyURL string, err error)
+}
+
+// Uploader diagnostic bundle uploader.
+type Uploader struct {
+	sync.Mutex
+
+	config        config.DiagnosticUpload
+	certStorage   string
+	certProvider  CertificateProvider
+	cryptoContext *cryptutils.CryptoContext
+	trigger       chan struct{}
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates diagnostic bundle uploader and starts upload of stored bundles.
+func New(
+	cfg *config.Config, certProvider CertificateProvider, cryptoContext *cryptutils.CryptoContext,
+) (uploader *Uploader, err error) {
+	if cfg.DiagnosticUpload.URL == "" {
+		return nil, aoserrors.New("diagnostic upload URL is not set")
+	}
+
+	if err = os.MkdirAll(cfg.DiagnosticUpload.Dir, 0o755); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	uploader = &Uploader{
+		config:        cfg.DiagnosticUpload,
+		certStorage:   cfg.CertStorage,
+		certProvider:  certProvider,
+		cryptoContext: cryptoContext,
+		trigger:       make(chan struct{}, 1),
+	}
+
+	var ctx context.Context
+
+	ctx, uploader.cancel = context.WithCancel(context.Background())
+
+	uploader.wg.Add(1)
+
+	go uploader.run(ctx)
+
+	return uploader, nil
+}
+
+// Close stops bundle upload, not uploaded bundles are uploaded on next start.
+func (uploader *Uploader) Close() {
+	uploader.cancel()
+	uploader.wg.Wait()
+}
+
+// UploadBundle stores the bundle and uploads it in background.
+func (uploader *Uploader) UploadBundle(name string, data []byte) (err error) {
+	if uint64(len(data)) > uploader.config.MaxBundleSize {
+		return aoserrors.Errorf("bundle size %d exceeds limit %d", len(data), uploader.config.MaxBundleSize)
+	}
+
+	if name == "" || filepath.Base(name) != name || strings.HasSuffix(name, bundleTmpExt) {
+		return aoserrors.Errorf("wrong bundle name %s", name)
+	}
+
+	uploader.Lock()
+	defer uploader.Unlock()
+
+	filePath := filepath.Join(uploader.config.Dir, name)
+
+	if err = os.WriteFile(filePath+bundleTmpExt, data, 0o600); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = os.Rename(filePath+bundleTmpExt, filePath); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	uploader.removeExcessBundles()
+
+	select {
+	case uploader.trigger <- struct{}{}:
+
+	default:
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (uploader *Uploader) run(ctx context.Context) {
+	defer uploader.wg.Done()
+
+	retryInterval := uploader.config.RetryInterval.Duration
+
+	for {
+		if err := uploader.uploadBundles(ctx); err != nil {
+			log.WithField("retryInterval", retryInterval).Warnf("Can't upload diagnostic bundle: %v", err)
+
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-time.After(retryInterval):
+			}
+
+			if retryInterval *= 2; retryInterval > maxRetryInterval {
+				retryInterval = maxRetryInterval
+			}
+
+			continue
+		}
+
+		retryInterval = uploader.config.RetryInterval.Duration
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-uploader.trigger:
+		}
+	}
+}
+
+// uploadBundles uploads stored bundles starting from the oldest one.
+func (uploader *Uploader) uploadBundles(ctx context.Context) (err error) {
+	for ctx.Err() == nil {
+		uploader.Lock()
+		bundles, err := uploader.getBundles()
+		uploader.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		if len(bundles) == 0 {
+			return nil
+		}
+
+		name := bundles[0].Name()
+
+		rejected, uploadErr := uploader.uploadBundle(ctx, bundles[0])
+
+		switch {
+		case uploadErr == nil:
+			log.WithField("name", name).Info("Diagnostic bundle uploaded")
+
+		case ctx.Err() != nil:
+			return nil
+
+		case rejected:
+			log.WithField("name", name).Errorf("Diagnostic bundle is rejected: %v", uploadErr)
+
+		default:
+			return uploadErr
+		}
+
+		if err = uploader.removeBundle(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (uploader *Uploader) uploadBundle(
+	ctx context.Context, bundle os.FileInfo,
+) (rejected bool, err error) {
+	data, err := os.ReadFile(filepath.Join(uploader.config.Dir, bundle.Name()))
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	certURL, keyURL, err := uploader.certProvider.GetCertificate(uploader.certStorage)
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	tlsConfig, err := uploader.cryptoContext.GetClientMutualTLSConfig(certURL, keyURL)
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	signature, err := uploader.sign(keyURL, data)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, uploader.config.Timeout.Duration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploader.config.URL, bytes.NewReader(data))
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set(BundleNameHeader, bundle.Name())
+	req.Header.Set(SignatureHeader, signature)
+
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return false, nil
+	}
+
+	// Client errors except timeout and rate limit mean the bundle can't be accepted
+	rejected = resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+
+	return rejected, aoserrors.Errorf("upload status: %s", resp.Status)
+}
+
+// sign signs SHA-256 digest of the data by device key and returns base64 encoded signature.
+func (uploader *Uploader) sign(keyURL string, data []byte) (signature string, err error) {
+	key, _, err := uploader.cryptoContext.LoadPrivateKeyByURL(keyURL)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "", aoserrors.New("device key doesn't support signing")
+	}
+
+	digest := sha256.Sum256(data)
+
+	rawSignature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(rawSignature), nil
+}
+
+// getBundles returns stored bundles sorted from the oldest one. It is called under uploader lock.
+func (uploader *Uploader) getBundles() (bundles []os.FileInfo, err error) {
+	entries, err := os.ReadDir(uploader.config.Dir)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), bundleTmpExt) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		bundles = append(bundles, info)
+	}
+
+	sort.SliceStable(bundles, func(i, j int) bool {
+		if bundles[i].ModTime().Equal(bundles[j].ModTime()) {
+			return bundles[i].Name() < bundles[j].Name()
+		}
+
+		return bundles[i].ModTime().Before(bundles[j].ModTime())
+	})
+
+	return bundles, nil
+}
+
+func (uploader *Uploader) removeBundle(name string) (err error) {
+	uploader.Lock()
+	defer uploader.Unlock()
+
+	if err = os.RemoveAll(filepath.Join(uploader.config.Dir, name)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// removeExcessBundles removes the oldest bundles if total size exceeds the limit. It is called under uploader lock.
+func (uploader *Uploader) removeExcessBundles() {
+	bundles, err := uploader.getBundles()
+	if err != nil {
+		log.Errorf("Can't get diagnostic bundles: %v", err)
+
+		return
+	}
+
+	var totalSize uint64
+
+	for _, bundle := range bundles {
+		totalSize += uint64(bundle.Size())
+	}
+
+	for _, bundle := range bundles {
+		if totalSize <= uploader.config.MaxTotalSize {
+			return
+		}
+
+		log.WithField("name", bundle.Name()).Warn("Remove diagnostic bundle exceeding total size limit")
+
+		if err := os.RemoveAll(filepath.Join(uploader.config.Dir, bundle.Name())); err != nil {
+			log.Errorf("Can't remove diagnostic bundle: %v", err)
+
+			return
+		}
+
+		totalSize -= uint64(bundle.Size())
+	}
+}
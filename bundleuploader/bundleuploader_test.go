@@ -0,0 +1,379 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package bundleuploader_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/utils/cryptutils"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/bundleuploader"
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const waitUploadTimeout = 5 * time.Second
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testCertProvider struct {
+	certURL string
+	keyURL  string
+}
+
+type testServer struct {
+	sync.Mutex
+	*httptest.Server
+	statuses []int
+	uploaded chan string
+	caFile   string
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+var certProvider testCertProvider
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	if tmpDir, err = os.MkdirTemp("", "um_"); err != nil {
+		log.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	if certProvider, err = createDeviceCert(tmpDir); err != nil {
+		log.Fatalf("Can't create device certificate: %s", err)
+	}
+
+	ret := m.Run()
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Fatalf("Error removing tmp dir: %s", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestUpload(t *testing.T) {
+	// The first upload fails and is retried, the second bundle is rejected and removed
+
+	server, err := newTestServer(http.StatusServiceUnavailable, http.StatusOK, http.StatusBadRequest)
+	if err != nil {
+		t.Fatalf("Can't create test server: %s", err)
+	}
+	defer server.Close()
+
+	cryptoContext, err := cryptutils.NewCryptoContext(server.caFile)
+	if err != nil {
+		t.Fatalf("Can't create crypto context: %s", err)
+	}
+	defer cryptoContext.Close()
+
+	cfg := newTestConfig(server.URL, "upload")
+
+	uploader, err := bundleuploader.New(cfg, &certProvider, cryptoContext)
+	if err != nil {
+		t.Fatalf("Can't create uploader: %s", err)
+	}
+	defer uploader.Close()
+
+	if err = uploader.UploadBundle("bundle1.tar.gz", []byte("bundle1")); err != nil {
+		t.Fatalf("Can't upload bundle: %s", err)
+	}
+
+	for _, expectedName := range []string{"bundle1.tar.gz", "bundle1.tar.gz"} {
+		if err = server.waitUpload(expectedName); err != nil {
+			t.Errorf("Wait upload failed: %s", err)
+		}
+	}
+
+	if err = uploader.UploadBundle("bundle2.tar.gz", []byte("bundle2")); err != nil {
+		t.Fatalf("Can't upload bundle: %s", err)
+	}
+
+	if err = server.waitUpload("bundle2.tar.gz"); err != nil {
+		t.Errorf("Wait upload failed: %s", err)
+	}
+
+	if err = waitBundles(cfg.DiagnosticUpload.Dir); err != nil {
+		t.Errorf("Wait bundles failed: %s", err)
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	// Unavailable backend keeps bundles stored
+
+	server, err := newTestServer()
+	if err != nil {
+		t.Fatalf("Can't create test server: %s", err)
+	}
+
+	server.Close()
+
+	cryptoContext, err := cryptutils.NewCryptoContext(server.caFile)
+	if err != nil {
+		t.Fatalf("Can't create crypto context: %s", err)
+	}
+	defer cryptoContext.Close()
+
+	cfg := newTestConfig(server.URL, "limits")
+	cfg.DiagnosticUpload.MaxBundleSize = 8
+	cfg.DiagnosticUpload.MaxTotalSize = 16
+	cfg.DiagnosticUpload.RetryInterval = aostypes.Duration{Duration: time.Hour}
+
+	uploader, err := bundleuploader.New(cfg, &certProvider, cryptoContext)
+	if err != nil {
+		t.Fatalf("Can't create uploader: %s", err)
+	}
+	defer uploader.Close()
+
+	if err = uploader.UploadBundle("big", []byte("big bundle")); err == nil {
+		t.Error("Error expected for bundle exceeding size limit")
+	}
+
+	for _, name := range []string{"bundle1", "bundle2", "bundle3"} {
+		if err = uploader.UploadBundle(name, []byte(name)); err != nil {
+			t.Fatalf("Can't upload bundle: %s", err)
+		}
+	}
+
+	if err = waitBundles(cfg.DiagnosticUpload.Dir, "bundle2", "bundle3"); err != nil {
+		t.Errorf("Wait bundles failed: %s", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Interfaces
+ **********************************************************************************************************************/
+
+func (provider *testCertProvider) GetCertificate(certType string) (certURL, keyURL string, err error) {
+	return provider.certURL, provider.keyURL, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newTestConfig(url, name string) (cfg *config.Config) {
+	return &config.Config{
+		CertStorage: "um",
+		DiagnosticUpload: config.DiagnosticUpload{
+			URL:           url,
+			Dir:           filepath.Join(tmpDir, name),
+			MaxBundleSize: 1024,
+			MaxTotalSize:  4096,
+			RetryInterval: aostypes.Duration{Duration: 10 * time.Millisecond},
+			Timeout:       aostypes.Duration{Duration: time.Second},
+		},
+	}
+}
+
+func createDeviceCert(dir string) (provider testCertProvider, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return provider, aoserrors.Wrap(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return provider, aoserrors.Wrap(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return provider, aoserrors.Wrap(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "device.crt"), filepath.Join(dir, "device.key")
+
+	if err = cryptutils.SaveCertificateToFile(certFile, []*x509.Certificate{cert}); err != nil {
+		return provider, aoserrors.Wrap(err)
+	}
+
+	if err = cryptutils.SavePrivateKeyToFile(keyFile, key); err != nil {
+		return provider, aoserrors.Wrap(err)
+	}
+
+	return testCertProvider{certURL: "file://" + certFile, keyURL: "file://" + keyFile}, nil
+}
+
+// newTestServer creates TLS server which responds with the statuses in order and then with OK.
+func newTestServer(statuses ...int) (server *testServer, err error) {
+	server = &testServer{statuses: statuses, uploaded: make(chan string, 10)}
+
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.handleUpload))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+
+	server.caFile = filepath.Join(tmpDir, "ca.pem")
+
+	if err = cryptutils.SaveCertificateToFile(
+		server.caFile, []*x509.Certificate{server.Certificate()}); err != nil {
+		server.Close()
+
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return server, nil
+}
+
+func (server *testServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get(bundleuploader.SignatureHeader))
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	publicKey, ok := r.TLS.PeerCertificates[0].PublicKey.(*ecdsa.PublicKey)
+	digest := sha256.Sum256(data)
+
+	if !ok || !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	server.Lock()
+
+	status := http.StatusOK
+
+	if len(server.statuses) != 0 {
+		status, server.statuses = server.statuses[0], server.statuses[1:]
+	}
+
+	server.Unlock()
+
+	server.uploaded <- r.Header.Get(bundleuploader.BundleNameHeader)
+
+	w.WriteHeader(status)
+}
+
+func (server *testServer) waitUpload(expectedName string) (err error) {
+	select {
+	case name := <-server.uploaded:
+		if name != expectedName {
+			return aoserrors.Errorf("wrong bundle uploaded: %s", name)
+		}
+
+		return nil
+
+	case <-time.After(waitUploadTimeout):
+		return aoserrors.New("wait upload timeout")
+	}
+}
+
+// waitBundles waits till the dir contains expected bundles only.
+func waitBundles(dir string, expectedNames ...string) (err error) {
+	var names []string
+
+	for start := time.Now(); time.Since(start) < waitUploadTimeout; time.Sleep(10 * time.Millisecond) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		names = make([]string, 0, len(entries))
+
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+
+		if len(names) != len(expectedNames) {
+			continue
+		}
+
+		match := true
+
+		for i, name := range names {
+			if name != expectedNames[i] {
+				match = false
+			}
+		}
+
+		if match {
+			return nil
+		}
+	}
+
+	return aoserrors.Errorf("wrong bundles: %v", names)
+}
@@ -22,8 +22,31 @@ import (
 	"encoding/json"
 	"os"
 	"path"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+)
+
+/*******************************************************************************
+ * Consts
+ ******************************************************************************/
+
+const defaultDiagnosticsInterval = time.Hour
+
+const (
+	defaultLeaseTTL      = 15 * time.Second
+	defaultLeaseFileName = "updatemanager.lease"
+)
+
+const defaultDeviceIDFile = "/etc/machine-id"
+
+const (
+	defaultDiagnosticBundlesDir    = "diagnosticBundles"
+	defaultDiagnosticBundleSize    = 10 * 1024 * 1024
+	defaultDiagnosticTotalSize     = 50 * 1024 * 1024
+	defaultDiagnosticRetryInterval = time.Minute
+	defaultDiagnosticUploadTimeout = time.Minute
 )
 
 /*******************************************************************************
@@ -36,26 +59,308 @@ type Migration struct {
 	MergedMigrationPath string `json:"mergedMigrationPath"`
 }
 
+// WritableStorage writable storage used to relocate dirs from read-only root filesystem.
+type WritableStorage struct {
+	Path          string `json:"path"`
+	MinFreeSpace  uint64 `json:"minFreeSpace"`
+	MinFreeInodes uint64 `json:"minFreeInodes"`
+}
+
+// DownloadHost TLS settings for image download host.
+type DownloadHost struct {
+	Host             string   `json:"host"`
+	CACert           string   `json:"caCert"`
+	ServerName       string   `json:"serverName"`
+	CertFingerprints []string `json:"certFingerprints"`
+}
+
+// SigningKey trusted image signing key.
+type SigningKey struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+}
+
+// SignaturePolicy image signature policy: image should be signed by at least threshold of listed keys.
+type SignaturePolicy struct {
+	Name      string   `json:"name"`
+	Keys      []string `json:"keys"`
+	Threshold int      `json:"threshold"`
+}
+
+// VerificationPolicy image verification policy: checks which component image should pass at prepare.
+type VerificationPolicy struct {
+	Name      string `json:"name"`
+	Signature bool   `json:"signature"`
+	Hash      bool   `json:"hash"`
+	Size      bool   `json:"size"`
+}
+
+// TrustAnchor CA certificates which detached image signatures should chain to.
+type TrustAnchor struct {
+	Name   string `json:"name"`
+	CACert string `json:"caCert"`
+}
+
+// KeyProvider image decryption key provider plugin.
+type KeyProvider struct {
+	Name   string          `json:"name"`
+	Plugin string          `json:"plugin"`
+	Params json.RawMessage `json:"params"`
+}
+
+// UpdateBlockers conditions which defer component update and apply while they are active.
+type UpdateBlockers struct {
+	Units        []string          `json:"units"`
+	DBusFlags    []DBusFlag        `json:"dbusFlags"`
+	PollInterval aostypes.Duration `json:"pollInterval"`
+}
+
+// DBusFlag boolean D-Bus property on system bus which blocks update when it is true.
+type DBusFlag struct {
+	Destination string `json:"destination"`
+	Path        string `json:"path"`
+	Property    string `json:"property"`
+}
+
+// Standby warm standby settings: instance waits for the lease on shared working dir before it starts update handler
+// and connects to the server.
+type Standby struct {
+	Enabled    bool              `json:"enabled"`
+	InstanceID string            `json:"instanceId"`
+	LeaseFile  string            `json:"leaseFile"`
+	LeaseTTL   aostypes.Duration `json:"leaseTtl"`
+}
+
+// Prefetch background prefetch of hinted images. Prefetch is disabled if dir is not set. Rate limit is in bytes per
+// second, windows are local time ranges in "15:04-15:04" format, prefetch is paused while any pause flag is set,
+// e.g. when network connection is metered.
+type Prefetch struct {
+	Dir          string            `json:"dir"`
+	RateLimit    uint64            `json:"rateLimit"`
+	Windows      []string          `json:"windows"`
+	PauseFlags   []DBusFlag        `json:"pauseFlags"`
+	PollInterval aostypes.Duration `json:"pollInterval"`
+}
+
+// DownloadBandwidth download bandwidth settings. Classes map bandwidth class name to download rate limit in bytes per
+// second, zero rate limit means unlimited download. Default class is applied on start, if it is not set downloads are
+// not limited till bandwidth is changed at runtime. If cap bytes is set, downloaded bytes are additionally capped
+// by cap bytes per cap period, e.g. for metered links.
+type DownloadBandwidth struct {
+	Classes      map[string]uint64 `json:"classes"`
+	DefaultClass string            `json:"defaultClass"`
+	CapBytes     uint64            `json:"capBytes"`
+	CapPeriod    aostypes.Duration `json:"capPeriod"`
+}
+
+// Rollout staged rollout settings. Device ID is used to compute rollout bucket, if it is not set it is read from
+// device ID file.
+type Rollout struct {
+	DeviceID     string `json:"deviceId"`
+	DeviceIDFile string `json:"deviceIdFile"`
+}
+
+// SpeedTest link speed test before large downloads. Speed test is disabled if min download size is not set. Link
+// throughput is probed by ranged request of probe size before prepare which downloads at least min download size.
+// If estimated download time exceeds max download time, warning is reported or prepare is deferred depending on
+// action.
+type SpeedTest struct {
+	MinDownloadSize uint64            `json:"minDownloadSize"`
+	ProbeSize       uint64            `json:"probeSize"`
+	MaxDownloadTime aostypes.Duration `json:"maxDownloadTime"`
+	Action          string            `json:"action"`
+	PollInterval    aostypes.Duration `json:"pollInterval"`
+}
+
+// HealthCheck post-update health check: systemd unit should be active, HTTP probe should return success status or
+// shell command should exit with zero code.
+type HealthCheck struct {
+	Name    string            `json:"name"`
+	Unit    string            `json:"unit"`
+	URL     string            `json:"url"`
+	Command string            `json:"command"`
+	Timeout aostypes.Duration `json:"timeout"`
+}
+
+// Hook update transition hook: shell command or registered hook plugin which is run before (pre stage) or after
+// (post stage) prepare, update, apply or revert. Hook failure policy is "fail" (default) or "ignore".
+type Hook struct {
+	Name    string            `json:"name"`
+	Events  []string          `json:"events"`
+	Stage   string            `json:"stage"`
+	Command string            `json:"command"`
+	Plugin  string            `json:"plugin"`
+	Params  json.RawMessage   `json:"params"`
+	Timeout aostypes.Duration `json:"timeout"`
+	OnError string            `json:"onError"`
+}
+
+// HealthChecks health checks of updated system. Checks are run each poll interval during window, update is reverted
+// if any check fails.
+type HealthChecks struct {
+	Checks       []HealthCheck     `json:"checks"`
+	Window       aostypes.Duration `json:"window"`
+	PollInterval aostypes.Duration `json:"pollInterval"`
+}
+
+// DiagnosticUpload diagnostic bundle upload of failed update sessions. Upload is disabled if URL is not set. Bundles
+// are kept in the dir till they are uploaded, the oldest ones are removed if total size exceeds the limit.
+type DiagnosticUpload struct {
+	URL           string            `json:"url"`
+	Dir           string            `json:"dir"`
+	MaxBundleSize uint64            `json:"maxBundleSize"`
+	MaxTotalSize  uint64            `json:"maxTotalSize"`
+	RetryInterval aostypes.Duration `json:"retryInterval"`
+	Timeout       aostypes.Duration `json:"timeout"`
+}
+
+// ControlServer local control API settings. Control server is disabled if socket path is not set.
+type ControlServer struct {
+	SocketPath string `json:"socketPath"`
+}
+
+// CanaryApply staged update settings. Canary components are updated and checked before the update is applied, other
+// components are updated only if the canary stage succeeds.
+type CanaryApply struct {
+	Components []string      `json:"components"`
+	Checks     []HealthCheck `json:"checks"`
+}
+
+// HealthScore component health score settings. Update failures, reverts and boot failures are counted within the
+// rolling window.
+type HealthScore struct {
+	Window aostypes.Duration `json:"window"`
+}
+
+// JournalCursors journald cursor capture around update phases. The command should print the cursor in journalctl
+// --show-cursor format, journalctl is used if the command is not set.
+type JournalCursors struct {
+	Enabled bool              `json:"enabled"`
+	Command string            `json:"command"`
+	Timeout aostypes.Duration `json:"timeout"`
+}
+
+// FailureInjection debug failure injection settings. Failure injection API is available only in builds with
+// failureinjection tag and is disabled if token is not set.
+type FailureInjection struct {
+	Token string `json:"token"`
+}
+
+// OperationRetry retry of module prepare and update which failed with transient error. Retry is disabled if max
+// retries is not set. Retry delay starts from initial delay and is doubled on each retry up to max delay.
+type OperationRetry struct {
+	MaxRetries   int               `json:"maxRetries"`
+	InitialDelay aostypes.Duration `json:"initialDelay"`
+	MaxDelay     aostypes.Duration `json:"maxDelay"`
+}
+
+// FailedImages retention of downloaded images of failed components for offline analysis. Images are removed with
+// update data if dir is not set. If max images is set, the oldest retained images are removed once it is exceeded.
+type FailedImages struct {
+	Dir       string `json:"dir"`
+	MaxImages int    `json:"maxImages"`
+}
+
+// SpaceCheck free space check of component update targets, e.g. overlay update dir. It is performed before prepare.
+type SpaceCheck struct {
+	Paths         []string `json:"paths"`
+	MinFreeSpace  uint64   `json:"minFreeSpace"`
+	MinFreeInodes uint64   `json:"minFreeInodes"`
+}
+
+// Confirmation local user confirmation of component update requested by campaign. On timeout the update either
+// proceeds or is canceled according to timeout action, update is canceled if the action is not set. Zero timeout means
+// the default timeout of update handler, confirmation is never awaited indefinitely.
+type Confirmation struct {
+	Timeout       aostypes.Duration `json:"timeout"`
+	TimeoutAction string            `json:"timeoutAction"`
+}
+
+// VerifyCommand component verification command.
+type VerifyCommand struct {
+	Command      string            `json:"command"`
+	Timeout      aostypes.Duration `json:"timeout"`
+	ExitCode     int               `json:"exitCode"`
+	OutputRegexp string            `json:"outputRegexp"`
+}
+
 // Config instance.
 type Config struct {
-	CMServerURL        string         `json:"cmServerUrl"`
-	IAMPublicServerURL string         `json:"iamPublicServerUrl"`
-	CACert             string         `json:"caCert"`
-	CertStorage        string         `json:"certStorage"`
-	WorkingDir         string         `json:"workingDir"`
-	DownloadDir        string         `json:"downloadDir"`
-	UpdateModules      []ModuleConfig `json:"updateModules"`
-	Migration          Migration      `json:"migration"`
+	CMServerURL            string               `json:"cmServerUrl"`
+	IAMPublicServerURL     string               `json:"iamPublicServerUrl"`
+	CACert                 string               `json:"caCert"`
+	CertStorage            string               `json:"certStorage"`
+	FunctionalServerID     string               `json:"functionalServerId"`
+	InstanceID             string               `json:"instanceId"`
+	WorkingDir             string               `json:"workingDir"`
+	DownloadDir            string               `json:"downloadDir"`
+	CacheDir               string               `json:"cacheDir"`
+	FailedImages           FailedImages         `json:"failedImages"`
+	DownloadHosts          []DownloadHost       `json:"downloadHosts"`
+	MaxConcurrentDownloads int                  `json:"maxConcurrentDownloads"`
+	DownloadBandwidth      DownloadBandwidth    `json:"downloadBandwidth"`
+	MirrorSelection        string               `json:"mirrorSelection"`
+	ProgressInterval       aostypes.Duration    `json:"progressInterval"`
+	SigningKeys            []SigningKey         `json:"signingKeys"`
+	SignaturePolicies      []SignaturePolicy    `json:"signaturePolicies"`
+	VerificationPolicies   []VerificationPolicy `json:"verificationPolicies"`
+	KeyProviders           []KeyProvider        `json:"keyProviders"`
+	TrustAnchors           []TrustAnchor        `json:"trustAnchors"`
+	VersionRefreshTimeout  aostypes.Duration    `json:"versionRefreshTimeout"`
+	RevertWindow           aostypes.Duration    `json:"revertWindow"`
+	ErrorRetention         aostypes.Duration    `json:"errorRetention"`
+	FailureThreshold       int                  `json:"failureThreshold"`
+	FailurePolicy          string               `json:"failurePolicy"`
+	OperationRetry         OperationRetry       `json:"operationRetry"`
+	UpdateBlockers         UpdateBlockers       `json:"updateBlockers"`
+	DiagnosticsInterval    aostypes.Duration    `json:"diagnosticsInterval"`
+	SnapshotPaths          []string             `json:"snapshotPaths"`
+	StateExportFile        string               `json:"stateExportFile"`
+	JournalCursors         JournalCursors       `json:"journalCursors"`
+	Labels                 map[string]string    `json:"labels"`
+	PluginFiles            []string             `json:"pluginFiles"`
+	ModuleStorageQuota     uint64               `json:"moduleStorageQuota"`
+	UpdateModules          []ModuleConfig       `json:"updateModules"`
+	Migration              Migration            `json:"migration"`
+	WritableStorage        WritableStorage      `json:"writableStorage"`
+	Standby                Standby              `json:"standby"`
+	FailureInjection       FailureInjection     `json:"failureInjection"`
+	Prefetch               Prefetch             `json:"prefetch"`
+	BatchReboots           bool                 `json:"batchReboots"`
+	MaintenanceActions     []string             `json:"maintenanceActions"`
+	StatusRefreshInterval  aostypes.Duration    `json:"statusRefreshInterval"`
+	Rollout                Rollout              `json:"rollout"`
+	ApplySchedule          string               `json:"applySchedule"`
+	SpeedTest              SpeedTest            `json:"speedTest"`
+	HealthChecks           HealthChecks         `json:"healthChecks"`
+	HealthScore            HealthScore          `json:"healthScore"`
+	CanaryApply            CanaryApply          `json:"canaryApply"`
+	DiagnosticUpload       DiagnosticUpload     `json:"diagnosticUpload"`
+	ControlServer          ControlServer        `json:"controlServer"`
+	Hooks                  []Hook               `json:"hooks"`
 }
 
 // ModuleConfig module configuration.
 type ModuleConfig struct {
-	ID             string `json:"id"`
-	Plugin         string `json:"plugin"`
-	Disabled       bool   `json:"disabled"`
-	UpdatePriority uint32 `json:"updatePriority"`
-	RebootPriority uint32 `json:"rebootPriority"`
-	Params         json.RawMessage
+	ID                 string            `json:"id"`
+	Plugin             string            `json:"plugin"`
+	Disabled           bool              `json:"disabled"`
+	UpdatePriority     uint32            `json:"updatePriority"`
+	RebootPriority     uint32            `json:"rebootPriority"`
+	RebootGroup        string            `json:"rebootGroup"`
+	RebootType         string            `json:"rebootType"`
+	ExternalTarget     bool              `json:"externalTarget"`
+	VersionScheme      string            `json:"versionScheme"`
+	SignaturePolicy    string            `json:"signaturePolicy"`
+	VerificationPolicy string            `json:"verificationPolicy"`
+	TrustAnchor        string            `json:"trustAnchor"`
+	UpdateTimeout      aostypes.Duration `json:"updateTimeout"`
+	Verify             *VerifyCommand    `json:"verify"`
+	ImageFormats       []string          `json:"imageFormats"`
+	Dependencies       []string          `json:"dependencies"`
+	SpaceCheck         *SpaceCheck       `json:"spaceCheck"`
+	Confirmation       *Confirmation     `json:"confirmation"`
+	Params             json.RawMessage
 }
 
 /*******************************************************************************
@@ -84,5 +389,41 @@ func New(fileName string) (config *Config, err error) {
 		config.Migration.MergedMigrationPath = path.Join(config.WorkingDir, "mergedMigration")
 	}
 
+	if config.DiagnosticsInterval.Duration == 0 {
+		config.DiagnosticsInterval.Duration = defaultDiagnosticsInterval
+	}
+
+	if config.Standby.LeaseFile == "" {
+		config.Standby.LeaseFile = path.Join(config.WorkingDir, defaultLeaseFileName)
+	}
+
+	if config.Standby.LeaseTTL.Duration == 0 {
+		config.Standby.LeaseTTL.Duration = defaultLeaseTTL
+	}
+
+	if config.Rollout.DeviceIDFile == "" {
+		config.Rollout.DeviceIDFile = defaultDeviceIDFile
+	}
+
+	if config.DiagnosticUpload.Dir == "" {
+		config.DiagnosticUpload.Dir = path.Join(config.WorkingDir, defaultDiagnosticBundlesDir)
+	}
+
+	if config.DiagnosticUpload.MaxBundleSize == 0 {
+		config.DiagnosticUpload.MaxBundleSize = defaultDiagnosticBundleSize
+	}
+
+	if config.DiagnosticUpload.MaxTotalSize == 0 {
+		config.DiagnosticUpload.MaxTotalSize = defaultDiagnosticTotalSize
+	}
+
+	if config.DiagnosticUpload.RetryInterval.Duration == 0 {
+		config.DiagnosticUpload.RetryInterval.Duration = defaultDiagnosticRetryInterval
+	}
+
+	if config.DiagnosticUpload.Timeout.Duration == 0 {
+		config.DiagnosticUpload.Timeout.Duration = defaultDiagnosticUploadTimeout
+	}
+
 	return config, nil
 }
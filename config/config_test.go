@@ -22,6 +22,7 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 
@@ -63,16 +64,20 @@ func createWrongConfigFile() (err error) {
 func createConfigFile() (err error) {
 	configContent := `{
 	"ID": "um01",
+	"InstanceID": "um-instance-1",
 	"CACert": "/etc/ssl/certs/rootCA.crt",
 	"CMServerUrl": "localhost:8093",
 	"CertStorage": "um",
 	"WorkingDir": "/var/aos/updatemanager",
 	"DownloadDir": "/var/aos/updatemanager/download",
+	"DiagnosticsInterval": "30m",
 	"UpdateModules":[{
 		"ID": "id1",
 		"Plugin": "test1",
 		"UpdatePriority": 1,
 		"RebootPriority": 1,
+		"RebootGroup": "soc",
+		"VersionScheme": "semver",
 		"Params": {
 			"Param1" :"value1",
 			"Param2" : 2
@@ -82,6 +87,7 @@ func createConfigFile() (err error) {
 		"Plugin": "test2",
 		"UpdatePriority": 2,
 		"RebootPriority": 2,
+		"RebootGroup": "soc",
 		"Params": {
 			"Param1" :"value1",
 			"Param2" : 2
@@ -91,6 +97,7 @@ func createConfigFile() (err error) {
 		"Plugin": "test3",
 		"UpdatePriority": 3,
 		"RebootPriority": 3,
+		"ExternalTarget": true,
 		"Disabled": true,
 		"Params": {
 			"Param1" :"value1",
@@ -100,6 +107,18 @@ func createConfigFile() (err error) {
 	"migration": {
 		"migrationPath" : "/usr/share/aos_updatemanager/migration",
 		"mergedMigrationPath" : "/var/aos/updatemanager/mergedMigrationPath"
+	},
+	"writableStorage": {
+		"path": "/var/aos/storage",
+		"minFreeSpace": 1048576,
+		"minFreeInodes": 1024
+	},
+	"standby": {
+		"enabled": true,
+		"instanceId": "gw-a"
+	},
+	"controlServer": {
+		"socketPath": "/run/aos/updatemanager.sock"
 	}
 }`
 
@@ -191,10 +210,24 @@ func TestModules(t *testing.T) {
 		t.Error("Wrong reboot priority value")
 	}
 
+	if cfg.UpdateModules[0].RebootGroup != "soc" || cfg.UpdateModules[1].RebootGroup != "soc" ||
+		cfg.UpdateModules[2].RebootGroup != "" {
+		t.Error("Wrong reboot group value")
+	}
+
+	if cfg.UpdateModules[0].ExternalTarget != false || cfg.UpdateModules[1].ExternalTarget != false ||
+		cfg.UpdateModules[2].ExternalTarget != true {
+		t.Error("Wrong external target value")
+	}
+
 	if cfg.UpdateModules[0].Disabled != false || cfg.UpdateModules[1].Disabled != false ||
 		cfg.UpdateModules[2].Disabled != true {
 		t.Error("Disabled value")
 	}
+
+	if cfg.UpdateModules[0].VersionScheme != "semver" || cfg.UpdateModules[1].VersionScheme != "" {
+		t.Error("Wrong version scheme value")
+	}
 }
 
 func TestGetWorkingDir(t *testing.T) {
@@ -209,6 +242,72 @@ func TestGetDownloadDir(t *testing.T) {
 	}
 }
 
+func TestDiagnosticsInterval(t *testing.T) {
+	if cfg.DiagnosticsInterval.Duration != 30*time.Minute {
+		t.Errorf("Wrong diagnostics interval value: %v", cfg.DiagnosticsInterval)
+	}
+}
+
+func TestWritableStorage(t *testing.T) {
+	if cfg.WritableStorage.Path != "/var/aos/storage" {
+		t.Errorf("Wrong writable storage path: %s", cfg.WritableStorage.Path)
+	}
+
+	if cfg.WritableStorage.MinFreeSpace != 1048576 {
+		t.Errorf("Wrong writable storage min free space: %d", cfg.WritableStorage.MinFreeSpace)
+	}
+
+	if cfg.WritableStorage.MinFreeInodes != 1024 {
+		t.Errorf("Wrong writable storage min free inodes: %d", cfg.WritableStorage.MinFreeInodes)
+	}
+}
+
+func TestStandby(t *testing.T) {
+	if !cfg.Standby.Enabled || cfg.Standby.InstanceID != "gw-a" {
+		t.Errorf("Wrong standby config: %v", cfg.Standby)
+	}
+
+	if cfg.Standby.LeaseFile != "/var/aos/updatemanager/updatemanager.lease" {
+		t.Errorf("Wrong lease file value: %s", cfg.Standby.LeaseFile)
+	}
+
+	if cfg.Standby.LeaseTTL.Duration != 15*time.Second {
+		t.Errorf("Wrong lease TTL value: %v", cfg.Standby.LeaseTTL)
+	}
+}
+
+func TestInstanceID(t *testing.T) {
+	if cfg.InstanceID != "um-instance-1" {
+		t.Errorf("Wrong instance ID: %s", cfg.InstanceID)
+	}
+}
+
+func TestRollout(t *testing.T) {
+	if cfg.Rollout.DeviceIDFile != "/etc/machine-id" {
+		t.Errorf("Wrong device ID file value: %s", cfg.Rollout.DeviceIDFile)
+	}
+}
+
+func TestDiagnosticUpload(t *testing.T) {
+	if cfg.DiagnosticUpload.Dir != "/var/aos/updatemanager/diagnosticBundles" {
+		t.Errorf("Wrong diagnostic bundles dir: %s", cfg.DiagnosticUpload.Dir)
+	}
+
+	if cfg.DiagnosticUpload.MaxBundleSize != 10*1024*1024 || cfg.DiagnosticUpload.MaxTotalSize != 50*1024*1024 {
+		t.Errorf("Wrong diagnostic bundle size limits: %v", cfg.DiagnosticUpload)
+	}
+
+	if cfg.DiagnosticUpload.RetryInterval.Duration != time.Minute {
+		t.Errorf("Wrong diagnostic upload retry interval: %v", cfg.DiagnosticUpload.RetryInterval)
+	}
+}
+
+func TestControlServer(t *testing.T) {
+	if cfg.ControlServer.SocketPath != "/run/aos/updatemanager.sock" {
+		t.Errorf("Wrong control socket path: %s", cfg.ControlServer.SocketPath)
+	}
+}
+
 func TestNewErrors(t *testing.T) {
 	// Executing new statement with nonexisting config file
 	if _, err := config.New("some_nonexisting_file"); err == nil {
@@ -0,0 +1,437 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controlserver provides local control API of update manager.
+package controlserver
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+// Control server serves HTTP API with JSON bodies on local unix socket for operator and HMI tools, as CM protocol has
+// no messages for local control. Each request is authorized separately: the caller provides its secret in the
+// secret header and it should have IAM permission to the requested operation, read access for queries and write
+// access for commands. Permissions are not checked if functional server ID is not configured, so the socket is
+// accessible by owner only. Rejected request is answered with JSON error and corresponding HTTP status.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// SecretHeader request header with secret of the caller.
+const SecretHeader = "X-Aos-Secret"
+
+// Control operations checked against IAM permissions.
+const (
+	OperationEmergencyStop     = "emergencyStop"
+	OperationReleaseQuarantine = "releaseQuarantine"
+	OperationCancelUpdate      = "cancelUpdate"
+	OperationRefreshStatus     = "refreshStatus"
+	OperationDownloadBandwidth = "downloadBandwidth"
+	OperationRunMaintenance    = "runMaintenance"
+	OperationReleaseComponent  = "releaseComponent"
+	OperationConfirm           = "confirm"
+)
+
+const (
+	socketPermissions = 0o600
+	maxRequestSize    = 64 * 1024
+	readHeaderTimeout = 10 * time.Second
+)
+
+const (
+	accessRead  = "r"
+	accessWrite = "w"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Handler handles control requests.
+type Handler interface {
+	EmergencyStop()
+	ReleaseQuarantine() (err error)
+	CancelUpdate() (err error)
+	RefreshStatus(ids []string) (refreshes []updatehandler.ComponentRefresh, err error)
+	SetDownloadBandwidth(control updatehandler.BandwidthControl) (err error)
+	GetDownloadBandwidth() (control updatehandler.BandwidthControl)
+	RunMaintenance(request updatehandler.MaintenanceRequest) (err error)
+	ReleaseComponent(id string) (err error)
+	Confirm(id string) (err error)
+	GetPendingConfirmations() (ids []string)
+}
+
+// Server control server.
+type Server struct {
+	socketPath         string
+	handler            Handler
+	permissionProvider umclient.PermissionProvider
+	funcServerID       string
+	httpServer         *http.Server
+}
+
+// RefreshRequest component status refresh request, all components are refreshed if no ID is specified.
+type RefreshRequest struct {
+	IDs []string `json:"ids,omitempty"`
+}
+
+// ReleaseComponentRequest releases component quarantined after repeated update failures.
+type ReleaseComponentRequest struct {
+	ID string `json:"id"`
+}
+
+// ConfirmRequest confirms update of component which requires local user confirmation.
+type ConfirmRequest struct {
+	ID string `json:"id"`
+}
+
+// PendingConfirmations components waiting for local user confirmation.
+type PendingConfirmations struct {
+	IDs []string `json:"ids"`
+}
+
+type requestHandler func(r *http.Request) (response interface{}, err error)
+
+type requestError struct {
+	status int
+	err    error
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates control server and starts serving requests.
+func New(
+	cfg *config.Config, handler Handler, permissionProvider umclient.PermissionProvider,
+) (server *Server, err error) {
+	if cfg.ControlServer.SocketPath == "" {
+		return nil, aoserrors.New("control socket path is not set")
+	}
+
+	log.WithField("socket", cfg.ControlServer.SocketPath).Debug("Create control server")
+
+	// Socket file is left if the previous instance is not closed properly
+	if err = os.Remove(cfg.ControlServer.SocketPath); err != nil && !os.IsNotExist(err) {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	listener, err := listen(cfg.ControlServer.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	server = &Server{
+		socketPath:         cfg.ControlServer.SocketPath,
+		handler:            handler,
+		permissionProvider: permissionProvider,
+		funcServerID:       cfg.FunctionalServerID,
+	}
+
+	mux := http.NewServeMux()
+
+	server.handle(mux, "/v1/emergency-stop", http.MethodPost, OperationEmergencyStop, accessWrite,
+		server.emergencyStop)
+	server.handle(mux, "/v1/release-quarantine", http.MethodPost, OperationReleaseQuarantine, accessWrite,
+		server.releaseQuarantine)
+	server.handle(mux, "/v1/cancel-update", http.MethodPost, OperationCancelUpdate, accessWrite,
+		server.cancelUpdate)
+	server.handle(mux, "/v1/refresh-status", http.MethodPost, OperationRefreshStatus, accessRead,
+		server.refreshStatus)
+	server.handle(mux, "/v1/download-bandwidth", http.MethodGet, OperationDownloadBandwidth, accessRead,
+		server.getDownloadBandwidth)
+	server.handle(mux, "/v1/set-download-bandwidth", http.MethodPost, OperationDownloadBandwidth, accessWrite,
+		server.setDownloadBandwidth)
+	server.handle(mux, "/v1/run-maintenance", http.MethodPost, OperationRunMaintenance, accessWrite,
+		server.runMaintenance)
+	server.handle(mux, "/v1/release-component", http.MethodPost, OperationReleaseComponent, accessWrite,
+		server.releaseComponent)
+	server.handle(mux, "/v1/pending-confirmations", http.MethodGet, OperationConfirm, accessRead,
+		server.getPendingConfirmations)
+	server.handle(mux, "/v1/confirm", http.MethodPost, OperationConfirm, accessWrite,
+		server.confirm)
+
+	server.handleFailureInjection(mux)
+
+	server.httpServer = &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+
+	go func() {
+		if err := server.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Can't serve control server: %s", aoserrors.Wrap(err))
+		}
+	}()
+
+	return server, nil
+}
+
+// Close closes control server.
+func (server *Server) Close() {
+	log.Debug("Close control server")
+
+	if err := server.httpServer.Close(); err != nil {
+		log.Errorf("Can't close control server: %s", aoserrors.Wrap(err))
+	}
+
+	if err := os.Remove(server.socketPath); err != nil && !os.IsNotExist(err) {
+		log.Errorf("Can't remove control socket: %s", aoserrors.Wrap(err))
+	}
+}
+
+func (err *requestError) Error() string {
+	return err.err.Error()
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// listen creates socket in private directory and moves it to socket path once its permissions are set, so other users
+// can't connect to the socket created with process umask.
+func listen(socketPath string) (listener net.Listener, err error) {
+	privateDir, err := os.MkdirTemp(filepath.Dir(socketPath), ".control")
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	defer os.RemoveAll(privateDir)
+
+	privatePath := filepath.Join(privateDir, filepath.Base(socketPath))
+
+	unixListener, err := net.ListenUnix("unix", &net.UnixAddr{Name: privatePath, Net: "unix"})
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	// Socket is removed on close by its path instead of the private one
+	unixListener.SetUnlinkOnClose(false)
+
+	if err = os.Chmod(privatePath, socketPermissions); err != nil {
+		unixListener.Close()
+
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = os.Rename(privatePath, socketPath); err != nil {
+		unixListener.Close()
+
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return unixListener, nil
+}
+
+func (server *Server) handle(
+	mux *http.ServeMux, pattern, method, operation, access string, handler requestHandler,
+) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		log.WithFields(log.Fields{"method": r.Method, "path": r.URL.Path}).Debug("Control request")
+
+		if r.Method != method {
+			writeError(w, &requestError{
+				status: http.StatusMethodNotAllowed, err: aoserrors.Errorf("method %s is not allowed", r.Method),
+			})
+
+			return
+		}
+
+		if err := umclient.CheckPermission(server.permissionProvider, server.funcServerID,
+			r.Header.Get(SecretHeader), operation, access); err != nil {
+			log.WithField("operation", operation).Warnf("Control request rejected: %s", err)
+
+			writeError(w, &requestError{status: http.StatusForbidden, err: err})
+
+			return
+		}
+
+		response, err := handler(r)
+		if err != nil {
+			log.WithField("operation", operation).Errorf("Control request failed: %s", err)
+
+			writeError(w, err)
+
+			return
+		}
+
+		if response == nil {
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+func (server *Server) emergencyStop(r *http.Request) (response interface{}, err error) {
+	server.handler.EmergencyStop()
+
+	return nil, nil
+}
+
+func (server *Server) releaseQuarantine(r *http.Request) (response interface{}, err error) {
+	if err = server.handler.ReleaseQuarantine(); err != nil {
+		return nil, conflictError(err)
+	}
+
+	return nil, nil
+}
+
+func (server *Server) cancelUpdate(r *http.Request) (response interface{}, err error) {
+	if err = server.handler.CancelUpdate(); err != nil {
+		return nil, conflictError(err)
+	}
+
+	return nil, nil
+}
+
+func (server *Server) refreshStatus(r *http.Request) (response interface{}, err error) {
+	var request RefreshRequest
+
+	if err = decodeRequest(r, &request); err != nil {
+		return nil, err
+	}
+
+	refreshes, err := server.handler.RefreshStatus(request.IDs)
+	if err != nil {
+		return nil, conflictError(err)
+	}
+
+	return refreshes, nil
+}
+
+func (server *Server) getDownloadBandwidth(r *http.Request) (response interface{}, err error) {
+	return server.handler.GetDownloadBandwidth(), nil
+}
+
+// setDownloadBandwidth changes bandwidth of in-flight downloads without aborting them.
+func (server *Server) setDownloadBandwidth(r *http.Request) (response interface{}, err error) {
+	var control updatehandler.BandwidthControl
+
+	if err = decodeRequest(r, &control); err != nil {
+		return nil, err
+	}
+
+	if err = server.handler.SetDownloadBandwidth(control); err != nil {
+		return nil, &requestError{status: http.StatusBadRequest, err: err}
+	}
+
+	return server.handler.GetDownloadBandwidth(), nil
+}
+
+func (server *Server) runMaintenance(r *http.Request) (response interface{}, err error) {
+	var request updatehandler.MaintenanceRequest
+
+	if err = decodeRequest(r, &request); err != nil {
+		return nil, err
+	}
+
+	if err = server.handler.RunMaintenance(request); err != nil {
+		return nil, conflictError(err)
+	}
+
+	return nil, nil
+}
+
+func (server *Server) releaseComponent(r *http.Request) (response interface{}, err error) {
+	var request ReleaseComponentRequest
+
+	if err = decodeRequest(r, &request); err != nil {
+		return nil, err
+	}
+
+	if err = server.handler.ReleaseComponent(request.ID); err != nil {
+		return nil, conflictError(err)
+	}
+
+	return nil, nil
+}
+
+func (server *Server) getPendingConfirmations(r *http.Request) (response interface{}, err error) {
+	return PendingConfirmations{IDs: server.handler.GetPendingConfirmations()}, nil
+}
+
+func (server *Server) confirm(r *http.Request) (response interface{}, err error) {
+	var request ConfirmRequest
+
+	if err = decodeRequest(r, &request); err != nil {
+		return nil, err
+	}
+
+	if err = server.handler.Confirm(request.ID); err != nil {
+		return nil, conflictError(err)
+	}
+
+	return nil, nil
+}
+
+// decodeRequest decodes JSON request body, unknown fields are rejected to not ignore misspelled parameters. Empty body
+// is decoded as request with default values.
+func decodeRequest(r *http.Request, request interface{}) (err error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestSize))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(request); err != nil && !errors.Is(err, io.EOF) {
+		return &requestError{status: http.StatusBadRequest, err: aoserrors.Wrap(err)}
+	}
+
+	return nil
+}
+
+// conflictError reports request which can't be performed in current state.
+func conflictError(err error) error {
+	return &requestError{status: http.StatusConflict, err: err}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	var reqErr *requestError
+
+	if errors.As(err, &reqErr) {
+		status = reqErr.status
+	}
+
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("Can't write control response: %s", aoserrors.Wrap(err))
+	}
+}
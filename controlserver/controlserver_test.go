@@ -0,0 +1,580 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controlserver_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/controlserver"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	secretOperator = "operator"
+	secretViewer   = "viewer"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testHandler struct {
+	sync.Mutex
+
+	quarantined bool
+	updating    bool
+	bandwidth   updatehandler.BandwidthControl
+	maintenance []updatehandler.MaintenanceRequest
+	released    []string
+	pending     []string
+}
+
+type testPermissionProvider struct {
+	permissions map[string]map[string]string
+}
+
+type testClient struct {
+	httpClient *http.Client
+	header     http.Header
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var testPermissions = map[string]map[string]string{
+	secretOperator: {
+		controlserver.OperationEmergencyStop:     "rw",
+		controlserver.OperationReleaseQuarantine: "rw",
+		controlserver.OperationCancelUpdate:      "rw",
+		controlserver.OperationDownloadBandwidth: "rw",
+		controlserver.OperationRunMaintenance:    "rw",
+		controlserver.OperationReleaseComponent:  "rw",
+		controlserver.OperationConfirm:           "rw",
+	},
+	secretViewer: {
+		controlserver.OperationEmergencyStop:     "r",
+		controlserver.OperationRefreshStatus:     "r",
+		controlserver.OperationDownloadBandwidth: "r",
+		controlserver.OperationConfirm:           "r",
+	},
+}
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestEmergencyStop(t *testing.T) {
+	handler := &testHandler{}
+	client := newTestServer(t, handler)
+
+	if status, err := client.send(http.MethodPost, "/v1/release-quarantine", secretOperator, nil, nil); err == nil ||
+		status != http.StatusConflict {
+		t.Errorf("Wrong release status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/emergency-stop", secretOperator, nil, nil); err != nil ||
+		status != http.StatusNoContent {
+		t.Errorf("Wrong emergency stop status: %d, error: %v", status, err)
+	}
+
+	if !handler.isQuarantined() {
+		t.Error("Handler should be quarantined")
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/release-quarantine", secretOperator, nil, nil); err != nil ||
+		status != http.StatusNoContent {
+		t.Errorf("Wrong release status: %d, error: %v", status, err)
+	}
+
+	if handler.isQuarantined() {
+		t.Error("Handler should not be quarantined")
+	}
+}
+
+func TestCancelUpdate(t *testing.T) {
+	handler := &testHandler{}
+	client := newTestServer(t, handler)
+
+	if status, err := client.send(http.MethodPost, "/v1/cancel-update", secretOperator, nil, nil); err == nil ||
+		status != http.StatusConflict {
+		t.Errorf("Wrong cancel status: %d, error: %v", status, err)
+	}
+
+	handler.updating = true
+
+	if status, err := client.send(http.MethodPost, "/v1/cancel-update", secretOperator, nil, nil); err != nil ||
+		status != http.StatusNoContent {
+		t.Errorf("Wrong cancel status: %d, error: %v", status, err)
+	}
+
+	if handler.updating {
+		t.Error("Update should be canceled")
+	}
+}
+
+func TestRefreshStatus(t *testing.T) {
+	client := newTestServer(t, &testHandler{})
+
+	var refreshes []updatehandler.ComponentRefresh
+
+	if status, err := client.send(http.MethodPost, "/v1/refresh-status", secretViewer,
+		controlserver.RefreshRequest{IDs: []string{"id1", "id2"}}, &refreshes); err != nil ||
+		status != http.StatusOK {
+		t.Fatalf("Wrong refresh status: %d, error: %v", status, err)
+	}
+
+	if !reflect.DeepEqual(refreshes, []updatehandler.ComponentRefresh{
+		{ID: "id1", Status: "installed"}, {ID: "id2", Status: "installed"},
+	}) {
+		t.Errorf("Wrong refreshes: %v", refreshes)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/refresh-status", secretViewer,
+		controlserver.RefreshRequest{IDs: []string{"unknown"}}, &refreshes); err == nil ||
+		status != http.StatusConflict {
+		t.Errorf("Wrong refresh status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/refresh-status", secretViewer,
+		map[string]string{"id": "id1"}, &refreshes); err == nil || status != http.StatusBadRequest {
+		t.Errorf("Wrong refresh status: %d, error: %v", status, err)
+	}
+}
+
+func TestDownloadBandwidth(t *testing.T) {
+	client := newTestServer(t, &testHandler{})
+
+	var control updatehandler.BandwidthControl
+
+	if status, err := client.send(http.MethodPost, "/v1/set-download-bandwidth", secretViewer,
+		updatehandler.BandwidthControl{Class: "background"}, &control); err == nil || status != http.StatusForbidden {
+		t.Errorf("Wrong set bandwidth status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/set-download-bandwidth", secretOperator,
+		updatehandler.BandwidthControl{Class: "unknown"}, &control); err == nil || status != http.StatusBadRequest {
+		t.Errorf("Wrong set bandwidth status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/set-download-bandwidth", secretOperator,
+		updatehandler.BandwidthControl{Class: "background"}, &control); err != nil || status != http.StatusOK {
+		t.Errorf("Wrong set bandwidth status: %d, error: %v", status, err)
+	}
+
+	if control != (updatehandler.BandwidthControl{Class: "background", RateLimit: 1024}) {
+		t.Errorf("Wrong bandwidth: %v", control)
+	}
+
+	control = updatehandler.BandwidthControl{}
+
+	if status, err := client.send(http.MethodGet, "/v1/download-bandwidth", secretViewer, nil, &control); err != nil ||
+		status != http.StatusOK {
+		t.Errorf("Wrong get bandwidth status: %d, error: %v", status, err)
+	}
+
+	if control != (updatehandler.BandwidthControl{Class: "background", RateLimit: 1024}) {
+		t.Errorf("Wrong bandwidth: %v", control)
+	}
+}
+
+func TestRunMaintenance(t *testing.T) {
+	handler := &testHandler{}
+	client := newTestServer(t, handler)
+
+	request := updatehandler.MaintenanceRequest{ID: "id1", Action: updatehandler.MaintenanceRefreshVersion}
+
+	if status, err := client.send(http.MethodPost, "/v1/run-maintenance", secretOperator, request, nil); err != nil ||
+		status != http.StatusNoContent {
+		t.Errorf("Wrong maintenance status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/run-maintenance", secretOperator,
+		updatehandler.MaintenanceRequest{Action: "reboot"}, nil); err == nil || status != http.StatusConflict {
+		t.Errorf("Wrong maintenance status: %d, error: %v", status, err)
+	}
+
+	if !reflect.DeepEqual(handler.maintenance, []updatehandler.MaintenanceRequest{request}) {
+		t.Errorf("Wrong maintenance requests: %v", handler.maintenance)
+	}
+}
+
+func TestReleaseComponent(t *testing.T) {
+	handler := &testHandler{}
+	client := newTestServer(t, handler)
+
+	if status, err := client.send(http.MethodPost, "/v1/release-component", secretOperator,
+		controlserver.ReleaseComponentRequest{ID: "id1"}, nil); err != nil || status != http.StatusNoContent {
+		t.Errorf("Wrong release status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/release-component", secretOperator,
+		controlserver.ReleaseComponentRequest{ID: "unknown"}, nil); err == nil || status != http.StatusConflict {
+		t.Errorf("Wrong release status: %d, error: %v", status, err)
+	}
+
+	if !reflect.DeepEqual(handler.released, []string{"id1"}) {
+		t.Errorf("Wrong released components: %v", handler.released)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	handler := &testHandler{pending: []string{"id1", "id2"}}
+	client := newTestServer(t, handler)
+
+	var pending controlserver.PendingConfirmations
+
+	if status, err := client.send(http.MethodGet, "/v1/pending-confirmations", secretViewer, nil,
+		&pending); err != nil || status != http.StatusOK {
+		t.Errorf("Wrong pending confirmations status: %d, error: %v", status, err)
+	}
+
+	if !reflect.DeepEqual(pending.IDs, []string{"id1", "id2"}) {
+		t.Errorf("Wrong pending confirmations: %v", pending.IDs)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/confirm", secretViewer,
+		controlserver.ConfirmRequest{ID: "id1"}, nil); err == nil || status != http.StatusForbidden {
+		t.Errorf("Wrong confirm status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/confirm", secretOperator,
+		controlserver.ConfirmRequest{ID: "id1"}, nil); err != nil || status != http.StatusNoContent {
+		t.Errorf("Wrong confirm status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodPost, "/v1/confirm", secretOperator,
+		controlserver.ConfirmRequest{ID: "id1"}, nil); err == nil || status != http.StatusConflict {
+		t.Errorf("Wrong confirm status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.send(http.MethodGet, "/v1/pending-confirmations", secretViewer, nil,
+		&pending); err != nil || status != http.StatusOK {
+		t.Errorf("Wrong pending confirmations status: %d, error: %v", status, err)
+	}
+
+	if !reflect.DeepEqual(pending.IDs, []string{"id2"}) {
+		t.Errorf("Wrong pending confirmations: %v", pending.IDs)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	handler := &testHandler{}
+	client := newTestServer(t, handler)
+
+	for _, secret := range []string{"", "unknown", secretViewer} {
+		if status, err := client.send(http.MethodPost, "/v1/emergency-stop", secret, nil, nil); err == nil ||
+			status != http.StatusForbidden {
+			t.Errorf("Wrong emergency stop status: %d, error: %v", status, err)
+		}
+	}
+
+	if handler.isQuarantined() {
+		t.Error("Handler should not be quarantined")
+	}
+
+	if status, err := client.send(http.MethodGet, "/v1/emergency-stop", secretOperator, nil, nil); err == nil ||
+		status != http.StatusMethodNotAllowed {
+		t.Errorf("Wrong emergency stop status: %d, error: %v", status, err)
+	}
+}
+
+/***********************************************************************************************************************
+ * testHandler
+ **********************************************************************************************************************/
+
+func (handler *testHandler) EmergencyStop() {
+	handler.Lock()
+	defer handler.Unlock()
+
+	handler.quarantined = true
+}
+
+func (handler *testHandler) ReleaseQuarantine() (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if !handler.quarantined {
+		return aoserrors.New("not quarantined")
+	}
+
+	handler.quarantined = false
+
+	return nil
+}
+
+func (handler *testHandler) CancelUpdate() (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if !handler.updating {
+		return aoserrors.New("no update in progress")
+	}
+
+	handler.updating = false
+
+	return nil
+}
+
+func (handler *testHandler) RefreshStatus(ids []string) (refreshes []updatehandler.ComponentRefresh, err error) {
+	for _, id := range ids {
+		if id == "unknown" {
+			return nil, aoserrors.Errorf("component %s not found", id)
+		}
+
+		refreshes = append(refreshes, updatehandler.ComponentRefresh{ID: id, Status: "installed"})
+	}
+
+	return refreshes, nil
+}
+
+func (handler *testHandler) SetDownloadBandwidth(control updatehandler.BandwidthControl) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	switch control.Class {
+	case "":
+		handler.bandwidth = control
+
+	case "background":
+		handler.bandwidth = updatehandler.BandwidthControl{Class: control.Class, RateLimit: 1024}
+
+	default:
+		return aoserrors.Errorf("bandwidth class %s not found", control.Class)
+	}
+
+	return nil
+}
+
+func (handler *testHandler) GetDownloadBandwidth() (control updatehandler.BandwidthControl) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	return handler.bandwidth
+}
+
+func (handler *testHandler) RunMaintenance(request updatehandler.MaintenanceRequest) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if request.Action != updatehandler.MaintenanceRefreshVersion {
+		return aoserrors.Errorf("maintenance action %s is not allowed", request.Action)
+	}
+
+	handler.maintenance = append(handler.maintenance, request)
+
+	return nil
+}
+
+func (handler *testHandler) ReleaseComponent(id string) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if id == "unknown" {
+		return aoserrors.Errorf("component %s is not quarantined", id)
+	}
+
+	handler.released = append(handler.released, id)
+
+	return nil
+}
+
+func (handler *testHandler) Confirm(id string) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	pending := make([]string, 0, len(handler.pending))
+
+	for _, pendingID := range handler.pending {
+		if pendingID != id {
+			pending = append(pending, pendingID)
+		}
+	}
+
+	if len(pending) == len(handler.pending) {
+		return aoserrors.Errorf("component %s doesn't wait for confirmation", id)
+	}
+
+	handler.pending = pending
+
+	return nil
+}
+
+func (handler *testHandler) GetPendingConfirmations() (ids []string) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	return append([]string(nil), handler.pending...)
+}
+
+func (handler *testHandler) isQuarantined() (quarantined bool) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	return handler.quarantined
+}
+
+/***********************************************************************************************************************
+ * testPermissionProvider
+ **********************************************************************************************************************/
+
+func (provider *testPermissionProvider) GetPermissions(
+	secret, funcServerID string,
+) (permissions map[string]string, err error) {
+	permissions, ok := provider.permissions[secret]
+	if !ok {
+		return nil, aoserrors.New("permissions not found")
+	}
+
+	return permissions, nil
+}
+
+/***********************************************************************************************************************
+ * testClient
+ **********************************************************************************************************************/
+
+// send sends control request, error is returned if the request is rejected.
+func (client *testClient) send(
+	method, path, secret string, request, response interface{},
+) (status int, err error) {
+	var body bytes.Buffer
+
+	if request != nil {
+		if err = json.NewEncoder(&body).Encode(request); err != nil {
+			return 0, aoserrors.Wrap(err)
+		}
+	}
+
+	httpRequest, err := http.NewRequestWithContext(context.Background(), method, "http://um"+path, &body)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	for key, values := range client.header {
+		httpRequest.Header[key] = values
+	}
+
+	if secret != "" {
+		httpRequest.Header.Set(controlserver.SecretHeader, secret)
+	}
+
+	httpResponse, err := client.httpClient.Do(httpRequest)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK && httpResponse.StatusCode != http.StatusNoContent {
+		var errResponse errorResponse
+
+		if err = json.NewDecoder(httpResponse.Body).Decode(&errResponse); err != nil {
+			return httpResponse.StatusCode, aoserrors.Wrap(err)
+		}
+
+		return httpResponse.StatusCode, aoserrors.New(errResponse.Error)
+	}
+
+	if response != nil {
+		if err = json.NewDecoder(httpResponse.Body).Decode(response); err != nil {
+			return httpResponse.StatusCode, aoserrors.Wrap(err)
+		}
+	}
+
+	return httpResponse.StatusCode, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newTestServer(t *testing.T, handler controlserver.Handler) (client *testClient) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "um.sock")
+
+	server, err := controlserver.New(&config.Config{
+		FunctionalServerID: "um",
+		ControlServer:      config.ControlServer{SocketPath: socketPath},
+	}, handler, &testPermissionProvider{permissions: testPermissions})
+	if err != nil {
+		t.Fatalf("Can't create control server: %s", err)
+	}
+
+	t.Cleanup(server.Close)
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Can't stat control socket: %s", err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Wrong control socket permissions: %v", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(socketPath))
+	if err != nil {
+		t.Fatalf("Can't read control socket dir: %s", err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("Wrong control socket dir entries count: %d", len(entries))
+	}
+
+	return &testClient{httpClient: &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}}}
+}
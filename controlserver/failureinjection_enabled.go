@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build failureinjection
+
+package controlserver
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+// Failure injection requests are served only by debug builds with failureinjection build tag. Besides IAM permission
+// each request should provide failure injection token configured for update handler in the token header. Request with
+// wrong token is forbidden while wrong failure is answered as bad request.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// InjectionTokenHeader request header with failure injection token.
+const InjectionTokenHeader = "X-Aos-Injection-Token"
+
+// OperationFailureInjection failure injection operation checked against IAM permissions.
+const OperationFailureInjection = "failureInjection"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// FailureInjector handles failure injection requests.
+type FailureInjector interface {
+	InjectFailure(token string, failure updatehandler.InjectedFailure) (err error)
+	ClearInjectedFailures(token string, id string) (err error)
+	GetInjectedFailures(token string) (failures []updatehandler.InjectedFailure, err error)
+}
+
+// ClearFailuresRequest clears injected failures of component, all injected failures are cleared if ID is not
+// specified.
+type ClearFailuresRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (server *Server) handleFailureInjection(mux *http.ServeMux) {
+	injector, ok := server.handler.(FailureInjector)
+	if !ok {
+		return
+	}
+
+	log.Warn("Failure injection control requests are enabled")
+
+	server.handle(mux, "/v1/injected-failures", http.MethodGet, OperationFailureInjection, accessRead,
+		func(r *http.Request) (response interface{}, err error) {
+			failures, err := injector.GetInjectedFailures(r.Header.Get(InjectionTokenHeader))
+			if err != nil {
+				return nil, injectionError(err, http.StatusInternalServerError)
+			}
+
+			return failures, nil
+		})
+
+	server.handle(mux, "/v1/inject-failure", http.MethodPost, OperationFailureInjection, accessWrite,
+		func(r *http.Request) (response interface{}, err error) {
+			var failure updatehandler.InjectedFailure
+
+			if err = decodeRequest(r, &failure); err != nil {
+				return nil, err
+			}
+
+			if err = injector.InjectFailure(r.Header.Get(InjectionTokenHeader), failure); err != nil {
+				return nil, injectionError(err, http.StatusBadRequest)
+			}
+
+			return nil, nil
+		})
+
+	server.handle(mux, "/v1/clear-injected-failures", http.MethodPost, OperationFailureInjection, accessWrite,
+		func(r *http.Request) (response interface{}, err error) {
+			var request ClearFailuresRequest
+
+			if err = decodeRequest(r, &request); err != nil {
+				return nil, err
+			}
+
+			if err = injector.ClearInjectedFailures(r.Header.Get(InjectionTokenHeader), request.ID); err != nil {
+				return nil, injectionError(err, http.StatusInternalServerError)
+			}
+
+			return nil, nil
+		})
+}
+
+// injectionError returns forbidden error if request is rejected due to failure injection token and error with provided
+// status otherwise.
+func injectionError(err error, status int) (reqErr error) {
+	if updatehandler.IsInjectionTokenError(err) {
+		status = http.StatusForbidden
+	}
+
+	return &requestError{status: status, err: err}
+}
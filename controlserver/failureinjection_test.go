@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build failureinjection
+
+package controlserver_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/controlserver"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const injectionToken = "debug-token"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testInjectionHandler struct {
+	testHandler
+
+	failures []updatehandler.InjectedFailure
+}
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	testPermissions[secretOperator][controlserver.OperationFailureInjection] = "rw"
+	testPermissions[secretViewer][controlserver.OperationFailureInjection] = "r"
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestFailureInjection(t *testing.T) {
+	handler := &testInjectionHandler{}
+	client := newTestServer(t, handler)
+
+	failure := updatehandler.InjectedFailure{ID: "id1", Phase: "update", Delay: time.Minute, Count: 1}
+
+	if status, err := client.sendWithToken(http.MethodPost, "/v1/inject-failure", secretViewer, injectionToken,
+		failure, nil); err == nil || status != http.StatusForbidden {
+		t.Errorf("Wrong inject status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.sendWithToken(http.MethodPost, "/v1/inject-failure", secretOperator, "wrong",
+		failure, nil); err == nil || status != http.StatusForbidden {
+		t.Errorf("Wrong inject status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.sendWithToken(http.MethodPost, "/v1/inject-failure", secretOperator, injectionToken,
+		updatehandler.InjectedFailure{ID: "id1", Phase: "unknown"}, nil); err == nil ||
+		status != http.StatusBadRequest {
+		t.Errorf("Wrong inject status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.sendWithToken(http.MethodPost, "/v1/inject-failure", secretOperator, injectionToken,
+		failure, nil); err != nil || status != http.StatusNoContent {
+		t.Errorf("Wrong inject status: %d, error: %v", status, err)
+	}
+
+	var failures []updatehandler.InjectedFailure
+
+	if status, err := client.sendWithToken(http.MethodGet, "/v1/injected-failures", secretViewer, "wrong",
+		nil, &failures); err == nil || status != http.StatusForbidden {
+		t.Errorf("Wrong get failures status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.sendWithToken(http.MethodGet, "/v1/injected-failures", secretViewer, injectionToken,
+		nil, &failures); err != nil || status != http.StatusOK {
+		t.Errorf("Wrong get failures status: %d, error: %v", status, err)
+	}
+
+	if !reflect.DeepEqual(failures, []updatehandler.InjectedFailure{failure}) {
+		t.Errorf("Wrong injected failures: %v", failures)
+	}
+
+	if status, err := client.sendWithToken(http.MethodPost, "/v1/clear-injected-failures", secretOperator,
+		"wrong", controlserver.ClearFailuresRequest{}, nil); err == nil || status != http.StatusForbidden {
+		t.Errorf("Wrong clear failures status: %d, error: %v", status, err)
+	}
+
+	if status, err := client.sendWithToken(http.MethodPost, "/v1/clear-injected-failures", secretOperator,
+		injectionToken, controlserver.ClearFailuresRequest{}, nil); err != nil || status != http.StatusNoContent {
+		t.Errorf("Wrong clear failures status: %d, error: %v", status, err)
+	}
+
+	if len(handler.failures) != 0 {
+		t.Errorf("Injected failures are not cleared: %v", handler.failures)
+	}
+}
+
+/***********************************************************************************************************************
+ * testInjectionHandler
+ **********************************************************************************************************************/
+
+func (handler *testInjectionHandler) InjectFailure(token string, failure updatehandler.InjectedFailure) (err error) {
+	if token != injectionToken {
+		return updatehandler.NewInjectionTokenError(aoserrors.New("wrong failure injection token"))
+	}
+
+	if failure.Phase != "update" {
+		return aoserrors.Errorf("wrong phase %s", failure.Phase)
+	}
+
+	handler.failures = append(handler.failures, failure)
+
+	return nil
+}
+
+func (handler *testInjectionHandler) ClearInjectedFailures(token string, id string) (err error) {
+	if token != injectionToken {
+		return updatehandler.NewInjectionTokenError(aoserrors.New("wrong failure injection token"))
+	}
+
+	handler.failures = nil
+
+	return nil
+}
+
+func (handler *testInjectionHandler) GetInjectedFailures(
+	token string,
+) (failures []updatehandler.InjectedFailure, err error) {
+	if token != injectionToken {
+		return nil, updatehandler.NewInjectionTokenError(aoserrors.New("wrong failure injection token"))
+	}
+
+	return handler.failures, nil
+}
+
+/***********************************************************************************************************************
+ * testClient
+ **********************************************************************************************************************/
+
+func (client *testClient) sendWithToken(
+	method, path, secret, token string, request, response interface{},
+) (status int, err error) {
+	client.header = http.Header{controlserver.InjectionTokenHeader: []string{token}}
+	defer func() { client.header = nil }()
+
+	return client.send(method, path, secret, request, response)
+}
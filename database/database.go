@@ -19,6 +19,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -28,6 +29,9 @@ import (
 	"github.com/aoscloud/aos_common/migration"
 	_ "github.com/mattn/go-sqlite3" // ignore lint
 	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+	"github.com/aoscloud/aos_updatemanager/utils/updatehistory"
 )
 
 /***********************************************************************************************************************
@@ -42,6 +46,10 @@ const (
 
 const dbVersion = 2
 
+const maxJournalEntries = 1000
+
+const maxHistoryEntries = 10000
+
 /***********************************************************************************************************************
  * Vars
  **********************************************************************************************************************/
@@ -201,6 +209,146 @@ func (db *Database) SetAosVersion(id string, version uint64) (err error) {
 	return nil
 }
 
+// AddJournalEntry adds module operation journal entry.
+func (db *Database) AddJournalEntry(id string, entry opjournal.Entry) (err error) {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = db.sql.Exec("INSERT INTO journal (id, timestamp, entry) values(?, ?, ?)",
+		id, entry.Timestamp.UnixNano(), entryJSON); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = db.sql.Exec(`DELETE FROM journal WHERE id = ? AND rowid NOT IN
+		(SELECT rowid FROM journal WHERE id = ? ORDER BY rowid DESC LIMIT ?)`,
+		id, id, maxJournalEntries); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// GetJournalEntries returns module operation journal entries.
+func (db *Database) GetJournalEntries(id string) (entries []opjournal.Entry, err error) {
+	rows, err := db.sql.Query("SELECT entry FROM journal WHERE id = ? ORDER BY rowid", id)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entryJSON []byte
+			entry     opjournal.Entry
+		)
+
+		if err = rows.Scan(&entryJSON); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if err = json.Unmarshal(entryJSON, &entry); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, aoserrors.Wrap(rows.Err())
+}
+
+// AddHistoryEntry adds update history entry.
+func (db *Database) AddHistoryEntry(entry updatehistory.Entry) (err error) {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = db.sql.Exec("INSERT INTO history (session, timestamp, entry) values(?, ?, ?)",
+		entry.Session, entry.Timestamp.UnixNano(), entryJSON); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = db.sql.Exec(`DELETE FROM history WHERE rowid NOT IN
+		(SELECT rowid FROM history ORDER BY rowid DESC LIMIT ?)`, maxHistoryEntries); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// GetHistoryEntries returns update history entries of the session or all entries if session is empty.
+func (db *Database) GetHistoryEntries(session string) (entries []updatehistory.Entry, err error) {
+	rows, err := db.sql.Query("SELECT entry FROM history WHERE ? = '' OR session = ? ORDER BY rowid",
+		session, session)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entryJSON []byte
+			entry     updatehistory.Entry
+		)
+
+		if err = rows.Scan(&entryJSON); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if err = json.Unmarshal(entryJSON, &entry); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, aoserrors.Wrap(rows.Err())
+}
+
+// GetInstanceID returns update manager instance ID or empty string if it is not set.
+func (db *Database) GetInstanceID() (id string, err error) {
+	if err = db.sql.QueryRow("SELECT id FROM instance").Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", aoserrors.Wrap(err)
+	}
+
+	return id, nil
+}
+
+// SetInstanceID stores update manager instance ID.
+func (db *Database) SetInstanceID(id string) (err error) {
+	tx, err := db.sql.Begin()
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("DELETE FROM instance"); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = tx.Exec("INSERT INTO instance (id) values(?)", id); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(tx.Commit())
+}
+
+// Stats returns database connection statistics.
+func (db *Database) Stats() (stats sql.DBStats) {
+	return db.sql.Stats()
+}
+
 // Close closes database.
 func (db *Database) Close() {
 	db.sql.Close()
@@ -249,12 +397,19 @@ func newDatabase(name string, migrationPath string, mergedMigrationPath string,
 		return nil, aoserrors.Wrap(err)
 	}
 
+	var legacy *legacyData
+
 	if !exists {
 		// Set database version if database not exist
 		if err = migration.SetDatabaseVersion(sqlite, mergedMigrationPath, version); err != nil {
 			return db, aoserrors.Errorf("%s (%s)", ErrMigrationFailedStr, err.Error())
 		}
 	} else {
+		// Legacy data is read before migration as migration drops legacy tables
+		if legacy, err = db.readLegacyData(); err != nil {
+			return nil, err
+		}
+
 		if err = migration.DoMigrate(db.sql, mergedMigrationPath, version); err != nil {
 			return nil, aoserrors.Errorf("%s (%s)", ErrMigrationFailedStr, err.Error())
 		}
@@ -268,6 +423,25 @@ func newDatabase(name string, migrationPath string, mergedMigrationPath string,
 		return nil, aoserrors.Wrap(err)
 	}
 
+	if err := db.createJournalTable(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err := db.createHistoryTable(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err := db.createInstanceTable(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	// Version 0 has legacy layout, legacy data is imported only if migration drops it
+	if legacy != nil && version > 0 {
+		if err = db.importLegacyData(legacy); err != nil {
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
 
@@ -323,3 +497,43 @@ func (db *Database) createModuleTable() (err error) {
 
 	return nil
 }
+
+func (db *Database) createJournalTable() (err error) {
+	log.Info("Create journal table")
+
+	if _, err = db.sql.Exec(
+		`CREATE TABLE IF NOT EXISTS journal (
+			id TEXT NOT NULL,
+			timestamp INTEGER,
+			entry TEXT)`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func (db *Database) createHistoryTable() (err error) {
+	log.Info("Create history table")
+
+	if _, err = db.sql.Exec(
+		`CREATE TABLE IF NOT EXISTS history (
+			session TEXT,
+			timestamp INTEGER,
+			entry TEXT)`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func (db *Database) createInstanceTable() (err error) {
+	log.Info("Create instance table")
+
+	if _, err = db.sql.Exec(
+		`CREATE TABLE IF NOT EXISTS instance (
+			id TEXT)`); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
@@ -26,10 +26,14 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/migration"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+	"github.com/aoscloud/aos_updatemanager/utils/updatehistory"
 )
 
 /***********************************************************************************************************************
@@ -188,6 +192,111 @@ func TestModuleState(t *testing.T) {
 	}
 }
 
+func TestJournal(t *testing.T) {
+	startTime := time.Unix(1700000000, 0).UTC()
+
+	for i := 0; i < maxJournalEntries+10; i++ {
+		if err := db.AddJournalEntry("journalID", opjournal.Entry{
+			Timestamp: startTime.Add(time.Duration(i) * time.Second),
+			Type:      opjournal.EntryCommand,
+			Command:   "command" + strconv.Itoa(i),
+			ExitCode:  i % 2,
+		}); err != nil {
+			t.Fatalf("Can't add journal entry: %s", err)
+		}
+	}
+
+	if err := db.AddJournalEntry("otherID", opjournal.Entry{
+		Timestamp: startTime, Type: opjournal.EntryFile, Operation: opjournal.FileWrite, Files: []string{"/file"},
+	}); err != nil {
+		t.Fatalf("Can't add journal entry: %s", err)
+	}
+
+	entries, err := db.GetJournalEntries("journalID")
+	if err != nil {
+		t.Fatalf("Can't get journal entries: %s", err)
+	}
+
+	if len(entries) != maxJournalEntries {
+		t.Fatalf("Wrong journal entries count: %d", len(entries))
+	}
+
+	if entries[0].Command != "command10" || !entries[0].Timestamp.Equal(startTime.Add(10*time.Second)) {
+		t.Errorf("Wrong first journal entry: %v", entries[0])
+	}
+
+	if entries[len(entries)-1].ExitCode != 1 {
+		t.Errorf("Wrong last journal entry: %v", entries[len(entries)-1])
+	}
+
+	if entries, err = db.GetJournalEntries("otherID"); err != nil {
+		t.Fatalf("Can't get journal entries: %s", err)
+	}
+
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].Files, []string{"/file"}) {
+		t.Errorf("Wrong journal entries: %v", entries)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	startTime := time.Unix(1700000000, 0).UTC()
+
+	for i, entry := range []updatehistory.Entry{
+		{Session: "session1", Type: updatehistory.EntryTransition, Event: "prepare", From: "idle", To: "prepared"},
+		{Session: "session1", Type: updatehistory.EntryOperation, ID: "id1", Phase: "prepare", Error: "failed"},
+		{Session: "session2", Type: updatehistory.EntryOperation, ID: "id2", Phase: "update", AosVersion: 2},
+	} {
+		entry.Timestamp = startTime.Add(time.Duration(i) * time.Second)
+
+		if err := db.AddHistoryEntry(entry); err != nil {
+			t.Fatalf("Can't add history entry: %s", err)
+		}
+	}
+
+	entries, err := db.GetHistoryEntries("session1")
+	if err != nil {
+		t.Fatalf("Can't get history entries: %s", err)
+	}
+
+	if len(entries) != 2 || entries[0].To != "prepared" || entries[1].Error != "failed" ||
+		!entries[1].Timestamp.Equal(startTime.Add(time.Second)) {
+		t.Errorf("Wrong history entries: %v", entries)
+	}
+
+	if entries, err = db.GetHistoryEntries(""); err != nil {
+		t.Fatalf("Can't get history entries: %s", err)
+	}
+
+	if len(entries) < 3 || entries[len(entries)-1].ID != "id2" || entries[len(entries)-1].AosVersion != 2 {
+		t.Errorf("Wrong history entries: %v", entries)
+	}
+}
+
+func TestInstanceID(t *testing.T) {
+	id, err := db.GetInstanceID()
+	if err != nil {
+		t.Fatalf("Can't get instance ID: %s", err)
+	}
+
+	if id != "" {
+		t.Errorf("Unexpected instance ID: %s", id)
+	}
+
+	for _, setID := range []string{"instance1", "instance2"} {
+		if err = db.SetInstanceID(setID); err != nil {
+			t.Fatalf("Can't set instance ID: %s", err)
+		}
+
+		if id, err = db.GetInstanceID(); err != nil {
+			t.Fatalf("Can't get instance ID: %s", err)
+		}
+
+		if id != setID {
+			t.Errorf("Wrong instance ID: %s", id)
+		}
+	}
+}
+
 func TestMultiThread(t *testing.T) {
 	const numIterations = 1000
 
@@ -300,10 +409,99 @@ func TestMigrationToV2(t *testing.T) {
 	db.Close()
 }
 
+func TestLegacyImport(t *testing.T) {
+	legacyDB := path.Join(tmpDir, "test_legacy.db")
+
+	if err := os.RemoveAll(legacyDB); err != nil {
+		t.Fatalf("Error deleting legacy DB: %s", err)
+	}
+
+	if err := createDatabaseV0(legacyDB); err != nil {
+		t.Fatalf("Can't create legacy database: %s", err)
+	}
+
+	if err := fillLegacyDatabase(legacyDB); err != nil {
+		t.Fatalf("Can't fill legacy database: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		db, err := newDatabase(legacyDB, migrationDir, migrationDir, dbVersion)
+		if err != nil {
+			t.Fatalf("Can't create database: %s", err)
+		}
+
+		for _, id := range []string{"rootfs", "boot"} {
+			version, err := db.GetAosVersion(id)
+			if err != nil {
+				t.Errorf("Can't get Aos version: %s", err)
+			}
+
+			if version != 4 {
+				t.Errorf("Wrong %s Aos version: %d", id, version)
+			}
+		}
+
+		state, err := db.GetModuleState("rootfs")
+		if err != nil {
+			t.Errorf("Can't get module state: %s", err)
+		}
+
+		if string(state) != "rootfs state" {
+			t.Errorf("Wrong module state: %s", string(state))
+		}
+
+		if state, err = db.GetModuleState("boot.partition"); err != nil {
+			t.Errorf("Can't get module state: %s", err)
+		}
+
+		if string(state) != "b" {
+			t.Errorf("Wrong module data: %s", string(state))
+		}
+
+		if state, err = db.GetUpdateState(); err != nil {
+			t.Errorf("Can't get update state: %s", err)
+		}
+
+		if len(state) != 0 {
+			t.Errorf("Legacy update state is not reset: %s", string(state))
+		}
+
+		db.Close()
+	}
+}
+
 /*******************************************************************************
  * Private
  ******************************************************************************/
 
+func fillLegacyDatabase(name string) (err error) {
+	sqlite, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=%s&_sync=%s",
+		name, busyTimeout, journalMode, syncMode))
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer sqlite.Close()
+
+	if _, err = sqlite.Exec(`UPDATE config SET updateState = ?`,
+		`{"state":1,"operationStage":2,"imagePath":"/tmp/image.bin","currentVersion":4,"operationVersion":5}`,
+	); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	for _, item := range [][]string{{"rootfs", "rootfs state"}, {"boot", "boot state"}} {
+		if _, err = sqlite.Exec(`INSERT INTO modules (id, state) values(?, ?)`, item[0], item[1]); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	if _, err = sqlite.Exec(`INSERT INTO modules_data (id, name, value) values(?, ?, ?)`,
+		"boot", "partition", "b"); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
 func createDatabaseV0(name string) (err error) {
 	sqlite, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=%s&_sync=%s",
 		name, busyTimeout, journalMode, syncMode))
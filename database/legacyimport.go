@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2021 Renesas Electronics Corporation.
+// Copyright (C) 2021 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Legacy database of umprotocol era UM has modules_data table with module key-value data and update state with
+// system wide current and operation versions, operation stage and image path of the operation. Legacy data is read
+// before schema migration as migration drops modules_data table and imported once the current schema is created:
+// legacy current version becomes Aos version of each legacy module, so the version history is kept, module data
+// entries are stored as module storage entries namespaced by module ID. Legacy operation can't be continued by the
+// current handler: update state is reset and interrupted operation is only logged.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type legacyUpdateState struct {
+	State            json.RawMessage `json:"state,omitempty"`
+	OperationStage   json.RawMessage `json:"operationStage,omitempty"`
+	ImagePath        string          `json:"imagePath,omitempty"`
+	CurrentVersion   uint64          `json:"currentVersion,omitempty"`
+	OperationVersion uint64          `json:"operationVersion,omitempty"`
+	LastError        string          `json:"lastError,omitempty"`
+}
+
+type legacyModuleData struct {
+	id, name, value string
+}
+
+type legacyData struct {
+	state      *legacyUpdateState
+	moduleIDs  []string
+	moduleData []legacyModuleData
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// readLegacyData returns nil if database has no legacy layout.
+func (db *Database) readLegacyData() (data *legacyData, err error) {
+	exists, err := db.isTableExist("modules_data")
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	log.Info("Legacy database layout detected")
+
+	data = &legacyData{}
+
+	var jsonState []byte
+
+	if err = db.sql.QueryRow("SELECT updateState FROM config").Scan(&jsonState); err != nil &&
+		!errors.Is(err, sql.ErrNoRows) {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if data.state, err = parseLegacyUpdateState(jsonState); err != nil {
+		return nil, err
+	}
+
+	if data.moduleIDs, err = db.readLegacyModuleIDs(); err != nil {
+		return nil, err
+	}
+
+	if data.moduleData, err = db.readLegacyModuleData(); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// parseLegacyUpdateState returns nil if update state is not in legacy format.
+func parseLegacyUpdateState(jsonState []byte) (state *legacyUpdateState, err error) {
+	if len(bytes.TrimSpace(jsonState)) == 0 {
+		return nil, nil
+	}
+
+	var keys map[string]json.RawMessage
+
+	// Update state which is not JSON object can't be legacy one
+	if json.Unmarshal(jsonState, &keys) != nil {
+		return nil, nil
+	}
+
+	_, hasStage := keys["operationStage"]
+	_, hasImagePath := keys["imagePath"]
+
+	if !hasStage && !hasImagePath {
+		return nil, nil
+	}
+
+	state = &legacyUpdateState{}
+
+	if err = json.Unmarshal(jsonState, state); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return state, nil
+}
+
+func (db *Database) readLegacyModuleIDs() (ids []string, err error) {
+	rows, err := db.sql.Query("SELECT id FROM modules")
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+
+		if err = rows.Scan(&id); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, aoserrors.Wrap(rows.Err())
+}
+
+func (db *Database) readLegacyModuleData() (moduleData []legacyModuleData, err error) {
+	rows, err := db.sql.Query("SELECT id, name, value FROM modules_data")
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			item  legacyModuleData
+			value sql.NullString
+		)
+
+		if err = rows.Scan(&item.id, &item.name, &value); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		item.value = value.String
+		moduleData = append(moduleData, item)
+	}
+
+	return moduleData, aoserrors.Wrap(rows.Err())
+}
+
+// importLegacyData converts legacy data to the current schema in one transaction.
+func (db *Database) importLegacyData(data *legacyData) (err error) {
+	tx, err := db.sql.Begin()
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, item := range data.moduleData {
+		if _, err = tx.Exec("INSERT OR REPLACE INTO modules (id, state) values(?, ?)",
+			item.id+"."+item.name, item.value); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	if data.state != nil {
+		logLegacyOperation(data.state)
+
+		if data.state.CurrentVersion != 0 {
+			for _, id := range data.moduleIDs {
+				if _, err = tx.Exec("UPDATE modules SET aosVersion = ? WHERE id = ? AND aosVersion IS NULL",
+					data.state.CurrentVersion, id); err != nil {
+					return aoserrors.Wrap(err)
+				}
+			}
+		}
+
+		if _, err = tx.Exec("UPDATE config SET updateState = ?", ""); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	log.WithFields(log.Fields{
+		"modules": len(data.moduleIDs), "moduleData": len(data.moduleData),
+	}).Info("Legacy database imported")
+
+	return nil
+}
+
+func logLegacyOperation(state *legacyUpdateState) {
+	if state.OperationVersion == 0 || state.OperationVersion == state.CurrentVersion {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"state": string(state.State), "stage": string(state.OperationStage), "imagePath": state.ImagePath,
+		"currentVersion": state.CurrentVersion, "operationVersion": state.OperationVersion,
+		"error": state.LastError,
+	}).Warn("Legacy operation is interrupted by upgrade")
+}
@@ -8,8 +8,10 @@ replace github.com/anexia-it/fsquota => github.com/aoscloud/fsquota v0.0.0-20231
 
 require (
 	github.com/aoscloud/aos_common v0.0.0-20240229163820-8da83091bc41
+	github.com/cavaliergopher/grab/v3 v3.0.1
 	github.com/coreos/go-systemd v0.0.0-20191104093116-d3cd4ed1dbcf
 	github.com/coreos/go-systemd/v22 v22.5.0
+	github.com/godbus/dbus/v5 v5.0.4
 	github.com/golang/protobuf v1.5.3
 	github.com/joelnb/xenstore-go v0.3.0
 	github.com/looplab/fsm v1.0.1
@@ -17,6 +19,7 @@ require (
 	github.com/sirupsen/logrus v1.9.3
 	github.com/tmc/scp v0.0.0-20170824174625-f7b48647feef
 	golang.org/x/crypto v0.16.0
+	golang.org/x/sys v0.15.0
 	google.golang.org/grpc v1.59.0
 	gopkg.in/ini.v1 v1.67.0
 )
@@ -24,9 +27,7 @@ require (
 require (
 	github.com/ThalesIgnite/crypto11 v0.0.0-00010101000000-000000000000 // indirect
 	github.com/anexia-it/fsquota v0.0.0-00010101000000-000000000000 // indirect
-	github.com/cavaliergopher/grab/v3 v3.0.1 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
-	github.com/godbus/dbus/v5 v5.0.4 // indirect
 	github.com/golang-migrate/migrate/v4 v4.16.2 // indirect
 	github.com/google/go-tpm v0.9.0 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
@@ -45,7 +46,6 @@ require (
 	github.com/thales-e-security/pool v0.0.2 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	golang.org/x/net v0.17.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
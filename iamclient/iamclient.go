@@ -45,8 +45,9 @@ const iamRequestTimeout = 30 * time.Second
 
 // Client IAM client instance.
 type Client struct {
-	connection *grpc.ClientConn
-	service    pb.IAMPublicServiceClient
+	connection         *grpc.ClientConn
+	service            pb.IAMPublicServiceClient
+	permissionsService pb.IAMPublicPermissionsServiceClient
 }
 
 /***********************************************************************************************************************
@@ -62,6 +63,8 @@ func New(config *config.Config, cryptocontext *cryptutils.CryptoContext, insecur
 		return client, err
 	}
 
+	client.permissionsService = pb.NewIAMPublicPermissionsServiceClient(client.connection)
+
 	defer func() {
 		if err != nil {
 			client.Close()
@@ -118,6 +121,22 @@ func (client *Client) GetCertificate(cerType string) (certURL, keyURL string, er
 	return response.GetCertUrl(), response.GetKeyUrl(), nil
 }
 
+// GetPermissions returns permissions of functional server for the instance identified by secret.
+func (client *Client) GetPermissions(secret, funcServerID string) (permissions map[string]string, err error) {
+	log.WithField("funcServerID", funcServerID).Debug("Get permissions")
+
+	ctx, cancel := context.WithTimeout(context.Background(), iamRequestTimeout)
+	defer cancel()
+
+	response, err := client.permissionsService.GetPermissions(ctx,
+		&pb.PermissionsRequest{Secret: secret, FunctionalServerId: funcServerID})
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return response.GetPermissions().GetPermissions(), nil
+}
+
 /***********************************************************************************************************************
  * Private
  **********************************************************************************************************************/
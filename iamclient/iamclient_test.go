@@ -21,6 +21,7 @@ import (
 	"context"
 	"net"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/aoscloud/aos_common/aoserrors"
@@ -51,11 +52,13 @@ type certInfo struct {
 
 type testServer struct {
 	pb.UnimplementedIAMPublicServiceServer
+	pb.UnimplementedIAMPublicPermissionsServiceServer
 
-	grpcServer *grpc.Server
-	nodeID     string
-	certURL    certInfo
-	keyURL     certInfo
+	grpcServer  *grpc.Server
+	nodeID      string
+	certURL     certInfo
+	keyURL      certInfo
+	permissions map[string]map[string]string
 }
 
 /***********************************************************************************************************************
@@ -143,6 +146,31 @@ func TestGetCertificates(t *testing.T) {
 	}
 }
 
+func TestGetPermissions(t *testing.T) {
+	server.permissions = map[string]map[string]string{
+		"secret1": {"applyUpdate": "rw", "revertUpdate": "r"},
+	}
+
+	client, err := iamclient.New(&config.Config{IAMPublicServerURL: serverURL}, nil, true)
+	if err != nil {
+		t.Fatalf("Can't create IAM client: %s", err)
+	}
+	defer client.Close()
+
+	permissions, err := client.GetPermissions("secret1", "um")
+	if err != nil {
+		t.Fatalf("Can't get permissions: %s", err)
+	}
+
+	if !reflect.DeepEqual(permissions, server.permissions["secret1"]) {
+		t.Errorf("Wrong permissions: %v", permissions)
+	}
+
+	if _, err = client.GetPermissions("unknown", "um"); err == nil {
+		t.Error("Error expected for unknown secret")
+	}
+}
+
 /***********************************************************************************************************************
  * Private
  **********************************************************************************************************************/
@@ -158,6 +186,7 @@ func newTestServer(url string) (server *testServer, err error) {
 	server.grpcServer = grpc.NewServer()
 
 	pb.RegisterIAMPublicServiceServer(server.grpcServer, server)
+	pb.RegisterIAMPublicPermissionsServiceServer(server.grpcServer, server)
 
 	go func() {
 		if err := server.grpcServer.Serve(listener); err != nil {
@@ -194,3 +223,14 @@ func (server *testServer) GetCert(context context.Context, req *pb.GetCertReques
 
 	return rsp, nil
 }
+
+func (server *testServer) GetPermissions(
+	context context.Context, req *pb.PermissionsRequest,
+) (*pb.PermissionsResponse, error) {
+	permissions, ok := server.permissions[req.GetSecret()]
+	if !ok {
+		return nil, aoserrors.New("not found")
+	}
+
+	return &pb.PermissionsResponse{Permissions: &pb.Permissions{Permissions: permissions}}, nil
+}
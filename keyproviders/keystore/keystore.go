@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keystore provides image decryption key provider which reads keys from local keystore dir.
+package keystore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+// Each key is stored in own file <key ID>.key of keystore dir as raw key bytes.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const keyExtension = ".key"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// KeyStore local keystore key provider.
+type KeyStore struct {
+	config providerConfig
+}
+
+type providerConfig struct {
+	Path string `json:"path"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates local keystore key provider.
+func New(params json.RawMessage) (provider updatehandler.KeyProvider, err error) {
+	keyStore := &KeyStore{}
+
+	if len(params) == 0 {
+		return nil, aoserrors.New("keystore params are required")
+	}
+
+	if err = updatehandler.DecodeParams(params, &keyStore.config); err != nil {
+		return nil, err
+	}
+
+	if keyStore.config.Path == "" {
+		return nil, aoserrors.New("keystore path should be configured")
+	}
+
+	log.WithField("path", keyStore.config.Path).Debug("Create keystore key provider")
+
+	return keyStore, nil
+}
+
+// GetKey returns key by key ID.
+func (keyStore *KeyStore) GetKey(keyID string) (key []byte, err error) {
+	if keyID == "" || keyID == "." || keyID == ".." || filepath.Base(keyID) != keyID {
+		return nil, aoserrors.Errorf("invalid key ID %s", keyID)
+	}
+
+	if key, err = os.ReadFile(filepath.Join(keyStore.config.Path, keyID+keyExtension)); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return key, nil
+}
+
+// Close closes key provider.
+func (keyStore *KeyStore) Close() (err error) {
+	return nil
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keystore_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/keyproviders/keystore"
+)
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestWrongParams(t *testing.T) {
+	for _, params := range []string{``, `{}`, `{"path": "/keys", "unknown": 1}`} {
+		if _, err := keystore.New(json.RawMessage(params)); err == nil {
+			t.Errorf("Error expected for params: %s", params)
+		}
+	}
+}
+
+func TestGetKey(t *testing.T) {
+	keysDir := t.TempDir()
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	if err := os.WriteFile(filepath.Join(keysDir, "eu.key"), key, 0o600); err != nil {
+		t.Fatalf("Can't write key: %v", err)
+	}
+
+	provider, err := keystore.New(json.RawMessage(`{"path": "` + keysDir + `"}`))
+	if err != nil {
+		t.Fatalf("Can't create keystore: %v", err)
+	}
+	defer provider.Close()
+
+	result, err := provider.GetKey("eu")
+	if err != nil {
+		t.Fatalf("Can't get key: %v", err)
+	}
+
+	if !bytes.Equal(result, key) {
+		t.Errorf("Wrong key: %x", result)
+	}
+
+	for _, keyID := range []string{"us", "", "..", "../eu", "dir/eu"} {
+		if _, err = provider.GetKey(keyID); err == nil {
+			t.Errorf("Error expected for key ID: %s", keyID)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
 )
 
 /***********************************************************************************************************************
@@ -47,6 +49,16 @@ const (
 	reconnectTimeout = 10 * time.Second
 )
 
+const secretMetadataKey = "aos-secret"
+
+// Control operations checked against IAM permissions.
+const (
+	OperationPrepareUpdate = "prepareUpdate"
+	OperationStartUpdate   = "startUpdate"
+	OperationApplyUpdate   = "applyUpdate"
+	OperationRevertUpdate  = "revertUpdate"
+)
+
 // UM states.
 const (
 	StateIdle = iota
@@ -69,11 +81,14 @@ const (
 // Client UM client instance.
 type Client struct {
 	sync.Mutex
-	connection     *grpc.ClientConn
-	stream         pb.UMService_RegisterUMClient
-	messageHandler MessageHandler
-	umID           string
-	closeChannel   chan struct{}
+	connection         *grpc.ClientConn
+	stream             pb.UMService_RegisterUMClient
+	messageHandler     MessageHandler
+	permissionProvider PermissionProvider
+	funcServerID       string
+	umID               string
+	closeChannel       chan struct{}
+	clock              clock.Clock
 }
 
 // UMState UM state.
@@ -84,14 +99,16 @@ type ComponentStatus int32
 
 // ComponentUpdateInfo component update info.
 type ComponentUpdateInfo struct {
-	ID            string
-	VendorVersion string
-	AosVersion    uint64
-	Annotations   json.RawMessage
-	URL           string
-	Sha256        []byte
-	Sha512        []byte
-	Size          uint64
+	ID             string
+	VendorVersion  string
+	AosVersion     uint64
+	Annotations    json.RawMessage
+	URL            string
+	Sha256         []byte
+	Sha512         []byte
+	Size           uint64
+	Reinstall      bool
+	ForceDowngrade bool
 }
 
 // ComponentStatusInfo component status info.
@@ -101,19 +118,47 @@ type ComponentStatusInfo struct {
 	AosVersion    uint64
 	Status        ComponentStatus
 	Error         string
+	Progress      *DownloadProgress `json:",omitempty"`
+}
+
+// DownloadProgress component image download progress.
+type DownloadProgress struct {
+	BytesComplete uint64
+	TotalBytes    uint64
+	Percentage    uint8
+	ETA           time.Duration
+}
+
+// ComponentCapabilities component update capabilities.
+type ComponentCapabilities struct {
+	Delta          bool
+	RebootRequired bool
+	MaxImageSize   uint64
+	ImageFormats   []string
+}
+
+// ComponentHealth component health score and update failures counted within health score window.
+type ComponentHealth struct {
+	Score        int
+	Failures     int
+	Reverts      int
+	BootFailures int
 }
 
 // Status update manager status.
 type Status struct {
-	State      UMState
-	Error      string
-	Components []ComponentStatusInfo
+	InstanceID   string
+	State        UMState
+	Error        string
+	Components   []ComponentStatusInfo
+	Capabilities map[string]ComponentCapabilities `json:",omitempty"`
+	HealthScores map[string]ComponentHealth       `json:",omitempty"`
 }
 
 // MessageHandler incoming messages handler.
 type MessageHandler interface {
-	// Registered indicates the client registered on the server
-	Registered()
+	// GetStatus returns current status without waiting for running operation
+	GetStatus() (status Status)
 	// PrepareUpdate prepares update
 	PrepareUpdate(components []ComponentUpdateInfo)
 	// StartUpdate starts update
@@ -132,13 +177,18 @@ type CertificateProvider interface {
 	GetCertificate(certType string) (certURL, ketURL string, err error)
 }
 
+// PermissionProvider interface to get permissions of the requesting functional unit.
+type PermissionProvider interface {
+	GetPermissions(secret, funcServerID string) (permissions map[string]string, err error)
+}
+
 /***********************************************************************************************************************
  * Public
  **********************************************************************************************************************/
 
 // New creates new UM client.
 func New(cfg *config.Config, messageHandler MessageHandler, certProvider CertificateProvider,
-	cryptocontext *cryptutils.CryptoContext, insecure bool,
+	permissionProvider PermissionProvider, cryptocontext *cryptutils.CryptoContext, insecure bool,
 ) (client *Client, err error) {
 	log.Debug("Create UM client")
 
@@ -147,8 +197,11 @@ func New(cfg *config.Config, messageHandler MessageHandler, certProvider Certifi
 	}
 
 	client = &Client{
-		messageHandler: messageHandler,
-		closeChannel:   make(chan struct{}),
+		messageHandler:     messageHandler,
+		permissionProvider: permissionProvider,
+		funcServerID:       cfg.FunctionalServerID,
+		closeChannel:       make(chan struct{}),
+		clock:              clock.New(),
 	}
 
 	if client.umID, err = certProvider.GetNodeID(); err != nil {
@@ -205,6 +258,31 @@ func (status ComponentStatus) String() string {
 	}[status]
 }
 
+// CheckPermission checks that caller identified by secret has access ("r" or "w") to the operation. Secret is taken
+// from each request, so requests of different callers are checked against their own permissions.
+func CheckPermission(
+	provider PermissionProvider, funcServerID, secret, operation, access string,
+) (err error) {
+	if provider == nil || funcServerID == "" {
+		return nil
+	}
+
+	if secret == "" {
+		return aoserrors.Errorf("no secret provided for %s operation", operation)
+	}
+
+	permissions, err := provider.GetPermissions(secret, funcServerID)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if !strings.Contains(permissions[operation], access) {
+		return aoserrors.Errorf("%s operation is not permitted", operation)
+	}
+
+	return nil
+}
+
 /***********************************************************************************************************************
  * Private
  **********************************************************************************************************************/
@@ -249,7 +327,11 @@ func (client *Client) createConnection(
 			if err != nil && len(client.closeChannel) == 0 {
 				log.Errorf("Error register to CM: %s", aoserrors.Wrap(err))
 			} else {
-				client.messageHandler.Registered()
+				// Current status is the reply to registration: it is taken from handler status snapshot to not wait
+				// for running operation
+				if err = client.sendStatus(currentStatus(client.messageHandler.GetStatus())); err != nil {
+					log.Errorf("Can't send status: %s", aoserrors.Wrap(err))
+				}
 
 				if err = client.processMessages(); err != nil {
 					if errors.Is(err, io.EOF) {
@@ -268,7 +350,7 @@ func (client *Client) createConnection(
 
 				return
 
-			case <-time.After(reconnectTimeout):
+			case <-client.clock.After(reconnectTimeout):
 				err = client.register()
 			}
 		}
@@ -306,6 +388,20 @@ func (client *Client) processMessages() (err error) {
 			return aoserrors.Wrap(err)
 		}
 
+		if err = client.checkPermission(message); err != nil {
+			log.Errorf("Request rejected: %s", aoserrors.Wrap(err))
+
+			// Server waits for status reply: current status with rejection error is sent back
+			status := currentStatus(client.messageHandler.GetStatus())
+			status.Error = err.Error()
+
+			if err = client.sendStatus(status); err != nil {
+				log.Errorf("Can't send status: %s", aoserrors.Wrap(err))
+			}
+
+			continue
+		}
+
 		switch data := message.GetCMMessage().(type) {
 		case *pb.CMMessages_PrepareUpdate:
 			log.Debug("Prepare update received")
@@ -346,6 +442,61 @@ func (client *Client) processMessages() (err error) {
 	}
 }
 
+// checkPermission checks permission of server request. The protocol has no per message caller identity: requests
+// are sent by the server on behalf of the caller identified by the secret provided in the header of the request
+// stream. It is read for each request as the stream is recreated on reconnect.
+func (client *Client) checkPermission(message *pb.CMMessages) (err error) {
+	if client.permissionProvider == nil || client.funcServerID == "" {
+		return nil
+	}
+
+	var operation string
+
+	switch message.GetCMMessage().(type) {
+	case *pb.CMMessages_PrepareUpdate:
+		operation = OperationPrepareUpdate
+
+	case *pb.CMMessages_StartUpdate:
+		operation = OperationStartUpdate
+
+	case *pb.CMMessages_ApplyUpdate:
+		operation = OperationApplyUpdate
+
+	case *pb.CMMessages_RevertUpdate:
+		operation = OperationRevertUpdate
+
+	default:
+		return nil
+	}
+
+	header, err := client.stream.Header()
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	var secret string
+
+	if secrets := header.Get(secretMetadataKey); len(secrets) != 0 {
+		secret = secrets[0]
+	}
+
+	return CheckPermission(client.permissionProvider, client.funcServerID, secret, operation, "w")
+}
+
+// currentStatus returns status without download progress as it is reported as final one.
+func currentStatus(status Status) Status {
+	components := make([]ComponentStatusInfo, 0, len(status.Components))
+
+	for _, component := range status.Components {
+		component.Progress = nil
+		components = append(components, component)
+	}
+
+	status.Components = components
+
+	return status
+}
+
 func (client *Client) sendStatus(status Status) (err error) {
 	client.Lock()
 	defer client.Unlock()
@@ -354,7 +505,22 @@ func (client *Client) sendStatus(status Status) (err error) {
 		return aoserrors.New("client is not connected")
 	}
 
-	log.WithFields(log.Fields{"umID": client, "state": status.State, "error": status.Error}).Debug("Send status")
+	// Download progress can't be sent as the protocol has no progress fields, progress status is intermediate
+	// and should not be reported as final one
+	for _, component := range status.Components {
+		if component.Progress != nil {
+			log.WithField("id", component.ID).Debug("Skip download progress status")
+
+			return nil
+		}
+	}
+
+	// Protocol has no instance ID, capabilities and health score fields, they are only logged to attribute the status
+	// sent with node ID
+	log.WithFields(log.Fields{
+		"umID": client.umID, "instanceID": status.InstanceID, "state": status.State, "error": status.Error,
+		"capabilities": status.Capabilities, "healthScores": status.HealthScores,
+	}).Debug("Send status")
 
 	pbComponents := make([]*pb.SystemComponent, 0, len(status.Components))
 
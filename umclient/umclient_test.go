@@ -24,6 +24,7 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	pb "github.com/aoscloud/aos_common/api/updatemanager/v1"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/aoscloud/aos_updatemanager/config"
 	"github.com/aoscloud/aos_updatemanager/umclient"
@@ -60,6 +62,7 @@ const (
 
 type testServer struct {
 	grpcServer      *grpc.Server
+	secret          string
 	stream          pb.UMService_RegisterUMServer
 	registerChannel chan bool
 	statusChannel   chan umclient.Status
@@ -69,6 +72,7 @@ type testServer struct {
 type testMessageHandler struct {
 	messageChannel chan string
 	components     []umclient.ComponentUpdateInfo
+	status         umclient.Status
 	statusChannel  chan umclient.Status
 }
 
@@ -76,6 +80,10 @@ type testCertProvider struct {
 	nodeID string
 }
 
+type testPermissionProvider struct {
+	permissions map[string]map[string]string
+}
+
 /***********************************************************************************************************************
  * Vars
  **********************************************************************************************************************/
@@ -112,7 +120,18 @@ func TestMessages(t *testing.T) {
 
 	handler := newMessageHandler()
 
-	client, err := umclient.New(&config.Config{CMServerURL: serverURL}, handler, newCertProvider("um1"), nil, true)
+	handler.status = umclient.Status{
+		State: umclient.StatePrepared,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "test1", Status: umclient.StatusInstalled, VendorVersion: "1.0", AosVersion: 1},
+			{
+				ID: "test2", Status: umclient.StatusInstalling, VendorVersion: "2.0", AosVersion: 2,
+				Progress: &umclient.DownloadProgress{BytesComplete: 10, TotalBytes: 100, Percentage: 10},
+			},
+		},
+	}
+
+	client, err := umclient.New(&config.Config{CMServerURL: serverURL}, handler, newCertProvider("um1"), nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %s", err)
 	}
@@ -122,6 +141,19 @@ func TestMessages(t *testing.T) {
 		t.Fatalf("Can't wait client registered: %s", err)
 	}
 
+	// Current status without download progress is sent on registration
+
+	registerStatus, err := server.waitStatus()
+	if err != nil {
+		t.Fatalf("Can't wait status: %s", err)
+	}
+
+	handler.status.Components[1].Progress = nil
+
+	if !reflect.DeepEqual(registerStatus, handler.status) {
+		t.Errorf("Wrong registration status: %v", registerStatus)
+	}
+
 	// Prepare update
 
 	components := []umclient.ComponentUpdateInfo{
@@ -212,7 +244,7 @@ func TestServerDisconnect(t *testing.T) {
 
 	handler := newMessageHandler()
 
-	client, err := umclient.New(&config.Config{CMServerURL: serverURL}, handler, newCertProvider("um1"), nil, true)
+	client, err := umclient.New(&config.Config{CMServerURL: serverURL}, handler, newCertProvider("um1"), nil, nil, true)
 	if err != nil {
 		t.Fatalf("Can't create UM client: %s", err)
 	}
@@ -222,6 +254,10 @@ func TestServerDisconnect(t *testing.T) {
 		t.Fatalf("Can't wait client registered: %s", err)
 	}
 
+	if _, err = server.waitStatus(); err != nil {
+		t.Fatalf("Can't wait status: %s", err)
+	}
+
 	// Disconnect server
 
 	server.close()
@@ -236,6 +272,10 @@ func TestServerDisconnect(t *testing.T) {
 		t.Fatalf("Can't wait client registered: %s", err)
 	}
 
+	if _, err = server.waitStatus(); err != nil {
+		t.Fatalf("Can't wait status: %s", err)
+	}
+
 	// Prepare update
 
 	if err = server.prepareUpdate([]umclient.ComponentUpdateInfo{
@@ -263,6 +303,96 @@ func TestServerDisconnect(t *testing.T) {
 	}
 }
 
+func TestPermissions(t *testing.T) {
+	server, err := newTestServer(serverURL)
+	if err != nil {
+		t.Fatalf("Can't create test server: %s", err)
+	}
+
+	defer server.close()
+
+	server.secret = "secret1"
+
+	handler := newMessageHandler()
+	permissionProvider := &testPermissionProvider{permissions: map[string]map[string]string{
+		"secret1": {umclient.OperationStartUpdate: "rw", umclient.OperationApplyUpdate: "r"},
+	}}
+
+	client, err := umclient.New(&config.Config{CMServerURL: serverURL, FunctionalServerID: "um"},
+		handler, newCertProvider("um1"), permissionProvider, nil, true)
+	if err != nil {
+		t.Fatalf("Can't create UM client: %s", err)
+	}
+	defer client.Close()
+
+	if err = server.waitClientRegistered(); err != nil {
+		t.Fatalf("Can't wait client registered: %s", err)
+	}
+
+	if _, err = server.waitStatus(); err != nil {
+		t.Fatalf("Can't wait status: %s", err)
+	}
+
+	// Apply and revert are not permitted: they should be skipped and replied with error status
+
+	if err = server.applyUpdate(); err != nil {
+		t.Fatalf("Can't send apply update: %s", err)
+	}
+
+	if err = server.revertUpdate(); err != nil {
+		t.Fatalf("Can't send revert update: %s", err)
+	}
+
+	for _, operation := range []string{umclient.OperationApplyUpdate, umclient.OperationRevertUpdate} {
+		status, err := server.waitStatus()
+		if err != nil {
+			t.Fatalf("Can't wait status: %s", err)
+		}
+
+		if !strings.Contains(status.Error, operation+" operation is not permitted") {
+			t.Errorf("Wrong status error: %s", status.Error)
+		}
+	}
+
+	if err = server.startUpdate(); err != nil {
+		t.Fatalf("Can't send start update: %s", err)
+	}
+
+	if err = handler.waitMessage(startUpdateMessage); err != nil {
+		t.Errorf("Wait message error: %s", err)
+	}
+}
+
+func TestCheckPermission(t *testing.T) {
+	permissionProvider := &testPermissionProvider{permissions: map[string]map[string]string{
+		"secret1": {umclient.OperationStartUpdate: "rw"},
+		"secret2": {umclient.OperationStartUpdate: "r"},
+	}}
+
+	if err := umclient.CheckPermission(
+		permissionProvider, "um", "secret1", umclient.OperationStartUpdate, "w"); err != nil {
+		t.Errorf("Operation should be permitted: %s", err)
+	}
+
+	if err := umclient.CheckPermission(
+		permissionProvider, "um", "secret2", umclient.OperationStartUpdate, "w"); err == nil {
+		t.Error("Operation should not be permitted")
+	}
+
+	if err := umclient.CheckPermission(
+		permissionProvider, "um", "secret2", umclient.OperationStartUpdate, "r"); err != nil {
+		t.Errorf("Operation should be permitted: %s", err)
+	}
+
+	if err := umclient.CheckPermission(permissionProvider, "um", "", umclient.OperationStartUpdate, "r"); err == nil {
+		t.Error("Operation without secret should not be permitted")
+	}
+
+	if err := umclient.CheckPermission(nil, "", "", umclient.OperationStartUpdate, "w"); err != nil {
+		t.Errorf("Operation should be permitted without permission provider: %s", err)
+	}
+}
+
 /*******************************************************************************
  * Private
  ******************************************************************************/
@@ -297,6 +427,12 @@ func (server *testServer) close() {
 func (server *testServer) RegisterUM(stream pb.UMService_RegisterUMServer) (err error) {
 	server.stream = stream
 
+	if server.secret != "" {
+		if err = stream.SendHeader(metadata.Pairs("aos-secret", server.secret)); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
 	server.registerChannel <- true
 
 	for {
@@ -404,7 +540,8 @@ func newMessageHandler() (handler *testMessageHandler) {
 	return handler
 }
 
-func (handler *testMessageHandler) Registered() {
+func (handler *testMessageHandler) GetStatus() (status umclient.Status) {
+	return handler.status
 }
 
 func (handler *testMessageHandler) PrepareUpdate(components []umclient.ComponentUpdateInfo) {
@@ -457,3 +594,14 @@ func (provider *testCertProvider) GetNodeID() (string, error) {
 func (provider *testCertProvider) GetCertificate(certType string) (certURL, ketURL string, err error) {
 	return "", "", nil
 }
+
+func (provider *testPermissionProvider) GetPermissions(
+	secret, funcServerID string,
+) (permissions map[string]string, err error) {
+	permissions, ok := provider.permissions[secret]
+	if !ok {
+		return nil, aoserrors.New("permissions not found")
+	}
+
+	return permissions, nil
+}
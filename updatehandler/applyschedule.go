@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/schedule"
+)
+
+// If apply schedule is configured, apply request waits for the next schedule occurrence, e.g. "TZ=Europe/Berlin
+// 0 2 * * *" applies update at 02:00 Berlin time regardless of device time zone and DST. The resolved apply time is
+// persisted on first apply request and is not recomputed after restart or config change till the update is
+// finished, so the apply time reported to the operator doesn't move.
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newApplySchedule(expr string) (applySchedule *schedule.Schedule, err error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	if applySchedule, err = schedule.Parse(expr); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if applySchedule.Next(time.Now()).IsZero() {
+		return nil, aoserrors.Errorf("apply schedule %q never occurs", expr)
+	}
+
+	return applySchedule, nil
+}
+
+func (handler *Handler) waitApplySchedule() {
+	if handler.applySchedule == nil {
+		return
+	}
+
+	handler.Lock()
+
+	if handler.state.ApplyTime == nil {
+		applyTime := handler.applySchedule.Next(handler.clock.Now())
+
+		log.WithFields(log.Fields{
+			"schedule": handler.applySchedule, "applyTime": applyTime,
+		}).Debug("Resolve apply schedule")
+
+		handler.state.ApplyTime = &applyTime
+
+		if err := handler.saveState(); err != nil {
+			log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+		}
+	}
+
+	delay := handler.state.ApplyTime.Sub(handler.clock.Now())
+	if delay <= 0 {
+		handler.Unlock()
+
+		return
+	}
+
+	scheduled := handler.clock.After(delay)
+	applyTime := handler.state.ApplyTime.In(handler.applySchedule.Location()).Format(time.RFC3339)
+
+	log.WithField("applyTime", applyTime).Info("Apply deferred by schedule")
+
+	handler.state.Error = fmt.Sprintf("apply deferred: scheduled at %s", applyTime)
+	handler.sendStatus()
+
+	handler.Unlock()
+
+	select {
+	case <-scheduled:
+		log.Info("Apply schedule occurred, continue apply")
+
+	case <-handler.operationContext().Done():
+		log.Warn("Apply schedule waiting is stopped")
+	}
+}
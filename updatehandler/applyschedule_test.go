@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestApplySchedule(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   cfg.DownloadDir,
+		ApplySchedule: "TZ=Europe/Berlin 0 3 * * *",
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	// 03:00 Berlin time is 01:00 UTC after DST switch
+	fakeClock := clock.NewFake(time.Date(2026, time.March, 30, 0, 0, 0, 0, time.UTC))
+
+	handler.SetClock(fakeClock)
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+	newStatus.State = umclient.StateUpdated
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus, nil, nil)
+
+	// Apply is deferred till scheduled time
+
+	newStatus.Error = "apply deferred: scheduled at 2026-03-30T03:00:00+02:00"
+	order = nil
+
+	testOperation(t, handler, handler.ApplyUpdate, &newStatus, map[string][]string{"id1": nil}, nil)
+
+	finalStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{
+			ID: "id1", AosVersion: infos[0].AosVersion, VendorVersion: infos[0].VendorVersion,
+			Status: umclient.StatusInstalled,
+		}},
+	}
+
+	testOperation(t, handler, func() {
+		fakeClock.Advance(time.Hour)
+	}, &finalStatus, map[string][]string{"id1": {opApply}}, nil)
+}
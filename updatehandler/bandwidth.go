@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// All image downloads share one bandwidth limiter, so the rate limit caps the total download rate. The limiter is
+// attached to each transfer even if downloads are not limited: bandwidth change is applied to in-flight transfers
+// immediately without aborting them, transfers waiting for bandwidth are rescheduled with the new rate limit. Runtime
+// bandwidth is not persistent, default class is applied after restart. Prefetch and link speed test are not limited
+// by download bandwidth: prefetch has own rate limit.
+//
+// Bandwidth cap is token bucket shared by all downloads: the bucket holds up to cap bytes and is refilled at cap bytes
+// per cap period, so the cap allows a burst of cap bytes and limits long term download volume. The bucket is full on
+// start. Bytes downloaded by each transfer are counted per component and per download session, counters of session
+// are reset when next session is opened. Counters are reported by current status and are not persistent.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// BandwidthControl download bandwidth control. If class is set, rate limit of the configured class is applied,
+// otherwise rate limit is applied as is. Rate limit is in bytes per second, zero rate limit means unlimited download.
+type BandwidthControl struct {
+	Class     string `json:"class,omitempty"`
+	RateLimit uint64 `json:"rateLimit"`
+}
+
+// DownloadCounters bytes downloaded by update downloads since start and in current download session.
+type DownloadCounters struct {
+	Session      string                      `json:"session,omitempty"`
+	SessionBytes uint64                      `json:"sessionBytes"`
+	TotalBytes   uint64                      `json:"totalBytes"`
+	Components   map[string]ComponentCounter `json:"components,omitempty"`
+}
+
+// ComponentCounter bytes downloaded for component since start and in current download session.
+type ComponentCounter struct {
+	SessionBytes uint64 `json:"sessionBytes"`
+	TotalBytes   uint64 `json:"totalBytes"`
+}
+
+type bandwidthLimiter struct {
+	sync.Mutex
+
+	handler    *Handler
+	classes    map[string]uint64
+	active     BandwidthControl
+	next       time.Time
+	changed    chan struct{}
+	capBytes   uint64
+	capPeriod  time.Duration
+	capTokens  float64
+	capUpdated time.Time
+	counters   DownloadCounters
+}
+
+// componentLimiter counts bytes of component download and passes them to the shared limiter.
+type componentLimiter struct {
+	limiter *bandwidthLimiter
+	id      string
+	session string
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// SetDownloadBandwidth changes download bandwidth of running and further downloads.
+func (handler *Handler) SetDownloadBandwidth(control BandwidthControl) (err error) {
+	return handler.bandwidth.set(control)
+}
+
+// GetDownloadBandwidth returns active download bandwidth.
+func (handler *Handler) GetDownloadBandwidth() (control BandwidthControl) {
+	handler.bandwidth.Lock()
+	defer handler.bandwidth.Unlock()
+
+	return handler.bandwidth.active
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkDownloadBandwidth(cfg config.DownloadBandwidth) (err error) {
+	if _, ok := cfg.Classes[cfg.DefaultClass]; cfg.DefaultClass != "" && !ok {
+		return aoserrors.Errorf("default bandwidth class %s not found", cfg.DefaultClass)
+	}
+
+	if cfg.CapPeriod.Duration < 0 || (cfg.CapBytes != 0 && cfg.CapPeriod.Duration == 0) {
+		return aoserrors.New("wrong bandwidth cap period")
+	}
+
+	return nil
+}
+
+func newBandwidthLimiter(handler *Handler, cfg config.DownloadBandwidth) (limiter *bandwidthLimiter, err error) {
+	if err = checkDownloadBandwidth(cfg); err != nil {
+		return nil, err
+	}
+
+	limiter = &bandwidthLimiter{
+		handler: handler, classes: cfg.Classes, changed: make(chan struct{}),
+		capBytes: cfg.CapBytes, capPeriod: cfg.CapPeriod.Duration,
+	}
+
+	if cfg.DefaultClass != "" {
+		limiter.active = BandwidthControl{Class: cfg.DefaultClass, RateLimit: cfg.Classes[cfg.DefaultClass]}
+	}
+
+	return limiter, nil
+}
+
+func (limiter *bandwidthLimiter) set(control BandwidthControl) (err error) {
+	if control.Class != "" {
+		rateLimit, ok := limiter.classes[control.Class]
+		if !ok {
+			return aoserrors.Errorf("bandwidth class %s not found", control.Class)
+		}
+
+		control.RateLimit = rateLimit
+	}
+
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	log.WithFields(log.Fields{
+		"class": control.Class, "rateLimit": control.RateLimit,
+	}).Info("Set download bandwidth")
+
+	limiter.active = control
+	limiter.next = time.Time{}
+
+	// Wake up transfers waiting for bandwidth
+	close(limiter.changed)
+	limiter.changed = make(chan struct{})
+
+	return nil
+}
+
+// forComponent returns limiter which counts downloaded bytes of component in download session.
+func (limiter *bandwidthLimiter) forComponent(id, session string) (counted *componentLimiter) {
+	return &componentLimiter{limiter: limiter, id: id, session: session}
+}
+
+func (limiter *bandwidthLimiter) getCounters() (counters DownloadCounters) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	counters = limiter.counters
+	counters.Components = make(map[string]ComponentCounter, len(limiter.counters.Components))
+
+	for id, counter := range limiter.counters.Components {
+		counters.Components[id] = counter
+	}
+
+	return counters
+}
+
+func (limiter *bandwidthLimiter) count(id, session string, n int) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	if limiter.counters.Session != session {
+		limiter.counters.Session = session
+		limiter.counters.SessionBytes = 0
+
+		for id, counter := range limiter.counters.Components {
+			counter.SessionBytes = 0
+			limiter.counters.Components[id] = counter
+		}
+	}
+
+	if limiter.counters.Components == nil {
+		limiter.counters.Components = make(map[string]ComponentCounter)
+	}
+
+	counter := limiter.counters.Components[id]
+
+	counter.SessionBytes += uint64(n)
+	counter.TotalBytes += uint64(n)
+
+	limiter.counters.Components[id] = counter
+	limiter.counters.SessionBytes += uint64(n)
+	limiter.counters.TotalBytes += uint64(n)
+}
+
+// reserveCap takes n bytes from the cap bucket and returns delay till they are available. Bucket may go into debt,
+// so concurrent transfers are served in order of reservation.
+func (limiter *bandwidthLimiter) reserveCap(n int) (delay time.Duration) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	if limiter.capBytes == 0 {
+		return 0
+	}
+
+	now := limiter.handler.clock.Now()
+
+	if limiter.capUpdated.IsZero() {
+		limiter.capTokens = float64(limiter.capBytes)
+	} else {
+		limiter.capTokens += float64(now.Sub(limiter.capUpdated)) * float64(limiter.capBytes) /
+			float64(limiter.capPeriod)
+	}
+
+	if limiter.capTokens > float64(limiter.capBytes) {
+		limiter.capTokens = float64(limiter.capBytes)
+	}
+
+	limiter.capUpdated = now
+	limiter.capTokens -= float64(n)
+
+	if limiter.capTokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-limiter.capTokens * float64(limiter.capPeriod) / float64(limiter.capBytes))
+}
+
+// WaitN counts downloaded bytes and limits total transfer rate of all downloads.
+func (componentLimiter *componentLimiter) WaitN(ctx context.Context, n int) (err error) {
+	componentLimiter.limiter.count(componentLimiter.id, componentLimiter.session, n)
+
+	return componentLimiter.limiter.WaitN(ctx, n)
+}
+
+// WaitN limits total transfer rate and volume of all downloads.
+func (limiter *bandwidthLimiter) WaitN(ctx context.Context, n int) (err error) {
+	if delay := limiter.reserveCap(n); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return aoserrors.Wrap(ctx.Err())
+
+		case <-limiter.handler.clock.After(delay):
+		}
+	}
+
+	for {
+		limiter.Lock()
+
+		if limiter.active.RateLimit == 0 {
+			limiter.Unlock()
+
+			return nil
+		}
+
+		now := limiter.handler.clock.Now()
+
+		if limiter.next.Before(now) {
+			limiter.next = now
+		}
+
+		limiter.next = limiter.next.Add(time.Duration(uint64(n) * uint64(time.Second) / limiter.active.RateLimit))
+
+		delay, changed := limiter.next.Sub(now), limiter.changed
+
+		limiter.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return aoserrors.Wrap(ctx.Err())
+
+		case <-changed:
+
+		case <-limiter.handler.clock.After(delay):
+			return nil
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDownloadBandwidth(t *testing.T) {
+	content := bytes.Repeat([]byte("bandwidth"), 1<<17)
+	imagePath := path.Join(tmpDir, "bandwidthimage.bin")
+
+	if err := writeImage(imagePath, content, false); err != nil {
+		t.Fatalf("Can't write image: %s", err)
+	}
+
+	imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("Can't create file info: %s", err)
+	}
+
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		http.ServeFile(w, r, imagePath)
+	}))
+	defer server.Close()
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: path.Join(tmpDir, "downloadDir"),
+		DownloadBandwidth: config.DownloadBandwidth{
+			Classes:      map[string]uint64{"trickle": 16 * 1024, "full": 0},
+			DefaultClass: "trickle",
+		},
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	})
+
+	if bandwidth := handler.GetDownloadBandwidth(); bandwidth.Class != "trickle" || bandwidth.RateLimit != 16*1024 {
+		t.Errorf("Wrong download bandwidth: %v", bandwidth)
+	}
+
+	if err = handler.SetDownloadBandwidth(updatehandler.BandwidthControl{Class: "unknown"}); err == nil {
+		t.Error("Error expected")
+	}
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	// Image can't be downloaded within wait timeout at trickle rate, bandwidth change applies to in-flight download
+
+	handler.PrepareUpdate([]umclient.ComponentUpdateInfo{{
+		ID: "id1", AosVersion: 1, URL: server.URL + "/bandwidthimage.bin",
+		Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+	}})
+
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if err = handler.SetDownloadBandwidth(updatehandler.BandwidthControl{Class: "full"}); err != nil {
+		t.Fatalf("Can't set download bandwidth: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Errorf("Wrong download requests count: %d", requests)
+	}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{
+		DownloadBandwidth: config.DownloadBandwidth{DefaultClass: "unknown"},
+	}); len(findings) != 1 {
+		t.Errorf("Wrong findings: %v", findings)
+	}
+}
+
+func TestDownloadCap(t *testing.T) {
+	content := bytes.Repeat([]byte("cap"), 1<<15)
+	imagePath := path.Join(tmpDir, "capimage.bin")
+
+	if err := writeImage(imagePath, content, false); err != nil {
+		t.Fatalf("Can't write image: %s", err)
+	}
+
+	imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("Can't create file info: %s", err)
+	}
+
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		http.ServeFile(w, r, imagePath)
+	}))
+	defer server.Close()
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: path.Join(tmpDir, "downloadDir"),
+		DownloadBandwidth: config.DownloadBandwidth{
+			CapBytes: 16 * 1024, CapPeriod: aostypes.Duration{Duration: time.Hour},
+		},
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	})
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	// Download is held by bandwidth cap till the bucket is refilled
+
+	handler.PrepareUpdate([]umclient.ComponentUpdateInfo{{
+		ID: "id1", AosVersion: 1, URL: server.URL + "/capimage.bin",
+		Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+	}})
+
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	counters := handler.GetCurrentStatus().DownloadCounters
+
+	if counter := counters.Components["id1"]; counter.SessionBytes == 0 ||
+		counter.SessionBytes >= uint64(len(content)) {
+		t.Errorf("Wrong download counter: %v", counter)
+	}
+
+	stateChannel := make(chan error, 1)
+
+	go func() { stateChannel <- waitForState(handler, umclient.StatePrepared) }()
+
+	for waiting := true; waiting; {
+		select {
+		case err = <-stateChannel:
+			if err != nil {
+				t.Errorf("Wait for state failed: %s", err)
+			}
+
+			waiting = false
+
+		case <-time.After(20 * time.Millisecond):
+			fakeClock.Advance(time.Hour)
+		}
+	}
+
+	counters = handler.GetCurrentStatus().DownloadCounters
+
+	if counters.Session == "" || counters.SessionBytes != uint64(len(content)) ||
+		counters.TotalBytes != uint64(len(content)) ||
+		counters.Components["id1"] != (updatehandler.ComponentCounter{
+			SessionBytes: uint64(len(content)), TotalBytes: uint64(len(content)),
+		}) {
+		t.Errorf("Wrong download counters: %v", counters)
+	}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{
+		DownloadBandwidth: config.DownloadBandwidth{CapBytes: 1024},
+	}); len(findings) != 1 {
+		t.Errorf("Wrong findings: %v", findings)
+	}
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Update blockers are checked before component update and apply. While any blocker is active, the operation is
+// deferred and status with the defer reason is sent. Blocker which can't be checked is treated as active.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	defaultBlockersPollInterval = 10 * time.Second
+	blockerCheckTimeout         = 10 * time.Second
+)
+
+const unitActiveState = "active"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// updateBlocker returns reason if update should be deferred.
+type updateBlocker func() (reason string, err error)
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newUpdateBlockers(cfg config.UpdateBlockers) (blockers []updateBlocker) {
+	if len(cfg.Units) != 0 {
+		blockers = append(blockers, newUnitsBlocker(cfg.Units))
+	}
+
+	for _, flag := range cfg.DBusFlags {
+		blockers = append(blockers, newDBusFlagBlocker(flag))
+	}
+
+	return blockers
+}
+
+func checkUpdateBlockers(cfg config.UpdateBlockers) (err error) {
+	for _, flag := range cfg.DBusFlags {
+		if flag.Destination == "" || !dbus.ObjectPath(flag.Path).IsValid() {
+			return aoserrors.Errorf("wrong D-Bus flag %s destination or path", flag.Property)
+		}
+
+		if strings.LastIndex(flag.Property, ".") <= 0 {
+			return aoserrors.Errorf("D-Bus flag property %s should be prefixed by interface", flag.Property)
+		}
+	}
+
+	return nil
+}
+
+func newUnitsBlocker(units []string) (blocker updateBlocker) {
+	return func() (reason string, err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), blockerCheckTimeout)
+		defer cancel()
+
+		conn, err := systemd.NewSystemConnectionContext(ctx)
+		if err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+		defer conn.Close()
+
+		statuses, err := conn.ListUnitsByNamesContext(ctx, units)
+		if err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+
+		for _, status := range statuses {
+			if status.ActiveState == unitActiveState {
+				return fmt.Sprintf("unit %s is active", status.Name), nil
+			}
+		}
+
+		return "", nil
+	}
+}
+
+func newDBusFlagBlocker(flag config.DBusFlag) (blocker updateBlocker) {
+	return func() (reason string, err error) {
+		conn, err := dbus.ConnectSystemBus()
+		if err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), blockerCheckTimeout)
+		defer cancel()
+
+		separator := strings.LastIndex(flag.Property, ".")
+
+		var value dbus.Variant
+
+		if err = conn.Object(flag.Destination, dbus.ObjectPath(flag.Path)).CallWithContext(ctx,
+			"org.freedesktop.DBus.Properties.Get", 0, flag.Property[:separator], flag.Property[separator+1:],
+		).Store(&value); err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+
+		active, ok := value.Value().(bool)
+		if !ok {
+			return "", aoserrors.Errorf("D-Bus flag %s is not boolean", flag.Property)
+		}
+
+		if active {
+			return fmt.Sprintf("D-Bus flag %s is set", flag.Property), nil
+		}
+
+		return "", nil
+	}
+}
+
+// waitUpdateBlockers waits till all update blockers are cleared. It should be called without handler lock.
+func (handler *Handler) waitUpdateBlockers(operation string) {
+	deferred := false
+
+	for {
+		reason := handler.getBlockReason()
+		if reason == "" {
+			break
+		}
+
+		handler.Lock()
+
+		if deferMsg := fmt.Sprintf("%s deferred: %s", operation, reason); handler.state.Error != deferMsg {
+			log.WithField("reason", reason).Warnf("Component %s deferred", operation)
+
+			handler.state.Error = deferMsg
+			handler.sendStatus()
+		}
+
+		deferred = true
+
+		handler.Unlock()
+
+		select {
+		case <-handler.clock.After(handler.blockersPollInterval):
+
+		case <-handler.operationContext().Done():
+			log.Warnf("Component %s waiting is stopped", operation)
+
+			return
+		}
+	}
+
+	if deferred {
+		log.Infof("Update blockers cleared, continue %s", operation)
+	}
+}
+
+func (handler *Handler) getBlockReason() (reason string) {
+	for _, blocker := range handler.blockers {
+		reason, err := blocker()
+		if err != nil {
+			log.Errorf("Can't check update blocker: %v", err)
+
+			return "can't check update blocker: " + err.Error()
+		}
+
+		if reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
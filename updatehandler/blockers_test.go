@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestUpdateBlockers(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	var (
+		blockMutex sync.Mutex
+		blocked    = true
+	)
+
+	handler.SetUpdateBlocker(func() (reason string, err error) {
+		blockMutex.Lock()
+		defer blockMutex.Unlock()
+
+		if blocked {
+			return "diagnostic session is active", nil
+		}
+
+		return "", nil
+	})
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+	// Update is deferred while blocker is active
+
+	newStatus.Error = "update deferred: diagnostic session is active"
+	order = nil
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus, map[string][]string{"id1": nil}, nil)
+
+	blockMutex.Lock()
+	blocked = false
+	blockMutex.Unlock()
+
+	newStatus.State = umclient.StateUpdated
+	newStatus.Error = ""
+
+	testOperation(t, handler, func() {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(time.Minute)
+	}, &newStatus, map[string][]string{"id1": {opUpdate}}, nil)
+}
@@ -0,0 +1,283 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Bundle is a tar archive, optionally gzip compressed, with images of several components and metadata.json manifest
+// which maps component IDs to images in the archive, so the cloud can publish single artifact per board. Components
+// of the bundle are sent in update request with the same bundle URL, hashes and size, and bundle update annotation.
+// The bundle is downloaded once per session and unpacked into the download session when the first of its components
+// is prepared, then each component gets its image by manifest entry. Entry image is checked against optional SHA-256
+// digest of the manifest, other checks (decryption, detached signature and image format) are applied to entry image.
+// Target space is not checked for bundle components as image size is known only after the bundle is unpacked.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	bundleManifestName = "metadata.json"
+	bundleDirPrefix    = "bundle-"
+	bundleTmpSuffix    = ".tmp"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type bundleManifest struct {
+	Entries []bundleEntry `json:"entries"`
+}
+
+type bundleEntry struct {
+	ID            string `json:"id"`
+	Path          string `json:"path"`
+	VendorVersion string `json:"vendorVersion,omitempty"`
+	Sha256        string `json:"sha256,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func isBundle(updateInfo *umclient.ComponentUpdateInfo) (bundle bool) {
+	return getUpdateAnnotations(updateInfo.Annotations).Bundle
+}
+
+// getBundleImage unpacks bundle if it is not unpacked yet and returns image of the component.
+func (handler *Handler) getBundleImage(
+	updateInfo *umclient.ComponentUpdateInfo, bundlePath string,
+) (filePath string, err error) {
+	if handler.downloadDir == "" {
+		return "", aoserrors.New("download dir should be configured for bundle")
+	}
+
+	handler.bundleMutex.Lock()
+	defer handler.bundleMutex.Unlock()
+
+	bundleDir := filepath.Join(handler.sessionDir(), bundleDirPrefix+downloadFileName(updateInfo.URL))
+
+	manifest, err := readBundleManifest(bundleDir)
+	if err != nil {
+		log.WithFields(log.Fields{"id": updateInfo.ID, "url": updateInfo.URL}).Debug("Unpack bundle")
+
+		if err = unpackBundle(bundlePath, bundleDir); err != nil {
+			return "", err
+		}
+
+		if manifest, err = readBundleManifest(bundleDir); err != nil {
+			return "", err
+		}
+	}
+
+	for _, entry := range manifest.Entries {
+		if entry.ID != updateInfo.ID {
+			continue
+		}
+
+		if entry.VendorVersion != "" && updateInfo.VendorVersion != "" &&
+			!handler.versionsEqual(updateInfo.ID, entry.VendorVersion, updateInfo.VendorVersion) {
+			return "", aoserrors.Errorf("bundle entry version %s mismatches requested version %s",
+				entry.VendorVersion, updateInfo.VendorVersion)
+		}
+
+		if filePath, err = bundleEntryPath(bundleDir, entry.Path); err != nil {
+			return "", err
+		}
+
+		if err = checkBundleEntry(filePath, entry.Sha256); err != nil {
+			return "", err
+		}
+
+		log.WithFields(log.Fields{"id": updateInfo.ID, "path": entry.Path}).Debug("Use bundle image")
+
+		return filePath, nil
+	}
+
+	return "", aoserrors.Errorf("component %s not found in bundle", updateInfo.ID)
+}
+
+func readBundleManifest(bundleDir string) (manifest bundleManifest, err error) {
+	data, err := os.ReadFile(filepath.Join(bundleDir, bundleManifestName))
+	if err != nil {
+		return manifest, aoserrors.Wrap(err)
+	}
+
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return manifest, aoserrors.Errorf("can't parse bundle manifest: %v", err)
+	}
+
+	return manifest, nil
+}
+
+// unpackBundle unpacks bundle into temporary dir which is renamed to bundle dir once the bundle is unpacked, so
+// interrupted unpack is restarted from scratch.
+func unpackBundle(bundlePath, bundleDir string) (err error) {
+	tmpDir := bundleDir + bundleTmpSuffix
+
+	for _, dir := range []string{bundleDir, tmpDir} {
+		if err = os.RemoveAll(dir); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			os.RemoveAll(tmpDir)
+		}
+	}()
+
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	var archiveReader io.Reader = reader
+
+	if magic, _ := reader.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+		defer gzipReader.Close()
+
+		archiveReader = gzipReader
+	}
+
+	if err = os.MkdirAll(tmpDir, 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	tarReader := tar.NewReader(archiveReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return aoserrors.Errorf("can't read bundle: %v", err)
+		}
+
+		if err = unpackBundleEntry(tarReader, header, tmpDir); err != nil {
+			return err
+		}
+	}
+
+	return aoserrors.Wrap(os.Rename(tmpDir, bundleDir))
+}
+
+func unpackBundleEntry(reader io.Reader, header *tar.Header, dir string) (err error) {
+	// Archive root entry
+	if header.Typeflag == tar.TypeDir && filepath.Clean(header.Name) == "." {
+		return nil
+	}
+
+	entryPath, err := bundleEntryPath(dir, header.Name)
+	if err != nil {
+		return err
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return aoserrors.Wrap(os.MkdirAll(entryPath, 0o755))
+
+	case tar.TypeReg:
+		if err = os.MkdirAll(filepath.Dir(entryPath), 0o755); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		file, err := os.OpenFile(entryPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+		defer file.Close()
+
+		if _, err = io.Copy(file, reader); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		return aoserrors.Wrap(file.Sync())
+
+	default:
+		return aoserrors.Errorf("unsupported bundle entry %s type %c", header.Name, header.Typeflag)
+	}
+}
+
+// bundleEntryPath returns path of bundle entry. Entry should not point outside of bundle dir.
+func bundleEntryPath(dir, name string) (entryPath string, err error) {
+	entryPath = filepath.Join(dir, filepath.FromSlash(name))
+
+	if entryPath == dir || !strings.HasPrefix(entryPath, dir+string(filepath.Separator)) {
+		return "", aoserrors.Errorf("wrong bundle entry path %s", name)
+	}
+
+	return entryPath, nil
+}
+
+func checkBundleEntry(filePath, sha256Hex string) (err error) {
+	if sha256Hex == "" {
+		return nil
+	}
+
+	expected, err := hex.DecodeString(sha256Hex)
+	if err != nil {
+		return aoserrors.Errorf("wrong bundle entry SHA-256 digest: %v", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if _, err = io.Copy(hash, file); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if !bytes.Equal(hash.Sum(nil), expected) {
+		return aoserrors.New("bundle entry SHA-256 digest mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestBundle(t *testing.T) {
+	order = nil
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+			{ID: "id3", Plugin: "testmodule"},
+		},
+	})
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+			{ID: "id3", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	rootfsHash := sha256.Sum256([]byte("rootfs"))
+
+	manifest, err := json.Marshal(map[string]interface{}{
+		"entries": []map[string]string{
+			{"id": "id1", "path": "images/rootfs.img", "sha256": hex.EncodeToString(rootfsHash[:])},
+			{"id": "id2", "path": "boot.img"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Can't marshal bundle manifest: %s", err)
+	}
+
+	var bundle bytes.Buffer
+
+	tarWriter := tar.NewWriter(&bundle)
+
+	for _, entry := range []struct {
+		name    string
+		content []byte
+	}{
+		{name: "./"},
+		{name: "metadata.json", content: manifest},
+		{name: "images/"},
+		{name: "images/rootfs.img", content: []byte("rootfs")},
+		{name: "boot.img", content: []byte("boot")},
+	} {
+		header := &tar.Header{Name: entry.name, Mode: 0o600, Size: int64(len(entry.content))}
+
+		if strings.HasSuffix(entry.name, "/") {
+			header.Typeflag, header.Mode = tar.TypeDir, 0o755
+		}
+
+		if err = tarWriter.WriteHeader(header); err != nil {
+			t.Fatalf("Can't write tar header: %s", err)
+		}
+
+		if _, err = tarWriter.Write(entry.content); err != nil {
+			t.Fatalf("Can't write tar content: %s", err)
+		}
+	}
+
+	if err = tarWriter.Close(); err != nil {
+		t.Fatalf("Can't close tar writer: %s", err)
+	}
+
+	bundlePath := path.Join(tmpDir, "bundle.tar.gz")
+
+	if err = writeImage(bundlePath, bundle.Bytes(), true); err != nil {
+		t.Fatalf("Can't write bundle: %s", err)
+	}
+
+	bundleInfo, err := image.CreateFileInfo(context.Background(), bundlePath)
+	if err != nil {
+		t.Fatalf("Can't create bundle info: %s", err)
+	}
+
+	bundleUpdateInfo := func(id string) umclient.ComponentUpdateInfo {
+		return umclient.ComponentUpdateInfo{
+			ID: id, AosVersion: 1, URL: "file://" + bundlePath, Sha256: bundleInfo.Sha256,
+			Sha512: bundleInfo.Sha512, Size: bundleInfo.Size, Annotations: json.RawMessage(`{"bundle":true}`),
+		}
+	}
+
+	// Each bundle component gets own image
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(append([]umclient.ComponentStatusInfo{}, currentStatus.Components...),
+		umclient.ComponentStatusInfo{ID: "id1", AosVersion: 1, Status: umclient.StatusInstalling},
+		umclient.ComponentStatusInfo{ID: "id2", AosVersion: 1, Status: umclient.StatusInstalling})
+
+	testOperation(t, handler, func() {
+		handler.PrepareUpdate([]umclient.ComponentUpdateInfo{bundleUpdateInfo("id1"), bundleUpdateInfo("id2")})
+	}, &newStatus, map[string][]string{"id1": {opInit, opPrepare}, "id2": {opInit, opPrepare}, "id3": {opInit}}, nil)
+
+	for id, expectedContent := range map[string]string{"id1": "rootfs", "id2": "boot"} {
+		content, err := os.ReadFile(components[id].imagePath)
+		if err != nil {
+			t.Fatalf("Can't read component image: %s", err)
+		}
+
+		if string(content) != expectedContent {
+			t.Errorf("Wrong image content of %s: %s", id, content)
+		}
+	}
+
+	testOperation(t, handler, handler.RevertUpdate, &currentStatus, nil, nil)
+
+	// Component which is not in bundle manifest fails
+
+	newStatus.State = umclient.StateFailed
+	newStatus.Error = "component id3 not found in bundle"
+	newStatus.Components = append(append([]umclient.ComponentStatusInfo{}, currentStatus.Components...),
+		umclient.ComponentStatusInfo{ID: "id1", AosVersion: 1, Status: umclient.StatusInstalling},
+		umclient.ComponentStatusInfo{
+			ID: "id3", AosVersion: 1, Status: umclient.StatusError, Error: "component id3 not found in bundle",
+		})
+
+	testOperation(t, handler, func() {
+		handler.PrepareUpdate([]umclient.ComponentUpdateInfo{bundleUpdateInfo("id1"), bundleUpdateInfo("id3")})
+	}, &newStatus, nil, nil)
+
+	testOperation(t, handler, handler.RevertUpdate, nil, nil, nil)
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Staged update is used for devices with many module instances: if update contains canary components together with
+// other ones, canary components are updated first and canary checks are run while the update is not applied yet.
+// Other components are updated only if canary components are updated and all checks pass. Otherwise the update is
+// failed: all session components are reported with canary error and the canary components are reverted together with
+// other ones by the revert of the failed update. Canary component can't depend on other components as they are
+// updated after it.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const canaryFailedMsg = "canary stage failed"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type canaryApply struct {
+	components []string
+	checks     []healthCheck
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// newCanaryApply returns nil if canary components are not configured.
+func newCanaryApply(cfg config.CanaryApply, modulesCfg []config.ModuleConfig) (canary *canaryApply, err error) {
+	if len(cfg.Components) == 0 {
+		if len(cfg.Checks) != 0 {
+			return nil, aoserrors.New("canary checks are configured without canary components")
+		}
+
+		return nil, nil
+	}
+
+	modules := make(map[string]config.ModuleConfig)
+
+	for _, moduleCfg := range modulesCfg {
+		if !moduleCfg.Disabled {
+			modules[moduleCfg.ID] = moduleCfg
+		}
+	}
+
+	for _, id := range cfg.Components {
+		moduleCfg, ok := modules[id]
+		if !ok {
+			return nil, aoserrors.Errorf("canary component %s not found", id)
+		}
+
+		for _, dependency := range moduleCfg.Dependencies {
+			if !containsString(cfg.Components, dependency) {
+				return nil, aoserrors.Errorf("canary component %s depends on non-canary component %s", id, dependency)
+			}
+		}
+	}
+
+	canary = &canaryApply{components: cfg.Components}
+
+	for _, checkCfg := range cfg.Checks {
+		check, err := newHealthCheck(checkCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		canary.checks = append(canary.checks, check)
+	}
+
+	return canary, nil
+}
+
+// updateComponents updates update components, in stages if canary components are configured. It is called under
+// handler lock.
+func (handler *Handler) updateComponents(operation componentOperation, stopOnError bool) (err error) {
+	if handler.canary == nil {
+		return handler.componentOperation(eventUpdate, operation, stopOnError)
+	}
+
+	var canaryStatuses, otherStatuses []*umclient.ComponentStatusInfo
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		// Components excluded from best effort session are already reverted
+		if handler.isExcluded(id) {
+			continue
+		}
+
+		if containsString(handler.canary.components, id) {
+			canaryStatuses = append(canaryStatuses, componentStatus)
+		} else {
+			otherStatuses = append(otherStatuses, componentStatus)
+		}
+	}
+
+	if len(canaryStatuses) == 0 || len(otherStatuses) == 0 {
+		return handler.componentOperation(eventUpdate, operation, stopOnError)
+	}
+
+	log.WithField("count", len(canaryStatuses)).Info("Update canary components")
+
+	canaryErr := handler.statusesOperation(canaryStatuses, eventUpdate, operation, stopOnError)
+	if canaryErr == nil {
+		canaryErr = handler.runHealthChecks(handler.canary.checks)
+	}
+
+	if canaryErr != nil {
+		if handler.checkStopped() != nil {
+			return aoserrors.Wrap(canaryErr)
+		}
+
+		log.Warnf("Canary stage failed: %v", canaryErr)
+
+		handler.failCanaryStage(append(canaryStatuses, otherStatuses...), canaryErr)
+
+		return aoserrors.Errorf("%s: %v", canaryFailedMsg, canaryErr)
+	}
+
+	log.WithField("count", len(otherStatuses)).Info("Update components after canary stage")
+
+	return handler.statusesOperation(otherStatuses, eventUpdate, operation, stopOnError)
+}
+
+// failCanaryStage reports session components as failed, so best effort session doesn't continue without them.
+// Component which failed its own operation keeps its error.
+func (handler *Handler) failCanaryStage(componentStatuses []*umclient.ComponentStatusInfo, canaryErr error) {
+	for _, componentStatus := range componentStatuses {
+		if componentStatus.Status == umclient.StatusError {
+			continue
+		}
+
+		componentStatus.Status = umclient.StatusError
+		componentStatus.Error = canaryFailedMsg + ": " + canaryErr.Error()
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestCanaryApply(t *testing.T) {
+	for _, testItem := range []struct {
+		checkCommand string
+		canaryErr    error
+		updateErr    string
+		expectedOps  map[string][]string
+	}{
+		{
+			checkCommand: "true",
+			expectedOps:  map[string][]string{"id1": {opUpdate}, "id2": {opUpdate}, "id3": {opUpdate}},
+		},
+		{
+			checkCommand: "false",
+			updateErr:    "canary stage failed: health check canary failed",
+			expectedOps:  map[string][]string{"id1": {opUpdate}, "id2": nil, "id3": nil},
+		},
+		{
+			checkCommand: "true",
+			canaryErr:    aoserrors.New("update error"),
+			updateErr:    "canary stage failed",
+			expectedOps:  map[string][]string{"id1": {opUpdate}, "id2": nil, "id3": nil},
+		},
+	} {
+		handler := newTestHandler(t, &config.Config{
+			CanaryApply: config.CanaryApply{
+				Components: []string{"id1"},
+				Checks:     []config.HealthCheck{{Name: "canary", Command: testItem.checkCommand}},
+			},
+			UpdateModules: []config.ModuleConfig{
+				{ID: "id1", Plugin: "testmodule"},
+				{ID: "id2", Plugin: "testmodule"},
+				{ID: "id3", Plugin: "testmodule"},
+			},
+		})
+
+		currentStatus := umclient.Status{
+			State: umclient.StateIdle,
+			Components: []umclient.ComponentStatusInfo{
+				{ID: "id1", Status: umclient.StatusInstalled},
+				{ID: "id2", Status: umclient.StatusInstalled},
+				{ID: "id3", Status: umclient.StatusInstalled},
+			},
+		}
+
+		testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+		infos, err := createUpdateInfos(currentStatus.Components, "")
+		if err != nil {
+			t.Fatalf("Can't create update infos: %s", err)
+		}
+
+		handler.PrepareUpdate(infos)
+
+		if err = waitForState(handler, umclient.StatePrepared); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		order = nil
+		components["id1"].status = testItem.canaryErr
+
+		handler.StartUpdate()
+
+		select {
+		case status := <-handler.StatusChannel():
+			if testItem.updateErr == "" {
+				if status.State != umclient.StateUpdated || status.Error != "" {
+					t.Errorf("Wrong status: %s, error: %s", status.State, status.Error)
+				}
+
+				break
+			}
+
+			if status.State != umclient.StateFailed || !strings.HasPrefix(status.Error, testItem.updateErr) {
+				t.Errorf("Wrong status: %s, error: %s", status.State, status.Error)
+			}
+
+			// All session components are failed, so best effort session doesn't continue without canary
+			for _, componentStatus := range status.Components {
+				if componentStatus.AosVersion != 0 && componentStatus.Status != umclient.StatusError {
+					t.Errorf("Component %s should be failed: %s", componentStatus.ID, componentStatus.Status)
+				}
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait status timeout")
+		}
+
+		if err = checkComponentOps(testItem.expectedOps); err != nil {
+			t.Errorf("Component operation error: %s", err)
+		}
+
+		if order[0].id != "id1" {
+			t.Errorf("Canary component is not updated first: %s", order[0].id)
+		}
+
+		// Canary component is reverted together with other components as the update is not applied
+
+		if testItem.updateErr != "" {
+			order = nil
+
+			handler.RevertUpdate()
+
+			if err = waitForState(handler, umclient.StateIdle); err != nil {
+				t.Errorf("Wait for state failed: %s", err)
+			}
+
+			if err = checkComponentOps(map[string][]string{
+				"id1": {opRevert}, "id2": {opRevert}, "id3": {opRevert},
+			}); err != nil {
+				t.Errorf("Component operation error: %s", err)
+			}
+		}
+
+		handler.Close(context.Background())
+	}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{
+		CanaryApply: config.CanaryApply{Components: []string{"id2"}},
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule", Dependencies: []string{"id1"}},
+		},
+	}); len(findings) != 1 || !strings.Contains(findings[0].Message, "depends on non-canary component id1") {
+		t.Errorf("Wrong findings: %v", findings)
+	}
+}
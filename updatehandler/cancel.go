@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Prepare and update transitions run with own context derived from the emergency stop context. Cancel is requested
+// without handler lock as running transition holds it: it cancels downloads, blocker waiting and injected delays
+// at once and next component operations fail with cancel error. Module operations get this context as well: running
+// operation is interrupted if the module supports it, legacy modules are not interrupted once started. Once the
+// transition is finished, failed or not, the update is reverted: components which were prepared or updated are
+// reverted and the handler returns to idle state.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const updateCanceledMsg = "update canceled"
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// CancelUpdate aborts running prepare or update and reverts the update.
+func (handler *Handler) CancelUpdate() (err error) {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	if handler.opCancel == nil {
+		return aoserrors.New("no prepare or update in progress")
+	}
+
+	log.Warn("Cancel update")
+
+	handler.canceled = true
+	handler.opCancel()
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func isCancelableEvent(event string) (cancelable bool) {
+	return event == eventPrepare || event == eventUpdate
+}
+
+func (handler *Handler) startCancelableOperation() {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	handler.opCtx, handler.opCancel = context.WithCancel(handler.stopCtx)
+	handler.canceled = false
+}
+
+// finishCancelableOperation returns true if the operation was canceled.
+func (handler *Handler) finishCancelableOperation() (canceled bool) {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	if handler.opCancel != nil {
+		handler.opCancel()
+	}
+
+	canceled = handler.canceled
+
+	handler.opCtx, handler.opCancel, handler.canceled = nil, nil, false
+
+	return canceled
+}
+
+// revertCanceled reverts canceled update once cancelable transition is finished.
+func (handler *Handler) revertCanceled() {
+	if !handler.finishCancelableOperation() {
+		return
+	}
+
+	log.Info("Revert canceled update")
+
+	if err := handler.sendEvent(eventRevert); err != nil {
+		log.Errorf("Can't revert canceled update: %s", aoserrors.Wrap(err))
+	}
+}
+
+func (handler *Handler) isCanceled() (canceled bool) {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	return handler.opCancel != nil && handler.canceled
+}
+
+// operationContext returns context which is canceled by update cancel or emergency stop.
+func (handler *Handler) operationContext() (ctx context.Context) {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	if handler.opCtx != nil {
+		return handler.opCtx
+	}
+
+	return handler.stopCtx
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestCancelUpdate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   path.Join(tmpDir, "downloadDir"),
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}, {ID: "id2", Plugin: "testmodule"}},
+	})
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	if err := handler.CancelUpdate(); err == nil {
+		t.Error("Error expected if no update in progress")
+	}
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Cancel hanging download
+
+	hangingInfos := append([]umclient.ComponentUpdateInfo{}, infos...)
+	hangingInfos[1].URL = server.URL + "/image.bin"
+
+	order = nil
+
+	handler.PrepareUpdate(hangingInfos)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err = handler.CancelUpdate(); err != nil {
+		t.Errorf("Can't cancel update: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{
+		"id1": {opPrepare, opRevert}, "id2": {opRevert},
+	}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	// Cancel delayed update stage
+
+	components["id1"].updateDelay = time.Minute
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	order = nil
+
+	handler.StartUpdate()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err = handler.CancelUpdate(); err != nil {
+		t.Errorf("Can't cancel update: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{"id1": {opRevert}, "id2": {opUpdate, opRevert}}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+}
+
+func TestCancelModuleOperation(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1", waitCancel: true}, "id2": {id: "id2"}}
+
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}, {ID: "id2", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	order = nil
+
+	handler.StartUpdate()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Update of id1 blocks till operation context is canceled
+
+	if err = handler.CancelUpdate(); err != nil {
+		t.Errorf("Can't cancel update: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{
+		"id1": {opUpdate, opRevert}, "id2": {opUpdate, opRevert},
+	}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+}
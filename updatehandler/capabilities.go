@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Component capabilities are requested together with vendor version as they may change after the component update.
+// Capabilities declared by module are aggregated with component config: configured image formats restrict formats
+// accepted by module. Module which doesn't declare capabilities is reported without delta support and as one which
+// requires reboot, the same way update diff expects it. Capabilities are reported in status to let the cloud tailor
+// update artifacts per device.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// CapabilitiesProvider optional interface which can be implemented by update module to declare its update
+// capabilities.
+type CapabilitiesProvider interface {
+	// Capabilities returns module update capabilities
+	Capabilities() (capabilities umclient.ComponentCapabilities, err error)
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetCapabilities returns update capabilities of components by component ID.
+func (handler *Handler) GetCapabilities() (capabilities map[string]umclient.ComponentCapabilities) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	return handler.getCapabilities()
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// getModuleCapabilities returns nil if module doesn't declare capabilities or can't get them.
+func getModuleCapabilities(id string, module UpdateModule) (capabilities *umclient.ComponentCapabilities) {
+	provider, ok := baseModule(module).(CapabilitiesProvider)
+	if !ok {
+		return nil
+	}
+
+	moduleCapabilities, err := provider.Capabilities()
+	if err != nil {
+		log.WithField("id", id).Errorf("Can't get component capabilities: %s", aoserrors.Wrap(err))
+
+		return nil
+	}
+
+	return &moduleCapabilities
+}
+
+// setCapabilities stores capabilities declared by module, nil capabilities means module doesn't declare them.
+func (handler *Handler) setCapabilities(id string, capabilities *umclient.ComponentCapabilities) {
+	if handler.capabilities == nil {
+		handler.capabilities = make(map[string]*umclient.ComponentCapabilities)
+	}
+
+	handler.capabilities[id] = capabilities
+}
+
+// getCapabilities returns capabilities of configured components whose versions are refreshed.
+func (handler *Handler) getCapabilities() (capabilities map[string]umclient.ComponentCapabilities) {
+	capabilities = make(map[string]umclient.ComponentCapabilities)
+
+	for id, moduleCapabilities := range handler.capabilities {
+		component, ok := handler.components[id]
+		if !ok {
+			continue
+		}
+
+		componentCapabilities := umclient.ComponentCapabilities{RebootRequired: true}
+
+		if moduleCapabilities != nil {
+			componentCapabilities = *moduleCapabilities
+		}
+
+		if len(component.imageFormats) != 0 {
+			componentCapabilities.ImageFormats = restrictImageFormats(
+				componentCapabilities.ImageFormats, component.imageFormats)
+		} else {
+			componentCapabilities.ImageFormats = append([]string(nil), componentCapabilities.ImageFormats...)
+		}
+
+		capabilities[id] = componentCapabilities
+	}
+
+	return capabilities
+}
+
+// restrictImageFormats returns module formats allowed by config or configured formats if module doesn't declare any.
+func restrictImageFormats(moduleFormats, configFormats []string) (formats []string) {
+	if len(moduleFormats) == 0 {
+		return append([]string(nil), configFormats...)
+	}
+
+	formats = make([]string, 0, len(moduleFormats))
+
+	for _, format := range moduleFormats {
+		if containsString(configFormats, format) {
+			formats = append(formats, format)
+		}
+	}
+
+	return formats
+}
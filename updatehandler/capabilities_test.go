@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestCapabilities(t *testing.T) {
+	components = map[string]*testModule{
+		"id1": {id: "id1", capabilities: umclient.ComponentCapabilities{
+			Delta: true, RebootRequired: true, MaxImageSize: 1024, ImageFormats: []string{"ext4", "gzip"},
+		}},
+		"id2": {id: "id2"},
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", ImageFormats: []string{"gzip", "tar"}},
+			{ID: "id2", Plugin: "testmodule", ImageFormats: []string{"ext4"}},
+		},
+	}, withModules(components))
+
+	expectedCapabilities := map[string]umclient.ComponentCapabilities{
+		"id1": {Delta: true, RebootRequired: true, MaxImageSize: 1024, ImageFormats: []string{"gzip"}},
+		"id2": {ImageFormats: []string{"ext4"}},
+	}
+
+	if capabilities := handler.GetCapabilities(); !reflect.DeepEqual(capabilities, expectedCapabilities) {
+		t.Errorf("Wrong component capabilities: %v", capabilities)
+	}
+
+	handler.Registered()
+
+	select {
+	case status := <-handler.StatusChannel():
+		if !reflect.DeepEqual(status.Capabilities, expectedCapabilities) {
+			t.Errorf("Wrong status capabilities: %v", status.Capabilities)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait status timeout")
+	}
+}
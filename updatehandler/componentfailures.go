@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Consecutive update failures are counted per component when update is finished: each errored component gets one
+// more failure, components of applied update which didn't fail are reset. Failures caused by update cancel, emergency
+// stop or shutdown are not counted. Once the failure threshold is reached, the component is quarantined: it is
+// reported with error status and quarantine message, and it is skipped by next updates, so other components can still
+// be updated. The protocol has no dedicated component status, so the cloud should recognize quarantine by the error
+// message. Quarantine is persistent and released by the operator.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const componentQuarantinedMsg = "component quarantined"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type componentFailures struct {
+	Count     int    `json:"count"`
+	LastError string `json:"lastError"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// ReleaseComponent releases component quarantined after repeated update failures.
+func (handler *Handler) ReleaseComponent(id string) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if handler.state.UpdateState != stateIdle {
+		return aoserrors.Errorf("component can't be released in %s state", handler.state.UpdateState)
+	}
+
+	if !handler.isComponentQuarantined(id) {
+		return aoserrors.Errorf("component %s is not quarantined", id)
+	}
+
+	log.WithField("id", id).Info("Release component quarantine")
+
+	delete(handler.state.ComponentFailures, id)
+
+	if err = handler.saveState(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	handler.sendStatus()
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkFailureThreshold(threshold int) (err error) {
+	if threshold < 0 {
+		return aoserrors.Errorf("wrong component failure threshold: %d", threshold)
+	}
+
+	return nil
+}
+
+func (handler *Handler) isComponentQuarantined(id string) (quarantined bool) {
+	failures, ok := handler.state.ComponentFailures[id]
+
+	return ok && handler.failureThreshold != 0 && failures.Count >= handler.failureThreshold
+}
+
+func (handler *Handler) quarantineMessage(id string) (msg string) {
+	failures := handler.state.ComponentFailures[id]
+
+	return fmt.Sprintf("%s after %d consecutive failures, last error: %s", componentQuarantinedMsg, failures.Count,
+		failures.LastError)
+}
+
+// quarantinedStatus returns status of quarantined component skipped by update.
+func (handler *Handler) quarantinedStatus(id string) (status *umclient.ComponentStatusInfo) {
+	status = handler.skippedStatus(id, handler.quarantineMessage(id))
+	status.Status = umclient.StatusError
+
+	return status
+}
+
+// countComponentFailures is called when update is finished.
+func (handler *Handler) countComponentFailures(applied bool) {
+	if handler.failureThreshold == 0 {
+		return
+	}
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		if componentStatus.Status != umclient.StatusError {
+			if _, ok := handler.state.ComponentFailures[id]; ok && applied {
+				log.WithField("id", id).Debug("Reset component failures")
+
+				delete(handler.state.ComponentFailures, id)
+			}
+
+			continue
+		}
+
+		if isInterruptError(componentStatus.Error) {
+			continue
+		}
+
+		if handler.state.ComponentFailures == nil {
+			handler.state.ComponentFailures = make(map[string]*componentFailures)
+		}
+
+		failures, ok := handler.state.ComponentFailures[id]
+		if !ok {
+			failures = &componentFailures{}
+			handler.state.ComponentFailures[id] = failures
+		}
+
+		failures.Count++
+		failures.LastError = componentStatus.Error
+
+		log.WithFields(log.Fields{"id": id, "count": failures.Count}).Debug("Component update failed")
+
+		if failures.Count == handler.failureThreshold {
+			log.WithField("id", id).Warn("Quarantine component after repeated failures")
+		}
+	}
+}
+
+func isInterruptError(errMsg string) (interrupted bool) {
+	for _, msg := range []string{updateCanceledMsg, emergencyStopMsg, closedMsg, context.Canceled.Error()} {
+		if strings.HasPrefix(errMsg, msg) {
+			return true
+		}
+	}
+
+	return false
+}
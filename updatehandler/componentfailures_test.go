@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestComponentQuarantine(t *testing.T) {
+	handler := newTestHandler(t, &config.Config{
+		FailureThreshold: 2,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	})
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Component is quarantined after consecutive failures
+
+	for i := 0; i < 2; i++ {
+		handler.PrepareUpdate(infos)
+
+		if err = waitForState(handler, umclient.StatePrepared); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		components["id1"].status = aoserrors.New("update error")
+
+		handler.StartUpdate()
+
+		if err = waitForState(handler, umclient.StateFailed); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		handler.RevertUpdate()
+
+		if err = waitForState(handler, umclient.StateIdle); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+	}
+
+	if err = handler.AcknowledgeErrors(nil); err != nil {
+		t.Fatalf("Can't acknowledge errors: %s", err)
+	}
+
+	quarantinedStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{
+				ID: "id1", Status: umclient.StatusError,
+				Error: "component quarantined after 2 consecutive failures, last error: update error",
+			},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	if err = waitForStatus(handler, &quarantinedStatus); err != nil {
+		t.Errorf("Wait for status failed: %s", err)
+	}
+
+	// Quarantined component is skipped while others are updated
+
+	order = nil
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateUpdated); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	quarantinedStatus.Components[1].AosVersion = infos[1].AosVersion
+
+	testOperation(t, handler, handler.ApplyUpdate, &quarantinedStatus,
+		map[string][]string{"id1": nil, "id2": {opPrepare, opUpdate, opApply}}, nil)
+
+	// Released component can be updated again
+
+	if err = handler.ReleaseComponent("id2"); err == nil {
+		t.Error("Error expected for not quarantined component")
+	}
+
+	currentStatus.Components[1].AosVersion = infos[1].AosVersion
+
+	testOperation(t, handler, func() {
+		if err := handler.ReleaseComponent("id1"); err != nil {
+			t.Errorf("Can't release component: %s", err)
+		}
+	}, &currentStatus, nil, nil)
+}
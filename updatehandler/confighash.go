@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Hash of effective module config is stored in module storage. If it is changed between runs, e.g. by new image
+// rollout, the component is reported with informational message and module state is invalidated as it may be not
+// meaningful for new config. Module state of component which is being updated is kept to not break the update.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const configHashSuffix = ".confighash"
+
+const configChangedMsg = "module config changed"
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func getConfigHash(moduleCfg config.ModuleConfig) (hash string, err error) {
+	if len(moduleCfg.Params) != 0 {
+		var params bytes.Buffer
+
+		if err = json.Compact(&params, moduleCfg.Params); err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+
+		moduleCfg.Params = params.Bytes()
+	}
+
+	data, err := json.Marshal(moduleCfg)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// checkConfigDrift compares module config with stored one and invalidates module state if config is changed.
+func (handler *Handler) checkConfigDrift(moduleCfg config.ModuleConfig, storage ModuleStorage) (err error) {
+	if storage == nil {
+		return nil
+	}
+
+	hash, err := getConfigHash(moduleCfg)
+	if err != nil {
+		return err
+	}
+
+	storedHash, err := storage.GetModuleState(moduleCfg.ID + configHashSuffix)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if string(storedHash) == hash {
+		return nil
+	}
+
+	if len(storedHash) != 0 {
+		log.WithField("id", moduleCfg.ID).Warn("Module config changed")
+
+		handler.configChanged[moduleCfg.ID] = true
+
+		if _, updating := handler.state.ComponentStatuses[moduleCfg.ID]; updating &&
+			handler.state.UpdateState != stateIdle {
+			log.WithField("id", moduleCfg.ID).Warn("Component is being updated, keep module state")
+		} else if err = storage.SetModuleState(moduleCfg.ID, nil); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	if err = storage.SetModuleState(moduleCfg.ID+configHashSuffix, []byte(hash)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// storeConfigHash stores hash of module config applied without module state invalidation.
+func storeConfigHash(moduleCfg config.ModuleConfig, storage ModuleStorage) (err error) {
+	if storage == nil {
+		return nil
+	}
+
+	hash, err := getConfigHash(moduleCfg)
+	if err != nil {
+		return err
+	}
+
+	return aoserrors.Wrap(storage.SetModuleState(moduleCfg.ID+configHashSuffix, []byte(hash)))
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestConfigDrift(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+	storage := newTestStorage()
+
+	for _, testItem := range []struct {
+		params        string
+		expectedError string
+	}{
+		{params: `{"param": 1}`},
+		{params: `{ "param" : 1 }`},
+		{params: `{"param": 2}`, expectedError: "module config changed"},
+	} {
+		if err := storage.SetModuleState("id1", []byte("state")); err != nil {
+			t.Fatalf("Can't set module state: %s", err)
+		}
+
+		handler := newTestHandler(t, &config.Config{
+			UpdateModules: []config.ModuleConfig{
+				{ID: "id1", Plugin: "testmodule", Params: json.RawMessage(testItem.params)},
+			},
+		}, withStorage(storage), withModules(components))
+
+		status := handler.Inventory()
+
+		handler.Close(context.Background())
+
+		if len(status.Components) != 1 || status.Components[0].Error != testItem.expectedError {
+			t.Errorf("Wrong status: %v", status)
+		}
+
+		// Module state is invalidated on config change
+
+		if state, _ := storage.GetModuleState("id1"); (len(state) == 0) != (testItem.expectedError != "") {
+			t.Errorf("Wrong module state: %s", state)
+		}
+	}
+}
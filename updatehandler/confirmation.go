@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Campaign marks components which require local user confirmation by confirmation update annotation. Components
+// pending confirmation are persisted at prepare and may be confirmed by HMI or CLI any time before the update
+// proceeds. Update waits for all pending confirmations after update blockers are cleared and sends status with the
+// defer reason while waiting. Confirmation timeout and timeout action are configured per component and measured from
+// the moment update started waiting: on timeout the component is either treated as confirmed or the update is
+// canceled and reverted, cancel is the default action. Confirmation never waits indefinitely: component without
+// confirmation config or with zero timeout uses the default timeout, so unattended device doesn't keep the update
+// deferred forever.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Confirmation timeout actions.
+const (
+	ConfirmationTimeoutProceed = "proceed"
+	ConfirmationTimeoutCancel  = "cancel"
+)
+
+// DefaultConfirmationTimeout confirmation timeout used if it is not configured for component.
+const DefaultConfirmationTimeout = 24 * time.Hour
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// Confirm confirms update of component which requires local user confirmation.
+func (handler *Handler) Confirm(id string) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if err = handler.checkStopped(); err != nil {
+		return err
+	}
+
+	if handler.state.UpdateState != statePrepared && handler.state.UpdateState != stateUpdated {
+		return aoserrors.Errorf("confirmation is not allowed in %s state", handler.state.UpdateState)
+	}
+
+	if !containsString(handler.state.PendingConfirmations, id) {
+		return aoserrors.Errorf("component %s doesn't wait for confirmation", id)
+	}
+
+	log.WithField("id", id).Info("Component update confirmed")
+
+	handler.removePendingConfirmation(id)
+
+	if err = handler.saveState(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	select {
+	case handler.confirmChannel <- struct{}{}:
+
+	default:
+	}
+
+	return nil
+}
+
+// GetPendingConfirmations returns IDs of components waiting for local user confirmation.
+func (handler *Handler) GetPendingConfirmations() (ids []string) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	return append([]string(nil), handler.state.PendingConfirmations...)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkConfirmation(confirmation *config.Confirmation) (err error) {
+	if confirmation == nil {
+		return nil
+	}
+
+	if confirmation.Timeout.Duration < 0 {
+		return aoserrors.Errorf("wrong confirmation timeout: %v", confirmation.Timeout.Duration)
+	}
+
+	switch confirmation.TimeoutAction {
+	case "", ConfirmationTimeoutProceed, ConfirmationTimeoutCancel:
+		return nil
+
+	default:
+		return aoserrors.Errorf("unsupported confirmation timeout action: %s", confirmation.TimeoutAction)
+	}
+}
+
+// waitConfirmations waits till all pending confirmations are received or timed out. It should be called without
+// handler lock.
+func (handler *Handler) waitConfirmations() {
+	for {
+		handler.Lock()
+
+		pending, wait, cancel := handler.checkConfirmations()
+		if len(pending) == 0 || cancel {
+			handler.Unlock()
+
+			if cancel {
+				if err := handler.CancelUpdate(); err != nil {
+					log.Errorf("Can't cancel update: %v", err)
+				}
+			}
+
+			return
+		}
+
+		if deferMsg := confirmationDeferMsg(pending); handler.state.Error != deferMsg {
+			log.WithField("components", pending).Warn("Component update deferred")
+
+			handler.state.Error = deferMsg
+			handler.sendStatus()
+		}
+
+		handler.Unlock()
+
+		select {
+		case <-handler.confirmChannel:
+
+		case <-handler.clock.After(wait):
+
+		case <-handler.operationContext().Done():
+			log.Warn("Confirmation waiting is stopped")
+
+			return
+		}
+	}
+}
+
+// checkConfirmations applies timeout actions of timed out confirmations and returns components which still wait for
+// confirmation and time till the next confirmation timeout.
+func (handler *Handler) checkConfirmations() (pending []string, wait time.Duration, cancel bool) {
+	if len(handler.state.PendingConfirmations) == 0 {
+		return nil, 0, false
+	}
+
+	now := handler.clock.Now()
+	changed := false
+
+	if handler.state.ConfirmationStart == nil {
+		handler.state.ConfirmationStart = &now
+		changed = true
+	}
+
+	for _, id := range append([]string(nil), handler.state.PendingConfirmations...) {
+		component, ok := handler.components[id]
+		if !ok || handler.isExcluded(id) {
+			handler.removePendingConfirmation(id)
+			changed = true
+
+			continue
+		}
+
+		timeout, action := confirmationTimeout(component.confirmation)
+
+		if left := handler.state.ConfirmationStart.Add(timeout).Sub(now); left > 0 {
+			pending = append(pending, id)
+
+			if wait == 0 || left < wait {
+				wait = left
+			}
+
+			continue
+		}
+
+		if action != ConfirmationTimeoutProceed {
+			log.WithField("id", id).Warn("Confirmation timeout, cancel update")
+
+			return nil, 0, true
+		}
+
+		log.WithField("id", id).Warn("Confirmation timeout, proceed update")
+
+		handler.removePendingConfirmation(id)
+		changed = true
+	}
+
+	if changed {
+		if err := handler.saveState(); err != nil {
+			log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+		}
+	}
+
+	return pending, wait, false
+}
+
+// confirmationTimeout returns confirmation timeout and timeout action of component applying defaults for not configured
+// values.
+func confirmationTimeout(confirmation *config.Confirmation) (timeout time.Duration, action string) {
+	timeout, action = DefaultConfirmationTimeout, ConfirmationTimeoutCancel
+
+	if confirmation == nil {
+		return timeout, action
+	}
+
+	if confirmation.Timeout.Duration != 0 {
+		timeout = confirmation.Timeout.Duration
+	}
+
+	if confirmation.TimeoutAction != "" {
+		action = confirmation.TimeoutAction
+	}
+
+	return timeout, action
+}
+
+func (handler *Handler) removePendingConfirmation(id string) {
+	pending := make([]string, 0, len(handler.state.PendingConfirmations))
+
+	for _, pendingID := range handler.state.PendingConfirmations {
+		if pendingID != id {
+			pending = append(pending, pendingID)
+		}
+	}
+
+	handler.state.PendingConfirmations = pending
+}
+
+func confirmationDeferMsg(pending []string) (msg string) {
+	return fmt.Sprintf("update deferred: waiting for confirmation of %s", strings.Join(pending, ", "))
+}
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestConfirmation(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}, "id2": {id: "id2"}}
+	storage := newTestStorage()
+
+	moduleConfigs := []config.ModuleConfig{
+		{ID: "id1", Plugin: "testmodule"},
+		{ID: "id2", Plugin: "testmodule", Confirmation: &config.Confirmation{
+			Timeout: aostypes.Duration{Duration: time.Minute}, TimeoutAction: updatehandler.ConfirmationTimeoutProceed,
+		}},
+	}
+
+	if _, err := updatehandler.New(&config.Config{UpdateModules: []config.ModuleConfig{
+		{ID: "id1", Plugin: "testmodule", Confirmation: &config.Confirmation{TimeoutAction: "ignore"}},
+	}}, storage, storage); err == nil {
+		t.Error("Error expected for unsupported confirmation timeout action")
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: cfg.DownloadDir, UpdateModules: moduleConfigs,
+	}, withStorage(storage), withModules(components))
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled}, {ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	if err := handler.Confirm("id1"); err == nil {
+		t.Error("Error expected if no update is prepared")
+	}
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+
+	for i := range infos {
+		infos[i].Annotations = json.RawMessage(`{"confirmation": true}`)
+		newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+			ID: infos[i].ID, AosVersion: infos[i].AosVersion, Status: umclient.StatusInstalling,
+		})
+	}
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+	if pending := handler.GetPendingConfirmations(); !reflect.DeepEqual(pending, []string{"id1", "id2"}) {
+		t.Errorf("Wrong pending confirmations: %v", pending)
+	}
+
+	// Update waits for confirmation of id1 and proceeds on timeout of id2
+
+	order = nil
+	newStatus.Error = "update deferred: waiting for confirmation of id1, id2"
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus, map[string][]string{"id1": nil, "id2": nil}, nil)
+
+	newStatus.Error = "update deferred: waiting for confirmation of id2"
+
+	testOperation(t, handler, func() {
+		if err := handler.Confirm("id1"); err != nil {
+			t.Errorf("Can't confirm update: %s", err)
+		}
+	}, &newStatus, map[string][]string{"id1": nil, "id2": nil}, nil)
+
+	if err = handler.Confirm("id1"); err == nil {
+		t.Error("Error expected for already confirmed component")
+	}
+
+	newStatus.State = umclient.StateUpdated
+	newStatus.Error = ""
+
+	testOperation(t, handler, func() {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(time.Minute)
+	}, &newStatus, map[string][]string{"id1": {opUpdate}, "id2": {opUpdate}}, nil)
+
+	handler.ApplyUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	// Update is canceled on confirmation timeout
+
+	moduleConfigs[1].Confirmation.TimeoutAction = updatehandler.ConfirmationTimeoutCancel
+
+	if err = handler.ReloadConfig(context.Background(), &config.Config{
+		DownloadDir: cfg.DownloadDir, UpdateModules: moduleConfigs,
+	}); err != nil {
+		t.Fatalf("Can't reload config: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	if infos, err = createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id2", AosVersion: 1}}, ""); err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	infos[0].Annotations = json.RawMessage(`{"confirmation": true}`)
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	order = nil
+
+	handler.StartUpdate()
+
+	if err = waitForStatus(handler, nil); err != nil {
+		t.Fatalf("Wait for status failed: %s", err)
+	}
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Minute)
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{"id2": {opRevert}}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	// Update is canceled on default timeout if confirmation is not configured
+
+	moduleConfigs[1].Confirmation = nil
+
+	if err = handler.ReloadConfig(context.Background(), &config.Config{
+		DownloadDir: cfg.DownloadDir, UpdateModules: moduleConfigs,
+	}); err != nil {
+		t.Fatalf("Can't reload config: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	order = nil
+
+	handler.StartUpdate()
+
+	if err = waitForStatus(handler, nil); err != nil {
+		t.Fatalf("Wait for status failed: %s", err)
+	}
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Minute)
+
+	if pending := handler.GetPendingConfirmations(); !reflect.DeepEqual(pending, []string{"id2"}) {
+		t.Errorf("Wrong pending confirmations: %v", pending)
+	}
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(updatehandler.DefaultConfirmationTimeout)
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{"id2": {opRevert}}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"sort"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/versionutils"
+)
+
+// Campaign declares compatibility constraints of a component in its update annotations: e.g. kernel of the new
+// version requires rootfs version in a range. Constraints are kept in handler state from prepare till the update is
+// finished. When apply is requested, the post-update version matrix is built from requested versions of updated
+// components and installed versions of the others, and each constraint is checked with version scheme of the
+// required component. Once a module applies the update, it can't be reverted, so the combination which violates
+// constraints is not applied: components are reverted and the violation is reported for them instead.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type versionConstraint struct {
+	ID         string `json:"id"`
+	MinVersion string `json:"minVersion,omitempty"`
+	MaxVersion string `json:"maxVersion,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkConstraintAnnotations(id string, constraints []versionConstraint) (err error) {
+	for _, constraint := range constraints {
+		if constraint.ID == "" {
+			return aoserrors.Errorf("component %s: constraint component ID is empty", id)
+		}
+
+		if constraint.MinVersion == "" && constraint.MaxVersion == "" {
+			return aoserrors.Errorf("component %s: constraint of %s has no version range", id, constraint.ID)
+		}
+	}
+
+	return nil
+}
+
+// checkConstraints checks post-update version matrix. It is called under handler lock.
+func (handler *Handler) checkConstraints() (err error) {
+	if len(handler.state.Constraints) == 0 {
+		return nil
+	}
+
+	versions := make(map[string]string)
+
+	for id, componentStatus := range handler.componentStatuses {
+		versions[id] = componentStatus.VendorVersion
+	}
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		if componentStatus.VendorVersion != "" {
+			versions[id] = componentStatus.VendorVersion
+		}
+	}
+
+	ids := make([]string, 0, len(handler.state.Constraints))
+
+	for id := range handler.state.Constraints {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		for _, constraint := range handler.state.Constraints[id] {
+			version, ok := versions[constraint.ID]
+			if !ok {
+				return aoserrors.Errorf("component %s requires component %s which is not installed", id,
+					constraint.ID)
+			}
+
+			if err = handler.checkConstraint(constraint, version); err != nil {
+				return aoserrors.Errorf("component %s requires %s: %v", id, constraint.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (handler *Handler) checkConstraint(constraint versionConstraint, version string) (err error) {
+	log.WithFields(log.Fields{
+		"id": constraint.ID, "version": version, "minVersion": constraint.MinVersion,
+		"maxVersion": constraint.MaxVersion,
+	}).Debug("Check version constraint")
+
+	scheme := handler.components[constraint.ID].versionScheme
+
+	if constraint.MinVersion != "" {
+		result, err := versionutils.CompareWithScheme(scheme, version, constraint.MinVersion)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if result < 0 {
+			return aoserrors.Errorf("version %s is lower than %s", version, constraint.MinVersion)
+		}
+	}
+
+	if constraint.MaxVersion != "" {
+		result, err := versionutils.CompareWithScheme(scheme, version, constraint.MaxVersion)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if result > 0 {
+			return aoserrors.Errorf("version %s is higher than %s", version, constraint.MaxVersion)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestConsistencyConstraints(t *testing.T) {
+	type testData struct {
+		versions    map[string]string
+		constraints string
+		prepareErr  string
+		applyErr    string
+	}
+
+	waitStatus := func(handler *updatehandler.Handler, state umclient.UMState) (status umclient.Status) {
+		t.Helper()
+
+		select {
+		case status = <-handler.StatusChannel():
+			if status.State != state {
+				t.Fatalf("Wrong state: %s, error: %s", status.State, status.Error)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait status timeout")
+		}
+
+		return status
+	}
+
+	for _, item := range []testData{
+		// Required component is not updated
+		{
+			versions:    map[string]string{"id1": "2.0.0"},
+			constraints: `[{"id": "id2", "minVersion": "2.0.0"}]`,
+			applyErr:    "update reverted: component id1 requires id2: version 1.0.0 is lower than 2.0.0",
+		},
+		// Required component is updated to out of range version
+		{
+			versions:    map[string]string{"id1": "2.0.0", "id2": "3.1.0"},
+			constraints: `[{"id": "id2", "minVersion": "2.0.0", "maxVersion": "3.0.0"}]`,
+			applyErr:    "update reverted: component id1 requires id2: version 3.1.0 is higher than 3.0.0",
+		},
+		// Required component is updated to compatible version
+		{
+			versions:    map[string]string{"id1": "2.0.0", "id2": "2.1.0"},
+			constraints: `[{"id": "id2", "minVersion": "2.0.0", "maxVersion": "3.0.0"}]`,
+		},
+		// Required component is not installed
+		{
+			versions:    map[string]string{"id1": "2.0.0"},
+			constraints: `[{"id": "id3", "minVersion": "1.0.0"}]`,
+			applyErr:    "update reverted: component id1 requires component id3 which is not installed",
+		},
+		// Wrong constraint
+		{
+			versions:    map[string]string{"id1": "2.0.0"},
+			constraints: `[{"id": "id2"}]`,
+			prepareErr:  "component id1: constraint of id2 has no version range",
+		},
+	} {
+		components = map[string]*testModule{
+			"id1": {id: "id1", vendorVersion: "1.0.0"},
+			"id2": {id: "id2", vendorVersion: "1.0.0"},
+		}
+
+		handler := newTestHandler(t, &config.Config{
+			UpdateModules: []config.ModuleConfig{
+				{ID: "id1", Plugin: "testmodule", VersionScheme: "semver"},
+				{ID: "id2", Plugin: "testmodule", VersionScheme: "semver"},
+			},
+		}, withModules(components))
+
+		testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+		var infos []umclient.ComponentUpdateInfo
+
+		for _, id := range []string{"id1", "id2"} {
+			version, ok := item.versions[id]
+			if !ok {
+				continue
+			}
+
+			idInfos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: id}}, version)
+			if err != nil {
+				t.Fatalf("Can't create update infos: %s", err)
+			}
+
+			if id == "id1" {
+				idInfos[0].Annotations = json.RawMessage(`{"constraints": ` + item.constraints + `}`)
+			}
+
+			infos = append(infos, idInfos...)
+		}
+
+		handler.PrepareUpdate(infos)
+
+		if item.prepareErr != "" {
+			if status := waitStatus(handler, umclient.StateFailed); !strings.Contains(status.Error, item.prepareErr) {
+				t.Errorf("Wrong prepare error: %s", status.Error)
+			}
+
+			handler.Close(context.Background())
+
+			continue
+		}
+
+		if err := waitForState(handler, umclient.StatePrepared); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		for id, version := range item.versions {
+			components[id].vendorVersion = version
+		}
+
+		handler.StartUpdate()
+
+		if err := waitForState(handler, umclient.StateUpdated); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		order = nil
+
+		handler.ApplyUpdate()
+
+		if status := waitStatus(handler, umclient.StateIdle); !strings.HasPrefix(status.Error, item.applyErr) ||
+			(item.applyErr == "" && status.Error != "") {
+			t.Errorf("Wrong apply error: %s", status.Error)
+		}
+
+		expectedOp := opApply
+
+		if item.applyErr != "" {
+			expectedOp = opRevert
+		}
+
+		expectedOps := make(map[string][]string)
+
+		for id := range item.versions {
+			expectedOps[id] = []string{expectedOp}
+		}
+
+		if err := checkComponentOps(expectedOps); err != nil {
+			t.Errorf("Component operation error: %s", err)
+		}
+
+		handler.Close(context.Background())
+	}
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"sort"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Handler lock is held for the whole transition, so current status is kept in a snapshot guarded by own mutex: it is
+// updated each time status is sent and can be queried at any time without waiting for running operation. Snapshot
+// reflects the last sent status: download progress updates component statuses of the snapshot as well. Active
+// operation is the event being processed by the handler, it is set when the event is accepted and cleared once its
+// transition is finished. Download counters are not part of the snapshot: they are read at request time.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// CurrentStatus current update handler status. Pending components are components with update in progress.
+type CurrentStatus struct {
+	umclient.Status
+	FSMState          string           `json:"fsmState"`
+	Operation         string           `json:"operation,omitempty"`
+	PendingComponents []string         `json:"pendingComponents,omitempty"`
+	DownloadCounters  DownloadCounters `json:"downloadCounters"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetCurrentStatus returns current update handler status. It doesn't wait for running operation.
+func (handler *Handler) GetCurrentStatus() (status CurrentStatus) {
+	handler.currentMutex.Lock()
+	defer handler.currentMutex.Unlock()
+
+	status = handler.currentStatus
+
+	status.Components = append([]umclient.ComponentStatusInfo(nil), status.Components...)
+	status.PendingComponents = append([]string(nil), status.PendingComponents...)
+	status.DownloadCounters = handler.bandwidth.getCounters()
+
+	return status
+}
+
+// GetStatus returns status of current status snapshot. It is used by UM client to reply server with current status.
+func (handler *Handler) GetStatus() (status umclient.Status) {
+	return handler.GetCurrentStatus().Status
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// setCurrentStatus updates current status snapshot. It is called under handler lock.
+func (handler *Handler) setCurrentStatus(status umclient.Status) {
+	var pending []string
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		if componentStatus.Status != umclient.StatusError {
+			pending = append(pending, id)
+		}
+	}
+
+	sort.Strings(pending)
+
+	handler.currentMutex.Lock()
+	defer handler.currentMutex.Unlock()
+
+	handler.currentStatus.Status = status
+	handler.currentStatus.FSMState = handler.fsm.Current()
+	handler.currentStatus.PendingComponents = pending
+}
+
+// setCurrentProgress updates component statuses of current status snapshot by download progress.
+func (handler *Handler) setCurrentProgress(status umclient.Status) {
+	handler.currentMutex.Lock()
+	defer handler.currentMutex.Unlock()
+
+	handler.currentStatus.Components = status.Components
+}
+
+func (handler *Handler) setCurrentOperation(operation string) {
+	handler.currentMutex.Lock()
+	defer handler.currentMutex.Unlock()
+
+	handler.currentStatus.Operation = operation
+}
+
+// clearCurrentOperation clears active operation if it is not replaced by next one, e.g. revert of canceled update.
+func (handler *Handler) clearCurrentOperation(operation string) {
+	handler.currentMutex.Lock()
+	defer handler.currentMutex.Unlock()
+
+	if handler.currentStatus.Operation == operation {
+		handler.currentStatus.Operation = ""
+	}
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestCurrentStatus(t *testing.T) {
+	order = nil
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: path.Join(tmpDir, "downloadDir"),
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	})
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	// Current status is available before the first status is sent
+
+	checkCurrentStatus(t, handler, &currentStatus, "idle", "", nil)
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+
+	for _, info := range infos {
+		newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+			ID: info.ID, AosVersion: info.AosVersion, Status: umclient.StatusInstalling,
+		})
+	}
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+	checkCurrentStatus(t, handler, &newStatus, "prepared", "", []string{"id1", "id2"})
+
+	// Current status is queried without waiting for running update
+
+	rebootBlock := make(chan struct{})
+
+	components["id1"].rebootRequired = true
+	components["id1"].rebootBlock = rebootBlock
+
+	handler.StartUpdate()
+
+	for i := 0; ; i++ {
+		if status := handler.GetCurrentStatus(); status.Operation == "update" {
+			break
+		}
+
+		if i == 100 {
+			close(rebootBlock)
+			t.Fatal("Update operation is not reported")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkCurrentStatus(t, handler, &newStatus, "prepared", "update", []string{"id1", "id2"})
+
+	newStatus.State = umclient.StateUpdated
+
+	testOperation(t, handler, func() { close(rebootBlock) }, &newStatus, nil, nil)
+
+	finalStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", AosVersion: 1, Status: umclient.StatusInstalled},
+			{ID: "id2", AosVersion: 1, Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.ApplyUpdate, &finalStatus, nil, nil)
+
+	for i := 0; handler.GetCurrentStatus().Operation != ""; i++ {
+		if i == 100 {
+			t.Fatal("Apply operation is not finished")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkCurrentStatus(t, handler, &finalStatus, "idle", "", nil)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkCurrentStatus(
+	t *testing.T, handler *updatehandler.Handler, expectedStatus *umclient.Status, fsmState, operation string,
+	pending []string,
+) {
+	t.Helper()
+
+	status := handler.GetCurrentStatus()
+
+	if err := compareStatus(*expectedStatus, status.Status); err != nil {
+		t.Errorf("Wrong current status: %s", err)
+	}
+
+	if status.FSMState != fsmState || status.Operation != operation {
+		t.Errorf("Wrong FSM state: %s, operation: %s", status.FSMState, status.Operation)
+	}
+
+	if !reflect.DeepEqual(status.PendingComponents, pending) {
+		t.Errorf("Wrong pending components: %v", status.PendingComponents)
+	}
+}
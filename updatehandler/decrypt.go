@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Encrypted image is described by encryption annotation: key ID, optional key provider name, algorithm and IV. The
+// same campaign artifact may be encrypted with different region or tenant keys, so the key is not shipped with the
+// update but fetched by key ID from configured key providers: the named one or each of them in config order. Image
+// hashes of update info are checked against encrypted image as it is downloaded, the decrypted image is written
+// into the download session and checked against optional plain image SHA-256 digest from the annotation.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	encryptionAESCTR      = "AES-CTR"
+	decryptedImagePattern = "decrypted-*"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var keyProviderPlugins = make(map[string]NewKeyProvider) //nolint:gochecknoglobals
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// KeyProvider provides image decryption keys by key ID, e.g. from IAM, local keystore or KMS proxy.
+type KeyProvider interface {
+	// GetKey returns decryption key by key ID. Returned key is wiped after use
+	GetKey(keyID string) (key []byte, err error)
+	// Close closes key provider
+	Close() (err error)
+}
+
+// NewKeyProvider key provider new function.
+type NewKeyProvider func(params json.RawMessage) (provider KeyProvider, err error)
+
+type namedKeyProvider struct {
+	name     string
+	provider KeyProvider
+}
+
+type imageEncryption struct {
+	KeyID     string `json:"keyId"`
+	Provider  string `json:"provider,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+	IV        []byte `json:"iv"`
+	Sha256    []byte `json:"sha256,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// RegisterKeyProvider registers image decryption key provider plugin.
+func RegisterKeyProvider(plugin string, newFunc NewKeyProvider) {
+	keyProviderPlugins[plugin] = newFunc
+}
+
+// CreateKeyProvider creates key provider by registered plugin, e.g. for update modules which need own keys.
+func CreateKeyProvider(plugin string, params json.RawMessage) (provider KeyProvider, err error) {
+	newFunc, ok := keyProviderPlugins[plugin]
+	if !ok {
+		return nil, aoserrors.Errorf("key provider plugin %s not found", plugin)
+	}
+
+	if provider, err = newFunc(params); err != nil {
+		return nil, aoserrors.Errorf("can't create key provider %s: %v", plugin, err)
+	}
+
+	return provider, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newKeyProviders(providersCfg []config.KeyProvider) (providers []namedKeyProvider, err error) {
+	if err = checkKeyProviders(providersCfg); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			closeKeyProviders(providers)
+		}
+	}()
+
+	for _, providerCfg := range providersCfg {
+		provider, err := keyProviderPlugins[providerCfg.Plugin](providerCfg.Params)
+		if err != nil {
+			return providers, aoserrors.Errorf("can't create key provider %s: %v", providerCfg.Name, err)
+		}
+
+		providers = append(providers, namedKeyProvider{name: providerCfg.Name, provider: provider})
+	}
+
+	return providers, nil
+}
+
+func checkKeyProviders(providersCfg []config.KeyProvider) (err error) {
+	names := make(map[string]bool)
+
+	for _, providerCfg := range providersCfg {
+		if providerCfg.Name == "" {
+			return aoserrors.New("key provider name is empty")
+		}
+
+		if names[providerCfg.Name] {
+			return aoserrors.Errorf("duplicated key provider %s", providerCfg.Name)
+		}
+
+		names[providerCfg.Name] = true
+
+		if _, ok := keyProviderPlugins[providerCfg.Plugin]; !ok {
+			return aoserrors.Errorf("key provider %s: plugin %s not found", providerCfg.Name, providerCfg.Plugin)
+		}
+	}
+
+	return nil
+}
+
+func closeKeyProviders(providers []namedKeyProvider) {
+	for _, item := range providers {
+		if err := item.provider.Close(); err != nil {
+			log.WithField("provider", item.name).Errorf("Can't close key provider: %v", err)
+		}
+	}
+}
+
+func (handler *Handler) getDecryptionKey(encryption *imageEncryption) (key []byte, err error) {
+	if encryption.KeyID == "" {
+		return nil, aoserrors.New("decryption key ID is empty")
+	}
+
+	found := false
+
+	for _, item := range handler.keyProviders {
+		if encryption.Provider != "" && item.name != encryption.Provider {
+			continue
+		}
+
+		found = true
+
+		if key, err = item.provider.GetKey(encryption.KeyID); err != nil {
+			log.WithFields(log.Fields{"provider": item.name, "keyID": encryption.KeyID}).Debugf(
+				"Can't get decryption key: %v", err)
+
+			continue
+		}
+
+		log.WithFields(log.Fields{"provider": item.name, "keyID": encryption.KeyID}).Debug("Decryption key found")
+
+		return key, nil
+	}
+
+	if encryption.Provider != "" && !found {
+		return nil, aoserrors.Errorf("key provider %s not found", encryption.Provider)
+	}
+
+	return nil, aoserrors.Errorf("decryption key %s not found", encryption.KeyID)
+}
+
+func (handler *Handler) decryptImage(
+	updateInfo *umclient.ComponentUpdateInfo, encryption *imageEncryption, filePath string,
+) (decryptedPath string, err error) {
+	if handler.downloadDir == "" {
+		return "", aoserrors.New("download dir should be configured for image decryption")
+	}
+
+	log.WithFields(log.Fields{
+		"id": updateInfo.ID, "keyID": encryption.KeyID, "algorithm": encryption.Algorithm,
+	}).Debug("Decrypt image")
+
+	key, err := handler.getDecryptionKey(encryption)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+
+	stream, err := newDecryptStream(encryption, key)
+	if err != nil {
+		return "", err
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(handler.sessionDir(), decryptedImagePattern)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+	defer dst.Close()
+
+	defer func() {
+		if err != nil {
+			os.Remove(dst.Name())
+		}
+	}()
+
+	hash := sha256.New()
+
+	if _, err = io.Copy(io.MultiWriter(dst, hash), cipher.StreamReader{S: stream, R: src}); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if len(encryption.Sha256) != 0 && !bytes.Equal(hash.Sum(nil), encryption.Sha256) {
+		return "", aoserrors.New("decrypted image SHA-256 digest mismatch")
+	}
+
+	if err = dst.Sync(); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return dst.Name(), nil
+}
+
+func newDecryptStream(encryption *imageEncryption, key []byte) (stream cipher.Stream, err error) {
+	switch encryption.Algorithm {
+	case "", encryptionAESCTR:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if len(encryption.IV) != block.BlockSize() {
+			return nil, aoserrors.Errorf("wrong IV size: %d", len(encryption.IV))
+		}
+
+		return cipher.NewCTR(block, encryption.IV), nil
+
+	default:
+		return nil, aoserrors.Errorf("unsupported encryption algorithm %s", encryption.Algorithm)
+	}
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestImageDecryption(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+
+	key, iv, plainImage := make([]byte, 32), make([]byte, aes.BlockSize), make([]byte, 4096)
+
+	for _, data := range [][]byte{key, iv, plainImage} {
+		if _, err := rand.Read(data); err != nil {
+			t.Fatalf("Can't generate random data: %s", err)
+		}
+	}
+
+	keyProvider.keys = map[string][]byte{"eu": key}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Can't create cipher: %s", err)
+	}
+
+	encryptedImage := make([]byte, len(plainImage))
+
+	cipher.NewCTR(block, iv).XORKeyStream(encryptedImage, plainImage)
+
+	imagePath := path.Join(tmpDir, "encrypted.bin")
+
+	if err = os.WriteFile(imagePath, encryptedImage, 0o600); err != nil {
+		t.Fatalf("Can't write image: %s", err)
+	}
+
+	imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("Can't create image info: %s", err)
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   cfg.DownloadDir,
+		KeyProviders:  []config.KeyProvider{{Name: "test", Plugin: "testkeys"}},
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	plainDigest := sha256.Sum256(plainImage)
+
+	for _, testItem := range []struct {
+		keyID         string
+		provider      string
+		digest        []byte
+		expectedError string
+	}{
+		{keyID: "eu", digest: plainDigest[:]},
+		{keyID: "us", expectedError: "decryption key us not found"},
+		{keyID: "eu", provider: "kms", expectedError: "key provider kms not found"},
+		{keyID: "eu", digest: make([]byte, sha256.Size), expectedError: "decrypted image SHA-256 digest mismatch"},
+	} {
+		annotations, err := json.Marshal(map[string]interface{}{
+			"encryption": map[string]interface{}{
+				"keyId": testItem.keyID, "provider": testItem.provider, "iv": iv, "sha256": testItem.digest,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Can't marshal annotations: %s", err)
+		}
+
+		infos := []umclient.ComponentUpdateInfo{{
+			ID: "id1", AosVersion: 1, URL: "file://" + imagePath, Sha256: imageInfo.Sha256,
+			Sha512: imageInfo.Sha512, Size: imageInfo.Size, Annotations: annotations,
+		}}
+
+		var (
+			expectedState   umclient.UMState         = umclient.StatePrepared
+			componentStatus umclient.ComponentStatus = umclient.StatusInstalling
+		)
+
+		if testItem.expectedError != "" {
+			expectedState, componentStatus = umclient.StateFailed, umclient.StatusError
+		}
+
+		testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &umclient.Status{
+			State: expectedState,
+			Error: testItem.expectedError,
+			Components: []umclient.ComponentStatusInfo{
+				{ID: "id1", Status: umclient.StatusInstalled},
+				{ID: "id1", AosVersion: 1, Status: componentStatus, Error: testItem.expectedError},
+			},
+		}, nil, nil)
+
+		if testItem.expectedError == "" {
+			data, err := os.ReadFile(components["id1"].imagePath)
+			if err != nil {
+				t.Errorf("Can't read prepared image: %s", err)
+			}
+
+			if !bytes.Equal(data, plainImage) {
+				t.Error("Wrong decrypted image")
+			}
+		}
+
+		testOperation(t, handler, handler.RevertUpdate, nil, nil, nil)
+	}
+}
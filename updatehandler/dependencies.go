@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Component operations are scheduled as DAG: operation is started once operations of its dependencies and all
+// operations of components with higher update priority are finished, independent operations run in parallel. So
+// dependencies refine the order of components with the same priority, e.g. bootloader should be applied before
+// rootfs, and a component can't depend on a component with lower priority. Dependencies on components which don't
+// take part in the operation are ignored. Dependency cycles are detected when the config is loaded. If stop on error
+// is requested, no new operations are started after the first failure.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type scheduledResult struct {
+	index int
+	err   error
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkDependencies(modulesCfg []config.ModuleConfig) (err error) {
+	modules := make(map[string]config.ModuleConfig)
+
+	for _, moduleCfg := range modulesCfg {
+		if !moduleCfg.Disabled {
+			modules[moduleCfg.ID] = moduleCfg
+		}
+	}
+
+	for _, moduleCfg := range modulesCfg {
+		if moduleCfg.Disabled {
+			continue
+		}
+
+		for _, dependency := range moduleCfg.Dependencies {
+			dependencyCfg, ok := modules[dependency]
+			if !ok {
+				return aoserrors.Errorf("dependency %s of component %s not found", dependency, moduleCfg.ID)
+			}
+
+			if dependencyCfg.UpdatePriority < moduleCfg.UpdatePriority {
+				return aoserrors.Errorf("dependency %s of component %s has lower update priority", dependency,
+					moduleCfg.ID)
+			}
+		}
+	}
+
+	visited := make(map[string]bool)
+
+	for _, moduleCfg := range modulesCfg {
+		if moduleCfg.Disabled {
+			continue
+		}
+
+		if cycle := findDependencyCycle(modules, moduleCfg.ID, visited, nil); cycle != nil {
+			return aoserrors.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	return nil
+}
+
+// findDependencyCycle returns dependency cycle reachable from the component if any.
+func findDependencyCycle(
+	modules map[string]config.ModuleConfig, id string, visited map[string]bool, path []string,
+) (cycle []string) {
+	for i, pathID := range path {
+		if pathID == id {
+			return append(append([]string{}, path[i:]...), id)
+		}
+	}
+
+	if visited[id] {
+		return nil
+	}
+
+	path = append(path, id)
+
+	for _, dependency := range modules[id].Dependencies {
+		if cycle = findDependencyCycle(modules, dependency, visited, path); cycle != nil {
+			return cycle
+		}
+	}
+
+	visited[id] = true
+
+	return nil
+}
+
+func scheduleOperations(operations []scheduledOperation, stopOnError bool) (err error) {
+	indexes := make(map[string]int)
+
+	for i, item := range operations {
+		if item.id != "" {
+			indexes[item.id] = i
+		}
+	}
+
+	pending := make([]int, len(operations))
+	dependents := make([][]int, len(operations))
+
+	for i, item := range operations {
+		for j, other := range operations {
+			if other.priority > item.priority {
+				dependents[j] = append(dependents[j], i)
+				pending[i]++
+			}
+		}
+
+		for _, dependency := range item.dependencies {
+			if j, ok := indexes[dependency]; ok && operations[j].priority == item.priority {
+				dependents[j] = append(dependents[j], i)
+				pending[i]++
+			}
+		}
+	}
+
+	results := make(chan scheduledResult, len(operations))
+	running, started := 0, 0
+
+	start := func(index int) {
+		running++
+		started++
+
+		go func() {
+			results <- scheduledResult{index: index, err: operations[index].operation()}
+		}()
+	}
+
+	for i := range operations {
+		if pending[i] == 0 {
+			start(i)
+		}
+	}
+
+	stopped := false
+
+	for ; running > 0; running-- {
+		result := <-results
+
+		if result.err != nil {
+			if err == nil {
+				err = result.err
+			}
+
+			stopped = stopped || stopOnError
+		}
+
+		if stopped {
+			continue
+		}
+
+		for _, i := range dependents[result.index] {
+			if pending[i]--; pending[i] == 0 {
+				start(i)
+			}
+		}
+	}
+
+	if !stopped && started != len(operations) {
+		return aoserrors.New("operations are not started due to dependency cycle")
+	}
+
+	return aoserrors.Wrap(err)
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDependencies(t *testing.T) {
+	storage := newTestStorage()
+
+	cfg := &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+			{ID: "id3", Plugin: "testmodule", Dependencies: []string{"id1"}},
+		},
+	}
+
+	handler := newTestHandler(t, cfg, withStorage(storage))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}, {ID: "id3"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, nil, nil, nil)
+
+	// id3 waits for delayed id1, independent id2 doesn't
+
+	components["id1"].updateDelay = 200 * time.Millisecond
+
+	order = nil
+
+	testOperation(t, handler, handler.StartUpdate, nil, nil, []orderInfo{
+		{id: "id2", op: opUpdate}, {id: "id1", op: opUpdate}, {id: "id3", op: opUpdate},
+	})
+
+	// Wrong dependencies are detected in config
+
+	for _, item := range []struct {
+		modules []config.ModuleConfig
+		message string
+	}{
+		{
+			modules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule", Dependencies: []string{"id2"}}},
+			message: "dependency id2 of component id1 not found",
+		},
+		{
+			modules: []config.ModuleConfig{
+				{ID: "id1", Plugin: "testmodule", UpdatePriority: 1, Dependencies: []string{"id2"}},
+				{ID: "id2", Plugin: "testmodule"},
+			},
+			message: "dependency id2 of component id1 has lower update priority",
+		},
+		{
+			modules: []config.ModuleConfig{
+				{ID: "id1", Plugin: "testmodule", Dependencies: []string{"id2"}},
+				{ID: "id2", Plugin: "testmodule", Dependencies: []string{"id3"}},
+				{ID: "id3", Plugin: "testmodule", Dependencies: []string{"id2"}},
+			},
+			message: "dependency cycle: id2 -> id3 -> id2",
+		},
+	} {
+		findings := updatehandler.ValidateConfig(&config.Config{UpdateModules: item.modules})
+
+		if len(findings) != 1 || !strings.HasPrefix(findings[0].Message, item.message) {
+			t.Errorf("Wrong config findings: %v", findings)
+		}
+
+		if _, err = updatehandler.New(&config.Config{UpdateModules: item.modules}, storage, storage); err == nil {
+			t.Error("Error expected")
+		}
+	}
+}
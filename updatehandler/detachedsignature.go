@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"crypto/x509"
+	"os"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/pkcs7"
+)
+
+// Component with trust anchor requires detached image signature in update annotations: PKCS #7 (CMS) signed data in
+// DER or PEM encoding which signer certificate chains to the trust anchor CA certificates. Hash checks don't protect
+// against compromised distribution channel which serves both the image and its digests. The signature is made over
+// the image passed to the module, i.e. over decrypted image if the image is encrypted, and is verified right before
+// module prepare. OpenPGP signatures are not supported.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const detachedSignaturePKCS7 = "pkcs7"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type detachedSignature struct {
+	Format string `json:"format,omitempty"`
+	Value  []byte `json:"value"`
+}
+
+type trustAnchor struct {
+	name  string
+	roots *x509.CertPool
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newTrustAnchors(cfg *config.Config) (anchors map[string]*trustAnchor, err error) {
+	if err = checkTrustAnchors(cfg); err != nil {
+		return nil, err
+	}
+
+	anchors = make(map[string]*trustAnchor)
+
+	for _, anchorCfg := range cfg.TrustAnchors {
+		data, err := os.ReadFile(anchorCfg.CACert)
+		if err != nil {
+			return nil, aoserrors.Errorf("can't load trust anchor %s: %v", anchorCfg.Name, err)
+		}
+
+		anchor := &trustAnchor{name: anchorCfg.Name, roots: x509.NewCertPool()}
+
+		if !anchor.roots.AppendCertsFromPEM(data) {
+			return nil, aoserrors.Errorf("trust anchor %s: no certificates found", anchorCfg.Name)
+		}
+
+		anchors[anchor.name] = anchor
+	}
+
+	return anchors, nil
+}
+
+func checkTrustAnchors(cfg *config.Config) (err error) {
+	anchors := make(map[string]bool)
+
+	for _, anchorCfg := range cfg.TrustAnchors {
+		if anchorCfg.Name == "" {
+			return aoserrors.New("trust anchor name is empty")
+		}
+
+		if anchors[anchorCfg.Name] {
+			return aoserrors.Errorf("duplicated trust anchor %s", anchorCfg.Name)
+		}
+
+		anchors[anchorCfg.Name] = true
+
+		if anchorCfg.CACert == "" {
+			return aoserrors.Errorf("trust anchor %s: CA cert is not set", anchorCfg.Name)
+		}
+	}
+
+	for _, moduleCfg := range cfg.UpdateModules {
+		if moduleCfg.TrustAnchor != "" && !anchors[moduleCfg.TrustAnchor] {
+			return aoserrors.Errorf("trust anchor %s of module %s not found", moduleCfg.TrustAnchor, moduleCfg.ID)
+		}
+	}
+
+	return nil
+}
+
+func (anchor *trustAnchor) verify(updateInfo *umclient.ComponentUpdateInfo, filePath string) (err error) {
+	signature := getUpdateAnnotations(updateInfo.Annotations).DetachedSignature
+	if signature == nil || len(signature.Value) == 0 {
+		return aoserrors.Errorf("detached image signature is required by trust anchor %s", anchor.name)
+	}
+
+	if signature.Format != "" && signature.Format != detachedSignaturePKCS7 {
+		return aoserrors.Errorf("unsupported detached signature format %s", signature.Format)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	signer, err := pkcs7.Verify(signature.Value, file, anchor.roots)
+	if err != nil {
+		return aoserrors.Errorf("invalid detached image signature: %v", err)
+	}
+
+	log.WithFields(log.Fields{
+		"id": updateInfo.ID, "anchor": anchor.name, "signer": signer.Subject.String(),
+	}).Debug("Detached image signature verified")
+
+	return nil
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/pkcs7"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDetachedSignature(t *testing.T) {
+	components = make(map[string]*testModule)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %s", err)
+	}
+
+	caCert, err := createCertificate("ca", caKey, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create certificate: %s", err)
+	}
+
+	caCertFile := path.Join(tmpDir, "trustanchor.pem")
+
+	if err = os.WriteFile(caCertFile, pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}), 0o600); err != nil {
+		t.Fatalf("Can't write CA cert: %s", err)
+	}
+
+	signerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %s", err)
+	}
+
+	signerCert, err := createCertificate("signer", signerKey, caCert, caKey)
+	if err != nil {
+		t.Fatalf("Can't create certificate: %s", err)
+	}
+
+	untrustedCert, err := createCertificate("untrusted", signerKey, nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create certificate: %s", err)
+	}
+
+	moduleCfg := &config.Config{
+		DownloadDir:  cfg.DownloadDir,
+		TrustAnchors: []config.TrustAnchor{{Name: "vendor", CACert: caCertFile}},
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", TrustAnchor: "vendor"},
+			{ID: "id2", Plugin: "testmodule", TrustAnchor: "oem"},
+		},
+	}
+
+	if _, err = updatehandler.New(moduleCfg, newTestStorage(), newTestStorage()); err == nil ||
+		!strings.Contains(err.Error(), "trust anchor oem of module id2 not found") {
+		t.Errorf("Trust anchor not found error expected: %v", err)
+	}
+
+	moduleCfg.UpdateModules[1].TrustAnchor = "vendor"
+
+	handler := newTestHandler(t, moduleCfg)
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// id1 is signed by certificate issued by trust anchor, id2 is signed by untrusted certificate
+
+	for i, cert := range []*x509.Certificate{signerCert, untrustedCert} {
+		imageFile, err := os.Open(strings.TrimPrefix(infos[i].URL, "file://"))
+		if err != nil {
+			t.Fatalf("Can't open image: %s", err)
+		}
+
+		signature, err := pkcs7.Sign(imageFile, cert, signerKey)
+
+		imageFile.Close()
+
+		if err != nil {
+			t.Fatalf("Can't sign image: %s", err)
+		}
+
+		if infos[i].Annotations, err = json.Marshal(map[string]interface{}{
+			"detachedSignature": map[string]interface{}{"format": "pkcs7", "value": signature},
+		}); err != nil {
+			t.Fatalf("Can't marshal annotations: %s", err)
+		}
+	}
+
+	signatureErr := "invalid detached image signature"
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &umclient.Status{
+		State: umclient.StateFailed,
+		Error: signatureErr,
+		Components: append(currentStatus.Components, []umclient.ComponentStatusInfo{
+			{ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling},
+			{ID: "id2", AosVersion: infos[1].AosVersion, Status: umclient.StatusError, Error: signatureErr},
+		}...),
+	}, nil, nil)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func createCertificate(
+	name string, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer,
+) (cert *x509.Certificate, err error) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return cert, nil
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// When failed update session is finished, diagnostic bundle is created and passed to bundle uploader if it is set.
+// The bundle is gzip compressed tar archive with session info, status, handler state (component errors, resource
+// usage, download reports, health decision) and update history entries of the session including journald cursors,
+// so the failure can be analyzed without collecting device logs. Sessions interrupted by cancel, emergency stop or
+// shutdown are not considered as failed.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const diagnosticBundleExt = ".tar.gz"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// BundleUploader uploads diagnostic bundles of failed update sessions.
+type BundleUploader interface {
+	// UploadBundle stores the bundle and uploads it in background
+	UploadBundle(name string, data []byte) (err error)
+}
+
+type diagnosticSessionInfo struct {
+	InstanceID string    `json:"instanceId"`
+	Session    string    `json:"session"`
+	Time       time.Time `json:"time"`
+	Error      string    `json:"error,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// SetBundleUploader sets uploader of diagnostic bundles, nil uploader disables bundle creation.
+func (handler *Handler) SetBundleUploader(uploader BundleUploader) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	handler.bundleUploader = uploader
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// isSessionFailed is called when update is finished.
+func (handler *Handler) isSessionFailed() (failed bool) {
+	if handler.state.Error != "" && !isInterruptError(handler.state.Error) {
+		return true
+	}
+
+	for _, componentStatus := range handler.state.ComponentStatuses {
+		if componentStatus.Status == umclient.StatusError && !isInterruptError(componentStatus.Error) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uploadDiagnosticBundle is called when update is finished.
+func (handler *Handler) uploadDiagnosticBundle() {
+	if handler.bundleUploader == nil || !handler.isSessionFailed() {
+		return
+	}
+
+	data, err := handler.createDiagnosticBundle()
+	if err != nil {
+		log.Errorf("Can't create diagnostic bundle: %v", err)
+
+		return
+	}
+
+	name := handler.instanceID + "-" + handler.state.HistorySession + diagnosticBundleExt
+
+	log.WithFields(log.Fields{"name": name, "size": len(data)}).Debug("Upload diagnostic bundle")
+
+	if err = handler.bundleUploader.UploadBundle(name, data); err != nil {
+		log.Errorf("Can't upload diagnostic bundle: %v", err)
+	}
+}
+
+func (handler *Handler) createDiagnosticBundle() (data []byte, err error) {
+	history, err := handler.history.Entries(handler.state.HistorySession)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"session.json", diagnosticSessionInfo{
+			InstanceID: handler.instanceID, Session: handler.state.HistorySession, Time: handler.clock.Now(),
+			Error: handler.state.Error,
+		}},
+		{"status.json", handler.getStatus()},
+		{"state.json", handler.state},
+		{"history.json", history},
+	}
+
+	var buffer bytes.Buffer
+
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, file := range files {
+		content, err := json.MarshalIndent(file.value, "", "    ")
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if err = tarWriter.WriteHeader(&tar.Header{
+			Name: file.name, Mode: 0o644, Size: int64(len(content)), ModTime: handler.clock.Now(),
+		}); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if _, err = tarWriter.Write(content); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	if err = tarWriter.Close(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = gzipWriter.Close(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return buffer.Bytes(), nil
+}
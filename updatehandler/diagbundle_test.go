@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testBundleUploader struct {
+	sync.Mutex
+	bundles map[string][]byte
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDiagnosticBundle(t *testing.T) {
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	})
+
+	uploader := &testBundleUploader{bundles: make(map[string][]byte)}
+
+	handler.SetBundleUploader(uploader)
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Successful session doesn't create bundle
+
+	for _, operation := range []func(){
+		func() { handler.PrepareUpdate(infos) }, handler.StartUpdate, handler.ApplyUpdate,
+	} {
+		operation()
+
+		if err = waitForStatus(handler, nil); err != nil {
+			t.Fatalf("Wait for status failed: %s", err)
+		}
+	}
+
+	if len(uploader.bundles) != 0 {
+		t.Errorf("Unexpected bundles: %d", len(uploader.bundles))
+	}
+
+	// Failed session creates bundle
+
+	infos[0].AosVersion++
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	components["id1"].status = aoserrors.New("update error")
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.RevertUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	uploader.Lock()
+	defer uploader.Unlock()
+
+	if len(uploader.bundles) != 1 {
+		t.Fatalf("Wrong bundle count: %d", len(uploader.bundles))
+	}
+
+	for name, data := range uploader.bundles {
+		if !strings.HasPrefix(name, handler.InstanceID()+"-") || !strings.HasSuffix(name, ".tar.gz") {
+			t.Errorf("Wrong bundle name: %s", name)
+		}
+
+		files, err := readBundleFiles(data)
+		if err != nil {
+			t.Fatalf("Can't read bundle: %s", err)
+		}
+
+		for _, fileName := range []string{"session.json", "status.json", "state.json", "history.json"} {
+			if _, ok := files[fileName]; !ok {
+				t.Errorf("Bundle file %s not found", fileName)
+			}
+		}
+
+		if !strings.Contains(string(files["state.json"]), "update error") {
+			t.Errorf("Component error not found in bundle state: %s", files["state.json"])
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func readBundleFiles(data []byte) (files map[string][]byte, err error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	tarReader := tar.NewReader(gzipReader)
+	files = make(map[string][]byte)
+
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if files[header.Name], err = io.ReadAll(tarReader); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+}
+
+func (uploader *testBundleUploader) UploadBundle(name string, data []byte) (err error) {
+	uploader.Lock()
+	defer uploader.Unlock()
+
+	uploader.bundles[name] = data
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/versionutils"
+)
+
+// Update to lower Aos version than installed one is rejected unless operator forces downgrade by the update info
+// flag or by force downgrade annotation as there is no dedicated field in the protocol. Same version is allowed by
+// reinstall flag, so factory rollback to the same release requires both. Vendor versions may be not comparable, so
+// lower vendor version doesn't reject update as before, but is treated as downgrade as well if versions can be
+// compared by component version scheme. Module which implements downgrade interface is prepared with the dedicated
+// call on downgrade, e.g. to allow older image or to migrate its data back, others are prepared as usual.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// DowngradeModule optional interface which can be implemented by update module to prepare downgrade of component.
+type DowngradeModule interface {
+	// PrepareDowngrade prepares module to downgrade component to older version
+	PrepareDowngrade(ctx context.Context, imagePath string, vendorVersion string, annotations json.RawMessage) (err error)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func isForceDowngrade(updateInfo *umclient.ComponentUpdateInfo) (force bool) {
+	return updateInfo.ForceDowngrade || getUpdateAnnotations(updateInfo.Annotations).ForceDowngrade
+}
+
+// checkDowngrade returns true if update downgrades the component and fails if downgrade is not forced.
+func (handler *Handler) checkDowngrade(
+	updateInfo *umclient.ComponentUpdateInfo, aosVersion uint64, vendorVersion string,
+) (downgrade bool, err error) {
+	force := isForceDowngrade(updateInfo)
+
+	if updateInfo.AosVersion != 0 && aosVersion > updateInfo.AosVersion {
+		if !force {
+			return false, aoserrors.New("wrong Aos version")
+		}
+
+		downgrade = true
+	}
+
+	if updateInfo.VendorVersion != "" && vendorVersion != "" {
+		if result, err := versionutils.CompareWithScheme(
+			handler.components[updateInfo.ID].versionScheme, updateInfo.VendorVersion, vendorVersion); err == nil &&
+			result < 0 {
+			downgrade = true
+		}
+	}
+
+	return downgrade, nil
+}
+
+func prepareModule(
+	ctx context.Context, module UpdateModule, filePath string, updateInfo *umclient.ComponentUpdateInfo, downgrade bool,
+) (err error) {
+	if downgrade {
+		log.WithFields(log.Fields{
+			"id": updateInfo.ID, "aosVersion": updateInfo.AosVersion, "vendorVersion": updateInfo.VendorVersion,
+		}).Warn("Downgrade component")
+
+		if downgradeModule, ok := baseModule(module).(DowngradeModule); ok {
+			return aoserrors.Wrap(downgradeModule.PrepareDowngrade(
+				ctx, filePath, updateInfo.VendorVersion, updateInfo.Annotations))
+		}
+	}
+
+	return aoserrors.Wrap(module.Prepare(ctx, filePath, updateInfo.VendorVersion, updateInfo.Annotations))
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestForceDowngrade(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1", vendorVersion: "2.0"}}
+	storage := newTestStorage()
+
+	if err := storage.SetAosVersion("id1", 3); err != nil {
+		t.Fatalf("Can't set Aos version: %s", err)
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withStorage(storage), withModules(components))
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", VendorVersion: "2.0", AosVersion: 3, Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1", AosVersion: 1}}, "1.0")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	if diff := handler.GetUpdateDiff(infos); len(diff) != 1 || !strings.Contains(diff[0].Error, "wrong Aos version") {
+		t.Errorf("Wrong update diff: %v", diff)
+	}
+
+	infos[0].ForceDowngrade = true
+
+	if diff := handler.GetUpdateDiff(infos); len(diff) != 1 || diff[0].Error != "" || !diff[0].Downgrade {
+		t.Errorf("Wrong update diff: %v", diff)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", VendorVersion: "1.0", AosVersion: 2, Status: umclient.StatusInstalling,
+	})
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus,
+		map[string][]string{"id1": {opDowngrade}}, nil)
+
+	newStatus.State = umclient.StateUpdated
+	components["id1"].vendorVersion = "1.0"
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus, nil, nil)
+
+	testOperation(t, handler, handler.ApplyUpdate, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", VendorVersion: "1.0", AosVersion: 2, Status: umclient.StatusInstalled},
+		},
+	}, map[string][]string{"id1": {opDowngrade, opUpdate, opApply}}, nil)
+}
@@ -0,0 +1,359 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/cavaliergopher/grab/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	customHeaderPrefix   = "X-Aos-"
+	downloadProgressTime = 30 * time.Second
+	cacheInfoExt         = ".json"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type cacheInfo struct {
+	URL      string `json:"url"`
+	ETag     string `json:"etag"`
+	FileName string `json:"fileName"`
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) downloadImage(
+	ctx context.Context, updateInfo *umclient.ComponentUpdateInfo, imageURL string, verifier *streamVerifier,
+) (filePath string, err error) {
+	log.WithField("url", imageURL).Debug("Start downloading image")
+
+	if filePath, err = handler.startSessionDownload(imageURL, updateInfo); err != nil {
+		return "", err
+	}
+
+	defer func() {
+		// Interrupted download is kept in session to be resumed
+		if ctx.Err() == nil {
+			handler.finishSessionDownload(imageURL)
+		}
+	}()
+
+	req, err := grab.NewRequest(filePath, imageURL)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	req = req.WithContext(ctx)
+	req.Size = int64(updateInfo.Size)
+	req.RateLimiter = handler.bandwidth.forComponent(updateInfo.ID, handler.state.DownloadSession)
+
+	for name, value := range getUpdateAnnotations(updateInfo.Annotations).DownloadHeaders {
+		name = http.CanonicalHeaderKey(name)
+
+		if !strings.HasPrefix(name, customHeaderPrefix) {
+			return "", aoserrors.Errorf("download header %s is not allowed", name)
+		}
+
+		req.HTTPRequest.Header.Set(name, value)
+	}
+
+	cached := handler.getCacheInfo(imageURL)
+	if cached != nil {
+		req.HTTPRequest.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	client := grab.NewClient()
+
+	if tlsConfig := handler.getHostTLSConfig(req.URL()); tlsConfig != nil {
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		}
+	}
+
+	verifier.attach(req, client)
+
+	resp := client.Do(req)
+
+	if filePath, err = handler.waitDownload(updateInfo.ID, resp); err != nil {
+		var statusErr grab.StatusCodeError
+
+		if cached != nil && errors.As(err, &statusErr) && int(statusErr) == http.StatusNotModified {
+			log.WithFields(log.Fields{"url": imageURL, "etag": cached.ETag}).Debug("Image not modified, use cached")
+
+			return handler.restoreFromCache(imageURL, cached)
+		}
+
+		// Invalid image should not be resumed by next download
+		if verifier.isFailed() {
+			if removeErr := os.RemoveAll(resp.Filename); removeErr != nil {
+				log.Errorf("Can't remove download file: %v", removeErr)
+			}
+		}
+
+		return "", err
+	}
+
+	log.WithFields(log.Fields{"url": imageURL, "file": filePath}).Debug("Download complete")
+
+	if etag := resp.HTTPResponse.Header.Get("ETag"); etag != "" && handler.cacheDir != "" {
+		if err := handler.storeToCache(imageURL, etag, filePath); err != nil {
+			log.WithField("url", imageURL).Warnf("Can't store image to cache: %v", err)
+		}
+	}
+
+	return filePath, nil
+}
+
+func (handler *Handler) waitDownload(id string, resp *grab.Response) (filePath string, err error) {
+	ticker := handler.clock.NewTicker(handler.downloadTickTime())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			log.WithFields(log.Fields{
+				"complete": resp.BytesComplete(), "total": resp.Size(),
+			}).Debug("Download progress")
+
+			handler.reportProgress(id, resp)
+
+		case <-resp.Done:
+			if err := resp.Err(); err != nil {
+				if resp.Filename != "" && resp.Request.Context().Err() == nil {
+					if removeErr := os.RemoveAll(resp.Filename); removeErr != nil {
+						log.Errorf("Can't remove download file: %v", removeErr)
+					}
+				}
+
+				return "", aoserrors.Wrap(err)
+			}
+
+			handler.reportProgress(id, resp)
+
+			return resp.Filename, nil
+		}
+	}
+}
+
+func (handler *Handler) getHostTLSConfig(hostURL *url.URL) (tlsConfig *tls.Config) {
+	if tlsConfig, ok := handler.downloadHosts[hostURL.Host]; ok {
+		return tlsConfig
+	}
+
+	return handler.downloadHosts[hostURL.Hostname()]
+}
+
+func newDownloadHosts(hostsCfg []config.DownloadHost) (hosts map[string]*tls.Config, err error) {
+	hosts = make(map[string]*tls.Config)
+
+	for _, hostCfg := range hostsCfg {
+		if hostCfg.Host == "" {
+			return nil, aoserrors.New("download host is not set")
+		}
+
+		if hosts[hostCfg.Host], err = newHostTLSConfig(hostCfg); err != nil {
+			return nil, aoserrors.Errorf("invalid TLS config for host %s: %v", hostCfg.Host, err)
+		}
+	}
+
+	return hosts, nil
+}
+
+func newHostTLSConfig(hostCfg config.DownloadHost) (tlsConfig *tls.Config, err error) {
+	tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12, ServerName: hostCfg.ServerName}
+
+	if hostCfg.CACert != "" {
+		pemData, err := os.ReadFile(hostCfg.CACert)
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pemData) {
+			return nil, aoserrors.Errorf("no certificates found in %s", hostCfg.CACert)
+		}
+	}
+
+	if len(hostCfg.CertFingerprints) == 0 {
+		return tlsConfig, nil
+	}
+
+	fingerprints := make([][]byte, 0, len(hostCfg.CertFingerprints))
+
+	for _, fingerprint := range hostCfg.CertFingerprints {
+		value, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+		if err != nil || len(value) != sha256.Size {
+			return nil, aoserrors.Errorf("invalid certificate fingerprint: %s", fingerprint)
+		}
+
+		fingerprints = append(fingerprints, value)
+	}
+
+	// Pinned certificate is trusted by itself if custom CA is not configured
+	tlsConfig.InsecureSkipVerify = hostCfg.CACert == "" //nolint:gosec // server certificate is verified by pin
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return aoserrors.New("no server certificate")
+		}
+
+		leafFingerprint := sha256.Sum256(rawCerts[0])
+
+		for _, fingerprint := range fingerprints {
+			if bytes.Equal(fingerprint, leafFingerprint[:]) {
+				return nil
+			}
+		}
+
+		return aoserrors.New("server certificate doesn't match pinned fingerprint")
+	}
+
+	return tlsConfig, nil
+}
+
+func (handler *Handler) cachePath(imageURL string) (path string) {
+	hash := sha256.Sum256([]byte(imageURL))
+
+	return filepath.Join(handler.cacheDir, hex.EncodeToString(hash[:]))
+}
+
+func (handler *Handler) getCacheInfo(imageURL string) (info *cacheInfo) {
+	if handler.cacheDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(handler.cachePath(imageURL) + cacheInfoExt)
+	if err != nil {
+		return nil
+	}
+
+	info = &cacheInfo{}
+
+	if err = json.Unmarshal(data, info); err != nil || info.URL != imageURL || info.ETag == "" {
+		return nil
+	}
+
+	if _, err = os.Stat(handler.cachePath(imageURL)); err != nil {
+		return nil
+	}
+
+	return info
+}
+
+func (handler *Handler) storeToCache(imageURL, etag, filePath string) (err error) {
+	if err = os.MkdirAll(handler.cacheDir, 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	// Remove cache info first to not leave stale info for partially updated cache entry
+	handler.removeFromCache(imageURL)
+
+	if err = linkOrCopyFile(filePath, handler.cachePath(imageURL)); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cacheInfo{URL: imageURL, ETag: etag, FileName: filepath.Base(filePath)})
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = os.WriteFile(handler.cachePath(imageURL)+cacheInfoExt, data, 0o600); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func (handler *Handler) restoreFromCache(imageURL string, info *cacheInfo) (filePath string, err error) {
+	filePath = filepath.Join(handler.sessionDir(), filepath.Base(info.FileName))
+
+	if err = os.RemoveAll(filePath); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if err = linkOrCopyFile(handler.cachePath(imageURL), filePath); err != nil {
+		return "", err
+	}
+
+	return filePath, nil
+}
+
+func (handler *Handler) removeFromCache(imageURL string) {
+	if handler.cacheDir == "" {
+		return
+	}
+
+	for _, path := range []string{handler.cachePath(imageURL) + cacheInfoExt, handler.cachePath(imageURL)} {
+		if err := os.RemoveAll(path); err != nil {
+			log.Errorf("Can't remove cache file: %v", err)
+		}
+	}
+}
+
+func linkOrCopyFile(src, dst string) (err error) {
+	if err = os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer dstFile.Close()
+
+	if _, err = io.Copy(dstFile, srcFile); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,339 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDownloadCache(t *testing.T) {
+	const etag = `"v1"`
+
+	imagePath := path.Join(tmpDir, "cacheimage.bin")
+
+	imageInfo, err := createImage(imagePath)
+	if err != nil {
+		t.Fatalf("Can't create image: %s", err)
+	}
+
+	var (
+		downloadCount    int
+		notModifiedCount int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Aos-Tenant") != "tenant1" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+
+		if r.Header.Get("If-None-Match") == etag {
+			if r.Method == http.MethodGet {
+				notModifiedCount++
+			}
+
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			downloadCount++
+		}
+
+		http.ServeFile(w, r, imagePath)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		DownloadDir:   path.Join(tmpDir, "downloadDir"),
+		CacheDir:      path.Join(tmpDir, "cacheDir"),
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}
+
+	handler := newTestHandler(t, cfg)
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos := []umclient.ComponentUpdateInfo{{
+		ID:          "id1",
+		AosVersion:  1,
+		URL:         server.URL + "/cacheimage.bin",
+		Sha256:      imageInfo.Sha256,
+		Sha512:      imageInfo.Sha512,
+		Size:        imageInfo.Size,
+		Annotations: json.RawMessage(`{"downloadHeaders":{"X-Aos-Tenant":"tenant1"}}`),
+	}}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: 1, Status: umclient.StatusInstalling,
+	})
+
+	for i := 0; i < 2; i++ {
+		testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+		testOperation(t, handler, handler.RevertUpdate, &currentStatus, nil, nil)
+	}
+
+	if downloadCount != 1 {
+		t.Errorf("Wrong download count: %d", downloadCount)
+	}
+
+	if notModifiedCount != 1 {
+		t.Errorf("Wrong not modified count: %d", notModifiedCount)
+	}
+
+	infos[0].Annotations = json.RawMessage(`{"downloadHeaders":{"Authorization":"token"}}`)
+
+	failedStatus := umclient.Status{
+		State: umclient.StateFailed,
+		Error: "download header Authorization is not allowed",
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{
+				ID: "id1", AosVersion: 1, Status: umclient.StatusError,
+				Error: "download header Authorization is not allowed",
+			},
+		},
+	}
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &failedStatus, nil, nil)
+}
+
+func TestResumeDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("resume"), 65536)
+	imagePath := path.Join(tmpDir, "resumeimage.bin")
+	downloadDir := path.Join(tmpDir, "resumeDownload")
+
+	if err := writeImage(imagePath, content, false); err != nil {
+		t.Fatalf("Can't write image: %s", err)
+	}
+
+	imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("Can't create file info: %s", err)
+	}
+
+	var rangeHeader atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.ServeContent(w, r, "resumeimage.bin", time.Time{}, bytes.NewReader(content))
+
+			return
+		}
+
+		// First download hangs in the middle till the client is gone
+		if r.Header.Get("Range") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+
+			_, _ = w.Write(content[:len(content)/2])
+
+			w.(http.Flusher).Flush() //nolint:forcetypeassert
+
+			<-r.Context().Done()
+
+			return
+		}
+
+		rangeHeader.Store(r.Header.Get("Range"))
+
+		http.ServeContent(w, r, "resumeimage.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	storage := newTestStorage()
+	cfg := &config.Config{
+		DownloadDir:   downloadDir,
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}
+	infos := []umclient.ComponentUpdateInfo{{
+		ID: "id1", AosVersion: 1, URL: server.URL + "/resumeimage.bin",
+		Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+	}}
+
+	crashedHandler := newTestHandler(t, cfg, withStorage(storage))
+
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		crashedHandler.Close(ctx)
+	}()
+
+	testOperation(t, crashedHandler, crashedHandler.Registered, nil, nil, nil)
+
+	crashedHandler.PrepareUpdate(infos)
+
+	// Wait till half of the image is downloaded
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		files, _ := filepath.Glob(path.Join(downloadDir, "session-*", "*-resumeimage.bin"))
+
+		if len(files) == 1 {
+			if info, err := os.Stat(files[0]); err == nil && info.Size() == int64(len(content)/2) {
+				break
+			}
+		}
+
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("Wait for partial download timeout")
+		}
+	}
+
+	// Restarted handler resumes download from the partial file
+
+	handler := newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if value, _ := rangeHeader.Load().(string); value != fmt.Sprintf("bytes=%d-", len(content)/2) {
+		t.Errorf("Wrong range header: %s", value)
+	}
+}
+
+func TestDownloadTLS(t *testing.T) {
+	imagePath := path.Join(tmpDir, "tlsimage.bin")
+
+	imageInfo, err := createImage(imagePath)
+	if err != nil {
+		t.Fatalf("Can't create image: %s", err)
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, imagePath)
+	}))
+	defer server.Close()
+
+	caCert := path.Join(tmpDir, "serverCA.pem")
+
+	if err = os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: server.Certificate().Raw,
+	}), 0o600); err != nil {
+		t.Fatalf("Can't write CA cert: %s", err)
+	}
+
+	fingerprint := sha256.Sum256(server.Certificate().Raw)
+
+	type testData struct {
+		host  config.DownloadHost
+		error string
+	}
+
+	data := []testData{
+		{host: config.DownloadHost{Host: "localhost"}, error: "certificate signed by unknown authority"},
+		{host: config.DownloadHost{Host: "127.0.0.1", CACert: caCert, ServerName: "example.com"}},
+		{host: config.DownloadHost{Host: "127.0.0.1", CACert: caCert, ServerName: "wrong.com"}, error: "wrong.com"},
+		{host: config.DownloadHost{Host: "127.0.0.1", CertFingerprints: []string{hex.EncodeToString(fingerprint[:])}}},
+		{
+			host:  config.DownloadHost{Host: "127.0.0.1", CertFingerprints: []string{hex.EncodeToString(make([]byte, 32))}},
+			error: "pinned fingerprint",
+		},
+	}
+
+	for _, item := range data {
+		cfg := &config.Config{
+			DownloadDir:   path.Join(tmpDir, "downloadDir"),
+			DownloadHosts: []config.DownloadHost{item.host},
+			UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+		}
+
+		handler := newTestHandler(t, cfg)
+
+		currentStatus := umclient.Status{
+			State:      umclient.StateIdle,
+			Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+		}
+
+		testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+		infos := []umclient.ComponentUpdateInfo{{
+			ID:         "id1",
+			AosVersion: 1,
+			URL:        server.URL + "/tlsimage.bin",
+			Sha256:     imageInfo.Sha256,
+			Sha512:     imageInfo.Sha512,
+			Size:       imageInfo.Size,
+		}}
+
+		newStatus := umclient.Status{
+			State: umclient.StatePrepared,
+			Components: []umclient.ComponentStatusInfo{
+				{ID: "id1", Status: umclient.StatusInstalled},
+				{ID: "id1", AosVersion: 1, Status: umclient.StatusInstalling},
+			},
+		}
+
+		if item.error != "" {
+			newStatus.State = umclient.StateFailed
+			newStatus.Error = item.error
+			newStatus.Components[1].Status = umclient.StatusError
+			newStatus.Components[1].Error = item.error
+		}
+
+		testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+		handler.Close(context.Background())
+	}
+
+	if _, err = updatehandler.New(&config.Config{
+		DownloadHosts: []config.DownloadHost{{Host: "127.0.0.1", CertFingerprints: []string{"invalid"}}},
+	}, newTestStorage(), newTestStorage()); err == nil {
+		t.Error("Error expected for invalid fingerprint")
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// If max concurrent downloads is set, images of all components are fetched by worker pool before components are
+// prepared, otherwise each image is fetched when its component is prepared. Images are fetched in update priority
+// order. Signatures are verified before the image is fetched. Once any image fails, pending downloads are not
+// started and running ones are canceled: the error is reported when the component is prepared.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type downloadResult struct {
+	filePath string
+	err      error
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkMaxDownloads(maxDownloads int) (err error) {
+	if maxDownloads < 0 {
+		return aoserrors.Errorf("wrong max concurrent downloads: %d", maxDownloads)
+	}
+
+	return nil
+}
+
+// downloadImages fetches images of components by worker pool.
+func (handler *Handler) downloadImages(
+	componentsInfo map[string]*umclient.ComponentUpdateInfo,
+) (results map[string]downloadResult) {
+	if handler.maxDownloads == 0 || len(componentsInfo) == 0 {
+		return nil
+	}
+
+	infos := make([]*umclient.ComponentUpdateInfo, 0, len(componentsInfo))
+
+	for _, info := range componentsInfo {
+		if _, ok := handler.components[info.ID]; ok {
+			infos = append(infos, info)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		iPriority, jPriority := handler.components[infos[i].ID].updatePriority,
+			handler.components[infos[j].ID].updatePriority
+
+		if iPriority != jPriority {
+			return iPriority > jPriority
+		}
+
+		return infos[i].ID < infos[j].ID
+	})
+
+	log.WithField("maxDownloads", handler.maxDownloads).Debug("Download component images")
+
+	ctx, cancel := context.WithCancel(handler.operationContext())
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		jobs  = make(chan *umclient.ComponentUpdateInfo)
+	)
+
+	results = make(map[string]downloadResult)
+
+	workers := handler.maxDownloads
+	if workers > len(infos) {
+		workers = len(infos)
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for info := range jobs {
+				filePath, err := handler.fetchImage(ctx, info)
+				if err != nil {
+					log.WithField("id", info.ID).Errorf("Can't download image: %v", err)
+
+					cancel()
+				}
+
+				mutex.Lock()
+				results[info.ID] = downloadResult{filePath: filePath, err: err}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+feedLoop:
+	for _, info := range infos {
+		select {
+		case jobs <- info:
+
+		case <-ctx.Done():
+			break feedLoop
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// getComponentImage returns image downloaded by worker pool or fetches it.
+func (handler *Handler) getComponentImage(
+	ctx context.Context, updateInfo *umclient.ComponentUpdateInfo, images map[string]downloadResult,
+) (filePath string, err error) {
+	if result, ok := images[updateInfo.ID]; ok {
+		return result.filePath, result.err
+	}
+
+	return handler.fetchImage(ctx, updateInfo)
+}
+
+func (handler *Handler) fetchImage(
+	ctx context.Context, updateInfo *umclient.ComponentUpdateInfo,
+) (filePath string, err error) {
+	if handler.components[updateInfo.ID].imagePolicy.signature {
+		if err = handler.components[updateInfo.ID].signaturePolicy.verify(updateInfo); err != nil {
+			return "", err
+		}
+	}
+
+	// Bundle is downloaded once for all its components
+	if isBundle(updateInfo) {
+		handler.bundleMutex.Lock()
+		defer handler.bundleMutex.Unlock()
+	}
+
+	return handler.getImage(ctx, updateInfo)
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestConcurrentDownloads(t *testing.T) {
+	imagePath := path.Join(tmpDir, "concurrentimage.bin")
+
+	imageInfo, err := createImage(imagePath)
+	if err != nil {
+		t.Fatalf("Can't create image: %s", err)
+	}
+
+	var active, maxActive int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+
+		for {
+			prev := atomic.LoadInt32(&maxActive)
+			if current <= prev || atomic.CompareAndSwapInt32(&maxActive, prev, current) {
+				break
+			}
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		http.ServeFile(w, r, imagePath)
+	}))
+	defer server.Close()
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:            path.Join(tmpDir, "downloadDir"),
+		MaxConcurrentDownloads: 2,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", UpdatePriority: 30},
+			{ID: "id2", Plugin: "testmodule", UpdatePriority: 20},
+			{ID: "id3", Plugin: "testmodule", UpdatePriority: 10},
+		},
+	})
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos := make([]umclient.ComponentUpdateInfo, 0, 3)
+
+	for _, id := range []string{"id1", "id2", "id3"} {
+		infos = append(infos, umclient.ComponentUpdateInfo{
+			ID: id, AosVersion: 1, URL: server.URL + "/" + id + ".bin",
+			Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+		})
+	}
+
+	// Components of different priorities are prepared one by one, but their images are downloaded in parallel
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if maxActive := atomic.LoadInt32(&maxActive); maxActive != 2 {
+		t.Errorf("Wrong max concurrent downloads: %d", maxActive)
+	}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{MaxConcurrentDownloads: -1}); len(findings) != 1 {
+		t.Errorf("Wrong findings: %v", findings)
+	}
+}
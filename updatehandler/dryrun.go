@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/looplab/fsm"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Dry run is a regular prepare: images are downloaded and checked, module prepare validates them, but the update
+// never goes further. Right after prepare, the dry run report is built and prepared components are reverted within
+// the same transition, so the handler returns to idle state and update or apply can't be requested for dry run.
+// Component failures of dry run are reported by dry run report only and don't stay in component statuses. Canceled
+// dry run is reverted as regular update. If dry run revert fails, the handler goes to failed state.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// DryRunComponent component change which would be done by the update.
+type DryRunComponent struct {
+	ID                   string `json:"id"`
+	CurrentVendorVersion string `json:"currentVendorVersion,omitempty"`
+	CurrentAosVersion    uint64 `json:"currentAosVersion"`
+	VendorVersion        string `json:"vendorVersion,omitempty"`
+	AosVersion           uint64 `json:"aosVersion"`
+	Skipped              bool   `json:"skipped,omitempty"`
+	Error                string `json:"error,omitempty"`
+}
+
+// DryRunReport result of the last dry run update.
+type DryRunReport struct {
+	Time       time.Time         `json:"time"`
+	Error      string            `json:"error,omitempty"`
+	Components []DryRunComponent `json:"components"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// DryRunUpdate downloads and validates update and prepares components without updating them. Prepared components are
+// reverted right after prepare, the result is available by GetDryRunReport.
+func (handler *Handler) DryRunUpdate(components []umclient.ComponentUpdateInfo) (err error) {
+	log.Info("Dry run update")
+
+	if err = handler.sendEvent(eventPrepare, components, true); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// GetDryRunReport returns report of the last dry run update or nil if there was no dry run.
+func (handler *Handler) GetDryRunReport() (report *DryRunReport) {
+	handler.dryRunMutex.Lock()
+	defer handler.dryRunMutex.Unlock()
+
+	if handler.state.DryRunReport == nil {
+		return nil
+	}
+
+	report = &DryRunReport{
+		Time:       handler.state.DryRunReport.Time,
+		Error:      handler.state.DryRunReport.Error,
+		Components: append([]DryRunComponent(nil), handler.state.DryRunReport.Components...),
+	}
+
+	return report
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func isDryRun(event *fsm.Event) (dryRun bool) {
+	if len(event.Args) < 2 { //nolint:gomnd // Dry run flag follows update infos
+		return false
+	}
+
+	dryRun, _ = event.Args[1].(bool)
+
+	return dryRun
+}
+
+// finishDryRun reports and reverts dry run prepare. It is called under handler lock.
+func (handler *Handler) finishDryRun(prepareErr error) {
+	if handler.checkStopped() != nil {
+		log.Warn("Dry run is stopped")
+
+		return
+	}
+
+	handler.setDryRunReport(handler.newDryRunReport(prepareErr))
+
+	log.Info("Revert dry run update")
+
+	if err := handler.revertComponents(); err != nil {
+		log.Errorf("Can't revert dry run update: %s", aoserrors.Wrap(err))
+
+		handler.state.Error = err.Error()
+		handler.fsm.SetState(stateFailed)
+
+		return
+	}
+
+	for _, componentStatus := range handler.state.ComponentStatuses {
+		componentStatus.Status = umclient.StatusInstalled
+		componentStatus.Error = ""
+	}
+
+	handler.state.Error = ""
+	handler.fsm.SetState(stateIdle)
+}
+
+func (handler *Handler) newDryRunReport(prepareErr error) (report *DryRunReport) {
+	report = &DryRunReport{Time: handler.clock.Now(), Components: make([]DryRunComponent, 0)}
+
+	if prepareErr != nil {
+		report.Error = prepareErr.Error()
+	}
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		component := handler.newDryRunComponent(id)
+
+		component.VendorVersion = componentStatus.VendorVersion
+		component.AosVersion = componentStatus.AosVersion
+
+		if componentStatus.Status == umclient.StatusError {
+			component.Error = componentStatus.Error
+		}
+
+		report.Components = append(report.Components, component)
+	}
+
+	for id, skippedStatus := range handler.state.SkippedComponents {
+		component := handler.newDryRunComponent(id)
+
+		component.Skipped = true
+		component.Error = skippedStatus.Error
+
+		report.Components = append(report.Components, component)
+	}
+
+	sort.Slice(report.Components, func(i, j int) bool { return report.Components[i].ID < report.Components[j].ID })
+
+	return report
+}
+
+func (handler *Handler) newDryRunComponent(id string) (component DryRunComponent) {
+	component = DryRunComponent{ID: id}
+
+	if installedStatus, ok := handler.componentStatuses[id]; ok {
+		component.CurrentVendorVersion = installedStatus.VendorVersion
+		component.CurrentAosVersion = installedStatus.AosVersion
+	}
+
+	return component
+}
+
+func (handler *Handler) setDryRunReport(report *DryRunReport) {
+	handler.dryRunMutex.Lock()
+	defer handler.dryRunMutex.Unlock()
+
+	handler.state.DryRunReport = report
+}
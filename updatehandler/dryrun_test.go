@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDryRun(t *testing.T) {
+	components = map[string]*testModule{
+		"id1": {id: "id1", vendorVersion: "1.0"},
+		"id2": {id: "id2", vendorVersion: "1.0"},
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", UpdatePriority: 10}, {ID: "id2", Plugin: "testmodule"},
+		},
+	}, withModules(components))
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", VendorVersion: "1.0", Status: umclient.StatusInstalled},
+			{ID: "id2", VendorVersion: "1.0", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	if handler.GetDryRunReport() != nil {
+		t.Error("Unexpected dry run report")
+	}
+
+	infos, err := createUpdateInfos(currentStatus.Components, "2.0")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Component which already has required version fails prepare
+	infos[1].VendorVersion = "1.0"
+
+	// Dry run prepares and reverts components, failures are reported by dry run report only
+
+	order = nil
+
+	testOperation(t, handler, func() {
+		if err := handler.DryRunUpdate(infos); err != nil {
+			t.Errorf("Can't dry run update: %s", err)
+		}
+	}, &currentStatus, map[string][]string{"id1": {opPrepare, opRevert}, "id2": {opRevert}}, nil)
+
+	report := handler.GetDryRunReport()
+	if report == nil {
+		t.Fatal("Dry run report expected")
+	}
+
+	const prepareErr = "component already has required vendor version: 1.0"
+
+	if !strings.Contains(report.Error, prepareErr) {
+		t.Errorf("Wrong dry run error: %s", report.Error)
+	}
+
+	for i := range report.Components {
+		if strings.Contains(report.Components[i].Error, prepareErr) {
+			report.Components[i].Error = prepareErr
+		}
+	}
+
+	if expected := []updatehandler.DryRunComponent{
+		{ID: "id1", CurrentVendorVersion: "1.0", VendorVersion: "2.0", AosVersion: 1},
+		{ID: "id2", CurrentVendorVersion: "1.0", VendorVersion: "1.0", AosVersion: 1, Error: prepareErr},
+	}; !reflect.DeepEqual(report.Components, expected) {
+		t.Errorf("Wrong dry run components: %+v", report.Components)
+	}
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Emergency stop is requested without handler lock as it should not wait for running operation. It cancels
+// downloads, blocker waiting and context aware module operations immediately. Legacy module operations are not
+// interrupted inside the module: each next component operation and reboot fails with emergency stop error. Once
+// running operation is finished, the handler is quarantined: quarantine is persistent, all update requests are
+// rejected and failed state is reported till the operator releases it. After release the handler continues from the
+// state it was frozen in.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	emergencyStopMsg = "emergency stop"
+	quarantinedMsg   = "quarantined by emergency stop"
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// EmergencyStop halts all update activity and quarantines update handler.
+func (handler *Handler) EmergencyStop() {
+	log.Warn("Emergency stop")
+
+	handler.stopMutex.Lock()
+
+	handler.quarantined = true
+	handler.stopCancel()
+
+	handler.stopMutex.Unlock()
+
+	go handler.quarantine()
+}
+
+// ReleaseQuarantine releases update handler quarantined by emergency stop.
+func (handler *Handler) ReleaseQuarantine() (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if !handler.isQuarantined() {
+		return aoserrors.New("update handler is not quarantined")
+	}
+
+	log.Warn("Release quarantine")
+
+	handler.stopMutex.Lock()
+
+	handler.quarantined = false
+	handler.stopCtx, handler.stopCancel = context.WithCancel(context.Background())
+
+	handler.stopMutex.Unlock()
+
+	handler.state.Quarantined = false
+
+	if handler.state.RevertDeadline != nil {
+		handler.scheduleCommit()
+	}
+
+	if handler.state.ScheduledEvent != nil {
+		handler.startEventTimer()
+	}
+
+	if err = handler.saveState(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	handler.sendStatus()
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) initQuarantine() {
+	handler.stopCtx, handler.stopCancel = context.WithCancel(context.Background())
+
+	if !handler.state.Quarantined {
+		return
+	}
+
+	log.Warn("Update handler is quarantined")
+
+	handler.quarantined = true
+	handler.stopCancel()
+}
+
+// quarantine freezes update handler once running operation is finished.
+func (handler *Handler) quarantine() {
+	handler.Lock()
+	defer handler.Unlock()
+
+	// Quarantine may be already released or handler closed
+	if !handler.isQuarantined() || handler.isClosed() || handler.state.Quarantined {
+		return
+	}
+
+	log.Warn("Quarantine update handler")
+
+	handler.state.Quarantined = true
+
+	// Revert window is frozen as well, it is rescheduled on release
+	if handler.commitTimer != nil {
+		handler.commitTimer.Stop()
+		handler.commitTimer = nil
+	}
+
+	handler.stopEventTimer()
+
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
+
+	if err := handler.exportState(); err != nil {
+		log.Errorf("Can't export update state: %s", aoserrors.Wrap(err))
+	}
+
+	handler.sendStatus()
+}
+
+func (handler *Handler) isQuarantined() (quarantined bool) {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	return handler.quarantined
+}
+
+// checkStopped is called at safe points between module operations.
+func (handler *Handler) checkStopped() (err error) {
+	if handler.isQuarantined() {
+		return aoserrors.New(emergencyStopMsg)
+	}
+
+	if handler.isClosed() {
+		return aoserrors.New(closedMsg)
+	}
+
+	if handler.isCanceled() {
+		return aoserrors.New(updateCanceledMsg)
+	}
+
+	return nil
+}
+
+// stopContext returns context which is canceled by emergency stop.
+func (handler *Handler) stopContext() (ctx context.Context) {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	return handler.stopCtx
+}
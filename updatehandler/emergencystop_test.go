@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestEmergencyStop(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+	storage := newTestStorage()
+	moduleCfg := &config.Config{
+		DownloadDir:   cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}
+
+	handler := newTestHandler(t, moduleCfg, withStorage(storage), withModules(components))
+	defer func() { handler.Close(context.Background()) }()
+
+	handler.SetUpdateBlocker(func() (reason string, err error) {
+		return "diagnostic session is active", nil
+	})
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	preparedStatus := currentStatus
+	preparedStatus.State = umclient.StatePrepared
+	preparedStatus.Components = append(preparedStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &preparedStatus, nil, nil)
+
+	deferredStatus := preparedStatus
+	deferredStatus.Error = "update deferred: diagnostic session is active"
+
+	testOperation(t, handler, handler.StartUpdate, &deferredStatus, nil, nil)
+
+	// Stop interrupts deferred update before module is updated
+
+	order = nil
+
+	stoppedStatus := currentStatus
+	stoppedStatus.State = umclient.StateFailed
+	stoppedStatus.Error = "quarantined by emergency stop"
+	stoppedStatus.Components = append(stoppedStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusError, Error: "emergency stop",
+	})
+
+	// Status is sent when the handler is quarantined and when the update is failed in any order
+	testOperation(t, handler, handler.EmergencyStop, nil, nil, nil)
+	testOperation(t, handler, func() {}, &stoppedStatus, map[string][]string{"id1": nil}, nil)
+
+	// Update requests are rejected while quarantined, quarantine is persistent
+
+	handler.RevertUpdate()
+
+	if err := checkComponentOps(map[string][]string{"id1": nil}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	handler.Close(context.Background())
+
+	handler = newTestHandler(t, moduleCfg, withStorage(storage), withModules(components))
+
+	testOperation(t, handler, handler.Registered, &stoppedStatus, nil, nil)
+
+	order = nil
+
+	// Handler continues from frozen state after release
+
+	failedStatus := stoppedStatus
+	failedStatus.Error = "emergency stop"
+
+	testOperation(t, handler, func() {
+		if err := handler.ReleaseQuarantine(); err != nil {
+			t.Errorf("Can't release quarantine: %s", err)
+		}
+	}, &failedStatus, nil, nil)
+
+	if err = handler.ReleaseQuarantine(); err == nil {
+		t.Error("Error expected when handler is not quarantined")
+	}
+
+	testOperation(t, handler, handler.RevertUpdate, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusError, Error: "emergency stop"},
+		},
+	}, map[string][]string{"id1": {opRevert}}, nil)
+}
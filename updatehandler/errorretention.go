@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Errored component statuses of finished update are reported in idle state till next update is prepared. If error
+// retention is configured, they are cleared when retention expires. Errors can be also acknowledged explicitly once
+// the backend records the failure.
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// AcknowledgeErrors clears errored statuses of specified components or of all components if ids are not specified.
+func (handler *Handler) AcknowledgeErrors(ids []string) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if handler.state.UpdateState != stateIdle {
+		return aoserrors.Errorf("errors can't be acknowledged in %s state", handler.state.UpdateState)
+	}
+
+	log.WithField("ids", ids).Info("Acknowledge component errors")
+
+	if !handler.clearErrors(ids) {
+		return nil
+	}
+
+	if err = handler.saveState(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = handler.exportState(); err != nil {
+		log.Errorf("Can't export update state: %s", aoserrors.Wrap(err))
+	}
+
+	handler.sendStatus()
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) startErrorRetention() {
+	if handler.errorRetention == 0 || len(handler.state.ComponentStatuses) == 0 {
+		return
+	}
+
+	deadline := handler.clock.Now().Add(handler.errorRetention)
+
+	log.WithField("deadline", deadline).Debug("Start error retention")
+
+	handler.state.ErrorDeadline = &deadline
+
+	handler.scheduleErrorsClear()
+}
+
+func (handler *Handler) initErrorRetention() {
+	if handler.state.ErrorDeadline == nil {
+		return
+	}
+
+	if handler.clock.Now().Before(*handler.state.ErrorDeadline) {
+		handler.scheduleErrorsClear()
+
+		return
+	}
+
+	handler.clearErrors(nil)
+
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
+}
+
+func (handler *Handler) scheduleErrorsClear() {
+	if handler.errorTimer != nil {
+		handler.errorTimer.Stop()
+	}
+
+	handler.errorTimer = handler.clock.AfterFunc(handler.state.ErrorDeadline.Sub(handler.clock.Now()),
+		handler.onErrorRetentionExpired)
+}
+
+func (handler *Handler) onErrorRetentionExpired() {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if handler.isClosed() || handler.state.ErrorDeadline == nil || handler.state.UpdateState != stateIdle {
+		return
+	}
+
+	log.Info("Error retention expired, clear component errors")
+
+	handler.clearErrors(nil)
+
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
+
+	if err := handler.exportState(); err != nil {
+		log.Errorf("Can't export update state: %s", aoserrors.Wrap(err))
+	}
+
+	handler.sendStatus()
+}
+
+// clearErrors removes errored statuses of specified or all components. Error retention is stopped when no errors
+// left.
+func (handler *Handler) clearErrors(ids []string) (cleared bool) {
+	clearIDs := make(map[string]bool)
+
+	for _, id := range ids {
+		clearIDs[id] = true
+	}
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		if componentStatus.Status != umclient.StatusError || (len(clearIDs) != 0 && !clearIDs[id]) {
+			continue
+		}
+
+		delete(handler.state.ComponentStatuses, id)
+
+		cleared = true
+	}
+
+	if len(handler.state.ComponentStatuses) == 0 {
+		handler.stopErrorRetention()
+	}
+
+	return cleared
+}
+
+func (handler *Handler) stopErrorRetention() {
+	if handler.errorTimer != nil {
+		handler.errorTimer.Stop()
+		handler.errorTimer = nil
+	}
+
+	handler.state.ErrorDeadline = nil
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestErrorRetention(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:    cfg.DownloadDir,
+		ErrorRetention: aostypes.Duration{Duration: time.Hour},
+		UpdateModules:  []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	if err := handler.AcknowledgeErrors(nil); err != nil {
+		t.Errorf("Can't acknowledge errors: %s", err)
+	}
+
+	failUpdate := func() {
+		t.Helper()
+
+		infos, err := createUpdateInfos(currentStatus.Components, "")
+		if err != nil {
+			t.Fatalf("Can't create update infos: %s", err)
+		}
+
+		testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, nil, nil, nil)
+
+		components["id1"].status = aoserrors.New("update error")
+
+		testOperation(t, handler, handler.StartUpdate, nil, nil, nil)
+
+		if err = handler.AcknowledgeErrors(nil); err == nil {
+			t.Error("Error expected in failed state")
+		}
+
+		testOperation(t, handler, handler.RevertUpdate, &umclient.Status{
+			State: umclient.StateIdle,
+			Components: []umclient.ComponentStatusInfo{
+				{ID: "id1", Status: umclient.StatusInstalled},
+				{ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusError, Error: "update error"},
+			},
+		}, nil, nil)
+	}
+
+	// Errors are cleared when retention expires
+
+	failUpdate()
+
+	testOperation(t, handler, func() { fakeClock.Advance(time.Hour) }, &currentStatus, nil, nil)
+
+	// Errors are cleared when acknowledged
+
+	failUpdate()
+
+	testOperation(t, handler, func() {
+		if err := handler.AcknowledgeErrors([]string{"id1"}); err != nil {
+			t.Errorf("Can't acknowledge errors: %s", err)
+		}
+	}, &currentStatus, nil, nil)
+}
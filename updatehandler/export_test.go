@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+// SetClock replaces handler time source. Used in tests only.
+func (handler *Handler) SetClock(clock clock.Clock) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	handler.clock = clock
+}
+
+// SetUpdateBlocker replaces configured update blockers by test one.
+func (handler *Handler) SetUpdateBlocker(blocker func() (reason string, err error)) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	handler.blockers = []updateBlocker{blocker}
+}
+
+// SetOperationStopTimeout sets time to wait for timed out operation to stop.
+func (handler *Handler) SetOperationStopTimeout(timeout time.Duration) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	handler.stopTimeout = timeout
+}
@@ -0,0 +1,321 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Downloaded image of component which is failed when update is finished is retained from download session in own
+// subdirectory of failed images dir together with manifest which describes the update and the failure, so the exact
+// failing artifact can be copied off the device. Image is hard linked if possible, otherwise it is copied. Failed
+// images are guarded by own lock, so they can be listed and purged while update is running.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const failedImageManifestName = "manifest.json"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// FailedImage retained image of failed component.
+type FailedImage struct {
+	Name          string    `json:"name"`
+	ID            string    `json:"id"`
+	VendorVersion string    `json:"vendorVersion,omitempty"`
+	AosVersion    uint64    `json:"aosVersion"`
+	URL           string    `json:"url"`
+	Sha256        string    `json:"sha256,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	Session       string    `json:"session,omitempty"`
+	Time          time.Time `json:"time"`
+	FileName      string    `json:"fileName"`
+	Size          int64     `json:"size"`
+	Path          string    `json:"path,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetFailedImages returns retained images of failed components sorted by retain time.
+func (handler *Handler) GetFailedImages() (images []FailedImage, err error) {
+	handler.failedImagesMutex.Lock()
+	defer handler.failedImagesMutex.Unlock()
+
+	if images, err = handler.readFailedImages(); err != nil {
+		return nil, err
+	}
+
+	for i := range images {
+		images[i].Path = filepath.Join(handler.failedImagesDir, images[i].Name, images[i].FileName)
+	}
+
+	return images, nil
+}
+
+// PurgeFailedImages removes retained images by name or all retained images if names are empty.
+func (handler *Handler) PurgeFailedImages(names []string) (err error) {
+	handler.failedImagesMutex.Lock()
+	defer handler.failedImagesMutex.Unlock()
+
+	if handler.failedImagesDir == "" {
+		return aoserrors.New("failed images retention is disabled")
+	}
+
+	if len(names) == 0 {
+		images, err := handler.readFailedImages()
+		if err != nil {
+			return err
+		}
+
+		for _, image := range images {
+			names = append(names, image.Name)
+		}
+	}
+
+	for _, name := range names {
+		if name == "" || filepath.Base(name) != name || name == ".." {
+			return aoserrors.Errorf("wrong failed image name %s", name)
+		}
+
+		dir := filepath.Join(handler.failedImagesDir, name)
+
+		if _, err = os.Stat(filepath.Join(dir, failedImageManifestName)); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return aoserrors.Errorf("failed image %s not found", name)
+			}
+
+			return aoserrors.Wrap(err)
+		}
+
+		log.WithField("name", name).Info("Purge failed image")
+
+		if err = os.RemoveAll(dir); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// retainFailedImages retains session images of failed components. It is called under handler lock before update
+// data is removed.
+func (handler *Handler) retainFailedImages() {
+	if handler.failedImagesDir == "" || handler.downloadDir == "" || handler.state.DownloadSession == "" {
+		return
+	}
+
+	handler.sessionMutex.Lock()
+	manifest, err := handler.readSessionManifest()
+	handler.sessionMutex.Unlock()
+
+	if err != nil {
+		log.Errorf("Can't read session manifest: %v", err)
+
+		return
+	}
+
+	handler.failedImagesMutex.Lock()
+	defer handler.failedImagesMutex.Unlock()
+
+	retained := false
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		if componentStatus.Status != umclient.StatusError {
+			continue
+		}
+
+		imageURL := handler.state.ImageURLs[id]
+
+		filePath := handler.getFailedImageFile(imageURL, manifest)
+		if filePath == "" {
+			log.WithField("id", id).Debug("Image of failed component is not downloaded")
+
+			continue
+		}
+
+		if err = handler.retainImage(componentStatus, imageURL, filePath); err != nil {
+			log.WithField("id", id).Errorf("Can't retain failed image: %v", err)
+		}
+
+		retained = true
+	}
+
+	if retained {
+		handler.removeExcessFailedImages()
+	}
+}
+
+// getFailedImageFile returns session image of URL, local images are not copied to session and retained from the
+// original path.
+func (handler *Handler) getFailedImageFile(imageURL string, manifest sessionManifest) (filePath string) {
+	for _, sessionImage := range manifest.Images {
+		if sessionImage.URL == imageURL {
+			return filepath.Join(handler.sessionDir(), sessionImage.FileName)
+		}
+	}
+
+	if urlVal, err := url.Parse(imageURL); err == nil && urlVal.Scheme == "file" {
+		return urlVal.Path
+	}
+
+	return ""
+}
+
+func (handler *Handler) retainImage(
+	componentStatus *umclient.ComponentStatusInfo, imageURL, filePath string,
+) (err error) {
+	fileName := filepath.Base(filePath)
+
+	now := handler.clock.Now()
+	image := FailedImage{
+		Name: fmt.Sprintf("%s-%d", url.PathEscape(componentStatus.ID), now.UnixNano()), ID: componentStatus.ID,
+		VendorVersion: componentStatus.VendorVersion, AosVersion: componentStatus.AosVersion, URL: imageURL,
+		Sha256: handler.state.ImageHashes[componentStatus.ID], Error: componentStatus.Error,
+		Session: handler.state.DownloadSession, Time: now, FileName: fileName,
+	}
+
+	log.WithFields(log.Fields{"id": image.ID, "name": image.Name}).Warn("Retain failed image")
+
+	dir := filepath.Join(handler.failedImagesDir, image.Name)
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	if err = linkOrCopyFile(filePath, filepath.Join(dir, fileName)); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(filepath.Join(dir, fileName))
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	image.Size = info.Size()
+
+	data, err := json.Marshal(image)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return writeFileAtomic(filepath.Join(dir, failedImageManifestName), data)
+}
+
+func (handler *Handler) removeExcessFailedImages() {
+	if handler.maxFailedImages == 0 {
+		return
+	}
+
+	images, err := handler.readFailedImages()
+	if err != nil {
+		log.Errorf("Can't read failed images: %v", err)
+
+		return
+	}
+
+	for len(images) > handler.maxFailedImages {
+		log.WithField("name", images[0].Name).Debug("Remove excess failed image")
+
+		if err = os.RemoveAll(filepath.Join(handler.failedImagesDir, images[0].Name)); err != nil {
+			log.Errorf("Can't remove failed image: %v", err)
+		}
+
+		images = images[1:]
+	}
+}
+
+func (handler *Handler) readFailedImages() (images []FailedImage, err error) {
+	images = make([]FailedImage, 0)
+
+	if handler.failedImagesDir == "" {
+		return images, nil
+	}
+
+	entries, err := os.ReadDir(handler.failedImagesDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return images, nil
+		}
+
+		return nil, aoserrors.Wrap(err)
+	}
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(handler.failedImagesDir, entry.Name(), failedImageManifestName))
+		if err != nil {
+			log.WithField("name", entry.Name()).Warnf("Can't read failed image manifest: %v", err)
+
+			continue
+		}
+
+		var image FailedImage
+
+		if err = json.Unmarshal(data, &image); err != nil {
+			log.WithField("name", entry.Name()).Warnf("Can't parse failed image manifest: %v", err)
+
+			continue
+		}
+
+		image.Name = entry.Name()
+		images = append(images, image)
+	}
+
+	sort.Slice(images, func(i, j int) bool { return images[i].Time.Before(images[j].Time) })
+
+	return images, nil
+}
+
+func checkFailedImages(dir string, maxImages int) (err error) {
+	if maxImages < 0 {
+		return aoserrors.Errorf("wrong max failed images: %d", maxImages)
+	}
+
+	if maxImages != 0 && dir == "" {
+		return aoserrors.New("failed images dir should be configured")
+	}
+
+	return nil
+}
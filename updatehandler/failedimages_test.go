@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestFailedImages(t *testing.T) {
+	failedImagesDir := filepath.Join(tmpDir, "failedImages")
+
+	defer os.RemoveAll(failedImagesDir)
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   cfg.DownloadDir,
+		FailedImages:  config.FailedImages{Dir: failedImagesDir, MaxImages: 1},
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}, {ID: "id2", Plugin: "testmodule"}},
+	})
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+		if err != nil {
+			t.Fatalf("Can't create update infos: %s", err)
+		}
+
+		handler.PrepareUpdate(infos)
+
+		if err = waitForState(handler, umclient.StatePrepared); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		components["id2"].status = aoserrors.New("update error")
+
+		handler.StartUpdate()
+
+		if err = waitForState(handler, umclient.StateFailed); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		handler.RevertUpdate()
+
+		if err = waitForState(handler, umclient.StateIdle); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		// Only image of failed component is retained, the oldest image is removed once max images is exceeded
+
+		images, err := handler.GetFailedImages()
+		if err != nil {
+			t.Fatalf("Can't get failed images: %s", err)
+		}
+
+		if len(images) != 1 {
+			t.Fatalf("Wrong failed images count: %d", len(images))
+		}
+
+		if images[0].ID != "id2" || images[0].AosVersion != 1 || images[0].URL != infos[1].URL ||
+			images[0].Sha256 != hex.EncodeToString(infos[1].Sha256) || images[0].Size != int64(infos[1].Size) ||
+			!strings.Contains(images[0].Error, "update error") {
+			t.Errorf("Wrong failed image: %+v", images[0])
+		}
+
+		retained, err := os.ReadFile(images[0].Path)
+		if err != nil {
+			t.Fatalf("Can't read failed image: %s", err)
+		}
+
+		original, err := os.ReadFile(strings.TrimPrefix(infos[1].URL, "file://"))
+		if err != nil {
+			t.Fatalf("Can't read image: %s", err)
+		}
+
+		if !bytes.Equal(retained, original) {
+			t.Error("Wrong failed image content")
+		}
+	}
+
+	if err := handler.PurgeFailedImages([]string{"unknown"}); err == nil {
+		t.Error("Error expected for unknown failed image")
+	}
+
+	if err := handler.PurgeFailedImages([]string{"../images"}); err == nil {
+		t.Error("Error expected for wrong failed image name")
+	}
+
+	if err := handler.PurgeFailedImages(nil); err != nil {
+		t.Errorf("Can't purge failed images: %s", err)
+	}
+
+	if images, err := handler.GetFailedImages(); err != nil || len(images) != 0 {
+		t.Errorf("Wrong failed images: %v, error: %v", images, err)
+	}
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import "time"
+
+// Failure injection is debug API used by QA to exercise revert and timeout paths on real hardware. API is built only
+// with failureinjection build tag, so production builds can't inject failures and ignore injected failures left in
+// update state. API is enabled only if token is configured and each request should provide it. Injected failure is
+// checked before module operation of the phase: it either fails the operation without calling the module or delays
+// the operation. Injected failures are kept in update state, so they survive reboots, till they are triggered count
+// times or cleared.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// InjectedFailure failure injected into module phase: prepare, update, apply, revert or reboot. If delay is set, the
+// operation is delayed otherwise it fails with error. Count is number of operations to inject the failure into,
+// zero count means till cleared.
+type InjectedFailure struct {
+	ID    string        `json:"id"`
+	Phase string        `json:"phase"`
+	Delay time.Duration `json:"delay,omitempty"`
+	Error string        `json:"error,omitempty"`
+	Count uint          `json:"count,omitempty"`
+}
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !failureinjection
+
+package updatehandler
+
+import "context"
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// injectFailure doesn't inject failures as failure injection API is not built.
+func (handler *Handler) injectFailure(ctx context.Context, id, phase string) (err error) {
+	return nil
+}
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build failureinjection
+
+package updatehandler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const defaultInjectedError = "injected failure"
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// InjectionTokenError failure injection request is rejected as failure injection is disabled or token is wrong.
+type InjectionTokenError struct {
+	Err error
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// NewInjectionTokenError marks error as failure injection token error.
+func NewInjectionTokenError(err error) (tokenErr error) {
+	if err == nil {
+		return nil
+	}
+
+	return aoserrors.Wrap(&InjectionTokenError{Err: err})
+}
+
+// IsInjectionTokenError returns true if failure injection request is rejected due to token.
+func IsInjectionTokenError(err error) (tokenErr bool) {
+	var injectionTokenErr *InjectionTokenError
+
+	return errors.As(err, &injectionTokenErr)
+}
+
+// Error returns error message.
+func (tokenErr *InjectionTokenError) Error() (message string) {
+	return tokenErr.Err.Error()
+}
+
+// Unwrap returns original error.
+func (tokenErr *InjectionTokenError) Unwrap() (err error) {
+	return tokenErr.Err
+}
+
+// InjectFailure injects failure into module phase.
+func (handler *Handler) InjectFailure(token string, failure InjectedFailure) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if err = handler.checkInjectionToken(token); err != nil {
+		return err
+	}
+
+	if _, ok := handler.components[failure.ID]; !ok {
+		return aoserrors.Errorf("component %s not found", failure.ID)
+	}
+
+	switch failure.Phase {
+	case eventPrepare, eventUpdate, eventApply, eventRevert, phaseReboot:
+
+	default:
+		return aoserrors.Errorf("wrong phase %s", failure.Phase)
+	}
+
+	if failure.Delay < 0 {
+		return aoserrors.Errorf("wrong delay %v", failure.Delay)
+	}
+
+	log.WithFields(log.Fields{
+		"id": failure.ID, "phase": failure.Phase, "delay": failure.Delay, "count": failure.Count,
+	}).Warn("Inject failure")
+
+	handler.failureMutex.Lock()
+	handler.state.InjectedFailures = append(handler.state.InjectedFailures, failure)
+	handler.failureMutex.Unlock()
+
+	return aoserrors.Wrap(handler.saveState())
+}
+
+// ClearInjectedFailures clears injected failures of component or all injected failures if ID is empty.
+func (handler *Handler) ClearInjectedFailures(token string, id string) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if err = handler.checkInjectionToken(token); err != nil {
+		return err
+	}
+
+	log.WithField("id", id).Warn("Clear injected failures")
+
+	handler.failureMutex.Lock()
+
+	failures := make([]InjectedFailure, 0, len(handler.state.InjectedFailures))
+
+	for _, failure := range handler.state.InjectedFailures {
+		if id != "" && failure.ID != id {
+			failures = append(failures, failure)
+		}
+	}
+
+	if len(failures) == 0 {
+		failures = nil
+	}
+
+	handler.state.InjectedFailures = failures
+
+	handler.failureMutex.Unlock()
+
+	return aoserrors.Wrap(handler.saveState())
+}
+
+// GetInjectedFailures returns active injected failures.
+func (handler *Handler) GetInjectedFailures(token string) (failures []InjectedFailure, err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if err = handler.checkInjectionToken(token); err != nil {
+		return nil, err
+	}
+
+	handler.failureMutex.Lock()
+	defer handler.failureMutex.Unlock()
+
+	return append([]InjectedFailure(nil), handler.state.InjectedFailures...), nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) checkInjectionToken(token string) (err error) {
+	if handler.injectionToken == "" {
+		return NewInjectionTokenError(aoserrors.New("failure injection is disabled"))
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(handler.injectionToken)) != 1 {
+		return NewInjectionTokenError(aoserrors.New("wrong failure injection token"))
+	}
+
+	return nil
+}
+
+// injectFailure is called before module operation of the phase. Consumed failures are saved with update state
+// after the operation.
+func (handler *Handler) injectFailure(ctx context.Context, id, phase string) (err error) {
+	failure, ok := handler.takeInjectedFailure(id, phase)
+	if !ok {
+		return nil
+	}
+
+	if failure.Delay == 0 {
+		log.WithFields(log.Fields{"id": id, "phase": phase}).Warn("Injected failure")
+
+		if failure.Error == "" {
+			return aoserrors.New(defaultInjectedError)
+		}
+
+		return aoserrors.New(failure.Error)
+	}
+
+	log.WithFields(log.Fields{"id": id, "phase": phase, "delay": failure.Delay}).Warn("Injected delay")
+
+	select {
+	case <-handler.clock.After(failure.Delay):
+		return nil
+
+	case <-ctx.Done():
+		if err = handler.checkStopped(); err != nil {
+			return err
+		}
+
+		if handler.operationContext().Err() != nil {
+			return aoserrors.New(emergencyStopMsg)
+		}
+
+		return aoserrors.Wrap(ctx.Err())
+	}
+}
+
+func (handler *Handler) takeInjectedFailure(id, phase string) (failure InjectedFailure, ok bool) {
+	handler.failureMutex.Lock()
+	defer handler.failureMutex.Unlock()
+
+	for i, item := range handler.state.InjectedFailures {
+		if item.ID != id || item.Phase != phase {
+			continue
+		}
+
+		switch item.Count {
+		case 0:
+
+		case 1:
+			handler.state.InjectedFailures = append(
+				handler.state.InjectedFailures[:i], handler.state.InjectedFailures[i+1:]...)
+
+			if len(handler.state.InjectedFailures) == 0 {
+				handler.state.InjectedFailures = nil
+			}
+
+		default:
+			handler.state.InjectedFailures[i].Count--
+		}
+
+		return item, true
+	}
+
+	return failure, false
+}
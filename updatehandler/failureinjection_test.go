@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build failureinjection
+
+package updatehandler_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestFailureInjection(t *testing.T) {
+	const token = "debug-token"
+
+	components = map[string]*testModule{"id1": {id: "id1"}}
+	storage := newTestStorage()
+	moduleCfg := &config.Config{
+		DownloadDir:      cfg.DownloadDir,
+		UpdateModules:    []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+		FailureInjection: config.FailureInjection{Token: token},
+	}
+
+	handler := newTestHandler(t, moduleCfg, withStorage(storage), withModules(components))
+	defer func() { handler.Close(context.Background()) }()
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	for _, failure := range []updatehandler.InjectedFailure{
+		{ID: "id2", Phase: "update"},
+		{ID: "id1", Phase: "install"},
+		{ID: "id1", Phase: "update", Delay: -time.Second},
+	} {
+		if err := handler.InjectFailure(token, failure); err == nil || updatehandler.IsInjectionTokenError(err) {
+			t.Errorf("Wrong error for failure: %v, error: %v", failure, err)
+		}
+	}
+
+	if err := handler.InjectFailure("wrong", updatehandler.InjectedFailure{ID: "id1", Phase: "update"}); err == nil ||
+		!updatehandler.IsInjectionTokenError(err) {
+		t.Errorf("Token error expected for wrong token: %v", err)
+	}
+
+	failures := []updatehandler.InjectedFailure{
+		{ID: "id1", Phase: "update", Error: "injected update error", Count: 1},
+		{ID: "id1", Phase: "revert", Delay: 100 * time.Millisecond, Count: 2},
+	}
+
+	for _, failure := range failures {
+		if err := handler.InjectFailure(token, failure); err != nil {
+			t.Fatalf("Can't inject failure: %s", err)
+		}
+	}
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	preparedStatus := currentStatus
+	preparedStatus.State = umclient.StatePrepared
+	preparedStatus.Components = append(preparedStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &preparedStatus, nil, nil)
+
+	// Module is not updated, failure is consumed
+
+	order = nil
+
+	failedStatus := currentStatus
+	failedStatus.State = umclient.StateFailed
+	failedStatus.Error = "injected update error"
+	failedStatus.Components = append(failedStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusError, Error: "injected update error",
+	})
+
+	testOperation(t, handler, handler.StartUpdate, &failedStatus, map[string][]string{"id1": nil}, nil)
+
+	// Injected failures are persistent
+
+	handler.Close(context.Background())
+
+	handler = newTestHandler(t, moduleCfg, withStorage(storage), withModules(components))
+
+	testOperation(t, handler, handler.Registered, &failedStatus, nil, nil)
+
+	activeFailures, err := handler.GetInjectedFailures(token)
+	if err != nil {
+		t.Fatalf("Can't get injected failures: %s", err)
+	}
+
+	if !reflect.DeepEqual(activeFailures, failures[1:]) {
+		t.Errorf("Wrong injected failures: %v", activeFailures)
+	}
+
+	// Revert is delayed
+
+	order = nil
+	startTime := time.Now()
+
+	testOperation(t, handler, handler.RevertUpdate, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusError, Error: "injected update error"},
+		},
+	}, map[string][]string{"id1": {opRevert}}, nil)
+
+	if time.Since(startTime) < failures[1].Delay {
+		t.Error("Revert should be delayed")
+	}
+
+	if err = handler.ClearInjectedFailures(token, "id1"); err != nil {
+		t.Fatalf("Can't clear injected failures: %s", err)
+	}
+
+	if activeFailures, err = handler.GetInjectedFailures(token); err != nil || len(activeFailures) != 0 {
+		t.Errorf("Wrong injected failures: %v, err: %v", activeFailures, err)
+	}
+}
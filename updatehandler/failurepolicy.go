@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Failure policy defines how update session handles component failure on prepare and update. With fail fast policy
+// (default) the first failure aborts the session: the handler goes to failed state and all components are reverted
+// by revert request. With best effort policy the session continues with components which don't depend on failed
+// ones: failed components and components depending on them are reverted at once, excluded from the session and
+// reported with their errors, the rest are updated and applied. The session is failed if no component is left or it
+// is interrupted by cancel or emergency stop. The policy is configured for all sessions and can be overridden by
+// failurePolicy update annotation which should be the same for all session components. The session policy is
+// resolved on prepare and persisted till the session is finished.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Failure policies.
+const (
+	FailurePolicyFailFast   = "failFast"
+	FailurePolicyBestEffort = "bestEffort"
+)
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkFailurePolicy(policy string) (err error) {
+	switch policy {
+	case "", FailurePolicyFailFast, FailurePolicyBestEffort:
+		return nil
+
+	default:
+		return aoserrors.Errorf("wrong failure policy %s", policy)
+	}
+}
+
+// getSessionFailurePolicy resolves failure policy of update session.
+func (handler *Handler) getSessionFailurePolicy(infos []umclient.ComponentUpdateInfo) (policy string, err error) {
+	policy = handler.failurePolicy
+	sessionPolicy := ""
+
+	for _, info := range infos {
+		infoPolicy := getUpdateAnnotations(info.Annotations).FailurePolicy
+		if infoPolicy == "" {
+			continue
+		}
+
+		if err = checkFailurePolicy(infoPolicy); err != nil {
+			return "", aoserrors.Errorf("component %s: %v", info.ID, err)
+		}
+
+		if sessionPolicy != "" && infoPolicy != sessionPolicy {
+			return "", aoserrors.Errorf("conflicting failure policies %s and %s", sessionPolicy, infoPolicy)
+		}
+
+		sessionPolicy = infoPolicy
+	}
+
+	if sessionPolicy != "" {
+		policy = sessionPolicy
+	}
+
+	if policy == "" {
+		return FailurePolicyFailFast, nil
+	}
+
+	return policy, nil
+}
+
+func (handler *Handler) isBestEffort() (bestEffort bool) {
+	return handler.state.FailurePolicy == FailurePolicyBestEffort
+}
+
+func (handler *Handler) isExcluded(id string) (excluded bool) {
+	for _, excludedID := range handler.state.ExcludedComponents {
+		if excludedID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// failedDependency returns failed dependency of the component in best effort session. Dependency operation is
+// finished before the component one is started, so its status is not changed concurrently.
+func (handler *Handler) failedDependency(phase string, dependencies []string) (dependency string) {
+	if !handler.isBestEffort() || phase == eventRevert {
+		return ""
+	}
+
+	for _, dependency := range dependencies {
+		if componentStatus, ok := handler.state.ComponentStatuses[dependency]; ok &&
+			componentStatus.Status == umclient.StatusError {
+			return dependency
+		}
+	}
+
+	return ""
+}
+
+// continueBestEffort checks if best effort session continues after phase failure and excludes failed components
+// from the session. It returns phase error if the session can't be continued. It is called under handler lock.
+func (handler *Handler) continueBestEffort(phase string, phaseErr error) (err error) {
+	if !handler.isBestEffort() || handler.checkStopped() != nil {
+		return phaseErr
+	}
+
+	var (
+		failed []*umclient.ComponentStatusInfo
+		left   int
+	)
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		if handler.isExcluded(id) {
+			continue
+		}
+
+		if componentStatus.Status != umclient.StatusError {
+			left++
+
+			continue
+		}
+
+		if isInterruptError(componentStatus.Error) {
+			return phaseErr
+		}
+
+		failed = append(failed, componentStatus)
+	}
+
+	if left == 0 {
+		return phaseErr
+	}
+
+	log.WithFields(log.Fields{"phase": phase, "failed": len(failed)}).Warnf(
+		"Continue best effort update: %v", phaseErr)
+
+	handler.excludeComponents(failed)
+
+	return nil
+}
+
+// excludeComponents reverts failed components and excludes them from the session. Component keeps error of the
+// failed operation even if revert fails.
+func (handler *Handler) excludeComponents(componentStatuses []*umclient.ComponentStatusInfo) {
+	failures := make(map[string]string)
+
+	for _, componentStatus := range componentStatuses {
+		failures[componentStatus.ID] = componentStatus.Error
+
+		handler.state.ExcludedComponents = append(handler.state.ExcludedComponents, componentStatus.ID)
+	}
+
+	if err := handler.statusesOperation(componentStatuses, eventRevert, revertComponent, false); err != nil {
+		log.Errorf("Can't revert failed components: %v", err)
+	}
+
+	for _, componentStatus := range componentStatuses {
+		componentStatus.Status = umclient.StatusError
+		componentStatus.Error = failures[componentStatus.ID]
+	}
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestBestEffortUpdate(t *testing.T) {
+	order = nil
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+			{ID: "id3", Plugin: "testmodule", Dependencies: []string{"id1"}},
+			{ID: "id4", Plugin: "testmodule"},
+		},
+	})
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+			{ID: "id3", Status: umclient.StatusInstalled},
+			{ID: "id4", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Conflicting session policies fail prepare
+
+	infos[0].Annotations = json.RawMessage(`{"failurePolicy":"bestEffort"}`)
+	infos[1].Annotations = json.RawMessage(`{"failurePolicy":"failFast"}`)
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) },
+		&umclient.Status{
+			State: umclient.StateFailed, Error: "conflicting failure policies", Components: currentStatus.Components,
+		}, nil, nil)
+
+	testOperation(t, handler, handler.RevertUpdate, &currentStatus, nil, nil)
+
+	for i := range infos {
+		infos[i].Annotations = json.RawMessage(`{"failurePolicy":"bestEffort"}`)
+	}
+
+	updateStatus := func(id string, status umclient.ComponentStatus, errStr string) umclient.ComponentStatusInfo {
+		return umclient.ComponentStatusInfo{ID: id, AosVersion: 1, Status: status, Error: errStr}
+	}
+
+	// Prepare: failed component and its dependent are reverted, independent components continue
+
+	components["id1"].status = aoserrors.New("prepare error")
+	order = nil
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(append([]umclient.ComponentStatusInfo{}, currentStatus.Components...),
+		updateStatus("id1", umclient.StatusError, "prepare error"),
+		updateStatus("id2", umclient.StatusInstalling, ""),
+		updateStatus("id3", umclient.StatusError, "dependency id1 failed"),
+		updateStatus("id4", umclient.StatusInstalling, ""))
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus,
+		map[string][]string{
+			"id1": {opPrepare, opRevert}, "id2": {opPrepare}, "id3": {opRevert}, "id4": {opPrepare},
+		}, nil)
+
+	// Update: failed component is reverted, the rest is updated
+
+	components["id4"].status = aoserrors.New("update error")
+	order = nil
+
+	newStatus.State = umclient.StateUpdated
+	newStatus.Components[len(newStatus.Components)-1] = updateStatus("id4", umclient.StatusError, "update error")
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus,
+		map[string][]string{"id1": nil, "id2": {opUpdate}, "id3": nil, "id4": {opUpdate, opRevert}}, nil)
+
+	// Apply: only updated component is applied, failed ones are reported individually
+
+	order = nil
+
+	finalStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			updateStatus("id1", umclient.StatusError, "prepare error"),
+			{ID: "id2", AosVersion: 1, Status: umclient.StatusInstalled},
+			{ID: "id3", Status: umclient.StatusInstalled},
+			updateStatus("id3", umclient.StatusError, "dependency id1 failed"),
+			{ID: "id4", Status: umclient.StatusInstalled},
+			updateStatus("id4", umclient.StatusError, "update error"),
+		},
+	}
+
+	testOperation(t, handler, handler.ApplyUpdate, &finalStatus,
+		map[string][]string{"id1": nil, "id2": {opApply}, "id3": nil, "id4": nil}, nil)
+
+	if findings := updatehandler.ValidateConfig(&config.Config{FailurePolicy: "wrong"}); len(findings) != 1 ||
+		!strings.Contains(findings[0].Message, "wrong failure policy") {
+		t.Errorf("Wrong config findings: %v", findings)
+	}
+}
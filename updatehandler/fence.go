@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Operation fence protects components from update manager instance which is not active anymore, e.g. primary
+// instance which was paused for longer than the lease TTL while the standby instance has taken over. The fence is
+// checked right before each module operation, reboot and maintenance action: fenced operation fails without calling
+// the module, so stale instance doesn't change components after takeover.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// OperationFence checks that the instance is still allowed to perform module operations.
+type OperationFence interface {
+	Check() (err error)
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// SetOperationFence sets fence checked before module operations, nil fence disables the check.
+func (handler *Handler) SetOperationFence(fence OperationFence) {
+	handler.fenceMutex.Lock()
+	defer handler.fenceMutex.Unlock()
+
+	handler.operationFence = fence
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) checkFence(id string) (err error) {
+	handler.fenceMutex.Lock()
+	fence := handler.operationFence
+	handler.fenceMutex.Unlock()
+
+	if fence == nil {
+		return nil
+	}
+
+	if err = fence.Check(); err != nil {
+		log.WithField("id", id).Errorf("Module operation is fenced: %v", err)
+
+		return aoserrors.Errorf("module operation is fenced: %v", err)
+	}
+
+	return nil
+}
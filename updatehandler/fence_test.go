@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testFence struct {
+	sync.Mutex
+	err error
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestOperationFence(t *testing.T) {
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	})
+
+	fence := &testFence{}
+
+	handler.SetOperationFence(fence)
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	// Module operations are not performed once the lease is taken by other instance
+
+	fence.set(aoserrors.New("lease is taken by other instance"))
+
+	resetOrder()
+
+	handler.StartUpdate()
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Error("Wait for status timeout")
+
+	case status := <-handler.StatusChannel():
+		if status.State != umclient.StateFailed || !strings.Contains(status.Error, "module operation is fenced") {
+			t.Errorf("Wrong status: %s, error: %s", status.State, status.Error)
+		}
+	}
+
+	if err = checkComponentOps(map[string][]string{"id1": nil, "id2": nil}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * testFence
+ **********************************************************************************************************************/
+
+func (fence *testFence) Check() (err error) {
+	fence.Lock()
+	defer fence.Unlock()
+
+	return fence.err
+}
+
+func (fence *testFence) set(err error) {
+	fence.Lock()
+	defer fence.Unlock()
+
+	fence.err = err
+}
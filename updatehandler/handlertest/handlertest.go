@@ -0,0 +1,500 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package handlertest provides helpers to test update modules against real update handler state machine: in-memory
+// storage, scriptable fake modules and status assertions.
+package handlertest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Module operations.
+const (
+	OpInit    = "init"
+	OpPrepare = "prepare"
+	OpUpdate  = "update"
+	OpApply   = "apply"
+	OpRevert  = "revert"
+	OpReboot  = "reboot"
+)
+
+const (
+	statusWaitTimeout = 5 * time.Second
+	imageSize         = 1024
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Storage in-memory update handler and module storage.
+type Storage struct {
+	sync.Mutex
+	updateState    []byte
+	aosVersions    map[string]uint64
+	moduleStates   map[string][]byte
+	journalEntries map[string][]opjournal.Entry
+}
+
+// Op performed module operation.
+type Op struct {
+	ID string
+	Op string
+}
+
+// Modules fake modules created by fake plugin.
+type Modules struct {
+	sync.Mutex
+	modules map[string]*Module
+	ops     []Op
+}
+
+// Module scriptable fake update module.
+type Module struct {
+	sync.Mutex
+	id                string
+	modules           *Modules
+	vendorVersion     string
+	preparedVersion   string
+	previousVersion   string
+	errors            map[string]error
+	rebootRequired    map[string]bool
+	preparedImagePath string
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// NewStorage creates in-memory storage.
+func NewStorage() (storage *Storage) {
+	return &Storage{
+		aosVersions:    make(map[string]uint64),
+		moduleStates:   make(map[string][]byte),
+		journalEntries: make(map[string][]opjournal.Entry),
+	}
+}
+
+// SetUpdateState sets update handler state.
+func (storage *Storage) SetUpdateState(state []byte) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.updateState = state
+
+	return nil
+}
+
+// GetUpdateState returns update handler state.
+func (storage *Storage) GetUpdateState() (state []byte, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	return storage.updateState, nil
+}
+
+// SetAosVersion sets component Aos version.
+func (storage *Storage) SetAosVersion(id string, version uint64) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.aosVersions[id] = version
+
+	return nil
+}
+
+// GetAosVersion returns component Aos version.
+func (storage *Storage) GetAosVersion(id string) (version uint64, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	return storage.aosVersions[id], nil
+}
+
+// SetModuleState sets module state.
+func (storage *Storage) SetModuleState(id string, state []byte) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.moduleStates[id] = state
+
+	return nil
+}
+
+// GetModuleState returns module state.
+func (storage *Storage) GetModuleState(id string) (state []byte, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	return storage.moduleStates[id], nil
+}
+
+// AddJournalEntry adds module operation journal entry.
+func (storage *Storage) AddJournalEntry(id string, entry opjournal.Entry) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.journalEntries[id] = append(storage.journalEntries[id], entry)
+
+	return nil
+}
+
+// GetJournalEntries returns module operation journal entries.
+func (storage *Storage) GetJournalEntries(id string) (entries []opjournal.Entry, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	return storage.journalEntries[id], nil
+}
+
+// RegisterPlugin registers update handler plugin which creates fake modules.
+func RegisterPlugin(plugin string) (modules *Modules) {
+	modules = &Modules{modules: make(map[string]*Module)}
+
+	updatehandler.RegisterPlugin(plugin,
+		func(id string, configJSON json.RawMessage,
+			storage updatehandler.ModuleStorage,
+		) (module updatehandler.UpdateModule, err error) {
+			return modules.Get(id), nil
+		})
+
+	return modules
+}
+
+// Get returns fake module. The module is created if it doesn't exist, so it can be scripted before update handler
+// is created.
+func (modules *Modules) Get(id string) (module *Module) {
+	modules.Lock()
+	defer modules.Unlock()
+
+	if module, ok := modules.modules[id]; ok {
+		return module
+	}
+
+	module = &Module{
+		id: id, modules: modules, errors: make(map[string]error), rebootRequired: make(map[string]bool),
+	}
+
+	modules.modules[id] = module
+
+	return module
+}
+
+// Ops returns all performed module operations in order.
+func (modules *Modules) Ops() (ops []Op) {
+	modules.Lock()
+	defer modules.Unlock()
+
+	return append([]Op(nil), modules.ops...)
+}
+
+// ModuleOps returns performed operations of specified module.
+func (modules *Modules) ModuleOps(id string) (ops []string) {
+	modules.Lock()
+	defer modules.Unlock()
+
+	for _, op := range modules.ops {
+		if op.ID == id {
+			ops = append(ops, op.Op)
+		}
+	}
+
+	return ops
+}
+
+// ResetOps clears performed operations.
+func (modules *Modules) ResetOps() {
+	modules.Lock()
+	defer modules.Unlock()
+
+	modules.ops = nil
+}
+
+// SetVendorVersion sets module vendor version.
+func (module *Module) SetVendorVersion(version string) {
+	module.Lock()
+	defer module.Unlock()
+
+	module.vendorVersion = version
+}
+
+// FailOn makes next module operation fail with specified error.
+func (module *Module) FailOn(op string, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	module.errors[op] = err
+}
+
+// RequireReboot makes next module operation request reboot.
+func (module *Module) RequireReboot(op string) {
+	module.Lock()
+	defer module.Unlock()
+
+	module.rebootRequired[op] = true
+}
+
+// PreparedImagePath returns image path passed to last prepare.
+func (module *Module) PreparedImagePath() (imagePath string) {
+	module.Lock()
+	defer module.Unlock()
+
+	return module.preparedImagePath
+}
+
+// GetID returns module ID.
+func (module *Module) GetID() (id string) {
+	return module.id
+}
+
+// GetVendorVersion returns module vendor version.
+func (module *Module) GetVendorVersion() (version string, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	return module.vendorVersion, nil
+}
+
+// Init initializes module.
+func (module *Module) Init() (err error) {
+	_, err = module.doOperation(OpInit)
+
+	return err
+}
+
+// Prepare prepares module.
+func (module *Module) Prepare(
+	ctx context.Context, imagePath string, vendorVersion string, annotations json.RawMessage,
+) (err error) {
+	if _, err = module.doOperation(OpPrepare); err != nil {
+		return err
+	}
+
+	module.Lock()
+	defer module.Unlock()
+
+	module.preparedImagePath = imagePath
+	module.preparedVersion = vendorVersion
+
+	return nil
+}
+
+// Update updates module. Vendor version passed to prepare becomes current module version.
+func (module *Module) Update(ctx context.Context) (rebootRequired bool, err error) {
+	if rebootRequired, err = module.doOperation(OpUpdate); err != nil || rebootRequired {
+		return rebootRequired, err
+	}
+
+	module.Lock()
+	defer module.Unlock()
+
+	if module.preparedVersion != "" {
+		module.previousVersion = module.vendorVersion
+		module.vendorVersion = module.preparedVersion
+		module.preparedVersion = ""
+	}
+
+	return false, nil
+}
+
+// Apply applies update.
+func (module *Module) Apply(ctx context.Context) (rebootRequired bool, err error) {
+	if rebootRequired, err = module.doOperation(OpApply); err != nil || rebootRequired {
+		return rebootRequired, err
+	}
+
+	module.Lock()
+	defer module.Unlock()
+
+	module.previousVersion = ""
+
+	return false, nil
+}
+
+// Revert reverts update. Module vendor version is restored.
+func (module *Module) Revert(ctx context.Context) (rebootRequired bool, err error) {
+	if rebootRequired, err = module.doOperation(OpRevert); err != nil || rebootRequired {
+		return rebootRequired, err
+	}
+
+	module.Lock()
+	defer module.Unlock()
+
+	if module.previousVersion != "" {
+		module.vendorVersion = module.previousVersion
+		module.previousVersion = ""
+	}
+
+	module.preparedVersion = ""
+
+	return false, nil
+}
+
+// Reboot reboots module.
+func (module *Module) Reboot(ctx context.Context) (err error) {
+	_, err = module.doOperation(OpReboot)
+
+	return err
+}
+
+// Close closes module.
+func (module *Module) Close(ctx context.Context) (err error) {
+	return nil
+}
+
+// WaitForStatus waits for next update handler status and checks it.
+func WaitForStatus(handler *updatehandler.Handler, expectedStatus *umclient.Status) (err error) {
+	select {
+	case <-time.After(statusWaitTimeout):
+		return aoserrors.New("wait status timeout")
+
+	case currentStatus := <-handler.StatusChannel():
+		if expectedStatus == nil {
+			return nil
+		}
+
+		return CheckStatus(currentStatus, *expectedStatus)
+	}
+}
+
+// CheckStatus checks that status matches expected one. Components order is ignored and errors are matched by
+// substring.
+func CheckStatus(currentStatus, expectedStatus umclient.Status) (err error) {
+	if currentStatus.State != expectedStatus.State {
+		return aoserrors.Errorf("wrong current state: %s", currentStatus.State)
+	}
+
+	if !strings.Contains(currentStatus.Error, expectedStatus.Error) {
+		return aoserrors.Errorf("wrong error value: %s", currentStatus.Error)
+	}
+
+	components := append([]umclient.ComponentStatusInfo(nil), currentStatus.Components...)
+
+	for _, expectedItem := range expectedStatus.Components {
+		index := len(components)
+
+		for i, currentItem := range components {
+			if currentItem.ID == expectedItem.ID &&
+				currentItem.VendorVersion == expectedItem.VendorVersion &&
+				currentItem.AosVersion == expectedItem.AosVersion &&
+				currentItem.Status == expectedItem.Status &&
+				strings.Contains(currentItem.Error, expectedItem.Error) {
+				index = i
+				break
+			}
+		}
+
+		if index == len(components) {
+			return aoserrors.Errorf("expected item not found: %v", expectedItem)
+		}
+
+		components = append(components[:index], components[index+1:]...)
+	}
+
+	if len(components) != 0 {
+		return aoserrors.Errorf("unexpected item found: %v", components[0])
+	}
+
+	return nil
+}
+
+// CreateUpdateInfos creates update images in dir and returns update infos which increment Aos version of
+// specified components.
+func CreateUpdateInfos(
+	dir string, components []umclient.ComponentStatusInfo, vendorVersion string,
+) (infos []umclient.ComponentUpdateInfo, err error) {
+	for _, component := range components {
+		imagePath := filepath.Join(dir, fmt.Sprintf("image_%s.bin", component.ID))
+
+		fileInfo, err := createImage(imagePath)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, umclient.ComponentUpdateInfo{
+			ID:            component.ID,
+			AosVersion:    component.AosVersion + 1,
+			VendorVersion: vendorVersion,
+			URL:           "file://" + imagePath,
+			Sha256:        fileInfo.Sha256,
+			Sha512:        fileInfo.Sha512,
+			Size:          fileInfo.Size,
+		})
+	}
+
+	return infos, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (module *Module) doOperation(op string) (rebootRequired bool, err error) {
+	module.Lock()
+
+	rebootRequired = module.rebootRequired[op]
+	err = module.errors[op]
+
+	delete(module.rebootRequired, op)
+	delete(module.errors, op)
+
+	module.Unlock()
+
+	module.modules.Lock()
+	module.modules.ops = append(module.modules.ops, Op{ID: module.id, Op: op})
+	module.modules.Unlock()
+
+	return rebootRequired, err
+}
+
+func createImage(imagePath string) (fileInfo image.FileInfo, err error) {
+	data := make([]byte, imageSize)
+
+	if _, err = rand.Read(data); err != nil {
+		return fileInfo, aoserrors.Wrap(err)
+	}
+
+	if err = os.WriteFile(imagePath, data, 0o600); err != nil {
+		return fileInfo, aoserrors.Wrap(err)
+	}
+
+	if fileInfo, err = image.CreateFileInfo(context.Background(), imagePath); err != nil {
+		return fileInfo, aoserrors.Wrap(err)
+	}
+
+	return fileInfo, nil
+}
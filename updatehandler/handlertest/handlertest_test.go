@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlertest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/updatehandler/handlertest"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	tmpDir, err = os.MkdirTemp("", "um_")
+	if err != nil {
+		log.Fatalf("Error create temporary dir: %s", err)
+	}
+
+	ret := m.Run()
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Fatalf("Error removing tmp dir: %s", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestUpdate(t *testing.T) {
+	modules := handlertest.RegisterPlugin("fakemodule")
+
+	modules.Get("id1").SetVendorVersion("1.0")
+
+	handler := newHandler(t, "id1")
+	defer handler.Close(context.Background())
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", VendorVersion: "1.0", Status: umclient.StatusInstalled},
+		},
+	}
+
+	handler.Registered()
+	waitForStatus(t, handler, &currentStatus)
+
+	infos, err := handlertest.CreateUpdateInfos(tmpDir, currentStatus.Components, "2.0")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %v", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", VendorVersion: "2.0", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+
+	handler.PrepareUpdate(infos)
+	waitForStatus(t, handler, &newStatus)
+
+	if modules.Get("id1").PreparedImagePath() == "" {
+		t.Error("Image path should be passed to prepare")
+	}
+
+	newStatus.State = umclient.StateUpdated
+
+	handler.StartUpdate()
+	waitForStatus(t, handler, &newStatus)
+
+	modules.Get("id1").RequireReboot(handlertest.OpApply)
+
+	handler.ApplyUpdate()
+	waitForStatus(t, handler, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", VendorVersion: "2.0", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalled},
+		},
+	})
+
+	if ops := modules.ModuleOps("id1"); !reflect.DeepEqual(ops, []string{
+		handlertest.OpInit, handlertest.OpPrepare, handlertest.OpUpdate,
+		handlertest.OpApply, handlertest.OpReboot, handlertest.OpApply,
+	}) {
+		t.Errorf("Wrong module ops: %v", ops)
+	}
+}
+
+func TestFailedUpdate(t *testing.T) {
+	modules := handlertest.RegisterPlugin("fakemodule")
+
+	handler := newHandler(t, "id1", "id2")
+	defer handler.Close(context.Background())
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	handler.Registered()
+	waitForStatus(t, handler, &currentStatus)
+
+	infos, err := handlertest.CreateUpdateInfos(tmpDir, currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %v", err)
+	}
+
+	handler.PrepareUpdate(infos)
+	waitForStatus(t, handler, nil)
+
+	failedErr := aoserrors.New("update error")
+
+	modules.Get("id2").FailOn(handlertest.OpUpdate, failedErr)
+	modules.ResetOps()
+
+	handler.StartUpdate()
+	waitForStatus(t, handler, &umclient.Status{
+		State: umclient.StateFailed,
+		Error: failedErr.Error(),
+		Components: append(currentStatus.Components, []umclient.ComponentStatusInfo{
+			{ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling},
+			{ID: "id2", AosVersion: infos[1].AosVersion, Status: umclient.StatusError, Error: failedErr.Error()},
+		}...),
+	})
+
+	handler.RevertUpdate()
+	waitForStatus(t, handler, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: append(currentStatus.Components, umclient.ComponentStatusInfo{
+			ID: "id2", AosVersion: infos[1].AosVersion, Status: umclient.StatusError, Error: failedErr.Error(),
+		}),
+	})
+
+	if ops := modules.ModuleOps("id2"); !reflect.DeepEqual(ops, []string{handlertest.OpUpdate, handlertest.OpRevert}) {
+		t.Errorf("Wrong module ops: %v", ops)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newHandler(t *testing.T, ids ...string) (handler *updatehandler.Handler) {
+	t.Helper()
+
+	cfg := &config.Config{DownloadDir: filepath.Join(tmpDir, "download")}
+
+	for _, id := range ids {
+		cfg.UpdateModules = append(cfg.UpdateModules, config.ModuleConfig{ID: id, Plugin: "fakemodule"})
+	}
+
+	storage := handlertest.NewStorage()
+
+	handler, err := updatehandler.New(cfg, storage, storage)
+	if err != nil {
+		t.Fatalf("Can't create update handler: %v", err)
+	}
+
+	return handler
+}
+
+func waitForStatus(t *testing.T, handler *updatehandler.Handler, expectedStatus *umclient.Status) {
+	t.Helper()
+
+	if err := handlertest.WaitForStatus(handler, expectedStatus); err != nil {
+		t.Errorf("Wait for status failed: %v", err)
+	}
+}
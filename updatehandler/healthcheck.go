@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Health checks run when apply is requested, before components are applied: once a module applies the update, it is
+// committed and can't be reverted, so the updated system is checked while revert is still possible. Checks are
+// repeated each poll interval till the health window expires. If any check fails, components are reverted instead
+// of apply, the handler returns to idle state with health error reported for the components. Window deadline is
+// persisted, so the window is not restarted by a reboot, and the health decision is kept in handler state.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	defaultHealthPollInterval = 10 * time.Second
+	defaultHealthCheckTimeout = 30 * time.Second
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// HealthDecision health check decision of the last update.
+type HealthDecision struct {
+	Time     time.Time `json:"time"`
+	Reverted bool      `json:"reverted"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) (err error)
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetHealthDecision returns health check decision of the last update or nil if health was not checked.
+func (handler *Handler) GetHealthDecision() (decision *HealthDecision) {
+	handler.healthMutex.Lock()
+	defer handler.healthMutex.Unlock()
+
+	if handler.state.HealthDecision == nil {
+		return nil
+	}
+
+	decisionCopy := *handler.state.HealthDecision
+
+	return &decisionCopy
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newHealthChecks(cfg config.HealthChecks) (checks []healthCheck, err error) {
+	if cfg.Window.Duration < 0 || cfg.PollInterval.Duration < 0 {
+		return nil, aoserrors.New("wrong health window or poll interval")
+	}
+
+	for _, checkCfg := range cfg.Checks {
+		check, err := newHealthCheck(checkCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+func newHealthCheck(cfg config.HealthCheck) (check healthCheck, err error) {
+	timeout := cfg.Timeout.Duration
+	if timeout == 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+
+	check.name = cfg.Name
+
+	switch {
+	case cfg.Unit != "" && cfg.URL == "" && cfg.Command == "":
+		if check.name == "" {
+			check.name = cfg.Unit
+		}
+
+		check.check = newUnitHealthCheck(cfg.Unit, timeout)
+
+	case cfg.URL != "" && cfg.Unit == "" && cfg.Command == "":
+		if check.name == "" {
+			check.name = cfg.URL
+		}
+
+		check.check = newHTTPHealthCheck(cfg.URL, timeout)
+
+	case cfg.Command != "" && cfg.Unit == "" && cfg.URL == "":
+		if check.name == "" {
+			check.name = cfg.Command
+		}
+
+		verifier, err := newComponentVerifier(&config.VerifyCommand{Command: cfg.Command, Timeout: cfg.Timeout})
+		if err != nil {
+			return check, err
+		}
+
+		check.check = func(ctx context.Context) (err error) { return verifier.verify(check.name) }
+
+	default:
+		return check, aoserrors.Errorf("health check %s should have one of unit, URL or command", cfg.Name)
+	}
+
+	return check, nil
+}
+
+func newUnitHealthCheck(unit string, timeout time.Duration) (check func(ctx context.Context) (err error)) {
+	return func(ctx context.Context) (err error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		conn, err := systemd.NewSystemConnectionContext(ctx)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+		defer conn.Close()
+
+		statuses, err := conn.ListUnitsByNamesContext(ctx, []string{unit})
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		for _, status := range statuses {
+			if status.ActiveState != unitActiveState {
+				return aoserrors.Errorf("unit %s is %s", status.Name, status.ActiveState)
+			}
+		}
+
+		return nil
+	}
+}
+
+func newHTTPHealthCheck(checkURL string, timeout time.Duration) (check func(ctx context.Context) (err error)) {
+	return func(ctx context.Context) (err error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL, nil)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return aoserrors.Errorf("health probe status: %s", resp.Status)
+		}
+
+		return nil
+	}
+}
+
+// waitHealthChecks runs health checks till health window expires and returns error of the first failed check. It
+// should be called without handler lock.
+func (handler *Handler) waitHealthChecks() (err error) {
+	if len(handler.healthChecks) == 0 {
+		return nil
+	}
+
+	handler.Lock()
+
+	if handler.state.HealthDeadline == nil {
+		deadline := handler.clock.Now().Add(handler.healthWindow)
+
+		log.WithField("deadline", deadline).Debug("Start health window")
+
+		handler.state.HealthDeadline = &deadline
+
+		if err := handler.saveState(); err != nil {
+			log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+		}
+	}
+
+	deadline := *handler.state.HealthDeadline
+
+	handler.Unlock()
+
+	for {
+		if err = handler.runHealthChecks(handler.healthChecks); err != nil {
+			return err
+		}
+
+		remaining := deadline.Sub(handler.clock.Now())
+		if remaining <= 0 {
+			log.Info("Health window passed")
+
+			return nil
+		}
+
+		if remaining > handler.healthPollInterval {
+			remaining = handler.healthPollInterval
+		}
+
+		select {
+		case <-handler.clock.After(remaining):
+
+		case <-handler.operationContext().Done():
+			// Apply fails on stop without revert, health window is continued on next apply
+			log.Warn("Health checks are stopped")
+
+			return nil
+		}
+	}
+}
+
+func (handler *Handler) runHealthChecks(checks []healthCheck) (err error) {
+	for _, check := range checks {
+		if err = check.check(handler.operationContext()); err != nil {
+			if handler.checkStopped() != nil {
+				return nil
+			}
+
+			log.WithField("check", check.name).Errorf("Health check failed: %v", err)
+
+			return aoserrors.Errorf("health check %s failed: %v", check.name, err)
+		}
+	}
+
+	return nil
+}
+
+// revertUnhealthy reverts update which failed health checks. It is called under handler lock.
+func (handler *Handler) revertUnhealthy(healthErr error) {
+	log.Warn("Revert unhealthy update")
+
+	handler.setHealthDecision(&HealthDecision{Time: handler.clock.Now(), Reverted: true, Error: healthErr.Error()})
+
+	handler.revertUpdate(healthErr)
+}
+
+func (handler *Handler) setHealthDecision(decision *HealthDecision) {
+	handler.healthMutex.Lock()
+	defer handler.healthMutex.Unlock()
+
+	handler.state.HealthDecision = decision
+}
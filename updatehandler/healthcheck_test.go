@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestHealthChecks(t *testing.T) {
+	var healthy int32 = 1
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	for _, testItem := range []struct {
+		failAfter int
+		reverted  bool
+	}{
+		{failAfter: 1, reverted: true},
+		{failAfter: -1},
+	} {
+		components = map[string]*testModule{"id1": {id: "id1"}}
+
+		handler := newTestHandler(t, &config.Config{
+			DownloadDir: cfg.DownloadDir,
+			HealthChecks: config.HealthChecks{
+				Checks:       []config.HealthCheck{{Name: "probe", URL: server.URL}, {Command: "true"}},
+				Window:       aostypes.Duration{Duration: time.Minute},
+				PollInterval: aostypes.Duration{Duration: 30 * time.Second},
+			},
+			UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+		}, withModules(components))
+
+		fakeClock := clock.NewFake(time.Now())
+
+		handler.SetClock(fakeClock)
+
+		currentStatus := umclient.Status{
+			State:      umclient.StateIdle,
+			Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+		}
+
+		testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+		atomic.StoreInt32(&healthy, 1)
+
+		infos, err := createUpdateInfos(currentStatus.Components, "")
+		if err != nil {
+			t.Fatalf("Can't create update infos: %s", err)
+		}
+
+		handler.PrepareUpdate(infos)
+
+		if err = waitForState(handler, umclient.StatePrepared); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		handler.StartUpdate()
+
+		if err = waitForState(handler, umclient.StateUpdated); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		order = nil
+
+		handler.ApplyUpdate()
+
+		// Health is checked at window start and each poll interval till the window expires
+
+		for i := 0; i < 2; i++ {
+			if i == testItem.failAfter {
+				atomic.StoreInt32(&healthy, 0)
+			}
+
+			fakeClock.BlockUntil(1)
+			fakeClock.Advance(30 * time.Second)
+		}
+
+		select {
+		case status := <-handler.StatusChannel():
+			if status.State != umclient.StateIdle {
+				t.Errorf("Wrong state: %s", status.State)
+			}
+
+			if testItem.reverted && !strings.HasPrefix(status.Error, "update reverted: health check probe failed") {
+				t.Errorf("Wrong error: %s", status.Error)
+			}
+
+			if !testItem.reverted && status.Error != "" {
+				t.Errorf("Unexpected error: %s", status.Error)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait status timeout")
+		}
+
+		expectedOps := map[string][]string{"id1": {opApply}}
+
+		if testItem.reverted {
+			expectedOps = map[string][]string{"id1": {opRevert}}
+		}
+
+		if err := checkComponentOps(expectedOps); err != nil {
+			t.Errorf("Component operation error: %s", err)
+		}
+
+		decision := handler.GetHealthDecision()
+		if decision == nil || decision.Reverted != testItem.reverted {
+			t.Errorf("Wrong health decision: %v", decision)
+		}
+
+		handler.Close(context.Background())
+	}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{
+		HealthChecks: config.HealthChecks{Checks: []config.HealthCheck{{Unit: "unit", Command: "true"}}},
+	}); len(findings) != 1 {
+		t.Errorf("Wrong findings: %v", findings)
+	}
+}
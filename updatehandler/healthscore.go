@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Component health events are recorded when update is finished: errored component gets failure event, component
+// reverted instead of apply gets revert event, and component reverted because updated system failed health checks
+// gets boot failure event. Failures caused by update cancel, emergency stop or shutdown are not recorded. Events are
+// kept in handler state, so they survive sessions and reboots, and events older than the health score window are
+// dropped. Health score starts from 100 and is decreased by weighted event counts. Status reports only components
+// with decreased score, full scores can be exposed in Prometheus text format to let the backend exclude chronically
+// failing units from risky campaigns.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const defaultHealthScoreWindow = 30 * 24 * time.Hour
+
+const (
+	healthEventFailure     = "failure"
+	healthEventRevert      = "revert"
+	healthEventBootFailure = "bootFailure"
+)
+
+const (
+	maxHealthScore          = 100
+	failureHealthWeight     = 10
+	revertHealthWeight      = 20
+	bootFailureHealthWeight = 30
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type healthEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetHealthScores returns health scores of configured components by component ID.
+func (handler *Handler) GetHealthScores() (scores map[string]umclient.ComponentHealth) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	return handler.getHealthScores()
+}
+
+// WriteHealthMetrics writes component health scores in Prometheus text exposition format.
+func (handler *Handler) WriteHealthMetrics(w io.Writer) (err error) {
+	scores := handler.GetHealthScores()
+
+	ids := make([]string, 0, len(scores))
+
+	for id := range scores {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	metrics := []struct {
+		name  string
+		help  string
+		value func(health umclient.ComponentHealth) int
+	}{
+		{
+			"aos_um_component_health_score", "Component health score from 0 to 100.",
+			func(health umclient.ComponentHealth) int { return health.Score },
+		},
+		{
+			"aos_um_component_failures", "Component update failures within health score window.",
+			func(health umclient.ComponentHealth) int { return health.Failures },
+		},
+		{
+			"aos_um_component_reverts", "Component update reverts within health score window.",
+			func(health umclient.ComponentHealth) int { return health.Reverts },
+		},
+		{
+			"aos_um_component_boot_failures", "Component boot failures within health score window.",
+			func(health umclient.ComponentHealth) int { return health.BootFailures },
+		},
+	}
+
+	var builder strings.Builder
+
+	for _, metric := range metrics {
+		fmt.Fprintf(&builder, "# HELP %s %s\n# TYPE %s gauge\n", metric.name, metric.help, metric.name)
+
+		for _, id := range ids {
+			fmt.Fprintf(&builder, "%s{component=%q} %d\n", metric.name, id, metric.value(scores[id]))
+		}
+	}
+
+	if _, err = io.WriteString(w, builder.String()); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func getHealthScoreWindow(cfg config.HealthScore) (window time.Duration, err error) {
+	if cfg.Window.Duration < 0 {
+		return 0, aoserrors.Errorf("wrong health score window: %s", cfg.Window.Duration)
+	}
+
+	if cfg.Window.Duration == 0 {
+		return defaultHealthScoreWindow, nil
+	}
+
+	return cfg.Window.Duration, nil
+}
+
+// recordHealthEvents is called when update is finished.
+func (handler *Handler) recordHealthEvents() {
+	now := handler.clock.Now()
+	reverted := strings.HasPrefix(handler.state.Error, updateRevertedMsg)
+	decision := handler.GetHealthDecision()
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		if componentStatus.Status != umclient.StatusError || isInterruptError(componentStatus.Error) {
+			continue
+		}
+
+		eventType := healthEventFailure
+
+		// Excluded components keep error of their failed operation
+		if reverted && !handler.isExcluded(id) {
+			eventType = healthEventRevert
+
+			if decision != nil && decision.Reverted {
+				eventType = healthEventBootFailure
+			}
+		}
+
+		log.WithFields(log.Fields{"id": id, "event": eventType}).Debug("Record component health event")
+
+		if handler.state.ComponentHealth == nil {
+			handler.state.ComponentHealth = make(map[string][]healthEvent)
+		}
+
+		handler.state.ComponentHealth[id] = append(handler.state.ComponentHealth[id],
+			healthEvent{Time: now, Type: eventType})
+	}
+
+	for id, events := range handler.state.ComponentHealth {
+		if events = handler.recentHealthEvents(events); len(events) == 0 {
+			delete(handler.state.ComponentHealth, id)
+
+			continue
+		}
+
+		handler.state.ComponentHealth[id] = events
+	}
+}
+
+// recentHealthEvents returns events within health score window.
+func (handler *Handler) recentHealthEvents(events []healthEvent) (recentEvents []healthEvent) {
+	since := handler.clock.Now().Add(-handler.healthScoreWindow)
+
+	for _, event := range events {
+		if event.Time.After(since) {
+			recentEvents = append(recentEvents, event)
+		}
+	}
+
+	return recentEvents
+}
+
+// getHealthScores returns health scores of configured components.
+func (handler *Handler) getHealthScores() (scores map[string]umclient.ComponentHealth) {
+	scores = make(map[string]umclient.ComponentHealth)
+
+	for id := range handler.components {
+		health := umclient.ComponentHealth{Score: maxHealthScore}
+
+		for _, event := range handler.recentHealthEvents(handler.state.ComponentHealth[id]) {
+			switch event.Type {
+			case healthEventFailure:
+				health.Failures++
+				health.Score -= failureHealthWeight
+
+			case healthEventRevert:
+				health.Reverts++
+				health.Score -= revertHealthWeight
+
+			case healthEventBootFailure:
+				health.BootFailures++
+				health.Score -= bootFailureHealthWeight
+			}
+		}
+
+		if health.Score < 0 {
+			health.Score = 0
+		}
+
+		scores[id] = health
+	}
+
+	return scores
+}
+
+// getDegradedHealthScores returns health scores of components which have health events within the window.
+func (handler *Handler) getDegradedHealthScores() (scores map[string]umclient.ComponentHealth) {
+	scores = handler.getHealthScores()
+
+	for id, health := range scores {
+		if health.Score == maxHealthScore {
+			delete(scores, id)
+		}
+	}
+
+	return scores
+}
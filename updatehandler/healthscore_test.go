@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestHealthScore(t *testing.T) {
+	storage := newTestStorage()
+	fakeClock := clock.NewFake(time.Now())
+
+	cfg := &config.Config{
+		HealthScore: config.HealthScore{Window: aostypes.Duration{Duration: 24 * time.Hour}},
+		HealthChecks: config.HealthChecks{
+			Checks: []config.HealthCheck{{Name: "probe", Command: "false"}},
+			Window: aostypes.Duration{Duration: time.Minute},
+		},
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	}
+
+	handler := newTestHandler(t, cfg, withStorage(storage))
+
+	handler.SetClock(fakeClock)
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Failed update is recorded as failure of errored component
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	components["id1"].status = aoserrors.New("update error")
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	components["id1"].status = nil
+
+	handler.RevertUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	// Update reverted by failed health checks is recorded as boot failure
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateUpdated); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.ApplyUpdate()
+
+	select {
+	case status := <-handler.StatusChannel():
+		expectedScores := map[string]umclient.ComponentHealth{
+			"id1": {Score: 60, Failures: 1, BootFailures: 1},
+			"id2": {Score: 70, BootFailures: 1},
+		}
+
+		if !reflect.DeepEqual(status.HealthScores, expectedScores) {
+			t.Errorf("Wrong status health scores: %v", status.HealthScores)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait status timeout")
+	}
+
+	handler.Close(context.Background())
+
+	// Health events are persistent, update reverted by failed hook is recorded as revert
+
+	cfg.HealthChecks = config.HealthChecks{}
+	cfg.Hooks = []config.Hook{{Name: "hook", Command: "false", Events: []string{"apply"}, Stage: "pre"}}
+
+	handler = newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	handler.SetClock(fakeClock)
+	handler.Registered()
+
+	if err = waitForStatus(handler, nil); err != nil {
+		t.Fatalf("Wait for status failed: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateUpdated); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.ApplyUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	expectedScores := map[string]umclient.ComponentHealth{
+		"id1": {Score: 40, Failures: 1, Reverts: 1, BootFailures: 1},
+		"id2": {Score: 50, Reverts: 1, BootFailures: 1},
+	}
+
+	if scores := handler.GetHealthScores(); !reflect.DeepEqual(scores, expectedScores) {
+		t.Errorf("Wrong health scores: %v", scores)
+	}
+
+	buffer := bytes.NewBuffer(nil)
+
+	if err = handler.WriteHealthMetrics(buffer); err != nil {
+		t.Fatalf("Can't write health metrics: %s", err)
+	}
+
+	for _, metric := range []string{
+		"# TYPE aos_um_component_health_score gauge\n",
+		"aos_um_component_health_score{component=\"id1\"} 40\n",
+		"aos_um_component_reverts{component=\"id2\"} 1\n",
+		"aos_um_component_boot_failures{component=\"id1\"} 1\n",
+	} {
+		if !strings.Contains(buffer.String(), metric) {
+			t.Errorf("Metric not found: %s", metric)
+		}
+	}
+
+	// Events out of window are not counted
+
+	fakeClock.Advance(25 * time.Hour)
+
+	expectedScores = map[string]umclient.ComponentHealth{"id1": {Score: 100}, "id2": {Score: 100}}
+
+	if scores := handler.GetHealthScores(); !reflect.DeepEqual(scores, expectedScores) {
+		t.Errorf("Wrong health scores: %v", scores)
+	}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{
+		HealthScore: config.HealthScore{Window: aostypes.Duration{Duration: -time.Hour}},
+	}); len(findings) != 1 || !strings.Contains(findings[0].Message, "wrong health score window") {
+		t.Errorf("Wrong findings: %v", findings)
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"time"
+
+	"github.com/looplab/fsm"
+
+	"github.com/aoscloud/aos_updatemanager/utils/updatehistory"
+)
+
+// Update history session is started on prepare and is persisted in handler state, so transitions and operations
+// after reboot are recorded into the same session. Each FSM transition is recorded with the resulting state: failed
+// state set by the transition callback overrides the event destination. Each component operation and reboot is
+// recorded with its start time, duration, versions of the component being updated and error. History is kept in
+// handler storage, it is only logged if the storage doesn't support it.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const historySessionFormat = "20060102T150405.000000000Z"
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetUpdateHistory returns update history entries of the session or all stored entries if session is empty.
+func (handler *Handler) GetUpdateHistory(session string) (entries []updatehistory.Entry, err error) {
+	return handler.history.Entries(session)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newHistorySession(now time.Time) (session string) {
+	return now.UTC().Format(historySessionFormat)
+}
+
+func (handler *Handler) recordTransition(event *fsm.Event) {
+	journal := newJournalEntry(handler.takeTransitionCursor(), handler.journalCursors.get())
+
+	handler.history.Transition(handler.state.HistorySession, event.Event, event.Src, handler.fsm.Current(),
+		handler.clock.Now(), handler.state.Error, journal)
+}
+
+// recordOperation is called from operation goroutines while handler lock is held by the transition, so handler
+// state is only read here.
+func (handler *Handler) recordOperation(
+	id, phase string, startTime time.Time, duration time.Duration, err error, journal *updatehistory.JournalCursors,
+) {
+	var (
+		vendorVersion string
+		aosVersion    uint64
+	)
+
+	if componentStatus, ok := handler.state.ComponentStatuses[id]; ok {
+		vendorVersion = componentStatus.VendorVersion
+		aosVersion = componentStatus.AosVersion
+	}
+
+	handler.history.Operation(handler.state.HistorySession, id, phase, vendorVersion, aosVersion, startTime,
+		duration, err, journal)
+}
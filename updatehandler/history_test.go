@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/updatehistory"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestUpdateHistory(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}, "id2": {id: "id2"}}
+
+	handler := newTestHandler(t, &config.Config{
+		InstanceID: "instance1",
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	}, withModules(components))
+
+	handler.Registered()
+
+	select {
+	case status := <-handler.StatusChannel():
+		if status.InstanceID != "instance1" {
+			t.Errorf("Wrong status instance ID: %s", status.InstanceID)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait status timeout")
+	}
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "2.0")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	components["id2"].status = aoserrors.New("update error")
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.RevertUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	entries, err := handler.GetUpdateHistory("")
+	if err != nil {
+		t.Fatalf("Can't get update history: %s", err)
+	}
+
+	if len(entries) == 0 || entries[0].Session == "" || entries[0].Instance != "instance1" {
+		t.Fatalf("Wrong update history: %v", entries)
+	}
+
+	if entries, err = handler.GetUpdateHistory(entries[0].Session); err != nil {
+		t.Fatalf("Can't get update history: %s", err)
+	}
+
+	var (
+		transitions []string
+		updateErr   *updatehistory.Entry
+	)
+
+	for i, entry := range entries {
+		switch entry.Type {
+		case updatehistory.EntryTransition:
+			transitions = append(transitions, entry.Event+":"+entry.From+"->"+entry.To)
+
+			if entry.To == "failed" && entry.Error == "" {
+				t.Error("Failed transition error is not recorded")
+			}
+
+		case updatehistory.EntryOperation:
+			if entry.ID == "id2" && entry.Phase == opUpdate {
+				updateErr = &entries[i]
+			}
+		}
+	}
+
+	expectedTransitions := []string{"prepare:idle->prepared", "update:prepared->failed", "revert:failed->idle"}
+
+	if !reflect.DeepEqual(transitions, expectedTransitions) {
+		t.Errorf("Wrong update history transitions: %v", transitions)
+	}
+
+	if updateErr == nil {
+		t.Fatal("Update operation of id2 is not recorded")
+	}
+
+	if !strings.Contains(updateErr.Error, "update error") || updateErr.VendorVersion != "2.0" ||
+		updateErr.AosVersion != infos[1].AosVersion {
+		t.Errorf("Wrong update operation entry: %v", *updateErr)
+	}
+}
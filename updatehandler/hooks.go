@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Transition hooks run under handler lock: pre stage hooks before component operations of the event, post stage
+// hooks after them, post hooks are run whether the operation succeeded or not and get its error. Shell hooks get
+// event info in UPDATE_EVENT, UPDATE_STAGE, UPDATE_COMPONENTS and UPDATE_ERROR environment variables. Hook failure
+// with fail policy is handled as component failure: all components of the update get the hook error. Failed pre
+// prepare or update hook fails the operation, failed pre apply hook reverts the update instead of apply, revert is
+// never skipped. Hooks may be run again if the operation is resumed after reboot, so they should be idempotent.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Hook stages.
+const (
+	HookStagePre  = "pre"
+	HookStagePost = "post"
+)
+
+const (
+	hookPolicyFail   = "fail"
+	hookPolicyIgnore = "ignore"
+)
+
+const defaultHookTimeout = time.Minute
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var hookPlugins = make(map[string]NewHook) //nolint:gochecknoglobals
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// HookInfo transition hook info.
+type HookInfo struct {
+	Event      string
+	Stage      string
+	Components []string
+	Error      string
+}
+
+// Hook transition hook plugin.
+type Hook interface {
+	// Run runs hook, it should return when context is canceled
+	Run(ctx context.Context, info HookInfo) (err error)
+}
+
+// NewHook hook plugin new function.
+type NewHook func(params json.RawMessage) (hook Hook, err error)
+
+type transitionHook struct {
+	name    string
+	events  map[string]bool
+	stage   string
+	timeout time.Duration
+	ignore  bool
+	hook    Hook
+}
+
+type commandHook struct {
+	command string
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// RegisterHook registers transition hook plugin.
+func RegisterHook(plugin string, newFunc NewHook) {
+	hookPlugins[plugin] = newFunc
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newTransitionHooks(hooksCfg []config.Hook) (hooks []transitionHook, err error) {
+	if err = checkHooks(hooksCfg); err != nil {
+		return nil, err
+	}
+
+	for _, hookCfg := range hooksCfg {
+		hook := transitionHook{
+			name: hookName(hookCfg), events: make(map[string]bool), stage: hookCfg.Stage,
+			timeout: hookCfg.Timeout.Duration, ignore: hookCfg.OnError == hookPolicyIgnore,
+		}
+
+		if hook.timeout == 0 {
+			hook.timeout = defaultHookTimeout
+		}
+
+		for _, event := range hookCfg.Events {
+			hook.events[event] = true
+		}
+
+		if hookCfg.Command != "" {
+			hook.hook = &commandHook{command: hookCfg.Command}
+		} else if hook.hook, err = hookPlugins[hookCfg.Plugin](hookCfg.Params); err != nil {
+			return nil, aoserrors.Errorf("can't create hook %s: %v", hook.name, err)
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+func checkHooks(hooksCfg []config.Hook) (err error) {
+	for _, hookCfg := range hooksCfg {
+		name := hookName(hookCfg)
+
+		if (hookCfg.Command == "") == (hookCfg.Plugin == "") {
+			return aoserrors.Errorf("hook %s should have either command or plugin", name)
+		}
+
+		if _, ok := hookPlugins[hookCfg.Plugin]; hookCfg.Plugin != "" && !ok {
+			return aoserrors.Errorf("hook %s: plugin %s not found", name, hookCfg.Plugin)
+		}
+
+		if hookCfg.Stage != HookStagePre && hookCfg.Stage != HookStagePost {
+			return aoserrors.Errorf("hook %s: wrong stage %s", name, hookCfg.Stage)
+		}
+
+		if hookCfg.OnError != "" && hookCfg.OnError != hookPolicyFail && hookCfg.OnError != hookPolicyIgnore {
+			return aoserrors.Errorf("hook %s: wrong error policy %s", name, hookCfg.OnError)
+		}
+
+		if hookCfg.Timeout.Duration < 0 {
+			return aoserrors.Errorf("hook %s: wrong timeout", name)
+		}
+
+		if len(hookCfg.Events) == 0 {
+			return aoserrors.Errorf("hook %s: no events", name)
+		}
+
+		for _, event := range hookCfg.Events {
+			switch event {
+			case eventPrepare, eventUpdate, eventApply, eventRevert:
+
+			default:
+				return aoserrors.Errorf("hook %s: wrong event %s", name, event)
+			}
+		}
+	}
+
+	return nil
+}
+
+func hookName(hookCfg config.Hook) (name string) {
+	switch {
+	case hookCfg.Name != "":
+		return hookCfg.Name
+
+	case hookCfg.Command != "":
+		return hookCfg.Command
+
+	default:
+		return hookCfg.Plugin
+	}
+}
+
+// runHooks runs hooks of event stage, operation error is passed to post stage hooks. It is called under handler
+// lock.
+func (handler *Handler) runHooks(event, stage, opError string) (err error) {
+	info := HookInfo{Event: event, Stage: stage, Error: opError}
+
+	for id := range handler.state.ComponentStatuses {
+		info.Components = append(info.Components, id)
+	}
+
+	sort.Strings(info.Components)
+
+	for _, hook := range handler.hooks {
+		if hook.stage != stage || !hook.events[event] {
+			continue
+		}
+
+		log.WithFields(log.Fields{"hook": hook.name, "event": event, "stage": stage}).Debug("Run transition hook")
+
+		if hookErr := hook.run(handler.operationContext(), info); hookErr != nil {
+			if hook.ignore {
+				log.WithField("hook", hook.name).Warnf("Ignore transition hook error: %v", hookErr)
+
+				continue
+			}
+
+			err = aoserrors.Errorf("%s %s hook %s failed: %v", stage, event, hook.name, hookErr)
+
+			break
+		}
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	// Operation error is already reported for the components
+	if opError != "" {
+		log.Errorf("Transition hook error: %v", err)
+
+		return nil
+	}
+
+	for _, componentStatus := range handler.state.ComponentStatuses {
+		componentError(componentStatus, err)
+	}
+
+	return err
+}
+
+func (hook *transitionHook) run(ctx context.Context, info HookInfo) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, hook.timeout)
+	defer cancel()
+
+	if err = hook.hook.Run(ctx, info); err != nil {
+		if ctx.Err() != nil {
+			return aoserrors.Errorf("timeout: %v", err)
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func (hook *commandHook) Run(ctx context.Context, info HookInfo) (err error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.command)
+
+	cmd.Env = append(os.Environ(),
+		"UPDATE_EVENT="+info.Event,
+		"UPDATE_STAGE="+info.Stage,
+		"UPDATE_COMPONENTS="+strings.Join(info.Components, " "),
+		"UPDATE_ERROR="+info.Error)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return aoserrors.Errorf("%v, output: %s", err, trimOutput(output))
+	}
+
+	return nil
+}
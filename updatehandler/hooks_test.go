@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestTransitionHooks(t *testing.T) {
+	type testData struct {
+		failOn      string
+		onError     string
+		updateState umclient.UMState
+		updateErr   string
+		calls       []string
+	}
+
+	hookFile := path.Join(tmpDir, "hook.log")
+
+	for _, item := range []testData{
+		// All hooks succeed
+		{
+			updateState: umclient.StateUpdated,
+			calls: []string{
+				"pre prepare", "post prepare", "pre update", "post update", "pre apply", "post apply",
+			},
+		},
+		// Pre update hook fails the update
+		{
+			failOn:      "pre update",
+			updateState: umclient.StateFailed,
+			updateErr:   "pre update hook testhook failed: hook failed",
+			calls:       []string{"pre prepare", "post prepare", "pre update", "pre revert", "post revert"},
+		},
+		// Pre update hook error is ignored
+		{
+			failOn:      "pre update",
+			onError:     "ignore",
+			updateState: umclient.StateUpdated,
+			calls: []string{
+				"pre prepare", "post prepare", "pre update", "post update", "pre apply", "post apply",
+			},
+		},
+	} {
+		components = map[string]*testModule{"id1": {id: "id1"}}
+		hookCalls = nil
+
+		if err := os.RemoveAll(hookFile); err != nil {
+			t.Fatalf("Can't remove hook file: %s", err)
+		}
+
+		params := json.RawMessage(`{"failOn": "` + item.failOn + `"}`)
+		events := []string{"prepare", "update", "apply", "revert"}
+
+		handler := newTestHandler(t, &config.Config{
+			UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+			Hooks: []config.Hook{
+				{Plugin: "testhook", Events: events, Stage: "pre", Params: params, OnError: item.onError},
+				{Plugin: "testhook", Events: events, Stage: "post", Params: params, OnError: item.onError},
+				{
+					Command: "echo $UPDATE_STAGE $UPDATE_EVENT $UPDATE_COMPONENTS >> " + hookFile,
+					Events:  []string{"prepare"}, Stage: "post",
+				},
+			},
+		}, withModules(components))
+
+		testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+		infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}}, "2.0")
+		if err != nil {
+			t.Fatalf("Can't create update infos: %s", err)
+		}
+
+		handler.PrepareUpdate(infos)
+
+		if err = waitForState(handler, umclient.StatePrepared); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		components["id1"].vendorVersion = "2.0"
+
+		handler.StartUpdate()
+
+		select {
+		case status := <-handler.StatusChannel():
+			if status.State != item.updateState || !strings.Contains(status.Error, item.updateErr) {
+				t.Errorf("Wrong update status: %s, error: %s", status.State, status.Error)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait status timeout")
+		}
+
+		if item.updateState == umclient.StateFailed {
+			handler.RevertUpdate()
+		} else {
+			handler.ApplyUpdate()
+		}
+
+		if err = waitForState(handler, umclient.StateIdle); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		mutex.Lock()
+
+		if !reflect.DeepEqual(hookCalls, item.calls) {
+			t.Errorf("Wrong hook calls: %v", hookCalls)
+		}
+
+		mutex.Unlock()
+
+		output, err := os.ReadFile(hookFile)
+		if err != nil {
+			t.Fatalf("Can't read hook file: %s", err)
+		}
+
+		if string(output) != "post prepare id1\n" {
+			t.Errorf("Wrong command hook output: %s", string(output))
+		}
+
+		handler.Close(context.Background())
+	}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{Hooks: []config.Hook{{
+		Command: "true", Plugin: "testhook", Events: []string{"prepare"}, Stage: "pre",
+	}}}); len(findings) != 1 || !strings.Contains(findings[0].Message, "should have either command or plugin") {
+		t.Errorf("Wrong config findings: %v", findings)
+	}
+}
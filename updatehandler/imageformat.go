@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Image format is sniffed by magic numbers before the image is passed to the module and is compared with image
+// formats expected by the module config. Gzip compressed image is sniffed by its decompressed content, so "ext4"
+// matches both plain and gzip compressed ext4 image. Ext2 and ext3 images are reported as ext4 as they share the
+// superblock magic.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	imageFormatSquashFS = "squashfs"
+	imageFormatExt4     = "ext4"
+	imageFormatTar      = "tar"
+	imageFormatCpio     = "cpio"
+	imageFormatELF      = "elf"
+	imageFormatGzip     = "gzip"
+	imageFormatUnknown  = "unknown"
+)
+
+const (
+	sniffSize       = 4096
+	extMagicOffset  = 1080
+	tarMagicOffset  = 257
+	cpioBinaryMagic = 0o70707
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+//nolint:gochecknoglobals
+var imageFormats = map[string]bool{
+	imageFormatSquashFS: true,
+	imageFormatExt4:     true,
+	imageFormatTar:      true,
+	imageFormatCpio:     true,
+	imageFormatELF:      true,
+	imageFormatGzip:     true,
+}
+
+var gzipMagic = []byte{0x1f, 0x8b} //nolint:gochecknoglobals
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkImageFormats(formats []string) (err error) {
+	for _, format := range formats {
+		if !imageFormats[format] {
+			return aoserrors.Errorf("unknown image format %s", format)
+		}
+	}
+
+	return nil
+}
+
+func checkImageFormat(filePath string, expectedFormats []string) (err error) {
+	if len(expectedFormats) == 0 {
+		return nil
+	}
+
+	format, compressed, err := sniffImageFormat(filePath)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"format": format, "compressed": compressed}).Debug("Image format detected")
+
+	for _, expectedFormat := range expectedFormats {
+		// Any gzip compressed image matches gzip format
+		if format == expectedFormat || (expectedFormat == imageFormatGzip && compressed) {
+			return nil
+		}
+	}
+
+	return aoserrors.Errorf("wrong image format: %s, expected: %v", format, expectedFormats)
+}
+
+// sniffImageFormat detects image format by magic numbers. Format of compressed image content is returned if it is
+// detected, gzip format otherwise.
+func sniffImageFormat(filePath string) (format string, compressed bool, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", false, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	header, err := readHeader(file)
+	if err != nil {
+		return "", false, err
+	}
+
+	if !bytes.HasPrefix(header, gzipMagic) {
+		return sniffFormat(header), false, nil
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return "", false, aoserrors.Wrap(err)
+	}
+
+	gzipReader, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		log.Debugf("Can't read gzip header: %v", err)
+
+		return imageFormatGzip, true, nil
+	}
+	defer gzipReader.Close()
+
+	if header, err = readHeader(gzipReader); err != nil {
+		log.Debugf("Can't read gzip content: %v", err)
+
+		return imageFormatGzip, true, nil
+	}
+
+	if format = sniffFormat(header); format == imageFormatUnknown {
+		return imageFormatGzip, true, nil
+	}
+
+	return format, true, nil
+}
+
+func readHeader(reader io.Reader) (header []byte, err error) {
+	header = make([]byte, sniffSize)
+
+	n, err := io.ReadFull(reader, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return header[:n], nil
+}
+
+func sniffFormat(header []byte) (format string) {
+	switch {
+	case bytes.HasPrefix(header, []byte("hsqs")):
+		return imageFormatSquashFS
+
+	case bytes.HasPrefix(header, []byte("\x7fELF")):
+		return imageFormatELF
+
+	case bytes.HasPrefix(header, []byte("07070")):
+		return imageFormatCpio
+
+	case len(header) >= 2 && (binary.LittleEndian.Uint16(header) == cpioBinaryMagic ||
+		binary.BigEndian.Uint16(header) == cpioBinaryMagic):
+		return imageFormatCpio
+
+	case len(header) >= tarMagicOffset+5 && bytes.Equal(header[tarMagicOffset:tarMagicOffset+5], []byte("ustar")):
+		return imageFormatTar
+
+	case len(header) >= extMagicOffset+2 &&
+		binary.LittleEndian.Uint16(header[extMagicOffset:]) == 0xef53:
+		return imageFormatExt4
+
+	default:
+		return imageFormatUnknown
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"path"
+	"testing"
+
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestImageFormat(t *testing.T) {
+	extImage := make([]byte, 2048)
+	extImage[1080], extImage[1081] = 0x53, 0xef
+
+	var tarImage bytes.Buffer
+
+	tarWriter := tar.NewWriter(&tarImage)
+
+	if err := tarWriter.WriteHeader(&tar.Header{Name: "rootfs", Mode: 0o600, Size: 4}); err != nil {
+		t.Fatalf("Can't write tar header: %s", err)
+	}
+
+	if _, err := tarWriter.Write([]byte("data")); err != nil {
+		t.Fatalf("Can't write tar content: %s", err)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("Can't close tar writer: %s", err)
+	}
+
+	for _, testItem := range []struct {
+		content       []byte
+		compress      bool
+		formats       []string
+		expectedError string
+	}{
+		{content: []byte("hsqs squashfs image"), formats: []string{"squashfs"}},
+		{content: extImage, compress: true, formats: []string{"ext4"}},
+		{content: extImage, compress: true, formats: []string{"gzip"}},
+		{content: []byte("unknown content"), compress: true, formats: []string{"gzip"}},
+		{content: []byte("070701 cpio archive"), formats: []string{"cpio"}},
+		{content: []byte("any content")},
+		{
+			content: tarImage.Bytes(), formats: []string{"squashfs", "ext4"},
+			expectedError: "wrong image format: tar, expected: [squashfs ext4]",
+		},
+		{
+			content: []byte("\x7fELF binary"), formats: []string{"cpio"},
+			expectedError: "wrong image format: elf, expected: [cpio]",
+		},
+		{
+			content: []byte("unknown content"), compress: true, formats: []string{"ext4"},
+			expectedError: "wrong image format: gzip, expected: [ext4]",
+		},
+	} {
+		components = map[string]*testModule{"id1": {id: "id1"}}
+
+		handler := newTestHandler(t, &config.Config{
+			UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule", ImageFormats: testItem.formats}},
+		}, withModules(components))
+
+		currentStatus := umclient.Status{
+			State:      umclient.StateIdle,
+			Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+		}
+
+		testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+		imagePath := path.Join(tmpDir, "formatimage")
+
+		if err := writeImage(imagePath, testItem.content, testItem.compress); err != nil {
+			t.Fatalf("Can't write image: %s", err)
+		}
+
+		imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+		if err != nil {
+			t.Fatalf("Can't create file info: %s", err)
+		}
+
+		infos := []umclient.ComponentUpdateInfo{{
+			ID: "id1", AosVersion: 1, URL: "file://" + imagePath,
+			Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+		}}
+
+		expectedStatus := currentStatus
+		expectedStatus.State = umclient.StatePrepared
+		expectedStatus.Components = append(expectedStatus.Components, umclient.ComponentStatusInfo{
+			ID: "id1", AosVersion: 1, Status: umclient.StatusInstalling,
+		})
+
+		if testItem.expectedError != "" {
+			expectedStatus.State = umclient.StateFailed
+			expectedStatus.Error = testItem.expectedError
+			expectedStatus.Components[1].Status = umclient.StatusError
+			expectedStatus.Components[1].Error = testItem.expectedError
+		}
+
+		order = nil
+
+		testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &expectedStatus, nil, nil)
+
+		if testItem.expectedError != "" {
+			if err = checkComponentOps(map[string][]string{"id1": nil}); err != nil {
+				t.Errorf("Component operation error: %s", err)
+			}
+		}
+
+		handler.Close(context.Background())
+	}
+}
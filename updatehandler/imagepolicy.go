@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/utils/contextreader"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Verification policy defines which checks component image should pass at prepare: signatures by the component
+// signature policy, image SHA3 digests and size from update info. Besides configured policies there are built-in
+// ones: strict, hash-only and none, which is intended for development only. Component without verification policy
+// is checked by default policy: digests and size are always checked, signatures if signature policy is set. Image
+// is checked against the policy wherever it comes from: downloaded, reused from download session or prefetched.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	verificationPolicyDefault  = "default"
+	verificationPolicyStrict   = "strict"
+	verificationPolicyHashOnly = "hash-only"
+	verificationPolicyNone     = "none"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+//nolint:gochecknoglobals
+var builtinVerificationPolicies = []config.VerificationPolicy{
+	{Name: verificationPolicyStrict, Signature: true, Hash: true, Size: true},
+	{Name: verificationPolicyHashOnly, Hash: true},
+	{Name: verificationPolicyNone},
+}
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type verificationPolicy struct {
+	name      string
+	signature bool
+	hash      bool
+	size      bool
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newVerificationPolicy(
+	cfg *config.Config, moduleCfg config.ModuleConfig,
+) (policy *verificationPolicy, err error) {
+	if moduleCfg.VerificationPolicy == "" {
+		return &verificationPolicy{
+			name: verificationPolicyDefault, signature: moduleCfg.SignaturePolicy != "", hash: true, size: true,
+		}, nil
+	}
+
+	policyCfg, ok := findVerificationPolicy(cfg, moduleCfg.VerificationPolicy)
+	if !ok {
+		return nil, aoserrors.Errorf("verification policy %s of module %s not found",
+			moduleCfg.VerificationPolicy, moduleCfg.ID)
+	}
+
+	if policyCfg.Signature && moduleCfg.SignaturePolicy == "" {
+		return nil, aoserrors.Errorf("verification policy %s of module %s requires signature policy",
+			policyCfg.Name, moduleCfg.ID)
+	}
+
+	if !policyCfg.Signature && moduleCfg.SignaturePolicy != "" {
+		log.WithFields(log.Fields{
+			"id": moduleCfg.ID, "policy": policyCfg.Name,
+		}).Warn("Image signatures are not verified by verification policy")
+	}
+
+	if !policyCfg.Hash && !policyCfg.Size {
+		log.WithFields(log.Fields{"id": moduleCfg.ID, "policy": policyCfg.Name}).Warn("Image is not verified")
+	}
+
+	return &verificationPolicy{
+		name: policyCfg.Name, signature: policyCfg.Signature, hash: policyCfg.Hash, size: policyCfg.Size,
+	}, nil
+}
+
+func checkVerificationPolicies(cfg *config.Config) (err error) {
+	policies := make(map[string]bool)
+
+	for _, policyCfg := range builtinVerificationPolicies {
+		policies[policyCfg.Name] = true
+	}
+
+	for _, policyCfg := range cfg.VerificationPolicies {
+		if policyCfg.Name == "" {
+			return aoserrors.New("verification policy name is empty")
+		}
+
+		if policies[policyCfg.Name] || policyCfg.Name == verificationPolicyDefault {
+			return aoserrors.Errorf("duplicated verification policy %s", policyCfg.Name)
+		}
+
+		policies[policyCfg.Name] = true
+	}
+
+	for _, moduleCfg := range cfg.UpdateModules {
+		if moduleCfg.Disabled || moduleCfg.VerificationPolicy == "" {
+			continue
+		}
+
+		if _, err = newVerificationPolicy(cfg, moduleCfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func findVerificationPolicy(cfg *config.Config, name string) (policyCfg config.VerificationPolicy, ok bool) {
+	for _, policies := range [][]config.VerificationPolicy{cfg.VerificationPolicies, builtinVerificationPolicies} {
+		for _, policyCfg = range policies {
+			if policyCfg.Name == name {
+				return policyCfg, true
+			}
+		}
+	}
+
+	return config.VerificationPolicy{}, false
+}
+
+// checkImage checks component image as required by component verification policy.
+func (handler *Handler) checkImage(
+	ctx context.Context, filePath string, updateInfo *umclient.ComponentUpdateInfo,
+) (err error) {
+	component, ok := handler.components[updateInfo.ID]
+	if !ok {
+		return aoserrors.Errorf("component %s not found", updateInfo.ID)
+	}
+
+	return component.imagePolicy.checkImage(ctx, filePath, updateInfo)
+}
+
+// checkImage checks image file against update info as required by the policy.
+func (policy *verificationPolicy) checkImage(
+	ctx context.Context, filePath string, updateInfo *umclient.ComponentUpdateInfo,
+) (err error) {
+	if !policy.hash && !policy.size {
+		return nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if policy.size {
+		stat, err := file.Stat()
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if uint64(stat.Size()) != updateInfo.Size {
+			return aoserrors.New("file size mismatch")
+		}
+	}
+
+	if !policy.hash {
+		return nil
+	}
+
+	if len(updateInfo.Sha256) == 0 || len(updateInfo.Sha512) == 0 {
+		return aoserrors.Errorf("image digests are required by verification policy %s", policy.name)
+	}
+
+	hash256, hash512 := sha3.New256(), sha3.New512()
+
+	if _, err = io.Copy(io.MultiWriter(hash256, hash512), contextreader.New(ctx, file)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if !bytes.Equal(hash256.Sum(nil), updateInfo.Sha256) {
+		return aoserrors.New("checksum sha256 mismatch")
+	}
+
+	if !bytes.Equal(hash512.Sum(nil), updateInfo.Sha512) {
+		return aoserrors.New("checksum sha512 mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestVerificationPolicies(t *testing.T) {
+	storage := newTestStorage()
+
+	moduleCfg := &config.Config{
+		VerificationPolicies: []config.VerificationPolicy{{Name: "size-only", Size: true}},
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", VerificationPolicy: "none"},
+			{ID: "id2", Plugin: "testmodule", VerificationPolicy: "hash-only"},
+			{ID: "id3", Plugin: "testmodule", VerificationPolicy: "size-only"},
+		},
+	}
+
+	// Strict policy requires signature policy
+
+	if _, err := updatehandler.New(&config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule", VerificationPolicy: "strict"}},
+	}, storage, storage); err == nil {
+		t.Error("Error expected if signature policy is not set")
+	}
+
+	handler := newTestHandler(t, moduleCfg, withStorage(storage))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}, {ID: "id3"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Each image fails checks which are not required by its policy
+
+	infos[0].Sha256, infos[0].Size = make([]byte, sha256.Size), infos[0].Size+1
+	infos[1].Size++
+	infos[2].Sha512 = nil
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	handler.RevertUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	infos[1].Sha256 = make([]byte, sha256.Size)
+
+	handler.PrepareUpdate(infos)
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Error("Wait for status timeout")
+
+	case status := <-handler.StatusChannel():
+		if status.State != umclient.StateFailed || !strings.Contains(status.Error, "checksum sha256 mismatch") {
+			t.Errorf("Wrong status: %s, error: %s", status.State, status.Error)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/looplab/fsm"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/utils/updatehistory"
+)
+
+// Journal cursors are captured by running the configured command which prints the current journald cursor in
+// journalctl --show-cursor format. Start cursor of a transition is captured when the FSM leaves the source state and
+// is kept in the FSM metadata till the transition is recorded, start cursor of a component operation is captured
+// right before the operation. Cursor capture failures are only logged: missing cursors shouldn't fail the update.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	defaultJournalCursorCommand = "journalctl -n 0 --show-cursor -q"
+	defaultJournalCursorTimeout = 5 * time.Second
+	journalCursorPrefix         = "-- cursor: "
+	transitionCursorKey         = "journalCursor"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type journalCursors struct {
+	command string
+	timeout time.Duration
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newJournalCursors(cfg config.JournalCursors) (cursors *journalCursors, err error) {
+	if cfg.Timeout.Duration < 0 {
+		return nil, aoserrors.Errorf("wrong journal cursors timeout: %v", cfg.Timeout.Duration)
+	}
+
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	cursors = &journalCursors{command: cfg.Command, timeout: cfg.Timeout.Duration}
+
+	if cursors.command == "" {
+		cursors.command = defaultJournalCursorCommand
+	}
+
+	if cursors.timeout == 0 {
+		cursors.timeout = defaultJournalCursorTimeout
+	}
+
+	return cursors, nil
+}
+
+// get returns current journal cursor or empty string if capture is disabled or failed.
+func (cursors *journalCursors) get() (cursor string) {
+	if cursors == nil {
+		return ""
+	}
+
+	cursor, err := cursors.read()
+	if err != nil {
+		log.Warnf("Can't get journal cursor: %v", err)
+	}
+
+	return cursor
+}
+
+func (cursors *journalCursors) read() (cursor string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cursors.timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", cursors.command).Output()
+
+	if ctx.Err() != nil {
+		return "", aoserrors.Errorf("journal cursor timeout: %s", cursors.command)
+	}
+
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); strings.HasPrefix(line, journalCursorPrefix) {
+			return strings.TrimPrefix(line, journalCursorPrefix), nil
+		}
+	}
+
+	return "", aoserrors.Errorf("no journal cursor in output: %s", trimOutput(output))
+}
+
+func newJournalEntry(start, end string) (journal *updatehistory.JournalCursors) {
+	if start == "" && end == "" {
+		return nil
+	}
+
+	return &updatehistory.JournalCursors{Start: start, End: end}
+}
+
+func (handler *Handler) onLeaveState(ctx context.Context, event *fsm.Event) {
+	if cursor := handler.journalCursors.get(); cursor != "" {
+		event.FSM.SetMetadata(transitionCursorKey, cursor)
+	}
+
+	event.Async()
+}
+
+func (handler *Handler) takeTransitionCursor() (cursor string) {
+	value, ok := handler.fsm.Metadata(transitionCursorKey)
+	if !ok {
+		return ""
+	}
+
+	handler.fsm.DeleteMetadata(transitionCursorKey)
+
+	cursor, _ = value.(string)
+
+	return cursor
+}
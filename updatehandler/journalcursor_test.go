@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/updatehistory"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestJournalCursors(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+	storage := newTestStorage()
+
+	cfg := &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+		JournalCursors: config.JournalCursors{
+			Enabled: true, Command: "echo 'journal header'; echo '-- cursor: s=test;i=1'",
+			Timeout: aostypes.Duration{Duration: -time.Second},
+		},
+	}
+
+	if _, err := updatehandler.New(cfg, storage, storage); err == nil {
+		t.Error("Error expected for wrong journal cursors timeout")
+	}
+
+	cfg.JournalCursors.Timeout.Duration = 0
+
+	handler := newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	handler.Registered()
+
+	if err := waitForStatus(handler, nil); err != nil {
+		t.Fatalf("Wait for status failed: %s", err)
+	}
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}}, "2.0")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	entries, err := handler.GetUpdateHistory("")
+	if err != nil {
+		t.Fatalf("Can't get update history: %s", err)
+	}
+
+	transitions, operations := 0, 0
+
+	for _, entry := range entries {
+		if entry.Journal == nil || entry.Journal.Start != "s=test;i=1" || entry.Journal.End != "s=test;i=1" {
+			t.Errorf("Wrong journal cursors of entry: %v", entry)
+		}
+
+		switch entry.Type {
+		case updatehistory.EntryTransition:
+			transitions++
+
+		case updatehistory.EntryOperation:
+			operations++
+		}
+	}
+
+	if transitions == 0 || operations == 0 {
+		t.Errorf("Wrong update history: %v", entries)
+	}
+}
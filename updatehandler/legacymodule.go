@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+)
+
+// Legacy module operations don't accept context and can't be interrupted. The adapter checks the context before
+// each operation: canceled operation is not started. Optional interfaces (StateExporter, MetadataProvider) are
+// looked up on the adapted module.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// LegacyUpdateModule interface for module plugin which operations don't accept context.
+type LegacyUpdateModule interface {
+	// GetID returns module ID
+	GetID() (id string)
+	// GetVendorVersion returns vendor version
+	GetVendorVersion() (version string, err error)
+	// Init initializes module
+	Init() (err error)
+	// Prepare prepares module, it is retried if it fails with TransientError
+	Prepare(imagePath string, vendorVersion string, annotations json.RawMessage) (err error)
+	// Update updates module, it is retried if it fails with TransientError
+	Update() (rebootRequired bool, err error)
+	// Apply applies update
+	Apply() (rebootRequired bool, err error)
+	// Revert reverts update
+	Revert() (rebootRequired bool, err error)
+	// Reboot performs module reboot
+	Reboot() (err error)
+	// Close closes update module
+	Close() (err error)
+}
+
+// NewLegacyPlugin legacy update module new function.
+type NewLegacyPlugin func(id string, configJSON json.RawMessage, storage ModuleStorage) (
+	module LegacyUpdateModule, err error)
+
+type legacyAdapter struct {
+	module LegacyUpdateModule
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// RegisterLegacyPlugin registers update plugin which creates legacy modules.
+func RegisterLegacyPlugin(plugin string, newFunc NewLegacyPlugin) {
+	RegisterPlugin(plugin, func(id string, configJSON json.RawMessage, storage ModuleStorage) (
+		module UpdateModule, err error,
+	) {
+		legacyModule, err := newFunc(id, configJSON, storage)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLegacyAdapter(legacyModule), nil
+	})
+}
+
+// NewLegacyAdapter adapts legacy module to update module interface.
+func NewLegacyAdapter(module LegacyUpdateModule) (adapter UpdateModule) {
+	return &legacyAdapter{module: module}
+}
+
+// GetID returns module ID.
+func (adapter *legacyAdapter) GetID() (id string) {
+	return adapter.module.GetID()
+}
+
+// GetVendorVersion returns vendor version.
+func (adapter *legacyAdapter) GetVendorVersion() (version string, err error) {
+	return adapter.module.GetVendorVersion()
+}
+
+// Init initializes module.
+func (adapter *legacyAdapter) Init() (err error) {
+	return adapter.module.Init()
+}
+
+// Prepare prepares module.
+func (adapter *legacyAdapter) Prepare(
+	ctx context.Context, imagePath string, vendorVersion string, annotations json.RawMessage,
+) (err error) {
+	if err = ctx.Err(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return adapter.module.Prepare(imagePath, vendorVersion, annotations)
+}
+
+// Update updates module.
+func (adapter *legacyAdapter) Update(ctx context.Context) (rebootRequired bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	return adapter.module.Update()
+}
+
+// Apply applies update.
+func (adapter *legacyAdapter) Apply(ctx context.Context) (rebootRequired bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	return adapter.module.Apply()
+}
+
+// Revert reverts update.
+func (adapter *legacyAdapter) Revert(ctx context.Context) (rebootRequired bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	return adapter.module.Revert()
+}
+
+// Reboot performs module reboot.
+func (adapter *legacyAdapter) Reboot(ctx context.Context) (err error) {
+	if err = ctx.Err(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return adapter.module.Reboot()
+}
+
+// Close closes module. Legacy module close can't be interrupted: if context is done before close is finished, it
+// is left to complete in background.
+func (adapter *legacyAdapter) Close(ctx context.Context) (err error) {
+	closeResult := make(chan error, 1)
+
+	go func() {
+		closeResult <- adapter.module.Close()
+	}()
+
+	select {
+	case err = <-closeResult:
+		return err
+
+	case <-ctx.Done():
+		return aoserrors.Wrap(ctx.Err())
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// baseModule returns adapted module to look up optional interfaces.
+func baseModule(module UpdateModule) (base interface{}) {
+	if adapter, ok := module.(*legacyAdapter); ok {
+		return adapter.module
+	}
+
+	return module
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testLegacyModule struct {
+	closeDone chan struct{}
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestLegacyModuleClose(t *testing.T) {
+	module := &testLegacyModule{closeDone: make(chan struct{})}
+	adapter := updatehandler.NewLegacyAdapter(module)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Hanging legacy close is interrupted by context
+
+	if err := adapter.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wrong close error: %v", err)
+	}
+
+	close(module.closeDone)
+
+	if err := adapter.Close(context.Background()); err != nil {
+		t.Errorf("Close error: %s", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * testLegacyModule
+ **********************************************************************************************************************/
+
+func (module *testLegacyModule) GetID() (id string) {
+	return "legacy"
+}
+
+func (module *testLegacyModule) GetVendorVersion() (version string, err error) {
+	return "", nil
+}
+
+func (module *testLegacyModule) Init() (err error) {
+	return nil
+}
+
+func (module *testLegacyModule) Prepare(imagePath string, vendorVersion string, annotations json.RawMessage) (
+	err error,
+) {
+	return nil
+}
+
+func (module *testLegacyModule) Update() (rebootRequired bool, err error) {
+	return false, nil
+}
+
+func (module *testLegacyModule) Apply() (rebootRequired bool, err error) {
+	return false, nil
+}
+
+func (module *testLegacyModule) Revert() (rebootRequired bool, err error) {
+	return false, nil
+}
+
+func (module *testLegacyModule) Reboot() (err error) {
+	return nil
+}
+
+func (module *testLegacyModule) Close() (err error) {
+	<-module.closeDone
+
+	return nil
+}
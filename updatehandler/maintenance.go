@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Maintenance request runs single action for remote support without update session. Only actions listed in config
+// are allowed and only while the handler is idle. Refresh version, clear staging and health check by verify
+// command are performed by the handler, module specific actions are delegated to modules which implement
+// maintenance provider. Component actions are recorded into component operation journal.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Maintenance actions.
+const (
+	MaintenanceRefreshVersion = "refreshVersion"
+	MaintenanceClearStaging   = "clearStaging"
+	MaintenanceHealthCheck    = "healthCheck"
+	MaintenanceResyncBoot     = "resyncBoot"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+//nolint:gochecknoglobals
+var maintenanceActions = map[string]bool{
+	MaintenanceRefreshVersion: true,
+	MaintenanceClearStaging:   true,
+	MaintenanceHealthCheck:    true,
+	MaintenanceResyncBoot:     true,
+}
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// MaintenanceRequest maintenance request. Component ID is not used by clear staging action.
+type MaintenanceRequest struct {
+	ID     string `json:"id,omitempty"`
+	Action string `json:"action"`
+}
+
+// MaintenanceProvider interface for update module which supports maintenance actions.
+type MaintenanceProvider interface {
+	// GetMaintenanceActions returns maintenance actions supported by module
+	GetMaintenanceActions() (actions []string)
+	// RunMaintenance runs maintenance action
+	RunMaintenance(ctx context.Context, action string) (err error)
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// RunMaintenance runs maintenance action.
+func (handler *Handler) RunMaintenance(request MaintenanceRequest) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if !handler.allowedMaintenance[request.Action] {
+		return aoserrors.Errorf("maintenance action %s is not allowed", request.Action)
+	}
+
+	if err = handler.checkStopped(); err != nil {
+		return err
+	}
+
+	if handler.state.UpdateState != stateIdle {
+		return aoserrors.Errorf("maintenance is not allowed in %s state", handler.state.UpdateState)
+	}
+
+	log.WithFields(log.Fields{"id": request.ID, "action": request.Action}).Info("Run maintenance action")
+
+	if request.Action == MaintenanceClearStaging {
+		return handler.clearStaging()
+	}
+
+	component, ok := handler.components[request.ID]
+	if !ok {
+		return aoserrors.Errorf("component %s not found", request.ID)
+	}
+
+	startTime := time.Now()
+
+	err = handler.runComponentMaintenance(request.ID, component, request.Action)
+	component.journal.Command("maintenance "+request.Action, startTime, err)
+
+	return err
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkMaintenanceActions(actions []string) (err error) {
+	for _, action := range actions {
+		if !maintenanceActions[action] {
+			return aoserrors.Errorf("unknown maintenance action %s", action)
+		}
+	}
+
+	return nil
+}
+
+func newAllowedMaintenance(actions []string) (allowed map[string]bool) {
+	allowed = make(map[string]bool)
+
+	for _, action := range actions {
+		allowed[action] = true
+	}
+
+	return allowed
+}
+
+func (handler *Handler) runComponentMaintenance(id string, component componentData, action string) (err error) {
+	provider, _ := baseModule(component.module).(MaintenanceProvider)
+	supported := provider != nil && containsString(provider.GetMaintenanceActions(), action)
+
+	switch action {
+	case MaintenanceRefreshVersion:
+		handler.getVersions([]string{id})
+		handler.sendStatus()
+
+		return nil
+
+	case MaintenanceHealthCheck:
+		if component.verifier == nil && !supported {
+			return aoserrors.Errorf("health check is not supported by component %s", id)
+		}
+
+		if component.verifier != nil {
+			if err = component.verifier.verify(id); err != nil || !supported {
+				return err
+			}
+		}
+	}
+
+	if !supported {
+		return aoserrors.Errorf("maintenance action %s is not supported by component %s", action, id)
+	}
+
+	if err = handler.checkFence(id); err != nil {
+		return err
+	}
+
+	return aoserrors.Wrap(provider.RunMaintenance(handler.operationContext(), action))
+}
+
+// clearStaging removes download sessions including interrupted one which would be resumed by next prepare.
+func (handler *Handler) clearStaging() (err error) {
+	if handler.downloadDir == "" {
+		return nil
+	}
+
+	handler.closeDownloadSession()
+	handler.removeOrphanSessions()
+
+	return aoserrors.Wrap(handler.saveState())
+}
+
+func containsString(items []string, value string) (contains bool) {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
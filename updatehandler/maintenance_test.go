@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestMaintenance(t *testing.T) {
+	components = map[string]*testModule{
+		"id1": {id: "id1", vendorVersion: "1.0", maintenance: []string{updatehandler.MaintenanceResyncBoot}},
+		"id2": {id: "id2"},
+	}
+
+	cfg := &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule", Verify: &config.VerifyCommand{Command: "exit 0"}},
+		},
+		MaintenanceActions: []string{
+			updatehandler.MaintenanceRefreshVersion, updatehandler.MaintenanceHealthCheck,
+			updatehandler.MaintenanceResyncBoot,
+		},
+	}
+
+	handler := newTestHandler(t, cfg, withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	order = nil
+
+	for _, item := range []struct {
+		request updatehandler.MaintenanceRequest
+		failed  bool
+	}{
+		{request: updatehandler.MaintenanceRequest{Action: updatehandler.MaintenanceClearStaging}, failed: true},
+		{request: updatehandler.MaintenanceRequest{ID: "id3", Action: updatehandler.MaintenanceResyncBoot}, failed: true},
+		{request: updatehandler.MaintenanceRequest{ID: "id1", Action: updatehandler.MaintenanceResyncBoot}},
+		{request: updatehandler.MaintenanceRequest{ID: "id2", Action: updatehandler.MaintenanceResyncBoot}, failed: true},
+		{request: updatehandler.MaintenanceRequest{ID: "id1", Action: updatehandler.MaintenanceHealthCheck}, failed: true},
+		{request: updatehandler.MaintenanceRequest{ID: "id2", Action: updatehandler.MaintenanceHealthCheck}},
+	} {
+		if err := handler.RunMaintenance(item.request); (err != nil) != item.failed {
+			t.Errorf("Wrong maintenance %s of %s result: %v", item.request.Action, item.request.ID, err)
+		}
+	}
+
+	if err := checkComponentOps(map[string][]string{
+		"id1": {updatehandler.MaintenanceResyncBoot}, "id2": nil,
+	}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	// Refreshed version is reported in status
+
+	components["id1"].vendorVersion = "2.0"
+
+	if err := handler.RunMaintenance(updatehandler.MaintenanceRequest{
+		ID: "id1", Action: updatehandler.MaintenanceRefreshVersion,
+	}); err != nil {
+		t.Errorf("Can't refresh version: %s", err)
+	}
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Error("Wait for status timeout")
+
+	case status := <-handler.StatusChannel():
+		for _, component := range status.Components {
+			if component.ID == "id1" && component.VendorVersion != "2.0" {
+				t.Errorf("Wrong vendor version: %s", component.VendorVersion)
+			}
+		}
+	}
+
+	cfg.MaintenanceActions = append(cfg.MaintenanceActions, "reformat")
+
+	if findings := updatehandler.ValidateConfig(cfg); len(findings) != 1 {
+		t.Errorf("Wrong config findings: %v", findings)
+	}
+}
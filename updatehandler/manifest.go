@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// SBOM/license manifest is passed in update annotations. It is stored at prepare per component Aos version and
+// only manifest of installed version is kept when update is finished.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	manifestsDirName  = "manifests"
+	manifestExtension = ".json"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// ComponentManifest component SBOM/license manifest.
+type ComponentManifest struct {
+	ID            string          `json:"id"`
+	AosVersion    uint64          `json:"aosVersion"`
+	VendorVersion string          `json:"vendorVersion"`
+	Format        string          `json:"format"`
+	Content       json.RawMessage `json:"content"`
+}
+
+type manifestAnnotation struct {
+	Format  string          `json:"format"`
+	Content json.RawMessage `json:"content"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetManifest returns SBOM/license manifest of installed component version.
+func (handler *Handler) GetManifest(id string) (manifest ComponentManifest, err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if handler.manifestsDir == "" {
+		return manifest, aoserrors.New("working dir should be configured for manifests")
+	}
+
+	aosVersion, err := handler.storage.GetAosVersion(id)
+	if err != nil {
+		return manifest, aoserrors.Wrap(err)
+	}
+
+	data, err := os.ReadFile(handler.manifestFile(id, aosVersion))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return manifest, aoserrors.Errorf("manifest of component %s version %d not found", id, aosVersion)
+		}
+
+		return manifest, aoserrors.Wrap(err)
+	}
+
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return manifest, aoserrors.Wrap(err)
+	}
+
+	return manifest, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) storeManifest(updateInfo *umclient.ComponentUpdateInfo) {
+	annotation := getUpdateAnnotations(updateInfo.Annotations).SBOM
+	if annotation == nil {
+		return
+	}
+
+	if handler.manifestsDir == "" {
+		log.WithField("id", updateInfo.ID).Warn("Working dir is not configured, skip manifest")
+
+		return
+	}
+
+	data, err := json.Marshal(ComponentManifest{
+		ID:            updateInfo.ID,
+		AosVersion:    updateInfo.AosVersion,
+		VendorVersion: updateInfo.VendorVersion,
+		Format:        annotation.Format,
+		Content:       annotation.Content,
+	})
+	if err != nil {
+		log.WithField("id", updateInfo.ID).Errorf("Can't marshal manifest: %v", err)
+
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"id": updateInfo.ID, "aosVersion": updateInfo.AosVersion, "format": annotation.Format,
+	}).Debug("Store component manifest")
+
+	if err = writeFileAtomic(handler.manifestFile(updateInfo.ID, updateInfo.AosVersion), data); err != nil {
+		log.WithField("id", updateInfo.ID).Errorf("Can't store manifest: %v", err)
+	}
+}
+
+// cleanupManifests removes manifests of not installed component versions.
+func (handler *Handler) cleanupManifests(ids []string) {
+	if handler.manifestsDir == "" {
+		return
+	}
+
+	for _, id := range ids {
+		aosVersion, err := handler.storage.GetAosVersion(id)
+		if err != nil {
+			log.WithField("id", id).Errorf("Can't get Aos version: %v", err)
+
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(handler.manifestsDir, id))
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.WithField("id", id).Errorf("Can't read manifests dir: %v", err)
+			}
+
+			continue
+		}
+
+		installedName := strconv.FormatUint(aosVersion, 10) + manifestExtension
+
+		for _, entry := range entries {
+			if entry.Name() == installedName || !strings.HasSuffix(entry.Name(), manifestExtension) {
+				continue
+			}
+
+			if err = os.RemoveAll(filepath.Join(handler.manifestsDir, id, entry.Name())); err != nil {
+				log.WithField("id", id).Errorf("Can't remove manifest: %v", err)
+			}
+		}
+	}
+}
+
+func (handler *Handler) manifestFile(id string, aosVersion uint64) (fileName string) {
+	return filepath.Join(handler.manifestsDir, id, strconv.FormatUint(aosVersion, 10)+manifestExtension)
+}
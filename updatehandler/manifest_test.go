@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestComponentManifest(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+	workingDir := path.Join(tmpDir, "manifestWorkingDir")
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   cfg.DownloadDir,
+		WorkingDir:    workingDir,
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	if _, err := handler.GetManifest("id1"); err == nil {
+		t.Error("Error expected for component without manifest")
+	}
+
+	for i := 0; i < 2; i++ {
+		infos, err := createUpdateInfos(currentStatus.Components, "")
+		if err != nil {
+			t.Fatalf("Can't create update infos: %s", err)
+		}
+
+		infos[0].Annotations = json.RawMessage(
+			fmt.Sprintf(`{"sbom": {"format": "spdx-json", "content": {"name": "id1-%d"}}}`, infos[0].AosVersion))
+
+		testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, nil, nil, nil)
+		testOperation(t, handler, handler.StartUpdate, nil, nil, nil)
+
+		currentStatus.Components[0].AosVersion = infos[0].AosVersion
+
+		testOperation(t, handler, handler.ApplyUpdate, &currentStatus, nil, nil)
+	}
+
+	manifest, err := handler.GetManifest("id1")
+	if err != nil {
+		t.Fatalf("Can't get manifest: %s", err)
+	}
+
+	if manifest.AosVersion != 2 || manifest.Format != "spdx-json" || string(manifest.Content) != `{"name":"id1-2"}` {
+		t.Errorf("Wrong manifest: %+v, %s", manifest, manifest.Content)
+	}
+
+	// Only manifest of installed version is kept
+
+	if entries, err := os.ReadDir(path.Join(workingDir, "manifests", "id1")); err != nil || len(entries) != 1 {
+		t.Errorf("Wrong manifests count: %v", err)
+	}
+}
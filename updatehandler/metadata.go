@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"reflect"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Component metadata is requested together with vendor version, so module which doesn't respond in time doesn't
+// block version refresh. Latest received metadata is persisted in update state and reported if module fails to
+// provide it, e.g. when component on remote hardware is not reachable.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// MetadataProvider optional interface which can be implemented by update module to provide vendor specific
+// component metadata: build ID, git commit, BSP version etc.
+type MetadataProvider interface {
+	// GetMetadata returns component key-value metadata
+	GetMetadata() (metadata map[string]string, err error)
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetComponentMetadata returns latest metadata of components by component ID.
+func (handler *Handler) GetComponentMetadata() (metadata map[string]map[string]string) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	metadata = make(map[string]map[string]string)
+
+	for id, componentMetadata := range handler.state.ComponentMetadata {
+		if _, ok := handler.components[id]; !ok {
+			continue
+		}
+
+		metadata[id] = make(map[string]string, len(componentMetadata))
+
+		for key, value := range componentMetadata {
+			metadata[id][key] = value
+		}
+	}
+
+	return metadata
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// getModuleMetadata returns nil if module doesn't provide metadata or can't get it.
+func getModuleMetadata(id string, module UpdateModule) (metadata map[string]string) {
+	provider, ok := baseModule(module).(MetadataProvider)
+	if !ok {
+		return nil
+	}
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		log.WithField("id", id).Errorf("Can't get component metadata: %s", aoserrors.Wrap(err))
+
+		return nil
+	}
+
+	return metadata
+}
+
+func (handler *Handler) setMetadata(id string, metadata map[string]string) {
+	if metadata == nil || reflect.DeepEqual(handler.state.ComponentMetadata[id], metadata) {
+		return
+	}
+
+	log.WithFields(log.Fields{"id": id, "metadata": metadata}).Debug("Component metadata changed")
+
+	if handler.state.ComponentMetadata == nil {
+		handler.state.ComponentMetadata = make(map[string]map[string]string)
+	}
+
+	handler.state.ComponentMetadata[id] = metadata
+
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestComponentMetadata(t *testing.T) {
+	metadata := map[string]string{"buildId": "1234", "gitCommit": "abcdef"}
+
+	components = map[string]*testModule{"id1": {id: "id1", metadata: metadata}, "id2": {id: "id2"}}
+	storage := newTestStorage()
+	moduleCfg := &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}, {ID: "id2", Plugin: "testmodule"}},
+	}
+
+	handler := newTestHandler(t, moduleCfg, withStorage(storage), withModules(components))
+
+	expectedMetadata := map[string]map[string]string{"id1": metadata}
+
+	if currentMetadata := handler.GetComponentMetadata(); !reflect.DeepEqual(currentMetadata, expectedMetadata) {
+		t.Errorf("Wrong component metadata: %v", currentMetadata)
+	}
+
+	handler.Close(context.Background())
+
+	// Persisted metadata is reported if module can't provide it
+
+	components["id1"].metadata = nil
+	components["id1"].metadataErr = aoserrors.New("component is not reachable")
+
+	handler = newTestHandler(t, moduleCfg, withStorage(storage), withModules(components))
+
+	if currentMetadata := handler.GetComponentMetadata(); !reflect.DeepEqual(currentMetadata, expectedMetadata) {
+		t.Errorf("Wrong component metadata: %v", currentMetadata)
+	}
+
+	handler.Close(context.Background())
+
+	// Metadata of not configured component is not reported
+
+	handler = newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id2", Plugin: "testmodule"}},
+	}, withStorage(storage), withModules(components))
+
+	if currentMetadata := handler.GetComponentMetadata(); len(currentMetadata) != 0 {
+		t.Errorf("Wrong component metadata: %v", currentMetadata)
+	}
+}
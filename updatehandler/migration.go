@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Component data is migrated once all components are updated, i.e. after update reboots, so migration is completed
+// before apply. Components are migrated in update priority order within update timeout. Migrated components are
+// persisted: migration is not repeated after restart and is undone in reverse order on revert before modules revert.
+// If migration fails, already migrated components are undone and update fails. Migrations are committed when the
+// handler returns to idle.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// DataMigrator optional interface which can be implemented by update module to migrate component data between vendor
+// versions: config file formats, application DB schema etc.
+type DataMigrator interface {
+	// MigrateData migrates component data of previous vendor version to updated one
+	MigrateData(ctx context.Context, fromVersion, toVersion string) (err error)
+	// UndoDataMigration restores component data of previous vendor version
+	UndoDataMigration(ctx context.Context, fromVersion, toVersion string) (err error)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) migrateData() (err error) {
+	ids := make([]string, 0, len(handler.state.ComponentStatuses))
+
+	for id := range handler.state.ComponentStatuses {
+		if handler.isExcluded(id) {
+			continue
+		}
+
+		if _, ok := handler.getDataMigrator(id); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		iPriority, jPriority := handler.components[ids[i]].updatePriority, handler.components[ids[j]].updatePriority
+
+		if iPriority != jPriority {
+			return iPriority > jPriority
+		}
+
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		if handler.isDataMigrated(id) {
+			continue
+		}
+
+		migrator, _ := handler.getDataMigrator(id)
+		fromVersion, toVersion := handler.getMigrationVersions(id)
+
+		log.WithFields(log.Fields{"id": id, "from": fromVersion, "to": toVersion}).Debug("Migrate component data")
+
+		if err = handler.checkStopped(); err == nil {
+			_, err = handler.runOperation(id, eventUpdate, handler.components[id].updateTimeout,
+				func(ctx context.Context) (rebootRequired bool, err error) {
+					return false, aoserrors.Wrap(migrator.MigrateData(ctx, fromVersion, toVersion))
+				})
+		}
+
+		if err != nil {
+			err = aoserrors.Errorf("can't migrate component %s data: %v", id, err)
+
+			componentError(handler.state.ComponentStatuses[id], err)
+
+			if undoErr := handler.undoDataMigrations(); undoErr != nil {
+				log.Errorf("Can't undo data migrations: %v", undoErr)
+			}
+
+			return err
+		}
+
+		handler.state.MigratedComponents = append(handler.state.MigratedComponents, id)
+
+		if err = handler.saveState(); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func (handler *Handler) undoDataMigrations() (err error) {
+	for i := len(handler.state.MigratedComponents) - 1; i >= 0; i-- {
+		id := handler.state.MigratedComponents[i]
+
+		migrator, ok := handler.getDataMigrator(id)
+		if !ok {
+			continue
+		}
+
+		fromVersion, toVersion := handler.getMigrationVersions(id)
+
+		log.WithFields(log.Fields{"id": id, "from": fromVersion, "to": toVersion}).Debug("Undo component data migration")
+
+		undoErr := handler.checkFence(id)
+		if undoErr == nil {
+			undoErr = migrator.UndoDataMigration(handler.operationContext(), fromVersion, toVersion)
+		}
+
+		if undoErr != nil {
+			log.WithField("id", id).Errorf("Can't undo data migration: %v", undoErr)
+
+			if err == nil {
+				err = aoserrors.Errorf("can't undo component %s data migration: %v", id, undoErr)
+			}
+		}
+	}
+
+	handler.state.MigratedComponents = nil
+
+	if saveErr := handler.saveState(); saveErr != nil && err == nil {
+		err = aoserrors.Wrap(saveErr)
+	}
+
+	return err
+}
+
+func (handler *Handler) getDataMigrator(id string) (migrator DataMigrator, ok bool) {
+	component, ok := handler.components[id]
+	if !ok {
+		return nil, false
+	}
+
+	migrator, ok = baseModule(component.module).(DataMigrator)
+
+	return migrator, ok
+}
+
+func (handler *Handler) isDataMigrated(id string) (migrated bool) {
+	for _, migratedID := range handler.state.MigratedComponents {
+		if migratedID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (handler *Handler) getMigrationVersions(id string) (fromVersion, toVersion string) {
+	if componentStatus, ok := handler.state.ComponentStatuses[id]; ok {
+		toVersion = componentStatus.VendorVersion
+	}
+
+	return handler.state.CurrentVendorVersions[id], toVersion
+}
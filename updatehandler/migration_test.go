@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDataMigration(t *testing.T) {
+	components = map[string]*testModule{
+		"id1": {id: "id1", migrate: true},
+		"id2": {id: "id2", migrate: true, migrateErr: aoserrors.New("migration error")},
+		"id3": {id: "id3"},
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", UpdatePriority: 10},
+			{ID: "id2", Plugin: "testmodule"},
+			{ID: "id3", Plugin: "testmodule"},
+		},
+	}, withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}, {ID: "id3"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	migrationOrder := func() (migrations []orderInfo) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		for _, item := range order {
+			if item.op == opMigrate || item.op == opUndo {
+				migrations = append(migrations, item)
+			}
+		}
+
+		return migrations
+	}
+
+	for _, item := range []struct {
+		state      umclient.UMState
+		migrations []orderInfo
+	}{
+		// Failed migration undoes already migrated components
+		{
+			state: umclient.StateFailed,
+			migrations: []orderInfo{
+				{id: "id1", op: opMigrate}, {id: "id2", op: opMigrate}, {id: "id1", op: opUndo},
+			},
+		},
+		// Revert undoes migrations in reverse order
+		{
+			state: umclient.StateUpdated,
+			migrations: []orderInfo{
+				{id: "id1", op: opMigrate}, {id: "id2", op: opMigrate},
+				{id: "id2", op: opUndo}, {id: "id1", op: opUndo},
+			},
+		},
+	} {
+		handler.PrepareUpdate(infos)
+
+		if err = waitForState(handler, umclient.StatePrepared); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		order = nil
+
+		handler.StartUpdate()
+
+		if err = waitForState(handler, item.state); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		handler.RevertUpdate()
+
+		if err = waitForState(handler, umclient.StateIdle); err != nil {
+			t.Fatalf("Wait for state failed: %s", err)
+		}
+
+		if migrations := migrationOrder(); !reflect.DeepEqual(migrations, item.migrations) {
+			t.Errorf("Wrong migrations: %v", migrations)
+		}
+	}
+
+	// Migrations are committed by apply
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateUpdated); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	order = nil
+
+	handler.ApplyUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	if migrations := migrationOrder(); len(migrations) != 0 {
+		t.Errorf("Unexpected migrations: %v", migrations)
+	}
+}
@@ -0,0 +1,329 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Component image may be served by mirrors listed in mirrors annotation in addition to the update info URL. Mirrors
+// are tried in listed order or, if latency selection is configured, in order of measured HEAD request latency.
+// Image downloaded from a mirror should match update info hashes, otherwise the next mirror is tried. The mirror
+// which served the image and per-mirror failures of the last prepare are kept in download reports for backend CDN
+// debugging, and per-mirror failures are added to the component error if all mirrors failed.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	mirrorSelectionOrder   = "order"
+	mirrorSelectionLatency = "latency"
+	mirrorProbeTimeout     = 5 * time.Second
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// MirrorFailure image download failure of mirror.
+type MirrorFailure struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// DownloadReport component image download report.
+type DownloadReport struct {
+	Mirror   string          `json:"mirror,omitempty"`
+	Failures []MirrorFailure `json:"failures,omitempty"`
+}
+
+type mirrorLatency struct {
+	url     string
+	latency time.Duration
+	err     error
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetDownloadReports returns image download reports of components prepared by the last update.
+func (handler *Handler) GetDownloadReports() (reports map[string]DownloadReport) {
+	handler.mirrorMutex.Lock()
+	defer handler.mirrorMutex.Unlock()
+
+	reports = make(map[string]DownloadReport)
+
+	for id, report := range handler.state.DownloadReports {
+		reports[id] = DownloadReport{
+			Mirror: report.Mirror, Failures: append([]MirrorFailure(nil), report.Failures...),
+		}
+	}
+
+	return reports
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkMirrorSelection(selection string) (err error) {
+	switch selection {
+	case "", mirrorSelectionOrder, mirrorSelectionLatency:
+		return nil
+
+	default:
+		return aoserrors.Errorf("unknown mirror selection %s", selection)
+	}
+}
+
+// getImage returns image of the component downloaded from the first mirror which serves valid image.
+func (handler *Handler) getImage(
+	ctx context.Context, updateInfo *umclient.ComponentUpdateInfo,
+) (filePath string, err error) {
+	// Session image is stored by update info URL regardless of mirror which served it
+	if handler.downloadDir != "" {
+		if filePath = handler.getSessionImage(updateInfo); filePath != "" {
+			return filePath, nil
+		}
+	}
+
+	if handler.prefetch != nil {
+		if filePath = handler.getPrefetchedImage(updateInfo); filePath != "" {
+			return filePath, nil
+		}
+	}
+
+	annotations := getUpdateAnnotations(updateInfo.Annotations)
+	imageURLs := append([]string{updateInfo.URL}, annotations.Mirrors...)
+
+	if handler.mirrorSelection == mirrorSelectionLatency && len(imageURLs) > 1 {
+		imageURLs = handler.sortMirrorsByLatency(ctx, imageURLs, annotations.DownloadHeaders)
+	}
+
+	var report DownloadReport
+
+	for _, imageURL := range imageURLs {
+		if filePath, err = handler.getMirrorImage(ctx, updateInfo, imageURL); err == nil {
+			report.Mirror = imageURL
+
+			break
+		}
+
+		log.WithFields(log.Fields{"id": updateInfo.ID, "url": imageURL}).Warnf("Can't get image: %v", err)
+
+		report.Failures = append(report.Failures, MirrorFailure{URL: imageURL, Error: err.Error()})
+
+		if handler.checkStopped() != nil || ctx.Err() != nil {
+			break
+		}
+	}
+
+	handler.setDownloadReport(updateInfo.ID, report)
+
+	if err != nil {
+		if len(imageURLs) == 1 {
+			return "", err
+		}
+
+		failures := make([]string, 0, len(report.Failures))
+
+		for _, failure := range report.Failures {
+			failures = append(failures, failure.URL+": "+failure.Error)
+		}
+
+		return "", aoserrors.Errorf("all mirrors failed: %s", strings.Join(failures, "; "))
+	}
+
+	if len(imageURLs) > 1 {
+		log.WithFields(log.Fields{"id": updateInfo.ID, "mirror": report.Mirror}).Info("Image served by mirror")
+	}
+
+	if urlVal, err := url.Parse(report.Mirror); err == nil && urlVal.Scheme != "file" {
+		if err = handler.addSessionImage(updateInfo.URL, filePath); err != nil {
+			log.WithField("url", updateInfo.URL).Errorf("Can't add image to download session: %v", err)
+		}
+	}
+
+	return filePath, nil
+}
+
+func (handler *Handler) getMirrorImage(
+	ctx context.Context, updateInfo *umclient.ComponentUpdateInfo, imageURL string,
+) (filePath string, err error) {
+	urlVal, err := url.Parse(imageURL)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	var verifier *streamVerifier
+
+	if urlVal.Scheme != "file" {
+		if handler.downloadDir == "" {
+			return "", aoserrors.New("download dir should be configured for remote image download")
+		}
+
+		verifier = handler.newImageVerifier(ctx, updateInfo)
+
+		if filePath, err = handler.downloadImage(ctx, updateInfo, imageURL, verifier); err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+	} else {
+		filePath = urlVal.Path
+	}
+
+	if verifier.isVerified() {
+		log.WithField("url", imageURL).Debug("Image verified while downloading")
+
+		return filePath, nil
+	}
+
+	if err = handler.checkImage(ctx, filePath, updateInfo); err != nil {
+		if urlVal.Scheme != "file" {
+			handler.removeFromCache(imageURL)
+
+			// Invalid image should not be resumed by next download
+			if removeErr := os.RemoveAll(filePath); removeErr != nil {
+				log.Errorf("Can't remove download file: %v", removeErr)
+			}
+		}
+
+		return "", aoserrors.Wrap(err)
+	}
+
+	return filePath, nil
+}
+
+// sortMirrorsByLatency sorts mirrors by HEAD request latency, mirrors which failed probe are tried last.
+func (handler *Handler) sortMirrorsByLatency(
+	ctx context.Context, imageURLs []string, headers map[string]string,
+) (sorted []string) {
+	latencies := make([]mirrorLatency, len(imageURLs))
+	done := make(chan struct{})
+
+	for i, imageURL := range imageURLs {
+		go func(i int, imageURL string) {
+			latency, err := handler.probeMirror(ctx, imageURL, headers)
+			latencies[i] = mirrorLatency{url: imageURL, latency: latency, err: err}
+
+			done <- struct{}{}
+		}(i, imageURL)
+	}
+
+	for range imageURLs {
+		<-done
+	}
+
+	sort.SliceStable(latencies, func(i, j int) bool {
+		if (latencies[i].err == nil) != (latencies[j].err == nil) {
+			return latencies[i].err == nil
+		}
+
+		return latencies[i].latency < latencies[j].latency
+	})
+
+	sorted = make([]string, 0, len(latencies))
+
+	for _, item := range latencies {
+		log.WithFields(log.Fields{"url": item.url, "latency": item.latency}).Debugf("Mirror probe: %v", item.err)
+
+		sorted = append(sorted, item.url)
+	}
+
+	return sorted
+}
+
+func (handler *Handler) probeMirror(
+	ctx context.Context, imageURL string, headers map[string]string,
+) (latency time.Duration, err error) {
+	urlVal, err := url.Parse(imageURL)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	// Local mirror has no latency
+	if urlVal.Scheme == "file" {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mirrorProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, imageURL, nil)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	for name, value := range headers {
+		if name = http.CanonicalHeaderKey(name); strings.HasPrefix(name, customHeaderPrefix) {
+			req.Header.Set(name, value)
+		}
+	}
+
+	client := &http.Client{}
+
+	if tlsConfig := handler.getHostTLSConfig(urlVal); tlsConfig != nil {
+		client.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}
+	}
+
+	startTime := time.Now()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	latency = time.Since(startTime)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return latency, aoserrors.Errorf("mirror probe status: %s", resp.Status)
+	}
+
+	return latency, nil
+}
+
+func (handler *Handler) setDownloadReport(id string, report DownloadReport) {
+	handler.mirrorMutex.Lock()
+	defer handler.mirrorMutex.Unlock()
+
+	if handler.state.DownloadReports == nil {
+		handler.state.DownloadReports = make(map[string]DownloadReport)
+	}
+
+	handler.state.DownloadReports[id] = report
+}
+
+func (handler *Handler) resetDownloadReports() {
+	handler.mirrorMutex.Lock()
+	defer handler.mirrorMutex.Unlock()
+
+	handler.state.DownloadReports = nil
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDownloadMirrors(t *testing.T) {
+	imagePath := path.Join(tmpDir, "mirrorimage.bin")
+
+	imageInfo, err := createImage(imagePath)
+	if err != nil {
+		t.Fatalf("Can't create image: %s", err)
+	}
+
+	failedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failedServer.Close()
+
+	corruptedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("corrupted image"))
+	}))
+	defer corruptedServer.Close()
+
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			time.Sleep(300 * time.Millisecond)
+		}
+
+		http.ServeFile(w, r, imagePath)
+	}))
+	defer slowServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, imagePath)
+	}))
+	defer server.Close()
+
+	for _, testItem := range []struct {
+		selection        string
+		urls             []string
+		expectedMirror   string
+		expectedFailures []string
+	}{
+		{
+			urls:             []string{failedServer.URL, corruptedServer.URL, server.URL},
+			expectedMirror:   server.URL,
+			expectedFailures: []string{failedServer.URL, corruptedServer.URL},
+		},
+		{
+			urls:             []string{failedServer.URL, corruptedServer.URL},
+			expectedFailures: []string{failedServer.URL, corruptedServer.URL},
+		},
+		{
+			selection:      "latency",
+			urls:           []string{slowServer.URL, failedServer.URL, server.URL},
+			expectedMirror: server.URL,
+		},
+	} {
+		handler := newTestHandler(t, &config.Config{
+			DownloadDir:     path.Join(tmpDir, "downloadDir"),
+			MirrorSelection: testItem.selection,
+			UpdateModules:   []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+		})
+
+		testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+		mirrors := make([]string, 0, len(testItem.urls)-1)
+
+		for _, mirrorURL := range testItem.urls[1:] {
+			mirrors = append(mirrors, mirrorURL+"/mirrorimage.bin")
+		}
+
+		annotations, err := json.Marshal(map[string][]string{"mirrors": mirrors})
+		if err != nil {
+			t.Fatalf("Can't marshal annotations: %s", err)
+		}
+
+		infos := []umclient.ComponentUpdateInfo{{
+			ID: "id1", AosVersion: 1, URL: testItem.urls[0] + "/mirrorimage.bin",
+			Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size, Annotations: annotations,
+		}}
+
+		expectedState := umclient.UMState(umclient.StatePrepared)
+
+		if testItem.expectedMirror == "" {
+			expectedState = umclient.StateFailed
+		}
+
+		handler.PrepareUpdate(infos)
+
+		select {
+		case status := <-handler.StatusChannel():
+			if status.State != expectedState {
+				t.Errorf("Wrong state: %s, error: %s", status.State, status.Error)
+			} else if expectedState == umclient.StateFailed && !strings.HasPrefix(status.Error, "all mirrors failed") {
+				t.Errorf("Wrong error: %s", status.Error)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait status timeout")
+		}
+
+		report := handler.GetDownloadReports()["id1"]
+
+		if testItem.expectedMirror != "" && report.Mirror != testItem.expectedMirror+"/mirrorimage.bin" {
+			t.Errorf("Wrong mirror: %s", report.Mirror)
+		}
+
+		failures := make([]string, 0, len(report.Failures))
+
+		for _, failure := range report.Failures {
+			failures = append(failures, strings.TrimSuffix(failure.URL, "/mirrorimage.bin"))
+		}
+
+		if len(testItem.expectedFailures) == 0 {
+			testItem.expectedFailures = []string{}
+		}
+
+		if !reflect.DeepEqual(failures, testItem.expectedFailures) {
+			t.Errorf("Wrong mirror failures: %v", report.Failures)
+		}
+
+		handler.Close(context.Background())
+	}
+}
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Modules share module storage, so each module gets namespaced view of it. The module may access only entry with
+// its ID and entries with its ID followed by dot separated suffix, e.g. boot environment archive. Entries reserved by
+// the handler, e.g. config hash, are read only for the module. Entries written through the view are framed with
+// SHA-256 checksum: corrupted entry is detected on read and reported as error instead of passing garbage to the
+// module. Entries written before framing was introduced are returned as is. If quota is configured, total size of
+// module entries known in current run, i.e. read or written by the module, is limited. Reserved entries are not
+// counted. Writes which don't increase entry size are always allowed, so the module can recover by shrinking its
+// state.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const moduleEntryMagic = "\x00AOSMS\x01"
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+//nolint:gochecknoglobals
+var reservedModuleEntries = []string{configHashSuffix}
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type moduleStorage struct {
+	sync.Mutex
+
+	id      string
+	storage ModuleStorage
+	quota   uint64
+	sizes   map[string]uint64
+	total   uint64
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// LoadModuleJSON decodes JSON document stored in module storage entry. Document is not changed if entry is empty.
+func LoadModuleJSON(storage ModuleStorage, key string, doc interface{}) (err error) {
+	data, err := storage.GetModuleState(key)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if err = json.Unmarshal(data, doc); err != nil {
+		return aoserrors.Errorf("can't decode module storage entry %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// StoreModuleJSON stores JSON document into module storage entry.
+func StoreModuleJSON(storage ModuleStorage, key string, doc interface{}) (err error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(storage.SetModuleState(key, data))
+}
+
+// GetModuleState returns module storage entry.
+func (storage *moduleStorage) GetModuleState(key string) (state []byte, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	if err = storage.checkKey(key, false); err != nil {
+		return nil, err
+	}
+
+	data, err := storage.storage.GetModuleState(key)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if !storage.isReserved(key) {
+		storage.setSize(key, uint64(len(data)))
+	}
+
+	return decodeModuleEntry(key, data)
+}
+
+// SetModuleState sets module storage entry.
+func (storage *moduleStorage) SetModuleState(key string, state []byte) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	if err = storage.checkKey(key, true); err != nil {
+		return err
+	}
+
+	size, ok := storage.sizes[key]
+	if !ok {
+		current, getErr := storage.storage.GetModuleState(key)
+		if getErr != nil {
+			return aoserrors.Wrap(getErr)
+		}
+
+		size = uint64(len(current))
+		storage.setSize(key, size)
+	}
+
+	data := encodeModuleEntry(state)
+	newSize := uint64(len(data))
+
+	if storage.quota != 0 && newSize > size && storage.total-size+newSize > storage.quota {
+		log.WithFields(log.Fields{"id": storage.id, "key": key}).Error("Module storage quota exceeded")
+
+		return aoserrors.Errorf("module %s storage quota %d exceeded", storage.id, storage.quota)
+	}
+
+	if err = storage.storage.SetModuleState(key, data); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	storage.setSize(key, newSize)
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newModuleStorage(id string, storage ModuleStorage, quota uint64) (namespaced ModuleStorage) {
+	if storage == nil {
+		return nil
+	}
+
+	return &moduleStorage{id: id, storage: storage, quota: quota, sizes: make(map[string]uint64)}
+}
+
+func (storage *moduleStorage) checkKey(key string, write bool) (err error) {
+	if key != storage.id && !strings.HasPrefix(key, storage.id+".") {
+		return aoserrors.Errorf("module %s can't access storage entry %s", storage.id, key)
+	}
+
+	if write && storage.isReserved(key) {
+		return aoserrors.Errorf("storage entry %s is reserved", key)
+	}
+
+	return nil
+}
+
+func (storage *moduleStorage) isReserved(key string) (reserved bool) {
+	for _, suffix := range reservedModuleEntries {
+		if key == storage.id+suffix {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (storage *moduleStorage) setSize(key string, size uint64) {
+	storage.total = storage.total - storage.sizes[key] + size
+	storage.sizes[key] = size
+}
+
+func encodeModuleEntry(state []byte) (data []byte) {
+	if len(state) == 0 {
+		return nil
+	}
+
+	sum := sha256.Sum256(state)
+
+	data = make([]byte, 0, len(moduleEntryMagic)+len(sum)+len(state))
+	data = append(data, moduleEntryMagic...)
+	data = append(data, sum[:]...)
+
+	return append(data, state...)
+}
+
+func decodeModuleEntry(key string, data []byte) (state []byte, err error) {
+	if !bytes.HasPrefix(data, []byte(moduleEntryMagic)) {
+		return data, nil
+	}
+
+	data = data[len(moduleEntryMagic):]
+
+	if len(data) < sha256.Size {
+		return nil, aoserrors.Errorf("module storage entry %s is corrupted", key)
+	}
+
+	if sum := sha256.Sum256(data[sha256.Size:]); !bytes.Equal(sum[:], data[:sha256.Size]) {
+		return nil, aoserrors.Errorf("module storage entry %s is corrupted", key)
+	}
+
+	return data[sha256.Size:], nil
+}
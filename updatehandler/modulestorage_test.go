@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestModuleStorage(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}, "id2": {id: "id2"}}
+	storage := newTestStorage()
+
+	newTestHandler(t, &config.Config{
+		ModuleStorageQuota: 256,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	}, withStorage(storage), withModules(components))
+
+	moduleStorage := components["id1"].storage
+
+	type moduleState struct {
+		Value int `json:"value"`
+	}
+
+	// Module accesses only its namespace, handler entries are read only
+
+	for _, key := range []string{"id1", "id1.bootenv"} {
+		if err := updatehandler.StoreModuleJSON(moduleStorage, key, moduleState{Value: 1}); err != nil {
+			t.Errorf("Can't store module entry %s: %s", key, err)
+		}
+
+		var state moduleState
+
+		if err := updatehandler.LoadModuleJSON(moduleStorage, key, &state); err != nil || state.Value != 1 {
+			t.Errorf("Wrong module entry %s: %v, %v", key, state, err)
+		}
+	}
+
+	for _, key := range []string{"id2", "id10", "id2.bootenv", "id1.confighash"} {
+		if err := moduleStorage.SetModuleState(key, []byte("state")); err == nil {
+			t.Errorf("Error expected for entry %s", key)
+		}
+	}
+
+	if _, err := moduleStorage.GetModuleState("id2"); err == nil {
+		t.Error("Error expected")
+	}
+
+	if _, err := moduleStorage.GetModuleState("id1.confighash"); err != nil {
+		t.Errorf("Can't get module entry: %s", err)
+	}
+
+	// Quota limits growth, shrinking is allowed
+
+	if err := moduleStorage.SetModuleState("id1.big", make([]byte, 256)); err == nil {
+		t.Error("Error expected")
+	}
+
+	if err := moduleStorage.SetModuleState("id1.bootenv", nil); err != nil {
+		t.Errorf("Can't set module entry: %s", err)
+	}
+
+	if err := moduleStorage.SetModuleState("id1.big", make([]byte, 128)); err != nil {
+		t.Errorf("Can't set module entry: %s", err)
+	}
+
+	// Corrupted entry is detected, entry written without checksum is accepted
+
+	data, _ := storage.GetModuleState("id1")
+	data[len(data)-2] ^= 0xff
+
+	if err := storage.SetModuleState("id1", data); err != nil {
+		t.Fatalf("Can't set module state: %s", err)
+	}
+
+	if _, err := moduleStorage.GetModuleState("id1"); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Errorf("Corrupted error expected: %v", err)
+	}
+
+	if err := storage.SetModuleState("id1", []byte(`{"value": 2}`)); err != nil {
+		t.Fatalf("Can't set module state: %s", err)
+	}
+
+	var state moduleState
+
+	if err := updatehandler.LoadModuleJSON(moduleStorage, "id1", &state); err != nil || state.Value != 2 {
+		t.Errorf("Wrong module entry: %v, %v", state, err)
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Component which requested reboot is reported as pending action till its reboot is performed, so HMI can tell the
+// driver which components wait for restart instead of inferring it from generic update state. Pending actions are
+// guarded by own lock, not by handler lock, as module reboot may block, e.g. when the module postpones it till the
+// vehicle is parked. Components of reboot group report reboot type and deadline of the shared group reboot once it
+// is started. Pending actions are not persisted: resumed operation requests reboot again if it is still required.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Pending actions.
+const (
+	PendingActionReboot = "reboot"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// PendingAction component action pending to finish update.
+type PendingAction struct {
+	ID          string     `json:"id"`
+	Action      string     `json:"action"`
+	Phase       string     `json:"phase"`
+	RebootGroup string     `json:"rebootGroup,omitempty"`
+	RebootType  string     `json:"rebootType,omitempty"`
+	RequestTime time.Time  `json:"requestTime"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+}
+
+// RebootDeadlineProvider optional interface which can be implemented by update module which postpones reboot.
+type RebootDeadlineProvider interface {
+	// GetRebootDeadline returns time when postponed reboot is forced, zero time if it is not known
+	GetRebootDeadline() (deadline time.Time)
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// PendingActions returns component actions pending to finish update sorted by component ID.
+func (handler *Handler) PendingActions() (actions []PendingAction) {
+	handler.pendingMutex.Lock()
+	defer handler.pendingMutex.Unlock()
+
+	actions = make([]PendingAction, 0, len(handler.pendingActions))
+
+	for _, action := range handler.pendingActions {
+		actionCopy := *action
+
+		if action.Deadline != nil {
+			deadline := *action.Deadline
+			actionCopy.Deadline = &deadline
+		}
+
+		actions = append(actions, actionCopy)
+	}
+
+	sort.Slice(actions, func(i, j int) bool { return actions[i].ID < actions[j].ID })
+
+	return actions
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) addPendingReboot(id, phase, rebootGroup, rebootType string) {
+	handler.pendingMutex.Lock()
+	defer handler.pendingMutex.Unlock()
+
+	if handler.pendingActions == nil {
+		handler.pendingActions = make(map[string]*PendingAction)
+	}
+
+	handler.pendingActions[id] = &PendingAction{
+		ID: id, Action: PendingActionReboot, Phase: phase, RebootGroup: rebootGroup, RebootType: rebootType,
+		RequestTime: handler.clock.Now(),
+	}
+}
+
+// startPendingReboot sets reboot type and deadline of group reboot to pending actions of group components.
+func (handler *Handler) startPendingReboot(group *rebootGroup) {
+	deadline := getRebootDeadline(group.module)
+
+	handler.pendingMutex.Lock()
+	defer handler.pendingMutex.Unlock()
+
+	for _, componentStatus := range group.statuses {
+		action, ok := handler.pendingActions[componentStatus.ID]
+		if !ok {
+			continue
+		}
+
+		action.RebootType = group.rebootType
+		action.Deadline = deadline
+	}
+}
+
+func (handler *Handler) removePendingActions(componentStatuses []*umclient.ComponentStatusInfo) {
+	handler.pendingMutex.Lock()
+	defer handler.pendingMutex.Unlock()
+
+	for _, componentStatus := range componentStatuses {
+		delete(handler.pendingActions, componentStatus.ID)
+	}
+}
+
+func (handler *Handler) clearPendingActions() {
+	handler.pendingMutex.Lock()
+	defer handler.pendingMutex.Unlock()
+
+	handler.pendingActions = nil
+}
+
+func getRebootDeadline(module UpdateModule) (deadline *time.Time) {
+	provider, ok := baseModule(module).(RebootDeadlineProvider)
+	if !ok {
+		return nil
+	}
+
+	moduleDeadline := provider.GetRebootDeadline()
+	if moduleDeadline.IsZero() {
+		return nil
+	}
+
+	log.WithFields(log.Fields{"id": module.GetID(), "deadline": moduleDeadline}).Debug("Reboot is postponed")
+
+	return &moduleDeadline
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestPendingActions(t *testing.T) {
+	cfg := &config.Config{
+		DownloadDir: path.Join(tmpDir, "downloadDir"),
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", RebootPriority: 1, RebootGroup: "soc"},
+			{ID: "id2", Plugin: "testmodule", RebootPriority: 2, RebootGroup: "soc"},
+			{ID: "id3", Plugin: "testmodule", RebootPriority: 1},
+		},
+	}
+
+	order = nil
+
+	handler := newTestHandler(t, cfg)
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+			{ID: "id3", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus,
+		map[string][]string{"id1": {opInit}, "id2": {opInit}, "id3": {opInit}}, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+
+	for _, info := range infos {
+		newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+			ID:            info.ID,
+			AosVersion:    info.AosVersion,
+			VendorVersion: info.VendorVersion,
+			Status:        umclient.StatusInstalling,
+		})
+	}
+
+	newStatus.State = umclient.StatePrepared
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus,
+		map[string][]string{"id1": {opPrepare}, "id2": {opPrepare}, "id3": {opPrepare}}, nil)
+
+	if actions := handler.PendingActions(); len(actions) != 0 {
+		t.Errorf("Unexpected pending actions: %v", actions)
+	}
+
+	// Group reboot performed by id2 is postponed: group components report group reboot type and deadline
+
+	deadline := time.Now().Add(time.Hour).Round(0)
+	rebootBlock := make(chan struct{})
+
+	for _, component := range components {
+		component.rebootRequired = true
+	}
+
+	components["id1"].rebootType = updatehandler.RebootWarm
+	components["id2"].rebootType = updatehandler.RebootServiceRestart
+	components["id2"].rebootDeadline = deadline
+	components["id2"].rebootBlock = rebootBlock
+
+	order = nil
+
+	handler.StartUpdate()
+
+	var actions []updatehandler.PendingAction
+
+	for i := 0; ; i++ {
+		if actions = handler.PendingActions(); len(actions) == 3 && actions[0].Deadline != nil {
+			break
+		}
+
+		if i == 100 {
+			close(rebootBlock)
+			t.Fatalf("Wrong pending actions: %v", actions)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expectedActions := []updatehandler.PendingAction{
+		{
+			ID: "id1", Action: updatehandler.PendingActionReboot, Phase: "update", RebootGroup: "soc",
+			RebootType: updatehandler.RebootWarm, Deadline: &deadline,
+		},
+		{
+			ID: "id2", Action: updatehandler.PendingActionReboot, Phase: "update", RebootGroup: "soc",
+			RebootType: updatehandler.RebootWarm, Deadline: &deadline,
+		},
+		{
+			ID: "id3", Action: updatehandler.PendingActionReboot, Phase: "update",
+			RebootType: updatehandler.RebootFull,
+		},
+	}
+
+	for i, action := range actions {
+		if action.RequestTime.IsZero() {
+			t.Errorf("Request time of %s is not set", action.ID)
+		}
+
+		action.RequestTime = time.Time{}
+
+		if !reflect.DeepEqual(action, expectedActions[i]) {
+			t.Errorf("Wrong pending action: %v", action)
+		}
+	}
+
+	// Pending actions are removed once components are rebooted
+
+	newStatus.State = umclient.StateUpdated
+
+	testOperation(t, handler, func() { close(rebootBlock) }, &newStatus,
+		map[string][]string{
+			"id1": {opUpdate, opUpdate},
+			"id2": {opUpdate, opReboot, opUpdate},
+			"id3": {opUpdate, opReboot, opUpdate},
+		}, nil)
+
+	if actions := handler.PendingActions(); len(actions) != 0 {
+		t.Errorf("Unexpected pending actions: %v", actions)
+	}
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"debug/elf"
+	"path/filepath"
+	"plugin"
+	"sync"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Besides plugins compiled in, update plugins can be loaded from Go plugin shared object files, e.g. proprietary
+// flashers shipped by board vendors. Plugin file registers its update plugins and validators by RegisterPlugin and
+// RegisterValidator in init function the same way as compiled in plugins do. It should be built with the same Go
+// version and dependencies as update manager. Plugin files are loaded once per process: loaded plugin can't be
+// unloaded or reloaded.
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var (
+	pluginFilesMutex  sync.Mutex              //nolint:gochecknoglobals
+	loadedPluginFiles = make(map[string]bool) //nolint:gochecknoglobals
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// LoadPlugins loads update plugins from Go plugin shared object files.
+func LoadPlugins(files []string) (err error) {
+	for _, file := range files {
+		if err = loadPluginFile(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// checkPluginFile checks that plugin file is ELF shared object without loading it.
+func checkPluginFile(file string) (err error) {
+	elfFile, err := elf.Open(file)
+	if err != nil {
+		return aoserrors.Errorf("wrong plugin file %s: %v", file, err)
+	}
+	defer elfFile.Close()
+
+	if elfFile.Type != elf.ET_DYN {
+		return aoserrors.Errorf("wrong plugin file %s: not shared object", file)
+	}
+
+	return nil
+}
+
+func loadPluginFile(file string) (err error) {
+	pluginFilesMutex.Lock()
+	defer pluginFilesMutex.Unlock()
+
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if loadedPluginFiles[absFile] {
+		return nil
+	}
+
+	log.WithField("file", absFile).Info("Load plugin file")
+
+	registered := len(plugins)
+
+	if _, err = plugin.Open(absFile); err != nil {
+		return aoserrors.Errorf("can't load plugin file %s: %v", file, err)
+	}
+
+	loadedPluginFiles[absFile] = true
+
+	if len(plugins) == registered {
+		return aoserrors.Errorf("plugin file %s doesn't register update plugins", file)
+	}
+
+	return nil
+}
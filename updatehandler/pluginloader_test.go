@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestLoadPlugins(t *testing.T) {
+	wrongFile := path.Join(tmpDir, "wrongplugin.so")
+
+	if err := os.WriteFile(wrongFile, []byte("not a shared object"), 0o600); err != nil {
+		t.Fatalf("Can't write plugin file: %s", err)
+	}
+
+	for _, file := range []string{path.Join(tmpDir, "notexist.so"), wrongFile} {
+		if err := updatehandler.LoadPlugins([]string{file}); err == nil {
+			t.Errorf("Error expected for plugin file %s", file)
+		}
+
+		if _, err := updatehandler.New(&config.Config{
+			PluginFiles: []string{file}, UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+		}, newTestStorage(), newTestStorage()); err == nil {
+			t.Errorf("Error expected for plugin file %s", file)
+		}
+
+		findings := updatehandler.ValidateConfig(&config.Config{PluginFiles: []string{file}})
+		if len(findings) != 1 || !strings.Contains(findings[0].Message, "wrong plugin file") {
+			t.Errorf("Wrong findings: %v", findings)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugintest_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+// Plugin file loading is tested in separate package: plugin file should be built with the same packages as the
+// loading binary, but test binary of update handler package includes update handler internal test files. The plugin
+// is built with the build flags of the test binary which change compiled packages, such as build tags and race
+// detector, otherwise it is rejected as built with different version of packages.
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestLoadPluginFile(t *testing.T) {
+	pluginFile := buildPlugin(t)
+
+	cfg := &config.Config{
+		PluginFiles:   []string{pluginFile},
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "sharedobjectmodule"}},
+	}
+
+	// Config check doesn't load plugin file
+
+	if findings := updatehandler.ValidateConfig(cfg); len(findings) != 0 {
+		t.Errorf("Unexpected findings: %v", findings)
+	}
+
+	if _, err := updatehandler.NewModule("sharedobjectmodule", "id1", nil, nil); err == nil {
+		t.Error("Plugin should not be loaded by config check")
+	}
+
+	// Loaded plugin file registers plugin and its validator
+
+	if err := updatehandler.LoadPlugins(cfg.PluginFiles); err != nil {
+		t.Fatalf("Can't load plugins: %s", err)
+	}
+
+	if err := updatehandler.LoadPlugins(cfg.PluginFiles); err != nil {
+		t.Errorf("Can't load plugins again: %s", err)
+	}
+
+	if err := updatehandler.ValidateModuleParams(
+		"sharedobjectmodule", json.RawMessage(`{"device": "/dev/sda"}`)); err != nil {
+		t.Errorf("Can't validate module params: %s", err)
+	}
+
+	if err := updatehandler.ValidateModuleParams(
+		"sharedobjectmodule", json.RawMessage(`{"disk": "/dev/sda"}`)); err == nil {
+		t.Error("Error expected for wrong module params")
+	}
+
+	module, err := updatehandler.NewModule("sharedobjectmodule", "id1", nil, nil)
+	if err != nil {
+		t.Fatalf("Can't create module: %s", err)
+	}
+
+	if module.GetID() != "id1" {
+		t.Errorf("Wrong module ID: %s", module.GetID())
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func buildPlugin(t *testing.T) (pluginFile string) {
+	t.Helper()
+
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skipf("Go tool not found: %s", err)
+	}
+
+	pluginFile = filepath.Join(t.TempDir(), "plugin.so")
+
+	args := append(append([]string{"build", "-buildmode=plugin"}, testBuildFlags()...),
+		"-o", pluginFile, "./testdata/plugin")
+
+	output, err := exec.Command(goTool, args...).CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "-buildmode=plugin not supported") {
+			t.Skipf("Plugins are not supported: %s", output)
+		}
+
+		t.Fatalf("Can't build plugin: %s, output: %s", err, output)
+	}
+
+	return pluginFile
+}
+
+// testBuildFlags returns build flags of the test binary which should be used to build the plugin.
+func testBuildFlags() (flags []string) {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "-tags", "-gcflags", "-asmflags":
+			flags = append(flags, setting.Key+"="+setting.Value)
+
+		case "-race", "-msan", "-asan":
+			if setting.Value == "true" {
+				flags = append(flags, setting.Key)
+			}
+		}
+	}
+
+	return flags
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type sharedObjectModule struct {
+	id string
+}
+
+type moduleParams struct {
+	Device string `json:"device"`
+}
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	updatehandler.RegisterPlugin("sharedobjectmodule",
+		func(id string, configJSON json.RawMessage,
+			storage updatehandler.ModuleStorage,
+		) (module updatehandler.UpdateModule, err error) {
+			return &sharedObjectModule{id: id}, nil
+		})
+
+	updatehandler.RegisterValidator("sharedobjectmodule", func(params json.RawMessage) (err error) {
+		var moduleParams moduleParams
+
+		return updatehandler.DecodeParams(params, &moduleParams)
+	})
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func main() {}
+
+/***********************************************************************************************************************
+ * sharedObjectModule
+ **********************************************************************************************************************/
+
+func (module *sharedObjectModule) GetID() (id string) {
+	return module.id
+}
+
+func (module *sharedObjectModule) GetVendorVersion() (version string, err error) {
+	return "1.0.0", nil
+}
+
+func (module *sharedObjectModule) Init() (err error) {
+	return nil
+}
+
+func (module *sharedObjectModule) Prepare(
+	ctx context.Context, imagePath string, vendorVersion string, annotations json.RawMessage,
+) (err error) {
+	return aoserrors.New("not supported")
+}
+
+func (module *sharedObjectModule) Update(ctx context.Context) (rebootRequired bool, err error) {
+	return false, aoserrors.New("not supported")
+}
+
+func (module *sharedObjectModule) Apply(ctx context.Context) (rebootRequired bool, err error) {
+	return false, aoserrors.New("not supported")
+}
+
+func (module *sharedObjectModule) Revert(ctx context.Context) (rebootRequired bool, err error) {
+	return false, nil
+}
+
+func (module *sharedObjectModule) Reboot(ctx context.Context) (err error) {
+	return nil
+}
+
+func (module *sharedObjectModule) Close(ctx context.Context) (err error) {
+	return nil
+}
@@ -0,0 +1,613 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/image"
+	"github.com/cavaliergopher/grab/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+// Backend may hint component infos of a future campaign. Hinted images are downloaded in background into prefetch
+// dir only while prefetch is allowed: within configured time windows and while no pause flag is set. When prefetch
+// becomes disallowed, running download is canceled and resumed from the partial file in the next allowed slice.
+// Downloaded image is verified against hinted hashes and stored by its SHA-256 digest, so prepare of the real
+// update takes prefetched image with the same digest instead of downloading it. Hints are stored in prefetch dir
+// and replaced by next hints, images which are not hinted anymore are removed.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	defaultPrefetchPollInterval = time.Minute
+	prefetchHintsFileName       = "hints.json"
+	prefetchPartialExt          = ".part"
+	prefetchWindowLayout        = "15:04"
+	prefetchedImagePrefix       = "prefetched-"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// PrefetchStatus prefetch status of hinted image.
+type PrefetchStatus struct {
+	ID    string `json:"id"`
+	URL   string `json:"url"`
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+type prefetchHint struct {
+	ID      string            `json:"id"`
+	URL     string            `json:"url"`
+	Sha256  []byte            `json:"sha256"`
+	Sha512  []byte            `json:"sha512"`
+	Size    uint64            `json:"size"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type prefetchWindow struct {
+	start int
+	end   int
+}
+
+type prefetcher struct {
+	sync.Mutex
+
+	handler      *Handler
+	clock        clock.Clock
+	dir          string
+	rateLimit    uint64
+	windows      []prefetchWindow
+	pauseFlags   []updateBlocker
+	pollInterval time.Duration
+	hints        []prefetchHint
+	errors       map[string]string
+	wakeChan     chan struct{}
+	closeChan    chan struct{}
+	wg           sync.WaitGroup
+}
+
+type prefetchRateLimiter struct {
+	clock     clock.Clock
+	rate      uint64
+	startTime time.Time
+	bytes     uint64
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// PrefetchImages replaces prefetch hints by component infos of a future update.
+func (handler *Handler) PrefetchImages(infos []umclient.ComponentUpdateInfo) (err error) {
+	if handler.prefetch == nil {
+		return aoserrors.New("prefetch is disabled")
+	}
+
+	hints := make([]prefetchHint, 0, len(infos))
+
+	for _, info := range infos {
+		if len(info.Sha256) == 0 {
+			return aoserrors.Errorf("component %s: SHA-256 digest is required for prefetch", info.ID)
+		}
+
+		if !strings.HasPrefix(info.URL, "http://") && !strings.HasPrefix(info.URL, "https://") {
+			return aoserrors.Errorf("component %s: only HTTP images can be prefetched", info.ID)
+		}
+
+		hints = append(hints, prefetchHint{
+			ID: info.ID, URL: info.URL, Sha256: info.Sha256, Sha512: info.Sha512, Size: info.Size,
+			Headers: getUpdateAnnotations(info.Annotations).DownloadHeaders,
+		})
+	}
+
+	return handler.prefetch.setHints(hints)
+}
+
+// GetPrefetchStatus returns prefetch status of hinted images.
+func (handler *Handler) GetPrefetchStatus() (statuses []PrefetchStatus) {
+	if handler.prefetch == nil {
+		return nil
+	}
+
+	return handler.prefetch.getStatus()
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// getPrefetchedImage returns prefetched image of the component. The image is linked into download session to keep
+// it till the update is finished even if hints are replaced.
+func (handler *Handler) getPrefetchedImage(updateInfo *umclient.ComponentUpdateInfo) (filePath string) {
+	if filePath = handler.prefetch.getImage(updateInfo); filePath == "" {
+		return ""
+	}
+
+	log.WithFields(log.Fields{"id": updateInfo.ID, "file": filePath}).Info("Use prefetched image")
+
+	if handler.downloadDir == "" {
+		return filePath
+	}
+
+	sessionPath := filepath.Join(handler.sessionDir(), prefetchedImagePrefix+filepath.Base(filePath))
+
+	if err := linkOrCopyFile(filePath, sessionPath); err != nil {
+		log.WithField("id", updateInfo.ID).Errorf("Can't add prefetched image to download session: %v", err)
+
+		return filePath
+	}
+
+	if err := handler.addSessionImage(updateInfo.URL, sessionPath); err != nil {
+		log.WithField("url", updateInfo.URL).Errorf("Can't add image to download session: %v", err)
+	}
+
+	return sessionPath
+}
+
+func checkPrefetchConfig(cfg config.Prefetch) (err error) {
+	if _, err = parsePrefetchWindows(cfg.Windows); err != nil {
+		return err
+	}
+
+	return checkUpdateBlockers(config.UpdateBlockers{DBusFlags: cfg.PauseFlags})
+}
+
+func newPrefetcher(handler *Handler, cfg config.Prefetch) (prefetch *prefetcher, err error) {
+	if cfg.Dir == "" {
+		return nil, nil //nolint:nilnil // Prefetch is disabled
+	}
+
+	if err = checkPrefetchConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	prefetch = &prefetcher{
+		handler:      handler,
+		clock:        handler.clock,
+		dir:          cfg.Dir,
+		rateLimit:    cfg.RateLimit,
+		pauseFlags:   newUpdateBlockers(config.UpdateBlockers{DBusFlags: cfg.PauseFlags}),
+		pollInterval: cfg.PollInterval.Duration,
+		errors:       make(map[string]string),
+		wakeChan:     make(chan struct{}, 1),
+		closeChan:    make(chan struct{}),
+	}
+
+	if prefetch.pollInterval == 0 {
+		prefetch.pollInterval = defaultPrefetchPollInterval
+	}
+
+	if prefetch.windows, err = parsePrefetchWindows(cfg.Windows); err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(prefetch.dir, 0o755); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(prefetch.dir, prefetchHintsFileName)); err == nil {
+		if err = json.Unmarshal(data, &prefetch.hints); err != nil {
+			log.Errorf("Can't parse prefetch hints: %v", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return prefetch, nil
+}
+
+func (prefetch *prefetcher) start() {
+	prefetch.wg.Add(1)
+
+	go prefetch.run()
+}
+
+func (prefetch *prefetcher) close() {
+	close(prefetch.closeChan)
+	prefetch.wg.Wait()
+}
+
+func (prefetch *prefetcher) setHints(hints []prefetchHint) (err error) {
+	prefetch.Lock()
+	defer prefetch.Unlock()
+
+	log.WithField("count", len(hints)).Info("Set prefetch hints")
+
+	data, err := json.Marshal(hints)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = writeFileAtomic(filepath.Join(prefetch.dir, prefetchHintsFileName), data); err != nil {
+		return err
+	}
+
+	prefetch.hints = hints
+	prefetch.errors = make(map[string]string)
+
+	prefetch.removeNotHinted()
+
+	select {
+	case prefetch.wakeChan <- struct{}{}:
+
+	default:
+	}
+
+	return nil
+}
+
+func (prefetch *prefetcher) getStatus() (statuses []PrefetchStatus) {
+	prefetch.Lock()
+	defer prefetch.Unlock()
+
+	statuses = make([]PrefetchStatus, 0, len(prefetch.hints))
+
+	for _, hint := range prefetch.hints {
+		statuses = append(statuses, PrefetchStatus{
+			ID: hint.ID, URL: hint.URL, Done: prefetch.isDone(hint), Error: prefetch.errors[hint.URL],
+		})
+	}
+
+	return statuses
+}
+
+// getImage returns prefetched image which matches update info digest.
+func (prefetch *prefetcher) getImage(updateInfo *umclient.ComponentUpdateInfo) (filePath string) {
+	prefetch.Lock()
+	defer prefetch.Unlock()
+
+	if len(updateInfo.Sha256) == 0 {
+		return ""
+	}
+
+	filePath = prefetch.imagePath(updateInfo.Sha256)
+
+	if err := image.CheckFileInfo(context.Background(), filePath, image.FileInfo{
+		Sha256: updateInfo.Sha256, Sha512: updateInfo.Sha512, Size: updateInfo.Size,
+	}); err != nil {
+		return ""
+	}
+
+	return filePath
+}
+
+// hasImage checks if image of the same size is prefetched without checking its hash.
+func (prefetch *prefetcher) hasImage(updateInfo *umclient.ComponentUpdateInfo) (ok bool) {
+	prefetch.Lock()
+	defer prefetch.Unlock()
+
+	if len(updateInfo.Sha256) == 0 {
+		return false
+	}
+
+	info, err := os.Stat(prefetch.imagePath(updateInfo.Sha256))
+
+	return err == nil && uint64(info.Size()) == updateInfo.Size
+}
+
+func (prefetch *prefetcher) run() {
+	defer prefetch.wg.Done()
+
+	for {
+		hint, ok := prefetch.nextHint()
+
+		if ok && prefetch.allowed() {
+			err := prefetch.download(hint)
+			if err == nil {
+				continue
+			}
+
+			// Canceled download is resumed in next time slice
+			if !errors.Is(err, context.Canceled) {
+				log.WithField("url", hint.URL).Warnf("Can't prefetch image: %v", err)
+
+				prefetch.setError(hint, err)
+			}
+		}
+
+		select {
+		case <-prefetch.closeChan:
+			return
+
+		case <-prefetch.wakeChan:
+
+		case <-prefetch.clock.After(prefetch.pollInterval):
+		}
+	}
+}
+
+func (prefetch *prefetcher) nextHint() (hint prefetchHint, ok bool) {
+	prefetch.Lock()
+	defer prefetch.Unlock()
+
+	for _, hint := range prefetch.hints {
+		if !prefetch.isDone(hint) && prefetch.errors[hint.URL] == "" {
+			return hint, true
+		}
+	}
+
+	// Retry failed hints on next poll
+	for _, hint := range prefetch.hints {
+		if !prefetch.isDone(hint) {
+			delete(prefetch.errors, hint.URL)
+
+			return hint, true
+		}
+	}
+
+	return hint, false
+}
+
+func (prefetch *prefetcher) setError(hint prefetchHint, err error) {
+	prefetch.Lock()
+	defer prefetch.Unlock()
+
+	prefetch.errors[hint.URL] = err.Error()
+}
+
+// allowed checks time windows and pause flags.
+func (prefetch *prefetcher) allowed() (allowed bool) {
+	if len(prefetch.windows) != 0 {
+		now := prefetch.clock.Now()
+		minute := now.Hour()*60 + now.Minute()
+
+		inWindow := false
+
+		for _, window := range prefetch.windows {
+			if window.contains(minute) {
+				inWindow = true
+
+				break
+			}
+		}
+
+		if !inWindow {
+			return false
+		}
+	}
+
+	for _, flag := range prefetch.pauseFlags {
+		reason, err := flag()
+		if err != nil {
+			log.Errorf("Can't check prefetch pause flag: %v", err)
+
+			return false
+		}
+
+		if reason != "" {
+			log.WithField("reason", reason).Debug("Prefetch paused")
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// download downloads image during allowed time slice.
+func (prefetch *prefetcher) download(hint prefetchHint) (err error) {
+	log.WithFields(log.Fields{"id": hint.ID, "url": hint.URL}).Debug("Prefetch image")
+
+	ctx, cancel := context.WithCancel(prefetch.handler.stopContext())
+	defer cancel()
+
+	sliceDone := make(chan struct{})
+	defer close(sliceDone)
+
+	go func() {
+		for {
+			select {
+			case <-sliceDone:
+				return
+
+			case <-prefetch.closeChan:
+				cancel()
+
+				return
+
+			case <-prefetch.clock.After(prefetch.pollInterval):
+				if !prefetch.allowed() {
+					log.WithField("url", hint.URL).Debug("Prefetch time slice is over")
+
+					cancel()
+
+					return
+				}
+			}
+		}
+	}()
+
+	partialPath := prefetch.imagePath(hint.Sha256) + prefetchPartialExt
+
+	req, err := grab.NewRequest(partialPath, hint.URL)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	req = req.WithContext(ctx)
+
+	for name, value := range hint.Headers {
+		if name = http.CanonicalHeaderKey(name); strings.HasPrefix(name, customHeaderPrefix) {
+			req.HTTPRequest.Header.Set(name, value)
+		}
+	}
+
+	if prefetch.rateLimit != 0 {
+		req.RateLimiter = &prefetchRateLimiter{
+			clock: prefetch.clock, rate: prefetch.rateLimit, startTime: prefetch.clock.Now(),
+		}
+	}
+
+	client := grab.NewClient()
+
+	if tlsConfig := prefetch.handler.getHostTLSConfig(req.URL()); tlsConfig != nil {
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		}
+	}
+
+	fileInfo := image.FileInfo{Sha256: hint.Sha256, Sha512: hint.Sha512, Size: hint.Size}
+	verifier := newStreamVerifier(ctx, fileInfo, true, true)
+
+	verifier.attach(req, client)
+
+	// Partial file is kept to resume download in next time slice
+	resp := client.Do(req)
+	<-resp.Done
+
+	// File which failed verification is removed, otherwise partial file is resumed
+	if err = resp.Err(); err != nil && !verifier.isFailed() {
+		return aoserrors.Wrap(err)
+	}
+
+	if err == nil && !verifier.isVerified() {
+		err = image.CheckFileInfo(ctx, partialPath, fileInfo)
+	}
+
+	if err != nil {
+		if removeErr := os.RemoveAll(partialPath); removeErr != nil {
+			log.Errorf("Can't remove prefetch file: %v", removeErr)
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	prefetch.Lock()
+	defer prefetch.Unlock()
+
+	if err = os.Rename(partialPath, prefetch.imagePath(hint.Sha256)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	log.WithFields(log.Fields{"id": hint.ID, "url": hint.URL}).Info("Image prefetched")
+
+	return nil
+}
+
+func (prefetch *prefetcher) imagePath(sha256 []byte) (path string) {
+	return filepath.Join(prefetch.dir, hex.EncodeToString(sha256))
+}
+
+func (prefetch *prefetcher) isDone(hint prefetchHint) (done bool) {
+	_, err := os.Stat(prefetch.imagePath(hint.Sha256))
+
+	return err == nil
+}
+
+// removeNotHinted removes images and partial files which are not hinted.
+func (prefetch *prefetcher) removeNotHinted() {
+	hinted := map[string]bool{prefetchHintsFileName: true}
+
+	for _, hint := range prefetch.hints {
+		name := hex.EncodeToString(hint.Sha256)
+
+		hinted[name] = true
+		hinted[name+prefetchPartialExt] = true
+	}
+
+	entries, err := os.ReadDir(prefetch.dir)
+	if err != nil {
+		log.Errorf("Can't read prefetch dir: %v", err)
+
+		return
+	}
+
+	for _, entry := range entries {
+		if hinted[entry.Name()] {
+			continue
+		}
+
+		log.WithField("name", entry.Name()).Debug("Remove not hinted prefetch file")
+
+		if err := os.RemoveAll(filepath.Join(prefetch.dir, entry.Name())); err != nil {
+			log.Errorf("Can't remove prefetch file: %v", err)
+		}
+	}
+}
+
+func parsePrefetchWindows(windowsCfg []string) (windows []prefetchWindow, err error) {
+	for _, windowCfg := range windowsCfg {
+		bounds := strings.Split(windowCfg, "-")
+		if len(bounds) != 2 { //nolint:gomnd // start and end
+			return nil, aoserrors.Errorf("wrong prefetch window %s", windowCfg)
+		}
+
+		var window prefetchWindow
+
+		for i, bound := range []*int{&window.start, &window.end} {
+			boundTime, err := time.Parse(prefetchWindowLayout, strings.TrimSpace(bounds[i]))
+			if err != nil {
+				return nil, aoserrors.Errorf("wrong prefetch window %s", windowCfg)
+			}
+
+			*bound = boundTime.Hour()*60 + boundTime.Minute()
+		}
+
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
+
+// contains checks if minute of day is within window, window may wrap over midnight.
+func (window prefetchWindow) contains(minute int) (contains bool) {
+	if window.start <= window.end {
+		return minute >= window.start && minute < window.end
+	}
+
+	return minute >= window.start || minute < window.end
+}
+
+// WaitN limits average transfer rate of the download.
+func (limiter *prefetchRateLimiter) WaitN(ctx context.Context, n int) (err error) {
+	limiter.bytes += uint64(n)
+
+	expected := limiter.startTime.Add(time.Duration(limiter.bytes * uint64(time.Second) / limiter.rate))
+
+	if delay := expected.Sub(limiter.clock.Now()); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return aoserrors.Wrap(ctx.Err())
+
+		case <-limiter.clock.After(delay):
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestPrefetch(t *testing.T) {
+	imagePath := path.Join(tmpDir, "prefetchimage.bin")
+
+	imageInfo, err := createImage(imagePath)
+	if err != nil {
+		t.Fatalf("Can't create image: %s", err)
+	}
+
+	var downloads int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&downloads, 1)
+		}
+
+		http.ServeFile(w, r, imagePath)
+	}))
+	defer server.Close()
+
+	infos := []umclient.ComponentUpdateInfo{{
+		ID: "id1", AosVersion: 1, URL: server.URL + "/prefetchimage.bin",
+		Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+	}}
+
+	now := time.Now()
+	closedWindow := now.Add(2*time.Hour).Format("15:04") + "-" + now.Add(3*time.Hour).Format("15:04")
+
+	for _, testItem := range []struct {
+		windows      []string
+		expectedDone bool
+	}{
+		{windows: []string{closedWindow}},
+		{expectedDone: true},
+	} {
+		prefetchDir := path.Join(tmpDir, "prefetchDir")
+
+		if err := os.RemoveAll(prefetchDir); err != nil {
+			t.Fatalf("Can't remove prefetch dir: %s", err)
+		}
+
+		atomic.StoreInt32(&downloads, 0)
+
+		handler := newTestHandler(t, &config.Config{
+			DownloadDir: path.Join(tmpDir, "downloadDir"),
+			Prefetch: config.Prefetch{
+				Dir: prefetchDir, Windows: testItem.windows,
+				PollInterval: aostypes.Duration{Duration: 50 * time.Millisecond},
+			},
+			UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+		})
+
+		testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+		if err = handler.PrefetchImages(infos); err != nil {
+			t.Fatalf("Can't prefetch images: %s", err)
+		}
+
+		done := false
+
+		for i := 0; i < 20 && !done; i++ {
+			time.Sleep(50 * time.Millisecond)
+
+			statuses := handler.GetPrefetchStatus()
+			if len(statuses) != 1 {
+				t.Fatalf("Wrong prefetch statuses count: %d", len(statuses))
+			}
+
+			if statuses[0].Error != "" {
+				t.Errorf("Prefetch error: %s", statuses[0].Error)
+			}
+
+			done = statuses[0].Done
+		}
+
+		if done != testItem.expectedDone {
+			t.Errorf("Wrong prefetch done: %v", done)
+		}
+
+		if !testItem.expectedDone {
+			if count := atomic.LoadInt32(&downloads); count != 0 {
+				t.Errorf("Image downloaded out of prefetch window: %d", count)
+			}
+
+			handler.Close(context.Background())
+
+			continue
+		}
+
+		handler.PrepareUpdate(infos)
+
+		select {
+		case status := <-handler.StatusChannel():
+			if status.State != umclient.StatePrepared {
+				t.Errorf("Wrong state: %s, error: %s", status.State, status.Error)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait status timeout")
+		}
+
+		if count := atomic.LoadInt32(&downloads); count != 1 {
+			t.Errorf("Wrong downloads count: %d", count)
+		}
+
+		// Replaced hints remove not hinted images
+		if err = handler.PrefetchImages(nil); err != nil {
+			t.Fatalf("Can't prefetch images: %s", err)
+		}
+
+		entries, err := os.ReadDir(prefetchDir)
+		if err != nil {
+			t.Fatalf("Can't read prefetch dir: %s", err)
+		}
+
+		if len(entries) != 1 {
+			t.Errorf("Wrong prefetch dir entries count: %d", len(entries))
+		}
+
+		handler.Close(context.Background())
+	}
+}
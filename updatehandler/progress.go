@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"time"
+
+	"github.com/cavaliergopher/grab/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Download progress is reported during prepare only if progress interval is configured. Components are prepared
+// in parallel goroutines, so progress status is assembled from the status snapshot taken when prepare starts, with
+// progress attached to in-progress entries of components being downloaded. Progress status is intermediate: it is
+// dropped if status channel is full to not stall downloads, final prepare status is always sent.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const percents = 100
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// startProgress takes status snapshot for progress reporting. It is called under handler lock.
+func (handler *Handler) startProgress() {
+	if handler.progressInterval == 0 {
+		return
+	}
+
+	status := handler.getStatus()
+
+	handler.progressMutex.Lock()
+	defer handler.progressMutex.Unlock()
+
+	handler.progressStatus = &status
+	handler.downloadProgress = make(map[string]umclient.DownloadProgress)
+}
+
+func (handler *Handler) stopProgress() {
+	handler.progressMutex.Lock()
+	defer handler.progressMutex.Unlock()
+
+	handler.progressStatus = nil
+	handler.downloadProgress = nil
+}
+
+func (handler *Handler) downloadTickTime() (tickTime time.Duration) {
+	if handler.progressInterval != 0 {
+		return handler.progressInterval
+	}
+
+	return downloadProgressTime
+}
+
+func (handler *Handler) reportProgress(id string, resp *grab.Response) {
+	if handler.progressInterval == 0 || id == "" {
+		return
+	}
+
+	progress := umclient.DownloadProgress{BytesComplete: uint64(resp.BytesComplete())}
+
+	if size := resp.Size(); size > 0 {
+		progress.TotalBytes = uint64(size)
+		progress.Percentage = uint8(progress.BytesComplete * percents / progress.TotalBytes)
+	}
+
+	if !resp.IsComplete() {
+		if eta := resp.ETA().Sub(handler.clock.Now()); eta > 0 {
+			progress.ETA = eta
+		}
+	}
+
+	handler.progressMutex.Lock()
+	defer handler.progressMutex.Unlock()
+
+	if handler.progressStatus == nil {
+		return
+	}
+
+	handler.downloadProgress[id] = progress
+
+	status := *handler.progressStatus
+	status.Components = make([]umclient.ComponentStatusInfo, 0, len(handler.progressStatus.Components))
+
+	for _, componentStatus := range handler.progressStatus.Components {
+		if componentProgress, ok := handler.downloadProgress[componentStatus.ID]; ok &&
+			componentStatus.Status == umclient.StatusInstalling {
+			componentStatus.Progress = &componentProgress
+		}
+
+		status.Components = append(status.Components, componentStatus)
+	}
+
+	log.WithFields(log.Fields{
+		"id": id, "complete": progress.BytesComplete, "total": progress.TotalBytes, "eta": progress.ETA,
+	}).Debug("Send download progress")
+
+	handler.setCurrentProgress(status)
+
+	select {
+	case handler.statusChannel <- status:
+
+	default:
+		log.WithField("id", id).Warn("Status channel is full, skip download progress")
+	}
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestDownloadProgress(t *testing.T) {
+	content := bytes.Repeat([]byte("progress"), 8192)
+	imagePath := path.Join(tmpDir, "progressimage.bin")
+
+	if err := writeImage(imagePath, content, false); err != nil {
+		t.Fatalf("Can't write image: %s", err)
+	}
+
+	imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("Can't create file info: %s", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+
+		for i := 0; i < len(content); i += 8192 {
+			_, _ = w.Write(content[i : i+8192])
+
+			w.(http.Flusher).Flush() //nolint:forcetypeassert
+
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:      path.Join(tmpDir, "downloadDir"),
+		ProgressInterval: aostypes.Duration{Duration: 50 * time.Millisecond},
+		UpdateModules:    []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	})
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	handler.PrepareUpdate([]umclient.ComponentUpdateInfo{{
+		ID: "id1", AosVersion: 1, URL: server.URL + "/progressimage.bin",
+		Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+	}})
+
+	var progresses []umclient.DownloadProgress
+
+	for {
+		select {
+		case status := <-handler.StatusChannel():
+			progress := getComponentProgress(status, "id1")
+			if progress == nil {
+				if status.State != umclient.StatePrepared {
+					t.Errorf("Wrong state: %s, error: %s", status.State, status.Error)
+				}
+
+				if len(progresses) < 2 {
+					t.Fatalf("Wrong progress count: %d", len(progresses))
+				}
+
+				last := progresses[len(progresses)-1]
+
+				if last.BytesComplete != uint64(len(content)) || last.TotalBytes != uint64(len(content)) ||
+					last.Percentage != 100 {
+					t.Errorf("Wrong final progress: %v", last)
+				}
+
+				return
+			}
+
+			if progress.BytesComplete > progress.TotalBytes {
+				t.Errorf("Wrong progress: %v", *progress)
+			}
+
+			progresses = append(progresses, *progress)
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait status timeout")
+		}
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func getComponentProgress(status umclient.Status, id string) (progress *umclient.DownloadProgress) {
+	for _, componentStatus := range status.Components {
+		if componentStatus.ID == id && componentStatus.Progress != nil {
+			return componentStatus.Progress
+		}
+	}
+
+	return nil
+}
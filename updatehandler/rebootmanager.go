@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+)
+
+// Reboot manager coordinates reboots requested by component operations of one stage. Components of the same reboot
+// group share one physical reboot: only one module of the group (with the highest reboot priority) is rebooted and
+// its result is applied to all group components. Components without group are rebooted independently unless reboot
+// batching is enabled: then components without group which require system reboot (warm or full) are coalesced into
+// one batch rebooted once by the highest priority module with reboot type which satisfies all of them. Service
+// restart is not batched as it restarts only the module service. Components of reboot groups are not batched as the
+// group may reside on other hardware.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type rebootManager struct {
+	handler *Handler
+	batch   bool
+}
+
+type rebootGroup struct {
+	module     UpdateModule
+	journal    *opjournal.Journal
+	priority   uint32
+	rebootType string
+	statuses   []*umclient.ComponentStatusInfo
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newRebootManager(handler *Handler, batch bool) (manager *rebootManager) {
+	return &rebootManager{handler: handler, batch: batch}
+}
+
+// reboot reboots components which require reboot by reboot groups in reboot priority order.
+func (manager *rebootManager) reboot(
+	componentStatuses []*umclient.ComponentStatusInfo, rebootTypes map[string]string, stopOnError bool,
+) (err error) {
+	groups, err := manager.groups(componentStatuses, rebootTypes, stopOnError)
+	if err != nil && stopOnError {
+		return err
+	}
+
+	operations := make([]scheduledOperation, 0, len(groups))
+
+	for _, group := range groups {
+		group := group
+
+		operations = append(operations, scheduledOperation{
+			priority:  group.priority,
+			operation: func() (err error) { return manager.runGroupReboot(group) },
+		})
+	}
+
+	if scheduleErr := scheduleOperations(operations, stopOnError); scheduleErr != nil && err == nil {
+		err = scheduleErr
+	}
+
+	return aoserrors.Wrap(err)
+}
+
+// groups splits components into reboot groups. Not found components are failed.
+func (manager *rebootManager) groups(
+	componentStatuses []*umclient.ComponentStatusInfo, rebootTypes map[string]string, stopOnError bool,
+) (groups []*rebootGroup, err error) {
+	groups = make([]*rebootGroup, 0, len(componentStatuses))
+	namedGroups := make(map[string]*rebootGroup)
+
+	var batch *rebootGroup
+
+	for _, componentStatus := range componentStatuses {
+		component, ok := manager.handler.components[componentStatus.ID]
+		if !ok {
+			notFoundErr := aoserrors.Errorf("component %s not found", componentStatus.ID)
+			componentError(componentStatus, notFoundErr)
+
+			if stopOnError {
+				return nil, aoserrors.Wrap(notFoundErr)
+			}
+
+			if err == nil {
+				err = aoserrors.Wrap(notFoundErr)
+			}
+
+			continue
+		}
+
+		rebootType := rebootTypes[componentStatus.ID]
+
+		if component.rebootGroup == "" && (!manager.batch || !isSystemReboot(rebootType)) {
+			groups = append(groups, &rebootGroup{
+				module: component.module, journal: component.journal, priority: component.rebootPriority,
+				rebootType: rebootType, statuses: []*umclient.ComponentStatusInfo{componentStatus},
+			})
+
+			continue
+		}
+
+		group := batch
+
+		if component.rebootGroup != "" {
+			group = namedGroups[component.rebootGroup]
+		}
+
+		if group == nil {
+			group = &rebootGroup{
+				module: component.module, journal: component.journal, priority: component.rebootPriority,
+			}
+			groups = append(groups, group)
+
+			if component.rebootGroup != "" {
+				namedGroups[component.rebootGroup] = group
+			} else {
+				batch = group
+			}
+		}
+
+		group.add(component, componentStatus, rebootType)
+	}
+
+	if batch != nil && len(batch.statuses) > 1 {
+		log.WithFields(log.Fields{
+			"id": batch.module.GetID(), "rebootType": batch.rebootType, "components": len(batch.statuses),
+		}).Info("Batch component reboots")
+	}
+
+	return groups, err
+}
+
+func (manager *rebootManager) runGroupReboot(group *rebootGroup) (err error) {
+	handler := manager.handler
+
+	log.WithFields(log.Fields{"id": group.module.GetID(), "rebootType": group.rebootType}).Debug(
+		"Reboot component")
+
+	defer handler.removePendingActions(group.statuses)
+
+	if err := handler.checkStopped(); err != nil {
+		log.WithField("id", group.module.GetID()).Warn("Reboot inhibited by emergency stop")
+
+		for _, componentStatus := range group.statuses {
+			componentError(componentStatus, err)
+		}
+
+		return err
+	}
+
+	handler.startPendingReboot(group)
+
+	if err := handler.measureUsage(group.module.GetID(), phaseReboot, group.journal, func() (err error) {
+		if err = handler.checkFence(group.module.GetID()); err != nil {
+			return err
+		}
+
+		if err = handler.injectFailure(handler.operationContext(), group.module.GetID(), phaseReboot); err != nil {
+			return err
+		}
+
+		return rebootModule(handler.operationContext(), group.module, group.rebootType)
+	}); err != nil {
+		for _, componentStatus := range group.statuses {
+			componentError(componentStatus, err)
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// add adds component to the group, the highest priority module performs the group reboot.
+func (group *rebootGroup) add(
+	component componentData, componentStatus *umclient.ComponentStatusInfo, rebootType string,
+) {
+	if component.rebootPriority > group.priority ||
+		(component.rebootPriority == group.priority && component.module.GetID() < group.module.GetID()) {
+		group.module = component.module
+		group.journal = component.journal
+		group.priority = component.rebootPriority
+	}
+
+	group.rebootType = satisfyingRebootType(group.rebootType, rebootType)
+	group.statuses = append(group.statuses, componentStatus)
+}
+
+func isSystemReboot(rebootType string) (system bool) {
+	return rebootTypeOrder[rebootType] >= rebootTypeOrder[RebootWarm]
+}
@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestRebootGroups(t *testing.T) {
+	cfg := &config.Config{
+		DownloadDir: path.Join(tmpDir, "downloadDir"),
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", RebootPriority: 1, RebootGroup: "soc"},
+			{ID: "id2", Plugin: "testmodule", RebootPriority: 2, RebootGroup: "soc"},
+			{ID: "id3", Plugin: "testmodule", RebootPriority: 1, RebootType: updatehandler.RebootServiceRestart},
+		},
+	}
+
+	order = nil
+
+	handler := newTestHandler(t, cfg)
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+			{ID: "id3", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus,
+		map[string][]string{"id1": {opInit}, "id2": {opInit}, "id3": {opInit}}, nil)
+
+	// Prepare
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+
+	for _, info := range infos {
+		newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+			ID:            info.ID,
+			AosVersion:    info.AosVersion,
+			VendorVersion: info.VendorVersion,
+			Status:        umclient.StatusInstalling,
+		})
+	}
+
+	newStatus.State = umclient.StatePrepared
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus,
+		map[string][]string{"id1": {opPrepare}, "id2": {opPrepare}, "id3": {opPrepare}}, nil)
+
+	// Update: group components share one reboot performed by the highest priority module. The group is rebooted
+	// with the least disruptive reboot type which satisfies all group components
+
+	for _, component := range components {
+		component.rebootRequired = true
+	}
+
+	components["id1"].rebootType = updatehandler.RebootWarm
+	components["id2"].rebootType = updatehandler.RebootServiceRestart
+
+	newStatus.State = umclient.StateUpdated
+	order = nil
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus,
+		map[string][]string{
+			"id1": {opUpdate, opUpdate},
+			"id2": {opUpdate, opReboot, opUpdate},
+			"id3": {opUpdate, opReboot, opUpdate},
+		}, nil)
+
+	checkRebootTypes(t, map[string]string{
+		"id1": "", "id2": updatehandler.RebootWarm, "id3": updatehandler.RebootServiceRestart,
+	})
+
+	// Apply: only group components which require reboot are taken into account
+
+	finalStatus := umclient.Status{State: umclient.StateIdle}
+
+	for _, info := range infos {
+		finalStatus.Components = append(finalStatus.Components, umclient.ComponentStatusInfo{
+			ID:            info.ID,
+			AosVersion:    info.AosVersion,
+			VendorVersion: info.VendorVersion,
+			Status:        umclient.StatusInstalled,
+		})
+	}
+
+	components["id1"].rebootRequired = true
+	order = nil
+
+	testOperation(t, handler, handler.ApplyUpdate, &finalStatus,
+		map[string][]string{"id1": {opApply, opReboot, opApply}, "id2": {opApply}, "id3": {opApply}}, nil)
+
+	checkRebootTypes(t, map[string]string{"id1": updatehandler.RebootWarm, "id2": "", "id3": ""})
+
+	// Wrong reboot type is not accepted
+
+	cfg.UpdateModules[2].RebootType = "coldBoot"
+
+	if findings := updatehandler.ValidateConfig(cfg); len(findings) != 1 ||
+		!strings.Contains(findings[0].Message, "wrong reboot type coldBoot") {
+		t.Errorf("Wrong config findings: %v", findings)
+	}
+}
+
+func TestBatchReboots(t *testing.T) {
+	cfg := &config.Config{
+		BatchReboots: true,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", RebootPriority: 1},
+			{ID: "id2", Plugin: "testmodule", RebootPriority: 2},
+			{ID: "id3", Plugin: "testmodule", RebootType: updatehandler.RebootServiceRestart},
+			{ID: "id4", Plugin: "testmodule", RebootGroup: "soc"},
+		},
+	}
+
+	handler := newTestHandler(t, cfg)
+
+	currentStatus := umclient.Status{State: umclient.StateIdle}
+
+	for _, moduleCfg := range cfg.UpdateModules {
+		currentStatus.Components = append(currentStatus.Components, umclient.ComponentStatusInfo{
+			ID: moduleCfg.ID, Status: umclient.StatusInstalled,
+		})
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, nil, nil, nil)
+
+	// Components without group which require system reboot are rebooted once by the highest priority module,
+	// service restart and reboot group are not batched
+
+	for _, component := range components {
+		component.rebootRequired = true
+	}
+
+	components["id1"].rebootType = updatehandler.RebootWarm
+	order = nil
+
+	testOperation(t, handler, handler.StartUpdate, nil,
+		map[string][]string{
+			"id1": {opUpdate, opUpdate},
+			"id2": {opUpdate, opReboot, opUpdate},
+			"id3": {opUpdate, opReboot, opUpdate},
+			"id4": {opUpdate, opReboot, opUpdate},
+		}, nil)
+
+	checkRebootTypes(t, map[string]string{
+		"id1": "", "id2": updatehandler.RebootFull, "id3": updatehandler.RebootServiceRestart,
+		"id4": updatehandler.RebootFull,
+	})
+
+	// Single component is rebooted by its own module
+
+	components["id1"].rebootRequired = true
+	order = nil
+
+	testOperation(t, handler, handler.ApplyUpdate, nil,
+		map[string][]string{"id1": {opApply, opReboot, opApply}, "id2": {opApply}, "id3": {opApply}}, nil)
+
+	checkRebootTypes(t, map[string]string{"id1": updatehandler.RebootWarm, "id2": ""})
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reboot types are ordered from the least to the most disruptive one: more disruptive reboot satisfies components
+// which require less disruptive one. Component requires reboot type configured for its module (full reboot by
+// default) unless the module declares another one after Update, Apply or Revert requested reboot. Components of
+// reboot group share one reboot of the most disruptive type required by the group components, i.e. the least
+// disruptive one which satisfies all of them. Module which can't perform reboot of specific type is rebooted by
+// Reboot call which is treated as full reboot.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Reboot types.
+const (
+	RebootServiceRestart = "serviceRestart"
+	RebootWarm           = "warm"
+	RebootFull           = "full"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var rebootTypeOrder = map[string]int{ //nolint:gochecknoglobals
+	RebootServiceRestart: 1,
+	RebootWarm:           2,
+	RebootFull:           3,
+}
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// RebootTypeProvider optional interface which can be implemented by update module to declare reboot type required
+// by last Update, Apply or Revert call which returned reboot required flag.
+type RebootTypeProvider interface {
+	// GetRebootType returns required reboot type
+	GetRebootType() (rebootType string)
+}
+
+// TypedRebooter optional interface which can be implemented by update module to perform reboot of specific type.
+type TypedRebooter interface {
+	// RebootWithType performs module reboot of requested type
+	RebootWithType(ctx context.Context, rebootType string) (err error)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkRebootType(rebootType string) (err error) {
+	if _, ok := rebootTypeOrder[rebootType]; rebootType != "" && !ok {
+		return aoserrors.Errorf("wrong reboot type %s", rebootType)
+	}
+
+	return nil
+}
+
+// getRebootType returns reboot type required by component after module operation.
+func getRebootType(module UpdateModule, defaultType string) (rebootType string) {
+	rebootType = defaultType
+
+	if provider, ok := baseModule(module).(RebootTypeProvider); ok {
+		if moduleType := provider.GetRebootType(); moduleType != "" {
+			if err := checkRebootType(moduleType); err != nil {
+				log.WithField("id", module.GetID()).Errorf("Module requires wrong reboot type: %v", err)
+
+				return RebootFull
+			}
+
+			rebootType = moduleType
+		}
+	}
+
+	if rebootType == "" {
+		return RebootFull
+	}
+
+	return rebootType
+}
+
+// satisfyingRebootType returns the least disruptive reboot type which satisfies both types.
+func satisfyingRebootType(rebootType, other string) (result string) {
+	if rebootTypeOrder[other] > rebootTypeOrder[rebootType] {
+		return other
+	}
+
+	return rebootType
+}
+
+func rebootModule(ctx context.Context, module UpdateModule, rebootType string) (err error) {
+	rebooter, ok := baseModule(module).(TypedRebooter)
+	if !ok {
+		return aoserrors.Wrap(module.Reboot(ctx))
+	}
+
+	if err = ctx.Err(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(rebooter.RebootWithType(ctx, rebootType))
+}
@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Status refresh is requested by backend to confirm actual state of suspicious device without waiting for next
+// update session. Versions and metadata of selected components are requested from modules and component health is
+// checked by verify command or by module which supports health check maintenance action. Refreshed status is also
+// sent to the status channel. Refresh of each component is rate limited as health checks may be expensive. Refresh
+// is allowed only while the handler is idle.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const defaultStatusRefreshInterval = time.Minute
+
+// Component health values.
+const (
+	ComponentHealthOK      = "ok"
+	ComponentHealthFailed  = "failed"
+	ComponentHealthUnknown = "unknown"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// ComponentRefresh result of component status refresh.
+type ComponentRefresh struct {
+	ID            string    `json:"id"`
+	VendorVersion string    `json:"vendorVersion"`
+	AosVersion    uint64    `json:"aosVersion"`
+	Status        string    `json:"status"`
+	Health        string    `json:"health"`
+	Error         string    `json:"error,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// RefreshStatus refreshes versions and health of specified components, all components are refreshed if no ID is
+// specified.
+func (handler *Handler) RefreshStatus(ids []string) (refreshes []ComponentRefresh, err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if err = handler.checkStopped(); err != nil {
+		return nil, err
+	}
+
+	if handler.state.UpdateState != stateIdle {
+		return nil, aoserrors.Errorf("status refresh is not allowed in %s state", handler.state.UpdateState)
+	}
+
+	if len(ids) == 0 {
+		ids = sortedComponentIDs(handler.components)
+	}
+
+	now := handler.clock.Now()
+
+	for _, id := range ids {
+		if _, ok := handler.components[id]; !ok {
+			return nil, aoserrors.Errorf("component %s not found", id)
+		}
+
+		if refreshTime, ok := handler.refreshTimes[id]; ok && now.Before(refreshTime.Add(handler.refreshInterval)) {
+			return nil, aoserrors.Errorf("status refresh of component %s is rate limited till %s", id,
+				refreshTime.Add(handler.refreshInterval).Format(time.RFC3339))
+		}
+	}
+
+	log.WithField("ids", ids).Info("Refresh component status")
+
+	handler.getVersions(ids)
+
+	refreshes = make([]ComponentRefresh, 0, len(ids))
+
+	for _, id := range ids {
+		component := handler.components[id]
+		status := handler.componentStatuses[id]
+
+		refresh := ComponentRefresh{
+			ID:            id,
+			VendorVersion: status.VendorVersion,
+			AosVersion:    status.AosVersion,
+			Status:        string(status.Status),
+			Health:        ComponentHealthUnknown,
+			Time:          now,
+		}
+
+		startTime := time.Now()
+
+		supported, healthErr := handler.checkComponentHealth(id, component)
+		component.journal.Command("refresh status", startTime, healthErr)
+
+		if supported {
+			refresh.Health = ComponentHealthOK
+		}
+
+		if healthErr != nil {
+			log.WithField("id", id).Errorf("Component health check failed: %v", healthErr)
+
+			refresh.Health, refresh.Error = ComponentHealthFailed, healthErr.Error()
+		}
+
+		handler.refreshTimes[id] = now
+		refreshes = append(refreshes, refresh)
+	}
+
+	sort.Slice(refreshes, func(i, j int) bool { return refreshes[i].ID < refreshes[j].ID })
+
+	handler.sendStatus()
+
+	return refreshes, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkStatusRefreshInterval(interval time.Duration) (err error) {
+	if interval < 0 {
+		return aoserrors.New("wrong status refresh interval")
+	}
+
+	return nil
+}
+
+// checkComponentHealth checks component health by verify command and module health check if they are supported.
+func (handler *Handler) checkComponentHealth(id string, component componentData) (supported bool, err error) {
+	if component.verifier != nil {
+		supported = true
+
+		if err = component.verifier.verify(id); err != nil {
+			return supported, err
+		}
+	}
+
+	provider, _ := baseModule(component.module).(MaintenanceProvider)
+	if provider == nil || !containsString(provider.GetMaintenanceActions(), MaintenanceHealthCheck) {
+		return supported, nil
+	}
+
+	return true, aoserrors.Wrap(provider.RunMaintenance(handler.operationContext(), MaintenanceHealthCheck))
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestRefreshStatus(t *testing.T) {
+	components = map[string]*testModule{
+		"id1": {id: "id1", vendorVersion: "1.0", maintenance: []string{updatehandler.MaintenanceHealthCheck}},
+		"id2": {id: "id2", vendorVersion: "1.0"},
+		"id3": {id: "id3", vendorVersion: "1.0"},
+	}
+
+	cfg := &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule", Verify: &config.VerifyCommand{Command: "exit 1"}},
+			{ID: "id3", Plugin: "testmodule"},
+		},
+	}
+
+	handler := newTestHandler(t, cfg, withModules(components))
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	// Refreshed versions are reported in status
+
+	components["id1"].vendorVersion = "2.0"
+	order = nil
+
+	var refreshes []updatehandler.ComponentRefresh
+
+	testOperation(t, handler, func() {
+		var err error
+
+		if refreshes, err = handler.RefreshStatus(nil); err != nil {
+			t.Errorf("Can't refresh status: %s", err)
+		}
+	}, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", VendorVersion: "2.0", Status: umclient.StatusInstalled},
+			{ID: "id2", VendorVersion: "1.0", Status: umclient.StatusInstalled},
+			{ID: "id3", VendorVersion: "1.0", Status: umclient.StatusInstalled},
+		},
+	}, map[string][]string{"id1": {updatehandler.MaintenanceHealthCheck}, "id2": nil, "id3": nil}, nil)
+
+	expectedHealth := []string{
+		updatehandler.ComponentHealthOK, updatehandler.ComponentHealthFailed, updatehandler.ComponentHealthUnknown,
+	}
+
+	if len(refreshes) != len(expectedHealth) {
+		t.Fatalf("Wrong refreshes: %v", refreshes)
+	}
+
+	for i, refresh := range refreshes {
+		if refresh.Health != expectedHealth[i] || (refresh.Error != "") != (i == 1) {
+			t.Errorf("Wrong component %s health: %s, %s", refresh.ID, refresh.Health, refresh.Error)
+		}
+	}
+
+	if refreshes[0].VendorVersion != "2.0" {
+		t.Errorf("Wrong vendor version: %s", refreshes[0].VendorVersion)
+	}
+
+	// Refresh is rate limited
+
+	for _, ids := range [][]string{{"id1"}, {"id4"}} {
+		if _, err := handler.RefreshStatus(ids); err == nil {
+			t.Errorf("Error expected for %v", ids)
+		}
+	}
+
+	fakeClock.Advance(time.Minute)
+
+	testOperation(t, handler, func() {
+		if refreshes, err := handler.RefreshStatus([]string{"id3"}); err != nil || len(refreshes) != 1 {
+			t.Errorf("Wrong refresh result: %v, %v", refreshes, err)
+		}
+	}, nil, nil, nil)
+
+	cfg.StatusRefreshInterval = aostypes.Duration{Duration: -time.Second}
+
+	if findings := updatehandler.ValidateConfig(cfg); len(findings) != 1 {
+		t.Errorf("Wrong config findings: %v", findings)
+	}
+}
@@ -0,0 +1,306 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+	"github.com/aoscloud/aos_updatemanager/utils/versionutils"
+)
+
+// Config reload applies update modules section of new config without restarting the daemon. It is allowed only in
+// idle state, as running update refers to modules and their settings. Module of kept component is recreated only if
+// its plugin or params are changed, otherwise the running module is kept and only handler settings of the component,
+// e.g. priorities, are updated. Reload is applied all or nothing: new config is validated and modules of added and
+// changed components are created before any running module is closed. If any module can't be created, created
+// modules are closed and the running modules and config are kept. Only then modules of removed and changed
+// components are closed and modules of added components are initialized. Other config sections are applied on
+// restart.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type moduleBackup struct {
+	id            string
+	state         []byte
+	configHash    []byte
+	configChanged bool
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// ReloadConfig applies update modules config.
+func (handler *Handler) ReloadConfig(ctx context.Context, cfg *config.Config) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if err = handler.checkStopped(); err != nil {
+		return err
+	}
+
+	if handler.state.UpdateState != stateIdle {
+		return aoserrors.Errorf("config reload is not allowed in %s state", handler.state.UpdateState)
+	}
+
+	log.Info("Reload update modules config")
+
+	if err = LoadPlugins(cfg.PluginFiles); err != nil {
+		return err
+	}
+
+	components, err := handler.newComponents(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, moduleCfg := range cfg.UpdateModules {
+		if _, ok := components[moduleCfg.ID]; !ok {
+			continue
+		}
+
+		if _, ok := plugins[moduleCfg.Plugin]; !ok {
+			return aoserrors.Errorf("plugin %s not found", moduleCfg.Plugin)
+		}
+	}
+
+	var (
+		added   []string
+		backups []moduleBackup
+	)
+
+	// Create all new modules first to keep running modules if any of them fails. Module creation invalidates state
+	// of changed module, so the state is backed up to be restored on rollback.
+	for _, id := range sortedComponentIDs(components) {
+		component := components[id]
+
+		if current, ok := handler.components[id]; ok && isSameModule(current.moduleConfig, component.moduleConfig) {
+			continue
+		}
+
+		log.WithField("id", id).Info("Create component module")
+
+		var backup moduleBackup
+
+		if backup, err = handler.backupModule(id); err != nil {
+			handler.rollbackModules(ctx, components, added, backups)
+
+			return err
+		}
+
+		backups = append(backups, backup)
+
+		if component.module, err = handler.newComponentModule(component.moduleConfig); err != nil {
+			handler.rollbackModules(ctx, components, added, backups)
+
+			return aoserrors.Errorf("can't create module %s: %v", id, err)
+		}
+
+		components[id] = component
+		added = append(added, id)
+	}
+
+	for _, id := range sortedComponentIDs(handler.components) {
+		current := handler.components[id]
+
+		if component, ok := components[id]; ok && component.module == nil {
+			log.WithField("id", id).Debug("Keep component module")
+
+			component.module = current.module
+			components[id] = component
+
+			if err := storeConfigHash(component.moduleConfig, handler.moduleStorage); err != nil {
+				log.WithField("id", id).Errorf("Can't store config hash: %v", err)
+			}
+
+			continue
+		}
+
+		log.WithField("id", id).Info("Close component module")
+
+		if err := current.module.Close(ctx); err != nil {
+			log.WithField("id", id).Errorf("Can't close module: %v", err)
+		}
+
+		delete(handler.componentStatuses, id)
+
+		if _, ok := components[id]; !ok {
+			delete(handler.configChanged, id)
+		}
+	}
+
+	handler.components = components
+
+	handler.initComponents(added)
+	handler.reconcileState(sortedComponentIDs(handler.components))
+	handler.sendStatus()
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// backupModule backs up module state and config drift data changed by module creation.
+func (handler *Handler) backupModule(id string) (backup moduleBackup, err error) {
+	backup = moduleBackup{id: id, configChanged: handler.configChanged[id]}
+
+	delete(handler.configChanged, id)
+
+	if handler.moduleStorage == nil {
+		return backup, nil
+	}
+
+	if backup.state, err = handler.moduleStorage.GetModuleState(id); err != nil {
+		return backup, aoserrors.Wrap(err)
+	}
+
+	if backup.configHash, err = handler.moduleStorage.GetModuleState(id + configHashSuffix); err != nil {
+		return backup, aoserrors.Wrap(err)
+	}
+
+	return backup, nil
+}
+
+// rollbackModules closes modules created for rejected config and restores backed up module data.
+func (handler *Handler) rollbackModules(
+	ctx context.Context, components map[string]componentData, added []string, backups []moduleBackup,
+) {
+	for _, id := range added {
+		log.WithField("id", id).Debug("Close created module")
+
+		if err := components[id].module.Close(ctx); err != nil {
+			log.WithField("id", id).Errorf("Can't close module: %v", err)
+		}
+	}
+
+	for _, backup := range backups {
+		if backup.configChanged {
+			handler.configChanged[backup.id] = true
+		} else {
+			delete(handler.configChanged, backup.id)
+		}
+
+		if handler.moduleStorage == nil {
+			continue
+		}
+
+		if err := handler.moduleStorage.SetModuleState(backup.id, backup.state); err != nil {
+			log.WithField("id", backup.id).Errorf("Can't restore module state: %v", err)
+		}
+
+		if err := handler.moduleStorage.SetModuleState(backup.id+configHashSuffix, backup.configHash); err != nil {
+			log.WithField("id", backup.id).Errorf("Can't restore config hash: %v", err)
+		}
+	}
+}
+
+// newComponents validates modules config and creates data of enabled components without modules.
+func (handler *Handler) newComponents(cfg *config.Config) (components map[string]componentData, err error) {
+	if err = checkDependencies(cfg.UpdateModules); err != nil {
+		return nil, err
+	}
+
+	signaturePolicies, err := newSignaturePolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkVerificationPolicies(cfg); err != nil {
+		return nil, err
+	}
+
+	trustAnchors, err := newTrustAnchors(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	components = make(map[string]componentData)
+
+	for _, moduleCfg := range cfg.UpdateModules {
+		if moduleCfg.Disabled {
+			continue
+		}
+
+		component := componentData{
+			moduleConfig:    moduleCfg,
+			updatePriority:  moduleCfg.UpdatePriority,
+			rebootPriority:  moduleCfg.RebootPriority,
+			rebootGroup:     moduleCfg.RebootGroup,
+			rebootType:      moduleCfg.RebootType,
+			externalTarget:  moduleCfg.ExternalTarget,
+			updateTimeout:   moduleCfg.UpdateTimeout.Duration,
+			signaturePolicy: signaturePolicies[moduleCfg.SignaturePolicy],
+			imageFormats:    moduleCfg.ImageFormats,
+			dependencies:    moduleCfg.Dependencies,
+			spaceCheck:      moduleCfg.SpaceCheck,
+			confirmation:    moduleCfg.Confirmation,
+			trustAnchor:     trustAnchors[moduleCfg.TrustAnchor],
+			journal:         opjournal.New(moduleCfg.ID, handler.moduleStorage),
+		}
+
+		if err = checkModuleConfig(moduleCfg); err != nil {
+			return nil, err
+		}
+
+		if component.versionScheme, err = versionutils.ParseScheme(moduleCfg.VersionScheme); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if component.verifier, err = newComponentVerifier(moduleCfg.Verify); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		if component.imagePolicy, err = newVerificationPolicy(cfg, moduleCfg); err != nil {
+			return nil, err
+		}
+
+		components[moduleCfg.ID] = component
+	}
+
+	return components, nil
+}
+
+func (handler *Handler) newComponentModule(moduleCfg config.ModuleConfig) (module UpdateModule, err error) {
+	if err = handler.checkConfigDrift(moduleCfg, handler.moduleStorage); err != nil {
+		return nil, err
+	}
+
+	if module, err = NewModule(moduleCfg.Plugin, moduleCfg.ID, moduleCfg.Params,
+		newModuleStorage(moduleCfg.ID, handler.moduleStorage, handler.moduleStorageQuota)); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return module, nil
+}
+
+// isSameModule checks if module created with config1 may be used for config2.
+func isSameModule(config1, config2 config.ModuleConfig) (same bool) {
+	hash1, err1 := getConfigHash(config.ModuleConfig{ID: config1.ID, Plugin: config1.Plugin, Params: config1.Params})
+	hash2, err2 := getConfigHash(config.ModuleConfig{ID: config2.ID, Plugin: config2.Plugin, Params: config2.Params})
+
+	return err1 == nil && err2 == nil && hash1 == hash2
+}
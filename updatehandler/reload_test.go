@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestReloadConfig(t *testing.T) {
+	cfg := &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	}
+
+	handler := newTestHandler(t, cfg)
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	// Wrong config is not applied
+
+	if err := handler.ReloadConfig(context.Background(), &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id2", Plugin: "testmodule"}, {ID: "id3", Plugin: "unknown"}},
+	}); err == nil {
+		t.Error("Error expected")
+	}
+
+	if components["id1"].closed {
+		t.Error("Module of component id1 should not be closed")
+	}
+
+	// Removed module is closed, added one is initialized, kept one is not reinitialized
+
+	cfg.UpdateModules = []config.ModuleConfig{
+		{ID: "id2", Plugin: "testmodule", UpdatePriority: 2},
+		{ID: "id3", Plugin: "testmodule", UpdatePriority: 1},
+	}
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id2", Status: umclient.StatusInstalled},
+			{ID: "id3", Status: umclient.StatusInstalled},
+		},
+	}
+
+	order = nil
+
+	testOperation(t, handler, func() {
+		if err := handler.ReloadConfig(context.Background(), cfg); err != nil {
+			t.Errorf("Can't reload config: %s", err)
+		}
+	}, &currentStatus, map[string][]string{"id1": nil, "id2": nil, "id3": {opInit}}, nil)
+
+	if !components["id1"].closed || components["id2"].closed {
+		t.Error("Wrong closed modules")
+	}
+
+	// Module is recreated if its params are changed
+
+	cfg.UpdateModules[0].Params = json.RawMessage(`{"param": 1}`)
+	order = nil
+
+	testOperation(t, handler, func() {
+		if err := handler.ReloadConfig(context.Background(), cfg); err != nil {
+			t.Errorf("Can't reload config: %s", err)
+		}
+	}, &currentStatus, map[string][]string{"id2": {opInit}, "id3": nil}, nil)
+
+	if !components["id2"].closed || components["id3"].closed {
+		t.Error("Wrong closed modules")
+	}
+
+	// Reloaded priorities are used by update, reload is not allowed during update
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+
+	for _, info := range infos {
+		newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+			ID: info.ID, AosVersion: info.AosVersion, Status: umclient.StatusInstalling,
+		})
+	}
+
+	newStatus.State = umclient.StatePrepared
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil,
+		[]orderInfo{{id: "id2", op: opPrepare}, {id: "id3", op: opPrepare}})
+
+	if err = handler.ReloadConfig(context.Background(), cfg); err == nil {
+		t.Error("Error expected")
+	}
+
+	testOperation(t, handler, handler.RevertUpdate, &currentStatus, nil, nil)
+}
+
+func TestReloadConfigRollback(t *testing.T) {
+	var created []*testModule
+
+	updatehandler.RegisterPlugin("recreatedmodule",
+		func(id string, configJSON json.RawMessage,
+			storage updatehandler.ModuleStorage,
+		) (module updatehandler.UpdateModule, err error) {
+			created = append(created, &testModule{id: id})
+
+			return created[len(created)-1], nil
+		})
+
+	updatehandler.RegisterPlugin("failingmodule",
+		func(id string, configJSON json.RawMessage,
+			storage updatehandler.ModuleStorage,
+		) (module updatehandler.UpdateModule, err error) {
+			return nil, aoserrors.New("can't create module")
+		})
+
+	storage := newTestStorage()
+
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	}, withStorage(storage))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	if err := storage.SetModuleState("id1", []byte("state")); err != nil {
+		t.Fatalf("Can't set module state: %s", err)
+	}
+
+	// Changed module is created before failed one, running modules are kept and changed module state is restored
+
+	order = nil
+
+	if err := handler.ReloadConfig(context.Background(), &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "recreatedmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+			{ID: "id3", Plugin: "failingmodule"},
+		},
+	}); err == nil {
+		t.Error("Error expected")
+	}
+
+	if len(created) != 1 || !created[0].closed {
+		t.Error("Created module should be closed")
+	}
+
+	if components["id1"].closed || components["id2"].closed {
+		t.Error("Running modules should not be closed")
+	}
+
+	if state, err := storage.GetModuleState("id1"); err != nil || string(state) != "state" {
+		t.Errorf("Wrong module state: %s, error: %v", state, err)
+	}
+
+	if err := checkComponentOps(map[string][]string{"id1": nil, "id2": nil}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Module prepare and update which fail with transient error are retried with exponential backoff, any other error
+// fails the component at once. Module which returns transient error should keep the operation retryable, e.g. fail
+// before it changes the target. Each attempt is limited by component update timeout. Retry delay is interrupted by
+// update cancel, close and emergency stop. Apply, revert and reboot are not retried as they are resumed after
+// restart anyway.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	defaultRetryInitialDelay = 1 * time.Second
+	defaultRetryMaxDelay     = 1 * time.Minute
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// TransientError module operation error which may be resolved by retry, e.g. network failure of remote target.
+type TransientError struct {
+	Err error
+}
+
+type retryPolicy struct {
+	maxRetries   int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// NewTransientError marks module operation error as transient.
+func NewTransientError(err error) (transientErr error) {
+	if err == nil {
+		return nil
+	}
+
+	return aoserrors.Wrap(&TransientError{Err: err})
+}
+
+// IsTransientError returns true if error is transient.
+func IsTransientError(err error) (transient bool) {
+	var transientErr *TransientError
+
+	return errors.As(err, &transientErr)
+}
+
+// Error returns error message.
+func (transientErr *TransientError) Error() (message string) {
+	return transientErr.Err.Error()
+}
+
+// Unwrap returns original error.
+func (transientErr *TransientError) Unwrap() (err error) {
+	return transientErr.Err
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newRetryPolicy(cfg config.OperationRetry) (policy retryPolicy, err error) {
+	if cfg.MaxRetries < 0 || cfg.InitialDelay.Duration < 0 || cfg.MaxDelay.Duration < 0 {
+		return policy, aoserrors.New("wrong operation retry config")
+	}
+
+	policy = retryPolicy{
+		maxRetries: cfg.MaxRetries, initialDelay: cfg.InitialDelay.Duration, maxDelay: cfg.MaxDelay.Duration,
+	}
+
+	if policy.initialDelay == 0 {
+		policy.initialDelay = defaultRetryInitialDelay
+	}
+
+	if policy.maxDelay == 0 {
+		policy.maxDelay = defaultRetryMaxDelay
+	}
+
+	if policy.maxDelay < policy.initialDelay {
+		return policy, aoserrors.New("operation retry max delay is less than initial delay")
+	}
+
+	return policy, nil
+}
+
+func isRetriedPhase(phase string) (retried bool) {
+	return phase == eventPrepare || phase == eventUpdate
+}
+
+func (handler *Handler) retryOperation(id, phase string,
+	operation func() (rebootRequired bool, err error),
+) (rebootRequired bool, err error) {
+	delay := handler.retryPolicy.initialDelay
+
+	for retry := 1; ; retry++ {
+		if rebootRequired, err = operation(); err == nil || !isRetriedPhase(phase) ||
+			retry > handler.retryPolicy.maxRetries || !IsTransientError(err) {
+			return rebootRequired, err
+		}
+
+		log.WithFields(log.Fields{
+			"id": id, "phase": phase, "retry": retry, "delay": delay,
+		}).Warnf("Transient component error: %v", err)
+
+		select {
+		case <-handler.clock.After(delay):
+
+		case <-handler.operationContext().Done():
+			if stopErr := handler.checkStopped(); stopErr != nil {
+				return false, stopErr
+			}
+
+			return false, err
+		}
+
+		if delay *= 2; delay > handler.retryPolicy.maxDelay {
+			delay = handler.retryPolicy.maxDelay
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestOperationRetry(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1", transientErrs: 1}, "id2": {id: "id2"}}
+	storage := newTestStorage()
+
+	cfg := &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}, {ID: "id2", Plugin: "testmodule"}},
+		OperationRetry: config.OperationRetry{
+			MaxRetries:   2,
+			InitialDelay: aostypes.Duration{Duration: 10 * time.Millisecond},
+			MaxDelay:     aostypes.Duration{Duration: 20 * time.Millisecond},
+		},
+	}
+
+	handler := newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Transient errors are retried
+
+	order = nil
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	components["id1"].transientErrs = 2
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateUpdated); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{
+		"id1": {opPrepare, opPrepare, opUpdate, opUpdate, opUpdate},
+		"id2": {opPrepare, opUpdate},
+	}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	handler.Close(context.Background())
+
+	// Component fails once retries are exhausted
+
+	components = map[string]*testModule{"id1": {id: "id1", transientErrs: 3}, "id2": {id: "id2"}}
+	storage = newTestStorage()
+
+	handler = newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	order = nil
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{"id1": {opPrepare, opPrepare, opPrepare}}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	if status := handler.GetCurrentStatus(); !strings.Contains(status.Error, "target is not reachable") {
+		t.Errorf("Wrong status error: %s", status.Error)
+	}
+}
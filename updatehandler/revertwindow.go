@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const committedMsg = "committed (revert unavailable)"
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// After apply, update data needed for revert (downloaded images, config snapshot) is retained during revert window.
+// When the window expires or next update is started, the data is removed and applied components are reported as
+// committed.
+
+func (handler *Handler) startRevertWindow(ids []string) {
+	deadline := handler.clock.Now().Add(handler.revertWindow)
+
+	log.WithFields(log.Fields{"ids": ids, "deadline": deadline}).Debug("Start revert window")
+
+	handler.state.RevertDeadline = &deadline
+	handler.state.RevertComponents = ids
+
+	handler.scheduleCommit()
+}
+
+func (handler *Handler) initRevertWindow() {
+	if handler.state.RevertDeadline == nil {
+		return
+	}
+
+	if handler.clock.Now().Before(*handler.state.RevertDeadline) {
+		handler.scheduleCommit()
+
+		return
+	}
+
+	handler.commitUpdate()
+
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
+}
+
+func (handler *Handler) scheduleCommit() {
+	if handler.commitTimer != nil {
+		handler.commitTimer.Stop()
+	}
+
+	handler.commitTimer = handler.clock.AfterFunc(handler.state.RevertDeadline.Sub(handler.clock.Now()),
+		handler.onRevertWindowExpired)
+}
+
+func (handler *Handler) onRevertWindowExpired() {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if handler.isClosed() || handler.state.RevertDeadline == nil {
+		return
+	}
+
+	handler.commitUpdate()
+
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
+
+	if handler.state.UpdateState == stateIdle {
+		handler.sendStatus()
+	}
+}
+
+// commitUpdate finishes revert window: frees update data and marks applied components as committed.
+func (handler *Handler) commitUpdate() {
+	if handler.commitTimer != nil {
+		handler.commitTimer.Stop()
+		handler.commitTimer = nil
+	}
+
+	if handler.state.RevertDeadline == nil {
+		return
+	}
+
+	log.WithField("ids", handler.state.RevertComponents).Info("Revert window expired, commit update")
+
+	handler.removeUpdateData()
+
+	handler.state.CommittedComponents = handler.state.RevertComponents
+	handler.state.RevertComponents = nil
+	handler.state.RevertDeadline = nil
+}
+
+func (handler *Handler) removeUpdateData() {
+	handler.closeDownloadSession()
+	handler.removeConfigSnapshot()
+}
+
+func (handler *Handler) isCommitted(id string) (committed bool) {
+	for _, committedID := range handler.state.CommittedComponents {
+		if committedID == id {
+			return true
+		}
+	}
+
+	return false
+}
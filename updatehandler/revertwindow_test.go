@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestRevertWindow(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+	storage := newTestStorage()
+	downloadDir := path.Join(tmpDir, "revertWindowDownload")
+	cfg := &config.Config{
+		DownloadDir:   downloadDir,
+		RevertWindow:  aostypes.Duration{Duration: time.Hour},
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}
+
+	handler := newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+	newStatus.State = umclient.StateUpdated
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus, nil, nil)
+
+	// Update data is retained during revert window
+
+	finalStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", AosVersion: 1, Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.ApplyUpdate, &finalStatus, nil, nil)
+
+	if entries, err := os.ReadDir(downloadDir); err != nil || len(entries) != 1 {
+		t.Errorf("Download session should be retained: %v", err)
+	}
+
+	// Update is committed after revert window expired
+
+	finalStatus.Components[0].Error = "committed (revert unavailable)"
+
+	testOperation(t, handler, func() { fakeClock.Advance(time.Hour) }, &finalStatus, nil, nil)
+
+	if entries, err := os.ReadDir(downloadDir); err != nil || len(entries) != 0 {
+		t.Errorf("Download session should be removed: %v", err)
+	}
+
+	handler.Close(context.Background())
+
+	// Committed state persists across restart
+
+	handler = newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	testOperation(t, handler, handler.Registered, &finalStatus, nil, nil)
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+// Staged rollout is requested by rollout annotation of component update info: rollout token and percentage of
+// devices which should install the update. Device bucket is computed from the hash of rollout token and device ID, so
+// it is stable for the token and independent between campaigns with different tokens. Increasing percentage of the
+// same token extends the set of updated devices. Components of devices outside of the rollout are skipped at prepare
+// and reported as installed with deferred by rollout policy error. If device ID is not available, rollout components
+// are deferred as well.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	rolloutDeferredMsg = "deferred by rollout policy"
+	rolloutBuckets     = 10000
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type rolloutAnnotation struct {
+	Token      string  `json:"token"`
+	Percentage float64 `json:"percentage"`
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func getDeviceID(cfg config.Rollout) (deviceID string) {
+	if cfg.DeviceID != "" {
+		return cfg.DeviceID
+	}
+
+	if cfg.DeviceIDFile == "" {
+		return ""
+	}
+
+	data, err := os.ReadFile(cfg.DeviceIDFile)
+	if err != nil {
+		log.WithField("file", cfg.DeviceIDFile).Warnf("Can't read device ID: %v", err)
+
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// rolloutBucket returns stable device bucket in range [0, rolloutBuckets).
+func rolloutBucket(token, deviceID string) (bucket uint64) {
+	hash := sha256.Sum256([]byte(token + "/" + deviceID))
+
+	return binary.BigEndian.Uint64(hash[:8]) % rolloutBuckets
+}
+
+func (handler *Handler) rolloutMatches(id string, rollout *rolloutAnnotation) (matches bool, err error) {
+	if rollout == nil {
+		return true, nil
+	}
+
+	if rollout.Percentage < 0 || rollout.Percentage > 100 {
+		return false, aoserrors.Errorf("wrong rollout percentage of component %s: %v", id, rollout.Percentage)
+	}
+
+	if handler.deviceID == "" {
+		log.WithField("id", id).Warn("Device ID is not available for rollout")
+
+		return false, nil
+	}
+
+	bucket := rolloutBucket(rollout.Token, handler.deviceID)
+
+	log.WithFields(log.Fields{
+		"id": id, "token": rollout.Token, "percentage": rollout.Percentage, "bucket": bucket,
+	}).Debug("Check rollout")
+
+	return float64(bucket) < rollout.Percentage*rolloutBuckets/100, nil
+}
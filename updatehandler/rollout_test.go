@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestRollout(t *testing.T) {
+	cfg := &config.Config{
+		Rollout: config.Rollout{DeviceID: "device1"},
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+			{ID: "id3", Plugin: "testmodule"},
+		},
+	}
+
+	handler := newTestHandler(t, cfg)
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}, {ID: "id3"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// id1 is in full rollout, id2 is deferred, id3 has no rollout
+
+	infos[0].Annotations = json.RawMessage(`{"rollout": {"token": "campaign1", "percentage": 100}}`)
+	infos[1].Annotations = json.RawMessage(`{"rollout": {"token": "campaign1", "percentage": 0}}`)
+
+	order = nil
+
+	handler.PrepareUpdate(infos)
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Error("Wait for status timeout")
+
+	case status := <-handler.StatusChannel():
+		if status.State != umclient.StatePrepared {
+			t.Errorf("Wrong state: %s, error: %s", status.State, status.Error)
+		}
+
+		deferred := false
+
+		for _, component := range status.Components {
+			if component.ID == "id2" && component.Error == "deferred by rollout policy" {
+				deferred = true
+			}
+		}
+
+		if !deferred {
+			t.Errorf("Component is not deferred: %v", status.Components)
+		}
+	}
+
+	if err = checkComponentOps(map[string][]string{
+		"id1": {opPrepare}, "id2": nil, "id3": {opPrepare},
+	}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	testOperation(t, handler, handler.RevertUpdate, nil, nil, nil)
+
+	// Wrong percentage fails prepare
+
+	infos[1].Annotations = json.RawMessage(`{"rollout": {"token": "campaign1", "percentage": 150}}`)
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StateFailed); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+}
@@ -0,0 +1,347 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	sessionPrefix       = "session-"
+	sessionIDLen        = 8
+	sessionManifestName = "manifest.json"
+	downloadFileDefault = "image"
+	downloadFileHashLen = 4
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type sessionManifest struct {
+	Session   string            `json:"session"`
+	Created   time.Time         `json:"created"`
+	Images    []sessionImage    `json:"images,omitempty"`
+	Downloads []sessionDownload `json:"downloads,omitempty"`
+}
+
+type sessionImage struct {
+	URL      string `json:"url"`
+	FileName string `json:"fileName"`
+}
+
+type sessionDownload struct {
+	URL      string `json:"url"`
+	FileName string `json:"fileName"`
+	Sha256   []byte `json:"sha256,omitempty"`
+	Sha512   []byte `json:"sha512,omitempty"`
+	Size     uint64 `json:"size,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// Each update session downloads images into own download dir subdirectory. The session manifest lists completely
+// downloaded images and running downloads with expected image hashes. The session which is interrupted before
+// update is prepared (e.g. by power loss) is resumed by next prepare: already downloaded images are reused and
+// partially downloaded images are resumed if expected hashes are not changed. All other sessions found on startup
+// are orphans and removed.
+
+func (handler *Handler) openDownloadSession() (err error) {
+	if handler.downloadDir == "" {
+		return nil
+	}
+
+	if handler.state.DownloadSession != "" {
+		if _, err = os.Stat(handler.sessionDir()); err == nil {
+			log.WithField("session", handler.state.DownloadSession).Info("Resume download session")
+
+			return nil
+		}
+
+		log.WithField("session", handler.state.DownloadSession).Warn("Download session not found, start new one")
+	}
+
+	sessionID := make([]byte, sessionIDLen)
+
+	if _, err = rand.Read(sessionID); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	handler.state.DownloadSession = sessionPrefix + hex.EncodeToString(sessionID)
+
+	log.WithField("session", handler.state.DownloadSession).Debug("Open download session")
+
+	if err = os.MkdirAll(handler.sessionDir(), 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = handler.writeSessionManifest(sessionManifest{
+		Session: handler.state.DownloadSession, Created: handler.clock.Now(),
+	}); err != nil {
+		return err
+	}
+
+	// Save session to be able to resume it after restart
+	return aoserrors.Wrap(handler.saveState())
+}
+
+func (handler *Handler) closeDownloadSession() {
+	if handler.downloadDir == "" || handler.state.DownloadSession == "" {
+		return
+	}
+
+	log.WithField("session", handler.state.DownloadSession).Debug("Close download session")
+
+	if err := os.RemoveAll(handler.sessionDir()); err != nil {
+		log.Errorf("Can't remove download session: %v", err)
+	}
+
+	handler.state.DownloadSession = ""
+}
+
+func (handler *Handler) sessionDir() (dir string) {
+	return filepath.Join(handler.downloadDir, handler.state.DownloadSession)
+}
+
+// removeOrphanSessions removes everything from download dir except current session.
+func (handler *Handler) removeOrphanSessions() {
+	if handler.downloadDir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(handler.downloadDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Errorf("Can't read download dir: %v", err)
+		}
+
+		return
+	}
+
+	for _, entry := range entries {
+		// Download dir may be shared with other data: only session dirs created by the handler are removed
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), sessionPrefix) {
+			continue
+		}
+
+		if handler.state.DownloadSession != "" && entry.Name() == handler.state.DownloadSession {
+			continue
+		}
+
+		log.WithField("name", entry.Name()).Debug("Remove orphan download session")
+
+		if err := os.RemoveAll(filepath.Join(handler.downloadDir, entry.Name())); err != nil {
+			log.Errorf("Can't remove orphan download session: %v", err)
+		}
+	}
+}
+
+// getSessionImage returns previously downloaded image if it is present in session manifest and valid.
+func (handler *Handler) getSessionImage(updateInfo *umclient.ComponentUpdateInfo) (filePath string) {
+	handler.sessionMutex.Lock()
+	defer handler.sessionMutex.Unlock()
+
+	manifest, err := handler.readSessionManifest()
+	if err != nil {
+		return ""
+	}
+
+	for _, sessionImage := range manifest.Images {
+		if sessionImage.URL != updateInfo.URL {
+			continue
+		}
+
+		filePath = filepath.Join(handler.sessionDir(), sessionImage.FileName)
+
+		if err = handler.checkImage(context.Background(), filePath, updateInfo); err != nil {
+			log.WithField("url", updateInfo.URL).Warnf("Session image is not valid: %v", err)
+
+			return ""
+		}
+
+		log.WithFields(log.Fields{"url": updateInfo.URL, "file": filePath}).Debug("Reuse session image")
+
+		return filePath
+	}
+
+	return ""
+}
+
+func (handler *Handler) addSessionImage(imageURL, filePath string) (err error) {
+	handler.sessionMutex.Lock()
+	defer handler.sessionMutex.Unlock()
+
+	manifest, err := handler.readSessionManifest()
+	if err != nil {
+		return err
+	}
+
+	images := make([]sessionImage, 0, len(manifest.Images)+1)
+
+	for _, sessionImage := range manifest.Images {
+		if sessionImage.URL != imageURL {
+			images = append(images, sessionImage)
+		}
+	}
+
+	manifest.Images = append(images, sessionImage{URL: imageURL, FileName: filepath.Base(filePath)})
+
+	return handler.writeSessionManifest(manifest)
+}
+
+// startSessionDownload registers download in session manifest and returns download file path. Partial file of
+// interrupted download is kept only if it is downloaded for the same image.
+func (handler *Handler) startSessionDownload(
+	imageURL string, updateInfo *umclient.ComponentUpdateInfo,
+) (filePath string, err error) {
+	handler.sessionMutex.Lock()
+	defer handler.sessionMutex.Unlock()
+
+	manifest, err := handler.readSessionManifest()
+	if err != nil {
+		return "", err
+	}
+
+	download := sessionDownload{
+		URL: imageURL, FileName: downloadFileName(imageURL), Sha256: updateInfo.Sha256, Sha512: updateInfo.Sha512,
+		Size: updateInfo.Size,
+	}
+	filePath = filepath.Join(handler.sessionDir(), download.FileName)
+	downloads := make([]sessionDownload, 0, len(manifest.Downloads)+1)
+
+	for _, item := range manifest.Downloads {
+		if item.URL != imageURL {
+			downloads = append(downloads, item)
+
+			continue
+		}
+
+		if download.sameImage(item) {
+			if _, err := os.Stat(filePath); err == nil {
+				log.WithFields(log.Fields{"url": imageURL, "file": filePath}).Info("Resume interrupted download")
+			}
+
+			return filePath, nil
+		}
+	}
+
+	// Partial file of other image can't be resumed
+	if err = os.RemoveAll(filePath); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	manifest.Downloads = append(downloads, download)
+
+	if err = handler.writeSessionManifest(manifest); err != nil {
+		return "", err
+	}
+
+	return filePath, nil
+}
+
+// finishSessionDownload removes download from session manifest once it is finished or failed.
+func (handler *Handler) finishSessionDownload(imageURL string) {
+	handler.sessionMutex.Lock()
+	defer handler.sessionMutex.Unlock()
+
+	manifest, err := handler.readSessionManifest()
+	if err != nil {
+		log.WithField("url", imageURL).Errorf("Can't finish session download: %v", err)
+
+		return
+	}
+
+	downloads := make([]sessionDownload, 0, len(manifest.Downloads))
+
+	for _, item := range manifest.Downloads {
+		if item.URL != imageURL {
+			downloads = append(downloads, item)
+		}
+	}
+
+	manifest.Downloads = downloads
+
+	if err = handler.writeSessionManifest(manifest); err != nil {
+		log.WithField("url", imageURL).Errorf("Can't finish session download: %v", err)
+	}
+}
+
+func (download sessionDownload) sameImage(other sessionDownload) (same bool) {
+	return download.FileName == other.FileName && download.Size == other.Size &&
+		bytes.Equal(download.Sha256, other.Sha256) && bytes.Equal(download.Sha512, other.Sha512)
+}
+
+// downloadFileName returns download file name which is stable for the image URL.
+func downloadFileName(imageURL string) (fileName string) {
+	hash := sha256.Sum256([]byte(imageURL))
+	fileName = downloadFileDefault
+
+	if urlVal, err := url.Parse(imageURL); err == nil {
+		if base := path.Base(urlVal.Path); base != "/" && base != "." {
+			fileName = base
+		}
+	}
+
+	return hex.EncodeToString(hash[:downloadFileHashLen]) + "-" + fileName
+}
+
+func (handler *Handler) readSessionManifest() (manifest sessionManifest, err error) {
+	data, err := os.ReadFile(filepath.Join(handler.sessionDir(), sessionManifestName))
+	if err != nil {
+		return manifest, aoserrors.Wrap(err)
+	}
+
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return manifest, aoserrors.Wrap(err)
+	}
+
+	return manifest, nil
+}
+
+func (handler *Handler) writeSessionManifest(manifest sessionManifest) (err error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return writeFileAtomic(filepath.Join(handler.sessionDir(), sessionManifestName), data)
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestOrphanDownloadSessions(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+	downloadDir := path.Join(tmpDir, "orphanDownload")
+	orphanDir := path.Join(downloadDir, "session-orphan")
+	otherDir := path.Join(downloadDir, "other")
+	otherFile := path.Join(downloadDir, "session-file")
+
+	for _, dir := range []string{orphanDir, otherDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Can't create dir: %s", err)
+		}
+	}
+
+	if err := os.WriteFile(otherFile, nil, 0o600); err != nil {
+		t.Fatalf("Can't create file: %s", err)
+	}
+
+	newTestHandler(t, &config.Config{
+		DownloadDir:   downloadDir,
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	if _, err := os.Stat(orphanDir); !os.IsNotExist(err) {
+		t.Errorf("Orphan download session should be removed: %v", err)
+	}
+
+	for _, item := range []string{otherDir, otherFile} {
+		if _, err := os.Stat(item); err != nil {
+			t.Errorf("Item not created by handler should be kept: %v", err)
+		}
+	}
+}
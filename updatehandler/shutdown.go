@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Close rejects new requests at once. Running FSM transition and background version refresh are tracked as
+// operations: close waits for them till the context is done. Remaining component operations of running transition
+// are not started after close is requested, so the transition finishes at the next safe point and its result is
+// persisted as usual. When the context is done, downloads and blocker waiting are canceled. If the operation is
+// still not finished, e.g. module operation hangs, close fails and modules are left open as they are in use. Once
+// operations are finished, timers are stopped, state is flushed and modules and key providers are closed.
+//
+// Transition which fails only because it is interrupted by close is not persisted as failed: the handler restores
+// the state checkpoint persisted before the transition, so the next start resumes from the same point as after power
+// loss. E.g. interrupted prepare keeps its download session with partial downloads to be resumed by next prepare,
+// interrupted update keeps prepared state to be started again. Module states are persisted by modules on each
+// operation.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	closedMsg          = "update handler is closed"
+	closeCancelTimeout = 5 * time.Second
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// Close closes update handler. Storages passed to the handler may be closed only if close succeeds.
+func (handler *Handler) Close(ctx context.Context) (err error) {
+	log.Debug("Close update handler")
+
+	handler.stopMutex.Lock()
+
+	if handler.closed {
+		handler.stopMutex.Unlock()
+
+		return aoserrors.New(closedMsg)
+	}
+
+	handler.closed = true
+
+	handler.stopMutex.Unlock()
+
+	if err = handler.waitOperations(ctx); err != nil {
+		return err
+	}
+
+	handler.Lock()
+	defer handler.Unlock()
+
+	if handler.commitTimer != nil {
+		handler.commitTimer.Stop()
+		handler.commitTimer = nil
+	}
+
+	if handler.errorTimer != nil {
+		handler.errorTimer.Stop()
+		handler.errorTimer = nil
+	}
+
+	handler.stopEventTimer()
+
+	if handler.prefetch != nil {
+		handler.prefetch.close()
+	}
+
+	if err = handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
+
+	for id, component := range handler.components {
+		if closeErr := component.module.Close(ctx); closeErr != nil {
+			log.WithField("id", id).Errorf("Can't close module: %v", closeErr)
+
+			if err == nil {
+				err = aoserrors.Wrap(closeErr)
+			}
+		}
+	}
+
+	closeKeyProviders(handler.keyProviders)
+
+	return err
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// startOperation tracks background operation, it fails if handler is closed.
+func (handler *Handler) startOperation() (started bool) {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	if handler.closed {
+		return false
+	}
+
+	handler.operations.Add(1)
+
+	return true
+}
+
+func (handler *Handler) finishOperation() {
+	handler.operations.Done()
+}
+
+// restoreCheckpoint restores persisted state if running transition failed as it is interrupted by close.
+func (handler *Handler) restoreCheckpoint() (restored bool) {
+	if !handler.isClosed() || handler.isQuarantined() || handler.isCanceled() ||
+		handler.fsm.Current() != stateFailed || !isInterruptError(handler.state.Error) {
+		return false
+	}
+
+	jsonState, err := handler.storage.GetUpdateState()
+	if err != nil {
+		log.Errorf("Can't get update state checkpoint: %s", aoserrors.Wrap(err))
+
+		return false
+	}
+
+	var state handlerState
+
+	if len(jsonState) != 0 {
+		if err = json.Unmarshal(jsonState, &state); err != nil {
+			log.Errorf("Can't parse update state checkpoint: %s", aoserrors.Wrap(err))
+
+			return false
+		}
+	}
+
+	if state.UpdateState == "" {
+		state.UpdateState = stateIdle
+	}
+
+	log.WithField("state", state.UpdateState).Warn("Transition is interrupted by close, restore state checkpoint")
+
+	handler.state = state
+	handler.fsm.SetState(state.UpdateState)
+
+	return true
+}
+
+func (handler *Handler) isClosed() (closed bool) {
+	handler.stopMutex.Lock()
+	defer handler.stopMutex.Unlock()
+
+	return handler.closed
+}
+
+func (handler *Handler) waitOperations(ctx context.Context) (err error) {
+	done := make(chan struct{})
+
+	go func() {
+		handler.operations.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+
+	case <-ctx.Done():
+	}
+
+	log.Warn("Running operation is not finished in time, cancel it")
+
+	handler.stopMutex.Lock()
+	handler.stopCancel()
+	handler.stopMutex.Unlock()
+
+	select {
+	case <-done:
+		return nil
+
+	case <-handler.clock.After(closeCancelTimeout):
+		return aoserrors.New("running operation is not canceled")
+	}
+}
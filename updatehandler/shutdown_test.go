@@ -0,0 +1,322 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestClose(t *testing.T) {
+	type testCase struct {
+		state     umclient.UMState
+		operation func(handler *updatehandler.Handler, infos []umclient.ComponentUpdateInfo)
+	}
+
+	prepare := func(handler *updatehandler.Handler, infos []umclient.ComponentUpdateInfo) {
+		handler.PrepareUpdate(infos)
+
+		if err := waitForState(handler, umclient.StatePrepared); err != nil {
+			t.Errorf("Wait for state failed: %s", err)
+		}
+	}
+
+	for _, testItem := range []testCase{
+		{state: umclient.StateIdle},
+		{state: umclient.StatePrepared, operation: prepare},
+		{
+			state: umclient.StateUpdated,
+			operation: func(handler *updatehandler.Handler, infos []umclient.ComponentUpdateInfo) {
+				prepare(handler, infos)
+				handler.StartUpdate()
+
+				if err := waitForState(handler, umclient.StateUpdated); err != nil {
+					t.Errorf("Wait for state failed: %s", err)
+				}
+			},
+		},
+		{
+			state: umclient.StateFailed,
+			operation: func(handler *updatehandler.Handler, infos []umclient.ComponentUpdateInfo) {
+				components["id1"].status = aoserrors.New("prepare error")
+
+				handler.PrepareUpdate(infos)
+
+				if err := waitForState(handler, umclient.StateFailed); err != nil {
+					t.Errorf("Wait for state failed: %s", err)
+				}
+			},
+		},
+	} {
+		storage := newTestStorage()
+
+		handler := newTestHandler(t, &config.Config{
+			UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}, {ID: "id2", Plugin: "testmodule"}},
+		}, withStorage(storage))
+
+		testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+		infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+		if err != nil {
+			t.Fatalf("Can't create update infos: %s", err)
+		}
+
+		if testItem.operation != nil {
+			testItem.operation(handler, infos)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+
+		if err = handler.Close(ctx); err != nil {
+			t.Errorf("Can't close update handler: %s", err)
+		}
+
+		cancel()
+
+		for id, component := range components {
+			if !component.closed {
+				t.Errorf("Module %s is not closed", id)
+			}
+		}
+
+		if err = handler.Close(context.Background()); err == nil {
+			t.Error("Error expected on second close")
+		}
+
+		// Requests are rejected after close
+		handler.PrepareUpdate(infos)
+
+		select {
+		case status := <-handler.StatusChannel():
+			t.Errorf("Unexpected status after close: %v", status)
+
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		handler = newTestHandler(t, &config.Config{
+			UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}, {ID: "id2", Plugin: "testmodule"}},
+		}, withStorage(storage), withModules(components))
+
+		handler.Registered()
+
+		if err = waitForState(handler, testItem.state); err != nil {
+			t.Errorf("Wait for state failed: %s", err)
+		}
+
+		handler.Close(context.Background())
+	}
+}
+
+func TestCloseDuringPrepare(t *testing.T) {
+	content := bytes.Repeat([]byte("close"), 65536)
+	imagePath := path.Join(tmpDir, "closeimage.bin")
+
+	if err := writeImage(imagePath, content, false); err != nil {
+		t.Fatalf("Can't write image: %s", err)
+	}
+
+	imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("Can't create file info: %s", err)
+	}
+
+	var rangeHeader atomic.Value
+
+	downloadStarted := make(chan struct{}, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.ServeContent(w, r, "closeimage.bin", time.Time{}, bytes.NewReader(content))
+
+			return
+		}
+
+		// First download hangs in the middle till the client is gone
+		if r.Header.Get("Range") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+
+			_, _ = w.Write(content[:len(content)/2])
+
+			w.(http.Flusher).Flush() //nolint:forcetypeassert
+
+			downloadStarted <- struct{}{}
+
+			<-r.Context().Done()
+
+			return
+		}
+
+		rangeHeader.Store(r.Header.Get("Range"))
+
+		http.ServeContent(w, r, "closeimage.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	storage := newTestStorage()
+	cfg := &config.Config{
+		DownloadDir:   path.Join(tmpDir, "closeDownload"),
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}
+	infos := []umclient.ComponentUpdateInfo{{
+		ID: "id1", AosVersion: 1, URL: server.URL + "/closeimage.bin",
+		Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+	}}
+
+	handler := newTestHandler(t, cfg, withStorage(storage))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	handler.PrepareUpdate(infos)
+
+	select {
+	case <-downloadStarted:
+		// Let the client receive the first part of the image
+		time.Sleep(100 * time.Millisecond)
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait download timeout")
+	}
+
+	statusChannel := make(chan umclient.Status, 1)
+
+	go func() {
+		statusChannel <- <-handler.StatusChannel()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Download hangs, it is canceled once close context is done. Interrupted prepare is not persisted as failed.
+	if err = handler.Close(ctx); err != nil {
+		t.Errorf("Can't close update handler: %s", err)
+	}
+
+	select {
+	case status := <-statusChannel:
+		if status.State != umclient.StateIdle {
+			t.Errorf("Wrong state: %s", status.State)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait status timeout")
+	}
+
+	handler = newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	handler.Registered()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	// Next prepare resumes partial download
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if value, _ := rangeHeader.Load().(string); value != fmt.Sprintf("bytes=%d-", len(content)/2) {
+		t.Errorf("Wrong range header: %s", value)
+	}
+}
+
+func TestCloseDuringUpdate(t *testing.T) {
+	storage := newTestStorage()
+	cfg := &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", UpdatePriority: 1},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	}
+
+	handler := newTestHandler(t, cfg, withStorage(storage))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	// Update of id1 hangs till close context is done, id2 is not updated after close
+
+	components["id1"].waitCancel = true
+	order = nil
+
+	handler.StartUpdate()
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err = handler.Close(ctx); err != nil {
+		t.Errorf("Can't close update handler: %s", err)
+	}
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{"id1": {opUpdate}, "id2": nil}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	// Update is started again after restart
+
+	handler = newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	handler.Registered()
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	handler.StartUpdate()
+
+	if err = waitForState(handler, umclient.StateUpdated); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+}
@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Image signatures are passed in update annotations as list of key name and signature pairs. Each signature is
+// made over image SHA-256 digest: RSA PKCS #1 v1.5, ECDSA ASN.1 or Ed25519 signature depending on the key type.
+// Signatures are verified at prepare before the image is downloaded, the image itself is then checked against the
+// signed digest.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type imageSignature struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type signaturePolicy struct {
+	name      string
+	keys      map[string]crypto.PublicKey
+	threshold int
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newSignaturePolicies(cfg *config.Config) (policies map[string]*signaturePolicy, err error) {
+	if err = checkSignaturePolicies(cfg); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+
+	for _, keyCfg := range cfg.SigningKeys {
+		if keys[keyCfg.Name], err = loadPublicKey(keyCfg.PublicKey); err != nil {
+			return nil, aoserrors.Errorf("can't load signing key %s: %v", keyCfg.Name, err)
+		}
+	}
+
+	policies = make(map[string]*signaturePolicy)
+
+	for _, policyCfg := range cfg.SignaturePolicies {
+		policy := &signaturePolicy{
+			name: policyCfg.Name, keys: make(map[string]crypto.PublicKey), threshold: policyCfg.Threshold,
+		}
+
+		for _, name := range policyCfg.Keys {
+			policy.keys[name] = keys[name]
+		}
+
+		if policy.threshold == 0 {
+			policy.threshold = len(policy.keys)
+		}
+
+		policies[policy.name] = policy
+	}
+
+	return policies, nil
+}
+
+func checkSignaturePolicies(cfg *config.Config) (err error) {
+	keys := make(map[string]bool)
+
+	for _, keyCfg := range cfg.SigningKeys {
+		if keyCfg.Name == "" {
+			return aoserrors.New("signing key name is empty")
+		}
+
+		if keys[keyCfg.Name] {
+			return aoserrors.Errorf("duplicated signing key %s", keyCfg.Name)
+		}
+
+		keys[keyCfg.Name] = true
+	}
+
+	policies := make(map[string]bool)
+
+	for _, policyCfg := range cfg.SignaturePolicies {
+		if policyCfg.Name == "" {
+			return aoserrors.New("signature policy name is empty")
+		}
+
+		if policies[policyCfg.Name] {
+			return aoserrors.Errorf("duplicated signature policy %s", policyCfg.Name)
+		}
+
+		policies[policyCfg.Name] = true
+
+		for _, name := range policyCfg.Keys {
+			if !keys[name] {
+				return aoserrors.Errorf("signature policy %s: signing key %s not found", policyCfg.Name, name)
+			}
+		}
+
+		if len(policyCfg.Keys) == 0 || policyCfg.Threshold < 0 || policyCfg.Threshold > len(policyCfg.Keys) {
+			return aoserrors.Errorf("signature policy %s: wrong keys threshold", policyCfg.Name)
+		}
+	}
+
+	for _, moduleCfg := range cfg.UpdateModules {
+		if moduleCfg.SignaturePolicy != "" && !policies[moduleCfg.SignaturePolicy] {
+			return aoserrors.Errorf("signature policy %s of module %s not found",
+				moduleCfg.SignaturePolicy, moduleCfg.ID)
+		}
+	}
+
+	return nil
+}
+
+func loadPublicKey(fileName string) (key crypto.PublicKey, err error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, aoserrors.New("invalid PEM data")
+	}
+
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		return cert.PublicKey, nil
+	}
+
+	if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return key, nil
+}
+
+func (policy *signaturePolicy) verify(updateInfo *umclient.ComponentUpdateInfo) (err error) {
+	if len(updateInfo.Sha256) != sha256.Size {
+		return aoserrors.New("image SHA-256 digest is required for signature verification")
+	}
+
+	signed := make(map[string]bool)
+
+	for _, signature := range getUpdateAnnotations(updateInfo.Annotations).Signatures {
+		key, ok := policy.keys[signature.Key]
+		if !ok || signed[signature.Key] {
+			continue
+		}
+
+		if err := verifySignature(key, updateInfo.Sha256, signature.Value); err != nil {
+			log.WithFields(log.Fields{"id": updateInfo.ID, "key": signature.Key}).Warnf(
+				"Invalid image signature: %v", err)
+
+			continue
+		}
+
+		signed[signature.Key] = true
+	}
+
+	log.WithFields(log.Fields{
+		"id": updateInfo.ID, "policy": policy.name, "signed": len(signed), "threshold": policy.threshold,
+	}).Debug("Image signatures verified")
+
+	if len(signed) < policy.threshold {
+		return aoserrors.Errorf("not enough valid image signatures: %d of %d required by policy %s",
+			len(signed), policy.threshold, policy.name)
+	}
+
+	return nil
+}
+
+func verifySignature(key crypto.PublicKey, digest, signature []byte) (err error) {
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		return aoserrors.Wrap(rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, signature))
+
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(publicKey, digest, signature) {
+			return aoserrors.New("ECDSA verification failed")
+		}
+
+	case ed25519.PublicKey:
+		if !ed25519.Verify(publicKey, digest, signature) {
+			return aoserrors.New("Ed25519 verification failed")
+		}
+
+	default:
+		return aoserrors.Errorf("unsupported key type %T", key)
+	}
+
+	return nil
+}
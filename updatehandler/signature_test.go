@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestImageSignatures(t *testing.T) {
+	_, vendorKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %s", err)
+	}
+
+	oemKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %s", err)
+	}
+
+	signingKeys := []config.SigningKey{
+		{Name: "vendor", PublicKey: path.Join(tmpDir, "vendor.pem")},
+		{Name: "oem", PublicKey: path.Join(tmpDir, "oem.pem")},
+	}
+
+	for i, key := range []crypto.Signer{vendorKey, oemKey} {
+		if err = writePublicKey(signingKeys[i].PublicKey, key.Public()); err != nil {
+			t.Fatalf("Can't write public key: %s", err)
+		}
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:       cfg.DownloadDir,
+		SigningKeys:       signingKeys,
+		SignaturePolicies: []config.SignaturePolicy{{Name: "dual", Keys: []string{"vendor", "oem"}, Threshold: 2}},
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", SignaturePolicy: "dual"},
+			{ID: "id2", Plugin: "testmodule", SignaturePolicy: "dual"},
+		},
+	})
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// id1 is signed by both keys, id2 is signed by vendor key and has invalid OEM signature
+
+	for i := range infos {
+		vendorSignature := ed25519.Sign(vendorKey, infos[i].Sha256)
+
+		oemDigest := infos[i].Sha256
+		if infos[i].ID == "id2" {
+			oemDigest = make([]byte, sha256.Size)
+		}
+
+		oemSignature, err := ecdsa.SignASN1(rand.Reader, oemKey, oemDigest)
+		if err != nil {
+			t.Fatalf("Can't sign image: %s", err)
+		}
+
+		if infos[i].Annotations, err = json.Marshal(map[string]interface{}{
+			"signatures": []map[string]interface{}{
+				{"key": "vendor", "value": vendorSignature},
+				{"key": "oem", "value": oemSignature},
+			},
+		}); err != nil {
+			t.Fatalf("Can't marshal annotations: %s", err)
+		}
+	}
+
+	signatureErr := "not enough valid image signatures: 1 of 2"
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &umclient.Status{
+		State: umclient.StateFailed,
+		Error: signatureErr,
+		Components: append(currentStatus.Components, []umclient.ComponentStatusInfo{
+			{ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling},
+			{ID: "id2", AosVersion: infos[1].AosVersion, Status: umclient.StatusError, Error: signatureErr},
+		}...),
+	}, nil, nil)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func writePublicKey(fileName string, key crypto.PublicKey) (err error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+}
@@ -0,0 +1,325 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const snapshotFileName = "configsnapshot.tar.gz"
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) createConfigSnapshot() (err error) {
+	if len(handler.snapshotPaths) == 0 || handler.state.SnapshotHash != nil {
+		return nil
+	}
+
+	log.WithField("paths", handler.snapshotPaths).Debug("Create config snapshot")
+
+	if handler.state.SnapshotHash, err = createSnapshot(handler.snapshotFile, handler.snapshotPaths); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(handler.saveState())
+}
+
+func (handler *Handler) restoreConfigSnapshot() (err error) {
+	if handler.state.SnapshotHash == nil {
+		return nil
+	}
+
+	log.WithField("paths", handler.snapshotPaths).Debug("Restore config snapshot")
+
+	return aoserrors.Wrap(restoreSnapshot(handler.snapshotFile, handler.snapshotPaths, handler.state.SnapshotHash))
+}
+
+func (handler *Handler) removeConfigSnapshot() {
+	if handler.snapshotFile == "" {
+		return
+	}
+
+	if err := os.RemoveAll(handler.snapshotFile); err != nil {
+		log.Errorf("Can't remove config snapshot: %v", err)
+	}
+
+	handler.state.SnapshotHash = nil
+}
+
+func createSnapshot(archivePath string, paths []string) (hash []byte, err error) {
+	if err = os.MkdirAll(filepath.Dir(archivePath), 0o755); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	file, err := os.OpenFile(archivePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	gzipWriter := gzip.NewWriter(io.MultiWriter(file, hasher))
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, snapshotPath := range paths {
+		if err = filepath.Walk(snapshotPath, func(itemPath string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+			return addSnapshotItem(tarWriter, itemPath, info)
+		}); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				log.WithField("path", snapshotPath).Warn("Snapshot path doesn't exist")
+
+				continue
+			}
+
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	if err = tarWriter.Close(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = gzipWriter.Close(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = file.Sync(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return hasher.Sum(nil), nil
+}
+
+func addSnapshotItem(tarWriter *tar.Writer, itemPath string, info fs.FileInfo) (err error) {
+	var link string
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(itemPath); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	header.Name = strings.TrimPrefix(itemPath, "/")
+
+	if err = tarWriter.WriteHeader(header); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(itemPath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if _, err = io.Copy(tarWriter, file); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func restoreSnapshot(archivePath string, paths []string, hash []byte) (err error) {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], hash) {
+		return aoserrors.New("config snapshot hash mismatch")
+	}
+
+	restorePaths := make(map[string]string)
+
+	for _, snapshotPath := range paths {
+		snapshotPath = filepath.Clean(snapshotPath)
+		restorePath := snapshotRestorePath(snapshotPath)
+
+		// Leftover of interrupted restore
+		if err = os.RemoveAll(restorePath); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		restorePaths[snapshotPath] = restorePath
+	}
+
+	if err = extractSnapshot(data, restorePaths); err != nil {
+		return err
+	}
+
+	for snapshotPath, restorePath := range restorePaths {
+		if err = replaceSnapshotPath(snapshotPath, restorePath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// snapshotRestorePath returns path snapshot item is extracted to before it replaces the item. It is placed next to
+// the item to be on the same file system.
+func snapshotRestorePath(snapshotPath string) (restorePath string) {
+	return filepath.Join(filepath.Dir(snapshotPath), "."+filepath.Base(snapshotPath)+".restore")
+}
+
+func extractSnapshot(data []byte, restorePaths map[string]string) (err error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return aoserrors.Wrap(err)
+		}
+
+		itemPath := filepath.Join("/", header.Name) //nolint:gosec // archive is created by UM and verified by hash
+
+		restorePath, ok := snapshotItemRestorePath(itemPath, restorePaths)
+		if !ok {
+			log.WithField("name", header.Name).Warn("Skip snapshot item out of snapshot paths")
+
+			continue
+		}
+
+		if err = restoreSnapshotItem(tarReader, header, restorePath); err != nil {
+			return err
+		}
+	}
+}
+
+func snapshotItemRestorePath(itemPath string, restorePaths map[string]string) (restorePath string, ok bool) {
+	for snapshotPath, restorePath := range restorePaths {
+		if itemPath == snapshotPath {
+			return restorePath, true
+		}
+
+		if strings.HasPrefix(itemPath, snapshotPath+"/") {
+			return filepath.Join(restorePath, strings.TrimPrefix(itemPath, snapshotPath)), true
+		}
+	}
+
+	return "", false
+}
+
+// replaceSnapshotPath puts extracted snapshot item in place of the current one. Items are exchanged atomically, so
+// either current or restored item exists at any time, and the current item is removed after the exchange only.
+func replaceSnapshotPath(snapshotPath, restorePath string) (err error) {
+	if _, err = os.Lstat(restorePath); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return aoserrors.Wrap(err)
+		}
+
+		// Item didn't exist when snapshot was created
+		return aoserrors.Wrap(os.RemoveAll(snapshotPath))
+	}
+
+	if _, err = os.Lstat(snapshotPath); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return aoserrors.Wrap(err)
+		}
+
+		return aoserrors.Wrap(os.Rename(restorePath, snapshotPath))
+	}
+
+	if err = unix.Renameat2(unix.AT_FDCWD, restorePath, unix.AT_FDCWD, snapshotPath, unix.RENAME_EXCHANGE); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	// Restore path holds replaced item after the exchange
+	return aoserrors.Wrap(os.RemoveAll(restorePath))
+}
+
+func restoreSnapshotItem(tarReader *tar.Reader, header *tar.Header, itemPath string) (err error) {
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err = os.MkdirAll(itemPath, header.FileInfo().Mode().Perm()); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+	case tar.TypeSymlink:
+		if err = os.Symlink(header.Linkname, itemPath); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+	case tar.TypeReg:
+		file, err := os.OpenFile(itemPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, header.FileInfo().Mode().Perm())
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+		defer file.Close()
+
+		if _, err = io.Copy(file, tarReader); err != nil { //nolint:gosec // archive size is limited by snapshot
+			return aoserrors.Wrap(err)
+		}
+
+		if err = file.Sync(); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+	default:
+		log.WithField("name", header.Name).Warn("Skip unsupported snapshot item")
+
+		return nil
+	}
+
+	if err = os.Lchown(itemPath, header.Uid, header.Gid); err != nil {
+		log.WithField("path", itemPath).Warnf("Can't restore snapshot item owner: %v", err)
+	}
+
+	return nil
+}
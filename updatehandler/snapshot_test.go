@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestConfigSnapshot(t *testing.T) {
+	snapshotDir := path.Join(tmpDir, "snapshot")
+	configFile := path.Join(snapshotDir, "network.conf")
+	newFile := path.Join(snapshotDir, "new.conf")
+
+	if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+		t.Fatalf("Can't create snapshot dir: %s", err)
+	}
+
+	if err := os.WriteFile(configFile, []byte("original"), 0o600); err != nil {
+		t.Fatalf("Can't write config file: %s", err)
+	}
+
+	cfg := &config.Config{
+		WorkingDir:    path.Join(tmpDir, "workingDir"),
+		DownloadDir:   path.Join(tmpDir, "downloadDir"),
+		SnapshotPaths: []string{snapshotDir},
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}
+
+	handler := newTestHandler(t, cfg)
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+	newStatus.State = umclient.StateUpdated
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus, nil, nil)
+
+	if err := os.WriteFile(configFile, []byte("modified"), 0o600); err != nil {
+		t.Fatalf("Can't write config file: %s", err)
+	}
+
+	if err := os.WriteFile(newFile, []byte("new"), 0o600); err != nil {
+		t.Fatalf("Can't write config file: %s", err)
+	}
+
+	testOperation(t, handler, handler.RevertUpdate, &currentStatus, nil, nil)
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatalf("Can't read config file: %s", err)
+	}
+
+	if string(data) != "original" {
+		t.Errorf("Wrong config file content: %s", string(data))
+	}
+
+	if _, err := os.Stat(newFile); !os.IsNotExist(err) {
+		t.Error("New config file should be removed")
+	}
+
+	if _, err := os.Stat(path.Join(tmpDir, ".snapshot.restore")); !os.IsNotExist(err) {
+		t.Error("Snapshot restore dir should be removed")
+	}
+}
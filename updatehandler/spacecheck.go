@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/writabledir"
+)
+
+// Update targets which receive many small files, e.g. overlay update dirs or package destinations, may run out of
+// inodes while free bytes are still available: module operation then fails with confusing ENOSPC error. Before
+// prepare, free bytes and inodes of configured target paths are checked for each component of the update and the
+// component fails with explicit error. Not existing path is checked on its nearest existing parent.
+//
+// Before images are downloaded, image size of each component is checked against space of its update target reported
+// by module, and total size of images which are not downloaded yet is checked against free space of download dir.
+// Image size is a lower bound of space required on target: compressed image is checked against target as is.
+// Download dir space includes decrypted copies of encrypted images and unpacked bundles, bundle shared by components is
+// counted once, partially downloaded and prefetched images are not counted.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// TargetSpaceProvider optional interface which can be implemented by update module to report space available for
+// component image on update target, e.g. size of fallback partition.
+type TargetSpaceProvider interface {
+	// GetTargetSpace returns space available on update target in bytes
+	GetTargetSpace() (space uint64, err error)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkSpaceCheck(spaceCheck *config.SpaceCheck) (err error) {
+	if spaceCheck == nil {
+		return nil
+	}
+
+	if len(spaceCheck.Paths) == 0 {
+		return aoserrors.New("space check paths are not set")
+	}
+
+	for _, path := range spaceCheck.Paths {
+		if !filepath.IsAbs(path) {
+			return aoserrors.Errorf("space check path %s is not absolute", path)
+		}
+	}
+
+	if spaceCheck.MinFreeSpace == 0 && spaceCheck.MinFreeInodes == 0 {
+		return aoserrors.New("space check min free space or inodes should be set")
+	}
+
+	return nil
+}
+
+func (handler *Handler) checkTargetSpace(componentsInfo map[string]*umclient.ComponentUpdateInfo) (err error) {
+	ids := make([]string, 0, len(componentsInfo))
+
+	for id := range componentsInfo {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if spaceErr := handler.checkComponentSpace(componentsInfo[id]); spaceErr != nil {
+			componentError(handler.state.ComponentStatuses[id], spaceErr)
+
+			if err == nil {
+				err = aoserrors.Errorf("component %s: %v", id, spaceErr)
+			}
+		}
+	}
+
+	return err
+}
+
+func (handler *Handler) checkComponentSpace(updateInfo *umclient.ComponentUpdateInfo) (err error) {
+	component := handler.components[updateInfo.ID]
+
+	if component.spaceCheck != nil {
+		for _, path := range component.spaceCheck.Paths {
+			log.WithFields(log.Fields{"id": updateInfo.ID, "path": path}).Debug("Check target space")
+
+			if err = writabledir.CheckFreeSpace(
+				path, component.spaceCheck.MinFreeSpace, component.spaceCheck.MinFreeInodes); err != nil {
+				return aoserrors.Wrap(err)
+			}
+		}
+	}
+
+	provider, ok := baseModule(component.module).(TargetSpaceProvider)
+	if !ok || updateInfo.Size == 0 || isBundle(updateInfo) {
+		return nil
+	}
+
+	space, err := provider.GetTargetSpace()
+	if err != nil {
+		return aoserrors.Errorf("can't get target space: %v", err)
+	}
+
+	log.WithFields(log.Fields{"id": updateInfo.ID, "space": space, "size": updateInfo.Size}).Debug(
+		"Check target space")
+
+	if updateInfo.Size > space {
+		return aoserrors.Errorf("image size %d exceeds target space %d", updateInfo.Size, space)
+	}
+
+	return nil
+}
+
+// checkDownloadSpace checks that images of all components fit into download dir before download is started.
+func (handler *Handler) checkDownloadSpace(componentsInfo map[string]*umclient.ComponentUpdateInfo) (err error) {
+	if handler.downloadDir == "" {
+		return nil
+	}
+
+	var required uint64
+
+	sizes := make(map[string]uint64)
+	bundles := make(map[string]bool)
+
+	for id, updateInfo := range componentsInfo {
+		if isBundle(updateInfo) {
+			if bundles[updateInfo.URL] {
+				continue
+			}
+
+			bundles[updateInfo.URL] = true
+		}
+
+		if size := handler.getDownloadSize(updateInfo); size != 0 {
+			sizes[id] = size
+			required += size
+		}
+	}
+
+	log.WithFields(log.Fields{"dir": handler.downloadDir, "required": required}).Debug("Check download space")
+
+	if err = writabledir.CheckFreeSpace(handler.sessionDir(), required, 0); err != nil {
+		for id, size := range sizes {
+			componentError(handler.state.ComponentStatuses[id],
+				aoserrors.Errorf("can't download image of %d bytes: %v", size, err))
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// getDownloadSize returns space required in download session for component image.
+func (handler *Handler) getDownloadSize(updateInfo *umclient.ComponentUpdateInfo) (size uint64) {
+	if urlVal, err := url.Parse(updateInfo.URL); err == nil && urlVal.Scheme != "file" &&
+		(handler.prefetch == nil || !handler.prefetch.hasImage(updateInfo)) {
+		size = updateInfo.Size
+
+		// Interrupted download is resumed in the same file
+		if info, err := os.Stat(filepath.Join(handler.sessionDir(), downloadFileName(updateInfo.URL))); err == nil {
+			size -= min(size, uint64(info.Size()))
+		}
+	}
+
+	if getUpdateAnnotations(updateInfo.Annotations).Encryption != nil {
+		size += updateInfo.Size
+	}
+
+	// Bundle is unpacked into download session
+	if isBundle(updateInfo) {
+		size += updateInfo.Size
+	}
+
+	return size
+}
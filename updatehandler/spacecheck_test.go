@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestSpaceCheck(t *testing.T) {
+	var stat syscall.Statfs_t
+
+	if err := syscall.Statfs(tmpDir, &stat); err != nil {
+		t.Fatalf("Can't get filesystem stat: %s", err)
+	}
+
+	if stat.Files == 0 {
+		t.Skip("Filesystem doesn't report inode count")
+	}
+
+	moduleConfigs := []config.ModuleConfig{
+		{ID: "id1", Plugin: "testmodule", SpaceCheck: &config.SpaceCheck{
+			Paths: []string{filepath.Join(tmpDir, "overlay", "update")}, MinFreeSpace: 1, MinFreeInodes: math.MaxUint64,
+		}},
+		{ID: "id2", Plugin: "testmodule", SpaceCheck: &config.SpaceCheck{
+			Paths: []string{tmpDir}, MinFreeSpace: 1, MinFreeInodes: 1,
+		}},
+	}
+
+	handler := newTestHandler(t, &config.Config{UpdateModules: moduleConfigs})
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Prepare fails before module operations if target is out of inodes
+
+	failedStatus := currentStatus
+	failedStatus.State = umclient.StateFailed
+	failedStatus.Error = "component id1: no inodes in"
+	failedStatus.Components = append(failedStatus.Components,
+		umclient.ComponentStatusInfo{
+			ID: "id1", AosVersion: infos[0].AosVersion, VendorVersion: infos[0].VendorVersion,
+			Status: umclient.StatusError, Error: "no inodes in",
+		},
+		umclient.ComponentStatusInfo{
+			ID: "id2", AosVersion: infos[1].AosVersion, VendorVersion: infos[1].VendorVersion,
+			Status: umclient.StatusInstalling,
+		})
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &failedStatus,
+		map[string][]string{"id1": nil, "id2": nil}, nil)
+
+	// Space check config is validated
+
+	moduleConfigs[1].SpaceCheck.Paths = []string{"overlay"}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{UpdateModules: moduleConfigs}); len(findings) != 1 ||
+		!strings.Contains(findings[0].Message, "space check path overlay is not absolute") {
+		t.Errorf("Wrong config findings: %v", findings)
+	}
+}
+
+func TestPrepareSpace(t *testing.T) {
+	var stat syscall.Statfs_t
+
+	downloadDir := filepath.Join(tmpDir, "spaceDownload")
+
+	if err := os.MkdirAll(downloadDir, 0o755); err != nil {
+		t.Fatalf("Can't create download dir: %s", err)
+	}
+
+	if err := syscall.Statfs(downloadDir, &stat); err != nil {
+		t.Fatalf("Can't get filesystem stat: %s", err)
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: downloadDir,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	})
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	// Prepare fails before download if image doesn't fit into update target
+
+	infos[1].Size = 1024
+	components["id2"].targetSpace = infos[1].Size - 1
+
+	failedStatus := currentStatus
+	failedStatus.State = umclient.StateFailed
+	failedStatus.Error = "component id2: image size"
+	failedStatus.Components = append(failedStatus.Components,
+		umclient.ComponentStatusInfo{
+			ID: "id1", AosVersion: infos[0].AosVersion, VendorVersion: infos[0].VendorVersion,
+			Status: umclient.StatusInstalling,
+		},
+		umclient.ComponentStatusInfo{
+			ID: "id2", AosVersion: infos[1].AosVersion, VendorVersion: infos[1].VendorVersion,
+			Status: umclient.StatusError, Error: "image size",
+		})
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &failedStatus,
+		map[string][]string{"id1": nil, "id2": nil}, nil)
+
+	handler.RevertUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	// Prepare fails before download if images don't fit into download dir together
+
+	components["id2"].targetSpace = 0
+
+	for i := range infos {
+		infos[i].URL = "http://localhost:9000/" + filepath.Base(infos[i].URL)
+		infos[i].Size = stat.Bavail*uint64(stat.Bsize)/2 + 1
+	}
+
+	failedStatus.Error = "not enough free space in"
+	failedStatus.Components = append(currentStatus.Components,
+		umclient.ComponentStatusInfo{
+			ID: "id1", AosVersion: infos[0].AosVersion, VendorVersion: infos[0].VendorVersion,
+			Status: umclient.StatusError, Error: "can't download image of",
+		},
+		umclient.ComponentStatusInfo{
+			ID: "id2", AosVersion: infos[1].AosVersion, VendorVersion: infos[1].VendorVersion,
+			Status: umclient.StatusError, Error: "can't download image of",
+		})
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &failedStatus,
+		map[string][]string{"id1": nil, "id2": nil}, nil)
+}
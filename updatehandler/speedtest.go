@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Before prepare which downloads at least configured min download size, link throughput is probed by ranged request
+// to the largest remote image of the update. Download size is the sum of remote image sizes: images reused from the
+// download session or prefetch are not excluded, so the estimate is an upper bound. If estimated download time
+// exceeds configured max download time, the estimate is reported in status error: prepare continues with warning or
+// is deferred and the link is probed again each poll interval till the estimate fits or the update is canceled.
+// Failed probe doesn't block prepare, it is logged and kept in link estimate.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	speedTestActionWarn  = "warn"
+	speedTestActionDefer = "defer"
+)
+
+const (
+	defaultSpeedTestProbeSize    = 1 << 20
+	defaultSpeedTestPollInterval = 10 * time.Minute
+	speedTestTimeout             = 30 * time.Second
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// LinkEstimate link speed test result of the last prepare. Throughput is in bytes per second.
+type LinkEstimate struct {
+	URL          string        `json:"url"`
+	Time         time.Time     `json:"time"`
+	ProbeSize    uint64        `json:"probeSize"`
+	Throughput   uint64        `json:"throughput"`
+	DownloadSize uint64        `json:"downloadSize"`
+	DownloadTime time.Duration `json:"downloadTime"`
+	Error        string        `json:"error,omitempty"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetLinkEstimate returns link speed test result of the last prepare or nil if the link was not probed.
+func (handler *Handler) GetLinkEstimate() (estimate *LinkEstimate) {
+	handler.linkMutex.Lock()
+	defer handler.linkMutex.Unlock()
+
+	if handler.state.LinkEstimate == nil {
+		return nil
+	}
+
+	estimateCopy := *handler.state.LinkEstimate
+
+	return &estimateCopy
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newSpeedTest(cfg config.SpeedTest) (speedTest config.SpeedTest) {
+	speedTest = cfg
+
+	if speedTest.ProbeSize == 0 {
+		speedTest.ProbeSize = defaultSpeedTestProbeSize
+	}
+
+	if speedTest.PollInterval.Duration == 0 {
+		speedTest.PollInterval.Duration = defaultSpeedTestPollInterval
+	}
+
+	return speedTest
+}
+
+func checkSpeedTest(cfg config.SpeedTest) (err error) {
+	if cfg.MaxDownloadTime.Duration < 0 || cfg.PollInterval.Duration < 0 {
+		return aoserrors.New("wrong speed test max download time or poll interval")
+	}
+
+	switch cfg.Action {
+	case "", speedTestActionWarn:
+
+	case speedTestActionDefer:
+		if cfg.MaxDownloadTime.Duration == 0 {
+			return aoserrors.New("max download time should be configured for speed test defer action")
+		}
+
+	default:
+		return aoserrors.Errorf("unknown speed test action %s", cfg.Action)
+	}
+
+	return nil
+}
+
+// checkLinkSpeed probes the link before images are downloaded. It is called under handler lock.
+func (handler *Handler) checkLinkSpeed(componentsInfo map[string]*umclient.ComponentUpdateInfo) (err error) {
+	if handler.speedTest.MinDownloadSize == 0 {
+		return nil
+	}
+
+	probeInfo, downloadSize := getRemoteDownloads(componentsInfo)
+	if probeInfo == nil || downloadSize < handler.speedTest.MinDownloadSize {
+		return nil
+	}
+
+	maxDownloadTime := handler.speedTest.MaxDownloadTime.Duration
+	deferred := false
+
+	for {
+		estimate := handler.probeLink(probeInfo, downloadSize)
+
+		handler.setLinkEstimate(estimate)
+
+		if estimate.Error != "" || maxDownloadTime == 0 || estimate.DownloadTime <= maxDownloadTime {
+			if deferred {
+				log.Info("Estimated download time fits, continue prepare")
+
+				handler.state.Error = ""
+			}
+
+			return nil
+		}
+
+		reason := fmt.Sprintf("estimated download time %s exceeds %s",
+			estimate.DownloadTime.Round(time.Second), maxDownloadTime)
+
+		if handler.speedTest.Action != speedTestActionDefer {
+			log.WithField("throughput", estimate.Throughput).Warn("Link is too slow for update download")
+
+			handler.state.Error = reason
+			handler.sendStatus()
+
+			return nil
+		}
+
+		if deferMsg := "prepare deferred: " + reason; handler.state.Error != deferMsg {
+			log.WithField("throughput", estimate.Throughput).Warn("Link is too slow, prepare deferred")
+
+			handler.state.Error = deferMsg
+			handler.sendStatus()
+		}
+
+		deferred = true
+
+		select {
+		case <-handler.clock.After(handler.speedTest.PollInterval.Duration):
+
+		case <-handler.operationContext().Done():
+			log.Warn("Link speed waiting is stopped")
+
+			return handler.checkStopped()
+		}
+	}
+}
+
+// getRemoteDownloads returns the largest remote image to probe and total size of remote images.
+func getRemoteDownloads(
+	componentsInfo map[string]*umclient.ComponentUpdateInfo,
+) (probeInfo *umclient.ComponentUpdateInfo, downloadSize uint64) {
+	ids := make([]string, 0, len(componentsInfo))
+
+	for id := range componentsInfo {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		info := componentsInfo[id]
+
+		if urlVal, err := url.Parse(info.URL); err != nil || urlVal.Scheme == "file" {
+			continue
+		}
+
+		downloadSize += info.Size
+
+		if probeInfo == nil || info.Size > probeInfo.Size {
+			probeInfo = info
+		}
+	}
+
+	return probeInfo, downloadSize
+}
+
+func (handler *Handler) probeLink(
+	probeInfo *umclient.ComponentUpdateInfo, downloadSize uint64,
+) (estimate LinkEstimate) {
+	estimate = LinkEstimate{URL: probeInfo.URL, Time: handler.clock.Now(), DownloadSize: downloadSize}
+
+	received, elapsed, err := handler.probeThroughput(probeInfo)
+	if err != nil {
+		log.WithField("url", probeInfo.URL).Warnf("Can't probe link speed: %v", err)
+
+		estimate.Error = err.Error()
+
+		return estimate
+	}
+
+	estimate.ProbeSize = received
+	estimate.Throughput = uint64(float64(received) / elapsed.Seconds())
+
+	if estimate.Throughput == 0 {
+		estimate.Throughput = 1
+	}
+
+	estimate.DownloadTime = time.Duration(float64(downloadSize) / float64(estimate.Throughput) * float64(time.Second))
+
+	log.WithFields(log.Fields{
+		"url": probeInfo.URL, "throughput": estimate.Throughput, "downloadSize": downloadSize,
+		"downloadTime": estimate.DownloadTime,
+	}).Info("Link speed estimated")
+
+	return estimate
+}
+
+func (handler *Handler) probeThroughput(
+	probeInfo *umclient.ComponentUpdateInfo,
+) (received uint64, elapsed time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(handler.operationContext(), speedTestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeInfo.URL, nil)
+	if err != nil {
+		return 0, 0, aoserrors.Wrap(err)
+	}
+
+	for name, value := range getUpdateAnnotations(probeInfo.Annotations).DownloadHeaders {
+		if name = http.CanonicalHeaderKey(name); strings.HasPrefix(name, customHeaderPrefix) {
+			req.Header.Set(name, value)
+		}
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", handler.speedTest.ProbeSize-1))
+
+	client := &http.Client{}
+
+	if tlsConfig := handler.getHostTLSConfig(req.URL); tlsConfig != nil {
+		client.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}
+	}
+
+	startTime := time.Now()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, aoserrors.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return 0, 0, aoserrors.Errorf("speed test status: %s", resp.Status)
+	}
+
+	// Server may ignore range request, read no more than probe size anyway
+	written, err := io.CopyN(io.Discard, resp.Body, int64(handler.speedTest.ProbeSize))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, 0, aoserrors.Wrap(err)
+	}
+
+	if written == 0 {
+		return 0, 0, aoserrors.New("speed test received no data")
+	}
+
+	return uint64(written), time.Since(startTime), nil
+}
+
+func (handler *Handler) setLinkEstimate(estimate LinkEstimate) {
+	handler.linkMutex.Lock()
+	defer handler.linkMutex.Unlock()
+
+	handler.state.LinkEstimate = &estimate
+}
+
+func (handler *Handler) resetLinkEstimate() {
+	handler.linkMutex.Lock()
+	defer handler.linkMutex.Unlock()
+
+	handler.state.LinkEstimate = nil
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aostypes"
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestSpeedTest(t *testing.T) {
+	content := bytes.Repeat([]byte("speedtest"), 1<<17)
+	imagePath := path.Join(tmpDir, "speedtestimage.bin")
+
+	if err := writeImage(imagePath, content, false); err != nil {
+		t.Fatalf("Can't write image: %s", err)
+	}
+
+	imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("Can't create file info: %s", err)
+	}
+
+	var slow int32 = 1
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&slow) == 0 {
+			http.ServeFile(w, r, imagePath)
+
+			return
+		}
+
+		// About 200 KB/s
+		for i := 0; i < len(content); i += 4096 {
+			if _, err := w.Write(content[i : i+4096]); err != nil {
+				return
+			}
+
+			w.(http.Flusher).Flush() //nolint:forcetypeassert
+
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: path.Join(tmpDir, "downloadDir"),
+		SpeedTest: config.SpeedTest{
+			MinDownloadSize: 1,
+			ProbeSize:       32768,
+			MaxDownloadTime: aostypes.Duration{Duration: time.Second},
+			Action:          "defer",
+			PollInterval:    aostypes.Duration{Duration: time.Minute},
+		},
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	})
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	// Prepare is deferred while estimated download time exceeds max download time
+
+	handler.PrepareUpdate([]umclient.ComponentUpdateInfo{{
+		ID: "id1", AosVersion: 1, URL: server.URL + "/speedtestimage.bin",
+		Sha256: imageInfo.Sha256, Sha512: imageInfo.Sha512, Size: imageInfo.Size,
+	}})
+
+	select {
+	case status := <-handler.StatusChannel():
+		if !strings.HasPrefix(status.Error, "prepare deferred: estimated download time") {
+			t.Errorf("Wrong error: %s", status.Error)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait status timeout")
+	}
+
+	estimate := handler.GetLinkEstimate()
+	if estimate == nil {
+		t.Fatal("Link estimate expected")
+	}
+
+	if estimate.DownloadSize != imageInfo.Size || estimate.DownloadTime <= time.Second {
+		t.Errorf("Wrong link estimate: %+v", *estimate)
+	}
+
+	// Link is probed again after poll interval
+
+	atomic.StoreInt32(&slow, 0)
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Minute)
+
+	select {
+	case status := <-handler.StatusChannel():
+		if status.State != umclient.StatePrepared || status.Error != "" {
+			t.Errorf("Wrong state: %s, error: %s", status.State, status.Error)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait status timeout")
+	}
+
+	if estimate = handler.GetLinkEstimate(); estimate == nil || estimate.DownloadTime > time.Second {
+		t.Errorf("Wrong link estimate: %v", estimate)
+	}
+
+	if findings := updatehandler.ValidateConfig(&config.Config{
+		SpeedTest: config.SpeedTest{Action: "defer"},
+	}); len(findings) != 1 {
+		t.Errorf("Wrong findings: %v", findings)
+	}
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// StateExporter optional interface which can be implemented by update module to export module specific
+// update decision (target slot, layer names etc.) to the boot-time state file.
+type StateExporter interface {
+	// GetExportState returns module specific key-value pairs
+	GetExportState() (state map[string]string)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// The state file is a shell compatible key-value file which can be sourced by initramfs scripts:
+//
+// UPDATE_STATE='updated'
+// UPDATE_COMPONENTS='rootfs'
+// ROOTFS_AOS_VERSION='2'
+// ROOTFS_SHA256='...'
+// ROOTFS_TARGET_PARTITION='1'
+
+func (handler *Handler) exportState() (err error) {
+	if handler.stateExportFile == "" {
+		return nil
+	}
+
+	return aoserrors.Wrap(writeFileAtomic(handler.stateExportFile, handler.createExportState()))
+}
+
+func (handler *Handler) verifyExportedState() {
+	if handler.stateExportFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(handler.stateExportFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Errorf("Can't read exported state: %v", err)
+	}
+
+	if bytes.Equal(data, handler.createExportState()) {
+		return
+	}
+
+	log.WithField("file", handler.stateExportFile).Warn("Exported state doesn't match update state, rewrite it")
+
+	if err = handler.exportState(); err != nil {
+		log.Errorf("Can't export state: %v", err)
+	}
+}
+
+func (handler *Handler) createExportState() (data []byte) {
+	state := map[string]string{"UPDATE_STATE": handler.state.UpdateState}
+
+	ids := make([]string, 0, len(handler.state.ComponentStatuses))
+
+	for id := range handler.state.ComponentStatuses {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	state["UPDATE_COMPONENTS"] = strings.Join(ids, " ")
+
+	for _, id := range ids {
+		componentStatus := handler.state.ComponentStatuses[id]
+		prefix := exportKey(id) + "_"
+
+		state[prefix+"AOS_VERSION"] = strconv.FormatUint(componentStatus.AosVersion, 10)
+		state[prefix+"VENDOR_VERSION"] = componentStatus.VendorVersion
+		state[prefix+"STATUS"] = componentStatus.Status.String()
+
+		if hash, ok := handler.state.ImageHashes[id]; ok {
+			state[prefix+"SHA256"] = hash
+		}
+
+		component, ok := handler.components[id]
+		if !ok {
+			continue
+		}
+
+		if exporter, ok := baseModule(component.module).(StateExporter); ok {
+			for key, value := range exporter.GetExportState() {
+				state[prefix+exportKey(key)] = value
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(state))
+
+	for key := range state {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var buffer bytes.Buffer
+
+	for _, key := range keys {
+		fmt.Fprintf(&buffer, "%s='%s'\n", key, strings.ReplaceAll(state[key], "'", `'\''`))
+	}
+
+	return buffer.Bytes()
+}
+
+func exportKey(name string) (key string) {
+	return strings.Map(func(char rune) rune {
+		switch {
+		case char >= 'a' && char <= 'z':
+			return char - 'a' + 'A'
+
+		case (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9'):
+			return char
+
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+func writeFileAtomic(fileName string, data []byte) (err error) {
+	if err = os.MkdirAll(filepath.Dir(fileName), 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*")
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(tmpFile.Name())
+		}
+	}()
+
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return aoserrors.Wrap(err)
+	}
+
+	if err = tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return aoserrors.Wrap(err)
+	}
+
+	if err = tmpFile.Close(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = os.Chmod(tmpFile.Name(), 0o644); err != nil { //nolint:gosec // state file is read by initramfs
+		return aoserrors.Wrap(err)
+	}
+
+	if err = os.Rename(tmpFile.Name(), fileName); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	dir, err := os.Open(filepath.Dir(fileName))
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer dir.Close()
+
+	return aoserrors.Wrap(dir.Sync())
+}
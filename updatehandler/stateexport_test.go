@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestStateExport(t *testing.T) {
+	cfg := &config.Config{
+		DownloadDir:     path.Join(tmpDir, "downloadDir"),
+		StateExportFile: path.Join(tmpDir, "export", "updatestate"),
+		UpdateModules:   []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}
+
+	storage := newTestStorage()
+
+	handler := newTestHandler(t, cfg, withStorage(storage))
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+	expectedState := fmt.Sprintf("ID1_AOS_VERSION='%d'\nID1_SHA256='%s'\nID1_STATUS='installing'\n"+
+		"ID1_VENDOR_VERSION=''\nUPDATE_COMPONENTS='id1'\nUPDATE_STATE='prepared'\n",
+		infos[0].AosVersion, hex.EncodeToString(infos[0].Sha256))
+
+	data, err := os.ReadFile(cfg.StateExportFile)
+	if err != nil {
+		t.Fatalf("Can't read exported state: %s", err)
+	}
+
+	if string(data) != expectedState {
+		t.Errorf("Wrong exported state: %s", string(data))
+	}
+
+	handler.Close(context.Background())
+
+	if err = os.WriteFile(cfg.StateExportFile, []byte("UPDATE_STATE='idle'\n"), 0o600); err != nil {
+		t.Fatalf("Can't write exported state: %s", err)
+	}
+
+	handler = newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	if data, err = os.ReadFile(cfg.StateExportFile); err != nil {
+		t.Fatalf("Can't read exported state: %s", err)
+	}
+
+	if string(data) != expectedState {
+		t.Errorf("Exported state is not restored: %s", string(data))
+	}
+
+	testOperation(t, handler, handler.RevertUpdate, &currentStatus, nil, nil)
+
+	if data, err = os.ReadFile(cfg.StateExportFile); err != nil {
+		t.Fatalf("Can't read exported state: %s", err)
+	}
+
+	if string(data) != "UPDATE_COMPONENTS=''\nUPDATE_STATE='idle'\n" {
+		t.Errorf("Wrong exported state: %s", string(data))
+	}
+}
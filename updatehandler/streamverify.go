@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"context"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/image"
+	"github.com/aoscloud/aos_common/utils/contextreader"
+	"github.com/cavaliergopher/grab/v3"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Downloaded image is verified while it is being downloaded: response body is hashed as it is written to the file,
+// so multi-GB images are not re-read after download. Partial file of resumed download is hashed before the transfer
+// is continued. Image which is not transferred (not modified cached image or already complete file) and local file
+// image are checked after download the usual way.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type streamVerifier struct {
+	ctx       context.Context //nolint:containedctx // Used by grab hooks
+	fileInfo  image.FileInfo
+	checkHash bool
+	checkSize bool
+	hash256   hash.Hash
+	hash512   hash.Hash
+	size      uint64
+	verified  bool
+	checkErr  error
+}
+
+type verifyingClient struct {
+	client   grab.HTTPClient
+	verifier *streamVerifier
+}
+
+type verifyingBody struct {
+	io.ReadCloser
+	verifier *streamVerifier
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newStreamVerifier(
+	ctx context.Context, fileInfo image.FileInfo, checkHash, checkSize bool,
+) (verifier *streamVerifier) {
+	return &streamVerifier{ctx: ctx, fileInfo: fileInfo, checkHash: checkHash, checkSize: checkSize}
+}
+
+// newImageVerifier returns stream verifier of component image or nil if the image can't be verified in stream by
+// the component verification policy.
+func (handler *Handler) newImageVerifier(
+	ctx context.Context, updateInfo *umclient.ComponentUpdateInfo,
+) (verifier *streamVerifier) {
+	component, ok := handler.components[updateInfo.ID]
+	if !ok {
+		return nil
+	}
+
+	policy := component.imagePolicy
+
+	// Missing digests are reported by post-download check
+	if (!policy.hash && !policy.size) || (policy.hash && (len(updateInfo.Sha256) == 0 || len(updateInfo.Sha512) == 0)) {
+		return nil
+	}
+
+	return newStreamVerifier(ctx, image.FileInfo{
+		Sha256: updateInfo.Sha256, Sha512: updateInfo.Sha512, Size: updateInfo.Size,
+	}, policy.hash, policy.size)
+}
+
+// attach sets grab request hooks and wraps client to verify downloaded data.
+func (verifier *streamVerifier) attach(req *grab.Request, client *grab.Client) {
+	if verifier == nil {
+		return
+	}
+
+	req.BeforeCopy = verifier.beforeCopy
+	req.AfterCopy = verifier.afterCopy
+	client.HTTPClient = &verifyingClient{client: client.HTTPClient, verifier: verifier}
+}
+
+// isFailed returns true if downloaded image failed verification.
+func (verifier *streamVerifier) isFailed() (failed bool) {
+	return verifier != nil && verifier.checkErr != nil
+}
+
+// isVerified returns true if image is verified in stream.
+func (verifier *streamVerifier) isVerified() (verified bool) {
+	return verifier != nil && verifier.verified
+}
+
+func (verifier *streamVerifier) Write(data []byte) (n int, err error) {
+	verifier.size += uint64(len(data))
+
+	if verifier.checkHash {
+		verifier.hash256.Write(data)
+		verifier.hash512.Write(data)
+	}
+
+	return len(data), nil
+}
+
+// beforeCopy resets the verifier and hashes partial file if download is resumed.
+func (verifier *streamVerifier) beforeCopy(resp *grab.Response) (err error) {
+	verifier.hash256, verifier.hash512 = sha3.New256(), sha3.New512()
+	verifier.size = 0
+	verifier.verified = false
+	verifier.checkErr = nil
+
+	resumed := resp.BytesComplete()
+	if !resp.DidResume || resumed == 0 {
+		return nil
+	}
+
+	file, err := os.Open(resp.Filename)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if _, err = io.CopyN(verifier, contextreader.New(verifier.ctx, file), resumed); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// afterCopy checks downloaded data, failed check fails the download.
+func (verifier *streamVerifier) afterCopy(resp *grab.Response) (err error) {
+	if verifier.checkErr = verifier.check(); verifier.checkErr != nil {
+		return verifier.checkErr
+	}
+
+	verifier.verified = true
+
+	return nil
+}
+
+func (verifier *streamVerifier) check() (err error) {
+	if verifier.checkSize && verifier.size != verifier.fileInfo.Size {
+		return aoserrors.New("file size mismatch")
+	}
+
+	if !verifier.checkHash {
+		return nil
+	}
+
+	if !bytes.Equal(verifier.hash256.Sum(nil), verifier.fileInfo.Sha256) {
+		return aoserrors.New("checksum sha256 mismatch")
+	}
+
+	if !bytes.Equal(verifier.hash512.Sum(nil), verifier.fileInfo.Sha512) {
+		return aoserrors.New("checksum sha512 mismatch")
+	}
+
+	return nil
+}
+
+func (client *verifyingClient) Do(req *http.Request) (resp *http.Response, err error) {
+	if resp, err = client.client.Do(req); err != nil {
+		return resp, aoserrors.Wrap(err)
+	}
+
+	if resp.Body != nil {
+		resp.Body = &verifyingBody{ReadCloser: resp.Body, verifier: client.verifier}
+	}
+
+	return resp, nil
+}
+
+func (body *verifyingBody) Read(data []byte) (n int, err error) {
+	n, err = body.ReadCloser.Read(data)
+
+	// Data read before copy is discarded when the verifier is reset
+	if n > 0 && body.verifier.hash256 != nil {
+		_, _ = body.verifier.Write(data[:n])
+	}
+
+	return n, err //nolint:wrapcheck // io.EOF should be returned as is
+}
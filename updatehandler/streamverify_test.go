@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/image"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestStreamVerification(t *testing.T) {
+	content := bytes.Repeat([]byte("stream"), 65536)
+	imagePath := path.Join(tmpDir, "streamimage.bin")
+	downloadDir := path.Join(tmpDir, "streamDownload")
+
+	if err := writeImage(imagePath, content, false); err != nil {
+		t.Fatalf("Can't write image: %s", err)
+	}
+
+	imageInfo, err := image.CreateFileInfo(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("Can't create file info: %s", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "streamimage.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   downloadDir,
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	})
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos := []umclient.ComponentUpdateInfo{{
+		ID: "id1", AosVersion: 1, URL: server.URL + "/streamimage.bin",
+		Sha256: imageInfo.Sha256, Sha512: make([]byte, len(imageInfo.Sha512)), Size: imageInfo.Size,
+	}}
+
+	// Image which doesn't match digest fails while downloading and is not kept for resume
+
+	handler.PrepareUpdate(infos)
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait for status timeout")
+
+	case status := <-handler.StatusChannel():
+		if status.State != umclient.StateFailed || !strings.Contains(status.Error, "checksum sha512 mismatch") {
+			t.Errorf("Wrong status: %s, error: %s", status.State, status.Error)
+		}
+	}
+
+	if files, _ := filepath.Glob(path.Join(downloadDir, "session-*", "*-streamimage.bin")); len(files) != 0 {
+		t.Errorf("Invalid image is kept: %v", files)
+	}
+
+	handler.RevertUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	infos[0].Sha512 = imageInfo.Sha512
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+}
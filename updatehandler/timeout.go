@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Component prepare, update and apply are limited by optional per component update timeout. The operation gets
+// context with the timeout: context aware modules and image download are interrupted once it expires. On timeout
+// the handler cancels the operation and waits till it is finished, so revert doesn't race with the module. If the
+// module doesn't stop within stop timeout, the operation is considered stuck: the component is failed and any next
+// operation of the component, including revert, is refused till the stuck operation is finished. Revert and reboot
+// are not limited as they restore the system.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const operationStopTimeout = 30 * time.Second
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type operationResult struct {
+	rebootRequired bool
+	err            error
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func isTimedPhase(phase string) (timed bool) {
+	return phase == eventPrepare || phase == eventUpdate || phase == eventApply
+}
+
+func (handler *Handler) runOperation(id, phase string, timeout time.Duration,
+	operation func(ctx context.Context) (rebootRequired bool, err error),
+) (rebootRequired bool, err error) {
+	if err = handler.checkStuckOperation(id); err != nil {
+		return false, err
+	}
+
+	if err = handler.checkFence(id); err != nil {
+		return false, err
+	}
+
+	if timeout == 0 || !isTimedPhase(phase) {
+		return operation(handler.operationContext())
+	}
+
+	ctx, cancel := context.WithTimeout(handler.operationContext(), timeout)
+	defer cancel()
+
+	resultChannel := make(chan operationResult, 1)
+
+	go func() {
+		rebootRequired, err := operation(ctx)
+		resultChannel <- operationResult{rebootRequired: rebootRequired, err: err}
+	}()
+
+	select {
+	case result := <-resultChannel:
+		return result.rebootRequired, result.err
+
+	case <-ctx.Done():
+	}
+
+	// Update cancel and emergency stop are handled by the operation itself
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result := <-resultChannel
+
+		return result.rebootRequired, result.err
+	}
+
+	log.WithFields(log.Fields{"id": id, "phase": phase, "timeout": timeout}).Error("Component operation timeout")
+
+	select {
+	case <-resultChannel:
+
+	case <-handler.clock.After(handler.stopTimeout):
+		log.WithFields(log.Fields{"id": id, "phase": phase}).Error("Component operation doesn't stop")
+
+		handler.setStuckOperation(id, phase)
+
+		go func() {
+			<-resultChannel
+
+			log.WithFields(log.Fields{"id": id, "phase": phase}).Warn("Stuck component operation finished")
+
+			handler.clearStuckOperation(id)
+		}()
+
+		return false, aoserrors.Errorf("%s timeout %v exceeded, operation doesn't stop", phase, timeout)
+	}
+
+	return false, aoserrors.Errorf("%s timeout %v exceeded", phase, timeout)
+}
+
+// refuseStuckRevert fails components with stuck operation and refuses revert request. Reverting the component
+// while its operation is still running would race with the module.
+func (handler *Handler) refuseStuckRevert() (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		stuckErr := handler.checkStuckOperation(id)
+		if stuckErr == nil {
+			continue
+		}
+
+		componentError(componentStatus, stuckErr)
+
+		if err == nil {
+			err = stuckErr
+		}
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	handler.state.Error = err.Error()
+
+	if saveErr := handler.saveState(); saveErr != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(saveErr))
+	}
+
+	handler.sendStatus()
+
+	return err
+}
+
+func (handler *Handler) checkStuckOperation(id string) (err error) {
+	handler.stuckMutex.Lock()
+	defer handler.stuckMutex.Unlock()
+
+	if phase, ok := handler.stuckOperations[id]; ok {
+		return aoserrors.Errorf("%s operation of component %s is still running", phase, id)
+	}
+
+	return nil
+}
+
+func (handler *Handler) setStuckOperation(id, phase string) {
+	handler.stuckMutex.Lock()
+	defer handler.stuckMutex.Unlock()
+
+	handler.stuckOperations[id] = phase
+}
+
+func (handler *Handler) clearStuckOperation(id string) {
+	handler.stuckMutex.Lock()
+	defer handler.stuckMutex.Unlock()
+
+	delete(handler.stuckOperations, id)
+}
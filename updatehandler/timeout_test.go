@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/aostypes"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestUpdateTimeout(t *testing.T) {
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", UpdateTimeout: aostypes.Duration{Duration: 200 * time.Millisecond}},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	})
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	// Hanging update of id1 is failed by timeout
+
+	components["id1"].updateDelay = time.Minute
+
+	order = nil
+
+	handler.StartUpdate()
+
+	select {
+	case <-time.After(5 * time.Second):
+		t.Error("Wait for status timeout")
+
+	case status := <-handler.StatusChannel():
+		if status.State != umclient.StateFailed || !strings.Contains(status.Error, "update timeout") {
+			t.Errorf("Wrong status: %s, error: %s", status.State, status.Error)
+		}
+	}
+
+	if err = checkComponentOps(map[string][]string{"id2": {opUpdate}}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+}
+
+func TestStuckOperation(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1", updateBlock: make(chan struct{})}, "id2": {id: "id2"}}
+
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", UpdateTimeout: aostypes.Duration{Duration: 200 * time.Millisecond}},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	}, withModules(components))
+
+	handler.SetOperationStopTimeout(100 * time.Millisecond)
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	// Update of id1 ignores context and doesn't stop after timeout
+
+	handler.StartUpdate()
+
+	if err = waitForFailedComponent(handler, "id1", "operation doesn't stop"); err != nil {
+		t.Errorf("Wrong status: %s", err)
+	}
+
+	// Revert is refused while update of id1 is running, handler stays in failed state
+
+	resetOrder()
+
+	handler.RevertUpdate()
+
+	if err = waitForFailedComponent(handler, "id1", "update operation of component id1 is still running"); err != nil {
+		t.Errorf("Wrong status: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{"id1": nil, "id2": nil}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	// Revert is performed once stuck update is finished
+
+	close(components["id1"].updateBlock)
+
+	time.Sleep(100 * time.Millisecond)
+
+	resetOrder()
+
+	handler.RevertUpdate()
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Errorf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{"id1": {opRevert}, "id2": {opRevert}}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func waitForFailedComponent(handler *updatehandler.Handler, id, errMsg string) (err error) {
+	select {
+	case <-time.After(5 * time.Second):
+		return aoserrors.New("wait operation timeout")
+
+	case status := <-handler.StatusChannel():
+		if status.State != umclient.StateFailed || !strings.Contains(status.Error, errMsg) {
+			return aoserrors.Errorf("wrong current state: %s, error: %s", status.State, status.Error)
+		}
+
+		for _, componentStatus := range status.Components {
+			if componentStatus.ID == id && componentStatus.Status == umclient.StatusError &&
+				strings.Contains(componentStatus.Error, errMsg) {
+				return nil
+			}
+		}
+
+		return aoserrors.Errorf("component %s is not failed", id)
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+// Update diff is rendered from cached component versions and config only: images are not downloaded and modules are
+// not requested to prepare, so it can be shown by operator UI before the campaign is confirmed. Component which
+// already has target version is reported as unchanged the same way as prepare skips it. Module may predict whether
+// update of the component requires reboot, otherwise reboot of configured type is expected as the module may request
+// it after update.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// ComponentDiff component change which would be done by proposed update.
+type ComponentDiff struct {
+	ID                   string `json:"id"`
+	CurrentVendorVersion string `json:"currentVendorVersion,omitempty"`
+	CurrentAosVersion    uint64 `json:"currentAosVersion"`
+	VendorVersion        string `json:"vendorVersion,omitempty"`
+	AosVersion           uint64 `json:"aosVersion"`
+	ImageSize            uint64 `json:"imageSize"`
+	Unchanged            bool   `json:"unchanged,omitempty"`
+	Downgrade            bool   `json:"downgrade,omitempty"`
+	RebootRequired       bool   `json:"rebootRequired,omitempty"`
+	RebootType           string `json:"rebootType,omitempty"`
+	RebootGroup          string `json:"rebootGroup,omitempty"`
+	Error                string `json:"error,omitempty"`
+}
+
+// RebootPredictor optional interface which can be implemented by update module to tell whether update to the
+// version requires reboot. It shouldn't access the hardware as it is used to render update diff.
+type RebootPredictor interface {
+	// IsRebootRequired returns true if update to the version requires reboot
+	IsRebootRequired(vendorVersion string, annotations json.RawMessage) (rebootRequired bool, err error)
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetUpdateDiff returns per component diff of proposed update sorted by component ID.
+func (handler *Handler) GetUpdateDiff(components []umclient.ComponentUpdateInfo) (diff []ComponentDiff) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	diff = make([]ComponentDiff, 0, len(components))
+
+	for i := range components {
+		diff = append(diff, handler.newComponentDiff(&components[i]))
+	}
+
+	sort.Slice(diff, func(i, j int) bool { return diff[i].ID < diff[j].ID })
+
+	return diff
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) newComponentDiff(updateInfo *umclient.ComponentUpdateInfo) (diff ComponentDiff) {
+	diff = ComponentDiff{
+		ID: updateInfo.ID, VendorVersion: updateInfo.VendorVersion, AosVersion: updateInfo.AosVersion,
+		ImageSize: updateInfo.Size,
+	}
+
+	component, ok := handler.components[updateInfo.ID]
+	if !ok {
+		diff.Error = aoserrors.Errorf("component %s not found", updateInfo.ID).Error()
+
+		return diff
+	}
+
+	if installedStatus, ok := handler.componentStatuses[updateInfo.ID]; ok {
+		diff.CurrentVendorVersion = installedStatus.VendorVersion
+		diff.CurrentAosVersion = installedStatus.AosVersion
+	}
+
+	downgrade, err := handler.checkDowngrade(updateInfo, diff.CurrentAosVersion, diff.CurrentVendorVersion)
+	if err != nil {
+		diff.Error = err.Error()
+
+		return diff
+	}
+
+	if !updateInfo.Reinstall && !getUpdateAnnotations(updateInfo.Annotations).Reinstall &&
+		handler.isUnchanged(updateInfo, &diff) {
+		diff.Unchanged = true
+
+		return diff
+	}
+
+	diff.Downgrade = downgrade
+	diff.RebootGroup = component.rebootGroup
+
+	if diff.RebootRequired = isRebootRequired(component.module, updateInfo); diff.RebootRequired {
+		diff.RebootType = component.rebootType
+
+		if diff.RebootType == "" {
+			diff.RebootType = RebootFull
+		}
+	}
+
+	return diff
+}
+
+func (handler *Handler) isUnchanged(updateInfo *umclient.ComponentUpdateInfo, diff *ComponentDiff) (unchanged bool) {
+	if updateInfo.VendorVersion != "" && diff.CurrentVendorVersion != "" &&
+		handler.versionsEqual(updateInfo.ID, diff.CurrentVendorVersion, updateInfo.VendorVersion) {
+		return true
+	}
+
+	return updateInfo.AosVersion != 0 && diff.CurrentAosVersion == updateInfo.AosVersion
+}
+
+// isRebootRequired returns true if module doesn't predict that update doesn't require reboot.
+func isRebootRequired(module UpdateModule, updateInfo *umclient.ComponentUpdateInfo) (rebootRequired bool) {
+	predictor, ok := baseModule(module).(RebootPredictor)
+	if !ok {
+		return true
+	}
+
+	rebootRequired, err := predictor.IsRebootRequired(updateInfo.VendorVersion, updateInfo.Annotations)
+	if err != nil {
+		log.WithField("id", updateInfo.ID).Errorf("Can't predict reboot: %v", err)
+
+		return true
+	}
+
+	return rebootRequired
+}
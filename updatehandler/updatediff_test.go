@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestUpdateDiff(t *testing.T) {
+	components = map[string]*testModule{
+		"id1": {id: "id1", vendorVersion: "1.0", rebootRequired: true},
+		"id2": {id: "id2", vendorVersion: "1.0", rebootRequired: true},
+		"id3": {id: "id3", vendorVersion: "1.0"},
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", RebootType: updatehandler.RebootWarm, RebootGroup: "group1"},
+			{ID: "id2", Plugin: "testmodule"},
+			{ID: "id3", Plugin: "testmodule"},
+		},
+	}, withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos := []umclient.ComponentUpdateInfo{
+		{ID: "id3", VendorVersion: "2.0", AosVersion: 1, Size: 30},
+		{ID: "id1", VendorVersion: "2.0", AosVersion: 1, Size: 10},
+		{ID: "id2", VendorVersion: "1.0", AosVersion: 1, Size: 20},
+		{ID: "id4", VendorVersion: "1.0", AosVersion: 1, Size: 40},
+	}
+
+	order = nil
+
+	diff := handler.GetUpdateDiff(infos)
+
+	if expected := []updatehandler.ComponentDiff{
+		{
+			ID: "id1", CurrentVendorVersion: "1.0", VendorVersion: "2.0", AosVersion: 1, ImageSize: 10,
+			RebootRequired: true, RebootType: updatehandler.RebootWarm, RebootGroup: "group1",
+		},
+		{ID: "id2", CurrentVendorVersion: "1.0", VendorVersion: "1.0", AosVersion: 1, ImageSize: 20, Unchanged: true},
+		{ID: "id3", CurrentVendorVersion: "1.0", VendorVersion: "2.0", AosVersion: 1, ImageSize: 30},
+		{ID: "id4", VendorVersion: "1.0", AosVersion: 1, ImageSize: 40, Error: diff[3].Error},
+	}; !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Wrong update diff: %+v", diff)
+	}
+
+	if !strings.Contains(diff[3].Error, "component id4 not found") {
+		t.Errorf("Wrong component error: %s", diff[3].Error)
+	}
+
+	// Reinstall is not reported as unchanged, reboot of not configured type is full one
+
+	infos[2].Reinstall = true
+
+	if diff = handler.GetUpdateDiff(infos[2:3]); !reflect.DeepEqual(diff, []updatehandler.ComponentDiff{{
+		ID: "id2", CurrentVendorVersion: "1.0", VendorVersion: "1.0", AosVersion: 1, ImageSize: 20,
+		RebootRequired: true, RebootType: updatehandler.RebootFull,
+	}}) {
+		t.Errorf("Wrong update diff: %+v", diff)
+	}
+
+	if len(order) != 0 {
+		t.Errorf("Unexpected module operations: %v", order)
+	}
+
+	if state := handler.GetCurrentStatus().State; state != umclient.StateIdle {
+		t.Errorf("Wrong handler state: %s", state)
+	}
+}
@@ -19,20 +19,28 @@ package updatehandler
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"net/url"
-	"os"
+	"path/filepath"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
-	"github.com/aoscloud/aos_common/image"
 	"github.com/looplab/fsm"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/aoscloud/aos_updatemanager/config"
 	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+	"github.com/aoscloud/aos_updatemanager/utils/diagnostics"
+	"github.com/aoscloud/aos_updatemanager/utils/instanceid"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+	"github.com/aoscloud/aos_updatemanager/utils/schedule"
+	"github.com/aoscloud/aos_updatemanager/utils/updatehistory"
+	"github.com/aoscloud/aos_updatemanager/utils/versionutils"
 )
 
 /*******************************************************************************
@@ -41,6 +49,12 @@ import (
 
 const statusChannelSize = 1
 
+const defaultVersionRefreshTimeout = 10 * time.Second
+
+const selectorMismatchMsg = "skipped: selector mismatch"
+
+const updateRevertedMsg = "update reverted"
+
 const (
 	eventPrepare = "prepare"
 	eventUpdate  = "update"
@@ -74,12 +88,92 @@ var plugins = make(map[string]NewPlugin) //nolint:gochecknoglobals
 type Handler struct {
 	sync.Mutex
 
-	storage           StateStorage
-	components        map[string]componentData
-	componentStatuses map[string]*umclient.ComponentStatusInfo
-	state             handlerState
-	fsm               *fsm.FSM
-	downloadDir       string
+	storage               StateStorage
+	moduleStorage         ModuleStorage
+	moduleStorageQuota    uint64
+	instanceID            string
+	history               *updatehistory.History
+	components            map[string]componentData
+	componentStatuses     map[string]*umclient.ComponentStatusInfo
+	state                 handlerState
+	fsm                   *fsm.FSM
+	downloadDir           string
+	cacheDir              string
+	failedImagesDir       string
+	maxFailedImages       int
+	failedImagesMutex     sync.Mutex
+	downloadHosts         map[string]*tls.Config
+	snapshotPaths         []string
+	snapshotFile          string
+	manifestsDir          string
+	stateExportFile       string
+	labels                map[string]string
+	clock                 clock.Clock
+	versionRefreshTimeout time.Duration
+	revertWindow          time.Duration
+	commitTimer           clock.Timer
+	errorRetention        time.Duration
+	errorTimer            clock.Timer
+	failureThreshold      int
+	failurePolicy         string
+	retryPolicy           retryPolicy
+	eventTimer            clock.Timer
+	blockers              []updateBlocker
+	blockersPollInterval  time.Duration
+	configChanged         map[string]bool
+	keyProviders          []namedKeyProvider
+	injectionToken        string
+	mirrorSelection       string
+	maxDownloads          int
+	allowedMaintenance    map[string]bool
+	deviceID              string
+	applySchedule         *schedule.Schedule
+	speedTest             config.SpeedTest
+	healthChecks          []healthCheck
+	canary                *canaryApply
+	bundleUploader        BundleUploader
+	operationFence        OperationFence
+	hooks                 []transitionHook
+	journalCursors        *journalCursors
+	capabilities          map[string]*umclient.ComponentCapabilities
+	healthWindow          time.Duration
+	healthPollInterval    time.Duration
+	healthScoreWindow     time.Duration
+	refreshInterval       time.Duration
+	refreshTimes          map[string]time.Time
+	stopTimeout           time.Duration
+	stuckOperations       map[string]string
+	progressInterval      time.Duration
+	prefetch              *prefetcher
+	bandwidth             *bandwidthLimiter
+	reboots               *rebootManager
+	sessionMutex          sync.Mutex
+	usageMutex            sync.Mutex
+	stopMutex             sync.Mutex
+	failureMutex          sync.Mutex
+	mirrorMutex           sync.Mutex
+	linkMutex             sync.Mutex
+	dryRunMutex           sync.Mutex
+	healthMutex           sync.Mutex
+	progressMutex         sync.Mutex
+	currentMutex          sync.Mutex
+	pendingMutex          sync.Mutex
+	bundleMutex           sync.Mutex
+	stuckMutex            sync.Mutex
+	fenceMutex            sync.Mutex
+	progressStatus        *umclient.Status
+	downloadProgress      map[string]umclient.DownloadProgress
+	currentStatus         CurrentStatus
+	pendingActions        map[string]*PendingAction
+	quarantined           bool
+	closed                bool
+	operations            sync.WaitGroup
+	stopCtx               context.Context //nolint:containedctx // Canceled by emergency stop
+	stopCancel            context.CancelFunc
+	opCtx                 context.Context //nolint:containedctx // Canceled by update cancel
+	opCancel              context.CancelFunc
+	canceled              bool
+	confirmChannel        chan struct{}
 
 	statusChannel chan umclient.Status
 }
@@ -92,18 +186,18 @@ type UpdateModule interface {
 	GetVendorVersion() (version string, err error)
 	// Init initializes module
 	Init() (err error)
-	// Prepare prepares module
-	Prepare(imagePath string, vendorVersion string, annotations json.RawMessage) (err error)
-	// Update updates module
-	Update() (rebootRequired bool, err error)
+	// Prepare prepares module, it is retried if it fails with TransientError
+	Prepare(ctx context.Context, imagePath string, vendorVersion string, annotations json.RawMessage) (err error)
+	// Update updates module, it is retried if it fails with TransientError
+	Update(ctx context.Context) (rebootRequired bool, err error)
 	// Apply applies update
-	Apply() (rebootRequired bool, err error)
+	Apply(ctx context.Context) (rebootRequired bool, err error)
 	// Revert reverts update
-	Revert() (rebootRequired bool, err error)
+	Revert(ctx context.Context) (rebootRequired bool, err error)
 	// Reboot performs module reboot
-	Reboot() (err error)
-	// Close closes update module
-	Close() (err error)
+	Reboot(ctx context.Context) (err error)
+	// Close closes update module, it should not block after context is done
+	Close(ctx context.Context) (err error)
 }
 
 // StateStorage provides API to store/retrieve persistent data.
@@ -124,23 +218,94 @@ type ModuleStorage interface {
 type NewPlugin func(id string, configJSON json.RawMessage, storage ModuleStorage) (module UpdateModule, err error)
 
 type handlerState struct {
-	UpdateState           string                                   `json:"updateState"`
-	Error                 string                                   `json:"error"`
-	ComponentStatuses     map[string]*umclient.ComponentStatusInfo `json:"componentStatuses"`
-	CurrentVendorVersions map[string]string                        `json:"currentVendorVersions"`
+	UpdateState           string                                       `json:"updateState"`
+	Error                 string                                       `json:"error"`
+	ComponentStatuses     map[string]*umclient.ComponentStatusInfo     `json:"componentStatuses"`
+	CurrentVendorVersions map[string]string                            `json:"currentVendorVersions"`
+	SnapshotHash          []byte                                       `json:"snapshotHash,omitempty"`
+	ImageHashes           map[string]string                            `json:"imageHashes,omitempty"`
+	ImageURLs             map[string]string                            `json:"imageUrls,omitempty"`
+	SkippedComponents     map[string]*umclient.ComponentStatusInfo     `json:"skippedComponents,omitempty"`
+	RevertDeadline        *time.Time                                   `json:"revertDeadline,omitempty"`
+	RevertComponents      []string                                     `json:"revertComponents,omitempty"`
+	ErrorDeadline         *time.Time                                   `json:"errorDeadline,omitempty"`
+	CommittedComponents   []string                                     `json:"committedComponents,omitempty"`
+	DownloadSession       string                                       `json:"downloadSession,omitempty"`
+	ResourceUsage         map[string]map[string]diagnostics.PhaseUsage `json:"resourceUsage,omitempty"`
+	Quarantined           bool                                         `json:"quarantined,omitempty"`
+	InjectedFailures      []InjectedFailure                            `json:"injectedFailures,omitempty"`
+	DownloadReports       map[string]DownloadReport                    `json:"downloadReports,omitempty"`
+	ComponentMetadata     map[string]map[string]string                 `json:"componentMetadata,omitempty"`
+	ApplyTime             *time.Time                                   `json:"applyTime,omitempty"`
+	LinkEstimate          *LinkEstimate                                `json:"linkEstimate,omitempty"`
+	DryRunReport          *DryRunReport                                `json:"dryRunReport,omitempty"`
+	HealthDeadline        *time.Time                                   `json:"healthDeadline,omitempty"`
+	HealthDecision        *HealthDecision                              `json:"healthDecision,omitempty"`
+	MigratedComponents    []string                                     `json:"migratedComponents,omitempty"`
+	HistorySession        string                                       `json:"historySession,omitempty"`
+	Constraints           map[string][]versionConstraint               `json:"constraints,omitempty"`
+	ScheduledEvent        *scheduledEvent                              `json:"scheduledEvent,omitempty"`
+	ComponentFailures     map[string]*componentFailures                `json:"componentFailures,omitempty"`
+	FailurePolicy         string                                       `json:"failurePolicy,omitempty"`
+	ExcludedComponents    []string                                     `json:"excludedComponents,omitempty"`
+	PendingConfirmations  []string                                     `json:"pendingConfirmations,omitempty"`
+	ConfirmationStart     *time.Time                                   `json:"confirmationStart,omitempty"`
+	ComponentHealth       map[string][]healthEvent                     `json:"componentHealth,omitempty"`
 }
 
 type componentData struct {
-	module         UpdateModule
-	updatePriority uint32
-	rebootPriority uint32
+	module          UpdateModule
+	moduleConfig    config.ModuleConfig
+	updatePriority  uint32
+	rebootPriority  uint32
+	rebootGroup     string
+	rebootType      string
+	externalTarget  bool
+	versionScheme   versionutils.Scheme
+	verifier        *componentVerifier
+	updateTimeout   time.Duration
+	signaturePolicy *signaturePolicy
+	imagePolicy     *verificationPolicy
+	imageFormats    []string
+	dependencies    []string
+	spaceCheck      *config.SpaceCheck
+	confirmation    *config.Confirmation
+	trustAnchor     *trustAnchor
+	journal         *opjournal.Journal
+}
+
+type updateAnnotations struct {
+	DownloadHeaders   map[string]string   `json:"downloadHeaders,omitempty"`
+	NodeSelector      map[string]string   `json:"nodeSelector,omitempty"`
+	Reinstall         bool                `json:"reinstall,omitempty"`
+	ForceDowngrade    bool                `json:"forceDowngrade,omitempty"`
+	Signatures        []imageSignature    `json:"signatures,omitempty"`
+	SBOM              *manifestAnnotation `json:"sbom,omitempty"`
+	Encryption        *imageEncryption    `json:"encryption,omitempty"`
+	DetachedSignature *detachedSignature  `json:"detachedSignature,omitempty"`
+	Mirrors           []string            `json:"mirrors,omitempty"`
+	Rollout           *rolloutAnnotation  `json:"rollout,omitempty"`
+	Constraints       []versionConstraint `json:"constraints,omitempty"`
+	FailurePolicy     string              `json:"failurePolicy,omitempty"`
+	Bundle            bool                `json:"bundle,omitempty"`
+	Confirmation      bool                `json:"confirmation,omitempty"`
+}
+
+type versionResult struct {
+	id            string
+	vendorVersion string
+	metadata      map[string]string
+	capabilities  *umclient.ComponentCapabilities
+	err           error
 }
 
-type componentOperation func(module UpdateModule) (rebootRequired bool, err error)
+type componentOperation func(ctx context.Context, module UpdateModule) (rebootRequired bool, err error)
 
-type priorityOperation struct {
-	priority  uint32
-	operation func() (err error)
+type scheduledOperation struct {
+	id           string
+	priority     uint32
+	dependencies []string
+	operation    func() (err error)
 }
 
 /*******************************************************************************
@@ -154,15 +319,178 @@ func RegisterPlugin(plugin string, newFunc NewPlugin) {
 	plugins[plugin] = newFunc
 }
 
+// NewModule creates update module instance of registered plugin.
+func NewModule(plugin, id string, params json.RawMessage, storage ModuleStorage) (module UpdateModule, err error) {
+	newFunc, ok := plugins[plugin]
+	if !ok {
+		return nil, aoserrors.Errorf("plugin %s not found", plugin)
+	}
+
+	if module, err = newFunc(id, params, storage); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return module, nil
+}
+
 // New returns pointer to new Handler.
 func New(cfg *config.Config, storage StateStorage, moduleStorage ModuleStorage) (handler *Handler, err error) {
 	log.Debug("Create update handler")
 
+	if err = LoadPlugins(cfg.PluginFiles); err != nil {
+		return nil, err
+	}
+
 	handler = &Handler{
-		componentStatuses: make(map[string]*umclient.ComponentStatusInfo),
-		storage:           storage,
-		statusChannel:     make(chan umclient.Status, statusChannelSize),
-		downloadDir:       cfg.DownloadDir,
+		componentStatuses:     make(map[string]*umclient.ComponentStatusInfo),
+		configChanged:         make(map[string]bool),
+		storage:               storage,
+		moduleStorage:         moduleStorage,
+		moduleStorageQuota:    cfg.ModuleStorageQuota,
+		statusChannel:         make(chan umclient.Status, statusChannelSize),
+		confirmChannel:        make(chan struct{}, 1),
+		downloadDir:           cfg.DownloadDir,
+		cacheDir:              cfg.CacheDir,
+		failedImagesDir:       cfg.FailedImages.Dir,
+		maxFailedImages:       cfg.FailedImages.MaxImages,
+		snapshotPaths:         cfg.SnapshotPaths,
+		stateExportFile:       cfg.StateExportFile,
+		labels:                cfg.Labels,
+		clock:                 clock.New(),
+		versionRefreshTimeout: cfg.VersionRefreshTimeout.Duration,
+		revertWindow:          cfg.RevertWindow.Duration,
+		errorRetention:        cfg.ErrorRetention.Duration,
+		failureThreshold:      cfg.FailureThreshold,
+		failurePolicy:         cfg.FailurePolicy,
+		blockersPollInterval:  cfg.UpdateBlockers.PollInterval.Duration,
+		injectionToken:        cfg.FailureInjection.Token,
+		mirrorSelection:       cfg.MirrorSelection,
+		maxDownloads:          cfg.MaxConcurrentDownloads,
+		allowedMaintenance:    newAllowedMaintenance(cfg.MaintenanceActions),
+		deviceID:              getDeviceID(cfg.Rollout),
+		progressInterval:      cfg.ProgressInterval.Duration,
+		speedTest:             newSpeedTest(cfg.SpeedTest),
+		healthWindow:          cfg.HealthChecks.Window.Duration,
+		healthPollInterval:    cfg.HealthChecks.PollInterval.Duration,
+		refreshInterval:       cfg.StatusRefreshInterval.Duration,
+		refreshTimes:          make(map[string]time.Time),
+		stopTimeout:           operationStopTimeout,
+		stuckOperations:       make(map[string]string),
+	}
+
+	if handler.instanceID, err = instanceid.Get(cfg.InstanceID, storage); err != nil {
+		return nil, err
+	}
+
+	log.WithField("instanceID", handler.instanceID).Info("Update manager instance")
+
+	handler.history = updatehistory.New(handler.instanceID, storage)
+
+	if handler.versionRefreshTimeout == 0 {
+		handler.versionRefreshTimeout = defaultVersionRefreshTimeout
+	}
+
+	if handler.blockersPollInterval == 0 {
+		handler.blockersPollInterval = defaultBlockersPollInterval
+	}
+
+	if handler.healthPollInterval == 0 {
+		handler.healthPollInterval = defaultHealthPollInterval
+	}
+
+	if handler.refreshInterval == 0 {
+		handler.refreshInterval = defaultStatusRefreshInterval
+	}
+
+	if err = checkUpdateBlockers(cfg.UpdateBlockers); err != nil {
+		return nil, err
+	}
+
+	if err = checkMirrorSelection(cfg.MirrorSelection); err != nil {
+		return nil, err
+	}
+
+	if err = checkMaxDownloads(cfg.MaxConcurrentDownloads); err != nil {
+		return nil, err
+	}
+
+	if handler.bandwidth, err = newBandwidthLimiter(handler, cfg.DownloadBandwidth); err != nil {
+		return nil, err
+	}
+
+	if err = checkFailureThreshold(cfg.FailureThreshold); err != nil {
+		return nil, err
+	}
+
+	if err = checkFailurePolicy(cfg.FailurePolicy); err != nil {
+		return nil, err
+	}
+
+	if handler.retryPolicy, err = newRetryPolicy(cfg.OperationRetry); err != nil {
+		return nil, err
+	}
+
+	if err = checkFailedImages(cfg.FailedImages.Dir, cfg.FailedImages.MaxImages); err != nil {
+		return nil, err
+	}
+
+	if err = checkPrefetchConfig(cfg.Prefetch); err != nil {
+		return nil, err
+	}
+
+	if err = checkMaintenanceActions(cfg.MaintenanceActions); err != nil {
+		return nil, err
+	}
+
+	if err = checkStatusRefreshInterval(cfg.StatusRefreshInterval.Duration); err != nil {
+		return nil, err
+	}
+
+	if handler.applySchedule, err = newApplySchedule(cfg.ApplySchedule); err != nil {
+		return nil, err
+	}
+
+	if err = checkSpeedTest(cfg.SpeedTest); err != nil {
+		return nil, err
+	}
+
+	if handler.hooks, err = newTransitionHooks(cfg.Hooks); err != nil {
+		return nil, err
+	}
+
+	if handler.healthChecks, err = newHealthChecks(cfg.HealthChecks); err != nil {
+		return nil, err
+	}
+
+	if handler.journalCursors, err = newJournalCursors(cfg.JournalCursors); err != nil {
+		return nil, err
+	}
+
+	if handler.healthScoreWindow, err = getHealthScoreWindow(cfg.HealthScore); err != nil {
+		return nil, err
+	}
+
+	if handler.canary, err = newCanaryApply(cfg.CanaryApply, cfg.UpdateModules); err != nil {
+		return nil, err
+	}
+
+	handler.blockers = newUpdateBlockers(cfg.UpdateBlockers)
+	handler.reboots = newRebootManager(handler, cfg.BatchReboots)
+
+	if len(handler.snapshotPaths) != 0 {
+		if cfg.WorkingDir == "" {
+			return nil, aoserrors.New("working dir should be configured for config snapshot")
+		}
+
+		handler.snapshotFile = filepath.Join(cfg.WorkingDir, snapshotFileName)
+	}
+
+	if cfg.WorkingDir != "" {
+		handler.manifestsDir = filepath.Join(cfg.WorkingDir, manifestsDirName)
+	}
+
+	if handler.downloadHosts, err = newDownloadHosts(cfg.DownloadHosts); err != nil {
+		return nil, aoserrors.Wrap(err)
 	}
 
 	if err = handler.getState(); err != nil {
@@ -173,6 +501,10 @@ func New(cfg *config.Config, storage StateStorage, moduleStorage ModuleStorage)
 		handler.state.UpdateState = stateIdle
 	}
 
+	handler.initQuarantine()
+
+	handler.removeOrphanSessions()
+
 	handler.fsm = fsm.NewFSM(handler.state.UpdateState, fsm.Events{
 		{Name: eventPrepare, Src: []string{stateIdle}, Dst: statePrepared},
 		{Name: eventUpdate, Src: []string{statePrepared}, Dst: stateUpdated},
@@ -181,7 +513,7 @@ func New(cfg *config.Config, storage StateStorage, moduleStorage ModuleStorage)
 	},
 		fsm.Callbacks{
 			afterPrefix + "event":      handler.onStateChanged,
-			leavePrefix + "state":      func(ctx context.Context, event *fsm.Event) { event.Async() },
+			leavePrefix + "state":      handler.onLeaveState,
 			afterPrefix + eventPrepare: handler.onPrepareState,
 			afterPrefix + eventUpdate:  handler.onUpdateState,
 			afterPrefix + eventApply:   handler.onApplyState,
@@ -189,25 +521,54 @@ func New(cfg *config.Config, storage StateStorage, moduleStorage ModuleStorage)
 		},
 	)
 
-	handler.components = make(map[string]componentData)
+	keyProviders, err := newKeyProviders(cfg.KeyProviders)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			closeKeyProviders(keyProviders)
+		}
+	}()
+
+	handler.keyProviders = keyProviders
+
+	if handler.components, err = handler.newComponents(cfg); err != nil {
+		return nil, err
+	}
 
 	for _, moduleCfg := range cfg.UpdateModules {
-		if moduleCfg.Disabled {
+		component, ok := handler.components[moduleCfg.ID]
+		if !ok {
 			log.WithField("id", moduleCfg.ID).Debug("Skip disabled module")
 			continue
 		}
 
-		component := componentData{updatePriority: moduleCfg.UpdatePriority, rebootPriority: moduleCfg.RebootPriority}
-
-		if component.module, err = handler.createComponent(moduleCfg.Plugin, moduleCfg.ID,
-			moduleCfg.Params, moduleStorage); err != nil {
-			return nil, aoserrors.Wrap(err)
+		if component.module, err = handler.newComponentModule(moduleCfg); err != nil {
+			return nil, err
 		}
 
 		handler.components[moduleCfg.ID] = component
 	}
 
-	handler.init()
+	handler.initComponents(sortedComponentIDs(handler.components))
+	handler.initRevertWindow()
+	handler.initErrorRetention()
+	handler.initUpdateWindow()
+	handler.verifyExportedState()
+
+	handler.Lock()
+	handler.setCurrentStatus(handler.getStatus())
+	handler.Unlock()
+
+	if handler.prefetch, err = newPrefetcher(handler, cfg.Prefetch); err != nil {
+		return nil, err
+	}
+
+	if handler.prefetch != nil {
+		handler.prefetch.start()
+	}
 
 	return handler, nil
 }
@@ -220,6 +581,29 @@ func (handler *Handler) Registered() {
 	handler.sendStatus()
 }
 
+// Inventory refreshes component versions, reconciles update bookkeeping and returns current status without sending
+// it to the status channel. It is used when update manager runs in dry start mode.
+func (handler *Handler) Inventory() (status umclient.Status) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	log.Info("Inventory components")
+
+	ids := make([]string, 0, len(handler.components))
+
+	for id := range handler.components {
+		ids = append(ids, id)
+	}
+
+	handler.getVersions(ids)
+
+	if handler.state.UpdateState == stateIdle {
+		handler.reconcileState(ids)
+	}
+
+	return handler.getStatus()
+}
+
 // PrepareUpdate prepares update.
 func (handler *Handler) PrepareUpdate(components []umclient.ComponentUpdateInfo) {
 	log.Info("Prepare update")
@@ -251,44 +635,31 @@ func (handler *Handler) ApplyUpdate() {
 func (handler *Handler) RevertUpdate() {
 	log.Info("Revert update")
 
+	if err := handler.refuseStuckRevert(); err != nil {
+		log.Errorf("Revert refused: %s", aoserrors.Wrap(err))
+
+		return
+	}
+
 	if err := handler.sendEvent(eventRevert); err != nil {
 		log.Errorf("Can't send revert event: %s", aoserrors.Wrap(err))
 	}
 }
 
+// InstanceID returns update manager instance ID.
+func (handler *Handler) InstanceID() (id string) {
+	return handler.instanceID
+}
+
 // StatusChannel returns status channel.
 func (handler *Handler) StatusChannel() (status <-chan umclient.Status) {
 	return handler.statusChannel
 }
 
-// Close closes update handler.
-func (handler *Handler) Close() {
-	log.Debug("Close update handler")
-
-	for _, component := range handler.components {
-		component.module.Close()
-	}
-}
-
 /*******************************************************************************
  * Private
  ******************************************************************************/
 
-func (handler *Handler) createComponent(
-	plugin, id string, params json.RawMessage, storage ModuleStorage,
-) (module UpdateModule, err error) {
-	newFunc, ok := plugins[plugin]
-	if !ok {
-		return nil, aoserrors.Errorf("plugin %s not found", plugin)
-	}
-
-	if module, err = newFunc(id, params, storage); err != nil {
-		return nil, aoserrors.Wrap(err)
-	}
-
-	return module, nil
-}
-
 func (handler *Handler) getState() (err error) {
 	jsonState, err := handler.storage.GetUpdateState()
 	if err != nil {
@@ -319,10 +690,16 @@ func (handler *Handler) saveState() (err error) {
 	return nil
 }
 
-func (handler *Handler) init() {
-	operations := make([]priorityOperation, 0, len(handler.components))
+// initComponents initializes modules of specified components and refreshes their versions.
+func (handler *Handler) initComponents(ids []string) {
+	operations := make([]scheduledOperation, 0, len(ids))
+
+	for _, id := range ids {
+		component, ok := handler.components[id]
+		if !ok {
+			continue
+		}
 
-	for id, component := range handler.components {
 		handler.componentStatuses[id] = &umclient.ComponentStatusInfo{
 			ID:     id,
 			Status: umclient.StatusInstalled,
@@ -331,8 +708,10 @@ func (handler *Handler) init() {
 		module := component.module
 		id := id
 
-		operations = append(operations, priorityOperation{
-			priority: component.updatePriority,
+		operations = append(operations, scheduledOperation{
+			id:           id,
+			priority:     component.updatePriority,
+			dependencies: component.dependencies,
 			operation: func() (err error) {
 				log.WithField("id", id).Debug("Init component")
 
@@ -348,221 +727,353 @@ func (handler *Handler) init() {
 		})
 	}
 
-	_ = doPriorityOperations(operations, false)
+	_ = scheduleOperations(operations, false)
 
-	handler.getVersions()
+	handler.getVersions(ids)
 }
 
-func (handler *Handler) getVersions() {
-	log.Debug("Update component versions")
+func sortedComponentIDs(components map[string]componentData) (ids []string) {
+	ids = make([]string, 0, len(components))
 
-	for id, component := range handler.components {
-		var err error
+	for id := range components {
+		ids = append(ids, id)
+	}
 
-		vendorVersion, ok := handler.state.CurrentVendorVersions[id]
+	sort.Strings(ids)
 
-		if handler.state.UpdateState == stateIdle || !ok {
-			if vendorVersion, err = component.module.GetVendorVersion(); err != nil {
-				log.Errorf("Can't get vendor version: %s", aoserrors.Wrap(err))
-			}
-		}
+	return ids
+}
+
+// getVersions refreshes versions of specified components. Versions of other components are cached and
+// invalidated only when the component is updated. Vendor versions are requested from modules concurrently,
+// modules which don't respond within refresh timeout keep previous version and are updated asynchronously.
+func (handler *Handler) getVersions(ids []string) {
+	log.WithField("ids", ids).Debug("Update component versions")
+
+	results := make(chan versionResult, len(ids))
+	pending := 0
 
-		handler.componentStatuses[id].VendorVersion = vendorVersion
+	for _, id := range ids {
+		component, ok := handler.components[id]
+		if !ok {
+			continue
+		}
 
-		aosVersion, err := handler.storage.GetAosVersion(id)
-		if err == nil {
+		if aosVersion, err := handler.storage.GetAosVersion(id); err == nil {
 			handler.componentStatuses[id].AosVersion = aosVersion
 		}
-	}
-}
 
-func (handler *Handler) sendStatus() {
-	log.WithFields(log.Fields{"state": handler.state.UpdateState, "error": handler.state.Error}).Debug("Send status")
+		if vendorVersion, ok := handler.state.CurrentVendorVersions[id]; ok &&
+			handler.state.UpdateState != stateIdle {
+			handler.componentStatuses[id].VendorVersion = vendorVersion
+
+			continue
+		}
+
+		pending++
+
+		go func(id string, module UpdateModule) {
+			vendorVersion, err := module.GetVendorVersion()
+			metadata := getModuleMetadata(id, module)
+			capabilities := getModuleCapabilities(id, module)
 
-	status := umclient.Status{
-		State: toUMState(handler.state.UpdateState),
-		Error: handler.state.Error,
+			results <- versionResult{
+				id: id, vendorVersion: vendorVersion, metadata: metadata, capabilities: capabilities, err: err,
+			}
+		}(id, component.module)
 	}
 
-	for id, componentStatus := range handler.componentStatuses {
-		status.Components = append(status.Components, *componentStatus)
+	timeout := handler.clock.After(handler.versionRefreshTimeout)
 
-		log.WithFields(log.Fields{
-			"id":            componentStatus.ID,
-			"vendorVersion": componentStatus.VendorVersion,
-			"aosVersion":    componentStatus.AosVersion,
-			"status":        componentStatus.Status,
-			"error":         componentStatus.Error,
-		}).Debug("Component status")
+	for ; pending > 0; pending-- {
+		select {
+		case result := <-results:
+			handler.setVendorVersion(result)
+
+		case <-timeout:
+			log.Warn("Component versions are not received in time, continue refreshing asynchronously")
 
-		updateStatus, ok := handler.state.ComponentStatuses[id]
-		if ok {
-			status.Components = append(status.Components, *updateStatus)
+			go handler.waitVersions(results, pending)
 
-			log.WithFields(log.Fields{
-				"id":            updateStatus.ID,
-				"vendorVersion": updateStatus.VendorVersion,
-				"aosVersion":    updateStatus.AosVersion,
-				"status":        updateStatus.Status,
-				"error":         updateStatus.Error,
-			}).Debug("Component status")
+			return
 		}
 	}
-
-	handler.statusChannel <- status
 }
 
-func (handler *Handler) onStateChanged(ctx context.Context, event *fsm.Event) {
-	handler.state.UpdateState = handler.fsm.Current()
+func (handler *Handler) waitVersions(results <-chan versionResult, pending int) {
+	for ; pending > 0; pending-- {
+		result := <-results
 
-	if handler.state.UpdateState == stateIdle {
-		handler.getVersions()
+		handler.Lock()
 
-		for id, componentStatus := range handler.state.ComponentStatuses {
-			if componentStatus.Status != umclient.StatusError {
-				delete(handler.state.ComponentStatuses, id)
-			}
+		// Module may respond after handler is closed
+		if handler.isClosed() {
+			handler.Unlock()
+
+			return
 		}
 
-		if handler.downloadDir != "" {
-			if err := os.RemoveAll(handler.downloadDir); err != nil {
-				log.Errorf("Can't remove download dir: %s", handler.downloadDir)
-			}
+		handler.setVendorVersion(result)
+
+		if handler.state.UpdateState == stateIdle {
+			handler.sendStatus()
 		}
+
+		handler.Unlock()
+	}
+}
+
+func (handler *Handler) setVendorVersion(result versionResult) {
+	// Component may be removed by config reload while its version is being refreshed
+	if _, ok := handler.componentStatuses[result.id]; !ok {
+		return
 	}
 
-	if err := handler.saveState(); err != nil {
-		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	handler.setMetadata(result.id, result.metadata)
+	handler.setCapabilities(result.id, result.capabilities)
 
-		if handler.state.Error == "" {
-			handler.state.Error = err.Error()
-		}
+	if result.err != nil {
+		log.WithField("id", result.id).Errorf("Can't get vendor version: %s", aoserrors.Wrap(result.err))
 
-		handler.state.UpdateState = stateFailed
-		handler.fsm.SetState(handler.state.UpdateState)
+		return
 	}
 
-	handler.sendStatus()
+	handler.componentStatuses[result.id].VendorVersion = result.vendorVersion
 }
 
-func componentError(componentStatus *umclient.ComponentStatusInfo, err error) {
-	log.WithField("id", componentStatus.ID).Errorf("Component error: %s", aoserrors.Wrap(err))
+func (handler *Handler) sendStatus() {
+	log.WithFields(log.Fields{"state": handler.state.UpdateState, "error": handler.state.Error}).Debug("Send status")
 
-	componentStatus.Status = umclient.StatusError
-	componentStatus.Error = err.Error()
-}
+	status := handler.getStatus()
 
-func doPriorityOperations(operations []priorityOperation, stopOnError bool) (err error) {
-	if len(operations) == 0 {
-		return nil
-	}
+	handler.setCurrentStatus(status)
 
-	sort.Slice(operations, func(i, j int) bool { return operations[i].priority > operations[j].priority })
+	handler.statusChannel <- status
+}
 
-	var (
-		wg       sync.WaitGroup
-		groupErr error
-	)
+// getStatus assembles status sorted by component ID. Each component is reported by installed entry followed by
+// in-progress entry if it differs from installed one. Component skipped in current update is reported by skipped
+// entry only.
+func (handler *Handler) getStatus() (status umclient.Status) {
+	status = umclient.Status{
+		InstanceID: handler.instanceID,
+		State:      toUMState(handler.state.UpdateState),
+		Error:      handler.state.Error,
+	}
 
-	priority := operations[0].priority
+	if capabilities := handler.getCapabilities(); len(capabilities) != 0 {
+		status.Capabilities = capabilities
+	}
 
-	for _, item := range operations {
-		if item.priority != priority {
-			wg.Wait()
+	if scores := handler.getDegradedHealthScores(); len(scores) != 0 {
+		status.HealthScores = scores
+	}
 
-			if groupErr != nil {
-				if stopOnError {
-					return groupErr
-				}
+	if handler.isQuarantined() {
+		status.State = umclient.StateFailed
+		status.Error = quarantinedMsg
+	}
 
-				if err == nil {
-					err = groupErr
-				}
+	ids := make([]string, 0, len(handler.componentStatuses))
 
-				groupErr = nil
-			}
+	for id := range handler.componentStatuses {
+		ids = append(ids, id)
+	}
 
-			priority = item.priority
+	for id := range handler.state.SkippedComponents {
+		if _, ok := handler.componentStatuses[id]; !ok {
+			ids = append(ids, id)
 		}
+	}
 
-		operation := item.operation
+	sort.Strings(ids)
 
-		wg.Add(1)
+	for _, id := range ids {
+		if skippedStatus, ok := handler.state.SkippedComponents[id]; ok {
+			status.Components = appendComponentStatus(status.Components, *skippedStatus)
 
-		go func() {
-			defer wg.Done()
+			continue
+		}
 
-			if err := operation(); err != nil {
-				if groupErr == nil {
-					groupErr = err
-				}
+		installedStatus := *handler.componentStatuses[id]
+
+		if handler.isComponentQuarantined(id) {
+			installedStatus.Status = umclient.StatusError
+			installedStatus.Error = handler.quarantineMessage(id)
+		} else if installedStatus.Status != umclient.StatusError {
+			if handler.isCommitted(id) {
+				installedStatus.Error = committedMsg
+			} else if handler.configChanged[id] {
+				installedStatus.Error = configChangedMsg
 			}
-		}()
-	}
+		}
 
-	wg.Wait()
+		status.Components = appendComponentStatus(status.Components, installedStatus)
 
-	if groupErr != nil {
-		if err == nil {
-			err = groupErr
+		if updateStatus, ok := handler.state.ComponentStatuses[id]; ok && *updateStatus != installedStatus {
+			status.Components = appendComponentStatus(status.Components, *updateStatus)
 		}
 	}
 
-	return aoserrors.Wrap(err)
+	return status
 }
 
-func (handler *Handler) doOperation(componentStatuses []*umclient.ComponentStatusInfo,
-	operation componentOperation, stopOnError bool,
-) (rebootStatuses []*umclient.ComponentStatusInfo, err error) {
-	operations := make([]priorityOperation, 0, len(componentStatuses))
+func appendComponentStatus(
+	components []umclient.ComponentStatusInfo, componentStatus umclient.ComponentStatusInfo,
+) []umclient.ComponentStatusInfo {
+	log.WithFields(log.Fields{
+		"id":            componentStatus.ID,
+		"vendorVersion": componentStatus.VendorVersion,
+		"aosVersion":    componentStatus.AosVersion,
+		"status":        componentStatus.Status,
+		"error":         componentStatus.Error,
+	}).Debug("Component status")
+
+	return append(components, componentStatus)
+}
 
-	for _, componentStatus := range componentStatuses {
-		component, ok := handler.components[componentStatus.ID]
-		if !ok {
-			notFoundErr := aoserrors.Errorf("component %s not found", componentStatus.ID)
-			componentError(componentStatus, notFoundErr)
+// reconcileState removes bookkeeping of not configured components and manifests of not installed versions.
+func (handler *Handler) reconcileState(ids []string) {
+	handler.cleanupManifests(ids)
 
-			if stopOnError {
-				return nil, notFoundErr
-			}
+	changed := false
 
-			if err == nil {
-				err = notFoundErr
-			}
+	for id := range handler.state.ComponentStatuses {
+		if _, ok := handler.components[id]; !ok {
+			log.WithField("id", id).Warn("Remove status of not configured component")
 
-			continue
+			delete(handler.state.ComponentStatuses, id)
+
+			changed = true
 		}
+	}
 
-		module := component.module
-		status := componentStatus
+	for id := range handler.state.ComponentMetadata {
+		if _, ok := handler.components[id]; !ok {
+			log.WithField("id", id).Warn("Remove metadata of not configured component")
 
-		operations = append(operations, priorityOperation{
-			priority: component.updatePriority,
-			operation: func() (err error) {
-				rebootRequired, err := operation(module)
-				if err != nil {
-					componentError(status, err)
-					return aoserrors.Wrap(err)
-				}
+			delete(handler.state.ComponentMetadata, id)
 
-				if rebootRequired {
-					log.WithField("id", module.GetID()).Debug("Reboot required")
+			changed = true
+		}
+	}
 
-					rebootStatuses = append(rebootStatuses, status)
-				}
+	for id := range handler.state.ComponentFailures {
+		if _, ok := handler.components[id]; !ok {
+			log.WithField("id", id).Warn("Remove failures of not configured component")
 
-				return nil
-			},
-		})
+			delete(handler.state.ComponentFailures, id)
+
+			changed = true
+		}
+	}
+
+	if !changed {
+		return
 	}
 
-	err = doPriorityOperations(operations, stopOnError)
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
 
-	return rebootStatuses, aoserrors.Wrap(err)
+	if err := handler.exportState(); err != nil {
+		log.Errorf("Can't export update state: %s", aoserrors.Wrap(err))
+	}
 }
 
-func (handler *Handler) doReboot(componentStatuses []*umclient.ComponentStatusInfo, stopOnError bool) (err error) {
-	operations := make([]priorityOperation, 0, len(componentStatuses))
+func (handler *Handler) onStateChanged(ctx context.Context, event *fsm.Event) {
+	if handler.restoreCheckpoint() {
+		handler.sendStatus()
+
+		return
+	}
+
+	handler.state.UpdateState = handler.fsm.Current()
+
+	handler.recordTransition(event)
+	handler.cancelScheduledEvent()
+
+	if handler.state.UpdateState == stateIdle {
+		ids := make([]string, 0, len(handler.state.ComponentStatuses))
+
+		for id := range handler.state.ComponentStatuses {
+			ids = append(ids, id)
+		}
+
+		handler.getVersions(ids)
+		handler.cleanupManifests(ids)
+		handler.countComponentFailures(event.Event == eventApply)
+		handler.recordHealthEvents()
+		handler.uploadDiagnosticBundle()
+
+		appliedIDs := make([]string, 0, len(handler.state.ComponentStatuses))
+
+		for id, componentStatus := range handler.state.ComponentStatuses {
+			if componentStatus.Status != umclient.StatusError {
+				delete(handler.state.ComponentStatuses, id)
+				delete(handler.configChanged, id)
+
+				appliedIDs = append(appliedIDs, id)
+			}
+		}
+
+		handler.retainFailedImages()
+
+		if event.Event == eventApply && handler.revertWindow > 0 && len(appliedIDs) != 0 {
+			handler.startRevertWindow(appliedIDs)
+		} else {
+			handler.removeUpdateData()
+		}
+
+		handler.startErrorRetention()
+
+		handler.state.ImageHashes = nil
+		handler.state.ImageURLs = nil
+		handler.state.SkippedComponents = nil
+		handler.state.ApplyTime = nil
+		handler.state.HealthDeadline = nil
+		handler.state.MigratedComponents = nil
+		handler.state.HistorySession = ""
+		handler.state.Constraints = nil
+		handler.state.FailurePolicy = ""
+		handler.state.ExcludedComponents = nil
+		handler.state.PendingConfirmations = nil
+		handler.state.ConfirmationStart = nil
+	}
+
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+
+		if handler.state.Error == "" {
+			handler.state.Error = err.Error()
+		}
+
+		handler.state.UpdateState = stateFailed
+		handler.fsm.SetState(handler.state.UpdateState)
+	}
+
+	if err := handler.exportState(); err != nil {
+		log.Errorf("Can't export update state: %s", aoserrors.Wrap(err))
+	}
+
+	handler.sendStatus()
+}
+
+func componentError(componentStatus *umclient.ComponentStatusInfo, err error) {
+	log.WithField("id", componentStatus.ID).Errorf("Component error: %s", aoserrors.Wrap(err))
+
+	componentStatus.Status = umclient.StatusError
+	componentStatus.Error = err.Error()
+}
+
+func (handler *Handler) doOperation(componentStatuses []*umclient.ComponentStatusInfo,
+	phase string, operation componentOperation, stopOnError bool,
+) (rebootStatuses []*umclient.ComponentStatusInfo, rebootTypes map[string]string, err error) {
+	var rebootMutex sync.Mutex
+
+	rebootTypes = make(map[string]string)
+
+	operations := make([]scheduledOperation, 0, len(componentStatuses))
 
 	for _, componentStatus := range componentStatuses {
 		component, ok := handler.components[componentStatus.ID]
@@ -571,45 +1082,118 @@ func (handler *Handler) doReboot(componentStatuses []*umclient.ComponentStatusIn
 			componentError(componentStatus, notFoundErr)
 
 			if stopOnError {
-				return aoserrors.Wrap(notFoundErr)
+				return nil, nil, notFoundErr
 			}
 
 			if err == nil {
-				err = aoserrors.Wrap(notFoundErr)
+				err = notFoundErr
 			}
 
 			continue
 		}
 
 		module := component.module
-
-		operations = append(operations, priorityOperation{
-			priority: component.rebootPriority,
+		status := componentStatus
+		externalTarget := component.externalTarget
+		journal := component.journal
+		timeout := component.updateTimeout
+		rebootType := component.rebootType
+		rebootGroup := component.rebootGroup
+		dependencies := component.dependencies
+
+		operations = append(operations, scheduledOperation{
+			id:           componentStatus.ID,
+			priority:     component.updatePriority,
+			dependencies: component.dependencies,
 			operation: func() (err error) {
-				log.WithField("id", module.GetID()).Debug("Reboot component")
+				var rebootRequired bool
 
-				if err := module.Reboot(); err != nil {
-					componentError(componentStatus, err)
+				if err = handler.checkStopped(); err != nil {
+					componentError(status, err)
+					return err
+				}
+
+				if dependency := handler.failedDependency(phase, dependencies); dependency != "" {
+					err = aoserrors.Errorf("dependency %s failed", dependency)
+					componentError(status, err)
+
+					return err
+				}
+
+				if err = handler.measureUsage(module.GetID(), phase, journal, func() (err error) {
+					rebootRequired, err = handler.retryOperation(module.GetID(), phase,
+						func() (rebootRequired bool, err error) {
+							return handler.runOperation(module.GetID(), phase, timeout,
+								func(ctx context.Context) (rebootRequired bool, err error) {
+									if err = handler.injectFailure(ctx, module.GetID(), phase); err != nil {
+										return false, err
+									}
+
+									return operation(ctx, module)
+								})
+						})
+
+					return err
+				}); err != nil {
+					componentError(status, err)
 					return aoserrors.Wrap(err)
 				}
 
+				// Components on remote hardware are rebooted and checked by module itself over remote channel
+				if rebootRequired && externalTarget {
+					log.WithField("id", module.GetID()).Warn("Ignore reboot request of external target component")
+
+					return nil
+				}
+
+				if rebootRequired {
+					requiredType := getRebootType(module, rebootType)
+
+					log.WithFields(log.Fields{"id": module.GetID(), "rebootType": requiredType}).Debug(
+						"Reboot required")
+
+					handler.addPendingReboot(status.ID, phase, rebootGroup, requiredType)
+
+					rebootMutex.Lock()
+					rebootStatuses = append(rebootStatuses, status)
+					rebootTypes[status.ID] = requiredType
+					rebootMutex.Unlock()
+				}
+
 				return nil
 			},
 		})
 	}
 
-	return aoserrors.Wrap(doPriorityOperations(operations, stopOnError))
+	err = scheduleOperations(operations, stopOnError)
+
+	return rebootStatuses, rebootTypes, aoserrors.Wrap(err)
 }
 
-func (handler *Handler) componentOperation(operation componentOperation, stopOnError bool) (err error) {
+func (handler *Handler) componentOperation(
+	phase string, operation componentOperation, stopOnError bool,
+) (err error) {
 	operationStatuses := make([]*umclient.ComponentStatusInfo, 0, len(handler.state.ComponentStatuses))
 
-	for _, operationStatus := range handler.state.ComponentStatuses {
+	for id, operationStatus := range handler.state.ComponentStatuses {
+		// Components excluded from best effort session are already reverted
+		if handler.isExcluded(id) {
+			continue
+		}
+
 		operationStatuses = append(operationStatuses, operationStatus)
 	}
 
+	return handler.statusesOperation(operationStatuses, phase, operation, stopOnError)
+}
+
+func (handler *Handler) statusesOperation(operationStatuses []*umclient.ComponentStatusInfo,
+	phase string, operation componentOperation, stopOnError bool,
+) (err error) {
+	defer handler.clearPendingActions()
+
 	for len(operationStatuses) != 0 {
-		rebootStatuses, opError := handler.doOperation(operationStatuses, operation, stopOnError)
+		rebootStatuses, rebootTypes, opError := handler.doOperation(operationStatuses, phase, operation, stopOnError)
 		if opError != nil {
 			if stopOnError {
 				return aoserrors.Wrap(opError)
@@ -624,7 +1208,7 @@ func (handler *Handler) componentOperation(operation componentOperation, stopOnE
 			return aoserrors.Wrap(err)
 		}
 
-		if rebootError := handler.doReboot(rebootStatuses, stopOnError); rebootError != nil {
+		if rebootError := handler.reboots.reboot(rebootStatuses, rebootTypes, stopOnError); rebootError != nil {
 			if stopOnError {
 				return aoserrors.Wrap(rebootError)
 			}
@@ -640,73 +1224,124 @@ func (handler *Handler) componentOperation(operation componentOperation, stopOnE
 	return aoserrors.Wrap(err)
 }
 
-func (handler *Handler) prepareComponent(module UpdateModule, updateInfo *umclient.ComponentUpdateInfo) (err error) {
+func (handler *Handler) prepareComponent(
+	ctx context.Context, module UpdateModule, updateInfo *umclient.ComponentUpdateInfo,
+	images map[string]downloadResult,
+) (err error) {
+	// Reinstall flag can be set by CM in annotations as there is no dedicated field in the protocol
+	reinstall := updateInfo.Reinstall || getUpdateAnnotations(updateInfo.Annotations).Reinstall
+
+	if reinstall {
+		log.WithField("id", updateInfo.ID).Info("Force component reinstall")
+	}
+
 	vendorVersion, err := module.GetVendorVersion()
-	if err == nil && updateInfo.VendorVersion != "" {
-		if vendorVersion == updateInfo.VendorVersion {
+	if err == nil && updateInfo.VendorVersion != "" && !reinstall {
+		if handler.versionsEqual(module.GetID(), vendorVersion, updateInfo.VendorVersion) {
 			return aoserrors.Errorf("component already has required vendor version: %s", vendorVersion)
 		}
 	}
 
+	var aosVersion uint64
+
 	if updateInfo.AosVersion != 0 {
-		aosVersion, err := handler.storage.GetAosVersion(updateInfo.ID)
-		if err == nil {
-			if aosVersion == updateInfo.AosVersion {
+		if aosVersion, err = handler.storage.GetAosVersion(updateInfo.ID); err == nil {
+			if aosVersion == updateInfo.AosVersion && !reinstall {
 				return aoserrors.Errorf("component already has required Aos version: %d", updateInfo.AosVersion)
 			}
-
-			if aosVersion > updateInfo.AosVersion {
-				return aoserrors.New("wrong Aos version")
-			}
 		}
 	}
 
-	if handler.downloadDir != "" {
-		if err = os.MkdirAll(handler.downloadDir, 0o755); err != nil {
-			return aoserrors.Wrap(err)
-		}
+	downgrade, err := handler.checkDowngrade(updateInfo, aosVersion, vendorVersion)
+	if err != nil {
+		return err
 	}
 
-	urlVal, err := url.Parse(updateInfo.URL)
+	filePath, err := handler.getComponentImage(ctx, updateInfo, images)
 	if err != nil {
-		return aoserrors.Wrap(err)
+		return err
 	}
 
-	var filePath string
+	if isBundle(updateInfo) {
+		if filePath, err = handler.getBundleImage(updateInfo, filePath); err != nil {
+			return err
+		}
+	}
 
-	if urlVal.Scheme != "file" {
-		if handler.downloadDir == "" {
-			return aoserrors.New("download dir should be configured for remote image download")
+	if encryption := getUpdateAnnotations(updateInfo.Annotations).Encryption; encryption != nil {
+		if filePath, err = handler.decryptImage(updateInfo, encryption, filePath); err != nil {
+			return err
 		}
+	}
 
-		if filePath, err = image.Download(context.Background(), handler.downloadDir, updateInfo.URL); err != nil {
-			return aoserrors.Wrap(err)
+	if anchor := handler.components[updateInfo.ID].trustAnchor; anchor != nil {
+		if err = anchor.verify(updateInfo, filePath); err != nil {
+			return err
 		}
-	} else {
-		filePath = urlVal.Path
 	}
 
-	if err = image.CheckFileInfo(context.Background(), filePath, image.FileInfo{
-		Sha256: updateInfo.Sha256,
-		Sha512: updateInfo.Sha512,
-		Size:   updateInfo.Size,
-	}); err != nil {
-		return aoserrors.Wrap(err)
+	// Backend may attach wrong artifact to the component
+	if err = checkImageFormat(filePath, handler.components[updateInfo.ID].imageFormats); err != nil {
+		return err
 	}
 
-	if err = module.Prepare(filePath, updateInfo.VendorVersion, updateInfo.Annotations); err != nil {
-		return aoserrors.Wrap(err)
+	if err = prepareModule(ctx, module, filePath, updateInfo, downgrade); err != nil {
+		return err
 	}
 
+	handler.storeManifest(updateInfo)
+
 	return nil
 }
 
+func (handler *Handler) selectorMatches(selector map[string]string) (matches bool) {
+	for name, value := range selector {
+		if label, ok := handler.labels[name]; !ok || label != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (handler *Handler) skippedStatus(id, reason string) (status *umclient.ComponentStatusInfo) {
+	status = &umclient.ComponentStatusInfo{ID: id, Status: umclient.StatusInstalled, Error: reason}
+
+	if installedStatus, ok := handler.componentStatuses[id]; ok {
+		status.VendorVersion = installedStatus.VendorVersion
+		status.AosVersion = installedStatus.AosVersion
+	}
+
+	return status
+}
+
+func getUpdateAnnotations(rawAnnotations json.RawMessage) (annotations updateAnnotations) {
+	if len(rawAnnotations) == 0 {
+		return annotations
+	}
+
+	// Annotations are module specific and may have any format, ignore them if they can't be parsed
+	if err := json.Unmarshal(rawAnnotations, &annotations); err != nil {
+		log.Debugf("Can't parse update annotations: %v", err)
+	}
+
+	return annotations
+}
+
+func (handler *Handler) versionsEqual(id, version1, version2 string) bool {
+	return versionutils.Equal(handler.components[id].versionScheme, version1, version2)
+}
+
 func (handler *Handler) onPrepareState(ctx context.Context, event *fsm.Event) {
 	handler.Lock()
 	defer handler.Unlock()
 
 	var err error
 
+	if isDryRun(event) {
+		defer func() { handler.finishDryRun(err) }()
+	}
+
 	defer func() {
 		if err != nil {
 			handler.state.Error = err.Error()
@@ -716,9 +1351,28 @@ func (handler *Handler) onPrepareState(ctx context.Context, event *fsm.Event) {
 
 	componentsInfo := make(map[string]*umclient.ComponentUpdateInfo)
 
+	handler.commitUpdate()
+	handler.stopErrorRetention()
+
 	handler.state.Error = ""
+	handler.state.CommittedComponents = nil
+	handler.state.HistorySession = newHistorySession(handler.clock.Now())
 	handler.state.ComponentStatuses = make(map[string]*umclient.ComponentStatusInfo)
 	handler.state.CurrentVendorVersions = make(map[string]string)
+	handler.state.ImageHashes = make(map[string]string)
+	handler.state.ImageURLs = make(map[string]string)
+	handler.state.SkippedComponents = make(map[string]*umclient.ComponentStatusInfo)
+	handler.state.Constraints = nil
+	handler.state.PendingConfirmations = nil
+	handler.state.ConfirmationStart = nil
+	handler.resetUsage()
+	handler.resetDownloadReports()
+	handler.resetLinkEstimate()
+	handler.setHealthDecision(nil)
+
+	if err = handler.openDownloadSession(); err != nil {
+		return
+	}
 
 	infos, ok := event.Args[0].([]umclient.ComponentUpdateInfo)
 	if !ok {
@@ -730,20 +1384,74 @@ func (handler *Handler) onPrepareState(ctx context.Context, event *fsm.Event) {
 		return
 	}
 
+	if handler.state.FailurePolicy, err = handler.getSessionFailurePolicy(infos); err != nil {
+		return
+	}
+
 	for i, info := range infos {
+		annotations := getUpdateAnnotations(info.Annotations)
+
+		if !handler.selectorMatches(annotations.NodeSelector) {
+			log.WithField("id", info.ID).Info("Skip component due to node selector mismatch")
+
+			handler.state.SkippedComponents[info.ID] = handler.skippedStatus(info.ID, selectorMismatchMsg)
+
+			continue
+		}
+
+		var inRollout bool
+
+		if inRollout, err = handler.rolloutMatches(info.ID, annotations.Rollout); err != nil {
+			return
+		}
+
+		if !inRollout {
+			log.WithField("id", info.ID).Info("Skip component deferred by rollout policy")
+
+			handler.state.SkippedComponents[info.ID] = handler.skippedStatus(info.ID, rolloutDeferredMsg)
+
+			continue
+		}
+
 		componentStatus, ok := handler.componentStatuses[info.ID]
 		if !ok {
 			err = aoserrors.Errorf("component %s is not installed", info.ID)
 			return
 		}
 
+		if handler.isComponentQuarantined(info.ID) {
+			log.WithField("id", info.ID).Warn("Skip quarantined component")
+
+			handler.state.SkippedComponents[info.ID] = handler.quarantinedStatus(info.ID)
+
+			continue
+		}
+
 		if componentStatus.Status == umclient.StatusError {
 			err = aoserrors.New(componentStatus.Error)
 			return
 		}
 
+		if err = checkConstraintAnnotations(info.ID, annotations.Constraints); err != nil {
+			return
+		}
+
+		if len(annotations.Constraints) != 0 {
+			if handler.state.Constraints == nil {
+				handler.state.Constraints = make(map[string][]versionConstraint)
+			}
+
+			handler.state.Constraints[info.ID] = annotations.Constraints
+		}
+
+		if annotations.Confirmation {
+			handler.state.PendingConfirmations = append(handler.state.PendingConfirmations, info.ID)
+		}
+
 		handler.state.CurrentVendorVersions[info.ID] = handler.componentStatuses[info.ID].VendorVersion
 		componentsInfo[info.ID] = &infos[i]
+		handler.state.ImageHashes[info.ID] = hex.EncodeToString(info.Sha256)
+		handler.state.ImageURLs[info.ID] = info.URL
 		handler.state.ComponentStatuses[info.ID] = &umclient.ComponentStatusInfo{
 			ID:            info.ID,
 			VendorVersion: info.VendorVersion,
@@ -752,7 +1460,41 @@ func (handler *Handler) onPrepareState(ctx context.Context, event *fsm.Event) {
 		}
 	}
 
-	err = handler.componentOperation(func(module UpdateModule) (rebootRequired bool, err error) {
+	if err = handler.checkTargetSpace(componentsInfo); err != nil {
+		return
+	}
+
+	if err = handler.checkDownloadSpace(componentsInfo); err != nil {
+		return
+	}
+
+	if err = handler.checkLinkSpeed(componentsInfo); err != nil {
+		return
+	}
+
+	if err = handler.runHooks(eventPrepare, HookStagePre, ""); err != nil {
+		return
+	}
+
+	defer func() {
+		opError := ""
+		if err != nil {
+			opError = err.Error()
+		}
+
+		if hookErr := handler.runHooks(eventPrepare, HookStagePost, opError); hookErr != nil {
+			err = hookErr
+		}
+	}()
+
+	handler.startProgress()
+	defer handler.stopProgress()
+
+	images := handler.downloadImages(componentsInfo)
+
+	if err = handler.componentOperation(eventPrepare, func(
+		ctx context.Context, module UpdateModule,
+	) (rebootRequired bool, err error) {
 		updateInfo, ok := componentsInfo[module.GetID()]
 		if !ok {
 			return false, aoserrors.Errorf("update info for %s component not found", module.GetID())
@@ -765,20 +1507,49 @@ func (handler *Handler) onPrepareState(ctx context.Context, event *fsm.Event) {
 			"url":           updateInfo.URL,
 		}).Debug("Prepare component")
 
-		return false, handler.prepareComponent(module, updateInfo)
-	}, true)
+		return false, handler.prepareComponent(ctx, module, updateInfo, images)
+	}, !handler.isBestEffort()); err != nil {
+		err = handler.continueBestEffort(eventPrepare, err)
+	}
 }
 
 func (handler *Handler) onUpdateState(ctx context.Context, event *fsm.Event) {
+	handler.waitUpdateBlockers("update")
+	handler.waitConfirmations()
+
 	handler.Lock()
 	defer handler.Unlock()
 
 	handler.state.Error = ""
 
-	if err := handler.componentOperation(func(module UpdateModule) (rebootRequired bool, err error) {
+	if err := handler.runHooks(eventUpdate, HookStagePre, ""); err != nil {
+		handler.state.Error = err.Error()
+		handler.fsm.SetState(stateFailed)
+
+		return
+	}
+
+	defer func() {
+		if err := handler.runHooks(eventUpdate, HookStagePost, handler.state.Error); err != nil {
+			handler.state.Error = err.Error()
+			handler.fsm.SetState(stateFailed)
+		}
+	}()
+
+	if err := handler.createConfigSnapshot(); err != nil {
+		log.Errorf("Can't create config snapshot: %s", aoserrors.Wrap(err))
+		handler.state.Error = err.Error()
+		handler.fsm.SetState(stateFailed)
+
+		return
+	}
+
+	if err := handler.updateComponents(func(
+		ctx context.Context, module UpdateModule,
+	) (rebootRequired bool, err error) {
 		log.WithFields(log.Fields{"id": module.GetID()}).Debug("Update component")
 
-		rebootRequired, err = module.Update()
+		rebootRequired, err = module.Update(ctx)
 		if err != nil {
 			return false, aoserrors.Wrap(err)
 		}
@@ -789,29 +1560,85 @@ func (handler *Handler) onUpdateState(ctx context.Context, event *fsm.Event) {
 				return false, aoserrors.Wrap(err)
 			}
 
-			if vendorVersion != handler.state.ComponentStatuses[module.GetID()].VendorVersion {
+			if !handler.versionsEqual(module.GetID(), vendorVersion,
+				handler.state.ComponentStatuses[module.GetID()].VendorVersion) {
 				return false, aoserrors.Errorf("versions mismatch in request %s and updated module %s",
 					handler.state.ComponentStatuses[module.GetID()].VendorVersion, vendorVersion)
 			}
+
+			// Verification is done after final update call i.e. after reboot if it was required
+			if verifier := handler.components[module.GetID()].verifier; verifier != nil {
+				if err = verifier.verify(module.GetID()); err != nil {
+					return false, err
+				}
+			}
 		}
 
 		return rebootRequired, aoserrors.Wrap(err)
-	}, true); err != nil {
+	}, !handler.isBestEffort()); err != nil {
+		if err = handler.continueBestEffort(eventUpdate, err); err != nil {
+			handler.state.Error = err.Error()
+			handler.fsm.SetState(stateFailed)
+
+			return
+		}
+	}
+
+	if err := handler.migrateData(); err != nil {
 		handler.state.Error = err.Error()
 		handler.fsm.SetState(stateFailed)
 	}
 }
 
 func (handler *Handler) onApplyState(ctx context.Context, event *fsm.Event) {
+	handler.waitApplySchedule()
+	handler.waitUpdateBlockers("apply")
+
+	healthErr := handler.waitHealthChecks()
+
 	handler.Lock()
 	defer handler.Unlock()
 
 	handler.state.Error = ""
 
-	if err := handler.componentOperation(func(module UpdateModule) (rebootRequired bool, err error) {
+	if healthErr != nil {
+		handler.revertUnhealthy(healthErr)
+
+		return
+	}
+
+	if err := handler.checkConstraints(); err != nil {
+		log.Warnf("Revert inconsistent update: %s", err)
+
+		handler.revertUpdate(err)
+
+		return
+	}
+
+	if len(handler.healthChecks) != 0 && handler.checkStopped() == nil {
+		handler.setHealthDecision(&HealthDecision{Time: handler.clock.Now()})
+	}
+
+	if err := handler.runHooks(eventApply, HookStagePre, ""); err != nil {
+		log.Warnf("Revert update due to failed hook: %s", err)
+
+		handler.revertUpdate(err)
+
+		return
+	}
+
+	defer func() {
+		if err := handler.runHooks(eventApply, HookStagePost, handler.state.Error); err != nil {
+			handler.state.Error = err.Error()
+		}
+	}()
+
+	if err := handler.componentOperation(eventApply, func(
+		ctx context.Context, module UpdateModule,
+	) (rebootRequired bool, err error) {
 		log.WithFields(log.Fields{"id": module.GetID()}).Debug("Apply component")
 
-		if rebootRequired, err = module.Apply(); err != nil {
+		if rebootRequired, err = module.Apply(ctx); err != nil {
 			return rebootRequired, aoserrors.Wrap(err)
 		}
 
@@ -837,38 +1664,135 @@ func (handler *Handler) onRevertState(ctx context.Context, event *fsm.Event) {
 
 	handler.state.Error = ""
 
-	if err := handler.componentOperation(func(module UpdateModule) (rebootRequired bool, err error) {
-		log.WithFields(log.Fields{"id": module.GetID()}).Debug("Revert component")
-		if rebootRequired, err = module.Revert(); err != nil {
-			return rebootRequired, aoserrors.Wrap(err)
-		}
+	// Revert is not skipped if pre hook fails
+	if err := handler.runHooks(eventRevert, HookStagePre, ""); err != nil {
+		handler.state.Error = err.Error()
+	}
 
-		return rebootRequired, nil
-	}, false); err != nil {
+	// Restore config before modules revert as module reboot may restart the system
+	if err := handler.restoreConfigSnapshot(); err != nil {
+		log.Errorf("Can't restore config snapshot: %s", aoserrors.Wrap(err))
+		handler.state.Error = err.Error()
+	}
+
+	if err := handler.revertComponents(); err != nil {
 		log.Errorf("Can't revert update: %s", aoserrors.Wrap(err))
 		handler.state.Error = err.Error()
 	}
+
+	if err := handler.runHooks(eventRevert, HookStagePost, handler.state.Error); err != nil {
+		handler.state.Error = err.Error()
+	}
+}
+
+func (handler *Handler) revertComponents() (err error) {
+	undoErr := handler.undoDataMigrations()
+
+	if err = handler.componentOperation(eventRevert, revertComponent, false); err != nil {
+		return err
+	}
+
+	return undoErr
+}
+
+func revertComponent(ctx context.Context, module UpdateModule) (rebootRequired bool, err error) {
+	log.WithFields(log.Fields{"id": module.GetID()}).Debug("Revert component")
+
+	if rebootRequired, err = module.Revert(ctx); err != nil {
+		return rebootRequired, aoserrors.Wrap(err)
+	}
+
+	return rebootRequired, nil
+}
+
+// revertUpdate reverts update instead of apply and reports the reason for the components. It is called under
+// handler lock.
+func (handler *Handler) revertUpdate(reason error) {
+	if err := handler.restoreConfigSnapshot(); err != nil {
+		log.Errorf("Can't restore config snapshot: %s", aoserrors.Wrap(err))
+	}
+
+	if err := handler.revertComponents(); err != nil {
+		log.Errorf("Can't revert update: %s", aoserrors.Wrap(err))
+
+		handler.state.Error = err.Error()
+		handler.fsm.SetState(stateFailed)
+
+		return
+	}
+
+	for id, componentStatus := range handler.state.ComponentStatuses {
+		// Excluded components keep error of their failed operation
+		if handler.isExcluded(id) {
+			continue
+		}
+
+		componentStatus.Status = umclient.StatusError
+		componentStatus.Error = reason.Error()
+	}
+
+	handler.state.Error = updateRevertedMsg + ": " + reason.Error()
 }
 
 func (handler *Handler) sendEvent(event string, args ...interface{}) (err error) {
+	if handler.isQuarantined() {
+		return aoserrors.Errorf("error sending event %s: update handler is quarantined", event)
+	}
+
 	if handler.fsm.Cannot(event) {
 		return aoserrors.Errorf("error sending event %s in state: %s", event, handler.fsm.Current())
 	}
 
+	if !handler.startOperation() {
+		return aoserrors.Errorf("error sending event %s: %s", event, closedMsg)
+	}
+
+	handler.setCurrentOperation(event)
+
+	cancelable := isCancelableEvent(event)
+
+	if cancelable {
+		handler.startCancelableOperation()
+	}
+
 	if err = handler.fsm.Event(context.Background(), event, args...); err != nil {
 		var fsmError fsm.AsyncError
 
 		if !errors.As(err, &fsmError) {
+			if cancelable {
+				handler.finishCancelableOperation()
+			}
+
+			handler.clearCurrentOperation(event)
+			handler.finishOperation()
+
 			return aoserrors.Wrap(err)
 		}
 
 		go func() {
+			defer handler.finishOperation()
+
 			if err := handler.fsm.Transition(); err != nil {
 				log.Errorf("Error transition event %s: %s", event, aoserrors.Wrap(err))
 			}
+
+			handler.clearCurrentOperation(event)
+
+			if cancelable {
+				handler.revertCanceled()
+			}
 		}()
+
+		return nil
+	}
+
+	if cancelable {
+		handler.finishCancelableOperation()
 	}
 
+	handler.clearCurrentOperation(event)
+	handler.finishOperation()
+
 	return nil
 }
 
@@ -18,9 +18,13 @@
 package updatehandler_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
 	"os/exec"
@@ -38,6 +42,8 @@ import (
 	"github.com/aoscloud/aos_updatemanager/config"
 	"github.com/aoscloud/aos_updatemanager/umclient"
 	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+	"github.com/aoscloud/aos_updatemanager/utils/updatehistory"
 )
 
 /*******************************************************************************
@@ -45,12 +51,15 @@ import (
  ******************************************************************************/
 
 const (
-	opInit    = "init"
-	opPrepare = "prepare"
-	opUpdate  = "update"
-	opApply   = "apply"
-	opRevert  = "revert"
-	opReboot  = "reboot"
+	opInit      = "init"
+	opPrepare   = "prepare"
+	opUpdate    = "update"
+	opApply     = "apply"
+	opRevert    = "revert"
+	opReboot    = "reboot"
+	opMigrate   = "migrate"
+	opUndo      = "undoMigration"
+	opDowngrade = "prepareDowngrade"
 )
 
 const versionExistMsg = "component already has required vendor version: "
@@ -61,8 +70,10 @@ const versionExistMsg = "component already has required vendor version: "
 
 type testStorage struct {
 	sync.Mutex
-	updateState []byte
-	aosVersions map[string]uint64
+	updateState  []byte
+	aosVersions  map[string]uint64
+	moduleStates map[string][]byte
+	history      []updatehistory.Entry
 }
 
 type testModule struct {
@@ -70,6 +81,33 @@ type testModule struct {
 	vendorVersion  string
 	rebootRequired bool
 	status         error
+	versionBlock   chan struct{}
+	imagePath      string
+	closed         bool
+	metadata       map[string]string
+	metadataErr    error
+	capabilities   umclient.ComponentCapabilities
+	waitCancel     bool
+	updateBlock    chan struct{}
+	updateDelay    time.Duration
+	maintenance    []string
+	migrate        bool
+	migrateErr     error
+	targetSpace    uint64
+	rebootType     string
+	rebootedWith   string
+	rebootDeadline time.Time
+	rebootBlock    chan struct{}
+	transientErrs  int
+	storage        updatehandler.ModuleStorage
+}
+
+type testKeyProvider struct {
+	keys map[string][]byte
+}
+
+type testHook struct {
+	FailOn string `json:"failOn"`
 }
 
 type orderInfo struct {
@@ -77,12 +115,21 @@ type orderInfo struct {
 	op string
 }
 
+type testHandlerOptions struct {
+	storage *testStorage
+	modules map[string]*testModule
+}
+
+type testHandlerOption func(options *testHandlerOptions)
+
 /*******************************************************************************
  * Vars
  ******************************************************************************/
 
 var tmpDir string
 
+var updateGolden = flag.Bool("update", false, "update golden files")
+
 var components map[string]*testModule
 
 var cfg *config.Config
@@ -91,6 +138,10 @@ var order []orderInfo
 
 var mutex sync.Mutex
 
+var keyProvider = &testKeyProvider{}
+
+var hookCalls []string
+
 /*******************************************************************************
  * Init
  ******************************************************************************/
@@ -125,9 +176,29 @@ func TestMain(m *testing.M) {
 				components[id] = &testModule{id: id}
 			}
 
+			components[id].storage = storage
+
 			return components[id], nil
 		})
 
+	updatehandler.RegisterKeyProvider("testkeys",
+		func(params json.RawMessage) (provider updatehandler.KeyProvider, err error) {
+			return keyProvider, nil
+		})
+
+	updatehandler.RegisterHook("testhook",
+		func(params json.RawMessage) (hook updatehandler.Hook, err error) {
+			hook = &testHook{}
+
+			if params != nil {
+				if err = json.Unmarshal(params, hook); err != nil {
+					return nil, aoserrors.Wrap(err)
+				}
+			}
+
+			return hook, nil
+		})
+
 	cfg = &config.Config{
 		DownloadDir: path.Join(tmpDir, "downloadDir"),
 		UpdateModules: []config.ModuleConfig{
@@ -163,14 +234,10 @@ func TestMain(m *testing.M) {
  ******************************************************************************/
 
 func TestUpdate(t *testing.T) {
-	components = make(map[string]*testModule)
 	storage := newTestStorage()
 	order = nil
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
+	handler := newTestHandler(t, cfg, withStorage(storage))
 
 	currentStatus := umclient.Status{
 		State: umclient.StateIdle,
@@ -221,15 +288,11 @@ func TestUpdate(t *testing.T) {
 
 	// Reboot
 
-	handler.Close()
+	handler.Close(context.Background())
 
-	components = make(map[string]*testModule)
 	order = nil
 
-	if handler, err = updatehandler.New(cfg, storage, storage); err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler = newTestHandler(t, cfg, withStorage(storage))
 
 	testOperation(t, handler, handler.Registered, &newStatus,
 		map[string][]string{"id1": {opInit}, "id2": {opInit}, "id3": {opInit}}, nil)
@@ -255,15 +318,9 @@ func TestUpdate(t *testing.T) {
 }
 
 func TestPrepareFail(t *testing.T) {
-	components = make(map[string]*testModule)
-	storage := newTestStorage()
 	order = nil
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler := newTestHandler(t, cfg)
 
 	currentStatus := umclient.Status{
 		State: umclient.StateIdle,
@@ -337,15 +394,9 @@ func TestPrepareFail(t *testing.T) {
 }
 
 func TestUpdateFailed(t *testing.T) {
-	components = make(map[string]*testModule)
-	storage := newTestStorage()
 	order = nil
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler := newTestHandler(t, cfg)
 
 	currentStatus := umclient.Status{
 		State: umclient.StateIdle,
@@ -438,15 +489,9 @@ func TestUpdateFailed(t *testing.T) {
 }
 
 func TestUpdateSameVendorVersion(t *testing.T) {
-	components = make(map[string]*testModule)
-	storage := newTestStorage()
 	order = nil
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler := newTestHandler(t, cfg)
 
 	currentStatus := umclient.Status{
 		State: umclient.StateIdle,
@@ -518,8 +563,52 @@ func TestUpdateSameVendorVersion(t *testing.T) {
 	testOperation(t, handler, handler.RevertUpdate, &newStatus, nil, nil)
 }
 
+func TestReinstallSameVersion(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1", vendorVersion: "1.0"}}
+	order = nil
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir:   cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", VendorVersion: "1.0", Status: umclient.StatusInstalled}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "1.0")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	infos[0].Annotations = json.RawMessage(`{"reinstall": true}`)
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", VendorVersion: "1.0", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling,
+	})
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus,
+		map[string][]string{"id1": {opPrepare}}, nil)
+
+	newStatus.State = umclient.StateUpdated
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus, nil, nil)
+
+	testOperation(t, handler, handler.ApplyUpdate, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", VendorVersion: "1.0", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalled},
+		},
+	}, map[string][]string{"id1": {opPrepare, opUpdate, opApply}}, nil)
+}
+
 func TestUpdateSameAosVersion(t *testing.T) {
-	components = make(map[string]*testModule)
 	storage := newTestStorage()
 
 	currentStatus := umclient.Status{
@@ -537,11 +626,7 @@ func TestUpdateSameAosVersion(t *testing.T) {
 		}
 	}
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler := newTestHandler(t, cfg, withStorage(storage))
 
 	infos, err := createUpdateInfos(currentStatus.Components, "")
 	if err != nil {
@@ -587,7 +672,6 @@ func TestUpdateSameAosVersion(t *testing.T) {
 
 func TestUpdateWrongVersion(t *testing.T) {
 	// test lower Aos version
-	components = make(map[string]*testModule)
 	storage := newTestStorage()
 
 	currentStatus := umclient.Status{
@@ -605,11 +689,7 @@ func TestUpdateWrongVersion(t *testing.T) {
 		}
 	}
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler := newTestHandler(t, cfg, withStorage(storage))
 
 	infos, err := createUpdateInfos(currentStatus.Components, "")
 	if err != nil {
@@ -666,15 +746,9 @@ func TestUpdateWrongVersion(t *testing.T) {
 }
 
 func TestUpdateBadImage(t *testing.T) {
-	components = make(map[string]*testModule)
-	storage := newTestStorage()
 	order = nil
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler := newTestHandler(t, cfg)
 
 	currentStatus := umclient.Status{
 		State: umclient.StateIdle,
@@ -756,15 +830,9 @@ func TestUpdatePriority(t *testing.T) {
 		},
 	}
 
-	components = make(map[string]*testModule)
-	storage := newTestStorage()
 	order = nil
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler := newTestHandler(t, cfg)
 
 	currentStatus := umclient.Status{
 		State: umclient.StateIdle,
@@ -856,6 +924,159 @@ func TestUpdatePriority(t *testing.T) {
 		})
 }
 
+func TestExternalTarget(t *testing.T) {
+	cfg := &config.Config{
+		DownloadDir: path.Join(tmpDir, "downloadDir"),
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule", ExternalTarget: true, RebootGroup: "soc"},
+		},
+	}
+
+	components = make(map[string]*testModule)
+
+	if _, err := updatehandler.New(cfg, newTestStorage(), nil); err == nil {
+		t.Error("Error expected for external target in reboot group")
+	}
+
+	cfg.UpdateModules[1].RebootGroup = ""
+
+	order = nil
+
+	handler := newTestHandler(t, cfg)
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus,
+		map[string][]string{"id1": {opInit}, "id2": {opInit}}, nil)
+
+	// Prepare
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+
+	for _, info := range infos {
+		newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+			ID:            info.ID,
+			AosVersion:    info.AosVersion,
+			VendorVersion: info.VendorVersion,
+			Status:        umclient.StatusInstalling,
+		})
+	}
+
+	newStatus.State = umclient.StatePrepared
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus,
+		map[string][]string{"id1": {opPrepare}, "id2": {opPrepare}}, nil)
+
+	// Update: reboot request of external target is ignored
+
+	for _, component := range components {
+		component.rebootRequired = true
+	}
+
+	newStatus.State = umclient.StateUpdated
+	order = nil
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus,
+		map[string][]string{"id1": {opUpdate, opReboot, opUpdate}, "id2": {opUpdate}}, nil)
+}
+
+func TestNodeSelector(t *testing.T) {
+	cfg := &config.Config{
+		DownloadDir: path.Join(tmpDir, "downloadDir"),
+		Labels:      map[string]string{"hwRevision": "2", "region": "eu"},
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+			{ID: "id3", Plugin: "testmodule"},
+		},
+	}
+
+	order = nil
+
+	handler := newTestHandler(t, cfg)
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+			{ID: "id3", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	// Prepare: id2 matches selector, id3 doesn't
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	infos[1].Annotations = json.RawMessage(`{"nodeSelector": {"hwRevision": "2"}}`)
+	infos[2].Annotations = json.RawMessage(`{"nodeSelector": {"hwRevision": "1", "region": "eu"}}`)
+
+	// Skipped component is reported by skipped entry only
+
+	newStatus := umclient.Status{Components: append([]umclient.ComponentStatusInfo{}, currentStatus.Components[:2]...)}
+
+	for _, info := range infos[:2] {
+		newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+			ID:            info.ID,
+			AosVersion:    info.AosVersion,
+			VendorVersion: info.VendorVersion,
+			Status:        umclient.StatusInstalling,
+		})
+	}
+
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id3", Status: umclient.StatusInstalled, Error: "skipped: selector mismatch",
+	})
+
+	newStatus.State = umclient.StatePrepared
+	order = nil
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus,
+		map[string][]string{"id1": {opPrepare}, "id2": {opPrepare}, "id3": nil}, nil)
+
+	// Update
+
+	newStatus.State = umclient.StateUpdated
+	order = nil
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus,
+		map[string][]string{"id1": {opUpdate}, "id2": {opUpdate}, "id3": nil}, nil)
+
+	// Apply
+
+	finalStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalled},
+			{ID: "id2", AosVersion: infos[1].AosVersion, Status: umclient.StatusInstalled},
+			{ID: "id3", Status: umclient.StatusInstalled},
+		},
+	}
+
+	order = nil
+
+	testOperation(t, handler, handler.ApplyUpdate, &finalStatus,
+		map[string][]string{"id1": {opApply}, "id2": {opApply}, "id3": nil}, nil)
+}
+
 func TestVendorVersionInUpdate(t *testing.T) {
 	components = map[string]*testModule{
 		"id1": {id: "id1", vendorVersion: "1.0"},
@@ -865,10 +1086,7 @@ func TestVendorVersionInUpdate(t *testing.T) {
 	storage := newTestStorage()
 	order = nil
 
-	handler, err := updatehandler.New(cfg, storage, storage)
-	if err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
+	handler := newTestHandler(t, cfg, withStorage(storage), withModules(components))
 
 	currentStatus := umclient.Status{
 		State: umclient.StateIdle,
@@ -917,25 +1135,220 @@ func TestVendorVersionInUpdate(t *testing.T) {
 
 	// Reboot
 
-	handler.Close()
+	handler.Close(context.Background())
 
 	order = nil
 
-	if handler, err = updatehandler.New(cfg, storage, storage); err != nil {
-		t.Fatalf("Can't create update handler: %s", err)
-	}
-	defer handler.Close()
+	handler = newTestHandler(t, cfg, withStorage(storage), withModules(components))
 
 	testOperation(t, handler, handler.Registered, &newStatus,
 		map[string][]string{"id1": {opInit}, "id2": {opInit}, "id3": {opInit}}, nil)
 }
 
+func TestAsyncVersionRefresh(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1", vendorVersion: "1.0"}}
+
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withModules(components))
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	currentStatus := umclient.Status{
+		State:      umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{{ID: "id1", Status: umclient.StatusInstalled, VendorVersion: "1.0"}},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "2.0")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	newStatus := currentStatus
+	newStatus.State = umclient.StatePrepared
+	newStatus.Components = append(newStatus.Components, umclient.ComponentStatusInfo{
+		ID: "id1", AosVersion: infos[0].AosVersion, VendorVersion: "2.0", Status: umclient.StatusInstalling,
+	})
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, &newStatus, nil, nil)
+
+	newStatus.State = umclient.StateUpdated
+	components["id1"].vendorVersion = "2.0"
+
+	testOperation(t, handler, handler.StartUpdate, &newStatus, nil, nil)
+
+	// Slow version provider should not stall transition to idle
+
+	components["id1"].versionBlock = make(chan struct{})
+
+	handler.ApplyUpdate()
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Minute)
+
+	if err = waitForStatus(handler, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled, VendorVersion: "1.0", AosVersion: infos[0].AosVersion},
+		},
+	}); err != nil {
+		t.Errorf("Wait for status failed: %s", err)
+	}
+
+	close(components["id1"].versionBlock)
+
+	if err = waitForStatus(handler, &umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled, VendorVersion: "2.0", AosVersion: infos[0].AosVersion},
+		},
+	}); err != nil {
+		t.Errorf("Wait for status failed: %s", err)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1", vendorVersion: "1.0"}}
+	storage := newTestStorage()
+
+	storage.updateState = []byte(`{"updateState": "idle", "componentStatuses": {` +
+		`"id1": {"ID": "id1", "AosVersion": 2, "Status": 2, "Error": "update failed"}, ` +
+		`"id2": {"ID": "id2", "AosVersion": 1, "Status": 2, "Error": "update failed"}}}`)
+
+	if err := storage.SetAosVersion("id1", 1); err != nil {
+		t.Fatalf("Can't set Aos version: %s", err)
+	}
+
+	handler := newTestHandler(t, &config.Config{
+		UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}},
+	}, withStorage(storage), withModules(components))
+
+	components["id1"].vendorVersion = "1.1"
+
+	status := handler.Inventory()
+
+	if status.State != umclient.StateIdle || len(status.Components) != 2 ||
+		status.Components[0] != (umclient.ComponentStatusInfo{
+			ID: "id1", VendorVersion: "1.1", AosVersion: 1, Status: umclient.StatusInstalled,
+		}) ||
+		status.Components[1].Status != umclient.StatusError {
+		t.Errorf("Wrong inventory status: %v", status)
+	}
+
+	// Status of not configured component should be removed
+
+	if updateState, _ := storage.GetUpdateState(); strings.Contains(string(updateState), `"id2"`) {
+		t.Errorf("Not configured component status is not removed: %s", updateState)
+	}
+
+	select {
+	case status := <-handler.StatusChannel():
+		t.Errorf("Unexpected status sent: %v", status)
+
+	default:
+	}
+}
+
+func TestStatusOrder(t *testing.T) {
+	components = map[string]*testModule{
+		"rootfs":     {id: "rootfs", vendorVersion: "1.0"},
+		"bootloader": {id: "bootloader", vendorVersion: "2.0"},
+		"dom0":       {id: "dom0", vendorVersion: "3.0"},
+		"firmware":   {id: "firmware", vendorVersion: "4.0"},
+	}
+	storage := newTestStorage()
+
+	// rootfs is updating, dom0 update status equals installed one, firmware is skipped
+
+	storage.updateState = []byte(`{"updateState": "prepared", ` +
+		`"currentVendorVersions": {"rootfs": "1.0", "bootloader": "2.0", "dom0": "3.0", "firmware": "4.0"}, ` +
+		`"componentStatuses": {` +
+		`"rootfs": {"ID": "rootfs", "VendorVersion": "1.1", "AosVersion": 2, "Status": 1}, ` +
+		`"dom0": {"ID": "dom0", "VendorVersion": "3.0", "AosVersion": 1, "Status": 0}}, ` +
+		`"skippedComponents": {` +
+		`"firmware": {"ID": "firmware", "VendorVersion": "4.0", "AosVersion": 1, "Status": 0, ` +
+		`"Error": "skipped: selector mismatch"}}}`)
+
+	moduleConfigs := make([]config.ModuleConfig, 0, len(components))
+
+	for id := range components {
+		if err := storage.SetAosVersion(id, 1); err != nil {
+			t.Fatalf("Can't set Aos version: %s", err)
+		}
+
+		moduleConfigs = append(moduleConfigs, config.ModuleConfig{ID: id, Plugin: "testmodule"})
+	}
+
+	handler := newTestHandler(t, &config.Config{InstanceID: "instance1", UpdateModules: moduleConfigs},
+		withStorage(storage), withModules(components))
+
+	for i := 0; i < 3; i++ {
+		handler.Registered()
+
+		status := <-handler.StatusChannel()
+
+		data, err := json.MarshalIndent(status, "", "    ")
+		if err != nil {
+			t.Fatalf("Can't marshal status: %s", err)
+		}
+
+		if err = compareGolden("status.golden", append(data, '\n')); err != nil {
+			t.Errorf("Wrong status: %s", err)
+		}
+	}
+}
+
 /*******************************************************************************
  * Private
  ******************************************************************************/
 
+// newTestHandler creates update handler with new test storage and modules unless they are provided by options. The
+// handler is closed on test cleanup.
+func newTestHandler(t *testing.T, cfg *config.Config, opts ...testHandlerOption) (handler *updatehandler.Handler) {
+	t.Helper()
+
+	options := testHandlerOptions{modules: make(map[string]*testModule)}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.storage == nil {
+		options.storage = newTestStorage()
+	}
+
+	components = options.modules
+
+	handler, err := updatehandler.New(cfg, options.storage, options.storage)
+	if err != nil {
+		t.Fatalf("Can't create update handler: %s", err)
+	}
+
+	t.Cleanup(func() { handler.Close(context.Background()) })
+
+	return handler
+}
+
+// withStorage creates handler with the storage, e.g. to restart handler with persisted state.
+func withStorage(storage *testStorage) testHandlerOption {
+	return func(options *testHandlerOptions) {
+		options.storage = storage
+	}
+}
+
+// withModules creates handler with the test modules instead of new ones.
+func withModules(modules map[string]*testModule) testHandlerOption {
+	return func(options *testHandlerOptions) {
+		options.modules = modules
+	}
+}
+
 func newTestStorage() (storage *testStorage) {
-	return &testStorage{aosVersions: make(map[string]uint64)}
+	return &testStorage{aosVersions: make(map[string]uint64), moduleStates: make(map[string][]byte)}
 }
 
 func (storage *testStorage) SetUpdateState(state []byte) (err error) {
@@ -974,16 +1387,40 @@ func (storage *testStorage) GetModuleState(id string) (state []byte, err error)
 	storage.Lock()
 	defer storage.Unlock()
 
-	return []byte("valid"), nil
+	return storage.moduleStates[id], nil
 }
 
 func (storage *testStorage) SetModuleState(id string, state []byte) (err error) {
 	storage.Lock()
 	defer storage.Unlock()
 
+	storage.moduleStates[id] = state
+
 	return nil
 }
 
+func (storage *testStorage) AddHistoryEntry(entry updatehistory.Entry) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	storage.history = append(storage.history, entry)
+
+	return nil
+}
+
+func (storage *testStorage) GetHistoryEntries(session string) (entries []updatehistory.Entry, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
+	for _, entry := range storage.history {
+		if session == "" || entry.Session == session {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
 func (module *testModule) GetID() (id string) {
 	return module.id
 }
@@ -999,13 +1436,62 @@ func (module *testModule) Init() (err error) {
 	return aoserrors.Wrap(err)
 }
 
+func (module *testModule) GetTargetSpace() (space uint64, err error) {
+	// Target space is not limited if not set
+	if module.targetSpace == 0 {
+		return math.MaxUint64, nil
+	}
+
+	return module.targetSpace, nil
+}
+
 func (module *testModule) GetVendorVersion() (version string, err error) {
+	if module.versionBlock != nil {
+		<-module.versionBlock
+	}
+
 	return module.vendorVersion, nil
 }
 
-func (module *testModule) Prepare(imagePath string, vendorVersion string, annotations json.RawMessage) (err error) {
+func (hook *testHook) Run(ctx context.Context, info updatehandler.HookInfo) (err error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	call := info.Stage + " " + info.Event
+
+	hookCalls = append(hookCalls, call)
+
+	if call == hook.FailOn {
+		return aoserrors.New("hook failed")
+	}
+
+	return nil
+}
+
+func (provider *testKeyProvider) GetKey(keyID string) (key []byte, err error) {
+	key, ok := provider.keys[keyID]
+	if !ok {
+		return nil, aoserrors.Errorf("key %s not found", keyID)
+	}
+
+	return append([]byte(nil), key...), nil
+}
+
+func (provider *testKeyProvider) Close() (err error) {
+	return nil
+}
+
+func (module *testModule) Prepare(
+	ctx context.Context, imagePath string, vendorVersion string, annotations json.RawMessage,
+) (err error) {
 	err = module.status
 	module.status = nil
+	module.imagePath = imagePath
+
+	if module.transientErrs > 0 {
+		module.transientErrs--
+		err = updatehandler.NewTransientError(aoserrors.New("target is not reachable"))
+	}
 
 	mutex.Lock()
 	order = append(order, orderInfo{id: module.id, op: opPrepare})
@@ -1014,21 +1500,62 @@ func (module *testModule) Prepare(imagePath string, vendorVersion string, annota
 	return aoserrors.Wrap(err)
 }
 
-func (module *testModule) Update() (rebootRequired bool, err error) {
+func (module *testModule) PrepareDowngrade(
+	ctx context.Context, imagePath string, vendorVersion string, annotations json.RawMessage,
+) (err error) {
+	module.imagePath = imagePath
+
+	mutex.Lock()
+	order = append(order, orderInfo{id: module.id, op: opDowngrade})
+	mutex.Unlock()
+
+	return nil
+}
+
+func (module *testModule) Update(ctx context.Context) (rebootRequired bool, err error) {
+	if module.updateDelay != 0 {
+		delay := module.updateDelay
+		module.updateDelay = 0
+
+		select {
+		case <-time.After(delay):
+
+		case <-ctx.Done():
+			return false, aoserrors.Wrap(ctx.Err())
+		}
+	}
+
 	rebootRequired = module.rebootRequired
 	module.rebootRequired = false
 
 	err = module.status
 	module.status = nil
 
+	if module.transientErrs > 0 {
+		module.transientErrs--
+		err = updatehandler.NewTransientError(aoserrors.New("target is not reachable"))
+	}
+
 	mutex.Lock()
 	order = append(order, orderInfo{id: module.id, op: opUpdate})
 	mutex.Unlock()
 
+	if module.waitCancel {
+		module.waitCancel = false
+
+		<-ctx.Done()
+
+		return false, aoserrors.Wrap(ctx.Err())
+	}
+
+	if module.updateBlock != nil {
+		<-module.updateBlock
+	}
+
 	return rebootRequired, err
 }
 
-func (module *testModule) Apply() (rebootRequired bool, err error) {
+func (module *testModule) Apply(ctx context.Context) (rebootRequired bool, err error) {
 	rebootRequired = module.rebootRequired
 	module.rebootRequired = false
 
@@ -1042,7 +1569,7 @@ func (module *testModule) Apply() (rebootRequired bool, err error) {
 	return rebootRequired, err
 }
 
-func (module *testModule) Revert() (rebootRequired bool, err error) {
+func (module *testModule) Revert(ctx context.Context) (rebootRequired bool, err error) {
 	rebootRequired = module.rebootRequired
 	module.rebootRequired = false
 
@@ -1056,7 +1583,7 @@ func (module *testModule) Revert() (rebootRequired bool, err error) {
 	return rebootRequired, err
 }
 
-func (module *testModule) Reboot() (err error) {
+func (module *testModule) Reboot(ctx context.Context) (err error) {
 	err = module.status
 	module.status = nil
 
@@ -1064,12 +1591,84 @@ func (module *testModule) Reboot() (err error) {
 	order = append(order, orderInfo{id: module.id, op: opReboot})
 	mutex.Unlock()
 
+	if module.rebootBlock != nil {
+		<-module.rebootBlock
+	}
+
 	return aoserrors.Wrap(err)
 }
 
-func (module *testModule) Close() (err error) {
+func (module *testModule) GetRebootType() (rebootType string) {
+	return module.rebootType
+}
+
+func (module *testModule) RebootWithType(ctx context.Context, rebootType string) (err error) {
+	mutex.Lock()
+	module.rebootedWith = rebootType
+	mutex.Unlock()
+
+	return module.Reboot(ctx)
+}
+
+func (module *testModule) IsRebootRequired(vendorVersion string, annotations json.RawMessage) (bool, error) {
+	return module.rebootRequired, nil
+}
+
+func (module *testModule) GetRebootDeadline() (deadline time.Time) {
+	return module.rebootDeadline
+}
+
+func (module *testModule) GetMetadata() (metadata map[string]string, err error) {
+	return module.metadata, module.metadataErr
+}
+
+func (module *testModule) Capabilities() (capabilities umclient.ComponentCapabilities, err error) {
+	return module.capabilities, nil
+}
+
+func (module *testModule) GetMaintenanceActions() (actions []string) {
+	return module.maintenance
+}
+
+func (module *testModule) RunMaintenance(ctx context.Context, action string) (err error) {
+	mutex.Lock()
+	order = append(order, orderInfo{id: module.id, op: action})
+	mutex.Unlock()
+
+	return nil
+}
+
+func (module *testModule) MigrateData(ctx context.Context, fromVersion, toVersion string) (err error) {
+	if !module.migrate {
+		return nil
+	}
+
+	err = module.migrateErr
+	module.migrateErr = nil
+
+	mutex.Lock()
+	order = append(order, orderInfo{id: module.id, op: opMigrate})
+	mutex.Unlock()
+
+	return err
+}
+
+func (module *testModule) UndoDataMigration(ctx context.Context, fromVersion, toVersion string) (err error) {
+	if !module.migrate {
+		return nil
+	}
+
+	mutex.Lock()
+	order = append(order, orderInfo{id: module.id, op: opUndo})
+	mutex.Unlock()
+
+	return nil
+}
+
+func (module *testModule) Close(ctx context.Context) (err error) {
 	err = module.status
 	module.status = nil
+	module.closed = true
 
 	return aoserrors.Wrap(err)
 }
@@ -1095,6 +1694,14 @@ func checkComponentOps(compOps map[string][]string) (err error) {
 	return nil
 }
 
+// resetOrder resets recorded component operations while module operations may still run.
+func resetOrder() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	order = nil
+}
+
 func waitForStatus(handler *updatehandler.Handler, expectedStatus *umclient.Status) (err error) {
 	select {
 	case <-time.After(5 * time.Second):
@@ -1105,41 +1712,66 @@ func waitForStatus(handler *updatehandler.Handler, expectedStatus *umclient.Stat
 			return nil
 		}
 
-		if currentStatus.State != expectedStatus.State {
-			return aoserrors.Errorf("wrong current state: %s", currentStatus.State)
-		}
+		return compareStatus(*expectedStatus, currentStatus)
+	}
+}
 
-		if !strings.Contains(currentStatus.Error, expectedStatus.Error) {
-			return aoserrors.Errorf("wrong error value: %s", currentStatus.Error)
-		}
+func compareStatus(expectedStatus, currentStatus umclient.Status) (err error) {
+	if currentStatus.State != expectedStatus.State {
+		return aoserrors.Errorf("wrong current state: %s", currentStatus.State)
+	}
 
-		for _, expectedItem := range expectedStatus.Components {
-			index := len(expectedStatus.Components)
-
-			for i, currentItem := range currentStatus.Components {
-				if currentItem.ID == expectedItem.ID &&
-					currentItem.VendorVersion == expectedItem.VendorVersion &&
-					currentItem.AosVersion == expectedItem.AosVersion &&
-					currentItem.Status == expectedItem.Status &&
-					strings.Contains(currentItem.Error, expectedItem.Error) {
-					index = i
-					break
-				}
-			}
+	if !strings.Contains(currentStatus.Error, expectedStatus.Error) {
+		return aoserrors.Errorf("wrong error value: %s", currentStatus.Error)
+	}
 
-			if index == len(expectedStatus.Components) {
-				return aoserrors.Errorf("expected item not found: %v", expectedItem)
-			}
+	currentStatus.Components = append([]umclient.ComponentStatusInfo(nil), currentStatus.Components...)
 
-			currentStatus.Components = append(currentStatus.Components[:index], currentStatus.Components[index+1:]...)
+	for _, expectedItem := range expectedStatus.Components {
+		index := len(expectedStatus.Components)
+
+		for i, currentItem := range currentStatus.Components {
+			if currentItem.ID == expectedItem.ID &&
+				currentItem.VendorVersion == expectedItem.VendorVersion &&
+				currentItem.AosVersion == expectedItem.AosVersion &&
+				currentItem.Status == expectedItem.Status &&
+				strings.Contains(currentItem.Error, expectedItem.Error) {
+				index = i
+				break
+			}
 		}
 
-		if len(currentStatus.Components) != 0 {
-			return aoserrors.Errorf("unexpected item found: %v", currentStatus.Components[0])
+		if index == len(expectedStatus.Components) {
+			return aoserrors.Errorf("expected item not found: %v", expectedItem)
 		}
 
-		return nil
+		currentStatus.Components = append(currentStatus.Components[:index], currentStatus.Components[index+1:]...)
 	}
+
+	if len(currentStatus.Components) != 0 {
+		return aoserrors.Errorf("unexpected item found: %v", currentStatus.Components[0])
+	}
+
+	return nil
+}
+
+func compareGolden(fileName string, data []byte) (err error) {
+	goldenFile := path.Join("testdata", fileName)
+
+	if *updateGolden {
+		return aoserrors.Wrap(os.WriteFile(goldenFile, data, 0o600))
+	}
+
+	golden, err := os.ReadFile(goldenFile)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if !bytes.Equal(golden, data) {
+		return aoserrors.Errorf("data doesn't match %s:\n%s", goldenFile, data)
+	}
+
+	return nil
 }
 
 func createImage(imagePath string) (fileInfo image.FileInfo, err error) {
@@ -1154,6 +1786,40 @@ func createImage(imagePath string) (fileInfo image.FileInfo, err error) {
 	return fileInfo, nil
 }
 
+func writeImage(imagePath string, content []byte, compress bool) (err error) {
+	if !compress {
+		return aoserrors.Wrap(os.WriteFile(imagePath, content, 0o600))
+	}
+
+	var buffer bytes.Buffer
+
+	gzipWriter := gzip.NewWriter(&buffer)
+
+	if _, err = gzipWriter.Write(content); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = gzipWriter.Close(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(os.WriteFile(imagePath, buffer.Bytes(), 0o600))
+}
+
+func waitForState(handler *updatehandler.Handler, state umclient.UMState) (err error) {
+	select {
+	case <-time.After(5 * time.Second):
+		return aoserrors.New("wait operation timeout")
+
+	case status := <-handler.StatusChannel():
+		if status.State != state {
+			return aoserrors.Errorf("wrong current state: %s, error: %s", status.State, status.Error)
+		}
+
+		return nil
+	}
+}
+
 func createUpdateInfos(currentStatus []umclient.ComponentStatusInfo,
 	vendorVersion string,
 ) (infos []umclient.ComponentUpdateInfo, err error) {
@@ -1181,6 +1847,22 @@ func createUpdateInfos(currentStatus []umclient.ComponentStatusInfo,
 	return infos, nil
 }
 
+// checkRebootTypes checks reboot types of components since last check.
+func checkRebootTypes(t *testing.T, expectedTypes map[string]string) {
+	t.Helper()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for id, expectedType := range expectedTypes {
+		if components[id].rebootedWith != expectedType {
+			t.Errorf("Wrong reboot type of %s: %s", id, components[id].rebootedWith)
+		}
+
+		components[id].rebootedWith = ""
+	}
+}
+
 func testOperation(
 	t *testing.T,
 	handler *updatehandler.Handler,
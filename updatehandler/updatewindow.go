@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/schedule"
+)
+
+// Start or apply of prepared update may be scheduled into update window: the handler stays in prepared or updated
+// state and sends the event itself when the window opens. Window is either fixed start time or cron-like schedule
+// which opens the window at each occurrence, optional duration closes the window. The resolved window start is
+// persisted with the scheduled event, so the event is sent after reboot as well: if the window is closed by that
+// time, scheduled window is moved to the next occurrence and fixed one is dropped. Any state transition, e.g. start
+// or revert requested directly, cancels the scheduled event.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// UpdateWindow window when scheduled update operation is started. Either start time or schedule should be set.
+// Zero duration means the window doesn't close.
+type UpdateWindow struct {
+	Start    time.Time     `json:"start,omitempty"`
+	Schedule string        `json:"schedule,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+type scheduledEvent struct {
+	Event  string       `json:"event"`
+	Window UpdateWindow `json:"window"`
+	Start  time.Time    `json:"start"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// ScheduleStartUpdate schedules start of prepared update into update window.
+func (handler *Handler) ScheduleStartUpdate(window UpdateWindow) (err error) {
+	return handler.scheduleEvent(eventUpdate, statePrepared, window)
+}
+
+// ScheduleApplyUpdate schedules apply of updated components into update window.
+func (handler *Handler) ScheduleApplyUpdate(window UpdateWindow) (err error) {
+	return handler.scheduleEvent(eventApply, stateUpdated, window)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) scheduleEvent(event, state string, window UpdateWindow) (err error) {
+	handler.Lock()
+	defer handler.Unlock()
+
+	if handler.isQuarantined() {
+		return aoserrors.Errorf("can't schedule event %s: update handler is quarantined", event)
+	}
+
+	if handler.fsm.Current() != state {
+		return aoserrors.Errorf("can't schedule event %s in state: %s", event, handler.fsm.Current())
+	}
+
+	start, err := window.next(handler.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"event": event, "start": start}).Info("Schedule update event")
+
+	handler.state.ScheduledEvent = &scheduledEvent{Event: event, Window: window, Start: start}
+
+	if err = handler.saveState(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	handler.startEventTimer()
+
+	return nil
+}
+
+func (handler *Handler) initUpdateWindow() {
+	if handler.state.ScheduledEvent == nil {
+		return
+	}
+
+	handler.startEventTimer()
+}
+
+func (handler *Handler) startEventTimer() {
+	handler.stopEventTimer()
+
+	delay := handler.state.ScheduledEvent.Start.Sub(handler.clock.Now())
+
+	// Window is already open, event is sent asynchronously as handler lock is held here
+	if delay <= 0 {
+		go handler.onUpdateWindowOpened()
+
+		return
+	}
+
+	handler.eventTimer = handler.clock.AfterFunc(delay, handler.onUpdateWindowOpened)
+}
+
+func (handler *Handler) stopEventTimer() {
+	if handler.eventTimer != nil {
+		handler.eventTimer.Stop()
+		handler.eventTimer = nil
+	}
+}
+
+// cancelScheduledEvent is called on each state transition under handler lock.
+func (handler *Handler) cancelScheduledEvent() {
+	handler.stopEventTimer()
+
+	if handler.state.ScheduledEvent == nil {
+		return
+	}
+
+	log.WithField("event", handler.state.ScheduledEvent.Event).Debug("Cancel scheduled update event")
+
+	handler.state.ScheduledEvent = nil
+}
+
+func (handler *Handler) onUpdateWindowOpened() {
+	handler.Lock()
+
+	// Scheduled event is kept during quarantine and restarted on release
+	if handler.isClosed() || handler.isQuarantined() || handler.state.ScheduledEvent == nil {
+		handler.Unlock()
+
+		return
+	}
+
+	scheduled := handler.state.ScheduledEvent
+
+	if window := scheduled.Window; window.Duration > 0 &&
+		!handler.clock.Now().Before(scheduled.Start.Add(window.Duration)) {
+		start, err := window.next(handler.clock.Now())
+		if err != nil {
+			log.WithField("event", scheduled.Event).Errorf("Drop scheduled update event: %s", err)
+
+			handler.state.ScheduledEvent = nil
+		} else {
+			log.WithFields(log.Fields{"event": scheduled.Event, "start": start}).Warn("Update window missed, reschedule")
+
+			scheduled.Start = start
+
+			handler.startEventTimer()
+		}
+
+		if err := handler.saveState(); err != nil {
+			log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+		}
+
+		handler.Unlock()
+
+		return
+	}
+
+	log.WithField("event", scheduled.Event).Info("Update window opened")
+
+	handler.eventTimer = nil
+	handler.state.ScheduledEvent = nil
+
+	if err := handler.saveState(); err != nil {
+		log.Errorf("Can't set update state: %s", aoserrors.Wrap(err))
+	}
+
+	handler.Unlock()
+
+	if err := handler.sendEvent(scheduled.Event); err != nil {
+		log.Errorf("Can't send scheduled event %s: %s", scheduled.Event, aoserrors.Wrap(err))
+	}
+}
+
+// next returns start of the current or the next window.
+func (window UpdateWindow) next(now time.Time) (start time.Time, err error) {
+	if window.Duration < 0 {
+		return time.Time{}, aoserrors.Errorf("wrong update window duration: %v", window.Duration)
+	}
+
+	if window.Start.IsZero() == (window.Schedule == "") {
+		return time.Time{}, aoserrors.New("either update window start or schedule should be set")
+	}
+
+	if window.Schedule == "" {
+		if window.Duration > 0 && !now.Before(window.Start.Add(window.Duration)) {
+			return time.Time{}, aoserrors.New("update window is closed")
+		}
+
+		return window.Start, nil
+	}
+
+	windowSchedule, err := schedule.Parse(window.Schedule)
+	if err != nil {
+		return time.Time{}, aoserrors.Wrap(err)
+	}
+
+	// Window opened less than duration ago is still open
+	if start = windowSchedule.Next(now.Add(-window.Duration)); start.IsZero() {
+		return time.Time{}, aoserrors.Errorf("update window schedule %q never occurs", window.Schedule)
+	}
+
+	return start, nil
+}
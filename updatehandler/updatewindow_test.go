@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestUpdateWindow(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}}
+	storage := newTestStorage()
+	cfg := &config.Config{UpdateModules: []config.ModuleConfig{{ID: "id1", Plugin: "testmodule"}}}
+
+	handler := newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	fakeClock := clock.NewFake(time.Now())
+
+	handler.SetClock(fakeClock)
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	if err = handler.ScheduleApplyUpdate(updatehandler.UpdateWindow{Start: fakeClock.Now()}); err == nil {
+		t.Error("Error expected scheduling apply in prepared state")
+	}
+
+	if err = handler.ScheduleStartUpdate(updatehandler.UpdateWindow{
+		Start: fakeClock.Now().Add(-2 * time.Hour), Duration: time.Hour,
+	}); err == nil || !strings.Contains(err.Error(), "update window is closed") {
+		t.Errorf("Wrong closed window error: %v", err)
+	}
+
+	// Start is deferred till fixed window opens
+
+	if err = handler.ScheduleStartUpdate(updatehandler.UpdateWindow{Start: fakeClock.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("Can't schedule start update: %s", err)
+	}
+
+	select {
+	case status := <-handler.StatusChannel():
+		t.Errorf("Unexpected status before window opens: %v", status)
+
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	fakeClock.Advance(time.Hour)
+
+	if err = waitForState(handler, umclient.StateUpdated); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	// Apply is deferred till scheduled window opens
+
+	order = nil
+
+	if err = handler.ScheduleApplyUpdate(updatehandler.UpdateWindow{
+		Schedule: "TZ=UTC 0 2 * * *", Duration: time.Minute,
+	}); err != nil {
+		t.Fatalf("Can't schedule apply update: %s", err)
+	}
+
+	now := fakeClock.Now().UTC()
+	applyTime := time.Date(now.Year(), now.Month(), now.Day(), 2, 0, 0, 0, time.UTC)
+
+	if !applyTime.After(now) {
+		applyTime = applyTime.AddDate(0, 0, 1)
+	}
+
+	fakeClock.Set(applyTime)
+
+	if err = waitForState(handler, umclient.StateIdle); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	if err = checkComponentOps(map[string][]string{"id1": {opApply}}); err != nil {
+		t.Errorf("Component operation error: %s", err)
+	}
+
+	// Scheduled event survives restart
+
+	if infos, err = createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1", AosVersion: 1}}, ""); err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	handler.PrepareUpdate(infos)
+
+	if err = waitForState(handler, umclient.StatePrepared); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+
+	if err = handler.ScheduleStartUpdate(updatehandler.UpdateWindow{Start: time.Now()}); err != nil {
+		t.Fatalf("Can't schedule start update: %s", err)
+	}
+
+	handler.Close(context.Background())
+
+	handler = newTestHandler(t, cfg, withStorage(storage), withModules(components))
+
+	if err = waitForState(handler, umclient.StateUpdated); err != nil {
+		t.Fatalf("Wait for state failed: %s", err)
+	}
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/diagnostics"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+)
+
+// Wall time, CPU time and written bytes are measured around each module operation and accumulated per update phase
+// of the last update. CPU time and written bytes are process counters: when modules of the same priority are
+// processed concurrently, each of them is accounted with usage of the others. Usage is kept in update state, so it
+// survives reboots, and each measurement is added to module operation journal.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const phaseReboot = "reboot"
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// GetResourceUsage returns resource usage of update modules per update phase of the last update.
+func (handler *Handler) GetResourceUsage() (usage map[string]map[string]diagnostics.PhaseUsage) {
+	handler.usageMutex.Lock()
+	defer handler.usageMutex.Unlock()
+
+	usage = make(map[string]map[string]diagnostics.PhaseUsage)
+
+	for id, phases := range handler.state.ResourceUsage {
+		usage[id] = make(map[string]diagnostics.PhaseUsage)
+
+		for phase, phaseUsage := range phases {
+			usage[id][phase] = phaseUsage
+		}
+	}
+
+	return usage
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (handler *Handler) measureUsage(
+	id, phase string, journal *opjournal.Journal, operation func() (err error),
+) (err error) {
+	startTime := time.Now()
+	startCursor := handler.journalCursors.get()
+
+	startUsage, usageErr := diagnostics.ReadProcessUsage()
+	if usageErr != nil {
+		log.WithField("id", id).Warnf("Can't read process usage: %v", usageErr)
+	}
+
+	err = operation()
+
+	usage := diagnostics.PhaseUsage{WallTime: time.Since(startTime)}
+
+	if usageErr == nil {
+		endUsage, usageErr := diagnostics.ReadProcessUsage()
+		if usageErr != nil {
+			log.WithField("id", id).Warnf("Can't read process usage: %v", usageErr)
+		} else {
+			diff := endUsage.Sub(startUsage)
+
+			usage.CPUTime = diff.CPUTime
+			usage.WriteBytes = diff.WriteBytes
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"id": id, "phase": phase, "wallTime": usage.WallTime, "cpuTime": usage.CPUTime,
+		"writeBytes": usage.WriteBytes,
+	}).Debug("Module resource usage")
+
+	handler.addUsage(id, phase, usage)
+
+	journal.Usage(phase, startTime, usage.WallTime, usage.CPUTime, usage.WriteBytes, err)
+
+	handler.recordOperation(id, phase, startTime, usage.WallTime, err,
+		newJournalEntry(startCursor, handler.journalCursors.get()))
+
+	return err
+}
+
+func (handler *Handler) addUsage(id, phase string, usage diagnostics.PhaseUsage) {
+	handler.usageMutex.Lock()
+	defer handler.usageMutex.Unlock()
+
+	if handler.state.ResourceUsage == nil {
+		handler.state.ResourceUsage = make(map[string]map[string]diagnostics.PhaseUsage)
+	}
+
+	if handler.state.ResourceUsage[id] == nil {
+		handler.state.ResourceUsage[id] = make(map[string]diagnostics.PhaseUsage)
+	}
+
+	// Operation may be called several times within phase, e.g. after reboot
+	phaseUsage := handler.state.ResourceUsage[id][phase]
+
+	phaseUsage.WallTime += usage.WallTime
+	phaseUsage.CPUTime += usage.CPUTime
+	phaseUsage.WriteBytes += usage.WriteBytes
+
+	handler.state.ResourceUsage[id][phase] = phaseUsage
+}
+
+func (handler *Handler) resetUsage() {
+	handler.usageMutex.Lock()
+	defer handler.usageMutex.Unlock()
+
+	handler.state.ResourceUsage = nil
+}
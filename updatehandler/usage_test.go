@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestResourceUsage(t *testing.T) {
+	components = map[string]*testModule{"id1": {id: "id1"}, "id2": {id: "id2"}}
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "testmodule"},
+		},
+	}, withModules(components))
+
+	testOperation(t, handler, handler.Registered, nil, nil, nil)
+
+	infos, err := createUpdateInfos([]umclient.ComponentStatusInfo{{ID: "id1"}, {ID: "id2"}}, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	components["id2"].rebootRequired = true
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, nil, nil, nil)
+	testOperation(t, handler, handler.StartUpdate, nil, nil, nil)
+	testOperation(t, handler, handler.ApplyUpdate, nil, nil, nil)
+
+	expectedPhases := map[string][]string{
+		"id1": {"apply", "prepare", "update"},
+		"id2": {"apply", "prepare", "reboot", "update"},
+	}
+
+	usage := handler.GetResourceUsage()
+
+	for id, expected := range expectedPhases {
+		phases := make([]string, 0, len(usage[id]))
+
+		for phase := range usage[id] {
+			phases = append(phases, phase)
+		}
+
+		sort.Strings(phases)
+
+		if !reflect.DeepEqual(phases, expected) {
+			t.Errorf("Wrong %s usage phases: %v", id, phases)
+		}
+	}
+
+	// Usage is reset on next update
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos[:1]) }, nil, nil, nil)
+
+	if usage = handler.GetResourceUsage(); len(usage) != 1 || len(usage["id1"]) != 1 {
+		t.Errorf("Wrong resource usage: %v", usage)
+	}
+}
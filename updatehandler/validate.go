@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/utils/versionutils"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var validators = make(map[string]ValidatePlugin) //nolint:gochecknoglobals
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// ValidatePlugin update module params validation function. It shouldn't access the hardware as it is used to check
+// config offline.
+type ValidatePlugin func(configJSON json.RawMessage) (err error)
+
+// ConfigFinding config validation finding.
+type ConfigFinding struct {
+	Module  string `json:"module,omitempty"`
+	Message string `json:"message"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// RegisterValidator registers update plugin params validator.
+func RegisterValidator(plugin string, validateFunc ValidatePlugin) {
+	validators[plugin] = validateFunc
+}
+
+// ValidateModuleParams checks that plugin is registered and validates module params by plugin validator if any.
+func ValidateModuleParams(plugin string, params json.RawMessage) (err error) {
+	if _, ok := plugins[plugin]; !ok {
+		return aoserrors.Errorf("plugin %s not found", plugin)
+	}
+
+	if validateFunc, ok := validators[plugin]; ok {
+		if err = validateFunc(params); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// DecodeParams strictly decodes module params: unknown fields are treated as error.
+func DecodeParams(params json.RawMessage, config interface{}) (err error) {
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+
+	return aoserrors.Wrap(decoder.Decode(config))
+}
+
+// ValidateConfig validates update handler config and params of enabled update modules without creating them. Plugin
+// files are not loaded as loading runs plugin code which can't be unloaded: it is checked only that they are ELF
+// shared objects. Plugins of plugin files are not registered, so their params are validated on start.
+func ValidateConfig(cfg *config.Config) (findings []ConfigFinding) {
+	if len(cfg.SnapshotPaths) != 0 && cfg.WorkingDir == "" {
+		findings = append(findings, ConfigFinding{Message: "working dir should be configured for config snapshot"})
+	}
+
+	if _, err := newDownloadHosts(cfg.DownloadHosts); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkSignaturePolicies(cfg); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkVerificationPolicies(cfg); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkTrustAnchors(cfg); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkKeyProviders(cfg.KeyProviders); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkUpdateBlockers(cfg.UpdateBlockers); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkMirrorSelection(cfg.MirrorSelection); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkMaxDownloads(cfg.MaxConcurrentDownloads); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkDownloadBandwidth(cfg.DownloadBandwidth); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkFailureThreshold(cfg.FailureThreshold); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkFailurePolicy(cfg.FailurePolicy); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if _, err := newRetryPolicy(cfg.OperationRetry); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkFailedImages(cfg.FailedImages.Dir, cfg.FailedImages.MaxImages); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkPrefetchConfig(cfg.Prefetch); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkMaintenanceActions(cfg.MaintenanceActions); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkStatusRefreshInterval(cfg.StatusRefreshInterval.Duration); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkDependencies(cfg.UpdateModules); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if _, err := newApplySchedule(cfg.ApplySchedule); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkSpeedTest(cfg.SpeedTest); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if _, err := newHealthChecks(cfg.HealthChecks); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if _, err := newJournalCursors(cfg.JournalCursors); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if _, err := getHealthScoreWindow(cfg.HealthScore); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if _, err := newCanaryApply(cfg.CanaryApply, cfg.UpdateModules); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	if err := checkHooks(cfg.Hooks); err != nil {
+		findings = append(findings, ConfigFinding{Message: err.Error()})
+	}
+
+	for _, file := range cfg.PluginFiles {
+		if err := checkPluginFile(file); err != nil {
+			findings = append(findings, ConfigFinding{Message: err.Error()})
+		}
+	}
+
+	ids := make(map[string]bool)
+
+	for _, moduleCfg := range cfg.UpdateModules {
+		if moduleCfg.ID == "" {
+			findings = append(findings, ConfigFinding{Message: "module ID is empty"})
+
+			continue
+		}
+
+		if ids[moduleCfg.ID] {
+			findings = append(findings, ConfigFinding{Module: moduleCfg.ID, Message: "duplicated module ID"})
+		}
+
+		ids[moduleCfg.ID] = true
+
+		if moduleCfg.Disabled {
+			continue
+		}
+
+		if err := checkModuleConfig(moduleCfg); err != nil {
+			findings = append(findings, ConfigFinding{Module: moduleCfg.ID, Message: err.Error()})
+		}
+
+		// Plugin which is not compiled in may be provided by plugin file
+		if _, ok := plugins[moduleCfg.Plugin]; !ok && len(cfg.PluginFiles) != 0 {
+			continue
+		}
+
+		if err := ValidateModuleParams(moduleCfg.Plugin, moduleCfg.Params); err != nil {
+			findings = append(findings, ConfigFinding{Module: moduleCfg.ID, Message: err.Error()})
+		}
+	}
+
+	return findings
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkModuleConfig(moduleCfg config.ModuleConfig) (err error) {
+	if moduleCfg.ExternalTarget && moduleCfg.RebootGroup != "" {
+		return aoserrors.Errorf("external target component %s can't be in reboot group", moduleCfg.ID)
+	}
+
+	if err = checkRebootType(moduleCfg.RebootType); err != nil {
+		return err
+	}
+
+	if moduleCfg.UpdateTimeout.Duration < 0 {
+		return aoserrors.Errorf("wrong update timeout of component %s", moduleCfg.ID)
+	}
+
+	if _, err = versionutils.ParseScheme(moduleCfg.VersionScheme); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = newComponentVerifier(moduleCfg.Verify); err != nil {
+		return err
+	}
+
+	if err = checkImageFormats(moduleCfg.ImageFormats); err != nil {
+		return err
+	}
+
+	if err = checkSpaceCheck(moduleCfg.SpaceCheck); err != nil {
+		return err
+	}
+
+	if err = checkConfirmation(moduleCfg.Confirmation); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestValidateConfig(t *testing.T) {
+	updatehandler.RegisterValidator("testmodule", func(configJSON json.RawMessage) (err error) {
+		if string(configJSON) == `"wrong"` {
+			return aoserrors.New("wrong params")
+		}
+
+		return nil
+	})
+
+	findings := updatehandler.ValidateConfig(&config.Config{
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id1", Plugin: "testmodule"},
+			{ID: "id2", Plugin: "unknown"},
+			{ID: "id3", Plugin: "testmodule", Params: json.RawMessage(`"wrong"`)},
+			{ID: "id4", Plugin: "testmodule", ExternalTarget: true, RebootGroup: "soc"},
+			{ID: "id5", Plugin: "unknown", Disabled: true},
+			{ID: "id6", Plugin: "testmodule", VersionScheme: "unknown"},
+			{ID: "id7", Plugin: "testmodule", Params: json.RawMessage(`"valid"`)},
+			{ID: "id8", Plugin: "testmodule", ImageFormats: []string{"ext4", "zip"}},
+		},
+	})
+
+	var modules []string
+
+	for _, finding := range findings {
+		modules = append(modules, finding.Module)
+	}
+
+	if !reflect.DeepEqual(modules, []string{"id1", "id2", "id3", "id4", "id6", "id8"}) {
+		t.Errorf("Wrong findings: %v", findings)
+	}
+}
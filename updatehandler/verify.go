@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	defaultVerifyTimeout = time.Minute
+	maxVerifyOutputLen   = 256
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// componentVerifier runs integrator defined shell command to verify component after update. The command is
+// considered successful if it exits with expected code and its combined output matches expected regexp.
+type componentVerifier struct {
+	command   string
+	timeout   time.Duration
+	exitCode  int
+	outputExp *regexp.Regexp
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newComponentVerifier(cfg *config.VerifyCommand) (verifier *componentVerifier, err error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.Command == "" {
+		return nil, aoserrors.New("verify command is empty")
+	}
+
+	verifier = &componentVerifier{command: cfg.Command, timeout: cfg.Timeout.Duration, exitCode: cfg.ExitCode}
+
+	if verifier.timeout == 0 {
+		verifier.timeout = defaultVerifyTimeout
+	}
+
+	if cfg.OutputRegexp != "" {
+		if verifier.outputExp, err = regexp.Compile(cfg.OutputRegexp); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	return verifier, nil
+}
+
+func (verifier *componentVerifier) verify(id string) (err error) {
+	log.WithFields(log.Fields{"id": id, "command": verifier.command}).Debug("Verify component")
+
+	ctx, cancel := context.WithTimeout(context.Background(), verifier.timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", verifier.command).CombinedOutput()
+
+	if ctx.Err() != nil {
+		return aoserrors.Errorf("verification timeout: %s", verifier.command)
+	}
+
+	exitCode := 0
+
+	if err != nil {
+		var exitError *exec.ExitError
+
+		if !errors.As(err, &exitError) {
+			return aoserrors.Errorf("verification failed: %v", err)
+		}
+
+		exitCode = exitError.ExitCode()
+	}
+
+	if exitCode != verifier.exitCode {
+		return aoserrors.Errorf("verification failed: exit code %d, output: %s", exitCode, trimOutput(output))
+	}
+
+	if verifier.outputExp != nil && !verifier.outputExp.Match(output) {
+		return aoserrors.Errorf("verification failed: unexpected output: %s", trimOutput(output))
+	}
+
+	return nil
+}
+
+func trimOutput(output []byte) (result string) {
+	result = strings.TrimSpace(string(output))
+
+	if len(result) > maxVerifyOutputLen {
+		result = result[:maxVerifyOutputLen] + "..."
+	}
+
+	return result
+}
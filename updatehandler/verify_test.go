@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updatehandler_test
+
+import (
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/umclient"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestVerifyCommand(t *testing.T) {
+	order = nil
+
+	handler := newTestHandler(t, &config.Config{
+		DownloadDir: cfg.DownloadDir,
+		UpdateModules: []config.ModuleConfig{
+			{ID: "id1", Plugin: "testmodule", UpdatePriority: 1, Verify: &config.VerifyCommand{
+				Command: "echo verified; exit 3", ExitCode: 3, OutputRegexp: "^verified",
+			}},
+			{ID: "id2", Plugin: "testmodule", Verify: &config.VerifyCommand{Command: "echo corrupted; exit 1"}},
+		},
+	})
+
+	currentStatus := umclient.Status{
+		State: umclient.StateIdle,
+		Components: []umclient.ComponentStatusInfo{
+			{ID: "id1", Status: umclient.StatusInstalled},
+			{ID: "id2", Status: umclient.StatusInstalled},
+		},
+	}
+
+	testOperation(t, handler, handler.Registered, &currentStatus, nil, nil)
+
+	infos, err := createUpdateInfos(currentStatus.Components, "")
+	if err != nil {
+		t.Fatalf("Can't create update infos: %s", err)
+	}
+
+	testOperation(t, handler, func() { handler.PrepareUpdate(infos) }, nil, nil, nil)
+
+	verifyErr := "verification failed: exit code 1, output: corrupted"
+
+	testOperation(t, handler, handler.StartUpdate, &umclient.Status{
+		State: umclient.StateFailed,
+		Error: verifyErr,
+		Components: append(currentStatus.Components, []umclient.ComponentStatusInfo{
+			{ID: "id1", AosVersion: infos[0].AosVersion, Status: umclient.StatusInstalling},
+			{ID: "id2", AosVersion: infos[1].AosVersion, Status: umclient.StatusError, Error: verifyErr},
+		}...),
+	}, nil, nil)
+}
@@ -18,14 +18,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"path"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/utils/cryptutils"
@@ -33,12 +37,19 @@ import (
 	"github.com/coreos/go-systemd/journal"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/aoscloud/aos_updatemanager/bundleuploader"
 	"github.com/aoscloud/aos_updatemanager/config"
+	"github.com/aoscloud/aos_updatemanager/controlserver"
 	"github.com/aoscloud/aos_updatemanager/database"
 	"github.com/aoscloud/aos_updatemanager/iamclient"
+	_ "github.com/aoscloud/aos_updatemanager/keyproviders"
 	"github.com/aoscloud/aos_updatemanager/umclient"
 	"github.com/aoscloud/aos_updatemanager/updatehandler"
 	_ "github.com/aoscloud/aos_updatemanager/updatemodules"
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+	"github.com/aoscloud/aos_updatemanager/utils/diagnostics"
+	"github.com/aoscloud/aos_updatemanager/utils/lease"
+	"github.com/aoscloud/aos_updatemanager/utils/writabledir"
 )
 
 /*******************************************************************************
@@ -47,6 +58,17 @@ import (
 
 const dbFileName = "updatemanager.db"
 
+const closeTimeout = 30 * time.Second
+
+const checkConfigCmd = "check-config"
+
+const (
+	exitConfigInvalid = 1
+	exitConfigError   = 2
+)
+
+const exitInventoryFailed = 1
+
 /*******************************************************************************
  * Vars
  ******************************************************************************/
@@ -62,12 +84,35 @@ type journalHook struct {
 	severityMap map[log.Level]journal.Priority
 }
 
+type checkConfigResult struct {
+	Valid    bool                          `json:"valid"`
+	Findings []updatehandler.ConfigFinding `json:"findings"`
+}
+
+type inventoryResult struct {
+	State      string               `json:"state"`
+	Error      string               `json:"error,omitempty"`
+	Components []inventoryComponent `json:"components"`
+}
+
+type inventoryComponent struct {
+	ID            string            `json:"id"`
+	VendorVersion string            `json:"vendorVersion"`
+	AosVersion    uint64            `json:"aosVersion"`
+	Status        string            `json:"status"`
+	Error         string            `json:"error,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
 type updateManager struct {
 	db            *database.Database
 	updater       *updatehandler.Handler
 	client        *umclient.Client
 	cryptoContext *cryptutils.CryptoContext
 	iam           *iamclient.Client
+	diagnostics   *diagnostics.Reporter
+	uploader      *bundleuploader.Uploader
+	control       *controlserver.Server
 }
 
 /*******************************************************************************
@@ -86,7 +131,7 @@ func init() {
  * Update manager
  ******************************************************************************/
 
-func newUpdateManager(cfg *config.Config) (um *updateManager, err error) {
+func newUpdateManager(cfg *config.Config, dryStart bool) (um *updateManager, err error) {
 	um = &updateManager{}
 
 	defer func() {
@@ -96,6 +141,10 @@ func newUpdateManager(cfg *config.Config) (um *updateManager, err error) {
 		}
 	}()
 
+	if err = prepareWritableDirs(cfg); err != nil {
+		return um, aoserrors.Wrap(err)
+	}
+
 	// Create DB
 	dbFile := path.Join(cfg.WorkingDir, dbFileName)
 
@@ -119,6 +168,14 @@ func newUpdateManager(cfg *config.Config) (um *updateManager, err error) {
 		return um, aoserrors.Wrap(err)
 	}
 
+	// In dry start mode only versions are reconciled, connections to other services are not required
+	if dryStart {
+		return um, nil
+	}
+
+	um.diagnostics = diagnostics.New(cfg.DiagnosticsInterval.Duration, um.updater.InstanceID(), um.db, um.updater,
+		clock.New())
+
 	um.cryptoContext, err = cryptutils.NewCryptoContext(cfg.CACert)
 	if err != nil {
 		return um, aoserrors.Wrap(err)
@@ -129,36 +186,229 @@ func newUpdateManager(cfg *config.Config) (um *updateManager, err error) {
 		return um, aoserrors.Wrap(err)
 	}
 
-	um.client, err = umclient.New(cfg, um.updater, um.iam, um.cryptoContext, false)
+	if cfg.DiagnosticUpload.URL != "" {
+		if um.uploader, err = bundleuploader.New(cfg, um.iam, um.cryptoContext); err != nil {
+			return um, aoserrors.Wrap(err)
+		}
+
+		um.updater.SetBundleUploader(um.uploader)
+	}
+
+	um.client, err = umclient.New(cfg, um.updater, um.iam, um.iam, um.cryptoContext, false)
 	if err != nil {
 		return um, aoserrors.Wrap(err)
 	}
 
+	if cfg.ControlServer.SocketPath != "" {
+		if um.control, err = controlserver.New(cfg, um.updater, um.iam); err != nil {
+			return um, aoserrors.Wrap(err)
+		}
+	}
+
 	return um, nil
 }
 
 func (um *updateManager) close() {
-	if um.db != nil {
-		um.db.Close()
+	// Control requests are not accepted during close
+	if um.control != nil {
+		um.control.Close()
+	}
+
+	if um.diagnostics != nil {
+		um.diagnostics.Close()
 	}
 
+	updaterClosed := true
+
+	// Update handler is closed while the client still sends statuses and before database as it flushes the state
 	if um.updater != nil {
-		um.updater.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+
+		if err := um.updater.Close(ctx); err != nil {
+			log.Errorf("Can't close update handler: %s", err)
+
+			updaterClosed = false
+		}
+
+		cancel()
+	}
+
+	if um.uploader != nil {
+		um.uploader.Close()
 	}
 
 	if um.client != nil {
 		um.client.Close()
 	}
 
+	if um.db != nil {
+		if updaterClosed {
+			um.db.Close()
+		} else {
+			log.Warn("Database is not closed as update handler may still use it")
+		}
+	}
+
 	if um.cryptoContext != nil {
 		um.cryptoContext.Close()
 	}
 }
 
+// reloadConfig applies update modules config of changed config file without restart.
+func (um *updateManager) reloadConfig(configFile string) {
+	log.WithField("configFile", configFile).Info("Reload config")
+
+	cfg, err := config.New(configFile)
+	if err != nil {
+		log.Errorf("Can't open config file: %s", err)
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	if err = um.updater.ReloadConfig(ctx, cfg); err != nil {
+		log.Errorf("Can't reload config: %s", err)
+	}
+}
+
 /*******************************************************************************
  * Private
  ******************************************************************************/
 
+func prepareWritableDirs(cfg *config.Config) (err error) {
+	for _, dir := range []*string{
+		&cfg.WorkingDir, &cfg.DownloadDir, &cfg.CacheDir, &cfg.Migration.MergedMigrationPath,
+	} {
+		if *dir, err = writabledir.Prepare(
+			*dir, cfg.WritableStorage.Path, cfg.WritableStorage.MinFreeSpace,
+			cfg.WritableStorage.MinFreeInodes); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func checkConfig(args []string) (exitCode int) {
+	flags := flag.NewFlagSet(checkConfigCmd, flag.ContinueOnError)
+	configFile := flags.String("c", "aos_updatemanager.cfg", "path to config file")
+
+	if err := flags.Parse(args); err != nil {
+		return exitConfigError
+	}
+
+	result := checkConfigResult{Findings: []updatehandler.ConfigFinding{}}
+
+	cfg, err := config.New(*configFile)
+	if err != nil {
+		result.Findings = append(result.Findings, updatehandler.ConfigFinding{Message: err.Error()})
+		exitCode = exitConfigError
+	} else if result.Findings = append(result.Findings, updatehandler.ValidateConfig(cfg)...); len(result.Findings) != 0 {
+		exitCode = exitConfigInvalid
+	}
+
+	result.Valid = exitCode == 0
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "    ")
+
+	if err = encoder.Encode(result); err != nil {
+		log.Errorf("Can't encode result: %s", err)
+
+		return exitConfigError
+	}
+
+	return exitCode
+}
+
+func (um *updateManager) inventory() (exitCode int) {
+	// Nobody receives statuses in dry start mode: drain them to not block versions refreshed asynchronously
+	go func() {
+		for range um.updater.StatusChannel() {
+		}
+	}()
+
+	status := um.updater.Inventory()
+	metadata := um.updater.GetComponentMetadata()
+
+	result := inventoryResult{
+		State: status.State.String(), Error: status.Error, Components: []inventoryComponent{},
+	}
+
+	if status.State == umclient.StateFailed {
+		exitCode = exitInventoryFailed
+	}
+
+	for _, componentStatus := range status.Components {
+		result.Components = append(result.Components, inventoryComponent{
+			ID:            componentStatus.ID,
+			VendorVersion: componentStatus.VendorVersion,
+			AosVersion:    componentStatus.AosVersion,
+			Status:        componentStatus.Status.String(),
+			Error:         componentStatus.Error,
+			Metadata:      metadata[componentStatus.ID],
+		})
+
+		if componentStatus.Status == umclient.StatusError {
+			exitCode = exitInventoryFailed
+		}
+	}
+
+	sort.SliceStable(result.Components, func(i, j int) bool {
+		return result.Components[i].ID < result.Components[j].ID
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "    ")
+
+	if err := encoder.Encode(result); err != nil {
+		log.Errorf("Can't encode result: %s", err)
+
+		return exitInventoryFailed
+	}
+
+	return exitCode
+}
+
+// acquireLease waits in warm standby till the lease is acquired. Nil lease is returned if terminated in standby.
+func acquireLease(cfg *config.Config) (instanceLease *lease.Lease, err error) {
+	instanceID := cfg.Standby.InstanceID
+
+	if instanceID == "" {
+		if instanceID, err = os.Hostname(); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	if instanceLease, err = lease.New(
+		cfg.Standby.LeaseFile, instanceID, cfg.Standby.LeaseTTL.Duration, clock.New()); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err = instanceLease.Acquire(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return instanceLease, nil
+}
+
+func leaseLost(instanceLease *lease.Lease) (channel <-chan struct{}) {
+	if instanceLease == nil {
+		return nil
+	}
+
+	return instanceLease.Lost()
+}
+
 func cleanup(dbFile string) {
 	log.Debug("System cleanup")
 
@@ -215,11 +465,20 @@ func (hook *journalHook) Levels() []log.Level {
  ******************************************************************************/
 
 func main() {
+	// Validate config and exit
+	if len(os.Args) > 1 && os.Args[1] == checkConfigCmd {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.WarnLevel)
+
+		os.Exit(checkConfig(os.Args[2:]))
+	}
+
 	// Initialize command line flags
 	configFile := flag.String("c", "aos_updatemanager.cfg", "path to config file")
 	strLogLevel := flag.String("v", "info", `log level: "debug", "info", "warn", "error", "fatal", "panic"`)
 	useJournal := flag.Bool("j", false, "output logs to systemd journal")
 	showVersion := flag.Bool("version", false, `show update manager version`)
+	dryStart := flag.Bool("dry-start", false, "print components inventory and exit")
 
 	flag.Parse()
 
@@ -232,6 +491,8 @@ func main() {
 	if *useJournal {
 		log.AddHook(newJournalHook())
 		log.SetOutput(io.Discard)
+	} else if *dryStart {
+		log.SetOutput(os.Stderr)
 	} else {
 		log.SetOutput(os.Stdout)
 	}
@@ -250,11 +511,48 @@ func main() {
 		log.Fatalf("Can' open config file: %s", aoserrors.Wrap(err))
 	}
 
-	um, err := newUpdateManager(cfg)
+	var instanceLease *lease.Lease
+
+	// In warm standby persistent state is shared with primary instance: start only when the lease is acquired
+	if cfg.Standby.Enabled && !*dryStart {
+		if _, err = daemon.SdNotify(false, daemon.SdNotifyReady+"\nSTATUS=standby"); err != nil {
+			log.Errorf("Can't notify systemd: %s", err)
+		}
+
+		if instanceLease, err = acquireLease(cfg); err != nil {
+			log.Fatalf("Can't acquire lease: %s", err)
+		}
+
+		if instanceLease == nil {
+			log.Info("Terminated in standby")
+
+			return
+		}
+
+		defer func() {
+			if err := instanceLease.Release(); err != nil {
+				log.Errorf("Can't release lease: %s", err)
+			}
+		}()
+	}
+
+	um, err := newUpdateManager(cfg, *dryStart)
 	if err != nil {
 		log.Fatalf("Can't create update manager: %s", err)
 	}
 
+	// Stale instance shouldn't touch components once the lease is taken over by other instance
+	if instanceLease != nil {
+		um.updater.SetOperationFence(instanceLease)
+	}
+
+	if *dryStart {
+		exitCode := um.inventory()
+
+		um.close()
+		os.Exit(exitCode)
+	}
+
 	defer um.close()
 
 	// Notify systemd
@@ -266,5 +564,22 @@ func main() {
 	terminateChannel := make(chan os.Signal, 1)
 	signal.Notify(terminateChannel, os.Interrupt, syscall.SIGTERM)
 
-	<-terminateChannel
+	// Handle SIGHUP
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+
+	for {
+		select {
+		case <-terminateChannel:
+			return
+
+		case <-reloadChannel:
+			um.reloadConfig(*configFile)
+
+		case <-leaseLost(instanceLease):
+			// Other instance may already use shared working dir and database: exit immediately without closing
+			// update manager as closing saves handler state and closes modules
+			log.Fatal("Lease lost")
+		}
+	}
 }
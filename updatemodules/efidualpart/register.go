@@ -28,18 +28,36 @@ import (
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/rebooters/systemdrebooter"
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/updatechecker/systemdchecker"
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/utils/bootparams"
+	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/utils/devmapper"
+	"github.com/aoscloud/aos_updatemanager/utils/bootenv"
 )
 
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const numPartitions = 2
+
 /***********************************************************************************************************************
  * Types
  **********************************************************************************************************************/
 
 type moduleConfig struct {
-	Loader         string                `json:"loader"`
-	VersionFile    string                `json:"versionFile"`
-	DetectMode     string                `json:"detectMode"`
-	Partitions     []string              `json:"partitions"`
-	SystemdChecker systemdchecker.Config `json:"systemdChecker"`
+	Loader         string                     `json:"loader"`
+	VersionFile    string                     `json:"versionFile"`
+	DetectMode     string                     `json:"detectMode"`
+	Partitions     []string                   `json:"partitions"`
+	SystemdChecker systemdchecker.Config      `json:"systemdChecker"`
+	BootEnv        bootenv.Config             `json:"bootEnv"`
+	PrivateMounts  bool                       `json:"privateMounts"`
+	DeviceMapper   []devmapper.Config         `json:"deviceMapper"`
+	KeyProvider    *keyProviderConfig         `json:"keyProvider"`
+	BackgroundSync *dualpartmodule.SyncConfig `json:"backgroundSync"`
+}
+
+type keyProviderConfig struct {
+	Plugin string          `json:"plugin"`
+	Params json.RawMessage `json:"params"`
 }
 
 /***********************************************************************************************************************
@@ -47,10 +65,10 @@ type moduleConfig struct {
  **********************************************************************************************************************/
 
 func init() {
-	updatehandler.RegisterPlugin("efidualpart",
+	updatehandler.RegisterLegacyPlugin("efidualpart",
 		func(id string, configJSON json.RawMessage,
 			storage updatehandler.ModuleStorage,
-		) (module updatehandler.UpdateModule, err error) {
+		) (module updatehandler.LegacyUpdateModule, err error) {
 			if len(configJSON) == 0 {
 				return nil, aoserrors.Errorf("config for %s module is required", id)
 			}
@@ -74,18 +92,98 @@ func init() {
 				}
 			}
 
-			controller, err := eficontroller.New(partitions, config.Loader)
+			controller, err := eficontroller.New(id, partitions, config.Loader)
+			if err != nil {
+				return nil, aoserrors.Wrap(err)
+			}
+
+			mapper, err := newPartitionMapper(config)
 			if err != nil {
 				return nil, aoserrors.Wrap(err)
 			}
 
 			if module, err = dualpartmodule.New(id, partitions, config.VersionFile,
 				controller, storage, &systemdrebooter.SystemdRebooter{},
-				systemdchecker.New(config.SystemdChecker)); err != nil {
+				systemdchecker.New(config.SystemdChecker), bootenv.New(id, config.BootEnv, storage),
+				config.PrivateMounts, mapper, config.BackgroundSync); err != nil {
 				return nil, aoserrors.Wrap(err)
 			}
 
 			return module, nil
 		},
 	)
+
+	updatehandler.RegisterValidator("efidualpart", func(configJSON json.RawMessage) (err error) {
+		if len(configJSON) == 0 {
+			return aoserrors.New("config is required")
+		}
+
+		var config moduleConfig
+
+		if err = updatehandler.DecodeParams(configJSON, &config); err != nil {
+			return err
+		}
+
+		if config.VersionFile == "" {
+			return aoserrors.New("version file is not set")
+		}
+
+		if config.DetectMode == "" && len(config.Partitions) != numPartitions {
+			return aoserrors.Errorf("num of configured partitions should be %d", numPartitions)
+		}
+
+		return checkDeviceMapper(config)
+	})
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newPartitionMapper(config moduleConfig) (mapper dualpartmodule.PartitionMapper, err error) {
+	if len(config.DeviceMapper) == 0 {
+		return nil, nil //nolint:nilnil // Partitions are accessed directly
+	}
+
+	var keyProvider devmapper.KeyProvider
+
+	if config.KeyProvider != nil {
+		if keyProvider, err = updatehandler.CreateKeyProvider(
+			config.KeyProvider.Plugin, config.KeyProvider.Params); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+	}
+
+	targets, err := devmapper.NewTargets(config.DeviceMapper, keyProvider)
+	if err != nil {
+		if keyProvider != nil {
+			keyProvider.Close()
+		}
+
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return targets, nil
+}
+
+func checkDeviceMapper(config moduleConfig) (err error) {
+	if len(config.DeviceMapper) == 0 {
+		return nil
+	}
+
+	if len(config.DeviceMapper) != numPartitions {
+		return aoserrors.Errorf("num of device-mapper targets should be %d", numPartitions)
+	}
+
+	for _, targetConfig := range config.DeviceMapper {
+		if err = targetConfig.Check(); err != nil {
+			return err
+		}
+
+		if targetConfig.Type == devmapper.TypeLUKS && config.KeyProvider == nil {
+			return aoserrors.New("key provider should be configured for LUKS targets")
+		}
+	}
+
+	return nil
 }
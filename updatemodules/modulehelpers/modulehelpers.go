@@ -0,0 +1,426 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package modulehelpers provides common plumbing for update module plugins: versioned state persistence, typed
+// config decoding, standard log fields, reboot request tracking, update checks and boot failure reasons.
+package modulehelpers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/database"
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+	"github.com/aoscloud/aos_updatemanager/utils/pstore"
+)
+
+// Module state is stored in module storage wrapped into envelope with schema version. State stored by a module
+// before it used the envelope is treated as schema version 0. When the module changes its state layout, it bumps
+// the schema version and provides migration which converts raw state from the previous version, migrations are
+// applied one by one on load. State of newer schema version, e.g. after downgrade, is rejected.
+
+// Failed boot into an update is reported with the crash reason decoded from pstore records, so kernel panic can be
+// told from failed health check of the booted system without collecting console logs. Boot failure is reported by
+// component error and by component metadata.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Standard log field names.
+const (
+	FieldID     = "id"
+	FieldPlugin = "plugin"
+	FieldState  = "state"
+)
+
+// Boot failure kinds.
+const (
+	BootFailureKernelPanic = pstore.KindKernelPanic
+	BootFailureKernelOops  = pstore.KindKernelOops
+	BootFailureHealthCheck = "healthCheck"
+	BootFailureUnknown     = "unknown"
+)
+
+// Boot failure metadata keys.
+const (
+	MetadataBootFailureKind   = "bootFailureKind"
+	MetadataBootFailureReason = "bootFailureReason"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// MigrateFunc converts raw module state of fromVersion schema to the next schema version.
+type MigrateFunc func(fromVersion int, state json.RawMessage) (migrated json.RawMessage, err error)
+
+// StateStorage persists module state with schema version in module storage.
+type StateStorage struct {
+	id      string
+	storage updatehandler.ModuleStorage
+	version int
+	migrate MigrateFunc
+}
+
+// ConfigChecker config which checks its values after decoding.
+type ConfigChecker interface {
+	Check() (err error)
+}
+
+// Rebooter performs module reboot.
+type Rebooter interface {
+	Reboot() (err error)
+}
+
+// RebootTracker tracks reboot requested by module operations: module operation requests reboot when it returns
+// reboot required, reboot is performed only if it was requested. Reboot request is not persisted as the process is
+// restarted by reboot.
+type RebootTracker struct {
+	sync.Mutex
+
+	rebooter  Rebooter
+	journal   *opjournal.Journal
+	requested bool
+}
+
+// UpdateChecker validates update after boot.
+type UpdateChecker interface {
+	Check() (err error)
+}
+
+// AnnotationChecker update checker which asserts campaign specific expectations passed by check annotations of the
+// update, e.g. expected kernel version. It performs generic update validation as well.
+type AnnotationChecker interface {
+	CheckAnnotations(annotations map[string]string) (err error)
+}
+
+// BootFailure failure of boot with update.
+type BootFailure struct {
+	Message string
+	Kind    string
+	Reason  string
+}
+
+type updateAnnotations struct {
+	Checks map[string]string `json:"checks"`
+}
+
+type stateEnvelope struct {
+	SchemaVersion *int            `json:"schemaVersion"`
+	State         json.RawMessage `json:"state"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// NewStateStorage creates module state storage of schema version. Migrate may be nil if the schema has never
+// changed.
+func NewStateStorage(
+	id string, storage updatehandler.ModuleStorage, version int, migrate MigrateFunc,
+) (stateStorage *StateStorage, err error) {
+	if storage == nil {
+		return nil, aoserrors.New("no storage provided")
+	}
+
+	if version < 0 {
+		return nil, aoserrors.Errorf("wrong state schema version %d", version)
+	}
+
+	return &StateStorage{id: id, storage: storage, version: version, migrate: migrate}, nil
+}
+
+// Load loads module state. State is not changed if nothing is stored yet.
+func (stateStorage *StateStorage) Load(state interface{}) (err error) {
+	data, err := stateStorage.storage.GetModuleState(stateStorage.id)
+	if err != nil {
+		if strings.Contains(err.Error(), database.ErrNotExistStr) {
+			return nil
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	var envelope stateEnvelope
+
+	if err = json.Unmarshal(data, &envelope); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	version, rawState := 0, json.RawMessage(data)
+
+	if envelope.SchemaVersion != nil {
+		version, rawState = *envelope.SchemaVersion, envelope.State
+	}
+
+	if version > stateStorage.version {
+		return aoserrors.Errorf("unsupported state schema version %d, max supported %d", version,
+			stateStorage.version)
+	}
+
+	for ; version < stateStorage.version; version++ {
+		if stateStorage.migrate == nil {
+			return aoserrors.Errorf("no migration from state schema version %d", version)
+		}
+
+		log.WithFields(log.Fields{FieldID: stateStorage.id, "from": version}).Debug("Migrate module state")
+
+		if rawState, err = stateStorage.migrate(version, rawState); err != nil {
+			return aoserrors.Errorf("can't migrate state from schema version %d: %v", version, err)
+		}
+	}
+
+	if len(rawState) == 0 {
+		return nil
+	}
+
+	if err = json.Unmarshal(rawState, state); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// Save saves module state with current schema version.
+func (stateStorage *StateStorage) Save(state interface{}) (err error) {
+	rawState, err := json.Marshal(state)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	data, err := json.Marshal(stateEnvelope{SchemaVersion: &stateStorage.version, State: rawState})
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = stateStorage.storage.SetModuleState(stateStorage.id, data); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// DecodeConfig decodes required module config and checks it if config implements ConfigChecker. Unknown fields are
+// ignored as they are reported by offline validation created by ConfigValidator.
+func DecodeConfig(id string, configJSON json.RawMessage, config interface{}) (err error) {
+	if len(configJSON) == 0 {
+		return aoserrors.Errorf("config for %s module is required", id)
+	}
+
+	if err = json.Unmarshal(configJSON, config); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return checkConfig(config)
+}
+
+// ConfigValidator returns update plugin params validator which strictly decodes required config created by newConfig
+// and checks it if config implements ConfigChecker.
+func ConfigValidator(newConfig func() interface{}) (validator updatehandler.ValidatePlugin) {
+	return func(configJSON json.RawMessage) (err error) {
+		if len(configJSON) == 0 {
+			return aoserrors.New("config is required")
+		}
+
+		config := newConfig()
+
+		if err = updatehandler.DecodeParams(configJSON, config); err != nil {
+			return err
+		}
+
+		return checkConfig(config)
+	}
+}
+
+// Logger returns log entry with standard module fields.
+func Logger(id, plugin string) (entry *log.Entry) {
+	fields := log.Fields{FieldID: id}
+
+	if plugin != "" {
+		fields[FieldPlugin] = plugin
+	}
+
+	return log.WithFields(fields)
+}
+
+// NewRebootTracker creates reboot tracker. Rebooter may be nil if module doesn't need physical reboot.
+func NewRebootTracker(rebooter Rebooter, journal *opjournal.Journal) (tracker *RebootTracker) {
+	return &RebootTracker{rebooter: rebooter, journal: journal}
+}
+
+// Request requests reboot and returns reboot required flag to be returned by module operation.
+func (tracker *RebootTracker) Request() (rebootRequired bool) {
+	tracker.Lock()
+	defer tracker.Unlock()
+
+	tracker.requested = true
+
+	return true
+}
+
+// Requested returns true if reboot is requested.
+func (tracker *RebootTracker) Requested() (requested bool) {
+	tracker.Lock()
+	defer tracker.Unlock()
+
+	return tracker.requested
+}
+
+// Reboot performs requested reboot and clears the request.
+func (tracker *RebootTracker) Reboot() (err error) {
+	tracker.Lock()
+	defer tracker.Unlock()
+
+	if !tracker.requested || tracker.rebooter == nil {
+		tracker.requested = false
+
+		return nil
+	}
+
+	startTime := time.Now()
+
+	err = tracker.rebooter.Reboot()
+
+	if tracker.journal != nil {
+		tracker.journal.Command("reboot", startTime, err)
+	}
+
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	tracker.requested = false
+
+	return nil
+}
+
+// GetCheckAnnotations returns check annotations of the update. Check annotations are rejected if checker doesn't
+// implement AnnotationChecker, so the update fails on prepare instead of passing unchecked after boot.
+func GetCheckAnnotations(annotations json.RawMessage, checker UpdateChecker) (checks map[string]string, err error) {
+	if len(annotations) == 0 {
+		return nil, nil
+	}
+
+	var updateAnnotations updateAnnotations
+
+	if err = json.Unmarshal(annotations, &updateAnnotations); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if len(updateAnnotations.Checks) == 0 {
+		return nil, nil
+	}
+
+	if _, ok := checker.(AnnotationChecker); !ok {
+		return nil, aoserrors.New("update checker doesn't support check annotations")
+	}
+
+	return updateAnnotations.Checks, nil
+}
+
+// CheckUpdate validates update by checker. Check annotations are asserted if set. Check failure is returned as
+// health check boot failure.
+func CheckUpdate(checker UpdateChecker, checks map[string]string) (err error) {
+	if annotationChecker, ok := checker.(AnnotationChecker); ok && len(checks) != 0 {
+		err = annotationChecker.CheckAnnotations(checks)
+	} else {
+		err = checker.Check()
+	}
+
+	if err != nil {
+		return aoserrors.Wrap(&BootFailure{
+			Message: "health check failed", Kind: BootFailureHealthCheck, Reason: err.Error(),
+		})
+	}
+
+	return nil
+}
+
+// NewBootFailure creates boot failure with crash reason read from pstore records.
+func NewBootFailure(message string) (err error) {
+	failure := &BootFailure{Message: message, Kind: BootFailureUnknown}
+
+	reason, err := pstore.ReadCrashReason(pstore.Path)
+	if err != nil {
+		log.Errorf("Can't read crash reason: %v", err)
+	}
+
+	if reason != nil {
+		log.WithField("record", reason.Record).Errorf("Crash reason: %s", reason)
+
+		failure.Kind = reason.Kind
+		failure.Reason = reason.Reason
+	}
+
+	return aoserrors.Wrap(failure)
+}
+
+// GetBootFailureMetadata returns boot failure kind and reason as component metadata, nil if error is not boot
+// failure.
+func GetBootFailureMetadata(err error) (metadata map[string]string) {
+	var failure *BootFailure
+
+	if !errors.As(err, &failure) {
+		return nil
+	}
+
+	metadata = map[string]string{MetadataBootFailureKind: failure.Kind}
+
+	if failure.Reason != "" {
+		metadata[MetadataBootFailureReason] = failure.Reason
+	}
+
+	return metadata
+}
+
+// Error returns boot failure message with reason.
+func (failure *BootFailure) Error() (message string) {
+	switch {
+	case failure.Kind == BootFailureHealthCheck:
+		return failure.Message + ": " + failure.Reason
+
+	case failure.Kind == BootFailureUnknown:
+		return failure.Message
+
+	default:
+		return failure.Message + ": " + (&pstore.CrashReason{Kind: failure.Kind, Reason: failure.Reason}).String()
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func checkConfig(config interface{}) (err error) {
+	if checker, ok := config.(ConfigChecker); ok {
+		return checker.Check()
+	}
+
+	return nil
+}
@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modulehelpers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/updatemodules/modulehelpers"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testStorage struct {
+	states map[string][]byte
+}
+
+type testState struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type testConfig struct {
+	Path string `json:"path"`
+}
+
+type testRebooter struct {
+	count int
+	err   error
+}
+
+type testChecker struct {
+	checked bool
+}
+
+type testAnnotationChecker struct {
+	testChecker
+	annotations map[string]string
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestStateStorage(t *testing.T) {
+	storage := &testStorage{states: make(map[string][]byte)}
+
+	stateStorage, err := modulehelpers.NewStateStorage("id1", storage, 0, nil)
+	if err != nil {
+		t.Fatalf("Can't create state storage: %v", err)
+	}
+
+	state := testState{Value: "default"}
+
+	if err = stateStorage.Load(&state); err != nil {
+		t.Fatalf("Can't load state: %v", err)
+	}
+
+	if state.Value != "default" {
+		t.Errorf("Wrong state: %v", state)
+	}
+
+	// Legacy state without schema version
+
+	storage.states["id1"] = []byte(`{"value":"legacy","count":1}`)
+
+	if err = stateStorage.Load(&state); err != nil {
+		t.Fatalf("Can't load state: %v", err)
+	}
+
+	if state != (testState{Value: "legacy", Count: 1}) {
+		t.Errorf("Wrong state: %v", state)
+	}
+
+	// Migration
+
+	stateStorage, err = modulehelpers.NewStateStorage("id1", storage, 2,
+		func(fromVersion int, state json.RawMessage) (migrated json.RawMessage, err error) {
+			var value testState
+
+			if err = json.Unmarshal(state, &value); err != nil {
+				return nil, aoserrors.Wrap(err)
+			}
+
+			value.Count += 10
+
+			return json.Marshal(value)
+		})
+	if err != nil {
+		t.Fatalf("Can't create state storage: %v", err)
+	}
+
+	if err = stateStorage.Load(&state); err != nil {
+		t.Fatalf("Can't load state: %v", err)
+	}
+
+	if state.Count != 21 {
+		t.Errorf("Wrong migrated state: %v", state)
+	}
+
+	if err = stateStorage.Save(&state); err != nil {
+		t.Fatalf("Can't save state: %v", err)
+	}
+
+	var loadedState testState
+
+	if err = stateStorage.Load(&loadedState); err != nil {
+		t.Fatalf("Can't load state: %v", err)
+	}
+
+	if loadedState != state {
+		t.Errorf("Wrong loaded state: %v", loadedState)
+	}
+
+	// Newer schema version
+
+	stateStorage, err = modulehelpers.NewStateStorage("id1", storage, 1, nil)
+	if err != nil {
+		t.Fatalf("Can't create state storage: %v", err)
+	}
+
+	if err = stateStorage.Load(&loadedState); err == nil ||
+		!strings.Contains(err.Error(), "unsupported state schema version 2") {
+		t.Errorf("Wrong load error: %v", err)
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	var config testConfig
+
+	if err := modulehelpers.DecodeConfig("id1", nil, &config); err == nil ||
+		!strings.Contains(err.Error(), "config for id1 module is required") {
+		t.Errorf("Wrong decode error: %v", err)
+	}
+
+	if err := modulehelpers.DecodeConfig("id1", json.RawMessage(`{"path":"","unknown":1}`), &config); err == nil ||
+		!strings.Contains(err.Error(), "path is not set") {
+		t.Errorf("Wrong decode error: %v", err)
+	}
+
+	if err := modulehelpers.DecodeConfig("id1", json.RawMessage(`{"path":"/p","unknown":1}`), &config); err != nil {
+		t.Errorf("Can't decode config: %v", err)
+	}
+
+	if config.Path != "/p" {
+		t.Errorf("Wrong config: %v", config)
+	}
+
+	validator := modulehelpers.ConfigValidator(func() interface{} { return &testConfig{} })
+
+	for _, item := range []struct {
+		config string
+		err    string
+	}{
+		{config: "", err: "config is required"},
+		{config: `{"path":"/p","unknown":1}`, err: "unknown field"},
+		{config: `{"path":""}`, err: "path is not set"},
+		{config: `{"path":"/p"}`},
+	} {
+		err := validator(json.RawMessage(item.config))
+
+		if item.err == "" && err != nil {
+			t.Errorf("Config %s validation error: %v", item.config, err)
+		}
+
+		if item.err != "" && (err == nil || !strings.Contains(err.Error(), item.err)) {
+			t.Errorf("Config %s wrong validation error: %v", item.config, err)
+		}
+	}
+}
+
+func TestRebootTracker(t *testing.T) {
+	rebooter := &testRebooter{}
+	tracker := modulehelpers.NewRebootTracker(rebooter, nil)
+
+	if err := tracker.Reboot(); err != nil {
+		t.Fatalf("Reboot error: %v", err)
+	}
+
+	if rebooter.count != 0 {
+		t.Error("Unexpected reboot")
+	}
+
+	if !tracker.Request() || !tracker.Requested() {
+		t.Error("Reboot should be requested")
+	}
+
+	rebooter.err = errors.New("reboot failed")
+
+	if err := tracker.Reboot(); err == nil {
+		t.Error("Reboot error expected")
+	}
+
+	if !tracker.Requested() {
+		t.Error("Reboot should stay requested on failure")
+	}
+
+	rebooter.err = nil
+
+	if err := tracker.Reboot(); err != nil {
+		t.Fatalf("Reboot error: %v", err)
+	}
+
+	if rebooter.count != 2 || tracker.Requested() {
+		t.Errorf("Wrong reboot count: %d", rebooter.count)
+	}
+}
+
+func TestCheckAnnotations(t *testing.T) {
+	annotations := json.RawMessage(`{"type":"full","checks":{"kernelVersion":"6.1.0"}}`)
+
+	if checks, err := modulehelpers.GetCheckAnnotations(json.RawMessage(`{"type":"full"}`),
+		&testChecker{}); err != nil || checks != nil {
+		t.Errorf("Wrong check annotations: %v, error: %v", checks, err)
+	}
+
+	if _, err := modulehelpers.GetCheckAnnotations(annotations, &testChecker{}); err == nil {
+		t.Error("Error expected")
+	}
+
+	checker := &testAnnotationChecker{}
+
+	checks, err := modulehelpers.GetCheckAnnotations(annotations, checker)
+	if err != nil {
+		t.Fatalf("Can't get check annotations: %v", err)
+	}
+
+	if err = modulehelpers.CheckUpdate(checker, nil); err != nil || !checker.checked || checker.annotations != nil {
+		t.Errorf("Wrong update check: %v", err)
+	}
+
+	if err = modulehelpers.CheckUpdate(checker, checks); err != nil ||
+		checker.annotations["kernelVersion"] != "6.1.0" {
+		t.Errorf("Wrong update check: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (storage *testStorage) SetModuleState(id string, state []byte) (err error) {
+	storage.states[id] = state
+
+	return nil
+}
+
+func (storage *testStorage) GetModuleState(id string) (state []byte, err error) {
+	return storage.states[id], nil
+}
+
+func (config *testConfig) Check() (err error) {
+	if config.Path == "" {
+		return aoserrors.New("path is not set")
+	}
+
+	return nil
+}
+
+func (checker *testChecker) Check() (err error) {
+	checker.checked = true
+
+	return nil
+}
+
+func (checker *testAnnotationChecker) CheckAnnotations(annotations map[string]string) (err error) {
+	checker.annotations = annotations
+
+	return nil
+}
+
+func (rebooter *testRebooter) Reboot() (err error) {
+	rebooter.count++
+
+	return rebooter.err
+}
@@ -22,6 +22,8 @@ import (
 	_ "github.com/aoscloud/aos_updatemanager/updatemodules/efidualpart"
 	_ "github.com/aoscloud/aos_updatemanager/updatemodules/overlaysystemd"
 	_ "github.com/aoscloud/aos_updatemanager/updatemodules/overlayxenstore"
+	_ "github.com/aoscloud/aos_updatemanager/updatemodules/rawdiskmodule"
+	_ "github.com/aoscloud/aos_updatemanager/updatemodules/routermodule"
 	_ "github.com/aoscloud/aos_updatemanager/updatemodules/sshmodule"
 	_ "github.com/aoscloud/aos_updatemanager/updatemodules/testmodule"
 	_ "github.com/aoscloud/aos_updatemanager/updatemodules/ubootdualpart"
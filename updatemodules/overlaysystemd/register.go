@@ -23,6 +23,7 @@ import (
 	"github.com/aoscloud/aos_common/aoserrors"
 
 	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/updatemodules/modulehelpers"
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/modules/overlaymodule"
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/rebooters/systemdrebooter"
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/updatechecker/systemdchecker"
@@ -43,18 +44,14 @@ type moduleConfig struct {
  ******************************************************************************/
 
 func init() {
-	updatehandler.RegisterPlugin("overlaysystemd",
+	updatehandler.RegisterLegacyPlugin("overlaysystemd",
 		func(id string, configJSON json.RawMessage,
 			storage updatehandler.ModuleStorage,
-		) (module updatehandler.UpdateModule, err error) {
-			if len(configJSON) == 0 {
-				return nil, aoserrors.Errorf("config for %s module is required", id)
-			}
-
+		) (module updatehandler.LegacyUpdateModule, err error) {
 			var config moduleConfig
 
-			if err = json.Unmarshal(configJSON, &config); err != nil {
-				return nil, aoserrors.Wrap(err)
+			if err = modulehelpers.DecodeConfig(id, configJSON, &config); err != nil {
+				return nil, err
 			}
 
 			if module, err = overlaymodule.New(id, config.VersionFile, config.UpdateDir,
@@ -64,4 +61,24 @@ func init() {
 
 			return module, nil
 		})
+
+	updatehandler.RegisterValidator("overlaysystemd", modulehelpers.ConfigValidator(func() interface{} {
+		return &moduleConfig{}
+	}))
+}
+
+/*******************************************************************************
+ * Private
+ ******************************************************************************/
+
+func (config *moduleConfig) Check() (err error) {
+	if config.VersionFile == "" {
+		return aoserrors.New("version file is not set")
+	}
+
+	if config.UpdateDir == "" {
+		return aoserrors.New("update dir is not set")
+	}
+
+	return nil
 }
@@ -20,9 +20,9 @@ package eficontroller
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/aoscloud/aos_common/aoserrors"
-	"github.com/aoscloud/aos_common/partition"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/utils/efi"
@@ -40,9 +40,12 @@ const defaultLoader = "/EFI/BOOT/bootx64.efi"
 
 // Controller instance.
 type Controller struct {
+	id        string
 	efi       *efi.Instance
 	loader    string
 	bootItems []uint16
+	removed   map[uint16]uint16
+	actions   []string
 }
 
 /*******************************************************************************
@@ -53,11 +56,12 @@ type Controller struct {
  * Public
  ******************************************************************************/
 
-// New creates new instance of EFI controller.
-func New(partitions []string, loader string) (controller *Controller, err error) {
-	log.Debug("Create EFI controller")
+// New creates new instance of EFI controller. Duplicated boot entries of module partitions and boot order entries
+// without boot variables left by firmware are removed on creation.
+func New(id string, partitions []string, loader string) (controller *Controller, err error) {
+	log.WithField("id", id).Debug("Create EFI controller")
 
-	controller = &Controller{loader: defaultLoader}
+	controller = &Controller{id: id, loader: defaultLoader, removed: make(map[uint16]uint16)}
 
 	if loader != "" {
 		controller.loader = loader
@@ -71,6 +75,10 @@ func New(partitions []string, loader string) (controller *Controller, err error)
 		return nil, aoserrors.Wrap(err)
 	}
 
+	if len(controller.actions) != 0 {
+		log.WithFields(log.Fields{"id": id, "actions": controller.actions}).Info("EFI boot entries healed")
+	}
+
 	return controller, nil
 }
 
@@ -188,26 +196,47 @@ func (controller *Controller) SetBootOK() (err error) {
  ******************************************************************************/
 
 func (controller *Controller) checkPartitions(partitions []string) (err error) {
+	bootCurrent, err := controller.efi.GetBootCurrent()
+	hasCurrent := err == nil
+
+	// Boot order may be absent, in this case it is created by checkBootOrder
+	bootOrder, _ := controller.efi.GetBootOrder()
+
 	for i, part := range partitions {
-		info, err := partition.GetPartInfo(part)
+		partUUID, err := efi.GetPartUUID(part)
 		if err != nil {
 			return aoserrors.Wrap(err)
 		}
 
-		id, err := controller.efi.GetBootByPartUUID(info.PartUUID)
+		entries, err := controller.efi.GetBootsByPartUUID(partUUID)
 		if err != nil {
-			if !errors.Is(err, efi.ErrNotFound) {
-				return aoserrors.Wrap(err)
-			}
+			return aoserrors.Wrap(err)
+		}
 
+		if len(entries) == 0 {
 			log.Warnf("Boot entry for partition %s not found. Creating...", part)
 
-			if id, err = controller.efi.CreateBootEntry(1, part, controller.loader, fmt.Sprintf("Boot%d", i)); err != nil {
+			id, err := controller.efi.CreateBootEntry(1, part, controller.loader, fmt.Sprintf("Boot%d", i))
+			if err != nil {
 				return aoserrors.Wrap(err)
 			}
+
+			controller.bootItems = append(controller.bootItems, id)
+
+			continue
+		}
+
+		canonical := controller.selectBootEntry(entries, bootCurrent, hasCurrent, bootOrder)
+
+		if err = controller.removeDuplicates(part, canonical, entries); err != nil {
+			return err
 		}
 
-		controller.bootItems = append(controller.bootItems, id)
+		controller.bootItems = append(controller.bootItems, canonical.ID)
+	}
+
+	if err = controller.checkBootNext(); err != nil {
+		return aoserrors.Wrap(err)
 	}
 
 	if err = controller.checkBootOrder(); err != nil {
@@ -217,9 +246,98 @@ func (controller *Controller) checkPartitions(partitions []string) (err error) {
 	return nil
 }
 
+// selectBootEntry selects boot entry which is kept for partition: entry with configured loader is preferred, then
+// current boot entry, then entry which is first in boot order.
+func (controller *Controller) selectBootEntry(
+	entries []efi.BootEntry, bootCurrent uint16, hasCurrent bool, bootOrder []uint16,
+) (canonical efi.BootEntry) {
+	orderIndex := func(id uint16) int {
+		for i, orderItem := range bootOrder {
+			if orderItem == id {
+				return i
+			}
+		}
+
+		return len(bootOrder)
+	}
+
+	canonical = entries[0]
+
+	for _, entry := range entries[1:] {
+		if entryLoader, canonicalLoader := sameLoader(entry.Loader, controller.loader),
+			sameLoader(canonical.Loader, controller.loader); entryLoader != canonicalLoader {
+			if entryLoader {
+				canonical = entry
+			}
+
+			continue
+		}
+
+		if entryCurrent, canonicalCurrent := hasCurrent && entry.ID == bootCurrent,
+			hasCurrent && canonical.ID == bootCurrent; entryCurrent != canonicalCurrent {
+			if entryCurrent {
+				canonical = entry
+			}
+
+			continue
+		}
+
+		if orderIndex(entry.ID) < orderIndex(canonical.ID) {
+			canonical = entry
+		}
+	}
+
+	return canonical
+}
+
+func (controller *Controller) removeDuplicates(
+	part string, canonical efi.BootEntry, entries []efi.BootEntry,
+) (err error) {
+	for _, entry := range entries {
+		if entry.ID == canonical.ID || !sameLoader(entry.Loader, canonical.Loader) {
+			continue
+		}
+
+		if err = controller.efi.DeleteBootEntry(entry.ID); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		controller.removed[entry.ID] = canonical.ID
+
+		controller.addAction(fmt.Sprintf("removed duplicate boot entry %04X of partition %s, kept %04X",
+			entry.ID, part, canonical.ID))
+	}
+
+	return nil
+}
+
+func (controller *Controller) checkBootNext() (err error) {
+	bootNext, err := controller.efi.GetBootNext()
+	if err != nil {
+		if errors.Is(err, efi.ErrNotFound) {
+			return nil
+		}
+
+		return aoserrors.Wrap(err)
+	}
+
+	canonical, ok := controller.removed[bootNext]
+	if !ok {
+		return nil
+	}
+
+	if err = controller.efi.SetBootNext(canonical); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	controller.addAction(fmt.Sprintf("moved boot next from removed entry %04X to %04X", bootNext, canonical))
+
+	return nil
+}
+
 func (controller *Controller) checkBootOrder() (err error) {
 	bootOrder, err := controller.efi.GetBootOrder()
-	if err != nil {
+	if err != nil && !errors.Is(err, efi.ErrNotFound) {
 		return aoserrors.Wrap(err)
 	}
 
@@ -229,68 +347,95 @@ func (controller *Controller) checkBootOrder() (err error) {
 	partBootOrder := make([]uint16, 0, len(controller.bootItems))
 
 	for _, orderItem := range bootOrder {
+		if _, ok := controller.removed[orderItem]; ok {
+			continue
+		}
+
 		found := false
 
 		for _, bootItem := range controller.bootItems {
 			if bootItem == orderItem {
 				found = true
 
-				continue
+				break
 			}
 		}
 
 		if found {
 			appendIfNotExist(&partBootOrder, orderItem)
-		} else {
-			appendIfNotExist(&newBootOrder, orderItem)
+
+			continue
 		}
-	}
 
-	// Check that all partitions are in boot order
+		exists, err := controller.efi.BootEntryExists(orderItem)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
 
-	if len(controller.bootItems) > len(partBootOrder) {
-		for _, bootItem := range controller.bootItems {
-			present := false
+		if !exists {
+			controller.addAction(fmt.Sprintf("removed ghost boot entry %04X from boot order", orderItem))
 
-			for _, orderItem := range partBootOrder {
-				if bootItem == orderItem {
-					present = true
+			continue
+		}
 
-					continue
-				}
-			}
+		appendIfNotExist(&newBootOrder, orderItem)
+	}
 
-			if !present {
-				appendIfNotExist(&partBootOrder, bootItem)
-			}
-		}
+	// Check that all partitions are in boot order
+
+	for _, bootItem := range controller.bootItems {
+		appendIfNotExist(&partBootOrder, bootItem)
 	}
 
 	newBootOrder = append(partBootOrder, newBootOrder...)
 
 	// Update boot order if required
 
-	updateBootOrder := false
+	updateBootOrder := len(newBootOrder) != len(bootOrder)
 
-	for i, newOrderItem := range newBootOrder {
-		if newOrderItem != bootOrder[i] {
+	for i := 0; i < len(newBootOrder) && !updateBootOrder; i++ {
+		if newBootOrder[i] != bootOrder[i] {
 			updateBootOrder = true
-
-			break
 		}
 	}
 
 	if updateBootOrder {
-		log.Warn("Boot order need to be updated")
+		log.WithField("id", controller.id).Warn("Boot order need to be updated")
 
 		if err = controller.efi.SetBootOrder(newBootOrder); err != nil {
 			return aoserrors.Wrap(err)
 		}
+
+		controller.addAction("rebuilt boot order: " + bootOrderToString(newBootOrder))
 	}
 
 	return nil
 }
 
+func (controller *Controller) addAction(action string) {
+	log.WithFields(log.Fields{"id": controller.id, "action": action}).Warn("Heal EFI boot entries")
+
+	controller.actions = append(controller.actions, action)
+}
+
+func sameLoader(loader1, loader2 string) (same bool) {
+	normalize := func(loader string) string {
+		return strings.TrimPrefix(strings.ReplaceAll(loader, "\\", "/"), "/")
+	}
+
+	return strings.EqualFold(normalize(loader1), normalize(loader2))
+}
+
+func bootOrderToString(bootOrder []uint16) (s string) {
+	items := make([]string, 0, len(bootOrder))
+
+	for _, item := range bootOrder {
+		items = append(items, fmt.Sprintf("%04X", item))
+	}
+
+	return strings.Join(items, ",")
+}
+
 func appendIfNotExist(slice *[]uint16, newItem uint16) {
 	for _, item := range *slice {
 		if newItem == item {
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build fakeefi
+
+package eficontroller_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/controllers/eficontroller"
+	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/utils/efi"
+)
+
+/*******************************************************************************
+ * Consts
+ ******************************************************************************/
+
+const (
+	loader      = "\\EFI\\BOOT\\bootx64.efi"
+	otherLoader = "\\EFI\\BOOT\\other.efi"
+)
+
+/*******************************************************************************
+ * Variables
+ ******************************************************************************/
+
+var partitions = []string{"/dev/fake1", "/dev/fake2"}
+
+/*******************************************************************************
+ * Init
+ ******************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/*******************************************************************************
+ * Tests
+ ******************************************************************************/
+
+func TestBootSwitch(t *testing.T) {
+	instance := resetEFI(t)
+
+	efi.AddFakeBootEntry(0, "uuid-pxe", "\\EFI\\pxe.efi", "PXE")
+
+	if err := instance.SetBootOrder([]uint16{0}); err != nil {
+		t.Fatalf("Can't set boot order: %v", err)
+	}
+
+	controller, err := eficontroller.New("test", partitions, "")
+	if err != nil {
+		t.Fatalf("Can't create EFI controller: %v", err)
+	}
+	defer controller.Close()
+
+	// Boot entries are created for partitions and placed first in boot order
+
+	checkBootOrder(t, instance, []uint16{1, 2, 0})
+
+	fakeReboot(t, 1)
+	checkBoot(t, controller, 0, 0)
+
+	// Boot next is used once: firmware falls back to main partition if new one is not confirmed
+
+	if err = controller.SetMainBoot(1); err != nil {
+		t.Fatalf("Can't set main boot: %v", err)
+	}
+
+	fakeReboot(t, 2)
+	checkBoot(t, controller, 1, 0)
+
+	fakeReboot(t, 1)
+	checkBoot(t, controller, 0, 0)
+
+	// Confirmed boot makes partition main
+
+	if err = controller.SetMainBoot(1); err != nil {
+		t.Fatalf("Can't set main boot: %v", err)
+	}
+
+	fakeReboot(t, 2)
+
+	if err = controller.SetBootOK(); err != nil {
+		t.Fatalf("Can't set boot OK: %v", err)
+	}
+
+	checkBootOrder(t, instance, []uint16{2, 1, 0})
+
+	fakeReboot(t, 2)
+	checkBoot(t, controller, 1, 1)
+}
+
+func TestHealBootEntries(t *testing.T) {
+	instance := resetEFI(t)
+
+	efi.AddFakeBootEntry(1, "uuid1", loader, "Boot0")
+	efi.AddFakeBootEntry(2, "uuid2", loader, "Boot1")
+	efi.AddFakeBootEntry(3, "uuid1", loader, "Boot0")
+	efi.AddFakeBootEntry(4, "uuid1", otherLoader, "Other")
+	efi.SetFakeBootCurrent(1)
+
+	if err := instance.SetBootOrder([]uint16{3, 2, 1, 5}); err != nil {
+		t.Fatalf("Can't set boot order: %v", err)
+	}
+
+	if err := instance.SetBootNext(3); err != nil {
+		t.Fatalf("Can't set boot next: %v", err)
+	}
+
+	controller, err := eficontroller.New("test", partitions, "")
+	if err != nil {
+		t.Fatalf("Can't create EFI controller: %v", err)
+	}
+	defer controller.Close()
+
+	// Duplicate of current entry is removed, entry with other loader is kept
+
+	for id, expected := range map[uint16]bool{1: true, 2: true, 3: false, 4: true} {
+		exists, err := instance.BootEntryExists(id)
+		if err != nil {
+			t.Fatalf("Can't check boot entry: %v", err)
+		}
+
+		if exists != expected {
+			t.Errorf("Wrong boot entry %04X exists: %v", id, exists)
+		}
+	}
+
+	bootNext, err := instance.GetBootNext()
+	if err != nil {
+		t.Fatalf("Can't get boot next: %v", err)
+	}
+
+	if bootNext != 1 {
+		t.Errorf("Wrong boot next: %04X", bootNext)
+	}
+
+	// Removed duplicate and ghost entry are dropped from boot order
+
+	checkBootOrder(t, instance, []uint16{2, 1})
+
+	checkBoot(t, controller, 0, 1)
+}
+
+func TestCreateBootEntryWithoutBootOrder(t *testing.T) {
+	instance := resetEFI(t)
+
+	if _, err := eficontroller.New("test", partitions, ""); !errors.Is(err, efi.ErrNotFound) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, err := instance.GetBootOrder(); !errors.Is(err, efi.ErrNotFound) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+/*******************************************************************************
+ * Private
+ ******************************************************************************/
+
+func resetEFI(t *testing.T) (instance *efi.Instance) {
+	t.Helper()
+
+	efi.ResetFake()
+
+	efi.SetFakePartUUID(partitions[0], "uuid1")
+	efi.SetFakePartUUID(partitions[1], "uuid2")
+
+	instance, err := efi.New()
+	if err != nil {
+		t.Fatalf("Can't create EFI instance: %v", err)
+	}
+
+	return instance
+}
+
+func fakeReboot(t *testing.T, expected uint16) {
+	t.Helper()
+
+	id, err := efi.FakeReboot()
+	if err != nil {
+		t.Fatalf("Can't reboot: %v", err)
+	}
+
+	if id != expected {
+		t.Errorf("Wrong boot entry: %04X", id)
+	}
+}
+
+func checkBoot(t *testing.T, controller *eficontroller.Controller, current, main int) {
+	t.Helper()
+
+	index, err := controller.GetCurrentBoot()
+	if err != nil {
+		t.Fatalf("Can't get current boot: %v", err)
+	}
+
+	if index != current {
+		t.Errorf("Wrong current boot: %d", index)
+	}
+
+	if index, err = controller.GetMainBoot(); err != nil {
+		t.Fatalf("Can't get main boot: %v", err)
+	}
+
+	if index != main {
+		t.Errorf("Wrong main boot: %d", index)
+	}
+}
+
+func checkBootOrder(t *testing.T, instance *efi.Instance, expected []uint16) {
+	t.Helper()
+
+	bootOrder, err := instance.GetBootOrder()
+	if err != nil {
+		t.Fatalf("Can't get boot order: %v", err)
+	}
+
+	if !reflect.DeepEqual(bootOrder, expected) {
+		t.Errorf("Wrong boot order: %v", bootOrder)
+	}
+}
@@ -18,11 +18,16 @@
 package dualpartmodule
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"os"
 	"path"
 	"regexp"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/image"
@@ -31,6 +36,9 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/updatemodules/modulehelpers"
+	"github.com/aoscloud/aos_updatemanager/utils/mountns"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
 )
 
 // The sequence diagram of update:
@@ -52,7 +60,9 @@ import (
 //
 // * Apply()                              return status and start same update
 //                                        on other partition, if it fails try to
-//                                        copy content from current partition
+//                                        copy content from current partition.
+//                                        If background sync is configured, the
+//                                        content is copied in background
 //
 // * Reboot()                             Reboot if reboot flag was set
 //------------------------------------------------------------------------------
@@ -68,6 +78,12 @@ import (
 //
 // * Reboot()                              Reboot if reboot flag was set
 //------------------------------- Reboot ---------------------------------------
+//
+// Background sync copies active partition content to the fallback one with
+// limited rate after apply. Pending sync is persisted and restarted on init if
+// the system is booted from the synced partition. Sync is stopped by any other
+// module operation: next update overwrites the fallback partition anyway and
+// revert copies it from the active one.
 
 /*******************************************************************************
  * Constants
@@ -81,6 +97,11 @@ const (
 
 const numPartitions = 2
 
+const (
+	defaultSyncRateLimit = 16 * 1024 * 1024
+	syncChunkSize        = 1024 * 1024
+)
+
 /*******************************************************************************
  * Types
  ******************************************************************************/
@@ -95,6 +116,21 @@ type UpdateChecker interface {
 	Check() (err error)
 }
 
+// BootEnvBackup handler for backup and restore of bootloader environment.
+type BootEnvBackup interface {
+	Snapshot() (err error)
+	Restore() (err error)
+	Remove() (err error)
+}
+
+// PartitionMapper provides access to partitions behind device-mapper (LUKS, LVM). Partitions are accessed through
+// mapped devices, boot controller and exported state keep using physical partitions.
+type PartitionMapper interface {
+	Open(index int) (device string, err error)
+	Close(index int) (err error)
+	Release() (err error)
+}
+
 // DualPartModule update dual partition module.
 type DualPartModule struct {
 	id string
@@ -103,12 +139,25 @@ type DualPartModule struct {
 	controller       StateController
 	rebootHandler    RebootHandler
 	checker          UpdateChecker
+	bootEnv          BootEnvBackup
 	partitions       []string
 	currentPartition int
 	state            moduleState
 	versionFile      string
 	vendorVersion    string
 	bootErr          error
+	journal          *opjournal.Journal
+	privateMounts    bool
+	mapper           PartitionMapper
+	syncConfig       *SyncConfig
+	syncCancel       context.CancelFunc
+	syncDone         chan struct{}
+}
+
+// SyncConfig background sync config.
+type SyncConfig struct {
+	// RateLimit sync rate limit in bytes per second
+	RateLimit uint64 `json:"rateLimit"`
 }
 
 // StateController state controller interface.
@@ -127,9 +176,12 @@ type Storage interface {
 }
 
 type moduleState struct {
-	State           updateState `json:"state"`
-	UpdatePartition int         `json:"updatePartition"`
-	ImagePath       string      `json:"imagePath"`
+	State           updateState       `json:"state"`
+	UpdatePartition int               `json:"updatePartition"`
+	ImagePath       string            `json:"imagePath"`
+	SyncPending     bool              `json:"syncPending,omitempty"`
+	SyncPartition   int               `json:"syncPartition,omitempty"`
+	Checks          map[string]string `json:"checks,omitempty"`
 }
 
 type updateState int
@@ -138,11 +190,13 @@ type updateState int
  * Public
  **********************************************************************************************************************/
 
-// New creates fs update module instance.
+// New creates fs update module instance. If privateMounts is set, partitions are mounted for version check in private
+// mount namespace. If mapper is set, partitions are accessed through device-mapper targets. If syncConfig is set,
+// fallback partition is synchronized in background after apply.
 func New(id string, partitions []string, versionFile string, controller StateController,
 	storage updatehandler.ModuleStorage, rebootHandler RebootHandler,
-	checker UpdateChecker,
-) (updateModule updatehandler.UpdateModule, err error) {
+	checker UpdateChecker, bootEnv BootEnvBackup, privateMounts bool, mapper PartitionMapper, syncConfig *SyncConfig,
+) (updateModule updatehandler.LegacyUpdateModule, err error) {
 	log.WithField("module", id).Debug("Create dualpart module")
 
 	module := &DualPartModule{
@@ -152,7 +206,12 @@ func New(id string, partitions []string, versionFile string, controller StateCon
 		storage:       storage,
 		rebootHandler: rebootHandler,
 		checker:       checker,
+		bootEnv:       bootEnv,
 		versionFile:   versionFile,
+		journal:       opjournal.New(id, storage),
+		privateMounts: privateMounts,
+		mapper:        mapper,
+		syncConfig:    syncConfig,
 	}
 
 	if len(partitions) != numPartitions {
@@ -166,8 +225,16 @@ func New(id string, partitions []string, versionFile string, controller StateCon
 func (module *DualPartModule) Close() (err error) {
 	log.WithFields(log.Fields{"id": module.id}).Debug("Close dualpart module")
 
+	module.stopSync()
+
 	module.controller.Close()
 
+	if module.mapper != nil {
+		if err = module.mapper.Release(); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
 	return nil
 }
 
@@ -190,6 +257,8 @@ func (module *DualPartModule) Init() (err error) {
 
 	log.WithFields(log.Fields{"id": module.id}).Debug("Init dualpart module")
 
+	module.stopSync()
+
 	if err = module.controller.SetBootOK(); err != nil {
 		return aoserrors.Wrap(err)
 	}
@@ -211,17 +280,37 @@ func (module *DualPartModule) Init() (err error) {
 		log.WithFields(log.Fields{"id": module.id}).Warn("Boot from fallback partition")
 	}
 
-	if module.vendorVersion, err = module.getModuleVersion(module.partitions[module.currentPartition]); err != nil {
+	if module.vendorVersion, err = module.getModuleVersion(module.currentPartition); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
+	if module.state.State == updatedState && module.currentPartition != module.state.UpdatePartition {
+		module.bootErr = modulehelpers.NewBootFailure("update was failed")
+	}
+
 	if module.checker != nil && module.bootErr == nil {
-		module.bootErr = aoserrors.Wrap(module.checker.Check())
+		var checks map[string]string
+
+		// Check annotations are asserted only after boot with update
+		if module.state.State == updatedState {
+			checks = module.state.Checks
+		}
+
+		module.bootErr = modulehelpers.CheckUpdate(module.checker, checks)
+	}
+
+	if module.state.State == idleState && module.state.SyncPending {
+		module.resumeSync()
 	}
 
 	return nil
 }
 
+// GetMetadata returns boot failure kind and reason if boot with update failed.
+func (module *DualPartModule) GetMetadata() (metadata map[string]string, err error) {
+	return modulehelpers.GetBootFailureMetadata(module.bootErr), nil
+}
+
 // GetVendorVersion returns vendor version.
 func (module *DualPartModule) GetVendorVersion() (version string, err error) {
 	return module.vendorVersion, nil
@@ -235,6 +324,8 @@ func (module *DualPartModule) Prepare(imagePath string, vendorVersion string, an
 		"vendorVersion": vendorVersion,
 	}).Debug("Prepare dualpart module")
 
+	module.stopSync()
+
 	if module.state.State != idleState && module.state.State != preparedState {
 		return aoserrors.Errorf("wrong state during Prepare command. Expected %d, got %d", idleState,
 			module.state.State)
@@ -244,6 +335,10 @@ func (module *DualPartModule) Prepare(imagePath string, vendorVersion string, an
 		return aoserrors.Wrap(err)
 	}
 
+	if module.state.Checks, err = modulehelpers.GetCheckAnnotations(annotations, module.checker); err != nil {
+		return err
+	}
+
 	module.state.ImagePath = imagePath
 
 	if err = module.setState(preparedState); err != nil {
@@ -257,17 +352,19 @@ func (module *DualPartModule) Prepare(imagePath string, vendorVersion string, an
 func (module *DualPartModule) Update() (rebootRequired bool, err error) {
 	log.WithFields(log.Fields{"id": module.id}).Debug("Update dualpart module")
 
+	module.stopSync()
+
 	if module.state.State == updatedState {
 		log.Debugf("Current partition %d, update partition = %d", module.currentPartition, module.state.UpdatePartition)
 
-		if module.currentPartition != module.state.UpdatePartition {
-			return false, aoserrors.Errorf("update was failed")
-		}
-
 		if module.bootErr != nil {
 			return false, aoserrors.Wrap(module.bootErr)
 		}
 
+		if module.currentPartition != module.state.UpdatePartition {
+			return false, aoserrors.Errorf("update was failed")
+		}
+
 		return false, nil
 	}
 
@@ -280,8 +377,22 @@ func (module *DualPartModule) Update() (rebootRequired bool, err error) {
 
 	module.state.UpdatePartition = secPartition
 
-	if _, err = image.CopyFromGzipArchiveToDevice(
-		module.partitions[secPartition], module.state.ImagePath, true); err != nil {
+	if module.bootEnv != nil {
+		if err = module.bootEnv.Snapshot(); err != nil {
+			return false, aoserrors.Wrap(err)
+		}
+	}
+
+	startTime := time.Now()
+
+	err = module.accessPartitions(func(devices []string) (err error) {
+		_, err = image.CopyFromGzipArchiveToDevice(devices[0], module.state.ImagePath, true)
+
+		return aoserrors.Wrap(err)
+	}, secPartition)
+	module.journal.File(opjournal.FileCopy, startTime, err, module.state.ImagePath, module.partitions[secPartition])
+
+	if err != nil {
 		return false, aoserrors.Wrap(err)
 	}
 
@@ -289,6 +400,8 @@ func (module *DualPartModule) Update() (rebootRequired bool, err error) {
 		return false, aoserrors.Wrap(err)
 	}
 
+	module.state.SyncPending = false
+
 	if err = module.setState(updatedState); err != nil {
 		return false, aoserrors.Wrap(err)
 	}
@@ -300,6 +413,8 @@ func (module *DualPartModule) Update() (rebootRequired bool, err error) {
 func (module *DualPartModule) Revert() (rebootRequired bool, err error) {
 	log.WithFields(log.Fields{"id": module.id}).Debug("Revert dualpart module")
 
+	module.stopSync()
+
 	if module.state.State == idleState {
 		return false, nil
 	}
@@ -307,19 +422,28 @@ func (module *DualPartModule) Revert() (rebootRequired bool, err error) {
 	updatePartition := module.state.UpdatePartition
 	secPartition := (updatePartition + 1) % len(module.partitions)
 
-	if _, err = image.CopyToDevice(
-		module.partitions[updatePartition], module.partitions[secPartition], true); err != nil {
+	if err = module.copyPartition(secPartition, updatePartition); err != nil {
 		return false, aoserrors.Wrap(err)
 	}
 
+	if module.bootEnv != nil && module.state.State == updatedState {
+		if err = module.bootEnv.Restore(); err != nil {
+			return false, aoserrors.Wrap(err)
+		}
+	}
+
 	if err = module.controller.SetMainBoot(secPartition); err != nil {
 		return false, aoserrors.Wrap(err)
 	}
 
+	module.state.SyncPending = false
+
 	if err = module.setState(idleState); err != nil {
 		return false, aoserrors.Wrap(err)
 	}
 
+	module.removeBootEnv()
+
 	if module.currentPartition == module.state.UpdatePartition {
 		rebootRequired = true
 	}
@@ -331,6 +455,8 @@ func (module *DualPartModule) Revert() (rebootRequired bool, err error) {
 func (module *DualPartModule) Apply() (rebootRequired bool, err error) {
 	log.WithFields(log.Fields{"id": module.id}).Debug("Apply dualpart module")
 
+	module.stopSync()
+
 	// Skip if update was already applied
 	if module.state.State == idleState {
 		return false, nil
@@ -344,8 +470,10 @@ func (module *DualPartModule) Apply() (rebootRequired bool, err error) {
 	currentPartition := module.state.UpdatePartition
 	secPartition := (currentPartition + 1) % len(module.partitions)
 
-	if _, err = image.CopyToDevice(
-		module.partitions[secPartition], module.partitions[currentPartition], true); err != nil {
+	if module.syncConfig != nil {
+		module.state.SyncPending = true
+		module.state.SyncPartition = secPartition
+	} else if err = module.copyPartition(currentPartition, secPartition); err != nil {
 		return false, aoserrors.Wrap(err)
 	}
 
@@ -353,13 +481,89 @@ func (module *DualPartModule) Apply() (rebootRequired bool, err error) {
 		return false, aoserrors.Wrap(err)
 	}
 
+	module.removeBootEnv()
+
+	if module.state.SyncPending {
+		module.startSync(currentPartition, secPartition)
+	}
+
 	return false, nil
 }
 
+// GetTargetSpace returns size of fallback partition which is updated by image.
+func (module *DualPartModule) GetTargetSpace() (space uint64, err error) {
+	// Fallback partition is accessed, so the sync is stopped as prepare would do
+	module.stopSync()
+
+	secPartition := (module.currentPartition + 1) % len(module.partitions)
+
+	err = module.accessPartitions(func(devices []string) (err error) {
+		space, err = getDeviceSize(devices[0])
+
+		return err
+	}, secPartition)
+
+	return space, err
+}
+
+// GetExportState returns update decision to be consumed by initramfs.
+func (module *DualPartModule) GetExportState() (state map[string]string) {
+	state = map[string]string{"STATE": module.state.State.String()}
+
+	if module.state.State == updatedState && module.state.UpdatePartition < len(module.partitions) {
+		state["TARGET_PARTITION"] = strconv.Itoa(module.state.UpdatePartition)
+		state["TARGET_DEVICE"] = module.partitions[module.state.UpdatePartition]
+	}
+
+	return state
+}
+
+// GetMaintenanceActions returns supported maintenance actions.
+func (module *DualPartModule) GetMaintenanceActions() (actions []string) {
+	if module.checker != nil {
+		actions = append(actions, updatehandler.MaintenanceHealthCheck)
+	}
+
+	return append(actions, updatehandler.MaintenanceResyncBoot)
+}
+
+// RunMaintenance runs maintenance action.
+func (module *DualPartModule) RunMaintenance(ctx context.Context, action string) (err error) {
+	log.WithFields(log.Fields{"id": module.id, "action": action}).Debug("Run dualpart module maintenance")
+
+	switch action {
+	case updatehandler.MaintenanceHealthCheck:
+		if module.checker == nil {
+			return aoserrors.New("update checker is not configured")
+		}
+
+		return aoserrors.Wrap(module.checker.Check())
+
+	case updatehandler.MaintenanceResyncBoot:
+		if module.state.State != idleState {
+			return aoserrors.Errorf("wrong state during boot resync. Expected %d, got %d", idleState,
+				module.state.State)
+		}
+
+		// Boot from current partition is known to be good: make it main boot entry again
+		if err = module.controller.SetMainBoot(module.currentPartition); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		return aoserrors.Wrap(module.controller.SetBootOK())
+
+	default:
+		return aoserrors.Errorf("unsupported maintenance action %s", action)
+	}
+}
+
 // Reboot performs module reboot.
 func (module *DualPartModule) Reboot() (err error) {
 	log.WithFields(log.Fields{"id": module.id}).Debugf("Reboot dualpart module")
 
+	// Pending sync is restarted after reboot
+	module.stopSync()
+
 	if module.rebootHandler == nil {
 		return nil
 	}
@@ -367,7 +571,12 @@ func (module *DualPartModule) Reboot() (err error) {
 	// Close controller before reboot
 	module.controller.Close()
 
-	return aoserrors.Wrap(module.rebootHandler.Reboot())
+	startTime := time.Now()
+
+	err = module.rebootHandler.Reboot()
+	module.journal.Command("reboot", startTime, err)
+
+	return aoserrors.Wrap(err)
 }
 
 /***********************************************************************************************************************
@@ -400,6 +609,10 @@ func (module *DualPartModule) setState(state updateState) (err error) {
 
 	module.state.State = state
 
+	return module.saveState()
+}
+
+func (module *DualPartModule) saveState() (err error) {
 	stateJSON, err := json.Marshal(module.state)
 	if err != nil {
 		return aoserrors.Wrap(err)
@@ -412,7 +625,226 @@ func (module *DualPartModule) setState(state updateState) (err error) {
 	return nil
 }
 
-func (module *DualPartModule) getModuleVersion(part string) (version string, err error) {
+func (module *DualPartModule) removeBootEnv() {
+	if module.bootEnv == nil {
+		return
+	}
+
+	if err := module.bootEnv.Remove(); err != nil {
+		log.WithField("id", module.id).Errorf("Can't remove boot environment snapshot: %v", err)
+	}
+}
+
+// accessPartitions performs operation on devices of partitions. Mapped devices are opened before operation and
+// closed in reverse order after it, so the destination is flushed and closed before its source.
+func (module *DualPartModule) accessPartitions(operation func(devices []string) error, indexes ...int) (err error) {
+	devices := make([]string, 0, len(indexes))
+
+	if module.mapper == nil {
+		for _, index := range indexes {
+			devices = append(devices, module.partitions[index])
+		}
+
+		return operation(devices)
+	}
+
+	for i, index := range indexes {
+		device, err := module.mapper.Open(index)
+		if err != nil {
+			_ = module.closePartitions(indexes[:i])
+
+			return aoserrors.Wrap(err)
+		}
+
+		devices = append(devices, device)
+	}
+
+	err = operation(devices)
+
+	if closeErr := module.closePartitions(indexes); closeErr != nil && err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (module *DualPartModule) closePartitions(indexes []int) (err error) {
+	for i := len(indexes) - 1; i >= 0; i-- {
+		if closeErr := module.mapper.Close(indexes[i]); closeErr != nil {
+			log.WithField("id", module.id).Errorf("Can't close partition %d: %v", indexes[i], closeErr)
+
+			if err == nil {
+				err = aoserrors.Wrap(closeErr)
+			}
+		}
+	}
+
+	return err
+}
+
+func (module *DualPartModule) copyPartition(srcIndex, dstIndex int) (err error) {
+	startTime := time.Now()
+
+	err = module.accessPartitions(func(devices []string) (err error) {
+		_, err = image.CopyToDevice(devices[1], devices[0], true)
+
+		return aoserrors.Wrap(err)
+	}, srcIndex, dstIndex)
+	module.journal.File(opjournal.FileCopy, startTime, err, module.partitions[srcIndex], module.partitions[dstIndex])
+
+	return err
+}
+
+// resumeSync restarts pending sync if the system is booted from the partition which should be synced.
+func (module *DualPartModule) resumeSync() {
+	dstIndex := module.state.SyncPartition
+	srcIndex := (dstIndex + 1) % len(module.partitions)
+
+	if module.syncConfig == nil || module.currentPartition != srcIndex {
+		log.WithFields(log.Fields{
+			"id": module.id, "partition": dstIndex,
+		}).Warn("Skip pending sync of fallback partition")
+
+		module.state.SyncPending = false
+
+		if err := module.saveState(); err != nil {
+			log.WithField("id", module.id).Errorf("Can't save state: %v", err)
+		}
+
+		return
+	}
+
+	module.startSync(srcIndex, dstIndex)
+}
+
+func (module *DualPartModule) startSync(srcIndex, dstIndex int) {
+	log.WithFields(log.Fields{"id": module.id, "src": srcIndex, "dst": dstIndex}).Debug("Start background sync")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	module.syncCancel = cancel
+	module.syncDone = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+
+		startTime := time.Now()
+
+		err := module.accessPartitions(func(devices []string) (err error) {
+			return module.syncDevice(ctx, devices[1], devices[0])
+		}, srcIndex, dstIndex)
+		module.journal.File(opjournal.FileCopy, startTime, err, module.partitions[srcIndex],
+			module.partitions[dstIndex])
+
+		if err != nil {
+			if ctx.Err() == nil {
+				log.WithField("id", module.id).Errorf("Background sync failed: %v", err)
+			}
+
+			return
+		}
+
+		log.WithFields(log.Fields{"id": module.id, "dst": dstIndex}).Debug("Background sync finished")
+
+		module.state.SyncPending = false
+
+		if err = module.saveState(); err != nil {
+			log.WithField("id", module.id).Errorf("Can't save state: %v", err)
+		}
+	}(module.syncDone)
+}
+
+// stopSync cancels running background sync and waits till it is finished. Sync stays pending.
+func (module *DualPartModule) stopSync() {
+	if module.syncCancel == nil {
+		return
+	}
+
+	module.syncCancel()
+	<-module.syncDone
+
+	module.syncCancel, module.syncDone = nil, nil
+}
+
+// syncDevice copies src to dst with rate limit.
+func (module *DualPartModule) syncDevice(ctx context.Context, dst, src string) (err error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_RDWR|os.O_TRUNC, 0)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer dstFile.Close()
+
+	rateLimit := module.syncConfig.RateLimit
+	if rateLimit == 0 {
+		rateLimit = defaultSyncRateLimit
+	}
+
+	var (
+		copied    uint64
+		startTime = time.Now()
+	)
+
+	for {
+		written, err := io.CopyN(dstFile, srcFile, syncChunkSize)
+		copied += uint64(written)
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		// Wait till copied amount fits the rate limit
+		delay := time.Duration(float64(copied)/float64(rateLimit)*float64(time.Second)) - time.Since(startTime)
+
+		if delay <= 0 {
+			if err = ctx.Err(); err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return aoserrors.Wrap(ctx.Err())
+
+		case <-time.After(delay):
+		}
+	}
+
+	return aoserrors.Wrap(dstFile.Sync())
+}
+
+func (module *DualPartModule) getModuleVersion(index int) (version string, err error) {
+	if err = module.accessPartitions(func(devices []string) (err error) {
+		if !module.privateMounts {
+			version, err = module.readModuleVersion(devices[0])
+
+			return err
+		}
+
+		return aoserrors.Wrap(mountns.Run(func() (err error) {
+			version, err = module.readModuleVersion(devices[0])
+
+			return err
+		}))
+	}, index); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
+func (module *DualPartModule) readModuleVersion(part string) (version string, err error) {
 	mountDir, err := os.MkdirTemp("", "aos_")
 	if err != nil {
 		return "", aoserrors.Wrap(err)
@@ -451,3 +883,18 @@ func (module *DualPartModule) getModuleVersion(part string) (version string, err
 
 	return string(data[loc[2]:loc[3]]), nil
 }
+
+func getDeviceSize(device string) (size uint64, err error) {
+	file, err := os.Open(device)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	end, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	return uint64(end), nil
+}
@@ -25,12 +25,16 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	"github.com/aoscloud/aos_common/utils/testtools"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/modules/dualpartmodule"
 )
 
@@ -45,6 +49,9 @@ const (
 
 const versionFile = "/etc/version.txt"
 
+// Test partition size in MiB.
+const partSize = 32
+
 /***********************************************************************************************************************
  * Types
  **********************************************************************************************************************/
@@ -56,6 +63,7 @@ type testStateController struct {
 }
 
 type testStateStorage struct {
+	sync.Mutex
 	state []byte
 }
 
@@ -68,6 +76,18 @@ type testChecker struct {
 	err error
 }
 
+type testBootEnv struct {
+	snapshot bool
+	restored bool
+	removed  bool
+}
+
+type testMapper struct {
+	devices  []string
+	ops      []string
+	released bool
+}
+
 /***********************************************************************************************************************
  * Var
  **********************************************************************************************************************/
@@ -110,8 +130,8 @@ func TestMain(m *testing.M) {
 	if disk, err = testtools.NewTestDisk(
 		path.Join(tmpDir, "testdisk.img"),
 		[]testtools.PartDesc{
-			{Type: "ext4", Label: "platform", Size: 32},
-			{Type: "ext4", Label: "platform", Size: 32},
+			{Type: "ext4", Label: "platform", Size: partSize},
+			{Type: "ext4", Label: "platform", Size: partSize},
 		}); err != nil {
 		log.Fatalf("Can't create test disk: %s", err)
 	}
@@ -145,7 +165,7 @@ func TestUpdate(t *testing.T) {
 	module, err := dualpartmodule.New("test", []string{
 		disk.Partitions[part0].Device,
 		disk.Partitions[part1].Device,
-	}, versionFile, &stateController, &stateStorage, nil, nil)
+	}, versionFile, &stateController, &stateStorage, nil, nil, nil, true, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create test module: %s", err)
 	}
@@ -178,6 +198,15 @@ func TestUpdate(t *testing.T) {
 		t.Error("Error, set boot OK failed")
 	}
 
+	space, err := module.(updatehandler.TargetSpaceProvider).GetTargetSpace() //nolint:forcetypeassert
+	if err != nil {
+		t.Fatalf("Can't get target space: %s", err)
+	}
+
+	if space != partSize*1024*1024 {
+		t.Errorf("Wrong target space: %d", space)
+	}
+
 	// Prepare
 
 	if err = module.Prepare(imagePath, updateVersion, nil); err != nil {
@@ -260,11 +289,85 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestPartitionMapper(t *testing.T) {
+	mapper := &testMapper{devices: []string{disk.Partitions[part0].Device, disk.Partitions[part1].Device}}
+
+	module, err := dualpartmodule.New("test", []string{"/dev/encrypted0", "/dev/encrypted1"},
+		versionFile, &stateController, &stateStorage, nil, nil, nil, false, mapper, nil)
+	if err != nil {
+		t.Fatalf("Can't create test module: %s", err)
+	}
+
+	imagePath := path.Join(tmpDir, "image.gz")
+
+	updateVersion := "v3.0"
+
+	if _, err = generateImage(imagePath, updateVersion); err != nil {
+		t.Fatalf("Can't generate image: %s", err)
+	}
+
+	stateController.bootMain = part0
+	stateController.bootCurrent = part0
+
+	if err = module.Init(); err != nil {
+		t.Fatalf("Error init module: %s", err)
+	}
+
+	if err = module.Prepare(imagePath, updateVersion, nil); err != nil {
+		t.Fatalf("Error prepare module: %s", err)
+	}
+
+	if _, err = module.Update(); err != nil {
+		t.Fatalf("Error update module: %s", err)
+	}
+
+	state := module.(updatehandler.StateExporter).GetExportState() //nolint:forcetypeassert
+
+	if state["TARGET_DEVICE"] != "/dev/encrypted1" {
+		t.Errorf("Wrong target device: %s", state["TARGET_DEVICE"])
+	}
+
+	stateController.bootCurrent = part1
+
+	if err = module.Init(); err != nil {
+		t.Fatalf("Error init module: %s", err)
+	}
+
+	if _, err = module.Update(); err != nil {
+		t.Fatalf("Error update module: %s", err)
+	}
+
+	if _, err = module.Apply(); err != nil {
+		t.Fatalf("Error apply module: %s", err)
+	}
+
+	if version, _ := module.GetVendorVersion(); version != updateVersion {
+		t.Errorf("Wrong vendor version: %s", version)
+	}
+
+	if err = module.Close(); err != nil {
+		t.Errorf("Error close module: %s", err)
+	}
+
+	// Destination is closed before source on copy
+	expectedOps := []string{
+		"open 0", "close 0", "open 1", "close 1", "open 1", "close 1", "open 1", "open 0", "close 0", "close 1",
+	}
+
+	if !reflect.DeepEqual(mapper.ops, expectedOps) {
+		t.Errorf("Wrong mapper operations: %v", mapper.ops)
+	}
+
+	if !mapper.released {
+		t.Error("Mapper is not released")
+	}
+}
+
 func TestRevert(t *testing.T) {
 	module, err := dualpartmodule.New("test", []string{
 		disk.Partitions[part0].Device,
 		disk.Partitions[part1].Device,
-	}, versionFile, &stateController, &stateStorage, nil, nil)
+	}, versionFile, &stateController, &stateStorage, nil, nil, nil, false, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create test module: %s", err)
 	}
@@ -395,10 +498,12 @@ func TestRevert(t *testing.T) {
 }
 
 func TestRevertOnFail(t *testing.T) {
+	bootEnv := &testBootEnv{}
+
 	module, err := dualpartmodule.New("test", []string{
 		disk.Partitions[part0].Device,
 		disk.Partitions[part1].Device,
-	}, versionFile, &stateController, &stateStorage, nil, nil)
+	}, versionFile, &stateController, &stateStorage, nil, nil, bootEnv, false, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create test module: %s", err)
 	}
@@ -480,6 +585,10 @@ func TestRevertOnFail(t *testing.T) {
 		t.Errorf("Reboot is not required")
 	}
 
+	if !bootEnv.snapshot || !bootEnv.restored || !bootEnv.removed {
+		t.Errorf("Boot environment is not restored: %+v", *bootEnv)
+	}
+
 	// Check
 
 	version, err := module.GetVendorVersion()
@@ -505,13 +614,95 @@ func TestRevertOnFail(t *testing.T) {
 	}
 }
 
+func TestBackgroundSync(t *testing.T) {
+	module, err := dualpartmodule.New("test", []string{
+		disk.Partitions[part0].Device,
+		disk.Partitions[part1].Device,
+	}, versionFile, &stateController, &stateStorage, nil, nil, nil, false, nil,
+		&dualpartmodule.SyncConfig{RateLimit: 64 * 1024 * 1024})
+	if err != nil {
+		t.Fatalf("Can't create test module: %s", err)
+	}
+	defer module.Close()
+
+	imagePath := path.Join(tmpDir, "image.gz")
+
+	updateVersion := "v4.0"
+
+	if _, err = generateImage(imagePath, updateVersion); err != nil {
+		t.Fatalf("Can't generate image: %s", err)
+	}
+
+	stateController.bootMain = part0
+	stateController.bootCurrent = part0
+
+	if err = module.Init(); err != nil {
+		t.Fatalf("Error init module: %s", err)
+	}
+
+	if err = module.Prepare(imagePath, updateVersion, nil); err != nil {
+		t.Fatalf("Error prepare module: %s", err)
+	}
+
+	if _, err = module.Update(); err != nil {
+		t.Fatalf("Error update module: %s", err)
+	}
+
+	stateController.bootCurrent = part1
+
+	if err = module.Init(); err != nil {
+		t.Fatalf("Error init module: %s", err)
+	}
+
+	if _, err = module.Update(); err != nil {
+		t.Fatalf("Error update module: %s", err)
+	}
+
+	// Apply returns at once, fallback partition is synced in background
+
+	if _, err = module.Apply(); err != nil {
+		t.Fatalf("Error apply module: %s", err)
+	}
+
+	if !isSyncPending() {
+		t.Error("Sync should be pending after apply")
+	}
+
+	// Sync is stopped by reboot and resumed on init
+
+	if err = module.Reboot(); err != nil {
+		t.Errorf("Error reboot module: %s", err)
+	}
+
+	if !isSyncPending() {
+		t.Error("Sync should be pending after reboot")
+	}
+
+	if err = module.Init(); err != nil {
+		t.Fatalf("Error init module: %s", err)
+	}
+
+	for timeout := time.After(10 * time.Second); isSyncPending(); {
+		select {
+		case <-timeout:
+			t.Fatal("Wait for background sync timeout")
+
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err = testtools.CompareFiles(disk.Partitions[part0].Device, disk.Partitions[part1].Device); err != nil {
+		t.Errorf("Compare partition error: %s", err)
+	}
+}
+
 func TestUpdateChecker(t *testing.T) {
 	updateChecker := newTestChecker(nil)
 
 	module, err := dualpartmodule.New("test", []string{
 		disk.Partitions[part0].Device,
 		disk.Partitions[part1].Device,
-	}, versionFile, &stateController, &stateStorage, nil, updateChecker)
+	}, versionFile, &stateController, &stateStorage, nil, updateChecker, nil, false, nil, nil)
 	if err != nil {
 		t.Fatalf("Can't create test module: %s", err)
 	}
@@ -603,15 +794,40 @@ func (controller *testStateController) Close() {
 
 // State storage.
 func (storage *testStateStorage) GetModuleState(id string) (state []byte, err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
 	return storage.state, nil
 }
 
 func (storage *testStateStorage) SetModuleState(id string, state []byte) (err error) {
+	storage.Lock()
+	defer storage.Unlock()
+
 	storage.state = state
 
 	return nil
 }
 
+// Boot environment.
+func (bootEnv *testBootEnv) Snapshot() (err error) {
+	bootEnv.snapshot = true
+
+	return nil
+}
+
+func (bootEnv *testBootEnv) Restore() (err error) {
+	bootEnv.restored = true
+
+	return nil
+}
+
+func (bootEnv *testBootEnv) Remove() (err error) {
+	bootEnv.removed = true
+
+	return nil
+}
+
 // Update checker.
 func newTestChecker(err error) (checker *testChecker) {
 	return &testChecker{err: err}
@@ -621,6 +837,31 @@ func (checker *testChecker) Check() (err error) {
 	return checker.err
 }
 
+// Partition mapper.
+func (mapper *testMapper) Open(index int) (device string, err error) {
+	mapper.ops = append(mapper.ops, fmt.Sprintf("open %d", index))
+
+	return mapper.devices[index], nil
+}
+
+func (mapper *testMapper) Close(index int) (err error) {
+	mapper.ops = append(mapper.ops, fmt.Sprintf("close %d", index))
+
+	return nil
+}
+
+func (mapper *testMapper) Release() (err error) {
+	mapper.released = true
+
+	return nil
+}
+
+func isSyncPending() (pending bool) {
+	state, _ := stateStorage.GetModuleState("test")
+
+	return strings.Contains(string(state), `"syncPending":true`)
+}
+
 func generateImage(imagePath string, vendorVersion string) (content []fsContent, err error) {
 	if err = os.MkdirAll(filepath.Dir(imagePath), 0o755); err != nil {
 		return nil, aoserrors.Wrap(err)
@@ -21,14 +21,17 @@ import (
 	"encoding/json"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	log "github.com/sirupsen/logrus"
 
-	"github.com/aoscloud/aos_updatemanager/database"
 	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/updatemodules/modulehelpers"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
 )
 
 // Success update sequence diagram:
@@ -76,6 +79,8 @@ const (
 	imageExtension   = ".squashfs"
 )
 
+const stateSchemaVersion = 1
+
 /*******************************************************************************
  * Types
  ******************************************************************************/
@@ -85,13 +90,14 @@ type OverlayModule struct {
 	id             string
 	versionFile    string
 	updateDir      string
-	storage        updatehandler.ModuleStorage
+	stateStorage   *modulehelpers.StateStorage
 	state          moduleState
 	bootWithUpdate bool
 	bootErr        error
-	rebooter       Rebooter
+	reboot         *modulehelpers.RebootTracker
 	checker        UpdateChecker
 	vendorVersion  string
+	journal        *opjournal.Journal
 }
 
 // Rebooter performs module reboot.
@@ -105,8 +111,9 @@ type UpdateChecker interface {
 }
 
 type moduleState struct {
-	UpdateState updateState `json:"updateState"`
-	UpdateType  string      `json:"updateType"`
+	UpdateState updateState       `json:"updateState"`
+	UpdateType  string            `json:"updateType"`
+	Checks      map[string]string `json:"checks,omitempty"`
 }
 
 type updateState int
@@ -123,16 +130,19 @@ type moduleMetadata struct {
 func New(id string, versionFile, updateDir string,
 	storage updatehandler.ModuleStorage, rebooter Rebooter,
 	checker UpdateChecker,
-) (module updatehandler.UpdateModule, err error) {
-	log.WithFields(log.Fields{"id": id}).Debug("Create overlay module")
+) (module updatehandler.LegacyUpdateModule, err error) {
+	modulehelpers.Logger(id, "").Debug("Create overlay module")
 
-	if storage == nil {
-		return nil, aoserrors.New("no storage provided")
+	stateStorage, err := modulehelpers.NewStateStorage(id, storage, stateSchemaVersion, nil)
+	if err != nil {
+		return nil, err
 	}
 
+	journal := opjournal.New(id, storage)
+
 	overlayModule := &OverlayModule{
-		id: id, versionFile: versionFile, updateDir: updateDir, storage: storage,
-		rebooter: rebooter, checker: checker,
+		id: id, versionFile: versionFile, updateDir: updateDir, stateStorage: stateStorage,
+		reboot: modulehelpers.NewRebootTracker(rebooter, journal), checker: checker, journal: journal,
 	}
 
 	if overlayModule.versionFile == "" {
@@ -189,7 +199,7 @@ func (module *OverlayModule) Init() (err error) {
 
 	if _, err = os.Stat(failedFile); err == nil {
 		if module.bootErr == nil {
-			module.bootErr = aoserrors.New("boot failed")
+			module.bootErr = modulehelpers.NewBootFailure("boot failed")
 		}
 
 		if err = os.RemoveAll(failedFile); err != nil {
@@ -197,13 +207,28 @@ func (module *OverlayModule) Init() (err error) {
 		}
 	}
 
+	if module.state.UpdateState == updatedState && !module.bootWithUpdate && module.bootErr == nil {
+		module.bootErr = modulehelpers.NewBootFailure("boot with update failed")
+	}
+
 	if module.checker != nil && module.bootErr == nil {
-		module.bootErr = aoserrors.Wrap(module.checker.Check())
+		var checks map[string]string
+
+		if module.state.UpdateState == updatedState {
+			checks = module.state.Checks
+		}
+
+		module.bootErr = modulehelpers.CheckUpdate(module.checker, checks)
 	}
 
 	return nil
 }
 
+// GetMetadata returns boot failure kind and reason if boot with update failed.
+func (module *OverlayModule) GetMetadata() (metadata map[string]string, err error) {
+	return modulehelpers.GetBootFailureMetadata(module.bootErr), nil
+}
+
 // GetID returns module ID.
 func (module *OverlayModule) GetID() (id string) {
 	return module.id
@@ -232,14 +257,26 @@ func (module *OverlayModule) Prepare(imagePath string, vendorVersion string, ann
 		return aoserrors.Wrap(err)
 	}
 
+	checks, err := modulehelpers.GetCheckAnnotations(annotations, module.checker)
+	if err != nil {
+		return err
+	}
+
 	module.state.UpdateType = metadata.Type
 	module.state.UpdateState = preparedState
+	module.state.Checks = checks
 
 	if err = module.clearUpdateDir(); err != nil {
 		return aoserrors.Wrap(err)
 	}
 
-	if err = os.Rename(imagePath, path.Join(module.updateDir, path.Base(imagePath)+imageExtension)); err != nil {
+	layerPath := path.Join(module.updateDir, path.Base(imagePath)+imageExtension)
+	startTime := time.Now()
+
+	err = os.Rename(imagePath, layerPath)
+	module.journal.File(opjournal.FileRename, startTime, err, imagePath, layerPath)
+
+	if err != nil {
 		return aoserrors.Wrap(err)
 	}
 
@@ -255,14 +292,14 @@ func (module *OverlayModule) Update() (rebootRequired bool, err error) {
 	log.WithFields(log.Fields{"id": module.id}).Debug("Update overlay module")
 
 	if module.state.UpdateState == updatedState {
-		if !module.bootWithUpdate {
-			return false, aoserrors.New("boot with update failed")
-		}
-
 		if module.bootErr != nil {
 			return false, aoserrors.Wrap(module.bootErr)
 		}
 
+		if !module.bootWithUpdate {
+			return false, aoserrors.New("boot with update failed")
+		}
+
 		if err = os.RemoveAll(path.Join(module.updateDir, updatedFileName)); err != nil {
 			return false, aoserrors.Wrap(err)
 		}
@@ -274,8 +311,7 @@ func (module *OverlayModule) Update() (rebootRequired bool, err error) {
 		return false, aoserrors.Errorf("wrong state: %s", module.state.UpdateState)
 	}
 
-	if err = os.WriteFile(path.Join(module.updateDir, doUpdateFileName),
-		[]byte(module.state.UpdateType), 0o600); err != nil {
+	if err = module.writeFlagFile(doUpdateFileName); err != nil {
 		return false, aoserrors.Wrap(err)
 	}
 
@@ -285,7 +321,7 @@ func (module *OverlayModule) Update() (rebootRequired bool, err error) {
 		return false, aoserrors.Wrap(err)
 	}
 
-	return true, nil
+	return module.reboot.Request(), nil
 }
 
 // Apply applies current update.
@@ -311,8 +347,7 @@ func (module *OverlayModule) Apply() (rebootRequired bool, err error) {
 		return false, aoserrors.Errorf("wrong state: %s", module.state.UpdateState)
 	}
 
-	if err = os.WriteFile(path.Join(module.updateDir, doApplyFileName),
-		[]byte(module.state.UpdateType), 0o600); err != nil {
+	if err = module.writeFlagFile(doApplyFileName); err != nil {
 		return false, aoserrors.Wrap(err)
 	}
 
@@ -322,7 +357,7 @@ func (module *OverlayModule) Apply() (rebootRequired bool, err error) {
 		return false, aoserrors.Wrap(err)
 	}
 
-	return true, nil
+	return module.reboot.Request(), nil
 }
 
 // Revert reverts current update.
@@ -344,23 +379,39 @@ func (module *OverlayModule) Revert() (rebootRequired bool, err error) {
 	}
 
 	if module.bootWithUpdate {
-		rebootRequired = true
+		rebootRequired = module.reboot.Request()
 	}
 
 	return rebootRequired, nil
 }
 
-// Reboot performs module reboot.
+// GetExportState returns update decision to be consumed by initramfs.
+func (module *OverlayModule) GetExportState() (state map[string]string) {
+	state = map[string]string{"STATE": module.state.UpdateState.String()}
+
+	if module.state.UpdateState == idleState {
+		return state
+	}
+
+	state["UPDATE_TYPE"] = module.state.UpdateType
+
+	layers, _ := filepath.Glob(filepath.Join(module.updateDir, "*"+imageExtension))
+	for i := range layers {
+		layers[i] = filepath.Base(layers[i])
+	}
+
+	state["LAYERS"] = strings.Join(layers, " ")
+
+	return state
+}
+
+// Reboot performs module reboot if it is requested by update, apply or revert.
 func (module *OverlayModule) Reboot() (err error) {
-	if module.rebooter != nil {
+	if module.reboot.Requested() {
 		log.WithFields(log.Fields{"id": module.id}).Debug("Reboot overlay module")
-
-		if err = module.rebooter.Reboot(); err != nil {
-			return aoserrors.Wrap(err)
-		}
 	}
 
-	return nil
+	return module.reboot.Reboot()
 }
 
 /*******************************************************************************
@@ -374,33 +425,14 @@ func (state updateState) String() string {
 func (module *OverlayModule) saveState() (err error) {
 	log.WithFields(log.Fields{"id": module.id, "state": module.state.UpdateState}).Debug("Save state")
 
-	stateJSON, err := json.Marshal(module.state)
-	if err != nil {
-		return aoserrors.Wrap(err)
-	}
-
-	if err = module.storage.SetModuleState(module.id, stateJSON); err != nil {
-		return aoserrors.Wrap(err)
-	}
-
-	return nil
+	return module.stateStorage.Save(module.state)
 }
 
 func (module *OverlayModule) getState() (err error) {
-	stateJSON, err := module.storage.GetModuleState(module.id)
-	if err != nil {
-		if strings.Contains(err.Error(), database.ErrNotExistStr) {
-			module.state = moduleState{}
-			return nil
-		}
-
-		return aoserrors.Wrap(err)
-	}
+	module.state = moduleState{}
 
-	if stateJSON != nil {
-		if err = json.Unmarshal(stateJSON, &module.state); err != nil {
-			return aoserrors.Wrap(err)
-		}
+	if err = module.stateStorage.Load(&module.state); err != nil {
+		return err
 	}
 
 	log.WithFields(log.Fields{"id": module.id, "state": module.state.UpdateState}).Debug("Get state")
@@ -424,8 +456,23 @@ func (module *OverlayModule) getModuleVersion() (version string, err error) {
 	return string(data[loc[2]:loc[3]]), nil
 }
 
+func (module *OverlayModule) writeFlagFile(fileName string) (err error) {
+	flagFile := path.Join(module.updateDir, fileName)
+	startTime := time.Now()
+
+	err = os.WriteFile(flagFile, []byte(module.state.UpdateType), 0o600)
+	module.journal.File(opjournal.FileWrite, startTime, err, flagFile)
+
+	return aoserrors.Wrap(err)
+}
+
 func (module *OverlayModule) clearUpdateDir() (err error) {
-	if err = os.RemoveAll(module.updateDir); err != nil {
+	startTime := time.Now()
+
+	err = os.RemoveAll(module.updateDir)
+	module.journal.File(opjournal.FileRemove, startTime, err, module.updateDir)
+
+	if err != nil {
 		return aoserrors.Wrap(err)
 	}
 
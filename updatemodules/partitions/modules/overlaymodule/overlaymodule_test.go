@@ -22,13 +22,17 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/aoscloud/aos_updatemanager/updatemodules/modulehelpers"
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/modules/overlaymodule"
+	"github.com/aoscloud/aos_updatemanager/utils/pstore"
 )
 
 /*******************************************************************************
@@ -53,6 +57,11 @@ type testChecker struct {
 	err error
 }
 
+type testAnnotationChecker struct {
+	testChecker
+	annotations map[string]string
+}
+
 /*******************************************************************************
  * Var
  ******************************************************************************/
@@ -346,10 +355,24 @@ func TestUpdateFail(t *testing.T) {
 		t.Fatalf("Wait for reboot error: %s", err)
 	}
 
-	// Restart and init module
+	// Restart and init module, crash record of failed boot is in pstore
 
 	module.Close()
 
+	pstore.Path = path.Join(tmpDir, "pstore")
+	defer func() { pstore.Path = "/sys/fs/pstore" }()
+
+	if err = os.MkdirAll(pstore.Path, 0o755); err != nil {
+		t.Fatalf("Can't create pstore dir: %s", err)
+	}
+	defer os.RemoveAll(pstore.Path)
+
+	if err = os.WriteFile(path.Join(pstore.Path, "dmesg-ramoops-0"),
+		[]byte("Panic#1 Part1\n<0>[    1.5] Kernel panic - not syncing: VFS: Unable to mount root fs\n"),
+		0o600); err != nil {
+		t.Fatalf("Can't write pstore record: %s", err)
+	}
+
 	if module, err = overlaymodule.New("test", versionFile, updateDir, storage, rebooter, nil); err != nil {
 		t.Fatalf("Can't create overlay module: %s", err)
 	}
@@ -364,6 +387,12 @@ func TestUpdateFail(t *testing.T) {
 		t.Fatal("Update should fail")
 	}
 
+	if !strings.Contains(err.Error(), "kernel panic: VFS: Unable to mount root fs") {
+		t.Errorf("Wrong update error: %s", err)
+	}
+
+	checkBootFailure(t, module, modulehelpers.BootFailureKernelPanic)
+
 	if rebootRequired {
 		t.Error("Reboot is not required")
 	}
@@ -481,6 +510,8 @@ func TestUpdateChecker(t *testing.T) {
 		t.Fatal("Update should fail")
 	}
 
+	checkBootFailure(t, module, modulehelpers.BootFailureHealthCheck)
+
 	if rebootRequired {
 		t.Error("Reboot is not required")
 	}
@@ -488,6 +519,91 @@ func TestUpdateChecker(t *testing.T) {
 	module.Close()
 }
 
+func TestCheckAnnotations(t *testing.T) {
+	rebooter := newTestRebooter()
+	storage := &testStorage{}
+
+	if err := createVersionFile("v1.0"); err != nil {
+		t.Fatalf("Can't create version file: %s", err)
+	}
+
+	imagePath := path.Join(tmpDir, "rootfs")
+	annotations := json.RawMessage(`{"type":"full","checks":{"kernelVersion":"6.1.0","feature":"on"}}`)
+
+	// Check annotations are rejected if checker can't assert them
+
+	module, err := overlaymodule.New("test", versionFile, updateDir, storage, rebooter, newTestChecker(nil))
+	if err != nil {
+		t.Fatalf("Can't create overlay module: %s", err)
+	}
+
+	if err = module.Init(); err != nil {
+		t.Fatalf("Can't initialize module: %s", err)
+	}
+
+	if err = createImage(imagePath); err != nil {
+		t.Fatalf("Can't create image: %s", err)
+	}
+
+	if err = module.Prepare(imagePath, "v3.0", annotations); err == nil {
+		t.Error("Prepare should fail")
+	}
+
+	module.Close()
+
+	// Check annotations are passed to checker after boot with update
+
+	if module, err = overlaymodule.New("test", versionFile, updateDir, storage, rebooter,
+		&testAnnotationChecker{}); err != nil {
+		t.Fatalf("Can't create overlay module: %s", err)
+	}
+
+	if err = module.Init(); err != nil {
+		t.Fatalf("Can't initialize module: %s", err)
+	}
+
+	if err = module.Prepare(imagePath, "v3.0", annotations); err != nil {
+		t.Fatalf("Prepare error: %s", err)
+	}
+
+	if _, err = module.Update(); err != nil {
+		t.Fatalf("Update error: %s", err)
+	}
+
+	if err = module.Reboot(); err != nil {
+		t.Fatalf("Reboot error: %s", err)
+	}
+
+	if err = rebooter.waitForReboot(); err != nil {
+		t.Fatalf("Wait for reboot error: %s", err)
+	}
+
+	module.Close()
+
+	if err = os.WriteFile(path.Join(updateDir, "updated"), nil, 0o600); err != nil {
+		t.Fatalf("Can't create updated file: %s", err)
+	}
+
+	checker := &testAnnotationChecker{}
+
+	if module, err = overlaymodule.New("test", versionFile, updateDir, storage, rebooter, checker); err != nil {
+		t.Fatalf("Can't create overlay module: %s", err)
+	}
+	defer module.Close()
+
+	if err = module.Init(); err != nil {
+		t.Fatalf("Can't initialize module: %s", err)
+	}
+
+	if !reflect.DeepEqual(checker.annotations, map[string]string{"kernelVersion": "6.1.0", "feature": "on"}) {
+		t.Errorf("Wrong check annotations: %v", checker.annotations)
+	}
+
+	if _, err = module.Update(); err != nil {
+		t.Errorf("Update error: %s", err)
+	}
+}
+
 /*******************************************************************************
  * Private
  ******************************************************************************/
@@ -530,6 +646,32 @@ func (checker *testChecker) Check() (err error) {
 	return checker.err
 }
 
+func (checker *testAnnotationChecker) CheckAnnotations(annotations map[string]string) (err error) {
+	checker.annotations = annotations
+
+	return checker.err
+}
+
+func checkBootFailure(t *testing.T, module interface{}, kind string) {
+	t.Helper()
+
+	provider, ok := module.(interface {
+		GetMetadata() (metadata map[string]string, err error)
+	})
+	if !ok {
+		t.Fatal("Module doesn't provide metadata")
+	}
+
+	metadata, err := provider.GetMetadata()
+	if err != nil {
+		t.Fatalf("Can't get metadata: %s", err)
+	}
+
+	if metadata[modulehelpers.MetadataBootFailureKind] != kind {
+		t.Errorf("Wrong boot failure metadata: %v", metadata)
+	}
+}
+
 func createImage(imagePath string) (err error) {
 	if err = os.MkdirAll(path.Dir(imagePath), 0o755); err != nil {
 		return aoserrors.Wrap(err)
@@ -19,6 +19,10 @@ package systemdchecker
 
 import (
 	"context"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,23 +30,35 @@ import (
 	"github.com/aoscloud/aos_common/aostypes"
 	"github.com/coreos/go-systemd/v22/dbus"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/aoscloud/aos_updatemanager/utils/versionutils"
 )
 
+// Check annotations are campaign specific expectations validated after boot with update in addition to watched
+// services. Annotation is checked by configured command if any: the command is run by shell with annotation name and
+// expected value in environment and should exit with zero code. Kernel version annotation is checked against running
+// kernel release if no command is configured for it. Annotation which can't be checked fails the validation.
+
 /***********************************************************************************************************************
  * Consts
  **********************************************************************************************************************/
 
 const defaultTimeout = 30 * time.Second
 
+// KernelVersionAnnotation check annotation of expected kernel release.
+const KernelVersionAnnotation = "kernelVersion"
+
 /***********************************************************************************************************************
  * Types
  **********************************************************************************************************************/
 
-// Config watch services configuration.
+// Config watch services configuration. Annotation checks map check annotation name to check command.
 type Config struct {
-	SystemServices []string          `json:"systemServices"`
-	UserServices   []string          `json:"userServices"`
-	Timeout        aostypes.Duration `json:"timeout"`
+	SystemServices   []string          `json:"systemServices"`
+	UserServices     []string          `json:"userServices"`
+	Timeout          aostypes.Duration `json:"timeout"`
+	AnnotationChecks map[string]string `json:"annotationChecks"`
 }
 
 // Checker systemd checker instance.
@@ -105,10 +121,80 @@ func (checker *Checker) Check() (err error) {
 	return nil
 }
 
+// CheckAnnotations performs update validation and asserts expectations of check annotations.
+func (checker *Checker) CheckAnnotations(annotations map[string]string) (err error) {
+	if err = checker.Check(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(annotations))
+
+	for name := range annotations {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err = checker.checkAnnotation(name, annotations[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 /***********************************************************************************************************************
  * Private
  **********************************************************************************************************************/
 
+func (checker *Checker) checkAnnotation(name, expected string) (err error) {
+	log.WithFields(log.Fields{"annotation": name, "expected": expected}).Debug("Check annotation")
+
+	if command, ok := checker.cfg.AnnotationChecks[name]; ok {
+		return runAnnotationCheck(command, name, expected, checker.cfg.Timeout.Duration)
+	}
+
+	switch name {
+	case KernelVersionAnnotation:
+		var uname unix.Utsname
+
+		if err = unix.Uname(&uname); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if release := unix.ByteSliceToString(uname.Release[:]); !versionutils.Equal(
+			versionutils.SchemeAuto, release, expected) {
+			return aoserrors.Errorf("kernel version %s mismatches expected %s", release, expected)
+		}
+
+		return nil
+
+	default:
+		return aoserrors.Errorf("unsupported check annotation %s", name)
+	}
+}
+
+func runAnnotationCheck(command, name, expected string, timeout time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+
+	cmd.Env = append(os.Environ(), "CHECK_ANNOTATION="+name, "CHECK_VALUE="+expected)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return aoserrors.Errorf("check annotation %s timeout", name)
+		}
+
+		return aoserrors.Errorf("check annotation %s failed: %v, output: %s", name, err,
+			strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
 func watchServices(createConnection func(context.Context) (*dbus.Conn, error), services []string,
 	timeout time.Duration,
 ) (err error) {
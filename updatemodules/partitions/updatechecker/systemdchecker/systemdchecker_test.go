@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -111,6 +112,33 @@ func TestServicesFailed(t *testing.T) {
 	}
 }
 
+func TestCheckAnnotations(t *testing.T) {
+	kernelVersion, err := exec.Command("uname", "-r").Output()
+	if err != nil {
+		t.Fatalf("Can't get kernel version: %s", err)
+	}
+
+	checker := systemdchecker.New(systemdchecker.Config{
+		AnnotationChecks: map[string]string{"feature": `test "$CHECK_ANNOTATION=$CHECK_VALUE" = "feature=on"`},
+	})
+
+	if err := checker.CheckAnnotations(map[string]string{
+		systemdchecker.KernelVersionAnnotation: strings.TrimSpace(string(kernelVersion)), "feature": "on",
+	}); err != nil {
+		t.Errorf("Check annotations error: %s", err)
+	}
+
+	for _, annotations := range []map[string]string{
+		{systemdchecker.KernelVersionAnnotation: "0.0.0"},
+		{"feature": "off"},
+		{"unknown": "value"},
+	} {
+		if err := checker.CheckAnnotations(annotations); err == nil {
+			t.Errorf("Error expected for annotations: %v", annotations)
+		}
+	}
+}
+
 /***********************************************************************************************************************
  * Private
  **********************************************************************************************************************/
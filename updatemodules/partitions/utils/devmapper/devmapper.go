@@ -0,0 +1,319 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package devmapper provides access to partitions behind device-mapper: LUKS containers and LVM logical volumes.
+package devmapper
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// Target is activated on open only if it is not active yet: e.g. the target the system is booted from is opened by
+// initramfs and should stay active. The target activated by open is deactivated on close. Before deactivation
+// buffers of the mapped device are flushed, LUKS backing device is flushed after the container is closed.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Target types.
+const (
+	TypeLUKS = "luks"
+	TypeLVM  = "lvm"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// MapperPath path to device-mapper devices dir.
+var MapperPath = "/dev/mapper" //nolint:gochecknoglobals // Used in unit tests to override path
+
+// DevPath path to devices dir where LVM volumes are linked.
+var DevPath = "/dev" //nolint:gochecknoglobals // Used in unit tests to override path
+
+// RunCommand runs external command with optional stdin data.
+var RunCommand = runCommand //nolint:gochecknoglobals // Used in unit tests to mock commands
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Config device-mapper target config.
+type Config struct {
+	Type          string `json:"type"`
+	Device        string `json:"device"`
+	Name          string `json:"name"`
+	KeyID         string `json:"keyId"`
+	VolumeGroup   string `json:"volumeGroup"`
+	LogicalVolume string `json:"logicalVolume"`
+}
+
+// KeyProvider provides LUKS keys by key ID.
+type KeyProvider interface {
+	GetKey(keyID string) (key []byte, err error)
+	Close() (err error)
+}
+
+// Target device-mapper target.
+type Target struct {
+	config      Config
+	keyProvider KeyProvider
+	activated   bool
+}
+
+// Targets device-mapper targets of partitions which share key provider.
+type Targets struct {
+	targets     []*Target
+	keyProvider KeyProvider
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates device-mapper target.
+func New(config Config, keyProvider KeyProvider) (target *Target, err error) {
+	if err = config.Check(); err != nil {
+		return nil, err
+	}
+
+	if config.Type == TypeLUKS && keyProvider == nil {
+		return nil, aoserrors.Errorf("key provider is required for LUKS target %s", config.Name)
+	}
+
+	return &Target{config: config, keyProvider: keyProvider}, nil
+}
+
+// NewTargets creates device-mapper targets. Targets own key provider and close it on release.
+func NewTargets(configs []Config, keyProvider KeyProvider) (targets *Targets, err error) {
+	targets = &Targets{keyProvider: keyProvider}
+
+	for _, config := range configs {
+		target, err := New(config, keyProvider)
+		if err != nil {
+			return nil, err
+		}
+
+		targets.targets = append(targets.targets, target)
+	}
+
+	return targets, nil
+}
+
+// Open opens target by index and returns mapped device path.
+func (targets *Targets) Open(index int) (device string, err error) {
+	if index < 0 || index >= len(targets.targets) {
+		return "", aoserrors.Errorf("wrong target index %d", index)
+	}
+
+	return targets.targets[index].Open()
+}
+
+// Close closes target by index.
+func (targets *Targets) Close(index int) (err error) {
+	if index < 0 || index >= len(targets.targets) {
+		return aoserrors.Errorf("wrong target index %d", index)
+	}
+
+	return targets.targets[index].Close()
+}
+
+// Release closes all targets activated by open and key provider.
+func (targets *Targets) Release() (err error) {
+	for _, target := range targets.targets {
+		if closeErr := target.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	if targets.keyProvider != nil {
+		if closeErr := targets.keyProvider.Close(); closeErr != nil && err == nil {
+			err = aoserrors.Wrap(closeErr)
+		}
+	}
+
+	return err
+}
+
+// Check checks target config.
+func (config Config) Check() (err error) {
+	switch config.Type {
+	case TypeLUKS:
+		if config.Device == "" || config.Name == "" || config.KeyID == "" {
+			return aoserrors.New("device, name and key ID should be configured for LUKS target")
+		}
+
+		if strings.ContainsRune(config.Name, '/') {
+			return aoserrors.Errorf("wrong LUKS target name %s", config.Name)
+		}
+
+	case TypeLVM:
+		if config.VolumeGroup == "" || config.LogicalVolume == "" {
+			return aoserrors.New("volume group and logical volume should be configured for LVM target")
+		}
+
+	default:
+		return aoserrors.Errorf("unsupported device-mapper target type %s", config.Type)
+	}
+
+	return nil
+}
+
+// Path returns path to mapped device.
+func (target *Target) Path() (path string) {
+	if target.config.Type == TypeLVM {
+		return filepath.Join(DevPath, target.config.VolumeGroup, target.config.LogicalVolume)
+	}
+
+	return filepath.Join(MapperPath, target.config.Name)
+}
+
+// Open activates target if it is not active and returns mapped device path.
+func (target *Target) Open() (device string, err error) {
+	device = target.Path()
+
+	if target.activated {
+		return device, nil
+	}
+
+	if _, err = os.Stat(device); err == nil {
+		log.WithField("device", device).Debug("Device-mapper target is already active")
+
+		return device, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", aoserrors.Wrap(err)
+	}
+
+	log.WithField("device", device).Debug("Activate device-mapper target")
+
+	switch target.config.Type {
+	case TypeLUKS:
+		err = target.openLUKS()
+
+	case TypeLVM:
+		err = RunCommand(nil, "lvchange", "--activate", "y", target.volume())
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	target.activated = true
+
+	return device, nil
+}
+
+// Close flushes and deactivates target if it was activated by open.
+func (target *Target) Close() (err error) {
+	if !target.activated {
+		return nil
+	}
+
+	log.WithField("device", target.Path()).Debug("Deactivate device-mapper target")
+
+	if err = flushDevice(target.Path()); err != nil {
+		return err
+	}
+
+	switch target.config.Type {
+	case TypeLUKS:
+		if err = RunCommand(nil, "cryptsetup", "close", target.config.Name); err != nil {
+			return err
+		}
+
+		if err = flushDevice(target.config.Device); err != nil {
+			return err
+		}
+
+	case TypeLVM:
+		if err = RunCommand(nil, "lvchange", "--activate", "n", target.volume()); err != nil {
+			return err
+		}
+	}
+
+	target.activated = false
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (target *Target) openLUKS() (err error) {
+	key, err := target.keyProvider.GetKey(target.config.KeyID)
+	if err != nil {
+		return aoserrors.Errorf("can't get key %s: %v", target.config.KeyID, err)
+	}
+
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+
+	return RunCommand(key, "cryptsetup", "open", "--type", "luks", "--key-file", "-",
+		target.config.Device, target.config.Name)
+}
+
+func (target *Target) volume() (volume string) {
+	return target.config.VolumeGroup + "/" + target.config.LogicalVolume
+}
+
+func flushDevice(device string) (err error) {
+	file, err := os.OpenFile(device, os.O_RDONLY, 0)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if err = file.Sync(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	// Drop device buffers, it is not applicable to regular files
+	if err := unix.IoctlSetInt(int(file.Fd()), unix.BLKFLSBUF, 0); err != nil {
+		log.WithField("device", device).Debugf("Can't flush device buffers: %v", err)
+	}
+
+	return nil
+}
+
+func runCommand(stdin []byte, name string, args ...string) (err error) {
+	cmd := exec.Command(name, args...)
+
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return aoserrors.Errorf("%s failed: %v, output: %s", name, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devmapper_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/utils/devmapper"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testKeyProvider struct {
+	keys   map[string][]byte
+	closed bool
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var (
+	commands []string
+	keys     [][]byte
+)
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	tmpDir, err := os.MkdirTemp("", "dm_")
+	if err != nil {
+		log.Fatalf("Error create temporary dir: %s", err)
+	}
+
+	devmapper.MapperPath = filepath.Join(tmpDir, "mapper")
+	devmapper.DevPath = tmpDir
+	devmapper.RunCommand = runCommand
+
+	ret := m.Run()
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Fatalf("Error removing tmp dir: %s", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestWrongConfig(t *testing.T) {
+	for _, config := range []devmapper.Config{
+		{},
+		{Type: "raid"},
+		{Type: devmapper.TypeLUKS, Device: "/dev/sda2", Name: "rootfs"},
+		{Type: devmapper.TypeLUKS, Device: "/dev/sda2", Name: "../rootfs", KeyID: "rootfs"},
+		{Type: devmapper.TypeLVM, VolumeGroup: "vg0"},
+	} {
+		if _, err := devmapper.New(config, &testKeyProvider{}); err == nil {
+			t.Errorf("Error expected for config: %v", config)
+		}
+	}
+
+	if _, err := devmapper.New(devmapper.Config{
+		Type: devmapper.TypeLUKS, Device: "/dev/sda2", Name: "rootfs", KeyID: "rootfs",
+	}, nil); err == nil {
+		t.Error("Error expected when key provider is not set")
+	}
+}
+
+func TestLUKS(t *testing.T) {
+	backingDevice := filepath.Join(t.TempDir(), "sda2")
+
+	if err := os.WriteFile(backingDevice, nil, 0o600); err != nil {
+		t.Fatalf("Can't create backing device: %v", err)
+	}
+
+	target, err := devmapper.New(devmapper.Config{
+		Type: devmapper.TypeLUKS, Device: backingDevice, Name: "rootfs-b", KeyID: "rootfs",
+	}, &testKeyProvider{keys: map[string][]byte{"rootfs": []byte("secret")}})
+	if err != nil {
+		t.Fatalf("Can't create target: %v", err)
+	}
+
+	commands, keys = nil, nil
+
+	device, err := target.Open()
+	if err != nil {
+		t.Fatalf("Can't open target: %v", err)
+	}
+
+	if device != filepath.Join(devmapper.MapperPath, "rootfs-b") {
+		t.Errorf("Wrong device: %s", device)
+	}
+
+	if err = target.Close(); err != nil {
+		t.Fatalf("Can't close target: %v", err)
+	}
+
+	checkCommands(t,
+		"cryptsetup open --type luks --key-file - "+backingDevice+" rootfs-b",
+		"cryptsetup close rootfs-b")
+
+	if !reflect.DeepEqual(keys, [][]byte{[]byte("secret")}) {
+		t.Errorf("Wrong keys: %s", keys)
+	}
+
+	// Already active target is not deactivated
+
+	if err = os.MkdirAll(devmapper.MapperPath, 0o755); err != nil {
+		t.Fatalf("Can't create mapper dir: %v", err)
+	}
+
+	if err = os.WriteFile(device, nil, 0o600); err != nil {
+		t.Fatalf("Can't create mapped device: %v", err)
+	}
+	defer os.Remove(device)
+
+	commands = nil
+
+	if _, err = target.Open(); err != nil {
+		t.Fatalf("Can't open target: %v", err)
+	}
+
+	if err = target.Close(); err != nil {
+		t.Fatalf("Can't close target: %v", err)
+	}
+
+	checkCommands(t)
+}
+
+func TestLVM(t *testing.T) {
+	target, err := devmapper.New(devmapper.Config{
+		Type: devmapper.TypeLVM, VolumeGroup: "vg0", LogicalVolume: "rootfs_b",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Can't create target: %v", err)
+	}
+
+	commands = nil
+
+	device, err := target.Open()
+	if err != nil {
+		t.Fatalf("Can't open target: %v", err)
+	}
+
+	if device != filepath.Join(devmapper.DevPath, "vg0", "rootfs_b") {
+		t.Errorf("Wrong device: %s", device)
+	}
+
+	if err = target.Close(); err != nil {
+		t.Fatalf("Can't close target: %v", err)
+	}
+
+	checkCommands(t, "lvchange --activate y vg0/rootfs_b", "lvchange --activate n vg0/rootfs_b")
+}
+
+func TestTargets(t *testing.T) {
+	backingDir := t.TempDir()
+	keyProvider := &testKeyProvider{keys: map[string][]byte{"rootfs": []byte("secret")}}
+
+	targets, err := devmapper.NewTargets([]devmapper.Config{
+		{Type: devmapper.TypeLUKS, Device: filepath.Join(backingDir, "sda2"), Name: "rootfs-a", KeyID: "rootfs"},
+		{Type: devmapper.TypeLUKS, Device: filepath.Join(backingDir, "sda3"), Name: "rootfs-b", KeyID: "rootfs"},
+	}, keyProvider)
+	if err != nil {
+		t.Fatalf("Can't create targets: %v", err)
+	}
+
+	for _, name := range []string{"sda2", "sda3"} {
+		if err = os.WriteFile(filepath.Join(backingDir, name), nil, 0o600); err != nil {
+			t.Fatalf("Can't create backing device: %v", err)
+		}
+	}
+
+	commands = nil
+
+	if _, err = targets.Open(2); err == nil {
+		t.Error("Error expected for wrong index")
+	}
+
+	if _, err = targets.Open(1); err != nil {
+		t.Fatalf("Can't open target: %v", err)
+	}
+
+	if err = targets.Release(); err != nil {
+		t.Fatalf("Can't release targets: %v", err)
+	}
+
+	checkCommands(t,
+		"cryptsetup open --type luks --key-file - "+filepath.Join(backingDir, "sda3")+" rootfs-b",
+		"cryptsetup close rootfs-b")
+
+	if !keyProvider.closed {
+		t.Error("Key provider is not closed")
+	}
+}
+
+/***********************************************************************************************************************
+ * Interfaces
+ **********************************************************************************************************************/
+
+func (provider *testKeyProvider) GetKey(keyID string) (key []byte, err error) {
+	key, ok := provider.keys[keyID]
+	if !ok {
+		return nil, aoserrors.Errorf("key %s not found", keyID)
+	}
+
+	return append([]byte(nil), key...), nil
+}
+
+func (provider *testKeyProvider) Close() (err error) {
+	provider.closed = true
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// runCommand emulates device-mapper tools by creating and removing mapped device files.
+func runCommand(stdin []byte, name string, args ...string) (err error) {
+	commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+
+	if stdin != nil {
+		keys = append(keys, append([]byte(nil), stdin...))
+	}
+
+	var device string
+
+	switch {
+	case name == "cryptsetup":
+		device = filepath.Join(devmapper.MapperPath, args[len(args)-1])
+
+	case name == "lvchange":
+		device = filepath.Join(devmapper.DevPath, args[len(args)-1])
+	}
+
+	if args[0] == "close" || args[1] == "n" {
+		return aoserrors.Wrap(os.Remove(device))
+	}
+
+	if err = os.MkdirAll(filepath.Dir(device), 0o755); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(os.WriteFile(device, nil, 0o600))
+}
+
+func checkCommands(t *testing.T, expectedCommands ...string) {
+	t.Helper()
+
+	if len(expectedCommands) == 0 {
+		expectedCommands = nil
+	}
+
+	if !reflect.DeepEqual(commands, expectedCommands) {
+		t.Errorf("Wrong commands: %v", commands)
+	}
+}
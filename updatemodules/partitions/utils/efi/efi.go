@@ -15,6 +15,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build !fakeefi
+
 package efi
 
 /*
@@ -48,8 +50,8 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
-	"strings"
 	"syscall"
+	"unicode/utf16"
 	"unsafe"
 
 	"github.com/aoscloud/aos_common/aoserrors"
@@ -95,13 +97,6 @@ const writeAttribute = 0o600
 
 const dpHeaderSize = 4
 
-/*******************************************************************************
- * Vars
- ******************************************************************************/
-
-// ErrNotFound efi var not exist error
-var ErrNotFound = errors.New("EFI var not found")
-
 /*******************************************************************************
  * Types
  ******************************************************************************/
@@ -119,6 +114,8 @@ type bootItem struct {
 	data        []byte
 }
 
+type filePathData string
+
 type hdData struct {
 	partNumber    uint32
 	start         uint64
@@ -147,8 +144,34 @@ func New() (instance *Instance, err error) {
 	return instance, nil
 }
 
+// GetPartUUID returns PARTUUID of partition
+func GetPartUUID(partitionPath string) (partUUID string, err error) {
+	info, err := partition.GetPartInfo(partitionPath)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return info.PartUUID, nil
+}
+
 // GetBootByPartUUID returns boot item by PARTUUID
 func (instance *Instance) GetBootByPartUUID(partUUID string) (id uint16, err error) {
+	entries, err := instance.GetBootsByPartUUID(partUUID)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	if len(entries) == 0 {
+		return 0, ErrNotFound
+	}
+
+	log.Debugf("Get EFI boot by PARTUUID=%s: %04X", partUUID, entries[0].ID)
+
+	return entries[0].ID, nil
+}
+
+// GetBootsByPartUUID returns all boot items which point to partition with PARTUUID sorted by ID
+func (instance *Instance) GetBootsByPartUUID(partUUID string) (entries []BootEntry, err error) {
 	for _, item := range instance.bootItems {
 		if item.data == nil {
 			continue
@@ -160,35 +183,82 @@ func (instance *Instance) GetBootByPartUUID(partUUID string) (id uint16, err err
 
 		dps, err := parseDP(C.GoBytes(unsafe.Pointer(dpData), C.int(pathLen)))
 		if err != nil {
-			return 0, aoserrors.Wrap(err)
+			return nil, aoserrors.Wrap(err)
 		}
 
+		matched := false
+		entry := BootEntry{ID: item.id, Description: item.description}
+
 		for _, dp := range dps {
-			hd, ok := dp.(hdData)
-			if !ok {
-				continue
-			}
+			switch data := dp.(type) {
+			case hdData:
+				if data.signatureType != hdSignatureGUID {
+					continue
+				}
 
-			if hd.signatureType != hdSignatureGUID {
-				continue
-			}
+				var uuidStr *C.char
 
-			var uuidStr *C.char
+				//nolint: gocritic
+				if rc := C.efi_guid_to_str((*C.efi_guid_t)(unsafe.Pointer(&data.signature[0])), &uuidStr); rc < 0 {
+					log.Errorf("Wrong PARTUUID in efi var: %s", getEfiError())
+				}
 
-			//nolint: gocritic
-			if rc := C.efi_guid_to_str((*C.efi_guid_t)(unsafe.Pointer(&hd.signature[0])), &uuidStr); rc < 0 {
-				log.Errorf("Wrong PARTUUID in efi var: %s", getEfiError())
+				if partUUID == C.GoString(uuidStr) {
+					matched = true
+				}
+
+			case filePathData:
+				entry.Loader += string(data)
 			}
+		}
+
+		if matched {
+			entries = append(entries, entry)
+		}
+	}
 
-			if partUUID == C.GoString(uuidStr) {
-				log.Debugf("Get EFI boot by PARTUUID=%s: %04X", partUUID, item.id)
+	return entries, nil
+}
 
-				return item.id, nil
+// BootEntryExists checks if boot item variable exists
+func (instance *Instance) BootEntryExists(id uint16) (exists bool, err error) {
+	for _, item := range instance.bootItems {
+		if item.id == id {
+			return true, nil
+		}
+	}
+
+	// Boot item can be skipped on read, check variable itself
+	for _, name := range []string{fmt.Sprintf("Boot%04X", id), fmt.Sprintf("Boot%04x", id)} {
+		if _, _, err = readVar(efiGlobalGUID, name); err == nil {
+			return true, nil
+		}
+
+		if !errors.Is(err, ErrNotFound) {
+			return false, aoserrors.Wrap(err)
+		}
+	}
+
+	return false, nil
+}
+
+// DeleteBootEntry deletes boot item variable
+func (instance *Instance) DeleteBootEntry(id uint16) (err error) {
+	log.Debugf("Delete EFI boot entry: %04X", id)
+
+	for i, item := range instance.bootItems {
+		if item.id == id {
+			if err = deleteVar(efiGlobalGUID, item.name); err != nil {
+				return aoserrors.Wrap(err)
 			}
+
+			instance.bootItems = append(instance.bootItems[:i], instance.bootItems[i+1:]...)
+
+			return nil
 		}
 	}
 
-	return 0, ErrNotFound
+	return ErrNotFound
 }
 
 // GetBootCurrent returns boot current item
@@ -592,14 +662,6 @@ func getEfiError() (err error) {
 	return aoserrors.Wrap(err)
 }
 
-func bootOrderToString(bootOrder []uint16) (s string) {
-	for _, order := range bootOrder {
-		s += fmt.Sprintf("%04X,", order)
-	}
-
-	return strings.TrimSuffix(s, ",")
-}
-
 func (instance *Instance) readBootItems() (err error) {
 	var (
 		guid *C.efi_guid_t = nil
@@ -718,6 +780,9 @@ func parseMediaType(subType uint8, data []byte) (dp interface{}, err error) {
 
 		return hd, nil
 
+	case C.EFIDP_MEDIA_FILE:
+		return parseFilePath(data), nil
+
 	default:
 		//nolint:nilnil // we parse only known fields
 		return nil, nil
@@ -753,3 +818,18 @@ func parseHD(data []byte) (hd hdData, err error) {
 
 	return hd, nil
 }
+
+func parseFilePath(data []byte) (filePath filePathData) {
+	chars := make([]uint16, 0, len(data)/2)
+
+	for i := 0; i+1 < len(data); i += 2 {
+		char := binary.LittleEndian.Uint16(data[i:])
+		if char == 0 {
+			break
+		}
+
+		chars = append(chars, char)
+	}
+
+	return filePathData(utf16.Decode(chars))
+}
@@ -15,6 +15,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build !fakeefi
+
 package efi_test
 
 import (
@@ -29,6 +31,7 @@ import (
 	"testing"
 
 	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/partition"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/aoscloud/aos_updatemanager/updatemodules/partitions/utils/efi"
@@ -156,6 +159,81 @@ func TestGetByPartUUID(t *testing.T) {
 	}
 }
 
+func TestDuplicateBootEntries(t *testing.T) {
+	efiVars, err := efi.New()
+	if err != nil {
+		t.Fatalf("Can't create EFI instance: %s", err)
+	}
+	defer efiVars.Close()
+
+	info, err := partition.GetPartInfo("/dev/nvme0n1p1")
+	if err != nil {
+		t.Fatalf("Can't get partition info: %s", err)
+	}
+
+	bootOrder, err := efiVars.GetBootOrder()
+	if err != nil {
+		t.Fatalf("Can't get boot order: %s", err)
+	}
+
+	defer func() {
+		if err := efiVars.SetBootOrder(bootOrder); err != nil {
+			t.Errorf("Can't restore boot order: %s", err)
+		}
+	}()
+
+	var ids []uint16
+
+	for i := 0; i < 2; i++ {
+		id, err := efiVars.CreateBootEntry(1, "/dev/nvme0n1p1", "/EFI/BOOT/bootx64.efi", loaderFW)
+		if err != nil {
+			t.Fatalf("Unable to create boot entry: %s", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	entries, err := efiVars.GetBootsByPartUUID(info.PartUUID)
+	if err != nil {
+		t.Fatalf("Can't get boots by PARTUUID: %s", err)
+	}
+
+	found := 0
+
+	for _, entry := range entries {
+		if entry.ID == ids[0] || entry.ID == ids[1] {
+			if !strings.EqualFold(entry.Loader, "\\EFI\\BOOT\\bootx64.efi") {
+				t.Errorf("Wrong boot entry loader: %s", entry.Loader)
+			}
+
+			found++
+		}
+	}
+
+	if found != len(ids) {
+		t.Errorf("Created boot entries not found: %v", entries)
+	}
+
+	for _, id := range ids {
+		if err = efiVars.DeleteBootEntry(id); err != nil {
+			t.Errorf("Can't delete boot entry: %s", err)
+		}
+
+		exists, err := efiVars.BootEntryExists(id)
+		if err != nil {
+			t.Errorf("Can't check boot entry: %s", err)
+		}
+
+		if exists {
+			t.Errorf("Boot entry %04X is not deleted", id)
+		}
+	}
+
+	if err = efiVars.DeleteBootEntry(ids[0]); !errors.Is(err, efi.ErrNotFound) {
+		t.Errorf("Not found error expected: %v", err)
+	}
+}
+
 func TestBootOrder(t *testing.T) {
 	efiVars, err := efi.New()
 	if err != nil {
@@ -0,0 +1,375 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build fakeefi
+
+package efi
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Fake EFI backend is selected by fakeefi build tag. It keeps boot variables in memory shared by all instances as
+// NVRAM does, so EFI boot controller and dual partition module can be tested in containers without UEFI firmware.
+// Partitions are resolved to PARTUUID by table filled with SetFakePartUUID instead of blkid. FakeReboot emulates
+// firmware boot selection: BootNext is consumed if set, otherwise the first active entry of BootOrder is booted.
+
+/*******************************************************************************
+ * Vars
+ ******************************************************************************/
+
+var fakeStore = newFakeVars() //nolint:gochecknoglobals // Emulates NVRAM shared by instances
+
+/*******************************************************************************
+ * Types
+ ******************************************************************************/
+
+// Instance boot instance
+type Instance struct{}
+
+type fakeBootEntry struct {
+	BootEntry
+	partUUID string
+	active   bool
+}
+
+type fakeVars struct {
+	sync.Mutex
+	partUUIDs   map[string]string
+	entries     map[uint16]fakeBootEntry
+	bootCurrent *uint16
+	bootNext    *uint16
+	bootOrder   []uint16
+}
+
+/*******************************************************************************
+ * Public
+ ******************************************************************************/
+
+// ResetFake clears fake EFI variables and partitions
+func ResetFake() {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	fakeStore.partUUIDs = make(map[string]string)
+	fakeStore.entries = make(map[uint16]fakeBootEntry)
+	fakeStore.bootCurrent, fakeStore.bootNext, fakeStore.bootOrder = nil, nil, nil
+}
+
+// SetFakePartUUID sets PARTUUID of fake partition
+func SetFakePartUUID(partitionPath, partUUID string) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	fakeStore.partUUIDs[partitionPath] = partUUID
+}
+
+// AddFakeBootEntry adds active fake boot entry which points to partition with PARTUUID. Boot order is not changed
+func AddFakeBootEntry(id uint16, partUUID, loader, description string) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	fakeStore.entries[id] = fakeBootEntry{
+		BootEntry: BootEntry{ID: id, Description: description, Loader: loader},
+		partUUID:  partUUID,
+		active:    true,
+	}
+}
+
+// SetFakeBootCurrent sets boot current item
+func SetFakeBootCurrent(id uint16) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	fakeStore.bootCurrent = &id
+}
+
+// FakeReboot emulates firmware boot and returns booted item
+func FakeReboot() (id uint16, err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	if fakeStore.bootNext != nil {
+		id = *fakeStore.bootNext
+		fakeStore.bootNext = nil
+
+		if _, ok := fakeStore.entries[id]; ok {
+			fakeStore.bootCurrent = &id
+
+			log.Debugf("Fake EFI boot from boot next: %04X", id)
+
+			return id, nil
+		}
+	}
+
+	for _, id := range fakeStore.bootOrder {
+		if entry, ok := fakeStore.entries[id]; ok && entry.active {
+			fakeStore.bootCurrent = &id
+
+			log.Debugf("Fake EFI boot from boot order: %04X", id)
+
+			return id, nil
+		}
+	}
+
+	return 0, aoserrors.New("no bootable entry")
+}
+
+// New returns new EFI instance
+func New() (instance *Instance, err error) {
+	return &Instance{}, nil
+}
+
+// GetPartUUID returns PARTUUID of partition
+func GetPartUUID(partitionPath string) (partUUID string, err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	partUUID, ok := fakeStore.partUUIDs[partitionPath]
+	if !ok {
+		return "", aoserrors.Errorf("partition %s not found", partitionPath)
+	}
+
+	return partUUID, nil
+}
+
+// GetBootByPartUUID returns boot item by PARTUUID
+func (instance *Instance) GetBootByPartUUID(partUUID string) (id uint16, err error) {
+	entries, err := instance.GetBootsByPartUUID(partUUID)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	if len(entries) == 0 {
+		return 0, ErrNotFound
+	}
+
+	return entries[0].ID, nil
+}
+
+// GetBootsByPartUUID returns all boot items which point to partition with PARTUUID sorted by ID
+func (instance *Instance) GetBootsByPartUUID(partUUID string) (entries []BootEntry, err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	for _, entry := range fakeStore.entries {
+		if entry.partUUID == partUUID {
+			entries = append(entries, entry.BootEntry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	return entries, nil
+}
+
+// BootEntryExists checks if boot item variable exists
+func (instance *Instance) BootEntryExists(id uint16) (exists bool, err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	_, exists = fakeStore.entries[id]
+
+	return exists, nil
+}
+
+// DeleteBootEntry deletes boot item variable
+func (instance *Instance) DeleteBootEntry(id uint16) (err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	log.Debugf("Delete fake EFI boot entry: %04X", id)
+
+	if _, ok := fakeStore.entries[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(fakeStore.entries, id)
+
+	return nil
+}
+
+// GetBootCurrent returns boot current item
+func (instance *Instance) GetBootCurrent() (id uint16, err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	if fakeStore.bootCurrent == nil {
+		return 0, aoserrors.Wrap(ErrNotFound)
+	}
+
+	return *fakeStore.bootCurrent, nil
+}
+
+// GetBootNext returns boot next item
+func (instance *Instance) GetBootNext() (id uint16, err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	if fakeStore.bootNext == nil {
+		return 0, aoserrors.Wrap(ErrNotFound)
+	}
+
+	return *fakeStore.bootNext, nil
+}
+
+// SetBootNext sets boot next item
+func (instance *Instance) SetBootNext(id uint16) (err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	log.Debugf("Set fake EFI boot next: %04X", id)
+
+	fakeStore.bootNext = &id
+
+	return nil
+}
+
+// DeleteBootNext deletes boot next
+func (instance *Instance) DeleteBootNext() (err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	if fakeStore.bootNext == nil {
+		return aoserrors.Wrap(ErrNotFound)
+	}
+
+	fakeStore.bootNext = nil
+
+	return nil
+}
+
+// GetBootOrder returns boot order
+func (instance *Instance) GetBootOrder() (ids []uint16, err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	if fakeStore.bootOrder == nil {
+		return nil, aoserrors.Wrap(ErrNotFound)
+	}
+
+	return append([]uint16{}, fakeStore.bootOrder...), nil
+}
+
+// SetBootOrder sets boot order
+func (instance *Instance) SetBootOrder(ids []uint16) (err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	log.Debugf("Set fake EFI boot order: %s", bootOrderToString(ids))
+
+	fakeStore.bootOrder = append([]uint16{}, ids...)
+
+	return nil
+}
+
+// DeleteBootOrder deletes boot order
+func (instance *Instance) DeleteBootOrder() (err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	if fakeStore.bootOrder == nil {
+		return aoserrors.Wrap(ErrNotFound)
+	}
+
+	fakeStore.bootOrder = nil
+
+	return nil
+}
+
+// SetBootActive make boot item active
+func (instance *Instance) SetBootActive(id uint16, active bool) (err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	entry, ok := fakeStore.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	entry.active = active
+	fakeStore.entries[id] = entry
+
+	return nil
+}
+
+// GetBootActive returns boot item active state
+func (instance *Instance) GetBootActive(id uint16) (active bool, err error) {
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	entry, ok := fakeStore.entries[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+
+	return entry.active, nil
+}
+
+// Close closes EFI instance
+func (instance *Instance) Close() (err error) {
+	return nil
+}
+
+// CreateBootEntry creates new boot entry variable
+func (instance *Instance) CreateBootEntry(
+	isActive int, partitionPath string, loader string, entryName string,
+) (id uint16, err error) {
+	partUUID, err := GetPartUUID(partitionPath)
+	if err != nil {
+		return 0, err
+	}
+
+	fakeStore.Lock()
+	defer fakeStore.Unlock()
+
+	if fakeStore.bootOrder == nil {
+		return 0, aoserrors.Wrap(ErrNotFound)
+	}
+
+	for {
+		if _, ok := fakeStore.entries[id]; !ok {
+			break
+		}
+
+		id++
+	}
+
+	log.Debugf("Create fake EFI boot entry %04X: partition %s, loader %s", id, partitionPath, loader)
+
+	fakeStore.entries[id] = fakeBootEntry{
+		BootEntry: BootEntry{ID: id, Description: entryName, Loader: loader},
+		partUUID:  partUUID,
+		active:    isActive != 0,
+	}
+
+	fakeStore.bootOrder = append(fakeStore.bootOrder, id)
+
+	return id, nil
+}
+
+/*******************************************************************************
+ * Private
+ ******************************************************************************/
+
+func newFakeVars() (vars *fakeVars) {
+	return &fakeVars{partUUIDs: make(map[string]string), entries: make(map[uint16]fakeBootEntry)}
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2021 Renesas Electronics Corporation.
+// Copyright (C) 2021 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package efi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*******************************************************************************
+ * Vars
+ ******************************************************************************/
+
+// ErrNotFound efi var not exist error
+var ErrNotFound = errors.New("EFI var not found")
+
+/*******************************************************************************
+ * Types
+ ******************************************************************************/
+
+// BootEntry boot entry info
+type BootEntry struct {
+	ID          uint16
+	Description string
+	Loader      string
+}
+
+/*******************************************************************************
+ * Private
+ ******************************************************************************/
+
+func bootOrderToString(bootOrder []uint16) (s string) {
+	for _, order := range bootOrder {
+		s += fmt.Sprintf("%04X,", order)
+	}
+
+	return strings.TrimSuffix(s, ",")
+}
@@ -0,0 +1,545 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rawdiskmodule provides update module which writes full-disk images (partition table included) to
+// secondary block devices.
+package rawdiskmodule
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/image"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+)
+
+// The target device is identified either by persistent /dev/disk/... path or by serial which is looked up in
+// /dev/disk/by-id. The device is resolved on each update as removable media can be re-enumerated. To avoid
+// clobbering the primary disk, the image is written only to whole disk which has no mounted partitions.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	idleState = iota
+	preparedState
+	updatedState
+)
+
+const sysBlockPath = "/sys/dev/block"
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// DiskPath path to persistent device links.
+var DiskPath = "/dev/disk" //nolint:gochecknoglobals // Used in unit tests to override path
+
+// MountInfoPath path to mount info file.
+var MountInfoPath = "/proc/self/mountinfo" //nolint:gochecknoglobals // Used in unit tests to override path
+
+var gzipMagic = []byte{0x1f, 0x8b} //nolint:gochecknoglobals // const
+
+var partExp = regexp.MustCompile(`-part[[:digit:]]+$`)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// RawDiskModule raw disk module.
+type RawDiskModule struct {
+	sync.Mutex
+
+	id      string
+	config  moduleConfig
+	storage updatehandler.ModuleStorage
+	state   moduleState
+	journal *opjournal.Journal
+}
+
+type moduleConfig struct {
+	Device string `json:"device"`
+	Serial string `json:"serial"`
+}
+
+type moduleState struct {
+	State          updateState `json:"state"`
+	ImagePath      string      `json:"imagePath"`
+	Version        string      `json:"version"`
+	PendingVersion string      `json:"pendingVersion"`
+}
+
+type updateState int
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates raw disk module instance.
+func New(id string, configJSON json.RawMessage,
+	storage updatehandler.ModuleStorage,
+) (module updatehandler.LegacyUpdateModule, err error) {
+	log.WithField("id", id).Debug("Create raw disk module")
+
+	rawDiskModule := &RawDiskModule{id: id, storage: storage, journal: opjournal.New(id, storage)}
+
+	if len(configJSON) == 0 {
+		return nil, aoserrors.Errorf("config for %s module is required", id)
+	}
+
+	if err = json.Unmarshal(configJSON, &rawDiskModule.config); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = rawDiskModule.config.check(); err != nil {
+		return nil, err
+	}
+
+	if err = rawDiskModule.getState(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return rawDiskModule, nil
+}
+
+// Validate validates raw disk module params.
+func Validate(configJSON json.RawMessage) (err error) {
+	if len(configJSON) == 0 {
+		return aoserrors.New("config is required")
+	}
+
+	var config moduleConfig
+
+	if err = updatehandler.DecodeParams(configJSON, &config); err != nil {
+		return err
+	}
+
+	return config.check()
+}
+
+// Close closes raw disk module.
+func (module *RawDiskModule) Close() (err error) {
+	log.WithField("id", module.id).Debug("Close raw disk module")
+
+	return nil
+}
+
+// Init initializes module.
+func (module *RawDiskModule) Init() (err error) {
+	log.WithField("id", module.id).Debug("Init raw disk module")
+
+	return nil
+}
+
+// GetID returns module ID.
+func (module *RawDiskModule) GetID() (id string) {
+	return module.id
+}
+
+// GetVendorVersion returns vendor version.
+func (module *RawDiskModule) GetVendorVersion() (version string, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	return module.state.Version, nil
+}
+
+// Prepare prepares module update.
+func (module *RawDiskModule) Prepare(imagePath string, vendorVersion string, annotations json.RawMessage) (err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	log.WithFields(log.Fields{
+		"id":            module.id,
+		"imagePath":     imagePath,
+		"vendorVersion": vendorVersion,
+	}).Debug("Prepare raw disk module")
+
+	if module.state.State != idleState && module.state.State != preparedState {
+		return aoserrors.Errorf("wrong state during Prepare command. Expected %s, got %s", updateState(idleState),
+			module.state.State)
+	}
+
+	if _, err = os.Stat(imagePath); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if _, err = module.getDevice(); err != nil {
+		return err
+	}
+
+	module.state.ImagePath = imagePath
+	module.state.PendingVersion = vendorVersion
+
+	return module.setState(preparedState)
+}
+
+// Update writes image to the device.
+func (module *RawDiskModule) Update() (rebootRequired bool, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	log.WithField("id", module.id).Debug("Update raw disk module")
+
+	if module.state.State == updatedState {
+		return false, nil
+	}
+
+	if module.state.State != preparedState {
+		return false, aoserrors.Errorf("wrong state during Update command. Expected %s, got %s",
+			updateState(preparedState), module.state.State)
+	}
+
+	device, err := module.getDevice()
+	if err != nil {
+		return false, err
+	}
+
+	if err = writeImage(device, module.state.ImagePath, module.journal); err != nil {
+		return false, err
+	}
+
+	module.state.Version = module.state.PendingVersion
+
+	return false, module.setState(updatedState)
+}
+
+// Apply applies current update.
+func (module *RawDiskModule) Apply() (rebootRequired bool, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	log.WithField("id", module.id).Debug("Apply raw disk module")
+
+	if module.state.State == idleState {
+		return false, nil
+	}
+
+	if module.state.State != updatedState {
+		return false, aoserrors.Errorf("wrong state during Apply command. Expected %s, got %s",
+			updateState(updatedState), module.state.State)
+	}
+
+	module.state.ImagePath = ""
+	module.state.PendingVersion = ""
+
+	return false, module.setState(idleState)
+}
+
+// Revert reverts current update. As previous disk content is not stored, the device keeps written image and only
+// update state is reset.
+func (module *RawDiskModule) Revert() (rebootRequired bool, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	log.WithField("id", module.id).Debug("Revert raw disk module")
+
+	if module.state.State == updatedState {
+		log.WithField("id", module.id).Warn("Device content can't be reverted")
+	}
+
+	module.state.ImagePath = ""
+	module.state.PendingVersion = ""
+
+	return false, module.setState(idleState)
+}
+
+// Reboot performs module reboot.
+func (module *RawDiskModule) Reboot() (err error) {
+	log.WithField("id", module.id).Debug("Reboot raw disk module")
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (config *moduleConfig) check() (err error) {
+	if (config.Device == "") == (config.Serial == "") {
+		return aoserrors.New("either device or serial should be configured")
+	}
+
+	if config.Device != "" && !strings.HasPrefix(filepath.Clean(config.Device), filepath.Clean(DiskPath)+"/") {
+		return aoserrors.Errorf("device should be persistent path inside %s", DiskPath)
+	}
+
+	return nil
+}
+
+func (state updateState) String() string {
+	return [...]string{"idle", "prepared", "updated"}[state]
+}
+
+func (module *RawDiskModule) getState() (err error) {
+	stateJSON, err := module.storage.GetModuleState(module.id)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if len(stateJSON) == 0 {
+		return nil
+	}
+
+	if err = json.Unmarshal(stateJSON, &module.state); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func (module *RawDiskModule) setState(state updateState) (err error) {
+	log.WithFields(log.Fields{"id": module.id, "state": state}).Debug("State changed")
+
+	module.state.State = state
+
+	stateJSON, err := json.Marshal(module.state)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = module.storage.SetModuleState(module.id, stateJSON); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func (module *RawDiskModule) getDevice() (device string, err error) {
+	link := module.config.Device
+
+	if module.config.Serial != "" {
+		if link, err = findBySerial(module.config.Serial); err != nil {
+			return "", err
+		}
+	}
+
+	if device, err = filepath.EvalSymlinks(link); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if err = checkDevice(device); err != nil {
+		return "", err
+	}
+
+	log.WithFields(log.Fields{"id": module.id, "link": link, "device": device}).Debug("Target device")
+
+	return device, nil
+}
+
+func findBySerial(serial string) (link string, err error) {
+	byIDPath := filepath.Join(DiskPath, "by-id")
+
+	entries, err := os.ReadDir(byIDPath)
+	if err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	devices := make(map[string]string)
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if partExp.MatchString(name) {
+			continue
+		}
+
+		if !strings.HasSuffix(name, "_"+serial) && !strings.HasSuffix(name, "-"+serial) {
+			continue
+		}
+
+		device, err := filepath.EvalSymlinks(filepath.Join(byIDPath, name))
+		if err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+
+		devices[device] = filepath.Join(byIDPath, name)
+	}
+
+	if len(devices) == 0 {
+		return "", aoserrors.Errorf("device with serial %s not found", serial)
+	}
+
+	if len(devices) > 1 {
+		return "", aoserrors.Errorf("more than one device with serial %s found", serial)
+	}
+
+	for _, link = range devices {
+		break
+	}
+
+	return link, nil
+}
+
+// checkDevice checks that device is whole disk which is not in use by the system.
+func checkDevice(device string) (err error) {
+	info, err := os.Stat(device)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if info.Mode()&os.ModeDevice == 0 || info.Mode()&os.ModeCharDevice != 0 {
+		return aoserrors.Errorf("%s is not block device", device)
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return aoserrors.Errorf("can't get %s device number", device)
+	}
+
+	diskPath, err := getSysBlockPath(unix.Major(uint64(stat.Rdev)), unix.Minor(uint64(stat.Rdev))) //nolint:unconvert
+	if err != nil {
+		return err
+	}
+
+	if _, err = os.Stat(filepath.Join(diskPath, "partition")); err == nil {
+		return aoserrors.Errorf("%s is partition, whole disk expected", device)
+	}
+
+	mounted, err := getMountedDevices()
+	if err != nil {
+		return err
+	}
+
+	for _, mountedDevice := range mounted {
+		mountedPath, err := getSysBlockPath(mountedDevice[0], mountedDevice[1])
+		if err != nil {
+			continue
+		}
+
+		if mountedPath == diskPath || strings.HasPrefix(mountedPath, diskPath+"/") {
+			return aoserrors.Errorf("%s is in use by the system", device)
+		}
+	}
+
+	return nil
+}
+
+func getSysBlockPath(major, minor uint32) (path string, err error) {
+	if path, err = filepath.EvalSymlinks(filepath.Join(sysBlockPath, fmt.Sprintf("%d:%d", major, minor))); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return path, nil
+}
+
+func getMountedDevices() (devices [][2]uint32, err error) {
+	file, err := os.Open(MountInfoPath)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		// mountinfo format: mount ID, parent ID, major:minor, ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		var major, minor uint32
+
+		if _, err := fmt.Sscanf(fields[2], "%d:%d", &major, &minor); err != nil || major == 0 {
+			continue
+		}
+
+		devices = append(devices, [2]uint32{major, minor})
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return devices, nil
+}
+
+func writeImage(device, imagePath string, journal *opjournal.Journal) (err error) {
+	compressed, err := isGzip(imagePath)
+	if err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+
+	if compressed {
+		_, err = image.CopyFromGzipArchiveToDevice(device, imagePath, false)
+	} else {
+		_, err = image.CopyToDevice(device, imagePath, false)
+	}
+
+	journal.File(opjournal.FileCopy, startTime, err, imagePath, device)
+
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	file, err := os.OpenFile(device, os.O_RDONLY, 0)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	if err = file.Sync(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	// Ask kernel to re-read new partition table. It is not critical as device may have no partition table at all.
+	if err := unix.IoctlSetInt(int(file.Fd()), unix.BLKRRPART, 0); err != nil {
+		log.WithField("device", device).Warnf("Can't re-read partition table: %v", err)
+	}
+
+	return nil
+}
+
+func isGzip(imagePath string) (compressed bool, err error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	header := make([]byte, len(gzipMagic))
+
+	if _, err = io.ReadFull(file, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+
+		return false, aoserrors.Wrap(err)
+	}
+
+	return bytes.Equal(header, gzipMagic), nil
+}
@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rawdiskmodule_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/aoscloud/aos_updatemanager/updatemodules/rawdiskmodule"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	diskSize   = 1024 * 1024
+	diskSerial = "0123456789"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testStorage struct {
+	state []byte
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var (
+	tmpDir     string
+	loopDevice string
+)
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	tmpDir, err = os.MkdirTemp("", "um_")
+	if err != nil {
+		log.Fatalf("Error create temporary dir: %s", err)
+	}
+
+	if loopDevice, err = setupDisk(); err != nil {
+		log.Fatalf("Can't setup test disk: %s", err)
+	}
+
+	ret := m.Run()
+
+	if output, err := exec.Command("losetup", "-d", loopDevice).CombinedOutput(); err != nil {
+		log.Errorf("Can't detach loop device: %s, %s", output, err)
+	}
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Fatalf("Error removing tmp dir: %s", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestWrongConfig(t *testing.T) {
+	for _, configJSON := range []string{
+		`{}`,
+		`{"device": "/dev/sda"}`,
+		fmt.Sprintf(`{"device": "%s/by-id/disk", "serial": "%s"}`, rawdiskmodule.DiskPath, diskSerial),
+	} {
+		if _, err := rawdiskmodule.New("rawdisk", []byte(configJSON), &testStorage{}); err == nil {
+			t.Errorf("Error expected for config: %s", configJSON)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	for _, configJSON := range []string{
+		fmt.Sprintf(`{"device": "%s"}`, filepath.Join(rawdiskmodule.DiskPath, "by-id", "usb-Test_Disk_"+diskSerial)),
+		fmt.Sprintf(`{"serial": "%s"}`, diskSerial),
+	} {
+		storage := &testStorage{}
+
+		module, err := rawdiskmodule.New("rawdisk", []byte(configJSON), storage)
+		if err != nil {
+			t.Fatalf("Can't create raw disk module: %s", err)
+		}
+
+		if err = module.Init(); err != nil {
+			t.Fatalf("Can't init module: %s", err)
+		}
+
+		imageData := bytes.Repeat([]byte(configJSON), diskSize/len(configJSON))
+		imagePath := filepath.Join(tmpDir, "disk.img.gz")
+
+		if err = createGzipImage(imagePath, imageData); err != nil {
+			t.Fatalf("Can't create image: %s", err)
+		}
+
+		if err = module.Prepare(imagePath, "2.0", nil); err != nil {
+			t.Fatalf("Prepare failed: %s", err)
+		}
+
+		if _, err = module.Update(); err != nil {
+			t.Fatalf("Update failed: %s", err)
+		}
+
+		if _, err = module.Apply(); err != nil {
+			t.Fatalf("Apply failed: %s", err)
+		}
+
+		version, err := module.GetVendorVersion()
+		if err != nil {
+			t.Errorf("Can't get vendor version: %s", err)
+		}
+
+		if version != "2.0" {
+			t.Errorf("Wrong vendor version: %s", version)
+		}
+
+		deviceData, err := os.ReadFile(loopDevice)
+		if err != nil {
+			t.Fatalf("Can't read device: %s", err)
+		}
+
+		if !bytes.HasPrefix(deviceData, imageData) {
+			t.Error("Wrong device content")
+		}
+
+		module.Close()
+	}
+}
+
+func TestDeviceInUse(t *testing.T) {
+	module, err := rawdiskmodule.New("rawdisk", []byte(fmt.Sprintf(`{"serial": "%s"}`, diskSerial)), &testStorage{})
+	if err != nil {
+		t.Fatalf("Can't create raw disk module: %s", err)
+	}
+	defer module.Close()
+
+	var stat unix.Stat_t
+
+	if err = unix.Stat(loopDevice, &stat); err != nil {
+		t.Fatalf("Can't stat device: %s", err)
+	}
+
+	mountInfo := fmt.Sprintf("30 1 %d:%d / /mnt rw,relatime - ext4 %s rw\n",
+		unix.Major(stat.Rdev), unix.Minor(stat.Rdev), loopDevice)
+
+	if err = os.WriteFile(rawdiskmodule.MountInfoPath, []byte(mountInfo), 0o600); err != nil {
+		t.Fatalf("Can't write mount info: %s", err)
+	}
+
+	defer func() {
+		if err := os.WriteFile(rawdiskmodule.MountInfoPath, nil, 0o600); err != nil {
+			t.Errorf("Can't clear mount info: %s", err)
+		}
+	}()
+
+	imagePath := filepath.Join(tmpDir, "disk.img")
+
+	if err = os.WriteFile(imagePath, []byte("image"), 0o600); err != nil {
+		t.Fatalf("Can't create image: %s", err)
+	}
+
+	if err = module.Prepare(imagePath, "3.0", nil); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Device in use error expected: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Interfaces
+ **********************************************************************************************************************/
+
+func (storage *testStorage) GetModuleState(id string) (state []byte, err error) {
+	return storage.state, nil
+}
+
+func (storage *testStorage) SetModuleState(id string, state []byte) (err error) {
+	storage.state = state
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func setupDisk() (device string, err error) {
+	diskFile := filepath.Join(tmpDir, "disk.raw")
+
+	if err = os.WriteFile(diskFile, make([]byte, diskSize), 0o600); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	output, err := exec.Command("losetup", "-f", "--show", diskFile).CombinedOutput()
+	if err != nil {
+		return "", aoserrors.Errorf("%s: %s", err, output)
+	}
+
+	device = strings.TrimSpace(string(output))
+
+	rawdiskmodule.DiskPath = filepath.Join(tmpDir, "disk")
+	rawdiskmodule.MountInfoPath = filepath.Join(tmpDir, "mountinfo")
+
+	byIDPath := filepath.Join(rawdiskmodule.DiskPath, "by-id")
+
+	if err = os.MkdirAll(byIDPath, 0o755); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if err = os.Symlink(device, filepath.Join(byIDPath, "usb-Test_Disk_"+diskSerial)); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	// Partition link should not be matched by serial
+	if err = os.Symlink("/dev/null", filepath.Join(byIDPath, "usb-Test_Disk_"+diskSerial+"-part1")); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if err = os.WriteFile(rawdiskmodule.MountInfoPath, nil, 0o600); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return device, nil
+}
+
+func createGzipImage(imagePath string, data []byte) (err error) {
+	file, err := os.Create(imagePath)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+
+	if _, err = writer.Write(data); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(writer.Close())
+}
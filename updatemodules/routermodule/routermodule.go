@@ -0,0 +1,417 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package routermodule provides update module which routes component update to one of configured backend modules
+// selected by update annotations.
+package routermodule
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+)
+
+// Each route is served by own backend module instance with ID <module ID>.<route name>. The route is selected at
+// prepare by value of configured annotation key. The route which was applied last becomes active one: it provides
+// component vendor version and is used when annotation is absent. Once pending route backend is updated, it provides
+// vendor version till update is applied or reverted.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// RouterModule router module.
+type RouterModule struct {
+	sync.Mutex
+
+	id       string
+	config   moduleConfig
+	storage  updatehandler.ModuleStorage
+	state    moduleState
+	backends map[string]updatehandler.UpdateModule
+}
+
+type moduleConfig struct {
+	Annotation   string                 `json:"annotation"`
+	DefaultRoute string                 `json:"defaultRoute"`
+	Routes       map[string]routeConfig `json:"routes"`
+}
+
+type routeConfig struct {
+	Plugin string          `json:"plugin"`
+	Params json.RawMessage `json:"params"`
+}
+
+type moduleState struct {
+	ActiveRoute  string `json:"activeRoute"`
+	PendingRoute string `json:"pendingRoute"`
+	Updated      bool   `json:"updated"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates router module instance.
+func New(id string, configJSON json.RawMessage,
+	storage updatehandler.ModuleStorage,
+) (module updatehandler.UpdateModule, err error) {
+	log.WithField("id", id).Debug("Create router module")
+
+	routerModule := &RouterModule{
+		id: id, storage: storage, backends: make(map[string]updatehandler.UpdateModule),
+	}
+
+	defer func() {
+		if err != nil {
+			routerModule.Close(context.Background())
+		}
+	}()
+
+	if len(configJSON) == 0 {
+		return nil, aoserrors.Errorf("config for %s module is required", id)
+	}
+
+	if err = json.Unmarshal(configJSON, &routerModule.config); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if err = routerModule.config.check(); err != nil {
+		return nil, err
+	}
+
+	for name, route := range routerModule.config.Routes {
+		backend, err := updatehandler.NewModule(route.Plugin, id+"."+name, route.Params, storage)
+		if err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		routerModule.backends[name] = backend
+	}
+
+	if err = routerModule.getState(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return routerModule, nil
+}
+
+// Validate validates router module params including params of route backends.
+func Validate(configJSON json.RawMessage) (err error) {
+	if len(configJSON) == 0 {
+		return aoserrors.New("config is required")
+	}
+
+	var config moduleConfig
+
+	if err = updatehandler.DecodeParams(configJSON, &config); err != nil {
+		return err
+	}
+
+	if err = config.check(); err != nil {
+		return err
+	}
+
+	for name, route := range config.Routes {
+		if err = updatehandler.ValidateModuleParams(route.Plugin, route.Params); err != nil {
+			return aoserrors.Errorf("route %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes router module.
+func (module *RouterModule) Close(ctx context.Context) (err error) {
+	log.WithField("id", module.id).Debug("Close router module")
+
+	for name, backend := range module.backends {
+		if backendErr := backend.Close(ctx); backendErr != nil {
+			log.WithFields(log.Fields{"id": module.id, "route": name}).Errorf("Can't close backend: %v", backendErr)
+
+			if err == nil {
+				err = aoserrors.Wrap(backendErr)
+			}
+		}
+	}
+
+	return err
+}
+
+// GetID returns module ID.
+func (module *RouterModule) GetID() (id string) {
+	return module.id
+}
+
+// GetVendorVersion returns vendor version of active or updated route backend.
+func (module *RouterModule) GetVendorVersion() (version string, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	route := module.activeRoute()
+	if module.state.Updated {
+		route = module.state.PendingRoute
+	}
+
+	backend, err := module.getBackend(route)
+	if err != nil {
+		return "", err
+	}
+
+	version, err = backend.GetVendorVersion()
+
+	return version, aoserrors.Wrap(err)
+}
+
+// Init initializes all backends.
+func (module *RouterModule) Init() (err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	log.WithField("id", module.id).Debug("Init router module")
+
+	for _, name := range module.routeNames() {
+		if err = module.backends[name].Init(); err != nil {
+			return aoserrors.Errorf("can't init route %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Prepare selects route by annotations and prepares its backend.
+func (module *RouterModule) Prepare(
+	ctx context.Context, imagePath string, vendorVersion string, annotations json.RawMessage,
+) (err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	route, err := module.selectRoute(annotations)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"id": module.id, "route": route}).Debug("Prepare router module")
+
+	backend, err := module.getBackend(route)
+	if err != nil {
+		return err
+	}
+
+	module.state.PendingRoute = route
+	module.state.Updated = false
+
+	if err = module.saveState(); err != nil {
+		return err
+	}
+
+	return aoserrors.Wrap(backend.Prepare(ctx, imagePath, vendorVersion, annotations))
+}
+
+// Update updates pending route backend.
+func (module *RouterModule) Update(ctx context.Context) (rebootRequired bool, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	backend, err := module.getBackend(module.state.PendingRoute)
+	if err != nil {
+		return false, err
+	}
+
+	if rebootRequired, err = backend.Update(ctx); err != nil || rebootRequired {
+		return rebootRequired, aoserrors.Wrap(err)
+	}
+
+	module.state.Updated = true
+
+	return false, module.saveState()
+}
+
+// Apply applies pending route backend and makes the route active.
+func (module *RouterModule) Apply(ctx context.Context) (rebootRequired bool, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	if module.state.PendingRoute == "" {
+		return false, nil
+	}
+
+	backend, err := module.getBackend(module.state.PendingRoute)
+	if err != nil {
+		return false, err
+	}
+
+	if rebootRequired, err = backend.Apply(ctx); err != nil || rebootRequired {
+		return rebootRequired, aoserrors.Wrap(err)
+	}
+
+	module.state.ActiveRoute = module.state.PendingRoute
+	module.state.PendingRoute = ""
+	module.state.Updated = false
+
+	return false, module.saveState()
+}
+
+// Revert reverts pending route backend.
+func (module *RouterModule) Revert(ctx context.Context) (rebootRequired bool, err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	if module.state.PendingRoute == "" {
+		return false, nil
+	}
+
+	backend, err := module.getBackend(module.state.PendingRoute)
+	if err != nil {
+		return false, err
+	}
+
+	if rebootRequired, err = backend.Revert(ctx); err != nil || rebootRequired {
+		return rebootRequired, aoserrors.Wrap(err)
+	}
+
+	module.state.PendingRoute = ""
+	module.state.Updated = false
+
+	return false, module.saveState()
+}
+
+// Reboot reboots pending route backend or active one if there is no pending update.
+func (module *RouterModule) Reboot(ctx context.Context) (err error) {
+	module.Lock()
+	defer module.Unlock()
+
+	route := module.state.PendingRoute
+	if route == "" {
+		route = module.activeRoute()
+	}
+
+	backend, err := module.getBackend(route)
+	if err != nil {
+		return err
+	}
+
+	return aoserrors.Wrap(backend.Reboot(ctx))
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (config *moduleConfig) check() (err error) {
+	if config.Annotation == "" {
+		return aoserrors.New("annotation should be configured")
+	}
+
+	if len(config.Routes) == 0 {
+		return aoserrors.New("no routes configured")
+	}
+
+	if _, ok := config.Routes[config.DefaultRoute]; !ok && config.DefaultRoute != "" {
+		return aoserrors.Errorf("default route %s not found", config.DefaultRoute)
+	}
+
+	return nil
+}
+
+func (module *RouterModule) selectRoute(rawAnnotations json.RawMessage) (route string, err error) {
+	annotations := make(map[string]json.RawMessage)
+
+	if len(rawAnnotations) != 0 {
+		if err = json.Unmarshal(rawAnnotations, &annotations); err != nil {
+			return "", aoserrors.Wrap(err)
+		}
+	}
+
+	rawValue, ok := annotations[module.config.Annotation]
+	if !ok {
+		if route = module.activeRoute(); route == "" {
+			return "", aoserrors.Errorf("annotation %s is required", module.config.Annotation)
+		}
+
+		return route, nil
+	}
+
+	if err = json.Unmarshal(rawValue, &route); err != nil {
+		return "", aoserrors.Errorf("annotation %s should be string", module.config.Annotation)
+	}
+
+	return route, nil
+}
+
+func (module *RouterModule) activeRoute() (route string) {
+	if module.state.ActiveRoute != "" {
+		return module.state.ActiveRoute
+	}
+
+	return module.config.DefaultRoute
+}
+
+func (module *RouterModule) getBackend(route string) (backend updatehandler.UpdateModule, err error) {
+	if route == "" {
+		return nil, aoserrors.New("no active route")
+	}
+
+	backend, ok := module.backends[route]
+	if !ok {
+		return nil, aoserrors.Errorf("route %s not found", route)
+	}
+
+	return backend, nil
+}
+
+func (module *RouterModule) routeNames() (names []string) {
+	for name := range module.backends {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func (module *RouterModule) getState() (err error) {
+	stateJSON, err := module.storage.GetModuleState(module.id)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if len(stateJSON) == 0 {
+		return nil
+	}
+
+	if err = json.Unmarshal(stateJSON, &module.state); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+func (module *RouterModule) saveState() (err error) {
+	stateJSON, err := json.Marshal(module.state)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(module.storage.SetModuleState(module.id, stateJSON))
+}
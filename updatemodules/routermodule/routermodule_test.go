@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routermodule_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/updatehandler/handlertest"
+	"github.com/aoscloud/aos_updatemanager/updatemodules/routermodule"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const routerConfig = `{
+	"annotation": "type",
+	"defaultRoute": "full",
+	"routes": {
+		"full": {"plugin": "fakebackend"},
+		"delta": {"plugin": "fakebackend"}
+	}
+}`
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var backends *handlertest.Modules
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+
+	backends = handlertest.RegisterPlugin("fakebackend")
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestWrongConfig(t *testing.T) {
+	for _, configJSON := range []string{
+		``,
+		`{"routes": {"full": {"plugin": "fakebackend"}}}`,
+		`{"annotation": "type"}`,
+		`{"annotation": "type", "routes": {"full": {"plugin": "unknown"}}}`,
+		`{"annotation": "type", "defaultRoute": "delta", "routes": {"full": {"plugin": "fakebackend"}}}`,
+	} {
+		if _, err := routermodule.New("wrong", json.RawMessage(configJSON), handlertest.NewStorage()); err == nil {
+			t.Errorf("Error expected for config: %s", configJSON)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := routermodule.Validate(json.RawMessage(routerConfig)); err != nil {
+		t.Errorf("Valid config expected: %v", err)
+	}
+
+	for _, configJSON := range []string{
+		``,
+		`{"annotation": "type", "routes": {"full": {"plugin": "unknown"}}}`,
+		`{"annotation": "type", "unknown": "value", "routes": {"full": {"plugin": "fakebackend"}}}`,
+	} {
+		if err := routermodule.Validate(json.RawMessage(configJSON)); err == nil {
+			t.Errorf("Error expected for config: %s", configJSON)
+		}
+	}
+}
+
+func TestRouting(t *testing.T) {
+	storage := handlertest.NewStorage()
+
+	backends.Get("rootfs.full").SetVendorVersion("1.0")
+	backends.Get("rootfs.delta").SetVendorVersion("1.0")
+
+	module := newRouter(t, storage)
+	defer module.Close(context.Background())
+
+	if err := module.Init(); err != nil {
+		t.Fatalf("Can't init module: %v", err)
+	}
+
+	checkVersion(t, module, "1.0")
+
+	// Delta update
+
+	backends.ResetOps()
+
+	if err := module.Prepare(context.Background(), "image", "2.0", json.RawMessage(`{"type": "delta"}`)); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	if _, err := module.Update(context.Background()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	checkVersion(t, module, "2.0")
+
+	if _, err := module.Apply(context.Background()); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	checkOps(t, "rootfs.delta", handlertest.OpPrepare, handlertest.OpUpdate, handlertest.OpApply)
+	checkOps(t, "rootfs.full")
+
+	// Active route is persistent and used when annotation is absent
+
+	module.Close(context.Background())
+
+	module = newRouter(t, storage)
+
+	checkVersion(t, module, "2.0")
+
+	backends.ResetOps()
+
+	if err := module.Prepare(context.Background(), "image", "3.0", nil); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	if _, err := module.Revert(context.Background()); err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+
+	checkOps(t, "rootfs.delta", handlertest.OpPrepare, handlertest.OpRevert)
+
+	// Unknown route
+
+	if err := module.Prepare(context.Background(), "image", "3.0", json.RawMessage(`{"type": "unknown"}`)); err == nil {
+		t.Error("Error expected for unknown route")
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newRouter(t *testing.T, storage *handlertest.Storage) (module updatehandler.UpdateModule) {
+	t.Helper()
+
+	module, err := routermodule.New("rootfs", json.RawMessage(routerConfig), storage)
+	if err != nil {
+		t.Fatalf("Can't create router module: %v", err)
+	}
+
+	return module
+}
+
+func checkVersion(t *testing.T, module updatehandler.UpdateModule, expectedVersion string) {
+	t.Helper()
+
+	version, err := module.GetVendorVersion()
+	if err != nil {
+		t.Errorf("Can't get vendor version: %v", err)
+	}
+
+	if version != expectedVersion {
+		t.Errorf("Wrong vendor version: %s", version)
+	}
+}
+
+func checkOps(t *testing.T, id string, expectedOps ...string) {
+	t.Helper()
+
+	if ops := backends.ModuleOps(id); !reflect.DeepEqual(ops, expectedOps) {
+		t.Errorf("Wrong %s ops: %v", id, ops)
+	}
+}
@@ -26,5 +26,6 @@ import (
  ******************************************************************************/
 
 func init() {
-	updatehandler.RegisterPlugin("sshmodule", New)
+	updatehandler.RegisterLegacyPlugin("sshmodule", New)
+	updatehandler.RegisterValidator("sshmodule", Validate)
 }
@@ -21,7 +21,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/aoscloud/aos_common/aoserrors"
 	log "github.com/sirupsen/logrus"
@@ -29,6 +31,7 @@ import (
 	"golang.org/x/crypto/ssh"
 
 	"github.com/aoscloud/aos_updatemanager/updatehandler"
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
 )
 
 /*******************************************************************************
@@ -51,14 +54,16 @@ type SSHModule struct {
 	filePath       string
 	vendorVersion  string
 	pendingVersion string
+	journal        *opjournal.Journal
 }
 
 type moduleConfig struct {
-	Host     string   `json:"host"`
-	User     string   `json:"user"`
-	Password string   `json:"password"`
-	DestPath string   `json:"destPath"`
-	Commands []string `json:"commands"`
+	Host          string   `json:"host"`
+	User          string   `json:"user"`
+	Password      string   `json:"password"`
+	DestPath      string   `json:"destPath"`
+	Commands      []string `json:"commands"`
+	CheckCommands []string `json:"checkCommands"`
 }
 
 type moduleState struct {
@@ -72,10 +77,10 @@ type moduleState struct {
 // New creates ssh module instance.
 func New(id string, configJSON json.RawMessage,
 	storage updatehandler.ModuleStorage,
-) (module updatehandler.UpdateModule, err error) {
+) (module updatehandler.LegacyUpdateModule, err error) {
 	log.WithField("id", id).Debug("Create SSH module")
 
-	sshModule := &SSHModule{id: id, storage: storage}
+	sshModule := &SSHModule{id: id, storage: storage, journal: opjournal.New(id, storage)}
 
 	if configJSON != nil {
 		if err = json.Unmarshal(configJSON, &sshModule.config); err != nil {
@@ -101,6 +106,27 @@ func New(id string, configJSON json.RawMessage,
 	return sshModule, nil
 }
 
+// Validate validates ssh module params.
+func Validate(configJSON json.RawMessage) (err error) {
+	var config moduleConfig
+
+	if len(configJSON) != 0 {
+		if err = updatehandler.DecodeParams(configJSON, &config); err != nil {
+			return err
+		}
+	}
+
+	if config.Host == "" {
+		return aoserrors.New("host should be configured")
+	}
+
+	if config.DestPath == "" {
+		return aoserrors.New("destination path should be configured")
+	}
+
+	return nil
+}
+
 // Close closes ssh module.
 func (module *SSHModule) Close() (err error) {
 	log.WithField("id", module.id).Debug("Close SSH module")
@@ -158,22 +184,29 @@ func (module *SSHModule) Update() (rebootRequired bool, err error) {
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //nolint:gosec // use as example update module
 	}
 
+	// Remote target may be temporary unreachable, nothing is changed on the target yet
 	client, err := ssh.Dial("tcp", module.config.Host, config)
 	if err != nil {
-		return false, aoserrors.Wrap(err)
+		return false, updatehandler.NewTransientError(err)
 	}
 	defer client.Close()
 
 	session, err := client.NewSession()
 	if err != nil {
-		return false, aoserrors.Wrap(err)
+		return false, updatehandler.NewTransientError(err)
 	}
 	defer session.Close()
 
 	log.WithFields(log.Fields{"src": module.filePath, "dst": module.config.DestPath}).Debug("Copy file")
 
 	// Copy file to the remote DestDir
-	if err = scp.CopyPath(module.filePath, module.config.DestPath, session); err != nil {
+	startTime := time.Now()
+
+	err = scp.CopyPath(module.filePath, module.config.DestPath, session)
+	module.journal.File(opjournal.FileCopy, startTime, err, module.filePath,
+		module.config.Host+":"+module.config.DestPath)
+
+	if err != nil {
 		return false, aoserrors.Wrap(err)
 	}
 
@@ -181,6 +214,10 @@ func (module *SSHModule) Update() (rebootRequired bool, err error) {
 		return false, aoserrors.Wrap(err)
 	}
 
+	if err = module.checkUpdate(client); err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
 	module.vendorVersion = module.pendingVersion
 
 	return false, nil
@@ -223,6 +260,12 @@ func (module *SSHModule) Reboot() (err error) {
  ******************************************************************************/
 
 func (module *SSHModule) runCommands(client *ssh.Client) (err error) {
+	startTime := time.Now()
+
+	defer func() {
+		module.journal.Command(strings.Join(module.config.Commands, "; "), startTime, err)
+	}()
+
 	session, err := client.NewSession()
 	if err != nil {
 		return aoserrors.Wrap(err)
@@ -259,3 +302,40 @@ func (module *SSHModule) runCommands(client *ssh.Client) (err error) {
 
 	return nil
 }
+
+// checkUpdate performs health check of remote target. As remote target is not rebooted by update manager, check is
+// done over SSH right after update: each check command should exit with zero status.
+func (module *SSHModule) checkUpdate(client *ssh.Client) (err error) {
+	for _, command := range module.config.CheckCommands {
+		if err = module.runCheckCommand(client, command); err != nil {
+			return aoserrors.Errorf("check command %s failed: %v", command, err)
+		}
+	}
+
+	return nil
+}
+
+func (module *SSHModule) runCheckCommand(client *ssh.Client, command string) (err error) {
+	startTime := time.Now()
+
+	defer func() {
+		module.journal.Command(command, startTime, err)
+	}()
+
+	log.WithField("command", command).Debug("SSH check command")
+
+	session, err := client.NewSession()
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+	defer session.Close()
+
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	if err = session.Run(command); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
@@ -26,5 +26,5 @@ import (
  ******************************************************************************/
 
 func init() {
-	updatehandler.RegisterPlugin("testmodule", New)
+	updatehandler.RegisterLegacyPlugin("testmodule", New)
 }
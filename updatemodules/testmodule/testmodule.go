@@ -49,7 +49,7 @@ type TestModule struct {
 // New creates test module instance.
 func New(id string, configJSON json.RawMessage,
 	storage updatehandler.ModuleStorage,
-) (module updatehandler.UpdateModule, err error) {
+) (module updatehandler.LegacyUpdateModule, err error) {
 	log.WithField("id", id).Debug("Create test module")
 
 	testModule := &TestModule{id: id}
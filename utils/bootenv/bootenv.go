@@ -0,0 +1,362 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bootenv snapshots and restores bootloader environment (EFI variables, U-Boot env blob, grubenv).
+package bootenv
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const (
+	defaultEFIVarsDir = "/sys/firmware/efi/efivars"
+	storageSuffix     = ".bootenv"
+	fsImmutableFlag   = 0x00000010
+)
+
+const (
+	itemEFIVar = "efivar"
+	itemFile   = "file"
+	itemBlob   = "blob"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Config boot environment configuration.
+type Config struct {
+	// EFIVars EFI variable names in efivarfs format: <Name>-<VendorGUID>
+	EFIVars    []string `json:"efiVars"`
+	EFIVarsDir string   `json:"efiVarsDir"`
+	// Files environment files, e.g. grubenv
+	Files []string `json:"files"`
+	// Blobs environment blobs located on raw devices, e.g. U-Boot env
+	Blobs []Blob `json:"blobs"`
+}
+
+// Blob raw environment blob.
+type Blob struct {
+	Device string `json:"device"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// Storage boot environment snapshot storage.
+type Storage interface {
+	GetModuleState(id string) (state []byte, err error)
+	SetModuleState(id string, state []byte) (err error)
+}
+
+// BootEnv boot environment instance.
+type BootEnv struct {
+	id      string
+	cfg     Config
+	storage Storage
+}
+
+type envItem struct {
+	kind   string
+	path   string
+	offset int64
+	size   int64
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates boot environment instance for component id.
+func New(id string, cfg Config, storage Storage) (bootEnv *BootEnv) {
+	if cfg.EFIVarsDir == "" {
+		cfg.EFIVarsDir = defaultEFIVarsDir
+	}
+
+	return &BootEnv{id: id, cfg: cfg, storage: storage}
+}
+
+// IsEmpty returns true if no boot environment items are configured.
+func (bootEnv *BootEnv) IsEmpty() (empty bool) {
+	return len(bootEnv.cfg.EFIVars) == 0 && len(bootEnv.cfg.Files) == 0 && len(bootEnv.cfg.Blobs) == 0
+}
+
+// Snapshot saves current boot environment as single archive to the storage. Not existing EFI variables and files
+// are not archived and removed on restore.
+func (bootEnv *BootEnv) Snapshot() (err error) {
+	if bootEnv.IsEmpty() {
+		return nil
+	}
+
+	log.WithField("id", bootEnv.id).Debug("Snapshot boot environment")
+
+	var buffer bytes.Buffer
+
+	writer := tar.NewWriter(&buffer)
+
+	for _, item := range bootEnv.getItems() {
+		data, err := item.read()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && item.kind != itemBlob {
+				continue
+			}
+
+			return err
+		}
+
+		if err = writer.WriteHeader(&tar.Header{
+			Name: item.name(), Mode: 0o600, Size: int64(len(data)), Typeflag: tar.TypeReg,
+		}); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if _, err = writer.Write(data); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if err = bootEnv.storage.SetModuleState(bootEnv.id+storageSuffix, buffer.Bytes()); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// HasSnapshot checks if boot environment snapshot exists.
+func (bootEnv *BootEnv) HasSnapshot() (exists bool, err error) {
+	archive, err := bootEnv.storage.GetModuleState(bootEnv.id + storageSuffix)
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	return len(archive) != 0, nil
+}
+
+// Restore restores boot environment from snapshot.
+func (bootEnv *BootEnv) Restore() (err error) {
+	if bootEnv.IsEmpty() {
+		return nil
+	}
+
+	log.WithField("id", bootEnv.id).Debug("Restore boot environment")
+
+	archive, err := bootEnv.storage.GetModuleState(bootEnv.id + storageSuffix)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if len(archive) == 0 {
+		return aoserrors.New("boot environment snapshot not found")
+	}
+
+	snapshot := make(map[string][]byte)
+	reader := tar.NewReader(bytes.NewReader(archive))
+
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return aoserrors.Wrap(err)
+		}
+
+		if snapshot[header.Name], err = io.ReadAll(reader); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	for _, item := range bootEnv.getItems() {
+		data, ok := snapshot[item.name()]
+		if !ok {
+			if item.kind == itemBlob {
+				return aoserrors.Errorf("blob %s not found in snapshot", item.path)
+			}
+
+			if err = item.remove(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err = item.write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove removes boot environment snapshot.
+func (bootEnv *BootEnv) Remove() (err error) {
+	if bootEnv.IsEmpty() {
+		return nil
+	}
+
+	return aoserrors.Wrap(bootEnv.storage.SetModuleState(bootEnv.id+storageSuffix, nil))
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (bootEnv *BootEnv) getItems() (items []envItem) {
+	for _, name := range bootEnv.cfg.EFIVars {
+		items = append(items, envItem{kind: itemEFIVar, path: filepath.Join(bootEnv.cfg.EFIVarsDir, name)})
+	}
+
+	for _, file := range bootEnv.cfg.Files {
+		items = append(items, envItem{kind: itemFile, path: file})
+	}
+
+	for _, blob := range bootEnv.cfg.Blobs {
+		items = append(items, envItem{kind: itemBlob, path: blob.Device, offset: blob.Offset, size: blob.Size})
+	}
+
+	return items
+}
+
+func (item envItem) name() (name string) {
+	if item.kind == itemBlob {
+		return fmt.Sprintf("%s/%s@%d", item.kind, item.path, item.offset)
+	}
+
+	return item.kind + "/" + item.path
+}
+
+func (item envItem) read() (data []byte, err error) {
+	if item.kind != itemBlob {
+		if data, err = os.ReadFile(item.path); err != nil {
+			return nil, aoserrors.Wrap(err)
+		}
+
+		return data, nil
+	}
+
+	file, err := os.Open(item.path)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	data = make([]byte, item.size)
+
+	if _, err = file.ReadAt(data, item.offset); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return data, nil
+}
+
+func (item envItem) write(data []byte) (err error) {
+	switch item.kind {
+	case itemEFIVar:
+		if err = clearImmutable(item.path); err != nil {
+			return err
+		}
+
+		// efivarfs requires attributes and data to be written by single write call
+		if err = os.WriteFile(item.path, data, 0o644); err != nil { //nolint:gosec // efivarfs permissions
+			return aoserrors.Wrap(err)
+		}
+
+	case itemFile:
+		if err = os.WriteFile(item.path, data, 0o644); err != nil { //nolint:gosec // keep bootloader file readable
+			return aoserrors.Wrap(err)
+		}
+
+	case itemBlob:
+		file, err := os.OpenFile(item.path, os.O_WRONLY, 0)
+		if err != nil {
+			return aoserrors.Wrap(err)
+		}
+		defer file.Close()
+
+		if _, err = file.WriteAt(data, item.offset); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		if err = file.Sync(); err != nil {
+			return aoserrors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func (item envItem) remove() (err error) {
+	if item.kind == itemEFIVar {
+		if err = clearImmutable(item.path); err != nil {
+			return err
+		}
+	}
+
+	if err = os.Remove(item.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+// efivarfs marks most variables immutable to prevent accidental removal.
+func clearImmutable(path string) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	flags, err := unix.IoctlGetUint32(int(file.Fd()), unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if flags&fsImmutableFlag == 0 {
+		return nil
+	}
+
+	if err = unix.IoctlSetPointerInt(int(file.Fd()), unix.FS_IOC_SETFLAGS,
+		int(flags&^fsImmutableFlag)); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
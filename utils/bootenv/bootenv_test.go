@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootenv_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/bootenv"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testStorage struct {
+	states map[string][]byte
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	tmpDir, err = os.MkdirTemp("", "um_")
+	if err != nil {
+		log.Fatalf("Error create temporary dir: %s", err)
+	}
+
+	ret := m.Run()
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Fatalf("Error removing tmp dir: %s", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestSnapshotRestore(t *testing.T) {
+	grubEnv := filepath.Join(tmpDir, "grubenv")
+	newFile := filepath.Join(tmpDir, "newfile")
+	envDevice := filepath.Join(tmpDir, "envdevice")
+
+	if err := os.WriteFile(grubEnv, []byte("saved_entry=0\n"), 0o600); err != nil {
+		t.Fatalf("Can't create grubenv: %v", err)
+	}
+
+	if err := os.WriteFile(envDevice, bytes.Repeat([]byte{0xAA}, 1024), 0o600); err != nil {
+		t.Fatalf("Can't create env device: %v", err)
+	}
+
+	storage := &testStorage{states: make(map[string][]byte)}
+
+	bootEnv := bootenv.New("boot", bootenv.Config{
+		Files: []string{grubEnv, newFile},
+		Blobs: []bootenv.Blob{{Device: envDevice, Offset: 256, Size: 128}},
+	}, storage)
+
+	if err := bootEnv.Restore(); err == nil {
+		t.Error("Error expected if snapshot doesn't exist")
+	}
+
+	if err := bootEnv.Snapshot(); err != nil {
+		t.Fatalf("Can't snapshot boot env: %v", err)
+	}
+
+	if exists, err := bootEnv.HasSnapshot(); err != nil || !exists {
+		t.Errorf("Snapshot should exist: %v", err)
+	}
+
+	// Modify boot environment
+
+	if err := os.WriteFile(grubEnv, []byte("saved_entry=1\n"), 0o600); err != nil {
+		t.Fatalf("Can't write grubenv: %v", err)
+	}
+
+	if err := os.WriteFile(newFile, []byte("new"), 0o600); err != nil {
+		t.Fatalf("Can't write new file: %v", err)
+	}
+
+	if err := os.WriteFile(envDevice, bytes.Repeat([]byte{0x55}, 1024), 0o600); err != nil {
+		t.Fatalf("Can't write env device: %v", err)
+	}
+
+	if err := bootEnv.Restore(); err != nil {
+		t.Fatalf("Can't restore boot env: %v", err)
+	}
+
+	data, err := os.ReadFile(grubEnv)
+	if err != nil {
+		t.Fatalf("Can't read grubenv: %v", err)
+	}
+
+	if string(data) != "saved_entry=0\n" {
+		t.Errorf("Wrong grubenv content: %s", data)
+	}
+
+	if _, err = os.Stat(newFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("File not existing in snapshot should be removed: %v", err)
+	}
+
+	if data, err = os.ReadFile(envDevice); err != nil {
+		t.Fatalf("Can't read env device: %v", err)
+	}
+
+	expectedData := bytes.Repeat([]byte{0x55}, 1024)
+	copy(expectedData[256:384], bytes.Repeat([]byte{0xAA}, 128))
+
+	if !bytes.Equal(data, expectedData) {
+		t.Error("Wrong env device content")
+	}
+
+	if err = bootEnv.Remove(); err != nil {
+		t.Fatalf("Can't remove snapshot: %v", err)
+	}
+
+	if exists, err := bootEnv.HasSnapshot(); err != nil || exists {
+		t.Errorf("Snapshot should not exist: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Interfaces
+ **********************************************************************************************************************/
+
+func (storage *testStorage) GetModuleState(id string) (state []byte, err error) {
+	return storage.states[id], nil
+}
+
+func (storage *testStorage) SetModuleState(id string, state []byte) (err error) {
+	storage.states[id] = state
+
+	return nil
+}
@@ -0,0 +1,307 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clock provides time source abstraction which allows to replace system time with fake one in tests.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Clock time source interface.
+type Clock interface {
+	// Now returns current time
+	Now() (now time.Time)
+	// After waits for duration to elapse and then sends current time on the returned channel
+	After(d time.Duration) (channel <-chan time.Time)
+	// NewTimer creates timer which sends current time on its channel after duration
+	NewTimer(d time.Duration) (timer Timer)
+	// NewTicker creates ticker which sends current time on its channel every period
+	NewTicker(d time.Duration) (ticker Ticker)
+	// AfterFunc calls function after duration elapsed
+	AfterFunc(d time.Duration, f func()) (timer Timer)
+}
+
+// Timer timer interface.
+type Timer interface {
+	// C returns timer channel
+	C() (channel <-chan time.Time)
+	// Stop stops timer
+	Stop() (stopped bool)
+	// Reset changes timer to expire after duration
+	Reset(d time.Duration) (active bool)
+}
+
+// Ticker ticker interface.
+type Ticker interface {
+	// C returns ticker channel
+	C() (channel <-chan time.Time)
+	// Stop stops ticker
+	Stop()
+}
+
+// FakeClock fake clock which time is changed manually.
+type FakeClock struct {
+	sync.Mutex
+
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type realClock struct{}
+
+type realTimer struct {
+	*time.Timer
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock    *FakeClock
+	deadline time.Time
+	period   time.Duration
+	channel  chan time.Time
+	callback func()
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New returns clock which uses system time.
+func New() (clock Clock) {
+	return realClock{}
+}
+
+// NewFake returns fake clock initialized with specified time.
+func NewFake(now time.Time) (clock *FakeClock) {
+	return &FakeClock{now: now}
+}
+
+// Now returns current fake time.
+func (clock *FakeClock) Now() (now time.Time) {
+	clock.Lock()
+	defer clock.Unlock()
+
+	return clock.now
+}
+
+// After returns channel which receives fake time when clock is advanced by duration.
+func (clock *FakeClock) After(d time.Duration) (channel <-chan time.Time) {
+	return clock.NewTimer(d).C()
+}
+
+// NewTimer creates fake timer.
+func (clock *FakeClock) NewTimer(d time.Duration) (timer Timer) {
+	return clock.addWaiter(&fakeWaiter{clock: clock, channel: make(chan time.Time, 1)}, d)
+}
+
+// NewTicker creates fake ticker.
+func (clock *FakeClock) NewTicker(d time.Duration) (ticker Ticker) {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	return fakeTicker{clock.addWaiter(&fakeWaiter{clock: clock, channel: make(chan time.Time, 1), period: d}, d)}
+}
+
+// AfterFunc creates fake timer which calls function when clock is advanced by duration.
+// The function is called synchronously from Advance or Set.
+func (clock *FakeClock) AfterFunc(d time.Duration, f func()) (timer Timer) {
+	return clock.addWaiter(&fakeWaiter{clock: clock, callback: f}, d)
+}
+
+// Advance moves fake time forward and fires expired timers and tickers.
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.Set(clock.Now().Add(d))
+}
+
+// Set sets fake time and fires expired timers and tickers.
+func (clock *FakeClock) Set(now time.Time) {
+	clock.Lock()
+
+	clock.now = now
+
+	var (
+		fired     []*fakeWaiter
+		remaining []*fakeWaiter
+	)
+
+	for _, waiter := range clock.waiters {
+		if waiter.deadline.After(now) {
+			remaining = append(remaining, waiter)
+
+			continue
+		}
+
+		fired = append(fired, waiter)
+
+		if waiter.period > 0 {
+			for !waiter.deadline.After(now) {
+				waiter.deadline = waiter.deadline.Add(waiter.period)
+			}
+
+			remaining = append(remaining, waiter)
+		}
+	}
+
+	clock.waiters = remaining
+	clock.sortWaiters()
+
+	clock.Unlock()
+
+	for _, waiter := range fired {
+		waiter.fire(now)
+	}
+}
+
+// WaitersCount returns number of active timers and tickers.
+// It can be used in tests to wait until the code under test starts waiting on the clock.
+func (clock *FakeClock) WaitersCount() (count int) {
+	clock.Lock()
+	defer clock.Unlock()
+
+	return len(clock.waiters)
+}
+
+// BlockUntil blocks until specified number of timers and tickers are active.
+func (clock *FakeClock) BlockUntil(count int) {
+	for clock.WaitersCount() < count {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (realClock) Now() (now time.Time) {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) (channel <-chan time.Time) {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) (timer Timer) {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) (ticker Ticker) {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) (timer Timer) {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (timer realTimer) C() (channel <-chan time.Time) {
+	return timer.Timer.C
+}
+
+func (ticker realTicker) C() (channel <-chan time.Time) {
+	return ticker.Ticker.C
+}
+
+func (clock *FakeClock) addWaiter(waiter *fakeWaiter, d time.Duration) *fakeWaiter {
+	clock.Lock()
+
+	waiter.deadline = clock.now.Add(d)
+
+	if d > 0 {
+		clock.waiters = append(clock.waiters, waiter)
+		clock.sortWaiters()
+	}
+
+	now := clock.now
+
+	clock.Unlock()
+
+	if d <= 0 {
+		waiter.fire(now)
+	}
+
+	return waiter
+}
+
+func (clock *FakeClock) sortWaiters() {
+	sort.SliceStable(clock.waiters, func(i, j int) bool {
+		return clock.waiters[i].deadline.Before(clock.waiters[j].deadline)
+	})
+}
+
+func (clock *FakeClock) removeWaiter(waiter *fakeWaiter) (removed bool) {
+	clock.Lock()
+	defer clock.Unlock()
+
+	for i, item := range clock.waiters {
+		if item == waiter {
+			clock.waiters = append(clock.waiters[:i], clock.waiters[i+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func (waiter *fakeWaiter) fire(now time.Time) {
+	if waiter.callback != nil {
+		waiter.callback()
+
+		return
+	}
+
+	// Drop the tick if receiver is slow, as real ticker does
+	select {
+	case waiter.channel <- now:
+
+	default:
+	}
+}
+
+func (waiter *fakeWaiter) C() (channel <-chan time.Time) {
+	return waiter.channel
+}
+
+func (waiter *fakeWaiter) Stop() (stopped bool) {
+	return waiter.clock.removeWaiter(waiter)
+}
+
+func (waiter *fakeWaiter) Reset(d time.Duration) (active bool) {
+	active = waiter.clock.removeWaiter(waiter)
+
+	waiter.clock.addWaiter(waiter, d)
+
+	return active
+}
+
+func (ticker fakeTicker) Stop() {
+	ticker.fakeWaiter.Stop()
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestFakeTimer(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(startTime)
+
+	afterChannel := fakeClock.After(time.Hour)
+	stoppedTimer := fakeClock.NewTimer(time.Minute)
+	called := false
+
+	fakeClock.AfterFunc(30*time.Minute, func() { called = true })
+
+	if !stoppedTimer.Stop() {
+		t.Error("Timer should be active")
+	}
+
+	fakeClock.Advance(59 * time.Minute)
+
+	if !called {
+		t.Error("Function should be called")
+	}
+
+	select {
+	case <-afterChannel:
+		t.Error("Timer should not be expired")
+
+	default:
+	}
+
+	fakeClock.Advance(time.Minute)
+
+	select {
+	case now := <-afterChannel:
+		if !now.Equal(startTime.Add(time.Hour)) {
+			t.Errorf("Wrong timer time: %v", now)
+		}
+
+	default:
+		t.Error("Timer should be expired")
+	}
+
+	select {
+	case <-stoppedTimer.C():
+		t.Error("Stopped timer should not be expired")
+
+	default:
+	}
+
+	if fakeClock.WaitersCount() != 0 {
+		t.Errorf("Wrong waiters count: %d", fakeClock.WaitersCount())
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	fakeClock := clock.NewFake(time.Now())
+
+	ticker := fakeClock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for i := 0; i < 3; i++ {
+		fakeClock.Advance(time.Second)
+
+		select {
+		case <-ticker.C():
+
+		default:
+			t.Errorf("Tick %d expected", i)
+		}
+	}
+
+	ticker.Stop()
+	fakeClock.Advance(time.Second)
+
+	select {
+	case <-ticker.C():
+		t.Error("Stopped ticker should not tick")
+
+	default:
+	}
+}
@@ -0,0 +1,335 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diagnostics periodically reports Go runtime and process metrics of update manager.
+package diagnostics
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// clockTicks is USER_HZ used by kernel to report process times in /proc.
+const clockTicks = 100
+
+// Offsets of utime, stime, cutime and cstime fields in /proc/self/stat counting from the field after command name.
+const (
+	statUTimeField  = 11
+	statCSTimeField = 14
+)
+
+const ioWriteBytesKey = "write_bytes"
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// FDPath path to process file descriptors dir.
+var FDPath = "/proc/self/fd" //nolint:gochecknoglobals // Used in unit tests to override path
+
+// StatPath path to process status file.
+var StatPath = "/proc/self/stat" //nolint:gochecknoglobals // Used in unit tests to override path
+
+// IOPath path to process I/O accounting file.
+var IOPath = "/proc/self/io" //nolint:gochecknoglobals // Used in unit tests to override path
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// DBStatsProvider provides database connection statistics.
+type DBStatsProvider interface {
+	Stats() (stats sql.DBStats)
+}
+
+// UsageProvider provides resource usage of update modules per update phase.
+type UsageProvider interface {
+	GetResourceUsage() (usage map[string]map[string]PhaseUsage)
+}
+
+// ProcessUsage process CPU time and written bytes including waited children.
+type ProcessUsage struct {
+	CPUTime    time.Duration
+	WriteBytes uint64
+}
+
+// PhaseUsage resource usage of update phase.
+type PhaseUsage struct {
+	WallTime   time.Duration `json:"wallTime"`
+	CPUTime    time.Duration `json:"cpuTime"`
+	WriteBytes uint64        `json:"writeBytes"`
+}
+
+// Metrics runtime and process metrics.
+type Metrics struct {
+	Goroutines        int                              `json:"goroutines"`
+	HeapAlloc         uint64                           `json:"heapAlloc"`
+	HeapInuse         uint64                           `json:"heapInuse"`
+	HeapObjects       uint64                           `json:"heapObjects"`
+	Sys               uint64                           `json:"sys"`
+	NumGC             uint32                           `json:"numGC"`
+	OpenFDs           int                              `json:"openFDs"`
+	DBOpenConnections int                              `json:"dbOpenConnections"`
+	DBInUse           int                              `json:"dbInUse"`
+	DBIdle            int                              `json:"dbIdle"`
+	DBWaitCount       int64                            `json:"dbWaitCount"`
+	DBWaitDuration    time.Duration                    `json:"dbWaitDuration"`
+	ModuleUsage       map[string]map[string]PhaseUsage `json:"moduleUsage,omitempty"`
+}
+
+// Reporter periodically logs metrics.
+type Reporter struct {
+	instanceID string
+	db         DBStatsProvider
+	usage      UsageProvider
+	ticker     clock.Ticker
+	closeChan  chan struct{}
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates metrics reporter of update manager instance. Usage provider is optional.
+func New(
+	interval time.Duration, instanceID string, db DBStatsProvider, usage UsageProvider, clk clock.Clock,
+) (reporter *Reporter) {
+	log.WithField("interval", interval).Debug("Create diagnostics reporter")
+
+	reporter = &Reporter{
+		instanceID: instanceID, db: db, usage: usage, ticker: clk.NewTicker(interval), closeChan: make(chan struct{}),
+	}
+
+	reporter.report()
+
+	go reporter.run()
+
+	return reporter
+}
+
+// Close closes metrics reporter.
+func (reporter *Reporter) Close() {
+	log.Debug("Close diagnostics reporter")
+
+	reporter.ticker.Stop()
+	close(reporter.closeChan)
+}
+
+// Collect collects current metrics. Usage provider is optional.
+func Collect(db DBStatsProvider, usage UsageProvider) (metrics Metrics, err error) {
+	var memStats runtime.MemStats
+
+	runtime.ReadMemStats(&memStats)
+
+	metrics = Metrics{
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   memStats.HeapAlloc,
+		HeapInuse:   memStats.HeapInuse,
+		HeapObjects: memStats.HeapObjects,
+		Sys:         memStats.Sys,
+		NumGC:       memStats.NumGC,
+	}
+
+	if db != nil {
+		dbStats := db.Stats()
+
+		metrics.DBOpenConnections = dbStats.OpenConnections
+		metrics.DBInUse = dbStats.InUse
+		metrics.DBIdle = dbStats.Idle
+		metrics.DBWaitCount = dbStats.WaitCount
+		metrics.DBWaitDuration = dbStats.WaitDuration
+	}
+
+	if usage != nil {
+		metrics.ModuleUsage = usage.GetResourceUsage()
+	}
+
+	fds, err := os.ReadDir(FDPath)
+	if err != nil {
+		return metrics, aoserrors.Wrap(err)
+	}
+
+	metrics.OpenFDs = len(fds)
+
+	return metrics, nil
+}
+
+// ReadProcessUsage reads CPU time and written bytes of update manager process. Usage of external commands is
+// accounted once they are waited.
+func ReadProcessUsage() (usage ProcessUsage, err error) {
+	statData, err := os.ReadFile(StatPath)
+	if err != nil {
+		return usage, aoserrors.Wrap(err)
+	}
+
+	// Command name may contain spaces and brackets, so fields are counted from the last bracket
+	commEnd := bytes.LastIndexByte(statData, ')')
+	if commEnd < 0 {
+		return usage, aoserrors.New("invalid process stat format")
+	}
+
+	fields := strings.Fields(string(statData[commEnd+1:]))
+	if len(fields) <= statCSTimeField {
+		return usage, aoserrors.New("invalid process stat format")
+	}
+
+	var ticks uint64
+
+	for _, field := range fields[statUTimeField : statCSTimeField+1] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return usage, aoserrors.Wrap(err)
+		}
+
+		ticks += value
+	}
+
+	usage.CPUTime = time.Duration(ticks) * time.Second / clockTicks
+
+	if usage.WriteBytes, err = readWriteBytes(); err != nil {
+		return usage, err
+	}
+
+	return usage, nil
+}
+
+// Sub returns usage difference.
+func (usage ProcessUsage) Sub(prev ProcessUsage) (diff ProcessUsage) {
+	diff.CPUTime = usage.CPUTime - prev.CPUTime
+
+	if usage.WriteBytes > prev.WriteBytes {
+		diff.WriteBytes = usage.WriteBytes - prev.WriteBytes
+	}
+
+	return diff
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (reporter *Reporter) run() {
+	for {
+		select {
+		case <-reporter.ticker.C():
+			reporter.report()
+
+		case <-reporter.closeChan:
+			return
+		}
+	}
+}
+
+func (reporter *Reporter) report() {
+	metrics, err := Collect(reporter.db, reporter.usage)
+	if err != nil {
+		log.Errorf("Can't collect diagnostics: %v", err)
+	}
+
+	log.WithFields(log.Fields{
+		"instanceID":        reporter.instanceID,
+		"goroutines":        metrics.Goroutines,
+		"heapAlloc":         metrics.HeapAlloc,
+		"heapInuse":         metrics.HeapInuse,
+		"heapObjects":       metrics.HeapObjects,
+		"sys":               metrics.Sys,
+		"numGC":             metrics.NumGC,
+		"openFDs":           metrics.OpenFDs,
+		"dbOpenConnections": metrics.DBOpenConnections,
+		"dbInUse":           metrics.DBInUse,
+		"dbIdle":            metrics.DBIdle,
+		"dbWaitCount":       metrics.DBWaitCount,
+		"dbWaitDuration":    metrics.DBWaitDuration,
+	}).Info("Diagnostics")
+
+	// Modules are reported starting from the one which dominates update duration
+	for _, id := range sortUsageByWallTime(metrics.ModuleUsage) {
+		for phase, usage := range metrics.ModuleUsage[id] {
+			log.WithFields(log.Fields{
+				"instanceID": reporter.instanceID, "id": id, "phase": phase, "wallTime": usage.WallTime, "cpuTime": usage.CPUTime,
+				"writeBytes": usage.WriteBytes,
+			}).Info("Module resource usage")
+		}
+	}
+}
+
+func sortUsageByWallTime(moduleUsage map[string]map[string]PhaseUsage) (ids []string) {
+	wallTimes := make(map[string]time.Duration)
+
+	for id, phases := range moduleUsage {
+		for _, usage := range phases {
+			wallTimes[id] += usage.WallTime
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		if wallTimes[ids[i]] != wallTimes[ids[j]] {
+			return wallTimes[ids[i]] > wallTimes[ids[j]]
+		}
+
+		return ids[i] < ids[j]
+	})
+
+	return ids
+}
+
+func readWriteBytes() (writeBytes uint64, err error) {
+	file, err := os.Open(IOPath)
+	if err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || key != ioWriteBytesKey {
+			continue
+		}
+
+		if writeBytes, err = strconv.ParseUint(strings.TrimSpace(value), 10, 64); err != nil {
+			return 0, aoserrors.Wrap(err)
+		}
+
+		return writeBytes, nil
+	}
+
+	if err = scanner.Err(); err != nil {
+		return 0, aoserrors.Wrap(err)
+	}
+
+	return 0, aoserrors.Errorf("%s not found in process I/O accounting", ioWriteBytesKey)
+}
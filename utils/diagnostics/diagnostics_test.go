@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+	"github.com/aoscloud/aos_updatemanager/utils/diagnostics"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testUsage struct {
+	usage map[string]map[string]diagnostics.PhaseUsage
+}
+
+type testDB struct {
+	stats     sql.DBStats
+	statsChan chan struct{}
+}
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestCollect(t *testing.T) {
+	db := &testDB{stats: sql.DBStats{OpenConnections: 2, InUse: 1, Idle: 1, WaitCount: 3}}
+
+	metrics, err := diagnostics.Collect(db, nil)
+	if err != nil {
+		t.Fatalf("Can't collect metrics: %v", err)
+	}
+
+	if metrics.Goroutines == 0 {
+		t.Error("Goroutines count should not be zero")
+	}
+
+	if metrics.HeapAlloc == 0 || metrics.Sys == 0 {
+		t.Error("Memory stats should not be zero")
+	}
+
+	if metrics.OpenFDs == 0 {
+		t.Error("Open FDs count should not be zero")
+	}
+
+	file, err := os.Open(os.Args[0])
+	if err != nil {
+		t.Fatalf("Can't open file: %v", err)
+	}
+	defer file.Close()
+
+	newMetrics, err := diagnostics.Collect(db, nil)
+	if err != nil {
+		t.Fatalf("Can't collect metrics: %v", err)
+	}
+
+	if newMetrics.OpenFDs != metrics.OpenFDs+1 {
+		t.Errorf("Wrong open FDs count: %d", newMetrics.OpenFDs)
+	}
+
+	if metrics.DBOpenConnections != 2 || metrics.DBInUse != 1 || metrics.DBIdle != 1 || metrics.DBWaitCount != 3 {
+		t.Errorf("Wrong DB metrics: %+v", metrics)
+	}
+}
+
+func TestModuleUsage(t *testing.T) {
+	usage := &testUsage{usage: map[string]map[string]diagnostics.PhaseUsage{
+		"rootfs": {"update": {WallTime: time.Minute, CPUTime: time.Second, WriteBytes: 1024}},
+	}}
+
+	metrics, err := diagnostics.Collect(nil, usage)
+	if err != nil {
+		t.Fatalf("Can't collect metrics: %v", err)
+	}
+
+	if !reflect.DeepEqual(metrics.ModuleUsage, usage.usage) {
+		t.Errorf("Wrong module usage: %v", metrics.ModuleUsage)
+	}
+}
+
+func TestReadProcessUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	statPath, ioPath := diagnostics.StatPath, diagnostics.IOPath
+
+	defer func() {
+		diagnostics.StatPath, diagnostics.IOPath = statPath, ioPath
+	}()
+
+	diagnostics.StatPath = filepath.Join(tmpDir, "stat")
+	diagnostics.IOPath = filepath.Join(tmpDir, "io")
+
+	if err := os.WriteFile(diagnostics.StatPath,
+		[]byte("42 (um (test) 1) S 1 42 42 0 -1 4194560 100 0 0 0 150 50 70 30 20 0 8 0 100 0 0"), 0o600); err != nil {
+		t.Fatalf("Can't write stat file: %v", err)
+	}
+
+	if err := os.WriteFile(diagnostics.IOPath,
+		[]byte("rchar: 100\nwchar: 200\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n"),
+		0o600); err != nil {
+		t.Fatalf("Can't write io file: %v", err)
+	}
+
+	usage, err := diagnostics.ReadProcessUsage()
+	if err != nil {
+		t.Fatalf("Can't read process usage: %v", err)
+	}
+
+	if usage.CPUTime != 3*time.Second {
+		t.Errorf("Wrong CPU time: %v", usage.CPUTime)
+	}
+
+	if usage.WriteBytes != 8192 {
+		t.Errorf("Wrong write bytes: %d", usage.WriteBytes)
+	}
+
+	diff := usage.Sub(diagnostics.ProcessUsage{CPUTime: time.Second, WriteBytes: 4096})
+
+	if diff.CPUTime != 2*time.Second || diff.WriteBytes != 4096 {
+		t.Errorf("Wrong usage difference: %+v", diff)
+	}
+
+	if err := os.WriteFile(diagnostics.IOPath, []byte("rchar: 100\n"), 0o600); err != nil {
+		t.Fatalf("Can't write io file: %v", err)
+	}
+
+	if _, err := diagnostics.ReadProcessUsage(); err == nil {
+		t.Error("Error expected for missing write bytes")
+	}
+}
+
+func TestReporter(t *testing.T) {
+	fakeClock := clock.NewFake(time.Now())
+	db := &testDB{statsChan: make(chan struct{}, 1)}
+
+	reporter := diagnostics.New(time.Hour, "instance1", db, nil, fakeClock)
+	defer reporter.Close()
+
+	// Metrics are reported on start and on each interval
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-db.statsChan:
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait metrics report timeout")
+		}
+
+		fakeClock.Advance(time.Hour)
+	}
+}
+
+/***********************************************************************************************************************
+ * Interfaces
+ **********************************************************************************************************************/
+
+func (usage *testUsage) GetResourceUsage() (result map[string]map[string]diagnostics.PhaseUsage) {
+	return usage.usage
+}
+
+func (db *testDB) Stats() (stats sql.DBStats) {
+	if db.statsChan != nil {
+		db.statsChan <- struct{}{}
+	}
+
+	return db.stats
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instanceid provides persistent update manager instance ID.
+package instanceid
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Node ID reported by update manager identifies the node, but a node may run several update managers, and logs and
+// history records may be replayed elsewhere. Instance ID identifies update manager instance: it is configured
+// explicitly or generated on the first start and persisted in storage, so it is stable across restarts and updates.
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Storage instance ID storage interface.
+type Storage interface {
+	GetInstanceID() (id string, err error)
+	SetInstanceID(id string) (err error)
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// Get returns configured instance ID or persisted one. If the ID is not persisted yet, it is generated and stored.
+// If storage doesn't implement instance ID storage, generated ID is not persisted.
+func Get(configID string, storage interface{}) (id string, err error) {
+	if configID != "" {
+		return configID, nil
+	}
+
+	idStorage, ok := storage.(Storage)
+	if !ok {
+		log.Warn("Instance ID storage is not available, generated instance ID is not persisted")
+
+		return generate()
+	}
+
+	if id, err = idStorage.GetInstanceID(); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if id != "" {
+		return id, nil
+	}
+
+	if id, err = generate(); err != nil {
+		return "", err
+	}
+
+	log.WithField("instanceID", id).Info("Generate instance ID")
+
+	if err = idStorage.SetInstanceID(id); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return id, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+// generate generates random UUID version 4.
+func generate() (id string, err error) {
+	var uuid [16]byte
+
+	if _, err = rand.Read(uuid[:]); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instanceid_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/utils/instanceid"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testStorage struct {
+	id string
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestInstanceID(t *testing.T) {
+	uuidRegexp := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	storage := &testStorage{}
+
+	id, err := instanceid.Get("", storage)
+	if err != nil {
+		t.Fatalf("Can't get instance ID: %s", err)
+	}
+
+	if !uuidRegexp.MatchString(id) || storage.id != id {
+		t.Errorf("Wrong generated instance ID: %s, stored: %s", id, storage.id)
+	}
+
+	persistedID, err := instanceid.Get("", storage)
+	if err != nil {
+		t.Fatalf("Can't get instance ID: %s", err)
+	}
+
+	if persistedID != id {
+		t.Errorf("Wrong persisted instance ID: %s", persistedID)
+	}
+
+	if id, err = instanceid.Get("um1", storage); err != nil || id != "um1" {
+		t.Errorf("Wrong configured instance ID: %s, err: %v", id, err)
+	}
+
+	if id, err = instanceid.Get("", nil); err != nil || !uuidRegexp.MatchString(id) {
+		t.Errorf("Wrong not persisted instance ID: %s, err: %v", id, err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (storage *testStorage) GetInstanceID() (id string, err error) {
+	return storage.id, nil
+}
+
+func (storage *testStorage) SetInstanceID(id string) (err error) {
+	storage.id = id
+
+	return nil
+}
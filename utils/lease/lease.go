@@ -0,0 +1,407 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lease provides file lease on shared storage which allows running warm standby update manager instance.
+package lease
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+)
+
+// Lease holder renews lease file every third of TTL by increasing its generation. Other instance treats the lease
+// as expired if the lease file is not changed during TTL measured by its own clock, so clocks of instances don't
+// have to be synchronized. Takeover is confirmed by re-reading the lease after poll interval: if two standby
+// instances take over simultaneously, only the last writer keeps the lease. Holder which finds the lease taken by
+// other instance or fails to renew it during TTL reports the lease as lost and should stop immediately. As renewal
+// detects the loss only on the next tick, holder checks the lease right before actions which are not allowed to be done
+// by both instances: the check re-reads the lease and requires the last renewal to be recent enough so the standby
+// can't consider the lease expired yet, e.g. when the holder was paused for longer than TTL.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const renewDivider = 3
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var errLeaseTaken = errors.New("lease is taken by other instance")
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Lease file lease.
+type Lease struct {
+	sync.Mutex
+
+	file       string
+	owner      string
+	ttl        time.Duration
+	clock      clock.Clock
+	generation uint64
+	lostChan   chan struct{}
+	closeChan  chan struct{}
+	wg         sync.WaitGroup
+	held       bool
+	lastRenew  time.Time
+	lost       bool
+}
+
+type leaseInfo struct {
+	Owner      string `json:"owner"`
+	Generation uint64 `json:"generation"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates lease of lease file for owner.
+func New(file, owner string, ttl time.Duration, clk clock.Clock) (lease *Lease, err error) {
+	if file == "" || owner == "" {
+		return nil, aoserrors.New("lease file and owner should be set")
+	}
+
+	if ttl <= 0 {
+		return nil, aoserrors.Errorf("wrong lease TTL: %v", ttl)
+	}
+
+	return &Lease{
+		file: file, owner: owner, ttl: ttl, clock: clk,
+		lostChan: make(chan struct{}), closeChan: make(chan struct{}),
+	}, nil
+}
+
+// Acquire waits until the lease is free or expired and takes it over. Once acquired, the lease is renewed in
+// background till release.
+func (lease *Lease) Acquire(ctx context.Context) (err error) {
+	var (
+		lastInfo   []byte
+		lastChange = lease.clock.Now()
+	)
+
+	log.WithFields(log.Fields{"file": lease.file, "owner": lease.owner}).Info("Wait for lease")
+
+	for {
+		data, info, err := lease.read()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case data == nil || info.Owner == lease.owner:
+			acquired, err := lease.takeOver(ctx, info.Generation)
+			if err != nil || acquired {
+				return err
+			}
+
+		case !bytes.Equal(data, lastInfo):
+			log.WithFields(log.Fields{"owner": info.Owner, "generation": info.Generation}).Debug("Lease is held")
+
+			lastInfo, lastChange = data, lease.clock.Now()
+
+		case lease.clock.Now().Sub(lastChange) >= lease.ttl:
+			log.WithField("owner", info.Owner).Warn("Lease expired")
+
+			acquired, err := lease.takeOver(ctx, info.Generation)
+			if err != nil || acquired {
+				return err
+			}
+		}
+
+		if err = lease.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// Lost returns channel which is closed when acquired lease is lost.
+func (lease *Lease) Lost() (channel <-chan struct{}) {
+	return lease.lostChan
+}
+
+// Check checks that acquired lease is still held by the instance.
+func (lease *Lease) Check() (err error) {
+	lease.Lock()
+	defer lease.Unlock()
+
+	if !lease.held {
+		return aoserrors.New("lease is not held")
+	}
+
+	if lease.lost {
+		return aoserrors.New("lease is lost")
+	}
+
+	// Lease should be renewed at least once more before the standby may treat it as expired
+	if sinceRenew := lease.clock.Now().Sub(lease.lastRenew); sinceRenew >= lease.ttl-lease.ttl/renewDivider {
+		lease.setLost()
+
+		return aoserrors.Errorf("lease is not renewed for %v", sinceRenew)
+	}
+
+	_, info, err := lease.read()
+	if err != nil {
+		return err
+	}
+
+	if info.Owner != lease.owner || info.Generation != lease.generation {
+		lease.setLost()
+
+		return aoserrors.Wrap(errLeaseTaken)
+	}
+
+	return nil
+}
+
+// Release stops lease renewal and removes lease file if the lease is held.
+func (lease *Lease) Release() (err error) {
+	lease.Lock()
+
+	held := lease.held
+	lease.held = false
+
+	lease.Unlock()
+
+	if !held {
+		return nil
+	}
+
+	close(lease.closeChan)
+	lease.wg.Wait()
+
+	log.WithField("file", lease.file).Info("Release lease")
+
+	_, info, err := lease.read()
+	if err != nil {
+		return err
+	}
+
+	if info.Owner != lease.owner {
+		return nil
+	}
+
+	if err = os.Remove(lease.file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return aoserrors.Wrap(err)
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (lease *Lease) takeOver(ctx context.Context, generation uint64) (acquired bool, err error) {
+	lease.generation = generation + 1
+	writeTime := lease.clock.Now()
+
+	if err = lease.write(); err != nil {
+		return false, err
+	}
+
+	// Let concurrent standby instance overwrite the lease
+	if err = lease.wait(ctx); err != nil {
+		return false, err
+	}
+
+	_, info, err := lease.read()
+	if err != nil {
+		return false, err
+	}
+
+	if info.Owner != lease.owner || info.Generation != lease.generation {
+		log.WithField("owner", info.Owner).Debug("Lease is taken by other instance")
+
+		return false, nil
+	}
+
+	log.WithFields(log.Fields{"file": lease.file, "generation": lease.generation}).Info("Lease acquired")
+
+	lease.Lock()
+	lease.held = true
+	lease.lastRenew = writeTime
+	lease.Unlock()
+
+	lease.wg.Add(1)
+
+	go lease.renew()
+
+	return true, nil
+}
+
+func (lease *Lease) renew() {
+	defer lease.wg.Done()
+
+	ticker := lease.clock.NewTicker(lease.ttl / renewDivider)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lease.closeChan:
+			return
+
+		case <-ticker.C():
+			if err := lease.renewOnce(); err != nil {
+				log.Errorf("Can't renew lease: %v", err)
+			}
+
+			if lease.isLost() {
+				return
+			}
+		}
+	}
+}
+
+func (lease *Lease) renewOnce() (err error) {
+	lease.Lock()
+	defer lease.Unlock()
+
+	if lease.lost {
+		return nil
+	}
+
+	_, info, err := lease.read()
+	if err != nil {
+		return err
+	}
+
+	if info.Owner != lease.owner || info.Generation != lease.generation {
+		lease.setLost()
+
+		return errLeaseTaken
+	}
+
+	writeTime := lease.clock.Now()
+
+	lease.generation++
+
+	if err = lease.write(); err != nil {
+		lease.generation--
+
+		return err
+	}
+
+	lease.lastRenew = writeTime
+
+	return nil
+}
+
+// isLost returns true if the lease is lost, the lease is lost if it is not renewed during TTL.
+func (lease *Lease) isLost() (lost bool) {
+	lease.Lock()
+	defer lease.Unlock()
+
+	if !lease.lost && lease.clock.Now().Sub(lease.lastRenew) >= lease.ttl {
+		lease.setLost()
+	}
+
+	return lease.lost
+}
+
+// setLost should be called with lease lock.
+func (lease *Lease) setLost() {
+	if lease.lost {
+		return
+	}
+
+	log.Error("Lease lost")
+
+	lease.lost = true
+
+	close(lease.lostChan)
+}
+
+func (lease *Lease) wait(ctx context.Context) (err error) {
+	select {
+	case <-ctx.Done():
+		return aoserrors.Wrap(ctx.Err())
+
+	case <-lease.clock.After(lease.ttl / renewDivider):
+		return nil
+	}
+}
+
+func (lease *Lease) read() (data []byte, info leaseInfo, err error) {
+	if data, err = os.ReadFile(lease.file); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, info, nil
+		}
+
+		return nil, info, aoserrors.Wrap(err)
+	}
+
+	// Partially written lease is treated as changed lease held by unknown owner
+	if err = json.Unmarshal(data, &info); err != nil {
+		log.WithField("file", lease.file).Warnf("Can't parse lease: %v", err)
+	}
+
+	return data, info, nil
+}
+
+// write replaces lease file atomically.
+func (lease *Lease) write() (err error) {
+	data, err := json.Marshal(leaseInfo{Owner: lease.owner, Generation: lease.generation})
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(lease.file), filepath.Base(lease.file)+".*")
+	if err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(file.Name())
+		}
+	}()
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+
+		return aoserrors.Wrap(err)
+	}
+
+	if err = file.Sync(); err != nil {
+		file.Close()
+
+		return aoserrors.Wrap(err)
+	}
+
+	if err = file.Close(); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	return aoserrors.Wrap(os.Rename(file.Name(), lease.file))
+}
@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lease_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/clock"
+	"github.com/aoscloud/aos_updatemanager/utils/lease"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+const leaseTTL = 150 * time.Millisecond
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestStandbyTakeOver(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "updatemanager.lease")
+
+	primary := newLease(t, leaseFile, "primary")
+
+	if err := primary.Acquire(context.Background()); err != nil {
+		t.Fatalf("Can't acquire lease: %v", err)
+	}
+
+	if owner := getOwner(t, leaseFile); owner != "primary" {
+		t.Errorf("Wrong lease owner: %s", owner)
+	}
+
+	standby := newLease(t, leaseFile, "standby")
+
+	// Lease is held while primary renews it
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*leaseTTL)
+	defer cancel()
+
+	if err := standby.Acquire(ctx); err == nil {
+		t.Fatal("Lease should not be acquired")
+	}
+
+	// Released lease is acquired immediately
+
+	if err := primary.Release(); err != nil {
+		t.Fatalf("Can't release lease: %v", err)
+	}
+
+	startTime := time.Now()
+
+	if err := standby.Acquire(context.Background()); err != nil {
+		t.Fatalf("Can't acquire lease: %v", err)
+	}
+
+	if time.Since(startTime) >= leaseTTL {
+		t.Error("Released lease should be acquired before TTL")
+	}
+
+	if owner := getOwner(t, leaseFile); owner != "standby" {
+		t.Errorf("Wrong lease owner: %s", owner)
+	}
+
+	if err := standby.Release(); err != nil {
+		t.Fatalf("Can't release lease: %v", err)
+	}
+
+	if _, err := os.Stat(leaseFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Lease file should be removed: %v", err)
+	}
+}
+
+func TestExpiredLease(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "updatemanager.lease")
+
+	// Primary died without lease release
+	if err := os.WriteFile(leaseFile, []byte(`{"owner":"primary","generation":10}`), 0o600); err != nil {
+		t.Fatalf("Can't write lease: %v", err)
+	}
+
+	standby := newLease(t, leaseFile, "standby")
+	defer standby.Release()
+
+	startTime := time.Now()
+
+	if err := standby.Acquire(context.Background()); err != nil {
+		t.Fatalf("Can't acquire lease: %v", err)
+	}
+
+	if time.Since(startTime) < leaseTTL {
+		t.Error("Lease should be acquired after TTL")
+	}
+
+	if owner := getOwner(t, leaseFile); owner != "standby" {
+		t.Errorf("Wrong lease owner: %s", owner)
+	}
+}
+
+func TestLostLease(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "updatemanager.lease")
+
+	primary := newLease(t, leaseFile, "primary")
+	defer primary.Release()
+
+	if err := primary.Acquire(context.Background()); err != nil {
+		t.Fatalf("Can't acquire lease: %v", err)
+	}
+
+	// Other instance takes over the lease, e.g. after the primary was frozen
+	if err := os.WriteFile(leaseFile, []byte(`{"owner":"standby","generation":100}`), 0o600); err != nil {
+		t.Fatalf("Can't write lease: %v", err)
+	}
+
+	select {
+	case <-primary.Lost():
+
+	case <-time.After(2 * leaseTTL):
+		t.Fatal("Lease should be lost")
+	}
+
+	if owner := getOwner(t, leaseFile); owner != "standby" {
+		t.Errorf("Wrong lease owner: %s", owner)
+	}
+}
+
+func TestCheckLease(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "updatemanager.lease")
+
+	primary := newLease(t, leaseFile, "primary")
+	defer primary.Release()
+
+	if err := primary.Check(); err == nil {
+		t.Error("Error expected if lease is not acquired")
+	}
+
+	if err := primary.Acquire(context.Background()); err != nil {
+		t.Fatalf("Can't acquire lease: %v", err)
+	}
+
+	if err := primary.Check(); err != nil {
+		t.Errorf("Lease check failed: %v", err)
+	}
+
+	// Takeover is detected by the check before the next renewal
+	if err := os.WriteFile(leaseFile, []byte(`{"owner":"standby","generation":100}`), 0o600); err != nil {
+		t.Fatalf("Can't write lease: %v", err)
+	}
+
+	if err := primary.Check(); err == nil {
+		t.Error("Error expected for lease taken by other instance")
+	}
+
+	select {
+	case <-primary.Lost():
+
+	default:
+		t.Error("Lease should be lost")
+	}
+
+	if err := os.WriteFile(leaseFile, []byte(`{"owner":"primary","generation":100}`), 0o600); err != nil {
+		t.Fatalf("Can't write lease: %v", err)
+	}
+
+	if err := primary.Check(); err == nil {
+		t.Error("Error expected for lost lease")
+	}
+}
+
+func TestCheckNotRenewedLease(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "updatemanager.lease")
+	fakeClock := clock.NewFake(time.Now())
+
+	primary, err := lease.New(leaseFile, "primary", leaseTTL, fakeClock)
+	if err != nil {
+		t.Fatalf("Can't create lease: %v", err)
+	}
+
+	defer primary.Release()
+
+	acquireChannel := make(chan error, 1)
+
+	go func() {
+		acquireChannel <- primary.Acquire(context.Background())
+	}()
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(leaseTTL / 3)
+
+	if err = <-acquireChannel; err != nil {
+		t.Fatalf("Can't acquire lease: %v", err)
+	}
+
+	if err = primary.Check(); err != nil {
+		t.Errorf("Lease check failed: %v", err)
+	}
+
+	// Lease can't be renewed, so the check fails before the standby may treat it as expired
+
+	if err = os.Remove(leaseFile); err != nil {
+		t.Fatalf("Can't remove lease: %v", err)
+	}
+
+	if err = os.Mkdir(leaseFile, 0o700); err != nil {
+		t.Fatalf("Can't create lease dir: %v", err)
+	}
+
+	fakeClock.Advance(leaseTTL / 3)
+
+	if err = primary.Check(); err == nil || !strings.Contains(err.Error(), "lease is not renewed") {
+		t.Errorf("Wrong not renewed lease error: %v", err)
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func newLease(t *testing.T, leaseFile, owner string) (instance *lease.Lease) {
+	t.Helper()
+
+	instance, err := lease.New(leaseFile, owner, leaseTTL, clock.New())
+	if err != nil {
+		t.Fatalf("Can't create lease: %v", err)
+	}
+
+	return instance
+}
+
+func getOwner(t *testing.T, leaseFile string) (owner string) {
+	t.Helper()
+
+	data, err := os.ReadFile(leaseFile)
+	if err != nil {
+		t.Fatalf("Can't read lease: %v", err)
+	}
+
+	var info struct {
+		Owner string `json:"owner"`
+	}
+
+	if err = json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("Can't parse lease: %v", err)
+	}
+
+	return info.Owner
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mountns runs functions in private mount namespace.
+package mountns
+
+import (
+	"runtime"
+	"syscall"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// Run runs function in private mount namespace. Mounts done by the function are not propagated to the global
+// namespace and are released together with the namespace when the function returns. The function should not
+// access mounts from other goroutines as the namespace belongs to the calling OS thread only.
+func Run(fn func() error) (err error) {
+	errChannel := make(chan error, 1)
+
+	go func() {
+		// The thread is not unlocked: it is terminated on goroutine exit and never reused in global namespace.
+		runtime.LockOSThread()
+
+		errChannel <- runInNamespace(fn)
+	}()
+
+	return <-errChannel
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func runInNamespace(fn func() error) (err error) {
+	if err = syscall.Unshare(syscall.CLONE_NEWNS); err != nil {
+		return aoserrors.Errorf("can't create mount namespace: %v", err)
+	}
+
+	if err = syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return aoserrors.Errorf("can't make mounts private: %v", err)
+	}
+
+	return fn()
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mountns_test
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	"github.com/aoscloud/aos_common/utils/fs"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/mountns"
+)
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestRun(t *testing.T) {
+	mountDir := filepath.Join(t.TempDir(), "mount")
+
+	if err := mountns.Run(func() (err error) {
+		if err = fs.Mount("tmpfs", mountDir, "tmpfs", 0, ""); err != nil {
+			return aoserrors.Wrap(err)
+		}
+
+		mounted, err := isMounted(mountDir)
+		if err != nil {
+			return err
+		}
+
+		if !mounted {
+			return aoserrors.New("dir is not mounted in private namespace")
+		}
+
+		return aoserrors.Wrap(os.WriteFile(filepath.Join(mountDir, "file"), []byte("data"), 0o600))
+	}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Mount is not visible in global namespace
+
+	mounted, err := isMounted(mountDir)
+	if err != nil {
+		t.Fatalf("Can't check mount: %v", err)
+	}
+
+	if mounted {
+		t.Error("Mount leaked to global namespace")
+	}
+
+	if _, err = os.Stat(filepath.Join(mountDir, "file")); !os.IsNotExist(err) {
+		t.Errorf("File should not exist in global namespace: %v", err)
+	}
+
+	// Function error is returned
+
+	if err = mountns.Run(func() error { return aoserrors.New("test error") }); err == nil {
+		t.Error("Error expected")
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func isMounted(dir string) (mounted bool, err error) {
+	// /proc/thread-self reflects namespace of the calling thread
+	file, err := os.Open("/proc/thread-self/mounts")
+	if err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 1 && fields[1] == dir {
+			return true, nil
+		}
+	}
+
+	return false, aoserrors.Wrap(scanner.Err())
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package opjournal journals external commands and file mutations performed by update modules.
+package opjournal
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Journal entry types.
+const (
+	EntryCommand = "command"
+	EntryFile    = "file"
+	EntryUsage   = "usage"
+)
+
+// File operations.
+const (
+	FileWrite  = "write"
+	FileRemove = "remove"
+	FileRename = "rename"
+	FileMount  = "mount"
+	FileCopy   = "copy"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Entry journal entry.
+type Entry struct {
+	Timestamp  time.Time     `json:"timestamp"`
+	Type       string        `json:"type"`
+	Command    string        `json:"command,omitempty"`
+	ExitCode   int           `json:"exitCode"`
+	Operation  string        `json:"operation,omitempty"`
+	Files      []string      `json:"files,omitempty"`
+	Phase      string        `json:"phase,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	CPUTime    time.Duration `json:"cpuTime,omitempty"`
+	WriteBytes uint64        `json:"writeBytes,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Storage journal storage interface.
+type Storage interface {
+	AddJournalEntry(id string, entry Entry) (err error)
+	GetJournalEntries(id string) (entries []Entry, err error)
+}
+
+// Journal component operation journal.
+type Journal struct {
+	id      string
+	storage Storage
+}
+
+type exitCoder interface {
+	ExitCode() int
+}
+
+type exitStatuser interface {
+	ExitStatus() int
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates journal for component. If storage doesn't implement journal storage, entries are only logged.
+func New(id string, storage interface{}) (journal *Journal) {
+	journal = &Journal{id: id}
+
+	journal.storage, _ = storage.(Storage)
+
+	return journal
+}
+
+// Command journals external command execution started at startTime. Exit code is taken from error.
+func (journal *Journal) Command(command string, startTime time.Time, err error) {
+	journal.add(Entry{
+		Timestamp: startTime,
+		Type:      EntryCommand,
+		Command:   command,
+		ExitCode:  exitCode(err),
+		Duration:  time.Since(startTime),
+		Error:     errorString(err),
+	})
+}
+
+// File journals file mutation started at startTime.
+func (journal *Journal) File(operation string, startTime time.Time, err error, files ...string) {
+	journal.add(Entry{
+		Timestamp: startTime,
+		Type:      EntryFile,
+		Operation: operation,
+		Files:     files,
+		Duration:  time.Since(startTime),
+		Error:     errorString(err),
+	})
+}
+
+// Usage journals resource usage of update phase started at startTime.
+func (journal *Journal) Usage(
+	phase string, startTime time.Time, duration, cpuTime time.Duration, writeBytes uint64, err error,
+) {
+	journal.add(Entry{
+		Timestamp:  startTime,
+		Type:       EntryUsage,
+		Phase:      phase,
+		Duration:   duration,
+		CPUTime:    cpuTime,
+		WriteBytes: writeBytes,
+		Error:      errorString(err),
+	})
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (journal *Journal) add(entry Entry) {
+	if journal == nil {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"id": journal.id, "type": entry.Type, "command": entry.Command, "exitCode": entry.ExitCode,
+		"operation": entry.Operation, "files": entry.Files, "phase": entry.Phase, "duration": entry.Duration,
+		"cpuTime": entry.CPUTime, "writeBytes": entry.WriteBytes, "error": entry.Error,
+	}).Debug("Journal module operation")
+
+	if journal.storage == nil {
+		return
+	}
+
+	if err := journal.storage.AddJournalEntry(journal.id, entry); err != nil {
+		log.Errorf("Can't add journal entry: %v", err)
+	}
+}
+
+func exitCode(err error) (code int) {
+	if err == nil {
+		return 0
+	}
+
+	var (
+		coder    exitCoder
+		statuser exitStatuser
+	)
+
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+
+	if errors.As(err, &statuser) {
+		return statuser.ExitStatus()
+	}
+
+	return -1
+}
+
+func errorString(err error) (str string) {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opjournal_test
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+
+	"github.com/aoscloud/aos_updatemanager/utils/opjournal"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type testStorage struct {
+	entries map[string][]opjournal.Entry
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestJournal(t *testing.T) {
+	storage := &testStorage{entries: make(map[string][]opjournal.Entry)}
+	journal := opjournal.New("id1", storage)
+
+	startTime := time.Now()
+
+	journal.Command("sh -c exit 3", startTime, aoserrors.Wrap(exec.Command("sh", "-c", "exit 3").Run()))
+	journal.Command("true", startTime, nil)
+	journal.File(opjournal.FileRemove, startTime, os.ErrNotExist, "/file1", "/file2")
+	journal.Usage("update", startTime, time.Minute, time.Second, 1024, nil)
+
+	entries := storage.entries["id1"]
+
+	if len(entries) != 4 {
+		t.Fatalf("Wrong entries count: %d", len(entries))
+	}
+
+	if entries[0].Type != opjournal.EntryCommand || entries[0].ExitCode != 3 || entries[0].Error == "" {
+		t.Errorf("Wrong command entry: %v", entries[0])
+	}
+
+	if entries[1].ExitCode != 0 || entries[1].Error != "" {
+		t.Errorf("Wrong command entry: %v", entries[1])
+	}
+
+	if entries[2].Type != opjournal.EntryFile || entries[2].Operation != opjournal.FileRemove ||
+		!reflect.DeepEqual(entries[2].Files, []string{"/file1", "/file2"}) || entries[2].Error == "" {
+		t.Errorf("Wrong file entry: %v", entries[2])
+	}
+
+	if entries[3].Type != opjournal.EntryUsage || entries[3].Phase != "update" || entries[3].Duration != time.Minute ||
+		entries[3].CPUTime != time.Second || entries[3].WriteBytes != 1024 || entries[3].Error != "" {
+		t.Errorf("Wrong usage entry: %v", entries[3])
+	}
+
+	// Journal without storage should only log entries
+	opjournal.New("id2", nil).Command("true", startTime, nil)
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (storage *testStorage) AddJournalEntry(id string, entry opjournal.Entry) (err error) {
+	storage.entries[id] = append(storage.entries[id], entry)
+
+	return nil
+}
+
+func (storage *testStorage) GetJournalEntries(id string) (entries []opjournal.Entry, err error) {
+	return storage.entries[id], nil
+}
@@ -0,0 +1,495 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pkcs7 verifies detached PKCS #7 (CMS) signed data signatures.
+package pkcs7
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"hash"
+	"io"
+	"math/big"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+)
+
+// Only detached signed data with signed attributes is supported: content is streamed to calculate its digest which
+// is then checked against message digest attribute, the signature is made over the signed attributes. Signer
+// certificate is looked up in the signed data certificates by issuer and serial number or subject key identifier and
+// should chain to the trusted roots, other certificates of the signed data are used as intermediates. Signature is
+// valid if at least one signer is verified.
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+//nolint:gochecknoglobals
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSA             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidRSAWithSHA256   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidRSAWithSHA384   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidRSAWithSHA512   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECDSA           = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// Verify verifies DER or PEM encoded detached signature of content against trusted roots and returns verified signer
+// certificate.
+func Verify(signature []byte, content io.Reader, roots *x509.CertPool) (signer *x509.Certificate, err error) {
+	data, err := parseSignedData(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data.SignerInfos) == 0 {
+		return nil, aoserrors.New("no signers")
+	}
+
+	certs, err := parseCertificates(data.Certificates)
+	if err != nil {
+		return nil, err
+	}
+
+	digests, err := calculateDigests(data.SignerInfos, content)
+	if err != nil {
+		return nil, err
+	}
+
+	intermediates := x509.NewCertPool()
+
+	for _, cert := range certs {
+		intermediates.AddCert(cert)
+	}
+
+	for i := range data.SignerInfos {
+		if signer, err = verifySigner(&data.SignerInfos[i], certs, digests, roots, intermediates); err == nil {
+			return signer, nil
+		}
+	}
+
+	return nil, err
+}
+
+// Sign creates DER encoded detached signature of content with SHA-256 digest. It is intended for tooling and tests.
+func Sign(content io.Reader, cert *x509.Certificate, key crypto.Signer) (signature []byte, err error) {
+	digest := crypto.SHA256.New()
+
+	if _, err = io.Copy(digest, content); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	attrs, err := marshalAttributes(digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		signatureAlgorithm asn1.ObjectIdentifier
+		opts               crypto.SignerOpts = crypto.SHA256
+	)
+
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		signatureAlgorithm = oidRSA
+
+	case *ecdsa.PublicKey:
+		signatureAlgorithm = oidECDSAWithSHA256
+
+	case ed25519.PublicKey:
+		signatureAlgorithm, opts = oidEd25519, crypto.Hash(0)
+
+	default:
+		return nil, aoserrors.Errorf("unsupported key type %T", key.Public())
+	}
+
+	signed := attrs
+
+	if opts.HashFunc() != 0 {
+		signed = sha256Sum(attrs)
+	}
+
+	value, err := key.Sign(rand.Reader, signed, opts)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber})
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	// Signed attributes are encoded as SET for signing and with implicit [0] tag in signer info
+	attrs[0] = 0xa0
+
+	data, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert.Raw,
+		},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{FullBytes: attrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: signatureAlgorithm},
+			Signature:          value,
+		}},
+	})
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if signature, err = asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: data},
+	}); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return signature, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func parseSignedData(signature []byte) (data *signedData, err error) {
+	if block, _ := pem.Decode(signature); block != nil {
+		signature = block.Bytes
+	}
+
+	var info contentInfo
+
+	if _, err = asn1.Unmarshal(signature, &info); err != nil {
+		return nil, aoserrors.Errorf("invalid signature: %v", err)
+	}
+
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, aoserrors.Errorf("unsupported content type %s", info.ContentType)
+	}
+
+	data = &signedData{}
+
+	if _, err = asn1.Unmarshal(info.Content.Bytes, data); err != nil {
+		return nil, aoserrors.Errorf("invalid signed data: %v", err)
+	}
+
+	if len(data.ContentInfo.Content.Bytes) != 0 {
+		return nil, aoserrors.New("signature is not detached")
+	}
+
+	return data, nil
+}
+
+func parseCertificates(raw asn1.RawValue) (certs []*x509.Certificate, err error) {
+	if len(raw.Bytes) == 0 {
+		return nil, nil
+	}
+
+	if certs, err = x509.ParseCertificates(raw.Bytes); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return certs, nil
+}
+
+func newHash(algorithm asn1.ObjectIdentifier) (hashFunc crypto.Hash, err error) {
+	switch {
+	case algorithm.Equal(oidSHA256):
+		return crypto.SHA256, nil
+
+	case algorithm.Equal(oidSHA384):
+		return crypto.SHA384, nil
+
+	case algorithm.Equal(oidSHA512):
+		return crypto.SHA512, nil
+
+	default:
+		return 0, aoserrors.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+}
+
+func calculateDigests(signers []signerInfo, content io.Reader) (digests map[crypto.Hash][]byte, err error) {
+	hashes := make(map[crypto.Hash]hash.Hash)
+	writers := make([]io.Writer, 0, len(signers))
+
+	for _, signer := range signers {
+		hashFunc, err := newHash(signer.DigestAlgorithm.Algorithm)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := hashes[hashFunc]; !ok {
+			hashes[hashFunc] = hashFunc.New()
+			writers = append(writers, hashes[hashFunc])
+		}
+	}
+
+	if len(hashes) == 0 {
+		return nil, aoserrors.New("no supported digest algorithm")
+	}
+
+	if _, err = io.Copy(io.MultiWriter(writers...), content); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	digests = make(map[crypto.Hash][]byte)
+
+	for hashFunc, digest := range hashes {
+		digests[hashFunc] = digest.Sum(nil)
+	}
+
+	return digests, nil
+}
+
+func verifySigner(
+	signer *signerInfo, certs []*x509.Certificate, digests map[crypto.Hash][]byte, roots,
+	intermediates *x509.CertPool,
+) (cert *x509.Certificate, err error) {
+	if len(signer.SignedAttrs.FullBytes) == 0 {
+		return nil, aoserrors.New("signed attributes are required")
+	}
+
+	hashFunc, err := newHash(signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkAttributes(signer.SignedAttrs, digests[hashFunc]); err != nil {
+		return nil, err
+	}
+
+	if cert, err = findCertificate(signer.SID, certs); err != nil {
+		return nil, err
+	}
+
+	algorithm, err := getSignatureAlgorithm(signer.SignatureAlgorithm.Algorithm, hashFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signature is made over DER encoded SET of signed attributes
+	signed := append([]byte{0x31}, signer.SignedAttrs.FullBytes[1:]...)
+
+	if err = cert.CheckSignature(algorithm, signed, signer.Signature); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if _, err = cert.Verify(x509.VerifyOptions{
+		Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return cert, nil
+}
+
+func checkAttributes(raw asn1.RawValue, digest []byte) (err error) {
+	var attrs []attribute
+
+	if _, err = asn1.UnmarshalWithParams(raw.FullBytes, &attrs, "set,tag:0"); err != nil {
+		return aoserrors.Errorf("invalid signed attributes: %v", err)
+	}
+
+	var contentTypeFound, digestFound bool
+
+	for _, attr := range attrs {
+		if len(attr.Values) != 1 {
+			return aoserrors.Errorf("wrong values count of attribute %s", attr.Type)
+		}
+
+		switch {
+		case attr.Type.Equal(oidContentType):
+			var contentType asn1.ObjectIdentifier
+
+			if _, err = asn1.Unmarshal(attr.Values[0].FullBytes, &contentType); err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+			if !contentType.Equal(oidData) {
+				return aoserrors.Errorf("unsupported signed content type %s", contentType)
+			}
+
+			contentTypeFound = true
+
+		case attr.Type.Equal(oidMessageDigest):
+			var messageDigest []byte
+
+			if _, err = asn1.Unmarshal(attr.Values[0].FullBytes, &messageDigest); err != nil {
+				return aoserrors.Wrap(err)
+			}
+
+			if !bytes.Equal(messageDigest, digest) {
+				return aoserrors.New("content digest mismatch")
+			}
+
+			digestFound = true
+		}
+	}
+
+	if !contentTypeFound || !digestFound {
+		return aoserrors.New("content type and message digest attributes are required")
+	}
+
+	return nil
+}
+
+func findCertificate(sid asn1.RawValue, certs []*x509.Certificate) (cert *x509.Certificate, err error) {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, cert := range certs {
+			if bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+
+		return nil, aoserrors.New("signer certificate not found")
+	}
+
+	var id issuerAndSerial
+
+	if _, err = asn1.Unmarshal(sid.FullBytes, &id); err != nil {
+		return nil, aoserrors.Errorf("invalid signer identifier: %v", err)
+	}
+
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, id.Issuer.FullBytes) && cert.SerialNumber.Cmp(id.Serial) == 0 {
+			return cert, nil
+		}
+	}
+
+	return nil, aoserrors.New("signer certificate not found")
+}
+
+func getSignatureAlgorithm(
+	oid asn1.ObjectIdentifier, hashFunc crypto.Hash,
+) (algorithm x509.SignatureAlgorithm, err error) {
+	switch {
+	case oid.Equal(oidEd25519):
+		return x509.PureEd25519, nil
+
+	case oid.Equal(oidRSA), oid.Equal(oidRSAWithSHA256), oid.Equal(oidRSAWithSHA384), oid.Equal(oidRSAWithSHA512):
+		algorithm = map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA256: x509.SHA256WithRSA, crypto.SHA384: x509.SHA384WithRSA, crypto.SHA512: x509.SHA512WithRSA,
+		}[hashFunc]
+
+	case oid.Equal(oidECDSA), oid.Equal(oidECDSAWithSHA256), oid.Equal(oidECDSAWithSHA384),
+		oid.Equal(oidECDSAWithSHA512):
+		algorithm = map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA256: x509.ECDSAWithSHA256, crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		}[hashFunc]
+	}
+
+	if algorithm == x509.UnknownSignatureAlgorithm {
+		return algorithm, aoserrors.Errorf("unsupported signature algorithm %s", oid)
+	}
+
+	return algorithm, nil
+}
+
+func marshalAttributes(digest []byte) (attrs []byte, err error) {
+	contentType, err := asn1.Marshal(oidData)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	messageDigest, err := asn1.Marshal(digest)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	if attrs, err = asn1.MarshalWithParams([]attribute{
+		{Type: oidContentType, Values: []asn1.RawValue{{FullBytes: contentType}}},
+		{Type: oidMessageDigest, Values: []asn1.RawValue{{FullBytes: messageDigest}}},
+	}, "set"); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return attrs, nil
+}
+
+func sha256Sum(data []byte) (digest []byte) {
+	hash := crypto.SHA256.New()
+	hash.Write(data)
+
+	return hash.Sum(nil)
+}
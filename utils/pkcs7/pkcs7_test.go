@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkcs7_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/utils/pkcs7"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestSignVerify(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %v", err)
+	}
+
+	caCert := createCertificate(t, "ca", caKey, nil, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Can't generate key: %v", err)
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %v", err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Can't generate key: %v", err)
+	}
+
+	content := bytes.Repeat([]byte("content"), 10000)
+
+	for _, key := range []crypto.Signer{rsaKey, ecdsaKey, ed25519Key} {
+		cert := createCertificate(t, "signer", key, caCert, caKey)
+
+		signature, err := pkcs7.Sign(bytes.NewReader(content), cert, key)
+		if err != nil {
+			t.Fatalf("Can't sign content: %v", err)
+		}
+
+		signer, err := pkcs7.Verify(signature, bytes.NewReader(content), roots)
+		if err != nil {
+			t.Errorf("Can't verify %T signature: %v", key, err)
+		} else if signer.Subject.CommonName != "signer" {
+			t.Errorf("Wrong signer: %s", signer.Subject)
+		}
+
+		pemSignature := pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: signature})
+
+		if _, err = pkcs7.Verify(pemSignature, bytes.NewReader(content), roots); err != nil {
+			t.Errorf("Can't verify PEM %T signature: %v", key, err)
+		}
+
+		if _, err = pkcs7.Verify(signature, bytes.NewReader(content[1:]), roots); err == nil ||
+			!strings.Contains(err.Error(), "content digest mismatch") {
+			t.Errorf("Content digest mismatch expected: %v", err)
+		}
+
+		if _, err = pkcs7.Verify(signature, bytes.NewReader(content), x509.NewCertPool()); err == nil ||
+			!strings.Contains(err.Error(), "unknown authority") {
+			t.Errorf("Unknown authority error expected: %v", err)
+		}
+	}
+
+	if _, err = pkcs7.Verify([]byte("invalid"), bytes.NewReader(content), roots); err == nil {
+		t.Error("Invalid signature error expected")
+	}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func createCertificate(
+	t *testing.T, name string, key crypto.Signer, parent *x509.Certificate, parentKey crypto.Signer,
+) (cert *x509.Certificate) {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("Can't create certificate: %v", err)
+	}
+
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("Can't parse certificate: %v", err)
+	}
+
+	return cert
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pstore decodes crash records preserved by pstore (e.g. ramoops) across reboot.
+package pstore
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+)
+
+// Kernel dump records (dmesg-*) are checked before console records (console-*) as they are written by the crashed
+// kernel itself. Kernel panic takes precedence over oops: oops is often the cause of the panic, but the panic line
+// tells why the system is down. Records are not removed as they may be archived by other services, e.g.
+// systemd-pstore.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Crash kinds.
+const (
+	KindKernelPanic = "kernelPanic"
+	KindKernelOops  = "kernelOops"
+)
+
+const (
+	dmesgPrefix   = "dmesg-"
+	consolePrefix = "console-"
+)
+
+const maxLineSize = 64 * 1024
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+// Path path to pstore filesystem.
+var Path = "/sys/fs/pstore" //nolint:gochecknoglobals // Used in unit tests to override path
+
+var (
+	//nolint:gochecknoglobals
+	logPrefixRegexp = regexp.MustCompile(`^(<\d+>)?(\[\s*\d+\.\d+\]\s*)?`)
+	//nolint:gochecknoglobals
+	panicRegexp = regexp.MustCompile(`Kernel panic - not syncing:\s*(.*)$`)
+	//nolint:gochecknoglobals
+	oopsRegexp = regexp.MustCompile(`(Oops[:\s].*|BUG: .*|Unable to handle kernel .*|general protection fault.*)$`)
+	//nolint:gochecknoglobals
+	headerRegexp = regexp.MustCompile(`^(Panic|Oops)#\d+ Part\d+`)
+)
+
+var kindTexts = map[string]string{ //nolint:gochecknoglobals
+	KindKernelPanic: "kernel panic",
+	KindKernelOops:  "kernel oops",
+}
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// CrashReason summarized crash reason.
+type CrashReason struct {
+	Kind   string `json:"kind"`
+	Reason string `json:"reason,omitempty"`
+	Record string `json:"record"`
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// ReadCrashReason returns crash reason found in pstore records or nil if there is no crash record.
+func ReadCrashReason(path string) (reason *CrashReason, err error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, aoserrors.Wrap(err)
+	}
+
+	var records []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() &&
+			(strings.HasPrefix(entry.Name(), dmesgPrefix) || strings.HasPrefix(entry.Name(), consolePrefix)) {
+			records = append(records, entry.Name())
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if dmesgI, dmesgJ := strings.HasPrefix(records[i], dmesgPrefix),
+			strings.HasPrefix(records[j], dmesgPrefix); dmesgI != dmesgJ {
+			return dmesgI
+		}
+
+		return records[i] < records[j]
+	})
+
+	for _, record := range records {
+		recordReason, err := readRecord(filepath.Join(path, record))
+		if err != nil {
+			return nil, err
+		}
+
+		if recordReason == nil {
+			continue
+		}
+
+		recordReason.Record = record
+
+		if recordReason.Kind == KindKernelPanic {
+			return recordReason, nil
+		}
+
+		if reason == nil {
+			reason = recordReason
+		}
+	}
+
+	return reason, nil
+}
+
+// String returns crash reason as text.
+func (reason *CrashReason) String() (text string) {
+	text = kindTexts[reason.Kind]
+	if text == "" {
+		text = reason.Kind
+	}
+
+	if reason.Reason != "" {
+		text += ": " + reason.Reason
+	}
+
+	return text
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func readRecord(fileName string) (reason *CrashReason, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+
+	var header, oopsReason string
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(logPrefixRegexp.ReplaceAllString(scanner.Text(), ""))
+
+		if match := panicRegexp.FindStringSubmatch(line); match != nil {
+			return &CrashReason{Kind: KindKernelPanic, Reason: strings.TrimSpace(match[1])}, nil
+		}
+
+		if match := headerRegexp.FindStringSubmatch(line); match != nil && header == "" {
+			header = match[1]
+
+			continue
+		}
+
+		if match := oopsRegexp.FindStringSubmatch(line); match != nil && oopsReason == "" {
+			oopsReason = strings.TrimSpace(match[1])
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	// Panic record may be truncated before panic line, oops is the best known reason then
+	switch {
+	case header == "Panic":
+		return &CrashReason{Kind: KindKernelPanic, Reason: oopsReason}, nil
+
+	case header == "Oops" || oopsReason != "":
+		return &CrashReason{Kind: KindKernelOops, Reason: oopsReason}, nil
+
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pstore_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/utils/pstore"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestReadCrashReason(t *testing.T) {
+	type testData struct {
+		records map[string]string
+		reason  *pstore.CrashReason
+		text    string
+	}
+
+	const (
+		oopsRecord = "Oops#1 Part1\n" +
+			"<1>[    2.100000] Unable to handle kernel NULL pointer dereference at virtual address 0000000000000008\n" +
+			"<0>[    2.100100] Internal error: Oops: 96000005 [#1] PREEMPT SMP\n"
+		panicRecord = "Panic#1 Part1\n" +
+			"<4>[    1.500000] VFS: Cannot open root device \"mmcblk0p2\"\n" +
+			"<0>[    1.500100] Kernel panic - not syncing: VFS: Unable to mount root fs on unknown-block(179,2)\n"
+	)
+
+	data := []testData{
+		{},
+		{records: map[string]string{"pmsg-ramoops-0": "Kernel panic - not syncing: ignored"}},
+		{records: map[string]string{"console-ramoops-0": "[    1.000000] Booting Linux\n"}},
+		{
+			records: map[string]string{"dmesg-ramoops-0": panicRecord},
+			reason: &pstore.CrashReason{
+				Kind:   pstore.KindKernelPanic,
+				Reason: "VFS: Unable to mount root fs on unknown-block(179,2)",
+				Record: "dmesg-ramoops-0",
+			},
+			text: "kernel panic: VFS: Unable to mount root fs on unknown-block(179,2)",
+		},
+		{
+			records: map[string]string{"dmesg-ramoops-0": oopsRecord},
+			reason: &pstore.CrashReason{
+				Kind: pstore.KindKernelOops, Record: "dmesg-ramoops-0",
+				Reason: "Unable to handle kernel NULL pointer dereference at virtual address 0000000000000008",
+			},
+			text: "kernel oops: Unable to handle kernel NULL pointer dereference at virtual address 0000000000000008",
+		},
+		{
+			records: map[string]string{"dmesg-ramoops-0": oopsRecord, "dmesg-ramoops-1": panicRecord},
+			reason: &pstore.CrashReason{
+				Kind:   pstore.KindKernelPanic,
+				Reason: "VFS: Unable to mount root fs on unknown-block(179,2)",
+				Record: "dmesg-ramoops-1",
+			},
+			text: "kernel panic: VFS: Unable to mount root fs on unknown-block(179,2)",
+		},
+		{
+			records: map[string]string{
+				"console-ramoops-0": "[    3.000000] Kernel panic - not syncing: Attempted to kill init!\n",
+				"dmesg-ramoops-0":   "Panic#1 Part2\n[    2.900000] BUG: scheduling while atomic: init/1\n",
+			},
+			reason: &pstore.CrashReason{
+				Kind: pstore.KindKernelPanic, Reason: "BUG: scheduling while atomic: init/1", Record: "dmesg-ramoops-0",
+			},
+			text: "kernel panic: BUG: scheduling while atomic: init/1",
+		},
+	}
+
+	for i, item := range data {
+		path := t.TempDir()
+
+		for name, content := range item.records {
+			if err := os.WriteFile(filepath.Join(path, name), []byte(content), 0o600); err != nil {
+				t.Fatalf("Can't write record: %v", err)
+			}
+		}
+
+		reason, err := pstore.ReadCrashReason(path)
+		if err != nil {
+			t.Errorf("Can't read crash reason: %v", err)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(reason, item.reason) {
+			t.Errorf("Wrong crash reason %d: %+v", i, reason)
+		}
+
+		if reason != nil && reason.String() != item.text {
+			t.Errorf("Wrong crash reason text %d: %s", i, reason)
+		}
+	}
+
+	if reason, err := pstore.ReadCrashReason(filepath.Join(t.TempDir(), "notexist")); err != nil || reason != nil {
+		t.Errorf("Wrong crash reason of not existing pstore: %v, error: %v", reason, err)
+	}
+}
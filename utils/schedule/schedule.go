@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schedule provides timezone aware cron-like schedules.
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Schedule time zones should be available on devices without system zoneinfo
+
+	"github.com/aoscloud/aos_common/aoserrors"
+)
+
+// Schedule is standard 5 fields cron expression: minute, hour, day of month, month and day of week. Fields accept
+// "*", values, ranges, lists and steps. Expression may be prefixed by "TZ=<zone>" or "CRON_TZ=<zone>", otherwise
+// it is evaluated in local time zone. Occurrences are computed in wall clock time of the schedule time zone:
+// occurrence which falls into DST gap is shifted forward by the gap, occurrence which is repeated on DST fall back
+// happens only once at its first instant.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Max number of days to search for the next occurrence, covers e.g. February 29 on Monday.
+const maxSearchDays = 366 * 28
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Schedule cron-like schedule.
+type Schedule struct {
+	expr     string
+	location *time.Location
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	anyDay   bool
+	anyWeek  bool
+}
+
+type fieldRange struct {
+	name     string
+	min, max int
+}
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+//nolint:gochecknoglobals
+var fieldRanges = []fieldRange{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// Parse parses schedule expression.
+func Parse(expr string) (schedule *Schedule, err error) {
+	schedule = &Schedule{expr: expr, location: time.Local}
+	fields := strings.Fields(expr)
+
+	if len(fields) != 0 {
+		for _, prefix := range []string{"TZ=", "CRON_TZ="} {
+			if zone, ok := strings.CutPrefix(fields[0], prefix); ok {
+				if schedule.location, err = time.LoadLocation(zone); err != nil {
+					return nil, aoserrors.Errorf("wrong schedule time zone %s", zone)
+				}
+
+				fields = fields[1:]
+
+				break
+			}
+		}
+	}
+
+	if len(fields) != len(fieldRanges) {
+		return nil, aoserrors.Errorf("wrong schedule %q: %d fields expected", expr, len(fieldRanges))
+	}
+
+	values := []*uint64{
+		&schedule.minutes, &schedule.hours, &schedule.days, &schedule.months, &schedule.weekdays,
+	}
+
+	for i, field := range fields {
+		if *values[i], err = parseField(field, fieldRanges[i]); err != nil {
+			return nil, aoserrors.Errorf("wrong schedule %q: %v", expr, err)
+		}
+	}
+
+	// Sunday may be set as 0 or 7
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	schedule.anyDay = fields[2] == "*"
+	schedule.anyWeek = fields[4] == "*"
+
+	return schedule, nil
+}
+
+// String returns schedule expression.
+func (schedule *Schedule) String() string {
+	return schedule.expr
+}
+
+// Location returns schedule time zone.
+func (schedule *Schedule) Location() (location *time.Location) {
+	return schedule.location
+}
+
+// Next returns the first occurrence after specified time. Zero time is returned if schedule never occurs.
+func (schedule *Schedule) Next(after time.Time) (next time.Time) {
+	local := after.In(schedule.location)
+	year, month, day := local.Date()
+
+	for i := 0; i < maxSearchDays; i++ {
+		date := time.Date(year, month, day+i, 12, 0, 0, 0, schedule.location)
+
+		if !schedule.matchDate(date) {
+			continue
+		}
+
+		for hour := 0; hour < 24; hour++ {
+			if schedule.hours&(1<<hour) == 0 {
+				continue
+			}
+
+			for minute := 0; minute < 60; minute++ {
+				if schedule.minutes&(1<<minute) == 0 {
+					continue
+				}
+
+				if next = schedule.occurrence(date, hour, minute); next.After(after) {
+					return next
+				}
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func parseField(field string, fieldRange fieldRange) (bits uint64, err error) {
+	for _, item := range strings.Split(field, ",") {
+		rangeItem, stepItem, hasStep := strings.Cut(item, "/")
+		step := 1
+
+		if hasStep {
+			if step, err = strconv.Atoi(stepItem); err != nil || step <= 0 {
+				return 0, aoserrors.Errorf("wrong %s step %s", fieldRange.name, stepItem)
+			}
+		}
+
+		start, end := fieldRange.min, fieldRange.max
+
+		if rangeItem != "*" {
+			startItem, endItem, hasEnd := strings.Cut(rangeItem, "-")
+
+			if start, err = parseValue(startItem, fieldRange); err != nil {
+				return 0, err
+			}
+
+			end = start
+
+			if hasEnd {
+				if end, err = parseValue(endItem, fieldRange); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = fieldRange.max
+			}
+
+			if start > end {
+				return 0, aoserrors.Errorf("wrong %s range %s", fieldRange.name, rangeItem)
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << value
+		}
+	}
+
+	return bits, nil
+}
+
+func parseValue(item string, fieldRange fieldRange) (value int, err error) {
+	if value, err = strconv.Atoi(item); err != nil || value < fieldRange.min || value > fieldRange.max {
+		return 0, aoserrors.Errorf("wrong %s value %s", fieldRange.name, item)
+	}
+
+	return value, nil
+}
+
+// matchDate checks date fields. As in cron, if both day of month and day of week are restricted, date matches any
+// of them.
+func (schedule *Schedule) matchDate(date time.Time) (matches bool) {
+	if schedule.months&(1<<int(date.Month())) == 0 {
+		return false
+	}
+
+	dayMatches := schedule.days&(1<<date.Day()) != 0
+	weekMatches := schedule.weekdays&(1<<int(date.Weekday())) != 0
+
+	switch {
+	case schedule.anyDay && schedule.anyWeek:
+		return true
+
+	case schedule.anyDay:
+		return weekMatches
+
+	case schedule.anyWeek:
+		return dayMatches
+
+	default:
+		return dayMatches || weekMatches
+	}
+}
+
+// occurrence returns the first instant of the wall clock time on specified date.
+func (schedule *Schedule) occurrence(date time.Time, hour, minute int) (instant time.Time) {
+	year, month, day := date.Date()
+
+	// Wall clock time in DST gap is normalized forward by the gap
+	instant = time.Date(year, month, day, hour, minute, 0, 0, schedule.location)
+
+	// Wall clock time repeated on fall back has two instants, take the earlier one
+	for _, offset := range []time.Duration{-time.Hour, -30 * time.Minute} {
+		earlier := instant.Add(offset)
+
+		if earlier.Hour() == hour && earlier.Minute() == minute && earlier.Day() == day {
+			return earlier
+		}
+	}
+
+	return instant
+}
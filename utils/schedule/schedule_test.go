@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aoscloud/aos_updatemanager/utils/schedule"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestNext(t *testing.T) {
+	type testData struct {
+		expr  string
+		after string
+		next  string
+	}
+
+	data := []testData{
+		{expr: "TZ=UTC 0 2 * * *", after: "2026-03-10T01:00:00Z", next: "2026-03-10T02:00:00Z"},
+		{expr: "TZ=UTC 0 2 * * *", after: "2026-03-10T02:00:00Z", next: "2026-03-11T02:00:00Z"},
+		{expr: "TZ=UTC */15 * * * *", after: "2026-03-10T02:07:00Z", next: "2026-03-10T02:15:00Z"},
+		{expr: "TZ=UTC 30 1-3/2 * * *", after: "2026-03-10T01:30:00Z", next: "2026-03-10T03:30:00Z"},
+		{expr: "TZ=UTC 0 0 29 2 *", after: "2026-01-01T00:00:00Z", next: "2028-02-29T00:00:00Z"},
+		{expr: "TZ=UTC 0 0 1 * 1", after: "2026-03-10T00:00:00Z", next: "2026-03-16T00:00:00Z"},
+		{expr: "TZ=UTC 0 0 * * 7", after: "2026-03-10T00:00:00Z", next: "2026-03-15T00:00:00Z"},
+		// 02:00 local is 01:00 UTC in winter and 00:00 UTC in summer
+		{expr: "CRON_TZ=Europe/Berlin 0 2 * * *", after: "2026-01-10T12:00:00Z", next: "2026-01-11T01:00:00Z"},
+		{expr: "CRON_TZ=Europe/Berlin 0 2 * * *", after: "2026-07-10T12:00:00Z", next: "2026-07-11T00:00:00Z"},
+		// 02:30 doesn't exist on spring forward and is shifted to 03:30 CEST
+		{expr: "TZ=Europe/Berlin 30 2 * * *", after: "2026-03-28T12:00:00Z", next: "2026-03-29T01:30:00Z"},
+		{expr: "TZ=Europe/Berlin 30 2 * * *", after: "2026-03-29T01:30:00Z", next: "2026-03-30T00:30:00Z"},
+		// 02:30 is repeated on fall back and occurs only once
+		{expr: "TZ=Europe/Berlin 30 2 * * *", after: "2026-10-24T12:00:00Z", next: "2026-10-25T00:30:00Z"},
+		{expr: "TZ=Europe/Berlin 30 2 * * *", after: "2026-10-25T00:30:00Z", next: "2026-10-26T01:30:00Z"},
+		{expr: "TZ=UTC 0 0 31 2 *", after: "2026-01-01T00:00:00Z", next: "0001-01-01T00:00:00Z"},
+	}
+
+	for _, item := range data {
+		sched, err := schedule.Parse(item.expr)
+		if err != nil {
+			t.Errorf("Can't parse schedule %s: %v", item.expr, err)
+			continue
+		}
+
+		after, _ := time.Parse(time.RFC3339, item.after)
+		expected, _ := time.Parse(time.RFC3339, item.next)
+
+		if next := sched.Next(after); !next.Equal(expected) {
+			t.Errorf("Wrong next time of %s after %s: %s", item.expr, item.after, next.UTC().Format(time.RFC3339))
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"", "0 2 * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * 8", "5-1 * * * *",
+		"*/0 * * * *", "a * * * *", "TZ=Mars/Base 0 2 * * *",
+	} {
+		if _, err := schedule.Parse(expr); err == nil {
+			t.Errorf("Error expected for schedule %q", expr)
+		}
+	}
+}
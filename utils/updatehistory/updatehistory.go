@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package updatehistory records update history: handler state transitions and component operations of update
+// sessions.
+package updatehistory
+
+import (
+	"time"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// History is append-only: entries are never modified, only the oldest entries are dropped by storage to bound its
+// size. Update session starts with prepare and lasts till the handler returns to idle, all entries of the session
+// share its ID.
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// History entry types.
+const (
+	EntryTransition = "transition"
+	EntryOperation  = "operation"
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Entry update history entry.
+type Entry struct {
+	Instance      string          `json:"instance,omitempty"`
+	Session       string          `json:"session"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Type          string          `json:"type"`
+	Event         string          `json:"event,omitempty"`
+	From          string          `json:"from,omitempty"`
+	To            string          `json:"to,omitempty"`
+	ID            string          `json:"id,omitempty"`
+	Phase         string          `json:"phase,omitempty"`
+	VendorVersion string          `json:"vendorVersion,omitempty"`
+	AosVersion    uint64          `json:"aosVersion,omitempty"`
+	Duration      time.Duration   `json:"duration,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Journal       *JournalCursors `json:"journal,omitempty"`
+}
+
+// JournalCursors journald cursors captured at the start and the end of recorded transition or operation. They are
+// used to extract logs of the phase.
+type JournalCursors struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// Storage update history storage interface.
+type Storage interface {
+	AddHistoryEntry(entry Entry) (err error)
+	GetHistoryEntries(session string) (entries []Entry, err error)
+}
+
+// History update history.
+type History struct {
+	instance string
+	storage  Storage
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// New creates update history of update manager instance. If storage doesn't implement history storage, entries are
+// only logged.
+func New(instance string, storage interface{}) (history *History) {
+	history = &History{instance: instance}
+
+	history.storage, _ = storage.(Storage)
+
+	return history
+}
+
+// Transition records handler state transition.
+func (history *History) Transition(
+	session, event, from, to string, timestamp time.Time, errStr string, journal *JournalCursors,
+) {
+	history.add(Entry{
+		Session: session, Timestamp: timestamp, Type: EntryTransition, Event: event, From: from, To: to,
+		Error: errStr, Journal: journal,
+	})
+}
+
+// Operation records component operation started at startTime.
+func (history *History) Operation(
+	session, id, phase, vendorVersion string, aosVersion uint64, startTime time.Time, duration time.Duration, err error,
+	journal *JournalCursors,
+) {
+	entry := Entry{
+		Session: session, Timestamp: startTime, Type: EntryOperation, ID: id, Phase: phase,
+		VendorVersion: vendorVersion, AosVersion: aosVersion, Duration: duration, Journal: journal,
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	history.add(entry)
+}
+
+// Entries returns history entries of the session or all entries if session is empty.
+func (history *History) Entries(session string) (entries []Entry, err error) {
+	if history.storage == nil {
+		return nil, aoserrors.New("update history storage is not available")
+	}
+
+	if entries, err = history.storage.GetHistoryEntries(session); err != nil {
+		return nil, aoserrors.Wrap(err)
+	}
+
+	return entries, nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func (history *History) add(entry Entry) {
+	entry.Instance = history.instance
+
+	log.WithFields(log.Fields{
+		"instance": entry.Instance, "session": entry.Session, "type": entry.Type, "event": entry.Event, "from": entry.From, "to": entry.To,
+		"id": entry.ID, "phase": entry.Phase, "error": entry.Error,
+	}).Debug("Update history")
+
+	if history.storage == nil {
+		return
+	}
+
+	if err := history.storage.AddHistoryEntry(entry); err != nil {
+		log.Errorf("Can't add update history entry: %v", err)
+	}
+}
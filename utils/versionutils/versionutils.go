@@ -0,0 +1,374 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package versionutils provides helpers to normalize and compare component vendor versions.
+package versionutils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+)
+
+/***********************************************************************************************************************
+ * Consts
+ **********************************************************************************************************************/
+
+// Version schemes.
+const (
+	SchemeAuto Scheme = iota
+	SchemeSemver
+	SchemeDebian
+	SchemePlain
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+//nolint:gochecknoglobals
+var (
+	semverExp = regexp.MustCompile(
+		`^(0|[1-9]\d*)(?:\.(0|[1-9]\d*))?(?:\.(0|[1-9]\d*))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+	debianExp = regexp.MustCompile(`^(?:(\d+):)?(\d[0-9A-Za-z.+~-]*?)(?:-([0-9A-Za-z.+~]+))?$`)
+)
+
+/***********************************************************************************************************************
+ * Types
+ **********************************************************************************************************************/
+
+// Scheme version scheme.
+type Scheme int
+
+type semver struct {
+	numbers    [3]uint64
+	prerelease []string
+}
+
+type debianVersion struct {
+	epoch    uint64
+	upstream string
+	revision string
+}
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// ParseScheme converts scheme name to scheme. Empty name means auto detection.
+func ParseScheme(name string) (scheme Scheme, err error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return SchemeAuto, nil
+
+	case "semver":
+		return SchemeSemver, nil
+
+	case "debian":
+		return SchemeDebian, nil
+
+	case "plain":
+		return SchemePlain, nil
+
+	default:
+		return SchemeAuto, aoserrors.Errorf("unsupported version scheme: %s", name)
+	}
+}
+
+// Normalize removes surrounding spaces and leading "v" prefix from the version string.
+func Normalize(version string) string {
+	version = strings.TrimSpace(version)
+
+	if len(version) > 1 && (version[0] == 'v' || version[0] == 'V') && version[1] >= '0' && version[1] <= '9' {
+		version = version[1:]
+	}
+
+	return version
+}
+
+// Compare compares two versions using scheme detected from version strings.
+// It returns -1 if version1 < version2, 0 if they are equal and 1 if version1 > version2.
+func Compare(version1, version2 string) (result int, err error) {
+	return CompareWithScheme(SchemeAuto, version1, version2)
+}
+
+// CompareWithScheme compares two versions using specified scheme.
+func CompareWithScheme(scheme Scheme, version1, version2 string) (result int, err error) {
+	version1, version2 = Normalize(version1), Normalize(version2)
+
+	if scheme == SchemeAuto {
+		scheme = detectScheme(version1, version2)
+	}
+
+	switch scheme {
+	case SchemeSemver:
+		return compareSemver(version1, version2)
+
+	case SchemeDebian:
+		return compareDebian(version1, version2)
+
+	case SchemePlain:
+		return strings.Compare(version1, version2), nil
+
+	default:
+		return 0, aoserrors.Errorf("unsupported version scheme: %d", scheme)
+	}
+}
+
+// Equal checks if two versions are equal using specified scheme.
+// Versions which can't be parsed with the scheme are compared as plain strings.
+func Equal(scheme Scheme, version1, version2 string) bool {
+	result, err := CompareWithScheme(scheme, version1, version2)
+	if err != nil {
+		return Normalize(version1) == Normalize(version2)
+	}
+
+	return result == 0
+}
+
+func (scheme Scheme) String() string {
+	return [...]string{"auto", "semver", "debian", "plain"}[scheme]
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func detectScheme(version1, version2 string) (scheme Scheme) {
+	if semverExp.MatchString(version1) && semverExp.MatchString(version2) {
+		return SchemeSemver
+	}
+
+	if debianExp.MatchString(version1) && debianExp.MatchString(version2) {
+		return SchemeDebian
+	}
+
+	return SchemePlain
+}
+
+func parseSemver(version string) (result semver, err error) {
+	matches := semverExp.FindStringSubmatch(version)
+	if matches == nil {
+		return result, aoserrors.Errorf("invalid semver version: %s", version)
+	}
+
+	for i := range result.numbers {
+		if matches[i+1] == "" {
+			continue
+		}
+
+		if result.numbers[i], err = strconv.ParseUint(matches[i+1], 10, 64); err != nil {
+			return result, aoserrors.Wrap(err)
+		}
+	}
+
+	if matches[4] != "" {
+		result.prerelease = strings.Split(matches[4], ".")
+	}
+
+	return result, nil
+}
+
+func compareSemver(version1, version2 string) (result int, err error) {
+	semver1, err := parseSemver(version1)
+	if err != nil {
+		return 0, err
+	}
+
+	semver2, err := parseSemver(version2)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range semver1.numbers {
+		if result = compareUint(semver1.numbers[i], semver2.numbers[i]); result != 0 {
+			return result, nil
+		}
+	}
+
+	// Version without prerelease has higher precedence
+
+	switch {
+	case len(semver1.prerelease) == 0 && len(semver2.prerelease) == 0:
+		return 0, nil
+
+	case len(semver1.prerelease) == 0:
+		return 1, nil
+
+	case len(semver2.prerelease) == 0:
+		return -1, nil
+	}
+
+	for i := 0; i < len(semver1.prerelease) && i < len(semver2.prerelease); i++ {
+		if result = comparePrereleaseID(semver1.prerelease[i], semver2.prerelease[i]); result != 0 {
+			return result, nil
+		}
+	}
+
+	return compareUint(uint64(len(semver1.prerelease)), uint64(len(semver2.prerelease))), nil
+}
+
+func comparePrereleaseID(id1, id2 string) (result int) {
+	num1, err1 := strconv.ParseUint(id1, 10, 64)
+	num2, err2 := strconv.ParseUint(id2, 10, 64)
+
+	switch {
+	case err1 == nil && err2 == nil:
+		return compareUint(num1, num2)
+
+	case err1 == nil:
+		return -1
+
+	case err2 == nil:
+		return 1
+
+	default:
+		return strings.Compare(id1, id2)
+	}
+}
+
+func parseDebian(version string) (result debianVersion, err error) {
+	matches := debianExp.FindStringSubmatch(version)
+	if matches == nil {
+		return result, aoserrors.Errorf("invalid debian version: %s", version)
+	}
+
+	if matches[1] != "" {
+		if result.epoch, err = strconv.ParseUint(matches[1], 10, 64); err != nil {
+			return result, aoserrors.Wrap(err)
+		}
+	}
+
+	result.upstream = matches[2]
+	result.revision = matches[3]
+
+	return result, nil
+}
+
+func compareDebian(version1, version2 string) (result int, err error) {
+	debian1, err := parseDebian(version1)
+	if err != nil {
+		return 0, err
+	}
+
+	debian2, err := parseDebian(version2)
+	if err != nil {
+		return 0, err
+	}
+
+	if result = compareUint(debian1.epoch, debian2.epoch); result != 0 {
+		return result, nil
+	}
+
+	if result = compareDebianPart(debian1.upstream, debian2.upstream); result != 0 {
+		return result, nil
+	}
+
+	return compareDebianPart(debian1.revision, debian2.revision), nil
+}
+
+// compareDebianPart implements dpkg comparison algorithm: alternating non-digit and digit parts,
+// non-digit parts are compared by modified ASCII order (tilde sorts before anything, letters before non-letters),
+// digit parts are compared numerically.
+func compareDebianPart(part1, part2 string) (result int) {
+	for part1 != "" || part2 != "" {
+		var str1, str2 string
+
+		str1, part1 = splitPrefix(part1, false)
+		str2, part2 = splitPrefix(part2, false)
+
+		if result = compareDebianString(str1, str2); result != 0 {
+			return result
+		}
+
+		str1, part1 = splitPrefix(part1, true)
+		str2, part2 = splitPrefix(part2, true)
+
+		num1, _ := strconv.ParseUint("0"+str1, 10, 64)
+		num2, _ := strconv.ParseUint("0"+str2, 10, 64)
+
+		if result = compareUint(num1, num2); result != 0 {
+			return result
+		}
+	}
+
+	return 0
+}
+
+func splitPrefix(str string, digits bool) (prefix, rest string) {
+	i := 0
+
+	for ; i < len(str); i++ {
+		if (str[i] >= '0' && str[i] <= '9') != digits {
+			break
+		}
+	}
+
+	return str[:i], str[i:]
+}
+
+func compareDebianString(str1, str2 string) (result int) {
+	for i := 0; i < len(str1) || i < len(str2); i++ {
+		var order1, order2 int
+
+		if i < len(str1) {
+			order1 = debianCharOrder(str1[i])
+		}
+
+		if i < len(str2) {
+			order2 = debianCharOrder(str2[i])
+		}
+
+		if order1 != order2 {
+			if order1 < order2 {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func debianCharOrder(char byte) (order int) {
+	switch {
+	case char == '~':
+		return -1
+
+	case (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z'):
+		return int(char)
+
+	default:
+		return int(char) + 256 //nolint:gomnd // non-letters sort after letters
+	}
+}
+
+func compareUint(value1, value2 uint64) (result int) {
+	switch {
+	case value1 < value2:
+		return -1
+
+	case value1 > value2:
+		return 1
+
+	default:
+		return 0
+	}
+}
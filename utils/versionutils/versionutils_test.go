@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package versionutils_test
+
+import (
+	"testing"
+
+	"github.com/aoscloud/aos_updatemanager/utils/versionutils"
+)
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestCompare(t *testing.T) {
+	type testData struct {
+		scheme   versionutils.Scheme
+		version1 string
+		version2 string
+		result   int
+	}
+
+	data := []testData{
+		{version1: "1.9", version2: "1.10", result: -1},
+		{version1: "1.10", version2: "1.9", result: 1},
+		{version1: "v1.2.3", version2: "1.2.3", result: 0},
+		{version1: "1.2", version2: "1.2.0", result: 0},
+		{version1: "1.0.0-rc.1", version2: "1.0.0", result: -1},
+		{version1: "1.0.0-alpha", version2: "1.0.0-alpha.1", result: -1},
+		{version1: "1.0.0-alpha.10", version2: "1.0.0-alpha.9", result: 1},
+		{version1: "1.0.0-2", version2: "1.0.0-beta", result: -1},
+		{version1: "1.0.0+build1", version2: "1.0.0+build2", result: 0},
+		{version1: "1:1.0", version2: "2.0", result: 1},
+		{version1: "1.0~rc1", version2: "1.0", result: -1},
+		{version1: "2.30-0ubuntu1", version2: "2.30-0ubuntu10", result: -1},
+		{version1: "1.2a", version2: "1.2+", result: -1},
+		{scheme: versionutils.SchemeDebian, version1: "1.0-rc1", version2: "1.0", result: 1},
+		{scheme: versionutils.SchemeSemver, version1: "1.0-rc1", version2: "1.0", result: -1},
+		{scheme: versionutils.SchemePlain, version1: "1.10", version2: "1.9", result: -1},
+		{version1: "release-b", version2: "release-a", result: 1},
+	}
+
+	for _, item := range data {
+		result, err := versionutils.CompareWithScheme(item.scheme, item.version1, item.version2)
+		if err != nil {
+			t.Errorf("Can't compare versions %s and %s: %v", item.version1, item.version2, err)
+			continue
+		}
+
+		if result != item.result {
+			t.Errorf("Wrong compare result for %s and %s (%s): %d", item.version1, item.version2,
+				item.scheme, result)
+		}
+	}
+}
+
+func TestWrongScheme(t *testing.T) {
+	if _, err := versionutils.CompareWithScheme(versionutils.SchemeSemver, "release-a", "1.0"); err == nil {
+		t.Error("Error expected for invalid semver version")
+	}
+
+	if _, err := versionutils.ParseScheme("unknown"); err == nil {
+		t.Error("Error expected for unknown scheme")
+	}
+
+	if !versionutils.Equal(versionutils.SchemeSemver, "release-a", " release-a ") {
+		t.Error("Versions should be equal")
+	}
+}
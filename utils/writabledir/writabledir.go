@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package writabledir relocates directories located on read-only filesystem to writable storage and checks free
+// space of directories.
+package writabledir
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/aoscloud/aos_common/aoserrors"
+	aosfs "github.com/aoscloud/aos_common/utils/fs"
+	log "github.com/sirupsen/logrus"
+)
+
+/***********************************************************************************************************************
+ * Public
+ **********************************************************************************************************************/
+
+// IsReadOnly checks if path or its nearest existing parent is located on read-only filesystem.
+func IsReadOnly(path string) (readOnly bool, err error) {
+	existingPath, err := getExistingPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	var stat syscall.Statfs_t
+
+	if err = syscall.Statfs(existingPath, &stat); err != nil {
+		return false, aoserrors.Wrap(err)
+	}
+
+	return stat.Flags&syscall.MS_RDONLY != 0, nil
+}
+
+// Prepare makes sure dir is writable. If dir is located on read-only filesystem, it is relocated to the same path
+// inside writable root. Existing dir is bind-mounted from writable root, not existing dir is replaced with new
+// location which is returned. It also checks that filesystem containing dir has at least minFreeSpace bytes and
+// minFreeInodes inodes available.
+func Prepare(dir, writableRoot string, minFreeSpace, minFreeInodes uint64) (newDir string, err error) {
+	if dir == "" {
+		return dir, nil
+	}
+
+	if newDir, err = relocate(dir, writableRoot); err != nil {
+		return "", err
+	}
+
+	if err = CheckFreeSpace(newDir, minFreeSpace, minFreeInodes); err != nil {
+		return "", err
+	}
+
+	return newDir, nil
+}
+
+// CheckFreeSpace checks that filesystem containing dir or its nearest existing parent has at least minFreeSpace bytes
+// and minFreeInodes inodes available. Zero limit is not checked. Inodes are not checked on filesystems which allocate
+// them dynamically and report zero inode count.
+func CheckFreeSpace(dir string, minFreeSpace, minFreeInodes uint64) (err error) {
+	if minFreeSpace == 0 && minFreeInodes == 0 {
+		return nil
+	}
+
+	existingPath, err := getExistingPath(dir)
+	if err != nil {
+		return err
+	}
+
+	var stat syscall.Statfs_t
+
+	if err = syscall.Statfs(existingPath, &stat); err != nil {
+		return aoserrors.Wrap(err)
+	}
+
+	if availableSize := stat.Bavail * uint64(stat.Bsize); availableSize < minFreeSpace {
+		return aoserrors.Errorf("not enough free space in %s: available %d, required %d", dir, availableSize,
+			minFreeSpace)
+	}
+
+	if stat.Files != 0 && stat.Ffree < minFreeInodes {
+		return aoserrors.Errorf("no inodes in %s: available %d, required %d", dir, stat.Ffree, minFreeInodes)
+	}
+
+	return nil
+}
+
+/***********************************************************************************************************************
+ * Private
+ **********************************************************************************************************************/
+
+func relocate(dir, writableRoot string) (newDir string, err error) {
+	readOnly, err := IsReadOnly(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if !readOnly {
+		return dir, nil
+	}
+
+	if writableRoot == "" {
+		return "", aoserrors.Errorf("dir %s is on read-only filesystem and writable storage is not configured", dir)
+	}
+
+	newDir = filepath.Join(writableRoot, dir)
+
+	log.WithFields(log.Fields{"dir": dir, "newDir": newDir}).Info("Relocate dir from read-only filesystem")
+
+	if err = os.MkdirAll(newDir, 0o755); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	if _, err = os.Stat(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return newDir, nil
+		}
+
+		return "", aoserrors.Wrap(err)
+	}
+
+	if err = aosfs.Mount(newDir, dir, "", syscall.MS_BIND, ""); err != nil {
+		return "", aoserrors.Wrap(err)
+	}
+
+	return dir, nil
+}
+
+func getExistingPath(path string) (existingPath string, err error) {
+	existingPath = filepath.Clean(path)
+
+	for {
+		if _, err = os.Stat(existingPath); err == nil {
+			return existingPath, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", aoserrors.Wrap(err)
+		}
+
+		parent := filepath.Dir(existingPath)
+		if parent == existingPath {
+			return "", aoserrors.Wrap(err)
+		}
+
+		existingPath = parent
+	}
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+//
+// Copyright (C) 2024 Renesas Electronics Corporation.
+// Copyright (C) 2024 EPAM Systems, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writabledir_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/aoscloud/aos_common/utils/fs"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aoscloud/aos_updatemanager/utils/writabledir"
+)
+
+/***********************************************************************************************************************
+ * Vars
+ **********************************************************************************************************************/
+
+var tmpDir string
+
+/***********************************************************************************************************************
+ * Init
+ **********************************************************************************************************************/
+
+func init() {
+	log.SetFormatter(&log.TextFormatter{
+		DisableTimestamp: false,
+		TimestampFormat:  "2006-01-02 15:04:05.000",
+		FullTimestamp:    true,
+	})
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(os.Stdout)
+}
+
+/***********************************************************************************************************************
+ * Main
+ **********************************************************************************************************************/
+
+func TestMain(m *testing.M) {
+	var err error
+
+	tmpDir, err = os.MkdirTemp("", "um_")
+	if err != nil {
+		log.Fatalf("Error create temporary dir: %s", err)
+	}
+
+	ret := m.Run()
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		log.Fatalf("Error removing tmp dir: %s", err)
+	}
+
+	os.Exit(ret)
+}
+
+/***********************************************************************************************************************
+ * Tests
+ **********************************************************************************************************************/
+
+func TestRelocate(t *testing.T) {
+	readOnlyDir := filepath.Join(tmpDir, "readonly")
+	writableRoot := filepath.Join(tmpDir, "writable")
+
+	if err := fs.Mount("tmpfs", readOnlyDir, "tmpfs", 0, ""); err != nil {
+		t.Fatalf("Can't mount tmpfs: %v", err)
+	}
+	defer fs.Umount(readOnlyDir) //nolint:errcheck
+
+	existingDir := filepath.Join(readOnlyDir, "existing")
+
+	if err := os.MkdirAll(existingDir, 0o755); err != nil {
+		t.Fatalf("Can't create dir: %v", err)
+	}
+
+	if err := syscall.Mount("", readOnlyDir, "", syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+		t.Fatalf("Can't remount tmpfs: %v", err)
+	}
+
+	notExistingDir := filepath.Join(readOnlyDir, "download")
+
+	readOnly, err := writabledir.IsReadOnly(notExistingDir)
+	if err != nil {
+		t.Fatalf("Can't check read-only: %v", err)
+	}
+
+	if !readOnly {
+		t.Error("Dir should be read-only")
+	}
+
+	if _, err = writabledir.Prepare(notExistingDir, "", 0, 0); err == nil {
+		t.Error("Error expected if writable storage is not configured")
+	}
+
+	newDir, err := writabledir.Prepare(notExistingDir, writableRoot, 0, 0)
+	if err != nil {
+		t.Fatalf("Can't prepare dir: %v", err)
+	}
+
+	if newDir != filepath.Join(writableRoot, notExistingDir) {
+		t.Errorf("Wrong relocated dir: %s", newDir)
+	}
+
+	if newDir, err = writabledir.Prepare(existingDir, writableRoot, 0, 0); err != nil {
+		t.Fatalf("Can't prepare dir: %v", err)
+	}
+	defer fs.Umount(existingDir) //nolint:errcheck
+
+	if newDir != existingDir {
+		t.Errorf("Wrong relocated dir: %s", newDir)
+	}
+
+	if err = os.WriteFile(filepath.Join(existingDir, "file"), []byte("data"), 0o600); err != nil {
+		t.Errorf("Can't write to relocated dir: %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(writableRoot, existingDir, "file")); err != nil {
+		t.Errorf("File should be created in writable storage: %v", err)
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	dir := filepath.Join(tmpDir, "workDir")
+
+	newDir, err := writabledir.Prepare(dir, "", 1, 1)
+	if err != nil {
+		t.Fatalf("Can't prepare dir: %v", err)
+	}
+
+	if newDir != dir {
+		t.Errorf("Wrong dir: %s", newDir)
+	}
+
+	if _, err = writabledir.Prepare(dir, "", math.MaxUint64, 0); err == nil {
+		t.Error("Not enough space error expected")
+	}
+}
+
+func TestFreeInodes(t *testing.T) {
+	var stat syscall.Statfs_t
+
+	if err := syscall.Statfs(tmpDir, &stat); err != nil {
+		t.Fatalf("Can't get filesystem stat: %v", err)
+	}
+
+	if stat.Files == 0 {
+		t.Skip("Filesystem doesn't report inode count")
+	}
+
+	dir := filepath.Join(tmpDir, "inodesDir")
+
+	if err := writabledir.CheckFreeSpace(dir, 0, 1); err != nil {
+		t.Errorf("Can't check free space: %v", err)
+	}
+
+	if err := writabledir.CheckFreeSpace(dir, 0, math.MaxUint64); err == nil ||
+		!strings.Contains(err.Error(), "no inodes") {
+		t.Errorf("No inodes error expected: %v", err)
+	}
+}